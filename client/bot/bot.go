@@ -12,13 +12,17 @@ import (
 	"github.com/gotd/td/telegram/dcs"
 	"github.com/gotd/td/tg"
 	"github.com/krau/SaveAny-Bot/client/bot/handlers"
+	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/shortcut"
 	"github.com/krau/SaveAny-Bot/client/middleware"
 	"github.com/krau/SaveAny-Bot/common/utils/netutil"
 	"github.com/krau/SaveAny-Bot/config"
+	"github.com/krau/SaveAny-Bot/pkg/dlpool"
 	"github.com/ncruces/go-sqlite3/gormlite"
 	"golang.org/x/net/proxy"
 )
 
+var botClient *gotgproto.Client
+
 func Init(ctx context.Context) {
 	log.FromContext(ctx).Info("初始化 Bot...")
 	resultChan := make(chan struct {
@@ -42,6 +46,7 @@ func Init(ctx context.Context) {
 		} else {
 			resolver = dcs.DefaultResolver()
 		}
+		account := dlpool.NewAccount("bot")
 		client, err := gotgproto.NewClient(
 			config.Cfg.Telegram.AppID,
 			config.Cfg.Telegram.AppHash,
@@ -49,7 +54,7 @@ func Init(ctx context.Context) {
 			&gotgproto.ClientOpts{
 				Session:          sessionMaker.SqlSession(gormlite.Open(config.Cfg.DB.Session)),
 				DisableCopyright: true,
-				Middlewares:      middleware.NewDefaultMiddlewares(ctx, 5*time.Minute),
+				Middlewares:      append(middleware.NewDefaultMiddlewares(ctx, 5*time.Minute), account.Middleware()),
 				Resolver:         resolver,
 				Context:          ctx,
 				MaxRetries:       config.Cfg.Telegram.RpcRetry,
@@ -67,6 +72,8 @@ func Init(ctx context.Context) {
 			}{nil, err}
 			return
 		}
+		account.Bind(client.API(), client.CreateContext())
+		dlpool.Default().Register(account)
 		client.API().BotsSetBotCommands(ctx, &tg.BotsSetBotCommandsRequest{
 			Scope: &tg.BotCommandScopeDefault{},
 		})
@@ -74,14 +81,17 @@ func Init(ctx context.Context) {
 			{Command: "start", Description: "开始使用"},
 			{Command: "help", Description: "显示帮助"},
 			{Command: "silent", Description: "开启/关闭静默模式"},
+			{Command: "dashboard", Description: "开启/关闭任务面板"},
 			{Command: "storage", Description: "设置默认存储端"},
 			{Command: "save", Description: "保存文件"},
+			{Command: "archive", Description: "归档聊天"},
 			{Command: "dir", Description: "管理存储文件夹"},
 			{Command: "rule", Description: "管理规则"},
 		}
 		if config.Cfg.Telegram.Userbot.Enable {
 			commands = append(commands, tg.BotCommand{Command: "watch", Description: "监听聊天"})
 			commands = append(commands, tg.BotCommand{Command: "unwatch", Description: "取消监听聊天"})
+			commands = append(commands, tg.BotCommand{Command: "stories", Description: "保存用户或频道的故事"})
 		}
 		_, err = client.API().BotsSetBotCommands(ctx, &tg.BotsSetBotCommandsRequest{
 			Scope:    &tg.BotCommandScopeDefault{},
@@ -100,7 +110,18 @@ func Init(ctx context.Context) {
 		if result.err != nil {
 			log.FromContext(ctx).Fatalf("初始化 Bot 失败: %s", result.err)
 		}
+		botClient = result.client
 		handlers.Register(result.client.Dispatcher)
 		log.FromContext(ctx).Info("Bot 初始化完成")
 	}
 }
+
+// 恢复上次关闭时保存的任务, 需要在 core.Run 之后调用
+func ResumeTasks(ctx context.Context) {
+	if botClient == nil {
+		return
+	}
+	ectx := botClient.CreateContext()
+	ectx.Context = ctx
+	shortcut.ResumeCheckpoints(ectx)
+}
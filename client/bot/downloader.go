@@ -0,0 +1,76 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/celestix/gotgproto"
+	"github.com/celestix/gotgproto/sessionMaker"
+	"github.com/charmbracelet/log"
+	"github.com/krau/SaveAny-Bot/client/middleware"
+	userclient "github.com/krau/SaveAny-Bot/client/user"
+	"github.com/krau/SaveAny-Bot/common/utils/netutil"
+	"github.com/krau/SaveAny-Bot/config"
+	"github.com/krau/SaveAny-Bot/pkg/dlpool"
+	"github.com/ncruces/go-sqlite3/gormlite"
+)
+
+// 登录配置中额外的 bot 和用户会话, 加入下载账号池. 登录失败的账号会被跳过
+func InitDownloadPool(ctx context.Context) {
+	if !config.Cfg.Telegram.DownloadPool.Enable {
+		return
+	}
+	logger := log.FromContext(ctx)
+	for i, token := range config.Cfg.Telegram.DownloadPool.Bots {
+		account := dlpool.NewAccount(fmt.Sprintf("bot#%d", i+1))
+		client, err := loginDownloaderBot(ctx, token, account)
+		if err != nil {
+			logger.Errorf("登录下载 Bot %s 失败: %s", account.Name(), err)
+			continue
+		}
+		account.Bind(client.API(), client.CreateContext())
+		dlpool.Default().Register(account)
+	}
+	for i, session := range config.Cfg.Telegram.DownloadPool.Sessions {
+		account := dlpool.NewAccount(fmt.Sprintf("user#%d", i+1))
+		client, err := userclient.LoginDownloader(ctx, session, account.Middleware())
+		if err != nil {
+			logger.Errorf("登录下载用户会话 %s 失败: %s", session, err)
+			continue
+		}
+		account.Bind(client.API(), client.CreateContext())
+		dlpool.Default().Register(account)
+	}
+	logger.Infof("下载账号池已就绪, 共 %d 个账号", len(dlpool.Default().Accounts()))
+}
+
+func loginDownloaderBot(ctx context.Context, token string, account *dlpool.Account) (*gotgproto.Client, error) {
+	proxyUrl := ""
+	if config.Cfg.Telegram.Proxy.Enable {
+		proxyUrl = config.Cfg.Telegram.Proxy.URL
+	}
+	resolver, err := netutil.NewResolver(proxyUrl)
+	if err != nil {
+		return nil, err
+	}
+	// 每个 bot 使用单独的会话文件, 以 token 中的 bot id 区分
+	botID, _, _ := strings.Cut(token, ":")
+	session := filepath.Join(filepath.Dir(config.Cfg.DB.Session), fmt.Sprintf("session_bot_%s.db", botID))
+	return gotgproto.NewClient(
+		config.Cfg.Telegram.AppID,
+		config.Cfg.Telegram.AppHash,
+		gotgproto.ClientTypeBot(token),
+		&gotgproto.ClientOpts{
+			Session:          sessionMaker.SqlSession(gormlite.Open(session)),
+			DisableCopyright: true,
+			Middlewares:      append(middleware.NewDefaultMiddlewares(ctx, 5*time.Minute), account.Middleware()),
+			Resolver:         resolver,
+			Context:          ctx,
+			MaxRetries:       config.Cfg.Telegram.RpcRetry,
+			NoUpdates:        true,
+		},
+	)
+}
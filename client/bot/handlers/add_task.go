@@ -67,12 +67,35 @@ func handleAddCallback(ctx *ext.Context, update *ext.Update) error {
 
 	switch data.TaskType {
 	case tasktype.TaskTypeTgfiles:
+		if data.SaveRange != nil {
+			return shortcut.SaveMessageRangeWithEdit(ctx, userID, selectedStorage, dirPath, *data.SaveRange, msgID)
+		}
+		if data.Comments != nil {
+			return shortcut.SaveCommentsWithEdit(ctx, userID, selectedStorage, dirPath, *data.Comments, msgID)
+		}
+		if data.Topic != nil {
+			return shortcut.SaveTopicWithEdit(ctx, userID, selectedStorage, dirPath, *data.Topic, msgID)
+		}
 		if data.AsBatch {
 			return shortcut.CreateAndAddBatchTGFileTaskWithEdit(ctx, userID, selectedStorage, dirPath, data.Files, msgID)
 		}
 		return shortcut.CreateAndAddTGFileTaskWithEdit(ctx, userID, selectedStorage, dirPath, data.Files[0], msgID)
 	case tasktype.TaskTypeTphpics:
 		return shortcut.CreateAndAddTphTaskWithEdit(ctx, userID, data.TphPageNode, data.TphDirPath, data.TphPics, selectedStorage, msgID)
+	case tasktype.TaskTypeHttpfile:
+		return shortcut.CreateAndAddHTTPTaskWithEdit(ctx, userID, selectedStorage, dirPath, *data.HTTPFile, msgID)
+	case tasktype.TaskTypeYtdlp:
+		return shortcut.CreateAndAddYtdlpTaskWithEdit(ctx, userID, selectedStorage, dirPath, *data.Ytdlp, msgID)
+	case tasktype.TaskTypeTorrent:
+		return shortcut.CreateAndAddTorrentTaskWithEdit(ctx, userID, selectedStorage, dirPath, *data.Torrent, msgID)
+	case tasktype.TaskTypeInstantview:
+		return shortcut.CreateAndAddInstantViewTaskWithEdit(ctx, userID, selectedStorage, dirPath, *data.InstantView, msgID)
+	case tasktype.TaskTypeNote:
+		return shortcut.CreateAndAddNoteTaskWithEdit(ctx, userID, selectedStorage, dirPath, *data.Note, msgID)
+	case tasktype.TaskTypeArchive:
+		return shortcut.CreateAndAddArchiveTaskWithEdit(ctx, userID, selectedStorage, dirPath, *data.Archive, msgID)
+	case tasktype.TaskTypeSticker:
+		return shortcut.CreateAndAddStickerTaskWithEdit(ctx, userID, selectedStorage, dirPath, *data.StickerSet, msgID)
 	default:
 		log.FromContext(ctx).Errorf("Unsupported task type: %s", data.TaskType)
 	}
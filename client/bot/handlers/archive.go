@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/celestix/gotgproto/dispatcher"
+	"github.com/celestix/gotgproto/ext"
+	"github.com/charmbracelet/log"
+	"github.com/gotd/td/tg"
+	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/msgelem"
+	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/shortcut"
+	userclient "github.com/krau/SaveAny-Bot/client/user"
+	"github.com/krau/SaveAny-Bot/common/utils/tgutil"
+	"github.com/krau/SaveAny-Bot/config"
+	"github.com/krau/SaveAny-Bot/pkg/tcbdata"
+	"github.com/krau/SaveAny-Bot/storage"
+)
+
+const archiveDateLayout = "2006-01-02"
+
+// /archive <chat> [from-date] [to-date]
+func handleArchiveCmd(ctx *ext.Context, update *ext.Update) error {
+	logger := log.FromContext(ctx)
+	args := strings.Fields(update.EffectiveMessage.Text)
+	if len(args) < 2 || len(args) > 4 {
+		ctx.Reply(update, ext.ReplyTextString(msgelem.ArchiveHelpText), nil)
+		return dispatcher.EndGroups
+	}
+	a := tcbdata.Archive{Userbot: config.Cfg.Telegram.Userbot.Enable}
+	for i, arg := range args[2:] {
+		date, err := time.ParseInLocation(archiveDateLayout, arg, time.Local)
+		if err != nil {
+			ctx.Reply(update, ext.ReplyTextString("无效的日期, 请使用 YYYY-MM-DD 格式: "+arg), nil)
+			return dispatcher.EndGroups
+		}
+		if i == 0 {
+			a.From = date
+		} else {
+			// 包含结束日期当天
+			a.To = date.AddDate(0, 0, 1)
+		}
+	}
+	if !a.To.IsZero() && !a.To.After(a.From) {
+		ctx.Reply(update, ext.ReplyTextString("结束日期不能早于开始日期"), nil)
+		return dispatcher.EndGroups
+	}
+
+	// 有 userbot 时使用 userbot 获取历史, 可以归档任意已加入的聊天并按日期定位
+	tctx := ctx
+	if a.Userbot {
+		uctx, err := userclient.ReadyCtx()
+		if err != nil {
+			ctx.Reply(update, ext.ReplyTextString("获取 userbot 失败: "+err.Error()), nil)
+			return dispatcher.EndGroups
+		}
+		tctx = uctx
+	}
+	chatID, err := tgutil.ParseChatID(tctx, args[1])
+	if err != nil {
+		ctx.Reply(update, ext.ReplyTextString("无效的ID或用户名: "+err.Error()), nil)
+		return dispatcher.EndGroups
+	}
+	if _, ok := tctx.PeerStorage.GetInputPeerById(chatID).(*tg.InputPeerChannel); !ok && !a.Userbot {
+		ctx.Reply(update, ext.ReplyTextString("Bot 只能归档频道和超级群组, 归档其他聊天需要启用 userbot"), nil)
+		return dispatcher.EndGroups
+	}
+	title, err := tgutil.GetChatTitle(tctx, chatID)
+	if err != nil {
+		logger.Errorf("Failed to get chat %d: %s", chatID, err)
+		ctx.Reply(update, ext.ReplyTextString("获取聊天失败: "+err.Error()), nil)
+		return dispatcher.EndGroups
+	}
+	a.ChatID, a.Title = chatID, title
+
+	replied, err := ctx.Reply(update, ext.ReplyTextString("正在准备归档..."), nil)
+	if err != nil {
+		logger.Errorf("回复失败: %s", err)
+		return dispatcher.EndGroups
+	}
+	userID := update.GetUserChat().GetID()
+	if stor := storage.FromContext(ctx); stor != nil {
+		return shortcut.CreateAndAddArchiveTaskWithEdit(ctx, userID, stor, "", a, replied.ID)
+	}
+	markup, err := msgelem.BuildAddSelectStorageKeyboard(ctx, userID, tcbdata.Add{Archive: &a})
+	if err != nil {
+		logger.Errorf("构建存储选择键盘失败: %s", err)
+		ctx.EditMessage(userID, &tg.MessagesEditMessageRequest{
+			ID:      replied.ID,
+			Message: "构建存储选择键盘失败: " + err.Error(),
+		})
+		return dispatcher.EndGroups
+	}
+	ctx.EditMessage(userID, &tg.MessagesEditMessageRequest{
+		ID:          replied.ID,
+		Message:     fmt.Sprintf("将归档聊天: %s\n请选择存储位置", title),
+		ReplyMarkup: markup,
+	})
+	return dispatcher.EndGroups
+}
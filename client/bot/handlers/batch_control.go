@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/celestix/gotgproto/dispatcher"
+	"github.com/celestix/gotgproto/ext"
+	"github.com/charmbracelet/log"
+	"github.com/gotd/td/tg"
+	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/msgelem"
+	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/shortcut"
+	"github.com/krau/SaveAny-Bot/common/cache"
+	"github.com/krau/SaveAny-Bot/core"
+	"github.com/krau/SaveAny-Bot/core/batchtftask"
+)
+
+// 批量任务中单个文件的跳过, 取消和重试
+func handleBatchElementCallback(ctx *ext.Context, update *ext.Update) error {
+	query := update.CallbackQuery
+	parts := strings.Split(strings.TrimPrefix(string(query.Data), batchtftask.ElementCallbackPrefix), ":")
+	if len(parts) != 3 {
+		ctx.AnswerCallback(msgelem.AlertCallbackAnswer(query.GetQueryID(), "无效的操作"))
+		return dispatcher.EndGroups
+	}
+	action, taskID, elemID := batchtftask.ElementAction(parts[0]), parts[1], parts[2]
+	task, err := core.GetTask(ctx, taskID)
+	if err != nil {
+		ctx.AnswerCallback(msgelem.AlertCallbackAnswer(query.GetQueryID(), "任务已结束或不存在"))
+		return dispatcher.EndGroups
+	}
+	batch, ok := task.(*batchtftask.Task)
+	if !ok || batch.UserID != query.GetUserID() {
+		ctx.AnswerCallback(msgelem.AlertCallbackAnswer(query.GetQueryID(), "无效的任务"))
+		return dispatcher.EndGroups
+	}
+	if err := batch.ControlElement(elemID, action); err != nil {
+		log.FromContext(ctx).Warnf("Failed to %s element %s of task %s: %v", action, elemID, taskID, err)
+		text := "操作失败: " + err.Error()
+		if errors.Is(err, batchtftask.ErrElementNotFound) {
+			text = "文件不存在"
+		}
+		ctx.AnswerCallback(msgelem.AlertCallbackAnswer(query.GetQueryID(), text))
+		return dispatcher.EndGroups
+	}
+	answer := map[batchtftask.ElementAction]string{
+		batchtftask.ElementActionSkip:   "⏭ 已跳过该文件",
+		batchtftask.ElementActionCancel: "✖ 已取消该文件",
+		batchtftask.ElementActionRetry:  "🔁 正在重试该文件",
+	}[action]
+	ctx.AnswerCallback(msgelem.CallbackAnswer(query.GetQueryID(), answer))
+	return dispatcher.EndGroups
+}
+
+// 仅重试批量任务中失败的文件
+func handleBatchRetryCallback(ctx *ext.Context, update *ext.Update) error {
+	query := update.CallbackQuery
+	key := strings.TrimPrefix(string(query.Data), batchtftask.RetryCallbackPrefix)
+	failed, ok := cache.Get[[]batchtftask.TaskElement](key)
+	if !ok || len(failed) == 0 {
+		ctx.AnswerCallback(msgelem.AlertCallbackAnswer(query.GetQueryID(), "数据已过期, 请重新发送"))
+		return dispatcher.EndGroups
+	}
+	cache.Del(key)
+	userID := query.GetUserID()
+	elems := make([]batchtftask.TaskElement, 0, len(failed))
+	for _, f := range failed {
+		// 重新创建元素, 使用新的 ID 和缓存路径
+		elem, err := batchtftask.NewTaskElement(f.Storage, f.Path, f.File)
+		if err != nil {
+			ctx.AnswerCallback(msgelem.AlertCallbackAnswer(query.GetQueryID(), "任务创建失败: "+err.Error()))
+			return dispatcher.EndGroups
+		}
+		elems = append(elems, *elem)
+	}
+	// 移除原消息上的按钮, 避免重复提交
+	ctx.EditMessage(userID, &tg.MessagesEditMessageRequest{
+		ID:          query.GetMsgID(),
+		ReplyMarkup: &tg.ReplyInlineMarkup{},
+	})
+	msg, err := ctx.SendMessage(userID, &tg.MessagesSendMessageRequest{Message: "正在重试失败的文件..."})
+	if err != nil {
+		log.FromContext(ctx).Errorf("Failed to send message: %s", err)
+		return dispatcher.EndGroups
+	}
+	ctx.AnswerCallback(msgelem.CallbackAnswer(query.GetQueryID(), "🔁 已创建重试任务"))
+	return shortcut.AddBatchTGFileTaskWithEdit(ctx, userID, elems, msg.ID)
+}
@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/celestix/gotgproto/dispatcher"
+	"github.com/celestix/gotgproto/ext"
+	"github.com/charmbracelet/log"
+	"github.com/gotd/td/tg"
+	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/msgelem"
+	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/shortcut"
+	"github.com/krau/SaveAny-Bot/common/cache"
+	"github.com/krau/SaveAny-Bot/pkg/tcbdata"
+	"github.com/rs/xid"
+)
+
+// 在存储选择键盘下附加保存评论中所有媒体的按钮
+func appendCommentsButton(markup *tg.ReplyInlineMarkup, c *tcbdata.Comments) error {
+	if c == nil || markup == nil {
+		return nil
+	}
+	dataid := xid.New().String()
+	if err := cache.Set(dataid, *c); err != nil {
+		return err
+	}
+	markup.Rows = append(markup.Rows, tg.KeyboardButtonRow{Buttons: []tg.KeyboardButtonClass{
+		&tg.KeyboardButtonCallback{
+			Text: fmt.Sprintf("💬 保存评论中的所有媒体 (%d 条评论)", c.Count),
+			Data: fmt.Appendf(nil, "%s %s", tcbdata.TypeComments, dataid),
+		},
+	}})
+	return nil
+}
+
+func handleCommentsCallback(ctx *ext.Context, update *ext.Update) error {
+	dataid := strings.Split(string(update.CallbackQuery.Data), " ")[1]
+	data, err := shortcut.GetCallbackDataWithAnswer[tcbdata.Comments](ctx, update, dataid)
+	if err != nil {
+		return err
+	}
+	userID := update.CallbackQuery.GetUserID()
+	msgID := update.CallbackQuery.GetMsgID()
+	markup, err := msgelem.BuildAddSelectStorageKeyboard(ctx, userID, tcbdata.Add{Comments: &data})
+	if err != nil {
+		log.FromContext(ctx).Errorf("构建存储选择键盘失败: %s", err)
+		ctx.AnswerCallback(msgelem.AlertCallbackAnswer(update.CallbackQuery.GetQueryID(), "构建存储选择键盘失败: "+err.Error()))
+		return dispatcher.EndGroups
+	}
+	ctx.EditMessage(userID, &tg.MessagesEditMessageRequest{
+		ID:          msgID,
+		Message:     fmt.Sprintf("将保存 %d 条评论中的所有媒体, 按评论者分目录保存\n请选择存储位置", data.Count),
+		ReplyMarkup: markup,
+	})
+	return dispatcher.EndGroups
+}
@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"strings"
+
+	"github.com/celestix/gotgproto/dispatcher"
+	"github.com/celestix/gotgproto/ext"
+	"github.com/charmbracelet/log"
+	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/dashboard"
+	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/msgelem"
+	"github.com/krau/SaveAny-Bot/database"
+)
+
+func handleDashboardCmd(ctx *ext.Context, update *ext.Update) error {
+	user, err := database.GetUserByChatID(ctx, update.GetUserChat().GetID())
+	if err != nil {
+		ctx.Reply(update, ext.ReplyTextString("获取用户信息失败: "+err.Error()), nil)
+		return nil
+	}
+	user.Dashboard = !user.Dashboard
+	if err := database.UpdateUser(ctx, user); err != nil {
+		ctx.Reply(update, ext.ReplyTextString("更新用户信息失败: "+err.Error()), nil)
+		return nil
+	}
+	responseText := "已" + map[bool]string{true: "开启", false: "关闭"}[user.Dashboard] + "任务面板模式"
+	if user.Dashboard {
+		responseText += ", 之后添加的任务将在一条置顶消息中汇总显示"
+	}
+	ctx.Reply(update, ext.ReplyTextString(responseText), nil)
+	return dispatcher.EndGroups
+}
+
+func handleDashboardCancelCallback(ctx *ext.Context, update *ext.Update) error {
+	query := update.CallbackQuery
+	taskID := strings.TrimPrefix(string(query.Data), dashboard.CancelCallbackPrefix)
+	if err := dashboard.For(query.GetUserID()).Cancel(ctx, taskID); err != nil {
+		log.FromContext(ctx).Errorf("Failed to cancel task %s: %v", taskID, err)
+		ctx.AnswerCallback(msgelem.AlertCallbackAnswer(query.GetQueryID(), "❌ 取消任务失败: "+err.Error()))
+		return dispatcher.EndGroups
+	}
+	ctx.AnswerCallback(msgelem.CallbackAnswer(query.GetQueryID(), "✅ 任务已取消"))
+	return dispatcher.EndGroups
+}
@@ -221,6 +221,11 @@ func buildFormattedSaveHelpText() (string, []tg.MessageEntityClass) {
 				"/save - 回复文件消息保存",
 				"/save 自定义名称 - 保存并重命名",
 				"/save 名称1 名称2 名称3 - 批量保存多个文件",
+				"/archive 聊天 [开始日期] [结束日期] - 归档聊天的消息和媒体",
+				"/stories 用户 - 保存用户或频道的故事, 也可发送故事链接 (需要 userbot)",
+				"发送有评论的频道消息链接可保存评论中的所有媒体",
+				"/save 聊天 topic:话题ID - 保存论坛话题中的所有文件, 也可发送话题中的消息链接",
+				"发送私有聊天的邀请链接和消息链接, 确认后由 userbot 加入并保存 (需要 userbot)",
 			},
 		},
 		{
@@ -231,11 +236,25 @@ func buildFormattedSaveHelpText() (string, []tg.MessageEntityClass) {
 				"静默模式下文件直接保存到默认位置",
 			},
 		},
+		{
+			Icon:  "📋",
+			Title: "任务面板",
+			Items: []string{
+				"/dashboard - 开关任务面板模式",
+				"所有任务的进度汇总在一条置顶消息中, 可单独取消任务",
+			},
+		},
 		{
 			Icon:  "📋",
 			Title: "支持的文件类型",
 			Items: []string{
-				"📄 文档、📷 图片、🎵 音频、🎬 视频、📎 所有媒体文件",
+				"📄 文档、📷 图片、🎵 音频、🎬 视频、📎 所有媒体文件, 包括已购买的付费媒体和游戏",
+				"🔗 HTTP(S) 直链, 可在链接后逐行附加请求头",
+				"🎞 视频网站链接 (需配置 yt-dlp)",
+				"🧲 磁力链接和 .torrent 文件 (需启用 BitTorrent 下载)",
+				"📰 带有 Instant View 的文章链接",
+				"📝 文本消息, 保存为 Markdown 笔记, 可保存整个对话",
+				"🎨 贴纸或贴纸包链接, 保存整个贴纸包或 Emoji 包",
 			},
 		},
 	}
@@ -313,7 +332,11 @@ func buildFormattedWatchHelpText() (string, []tg.MessageEntityClass) {
 		styling.Code("/watch"),
 		styling.Plain(" - 添加监控频道\n• "),
 		styling.Code("/unwatch"),
-		styling.Plain(" - 取消监控频道"),
+		styling.Plain(" - 取消监控频道\n• "),
+		styling.Code("/watch <聊天> stories"),
+		styling.Plain(" - 自动保存新发布的故事\n• "),
+		styling.Code("/watch <聊天> topic:<话题ID>"),
+		styling.Plain(" - 只监控论坛中的一个话题"),
 	)
 }
 
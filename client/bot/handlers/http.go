@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"github.com/celestix/gotgproto/dispatcher"
+	"github.com/celestix/gotgproto/ext"
+	"github.com/charmbracelet/log"
+	"github.com/gotd/td/telegram/message/entity"
+	"github.com/gotd/td/telegram/message/styling"
+	"github.com/gotd/td/tg"
+	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/msgelem"
+	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/shortcut"
+	"github.com/krau/SaveAny-Bot/pkg/enums/tasktype"
+	"github.com/krau/SaveAny-Bot/pkg/tcbdata"
+	"github.com/krau/SaveAny-Bot/storage"
+)
+
+// 用户正在输入规则时不处理链接, 交给之后的规则输入处理器
+func skipOnRuleInput(next func(*ext.Context, *ext.Update) error) func(*ext.Context, *ext.Update) error {
+	return func(ctx *ext.Context, update *ext.Update) error {
+		if _, ok := userInputStates[update.GetUserChat().GetID()]; ok {
+			return nil
+		}
+		return next(ctx, update)
+	}
+}
+
+func handleHTTPUrlMessage(ctx *ext.Context, update *ext.Update) error {
+	logger := log.FromContext(ctx)
+	userID := update.GetUserChat().GetID()
+	msg, file, err := shortcut.GetHTTPFileFromMessageWithReply(ctx, update)
+	if err != nil {
+		return err
+	}
+	markup, err := msgelem.BuildAddSelectStorageKeyboard(ctx, userID, tcbdata.Add{
+		TaskType: tasktype.TaskTypeHttpfile,
+		HTTPFile: file,
+	})
+	if err != nil {
+		logger.Errorf("构建存储选择键盘失败: %s", err)
+		ctx.Reply(update, ext.ReplyTextString("构建存储选择键盘失败: "+err.Error()), nil)
+		return dispatcher.EndGroups
+	}
+	size := "未知"
+	if file.Size >= 0 {
+		size = msgelem.FormatSize(file.Size)
+	}
+	eb := entity.Builder{}
+	if err := styling.Perform(&eb,
+		styling.Plain("文件名: "),
+		styling.Code(file.FileName),
+		styling.Plain("\n文件大小: "),
+		styling.Code(size),
+		styling.Plain("\n请选择存储位置"),
+	); err != nil {
+		logger.Errorf("Failed to build entity: %s", err)
+		return dispatcher.EndGroups
+	}
+	text, entities := eb.Complete()
+	ctx.EditMessage(userID, &tg.MessagesEditMessageRequest{
+		Message:     text,
+		ID:          msg.ID,
+		ReplyMarkup: markup,
+		Entities:    entities,
+	})
+	return dispatcher.EndGroups
+}
+
+func handleSilentSaveHTTPUrl(ctx *ext.Context, update *ext.Update) error {
+	logger := log.FromContext(ctx)
+	userID := update.GetUserChat().GetID()
+	stor := storage.FromContext(ctx)
+	if stor == nil {
+		logger.Warn("Context storage is nil")
+		ctx.Reply(update, ext.ReplyTextString("未找到存储"), nil)
+		return dispatcher.EndGroups
+	}
+	msg, file, err := shortcut.GetHTTPFileFromMessageWithReply(ctx, update)
+	if err != nil {
+		return err
+	}
+	return shortcut.CreateAndAddHTTPTaskWithEdit(ctx, userID, stor, "", *file, msg.ID)
+}
@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/celestix/gotgproto/dispatcher"
+	"github.com/celestix/gotgproto/ext"
+	"github.com/celestix/gotgproto/types"
+	"github.com/charmbracelet/log"
+	"github.com/gotd/td/telegram/message/entity"
+	"github.com/gotd/td/telegram/message/styling"
+	"github.com/gotd/td/tg"
+	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/msgelem"
+	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/shortcut"
+	"github.com/krau/SaveAny-Bot/pkg/enums/tasktype"
+	"github.com/krau/SaveAny-Bot/pkg/instantview"
+	"github.com/krau/SaveAny-Bot/pkg/tcbdata"
+	"github.com/krau/SaveAny-Bot/pkg/telegraph"
+	"github.com/krau/SaveAny-Bot/storage"
+)
+
+type instantViewKey struct{}
+
+// 消息带有网页预览
+func isWebPageMessage(m *types.Message) bool {
+	_, ok := m.Media.(*tg.MessageMediaWebPage)
+	return ok
+}
+
+// 消息带有文件等媒体. 网页预览不是文件, 交给之后的链接处理器
+func isFileMediaMessage(m *types.Message) bool {
+	return m.Media != nil && !isWebPageMessage(m)
+}
+
+// 只处理带有 Instant View 的网页预览, 其他链接交给之后的处理器
+func onInstantView(next func(*ext.Context, *ext.Update) error) func(*ext.Context, *ext.Update) error {
+	return func(ctx *ext.Context, update *ext.Update) error {
+		iv := shortcut.GetInstantView(ctx, update.EffectiveMessage.Message)
+		if iv == nil {
+			return nil
+		}
+		ctx.Context = context.WithValue(ctx.Context, instantViewKey{}, iv)
+		return next(ctx, update)
+	}
+}
+
+func instantViewFromContext(ctx context.Context) *tcbdata.InstantView {
+	iv, _ := ctx.Value(instantViewKey{}).(*tcbdata.InstantView)
+	return iv
+}
+
+func handleInstantViewMessage(ctx *ext.Context, update *ext.Update) error {
+	logger := log.FromContext(ctx)
+	iv := instantViewFromContext(ctx)
+	if iv == nil {
+		return nil
+	}
+	userID := update.GetUserChat().GetID()
+	markup, err := msgelem.BuildAddSelectStorageKeyboard(ctx, userID, tcbdata.Add{
+		TaskType:    tasktype.TaskTypeInstantview,
+		InstantView: iv,
+	})
+	if err != nil {
+		logger.Errorf("构建存储选择键盘失败: %s", err)
+		ctx.Reply(update, ext.ReplyTextString("构建存储选择键盘失败: "+err.Error()), nil)
+		return dispatcher.EndGroups
+	}
+	page := instantview.Convert(iv.WebPage)
+	medias := 0
+	for _, m := range telegraph.CollectMedia(page.Content) {
+		if m.Kind != telegraph.MediaEmbed {
+			medias++
+		}
+	}
+	eb := entity.Builder{}
+	if err := styling.Perform(&eb,
+		styling.Plain("Instant View 文章: "),
+		styling.Code(page.Title),
+		styling.Plain("\n媒体数量: "),
+		styling.Code(fmt.Sprintf("%d", medias)),
+		styling.Plain("\n请选择存储位置"),
+	); err != nil {
+		logger.Errorf("Failed to build entity: %s", err)
+		return dispatcher.EndGroups
+	}
+	text, entities := eb.Complete()
+	if err := msgelem.ReplyWithFormattedText(ctx, update, text, entities, &ext.ReplyOpts{Markup: markup}); err != nil {
+		logger.Errorf("Failed to reply: %s", err)
+	}
+	return dispatcher.EndGroups
+}
+
+func handleSilentSaveInstantView(ctx *ext.Context, update *ext.Update) error {
+	logger := log.FromContext(ctx)
+	iv := instantViewFromContext(ctx)
+	if iv == nil {
+		return nil
+	}
+	stor := storage.FromContext(ctx)
+	if stor == nil {
+		logger.Warn("Context storage is nil")
+		ctx.Reply(update, ext.ReplyTextString("未找到存储"), nil)
+		return dispatcher.EndGroups
+	}
+	msg, err := ctx.Reply(update, ext.ReplyTextString("正在保存文章..."), nil)
+	if err != nil {
+		logger.Errorf("Failed to reply: %s", err)
+		return dispatcher.EndGroups
+	}
+	userID := update.GetUserChat().GetID()
+	return shortcut.CreateAndAddInstantViewTaskWithEdit(ctx, userID, stor, "", *iv, msg.ID)
+}
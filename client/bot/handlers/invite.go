@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/celestix/gotgproto/dispatcher"
+	"github.com/celestix/gotgproto/ext"
+	"github.com/charmbracelet/log"
+	"github.com/gotd/td/tg"
+	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/msgelem"
+	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/re"
+	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/shortcut"
+	userclient "github.com/krau/SaveAny-Bot/client/user"
+	"github.com/krau/SaveAny-Bot/common/cache"
+	"github.com/krau/SaveAny-Bot/common/utils/tgutil"
+	"github.com/krau/SaveAny-Bot/config"
+	"github.com/krau/SaveAny-Bot/pkg/tcbdata"
+	"github.com/rs/xid"
+)
+
+const inviteNeedUserbotText = "通过邀请链接访问私有聊天需要启用 userbot"
+
+func buildInvitePreviewText(invite *tgutil.ChatInvite, links int) string {
+	kind := "群组"
+	if invite.Broadcast {
+		kind = "频道"
+	} else if invite.Channel {
+		kind = "超级群组"
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "邀请链接指向%s: %s\n成员数: %d\n", kind, invite.Title, invite.Members)
+	if invite.About != "" {
+		fmt.Fprintf(&sb, "简介: %s\n", invite.About)
+	}
+	if invite.RequestNeeded {
+		sb.WriteString("\n加入该聊天需要管理员批准, 确认后将发送加入请求\n")
+	}
+	if links > 0 {
+		fmt.Fprintf(&sb, "\n确认后 userbot 将加入该聊天并获取 %d 个消息链接中的文件", links)
+	} else {
+		sb.WriteString("\n确认后 userbot 将加入该聊天, 之后可以发送其中的消息链接来保存文件")
+	}
+	return sb.String()
+}
+
+// 邀请链接, 可以和该聊天中的消息链接放在同一条消息中发送
+func handleInviteLinkMessage(ctx *ext.Context, update *ext.Update) error {
+	logger := log.FromContext(ctx)
+	if !config.Cfg.Telegram.Userbot.Enable {
+		ctx.Reply(update, ext.ReplyTextString(inviteNeedUserbotText), nil)
+		return dispatcher.EndGroups
+	}
+	text := update.EffectiveMessage.GetMessage()
+	hash := re.TgInviteLinkRegexp.FindStringSubmatch(text)[1]
+	links := re.TgMessageLinkRegexp.FindAllString(text, -1)
+	uctx, err := userclient.ReadyCtx()
+	if err != nil {
+		ctx.Reply(update, ext.ReplyTextString(inviteNeedUserbotText), nil)
+		return dispatcher.EndGroups
+	}
+	invite, err := tgutil.CheckChatInvite(uctx, hash)
+	if err != nil {
+		logger.Errorf("Failed to check chat invite %s: %s", hash, err)
+		ctx.Reply(update, ext.ReplyTextString("邀请链接无效或已过期: "+err.Error()), nil)
+		return dispatcher.EndGroups
+	}
+	if invite.ChatID != 0 {
+		if len(links) > 0 {
+			// userbot 已在聊天中, 交给消息链接处理器
+			return dispatcher.ContinueGroups
+		}
+		ctx.Reply(update, ext.ReplyTextString(fmt.Sprintf("userbot 已加入聊天 %s, 发送其中的消息链接即可保存文件", invite.Title)), nil)
+		return dispatcher.EndGroups
+	}
+	dataid := xid.New().String()
+	if err := cache.Set(dataid, tcbdata.JoinChat{
+		Hash:  hash,
+		Title: invite.Title,
+		Links: links,
+	}); err != nil {
+		logger.Errorf("Failed to set cache: %s", err)
+		ctx.Reply(update, ext.ReplyTextString("缓存数据失败: "+err.Error()), nil)
+		return dispatcher.EndGroups
+	}
+	markup := &tg.ReplyInlineMarkup{Rows: []tg.KeyboardButtonRow{{Buttons: []tg.KeyboardButtonClass{
+		&tg.KeyboardButtonCallback{
+			Text: "✅ 加入聊天",
+			Data: fmt.Appendf(nil, "%s %s", tcbdata.TypeJoinChat, dataid),
+		},
+		&tg.KeyboardButtonCallback{
+			Text: "取消",
+			Data: []byte("cancel"),
+		},
+	}}}}
+	ctx.Reply(update, ext.ReplyTextString(buildInvitePreviewText(invite, len(links))), &ext.ReplyOpts{Markup: markup})
+	return dispatcher.EndGroups
+}
+
+// 确认后加入聊天, 再获取一起发送的消息链接中的文件并选择存储
+func handleJoinChatCallback(ctx *ext.Context, update *ext.Update) error {
+	dataid := strings.Split(string(update.CallbackQuery.Data), " ")[1]
+	data, err := shortcut.GetCallbackDataWithAnswer[tcbdata.JoinChat](ctx, update, dataid)
+	if err != nil {
+		return err
+	}
+	logger := log.FromContext(ctx)
+	userID := update.CallbackQuery.GetUserID()
+	msgID := update.CallbackQuery.GetMsgID()
+	editText := func(text string, markup tg.ReplyMarkupClass) {
+		ctx.EditMessage(userID, &tg.MessagesEditMessageRequest{
+			ID:          msgID,
+			Message:     text,
+			ReplyMarkup: markup,
+		})
+	}
+	cache.Del(dataid)
+	uctx, err := userclient.ReadyCtx()
+	if err != nil {
+		editText("加入聊天失败: "+err.Error(), nil)
+		return dispatcher.EndGroups
+	}
+	editText("正在加入聊天...", nil)
+	if _, err := tgutil.JoinChatInvite(uctx, data.Hash); err != nil {
+		if errors.Is(err, tgutil.ErrInviteRequestSent) {
+			editText(fmt.Sprintf("已发送加入 %s 的请求, 管理员批准后再发送消息链接即可保存文件", data.Title), nil)
+			return dispatcher.EndGroups
+		}
+		logger.Errorf("Failed to join chat %s: %s", data.Title, err)
+		editText("加入聊天失败: "+err.Error(), nil)
+		return dispatcher.EndGroups
+	}
+	if len(data.Links) == 0 {
+		editText(fmt.Sprintf("userbot 已加入聊天 %s, 现在可以发送其中的消息链接来保存文件", data.Title), nil)
+		return dispatcher.EndGroups
+	}
+	editText("已加入聊天, 正在获取消息...", nil)
+	// 文件由 userbot 获取, 下载同样使用 userbot 的客户端
+	files, err := shortcut.GetFilesFromLinks(uctx, data.Links)
+	if err != nil {
+		logger.Errorf("Failed to get files from links: %s", err)
+		editText("获取消息失败: "+err.Error(), nil)
+		return dispatcher.EndGroups
+	}
+	switch len(files) {
+	case 0:
+		editText(fmt.Sprintf("已加入聊天 %s, 但链接指向的消息中没有可保存的文件", data.Title), nil)
+	case 1:
+		req, err := msgelem.BuildAddOneSelectStorageMessage(ctx, userID, files[0], msgID)
+		if err != nil {
+			logger.Errorf("构建存储选择消息失败: %s", err)
+			editText("构建存储选择消息失败: "+err.Error(), nil)
+			return dispatcher.EndGroups
+		}
+		ctx.EditMessage(userID, req)
+	default:
+		markup, err := msgelem.BuildAddSelectStorageKeyboard(ctx, userID, tcbdata.Add{Files: files})
+		if err != nil {
+			logger.Errorf("构建存储选择键盘失败: %s", err)
+			editText("构建存储选择键盘失败: "+err.Error(), nil)
+			return dispatcher.EndGroups
+		}
+		editText(fmt.Sprintf("找到 %d 个文件, 请选择存储位置", len(files)), markup)
+	}
+	return dispatcher.EndGroups
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/celestix/gotgproto/dispatcher"
 	"github.com/celestix/gotgproto/ext"
 	"github.com/charmbracelet/log"
+	"github.com/gotd/td/tg"
 	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/msgelem"
 	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/shortcut"
 	"github.com/krau/SaveAny-Bot/pkg/tcbdata"
@@ -17,10 +18,24 @@ func handleMessageLink(ctx *ext.Context, update *ext.Update) error {
 	if err != nil {
 		return err
 	}
+	if len(files) == 0 {
+		return handleLinkNote(ctx, update, replied, editReplied)
+	}
 	logger := log.FromContext(ctx)
 	userId := update.GetUserChat().GetID()
+	// 频道消息有评论时可以保存评论中的所有媒体
+	comments := shortcut.GetCommentsFromLinks(ctx, update.EffectiveMessage.GetMessage())
+	// 论坛话题中的消息可以保存整个话题
+	topic := shortcut.GetTopicFromLinks(ctx, update.EffectiveMessage.GetMessage())
 	if len(files) == 1 {
 		req, err := msgelem.BuildAddOneSelectStorageMessage(ctx, userId, files[0], replied.ID)
+		if err == nil {
+			markup, _ := req.ReplyMarkup.(*tg.ReplyInlineMarkup)
+			err = appendCommentsButton(markup, comments)
+			if err == nil {
+				err = appendTopicButton(markup, topic)
+			}
+		}
 		if err != nil {
 			logger.Errorf("构建存储选择消息失败: %s", err)
 			editReplied("构建存储选择消息失败: "+err.Error(), nil)
@@ -32,6 +47,12 @@ func handleMessageLink(ctx *ext.Context, update *ext.Update) error {
 	markup, err := msgelem.BuildAddSelectStorageKeyboard(ctx, userId, tcbdata.Add{
 		Files: files,
 	})
+	if err == nil {
+		err = appendCommentsButton(markup, comments)
+	}
+	if err == nil {
+		err = appendTopicButton(markup, topic)
+	}
 	if err != nil {
 		logger.Errorf("构建存储选择键盘失败: %s", err)
 		editReplied("构建存储选择键盘失败: "+err.Error(), nil)
@@ -49,10 +70,13 @@ func handleSilentSaveLink(ctx *ext.Context, update *ext.Update) error {
 		ctx.Reply(update, ext.ReplyTextString("未找到存储"), nil)
 		return dispatcher.EndGroups
 	}
-	replied, files, _, err := shortcut.GetFilesFromUpdateLinkMessageWithReplyEdit(ctx, update)
+	replied, files, editReplied, err := shortcut.GetFilesFromUpdateLinkMessageWithReplyEdit(ctx, update)
 	if err != nil {
 		return err
 	}
+	if len(files) == 0 {
+		return handleLinkNote(ctx, update, replied, editReplied)
+	}
 	userId := update.GetUserChat().GetID()
 	if len(files) == 1 {
 		return shortcut.CreateAndAddTGFileTaskWithEdit(ctx, userId, stor, "", files[0], replied.ID)
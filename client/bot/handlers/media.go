@@ -26,12 +26,12 @@ func handleMediaMessage(ctx *ext.Context, update *ext.Update) error {
 	}
 	logger.Debugf("Got media: %s", message.Media.TypeName())
 
-	msg, file, err := shortcut.GetFileFromMessageWithReply(ctx, update, message)
+	msg, files, err := shortcut.GetFilesFromMessageWithReply(ctx, update, message)
 	if err != nil {
 		return err
 	}
 	userId := update.GetUserChat().GetID()
-	req, err := msgelem.BuildAddOneSelectStorageMessage(ctx, userId, file, msg.ID)
+	req, err := msgelem.BuildAddSelectStorageMessage(ctx, userId, files, msg.ID)
 	if err != nil {
 		logger.Errorf("构建存储选择消息失败: %s", err)
 		ctx.Reply(update, ext.ReplyTextString("构建存储选择消息失败: "+err.Error()), nil)
@@ -56,11 +56,11 @@ func handleSilentSaveMedia(ctx *ext.Context, update *ext.Update) error {
 	}
 	logger.Debugf("Got media: %s", message.Media.TypeName())
 	userID := update.GetUserChat().GetID()
-	msg, file, err := shortcut.GetFileFromMessageWithReply(ctx, update, message)
+	msg, files, err := shortcut.GetFilesFromMessageWithReply(ctx, update, message)
 	if err != nil {
 		return err
 	}
-	return shortcut.CreateAndAddTGFileTaskWithEdit(ctx, userID, stor, "", file, msg.ID)
+	return shortcut.CreateAndAddTGFilesTaskWithEdit(ctx, userID, stor, "", files, msg.ID)
 }
 
 type MediaGroupHandler struct {
@@ -83,7 +83,7 @@ func handleGroupMediaMessage(ctx *ext.Context, update *ext.Update, message *tg.M
 	if !supported {
 		return dispatcher.EndGroups
 	}
-	file, err := tfile.FromMediaMessage(media, ctx.Raw, message)
+	files, err := tfile.FilesFromMediaMessage(media, ctx.Raw, message, tfile.WithMessageGetter(ctx))
 	if err != nil {
 		logger.Errorf("Failed to get file from media: %s", err)
 		return dispatcher.EndGroups
@@ -93,7 +93,7 @@ func handleGroupMediaMessage(ctx *ext.Context, update *ext.Update, message *tg.M
 	if mediaGroupHandler.groups[groupID] == nil {
 		mediaGroupHandler.groups[groupID] = make([]tfile.TGFileMessage, 0)
 	}
-	mediaGroupHandler.groups[groupID] = append(mediaGroupHandler.groups[groupID], file)
+	mediaGroupHandler.groups[groupID] = append(mediaGroupHandler.groups[groupID], files...)
 
 	if timer, exists := mediaGroupHandler.timers[groupID]; exists {
 		timer.Stop()
@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/celestix/gotgproto/dispatcher"
+	"github.com/celestix/gotgproto/ext"
+	"github.com/celestix/gotgproto/types"
+	"github.com/charmbracelet/log"
+	"github.com/gotd/td/telegram/message/entity"
+	"github.com/gotd/td/telegram/message/styling"
+	"github.com/gotd/td/tg"
+	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/msgelem"
+	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/shortcut"
+	"github.com/krau/SaveAny-Bot/common/cache"
+	"github.com/krau/SaveAny-Bot/pkg/enums/tasktype"
+	"github.com/krau/SaveAny-Bot/pkg/tcbdata"
+	"github.com/krau/SaveAny-Bot/storage"
+	"github.com/rs/xid"
+)
+
+// 没有文件的文本消息, 命令除外
+func isNoteMessage(m *types.Message) bool {
+	return m.Message.Message != "" && !isFileMediaMessage(m) && !strings.HasPrefix(m.Message.Message, "/")
+}
+
+// 笔记的存储选择消息, 有回复链或评论时附加保存整个对话的按钮
+func buildNoteMessage(ctx *ext.Context, userID int64, note *tcbdata.Note) (string, []tg.MessageEntityClass, *tg.ReplyInlineMarkup, error) {
+	markup, err := msgelem.BuildAddSelectStorageKeyboard(ctx, userID, tcbdata.Add{
+		TaskType: tasktype.TaskTypeNote,
+		Note:     note,
+	})
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("构建存储选择键盘失败: %w", err)
+	}
+	if note.HasThread && !note.Content.Thread {
+		dataid := xid.New().String()
+		if err := cache.Set(dataid, *note); err != nil {
+			return "", nil, nil, err
+		}
+		markup.Rows = append(markup.Rows, tg.KeyboardButtonRow{Buttons: []tg.KeyboardButtonClass{
+			&tg.KeyboardButtonCallback{
+				Text: "🧵 保存整个对话",
+				Data: fmt.Appendf(nil, "%s %s", tcbdata.TypeNoteThread, dataid),
+			},
+		}})
+	}
+	kind := "文本消息"
+	if note.Content.Thread {
+		kind = fmt.Sprintf("对话 (%d 条消息)", len(note.Content.Messages))
+	}
+	eb := entity.Builder{}
+	if err := styling.Perform(&eb,
+		styling.Plain(kind+": "),
+		styling.Code(note.Content.Title()),
+		styling.Plain("\n文件名: "),
+		styling.Code(note.Content.FileName()),
+		styling.Plain("\n请选择存储位置"),
+	); err != nil {
+		return "", nil, nil, err
+	}
+	text, entities := eb.Complete()
+	return text, entities, markup, nil
+}
+
+func handleNoteMessage(ctx *ext.Context, update *ext.Update) error {
+	logger := log.FromContext(ctx)
+	note := shortcut.NoteFromUpdate(ctx, update)
+	text, entities, markup, err := buildNoteMessage(ctx, update.GetUserChat().GetID(), note)
+	if err != nil {
+		logger.Errorf("Failed to build note message: %s", err)
+		ctx.Reply(update, ext.ReplyTextString(err.Error()), nil)
+		return dispatcher.EndGroups
+	}
+	if err := msgelem.ReplyWithFormattedText(ctx, update, text, entities, &ext.ReplyOpts{Markup: markup}); err != nil {
+		logger.Errorf("Failed to reply: %s", err)
+	}
+	return dispatcher.EndGroups
+}
+
+func handleSilentSaveNote(ctx *ext.Context, update *ext.Update) error {
+	logger := log.FromContext(ctx)
+	stor := storage.FromContext(ctx)
+	if stor == nil {
+		logger.Warn("Context storage is nil")
+		ctx.Reply(update, ext.ReplyTextString("未找到存储"), nil)
+		return dispatcher.EndGroups
+	}
+	msg, err := ctx.Reply(update, ext.ReplyTextString("正在保存笔记..."), nil)
+	if err != nil {
+		logger.Errorf("Failed to reply: %s", err)
+		return dispatcher.EndGroups
+	}
+	note := shortcut.NoteFromUpdate(ctx, update)
+	return shortcut.CreateAndAddNoteTaskWithEdit(ctx, update.GetUserChat().GetID(), stor, "", *note, msg.ID)
+}
+
+// 链接指向的消息没有文件时, 将其中的文本消息保存为笔记
+func handleLinkNote(ctx *ext.Context, update *ext.Update, replied *types.Message, editReplied shortcut.EditMessageFunc) error {
+	logger := log.FromContext(ctx)
+	note, err := shortcut.GetNoteFromLinks(ctx, update.EffectiveMessage.GetMessage())
+	if err != nil {
+		logger.Debugf("Failed to get note from links: %s", err)
+		editReplied("没有找到可保存的文件", nil)
+		return dispatcher.EndGroups
+	}
+	userID := update.GetUserChat().GetID()
+	if stor := storage.FromContext(ctx); stor != nil {
+		return shortcut.CreateAndAddNoteTaskWithEdit(ctx, userID, stor, "", *note, replied.ID)
+	}
+	text, entities, markup, err := buildNoteMessage(ctx, userID, note)
+	if err == nil {
+		err = appendCommentsButton(markup, shortcut.GetCommentsFromLinks(ctx, update.EffectiveMessage.GetMessage()))
+	}
+	if err != nil {
+		logger.Errorf("Failed to build note message: %s", err)
+		editReplied(err.Error(), nil)
+		return dispatcher.EndGroups
+	}
+	ctx.EditMessage(userID, &tg.MessagesEditMessageRequest{
+		ID:          replied.ID,
+		Message:     text,
+		Entities:    entities,
+		ReplyMarkup: markup,
+	})
+	return dispatcher.EndGroups
+}
+
+func handleNoteThreadCallback(ctx *ext.Context, update *ext.Update) error {
+	dataid := strings.Split(string(update.CallbackQuery.Data), " ")[1]
+	data, err := shortcut.GetCallbackDataWithAnswer[tcbdata.Note](ctx, update, dataid)
+	if err != nil {
+		return err
+	}
+	logger := log.FromContext(ctx)
+	userID := update.CallbackQuery.GetUserID()
+	msgID := update.CallbackQuery.GetMsgID()
+	ctx.EditMessage(userID, &tg.MessagesEditMessageRequest{
+		ID:      msgID,
+		Message: "正在获取对话...",
+	})
+	thread, err := shortcut.GetNoteThread(ctx, data)
+	if err != nil {
+		logger.Errorf("Failed to get note thread: %s", err)
+		ctx.EditMessage(userID, &tg.MessagesEditMessageRequest{
+			ID:      msgID,
+			Message: "获取对话失败: " + err.Error(),
+		})
+		return dispatcher.EndGroups
+	}
+	data.Content = thread
+	text, entities, markup, err := buildNoteMessage(ctx, userID, &data)
+	if err != nil {
+		logger.Errorf("Failed to build note message: %s", err)
+		ctx.EditMessage(userID, &tg.MessagesEditMessageRequest{
+			ID:      msgID,
+			Message: err.Error(),
+		})
+		return dispatcher.EndGroups
+	}
+	ctx.EditMessage(userID, &tg.MessagesEditMessageRequest{
+		ID:          msgID,
+		Message:     text,
+		Entities:    entities,
+		ReplyMarkup: markup,
+	})
+	return dispatcher.EndGroups
+}
@@ -10,12 +10,15 @@ import (
 	"github.com/celestix/gotgproto/dispatcher/handlers/filters"
 	"github.com/celestix/gotgproto/ext"
 	"github.com/charmbracelet/log"
+	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/dashboard"
 	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/re"
 	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/ruleutil"
+	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/shortcut"
 	userclient "github.com/krau/SaveAny-Bot/client/user"
 	"github.com/krau/SaveAny-Bot/common/utils/tgutil"
 	"github.com/krau/SaveAny-Bot/config"
 	"github.com/krau/SaveAny-Bot/core"
+	"github.com/krau/SaveAny-Bot/core/batchtftask"
 	"github.com/krau/SaveAny-Bot/core/tftask"
 	"github.com/krau/SaveAny-Bot/database"
 	"github.com/krau/SaveAny-Bot/pkg/tcbdata"
@@ -34,6 +37,7 @@ func Register(disp dispatcher.Dispatcher) {
 	disp.AddHandler(handlers.NewCommand("start", handleStartCmd))
 	disp.AddHandler(handlers.NewCommand("help", handleHelpCmd))
 	disp.AddHandler(handlers.NewCommand("silent", handleSilentCmd))
+	disp.AddHandler(handlers.NewCommand("dashboard", handleDashboardCmd))
 	disp.AddHandler(handlers.NewCommand("storage", handleStorageCmd))
 	disp.AddHandler(handlers.NewCommand("storage_list", handleStorageListCmd))
 	disp.AddHandler(handlers.NewCommand("dir", handleDirCmd))
@@ -41,10 +45,18 @@ func Register(disp dispatcher.Dispatcher) {
 	disp.AddHandler(handlers.NewCommand("watch", handleWatchCmd))
 	disp.AddHandler(handlers.NewCommand("unwatch", handleUnwatchCmd))
 	disp.AddHandler(handlers.NewCommand("save", handleSilentMode(handleSaveCmd, handleSilentSaveReplied)))
+	disp.AddHandler(handlers.NewCommand("archive", handleSilentMode(handleArchiveCmd, handleArchiveCmd)))
+	disp.AddHandler(handlers.NewCommand("stories", handleSilentMode(handleStoriesCmd, handleStoriesCmd)))
 	disp.AddHandler(handlers.NewCommand("ai_status", handleAIStatusCmd))
 	disp.AddHandler(handlers.NewCommand("ai_toggle", handleAIToggleCmd))
 	disp.AddHandler(handlers.NewCallbackQuery(filters.CallbackQuery.Prefix(tcbdata.TypeAdd), handleAddCallback))
 	disp.AddHandler(handlers.NewCallbackQuery(filters.CallbackQuery.Prefix(tcbdata.TypeSetDefault), handleSetDefaultCallback))
+	disp.AddHandler(handlers.NewCallbackQuery(filters.CallbackQuery.Prefix(tcbdata.TypeYtdlpFormat), handleYtdlpFormatCallback))
+	disp.AddHandler(handlers.NewCallbackQuery(filters.CallbackQuery.Prefix(tcbdata.TypeTorrentSelect), handleTorrentSelectCallback))
+	disp.AddHandler(handlers.NewCallbackQuery(filters.CallbackQuery.Prefix(tcbdata.TypeNoteThread), handleNoteThreadCallback))
+	disp.AddHandler(handlers.NewCallbackQuery(filters.CallbackQuery.Prefix(tcbdata.TypeComments), handleCommentsCallback))
+	disp.AddHandler(handlers.NewCallbackQuery(filters.CallbackQuery.Prefix(tcbdata.TypeTopic), handleTopicCallback))
+	disp.AddHandler(handlers.NewCallbackQuery(filters.CallbackQuery.Prefix(tcbdata.TypeJoinChat), handleJoinChatCallback))
 	disp.AddHandler(handlers.NewCallbackQuery(filters.CallbackQuery.Prefix(tcbdata.TypeDeleteStorageConfirm), handleDeleteStorageConfirmCallback))
 	disp.AddHandler(handlers.NewCallbackQuery(filters.CallbackQuery.Prefix(tcbdata.TypeStorageToggle), handleStorageToggleCallback))
 	disp.AddHandler(handlers.NewCallbackQuery(filters.CallbackQuery.Prefix("storage_info"), handleStorageInfoCallback))
@@ -62,6 +74,28 @@ func Register(disp dispatcher.Dispatcher) {
 	disp.AddHandler(handlers.NewCallbackQuery(filters.CallbackQuery.Prefix("rule_"), handleRuleCallback))
 	disp.AddHandler(handlers.NewCallbackQuery(filters.CallbackQuery.Prefix("cancel_task:"), handleCancelTaskCallback))
 	disp.AddHandler(handlers.NewCallbackQuery(filters.CallbackQuery.Prefix("task_detail:"), handleTaskDetailCallback))
+	disp.AddHandler(handlers.NewCallbackQuery(filters.CallbackQuery.Prefix(dashboard.CancelCallbackPrefix), handleDashboardCancelCallback))
+	disp.AddHandler(handlers.NewCallbackQuery(filters.CallbackQuery.Prefix(batchtftask.ElementCallbackPrefix), handleBatchElementCallback))
+	disp.AddHandler(handlers.NewCallbackQuery(filters.CallbackQuery.Prefix(batchtftask.RetryCallbackPrefix), handleBatchRetryCallback))
+	disp.AddHandler(handlers.NewCallbackQuery(filters.CallbackQuery.Prefix(shortcut.StopScanCallbackPrefix), handleStopScanCallback))
+	// 邀请链接可能和消息链接一起发送, 需要在消息链接和直链之前处理
+	inviteLinkRegexFilter, err := filters.Message.Regex(re.TgInviteLinkRegexString)
+	if err != nil {
+		panic("failed to create invite link regex filter: " + err.Error())
+	}
+	disp.AddHandler(handlers.NewMessage(inviteLinkRegexFilter, skipOnRuleInput(handleInviteLinkMessage)))
+	storyLinkRegexFilter, err := filters.Message.Regex(re.StoryLinkRegexString)
+	if err != nil {
+		panic("failed to create story link regex filter: " + err.Error())
+	}
+	disp.AddHandler(handlers.NewMessage(storyLinkRegexFilter, skipOnRuleInput(handleSilentMode(handleStoryLinkMessage, handleStoryLinkMessage))))
+	// 贴纸包链接也符合消息链接的格式, 需要在消息链接之前处理
+	stickerSetLinkRegexFilter, err := filters.Message.Regex(re.StickerSetLinkRegexString)
+	if err != nil {
+		panic("failed to create sticker set link regex filter: " + err.Error())
+	}
+	disp.AddHandler(handlers.NewMessage(stickerSetLinkRegexFilter, skipOnRuleInput(onStickerSet(handleSilentMode(handleStickerSetMessage, handleSilentSaveStickerSet)))))
+	disp.AddHandler(handlers.NewMessage(isStickerMessage, onStickerSet(handleSilentMode(handleStickerSetMessage, handleSilentSaveStickerSet))))
 	linkRegexFilter, err := filters.Message.Regex(re.TgMessageLinkRegexString)
 	if err != nil {
 		panic("failed to create regex filter: " + err.Error())
@@ -72,9 +106,28 @@ func Register(disp dispatcher.Dispatcher) {
 		panic("failed to create Telegraph URL regex filter: " + err.Error())
 	}
 	disp.AddHandler(handlers.NewMessage(telegraphUrlRegexFilter, handleSilentMode(handleTelegraphUrlMessage, handleSilentSaveTelegraph)))
-	disp.AddHandler(handlers.NewMessage(filters.Message.Media, handleSilentMode(handleMediaMessage, handleSilentSaveMedia)))
+	// 带有 Instant View 的网页预览, 其他网页预览交给之后的链接处理器
+	disp.AddHandler(handlers.NewMessage(isWebPageMessage, skipOnRuleInput(onInstantView(handleSilentMode(handleInstantViewMessage, handleSilentSaveInstantView)))))
+	// .torrent 文件需要在普通文件之前处理
+	disp.AddHandler(handlers.NewMessage(isFileMediaMessage, onTorrentDocument(handleSilentMode(handleTorrentMessage, handleSilentSaveTorrent))))
+	disp.AddHandler(handlers.NewMessage(isFileMediaMessage, handleSilentMode(handleMediaMessage, handleSilentSaveMedia)))
 	// 添加存储配置响应处理器（需要在其他消息处理器之前）
 	disp.AddHandler(handlers.NewMessage(filters.Message.Text, handleStorageConfigResponse))
+	// 磁力链接中可能带有 tracker 地址, 需要在直链下载之前处理
+	magnetRegexFilter, err := filters.Message.Regex(re.MagnetRegexString)
+	if err != nil {
+		panic("failed to create magnet regex filter: " + err.Error())
+	}
+	disp.AddHandler(handlers.NewMessage(magnetRegexFilter, skipOnRuleInput(onTorrentEnabled(handleSilentMode(handleTorrentMessage, handleSilentSaveTorrent)))))
+	// 直链下载, 需要在存储配置向导之后, 避免处理向导中输入的地址
+	httpUrlRegexFilter, err := filters.Message.Regex(re.HttpUrlRegexString)
+	if err != nil {
+		panic("failed to create HTTP URL regex filter: " + err.Error())
+	}
+	disp.AddHandler(handlers.NewMessage(httpUrlRegexFilter, skipOnRuleInput(onYtdlpLink(handleSilentMode(handleYtdlpUrlMessage, handleSilentSaveYtdlpUrl)))))
+	disp.AddHandler(handlers.NewMessage(httpUrlRegexFilter, skipOnRuleInput(handleSilentMode(handleHTTPUrlMessage, handleSilentSaveHTTPUrl))))
+	// 其他处理器都未处理的文本消息保存为笔记
+	disp.AddHandler(handlers.NewMessage(isNoteMessage, skipOnRuleInput(handleSilentMode(handleNoteMessage, handleSilentSaveNote))))
 	// 添加规则输入消息处理器
 	disp.AddHandler(handlers.NewMessage(filters.Message.Text, handleRuleInputMessage))
 
@@ -84,18 +137,30 @@ func Register(disp dispatcher.Dispatcher) {
 }
 
 func listenMediaMessageEvent(ch chan userclient.MediaMessageEvent) {
-	logger := log.FromContext(userclient.GetCtx())
+	uctx, err := userclient.ReadyCtx()
+	if err != nil {
+		log.Errorf("Failed to listen media messages: %s", err)
+		return
+	}
+	logger := log.FromContext(uctx)
 	for event := range ch {
 		logger.Debug("Received media message event", "chat_id", event.ChatID, "file_name", event.File.Name())
 		ctx := event.Ctx
 		file := event.File
-		chats, err := database.GetWatchChatsByChatID(ctx, event.ChatID)
+		chats, err := database.GetWatchChatsByChatID(ctx, event.ChatID, event.Story)
 		if err != nil {
 			logger.Errorf("Failed to get watch chats for chat ID %d: %v", event.ChatID, err)
 			continue
 		}
 		msgText := event.File.Message().GetMessage()
+		topicID, inTopic := tgutil.MessageTopicID(event.File.Message())
+		if !inTopic {
+			topicID = tgutil.GeneralTopicID
+		}
 		for _, chat := range chats {
+			if chat.TopicID != 0 && chat.TopicID != topicID {
+				continue
+			}
 			if chat.Filter != "" {
 				filter := strings.Split(chat.Filter, ":")
 				if len(filter) != 2 {
@@ -144,11 +209,14 @@ func listenMediaMessageEvent(ch chan userclient.MediaMessageEvent) {
 			}
 			// Generate filename using AI if available, otherwise use original
 			fileName := tgutil.GenFileNameFromMessage(*file.Message())
+			if event.Story {
+				fileName = file.Name()
+			}
 			storagePath := stor.JoinStoragePath(path.Join(dirPath, fileName))
 
 			injectCtx := tgutil.ExtWithContext(ctx.Context, ctx)
 			taskid := xid.New().String()
-			task, err := tftask.NewTGFileTask(taskid, injectCtx, file, stor, storagePath, nil)
+			task, err := tftask.NewTGFileTask(taskid, injectCtx, user.ChatID, file, stor, storagePath, nil)
 			if err != nil {
 				logger.Errorf("create task failed: %s", err)
 				continue
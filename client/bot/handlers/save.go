@@ -9,7 +9,6 @@ import (
 	"github.com/celestix/gotgproto/ext"
 	"github.com/charmbracelet/log"
 	"github.com/gotd/td/tg"
-	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/mediautil"
 	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/msgelem"
 	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/shortcut"
 	"github.com/krau/SaveAny-Bot/common/utils/strutil"
@@ -42,12 +41,12 @@ func handleSaveCmd(ctx *ext.Context, update *ext.Update) error {
 	if len(args) > 1 {
 		option = tfile.WithName(genFilename)
 	}
-	msg, file, err := shortcut.GetFileFromMessageWithReply(ctx, update, replyTo.Message, option)
+	msg, files, err := shortcut.GetFilesFromMessageWithReply(ctx, update, replyTo.Message, option)
 	if err != nil {
 		return err
 	}
 	userId := update.GetUserChat().GetID()
-	req, err := msgelem.BuildAddOneSelectStorageMessage(ctx, userId, file, msg.ID)
+	req, err := msgelem.BuildAddSelectStorageMessage(ctx, userId, files, msg.ID)
 	if err != nil {
 		logger.Errorf("构建存储选择消息失败: %s", err)
 		ctx.Reply(update, ext.ReplyTextString("构建存储选择消息失败: "+err.Error()), nil)
@@ -85,27 +84,27 @@ func handleSilentSaveReplied(ctx *ext.Context, update *ext.Update) error {
 	if len(args) > 1 {
 		option = tfile.WithName(genFilename)
 	}
-	msg, file, err := shortcut.GetFileFromMessageWithReply(ctx, update, replyTo.Message, option)
+	msg, files, err := shortcut.GetFilesFromMessageWithReply(ctx, update, replyTo.Message, option)
 	if err != nil {
 		return err
 	}
-	return shortcut.CreateAndAddTGFileTaskWithEdit(ctx, update.GetUserChat().GetID(), stor, "", file, msg.GetID())
+	return shortcut.CreateAndAddTGFilesTaskWithEdit(ctx, update.GetUserChat().GetID(), stor, "", files, msg.GetID())
 }
 
 func handleBatchSave(ctx *ext.Context, update *ext.Update, args []string) error {
 	chatArg := args[0]
 	msgIdRangeArg := args[1]
 	var filterStr string
-	var filter *regexp.Regexp
 	if len(args) > 2 {
 		filterStr = args[2]
-		var err error
-		filter, err = regexp.Compile(filterStr)
-		if err != nil {
+		if _, err := regexp.Compile(filterStr); err != nil {
 			ctx.Reply(update, ext.ReplyTextString("无效的正则表达式: "+err.Error()), nil)
 			return dispatcher.EndGroups
 		}
 	}
+	if isTopicArg(msgIdRangeArg) {
+		return handleTopicSave(ctx, update, chatArg, msgIdRangeArg, filterStr)
+	}
 	startID, endID, err := strutil.ParseIntStrRange(msgIdRangeArg, "-")
 	if err != nil {
 		ctx.Reply(update, ext.ReplyTextString("无效的消息ID范围: "+err.Error()), nil)
@@ -117,63 +116,25 @@ func handleBatchSave(ctx *ext.Context, update *ext.Update, args []string) error
 		return dispatcher.EndGroups
 	}
 
-	replied, err := ctx.Reply(update, ext.ReplyTextString("正在获取消息..."), nil)
+	replied, err := ctx.Reply(update, ext.ReplyTextString("正在准备扫描消息..."), nil)
 	if err != nil {
 		log.FromContext(ctx).Errorf("回复失败: %s", err)
 		return dispatcher.EndGroups
 	}
 
-	// TODO: generator istead of get all messages
-	msgs, err := tgutil.GetMessagesRange(ctx, chatID, int(startID), int(endID))
-	if err != nil {
-		ctx.Reply(update, ext.ReplyTextString("获取消息失败: "+err.Error()), nil)
-		return dispatcher.EndGroups
-	}
-	if len(msgs) == 0 {
-		ctx.Reply(update, ext.ReplyTextString("没有找到指定范围内的消息"), nil)
-		return dispatcher.EndGroups
-	}
-	files := make([]tfile.TGFileMessage, 0, len(msgs))
-	sb := strings.Builder{}
-	for _, msg := range msgs {
-		if msg == nil {
-			continue
-		}
-		media, ok := msg.GetMedia()
-		if !ok {
-			continue
-		}
-		supported := mediautil.IsSupported(media)
-		if !supported {
-			continue
-		}
-		file, err := tfile.FromMediaMessage(media, ctx.Raw, msg, tfile.WithNameIfEmpty(tgutil.GenFileNameFromMessage(*msg)))
-		if err != nil {
-			log.FromContext(ctx).Errorf("获取文件失败: %s", err)
-			continue
-		}
-		if filter != nil {
-			sb.Reset()
-			sb.WriteString(msg.GetMessage())
-			sb.WriteString(" ")
-			fn, _ := tgutil.GetMediaFileName(media)
-			sb.WriteString(fn)
-			if !filter.MatchString(sb.String()) {
-				continue
-			}
-		}
-		files = append(files, file)
-	}
-	if len(files) == 0 {
-		ctx.Reply(update, ext.ReplyTextString("没有找到指定范围内的可保存消息"), nil)
-		return dispatcher.EndGroups
-	}
+	// 范围可能很大, 选择存储后再分页扫描, 边扫描边创建任务
+	saveRange := tcbdata.SaveRange{
+		ChatID:  chatID,
+		StartID: int(startID),
+		EndID:   int(endID),
+		Filter:  filterStr,
+	}
+	userID := update.GetUserChat().GetID()
 	stor := storage.FromContext(ctx)
 	if stor == nil {
 		// not in silent mode
-		userID := update.GetUserChat().GetID()
 		markup, err := msgelem.BuildAddSelectStorageKeyboard(ctx, userID, tcbdata.Add{
-			Files: files,
+			SaveRange: &saveRange,
 		})
 		if err != nil {
 			log.FromContext(ctx).Errorf("构建存储选择键盘失败: %s", err)
@@ -185,10 +146,21 @@ func handleBatchSave(ctx *ext.Context, update *ext.Update, args []string) error
 		}
 		ctx.EditMessage(update.EffectiveChat().GetID(), &tg.MessagesEditMessageRequest{
 			ID:          replied.ID,
-			Message:     fmt.Sprintf("找到 %d 个文件, 请选择存储位置", len(files)),
+			Message:     fmt.Sprintf("将扫描消息 %d-%d 中的文件, 请选择存储位置", startID, endID),
 			ReplyMarkup: markup,
 		})
 		return dispatcher.EndGroups
 	}
-	return shortcut.CreateAndAddBatchTGFileTaskWithEdit(ctx, update.GetUserChat().GetID(), stor, "", files, replied.ID)
+	return shortcut.SaveMessageRangeWithEdit(ctx, userID, stor, "", saveRange, replied.ID)
+}
+
+func handleStopScanCallback(ctx *ext.Context, update *ext.Update) error {
+	query := update.CallbackQuery
+	scanID := strings.TrimPrefix(string(query.Data), shortcut.StopScanCallbackPrefix)
+	if !shortcut.StopRangeScan(query.GetUserID(), scanID) {
+		ctx.AnswerCallback(msgelem.AlertCallbackAnswer(query.GetQueryID(), "扫描已结束"))
+		return dispatcher.EndGroups
+	}
+	ctx.AnswerCallback(msgelem.CallbackAnswer(query.GetQueryID(), "正在停止扫描..."))
+	return dispatcher.EndGroups
 }
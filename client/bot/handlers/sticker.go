@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/celestix/gotgproto/dispatcher"
+	"github.com/celestix/gotgproto/ext"
+	"github.com/celestix/gotgproto/types"
+	"github.com/charmbracelet/log"
+	"github.com/gotd/td/telegram/message/entity"
+	"github.com/gotd/td/telegram/message/styling"
+	"github.com/gotd/td/tg"
+	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/msgelem"
+	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/re"
+	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/shortcut"
+	"github.com/krau/SaveAny-Bot/pkg/stickerset"
+	"github.com/krau/SaveAny-Bot/pkg/tcbdata"
+	"github.com/krau/SaveAny-Bot/storage"
+)
+
+type stickerSetKey struct{}
+
+// 消息中贴纸所属的贴纸包, 不是贴纸或贴纸不属于贴纸包时返回 false
+func stickerSetOfMessage(m *tg.Message) (tg.InputStickerSetClass, bool) {
+	media, ok := m.Media.(*tg.MessageMediaDocument)
+	if !ok {
+		return nil, false
+	}
+	doc, ok := media.Document.AsNotEmpty()
+	if !ok {
+		return nil, false
+	}
+	return stickerset.FromDocument(doc)
+}
+
+// 消息是属于贴纸包的贴纸
+func isStickerMessage(m *types.Message) bool {
+	_, ok := stickerSetOfMessage(m.Message)
+	return ok
+}
+
+// 获取贴纸或链接所属的贴纸包. 贴纸所属的贴纸包获取失败时交给之后的文件处理器保存单个贴纸
+func onStickerSet(next func(*ext.Context, *ext.Update) error) func(*ext.Context, *ext.Update) error {
+	return func(ctx *ext.Context, update *ext.Update) error {
+		message := update.EffectiveMessage.Message
+		input, fromSticker := stickerSetOfMessage(message)
+		if !fromSticker {
+			match := re.StickerSetLinkRegexp.FindStringSubmatch(message.Message)
+			if match == nil {
+				return nil
+			}
+			input = stickerset.ByShortName(match[2])
+		}
+		set, err := stickerset.Fetch(ctx, ctx.Raw, input)
+		if err != nil {
+			log.FromContext(ctx).Errorf("Failed to get sticker set: %s", err)
+			if fromSticker {
+				return nil
+			}
+			ctx.Reply(update, ext.ReplyTextString("获取贴纸包失败: "+err.Error()), nil)
+			return dispatcher.EndGroups
+		}
+		ctx.Context = context.WithValue(ctx.Context, stickerSetKey{}, &tcbdata.StickerSet{Set: set})
+		return next(ctx, update)
+	}
+}
+
+func stickerSetFromContext(ctx context.Context) *tcbdata.StickerSet {
+	s, _ := ctx.Value(stickerSetKey{}).(*tcbdata.StickerSet)
+	return s
+}
+
+func handleStickerSetMessage(ctx *ext.Context, update *ext.Update) error {
+	logger := log.FromContext(ctx)
+	s := stickerSetFromContext(ctx)
+	if s == nil {
+		return nil
+	}
+	userID := update.GetUserChat().GetID()
+	markup, err := msgelem.BuildAddSelectStorageKeyboard(ctx, userID, tcbdata.Add{StickerSet: s})
+	if err != nil {
+		logger.Errorf("构建存储选择键盘失败: %s", err)
+		ctx.Reply(update, ext.ReplyTextString("构建存储选择键盘失败: "+err.Error()), nil)
+		return dispatcher.EndGroups
+	}
+	kind := "贴纸包"
+	if s.Set.Emoji {
+		kind = "Emoji 包"
+	}
+	eb := entity.Builder{}
+	if err := styling.Perform(&eb,
+		styling.Plain(kind+": "),
+		styling.Code(s.Set.Title),
+		styling.Plain("\n贴纸数量: "),
+		styling.Code(fmt.Sprintf("%d", len(s.Set.Stickers))),
+		styling.Plain("\n请选择存储位置"),
+	); err != nil {
+		logger.Errorf("Failed to build entity: %s", err)
+		return dispatcher.EndGroups
+	}
+	text, entities := eb.Complete()
+	if err := msgelem.ReplyWithFormattedText(ctx, update, text, entities, &ext.ReplyOpts{Markup: markup}); err != nil {
+		logger.Errorf("Failed to reply: %s", err)
+	}
+	return dispatcher.EndGroups
+}
+
+func handleSilentSaveStickerSet(ctx *ext.Context, update *ext.Update) error {
+	logger := log.FromContext(ctx)
+	s := stickerSetFromContext(ctx)
+	if s == nil {
+		return nil
+	}
+	stor := storage.FromContext(ctx)
+	if stor == nil {
+		logger.Warn("Context storage is nil")
+		ctx.Reply(update, ext.ReplyTextString("未找到存储"), nil)
+		return dispatcher.EndGroups
+	}
+	msg, err := ctx.Reply(update, ext.ReplyTextString("正在保存贴纸包..."), nil)
+	if err != nil {
+		logger.Errorf("Failed to reply: %s", err)
+		return dispatcher.EndGroups
+	}
+	userID := update.GetUserChat().GetID()
+	return shortcut.CreateAndAddStickerTaskWithEdit(ctx, userID, stor, "", *s, msg.ID)
+}
@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/celestix/gotgproto/dispatcher"
+	"github.com/celestix/gotgproto/ext"
+	"github.com/charmbracelet/log"
+	"github.com/gotd/td/tg"
+	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/msgelem"
+	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/re"
+	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/shortcut"
+	userclient "github.com/krau/SaveAny-Bot/client/user"
+	"github.com/krau/SaveAny-Bot/common/utils/tgutil"
+	"github.com/krau/SaveAny-Bot/config"
+	"github.com/krau/SaveAny-Bot/pkg/tcbdata"
+	"github.com/krau/SaveAny-Bot/pkg/tfile"
+	"github.com/krau/SaveAny-Bot/storage"
+)
+
+const storyNeedUserbotText = "保存故事需要启用 userbot"
+
+// 获取用户或频道的故事文件, ids 为空时获取当前的故事和所有置顶的故事
+func getStoryFiles(username string, ids []int) ([]tfile.TGFileMessage, error) {
+	uctx, err := userclient.ReadyCtx()
+	if err != nil {
+		return nil, err
+	}
+	chatID, err := tgutil.ParseChatID(uctx, username)
+	if err != nil {
+		return nil, fmt.Errorf("无效的ID或用户名: %w", err)
+	}
+	var (
+		stories []*tg.StoryItem
+		names   *tgutil.PeerNames
+	)
+	if len(ids) == 0 {
+		stories, names, err = tgutil.GetPeerStories(uctx, chatID)
+	} else {
+		stories, names, err = tgutil.GetStoriesByID(uctx, chatID, ids)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("获取故事失败: %w", err)
+	}
+	return tgutil.StoryFiles(uctx, chatID, stories, names)
+}
+
+// 静默模式下保存到默认存储, 否则让用户选择存储
+func handleStoryFiles(ctx *ext.Context, update *ext.Update, files []tfile.TGFileMessage, trackMsgID int) error {
+	logger := log.FromContext(ctx)
+	userID := update.GetUserChat().GetID()
+	editReplied := func(text string, markup tg.ReplyMarkupClass) {
+		ctx.EditMessage(userID, &tg.MessagesEditMessageRequest{
+			ID:          trackMsgID,
+			Message:     text,
+			ReplyMarkup: markup,
+		})
+	}
+	if len(files) == 0 {
+		editReplied("没有可以保存的故事", nil)
+		return dispatcher.EndGroups
+	}
+	if stor := storage.FromContext(ctx); stor != nil {
+		if len(files) == 1 {
+			return shortcut.CreateAndAddTGFileTaskWithEdit(ctx, userID, stor, "", files[0], trackMsgID)
+		}
+		return shortcut.CreateAndAddBatchTGFileTaskWithEdit(ctx, userID, stor, "", files, trackMsgID)
+	}
+	if len(files) == 1 {
+		req, err := msgelem.BuildAddOneSelectStorageMessage(ctx, userID, files[0], trackMsgID)
+		if err != nil {
+			logger.Errorf("构建存储选择消息失败: %s", err)
+			editReplied("构建存储选择消息失败: "+err.Error(), nil)
+			return dispatcher.EndGroups
+		}
+		ctx.EditMessage(userID, req)
+		return dispatcher.EndGroups
+	}
+	markup, err := msgelem.BuildAddSelectStorageKeyboard(ctx, userID, tcbdata.Add{Files: files})
+	if err != nil {
+		logger.Errorf("构建存储选择键盘失败: %s", err)
+		editReplied("构建存储选择键盘失败: "+err.Error(), nil)
+		return dispatcher.EndGroups
+	}
+	editReplied(fmt.Sprintf("找到 %d 个故事, 请选择存储位置", len(files)), markup)
+	return dispatcher.EndGroups
+}
+
+// 消息中的故事链接, 同一用户或频道的故事一起获取
+func handleStoryLinkMessage(ctx *ext.Context, update *ext.Update) error {
+	logger := log.FromContext(ctx)
+	if !config.Cfg.Telegram.Userbot.Enable {
+		ctx.Reply(update, ext.ReplyTextString(storyNeedUserbotText), nil)
+		return dispatcher.EndGroups
+	}
+	owners := make([]string, 0)
+	byOwner := make(map[string][]int)
+	for _, match := range re.StoryLinkRegexp.FindAllStringSubmatch(update.EffectiveMessage.GetMessage(), -1) {
+		id, err := strconv.Atoi(match[2])
+		if err != nil {
+			continue
+		}
+		if _, ok := byOwner[match[1]]; !ok {
+			owners = append(owners, match[1])
+		}
+		byOwner[match[1]] = append(byOwner[match[1]], id)
+	}
+	replied, err := ctx.Reply(update, ext.ReplyTextString("正在获取故事..."), nil)
+	if err != nil {
+		logger.Errorf("Failed to reply: %s", err)
+		return dispatcher.EndGroups
+	}
+	files := make([]tfile.TGFileMessage, 0)
+	for _, owner := range owners {
+		ofiles, err := getStoryFiles(owner, byOwner[owner])
+		if err != nil {
+			logger.Errorf("Failed to get stories of %s: %s", owner, err)
+			if len(owners) == 1 {
+				ctx.EditMessage(update.GetUserChat().GetID(), &tg.MessagesEditMessageRequest{
+					ID:      replied.ID,
+					Message: err.Error(),
+				})
+				return dispatcher.EndGroups
+			}
+			continue
+		}
+		files = append(files, ofiles...)
+	}
+	return handleStoryFiles(ctx, update, files, replied.ID)
+}
+
+// /stories <chat>
+func handleStoriesCmd(ctx *ext.Context, update *ext.Update) error {
+	logger := log.FromContext(ctx)
+	args := strings.Fields(update.EffectiveMessage.Text)
+	if len(args) != 2 {
+		ctx.Reply(update, ext.ReplyTextString(msgelem.StoriesHelpText), nil)
+		return dispatcher.EndGroups
+	}
+	if !config.Cfg.Telegram.Userbot.Enable {
+		ctx.Reply(update, ext.ReplyTextString(storyNeedUserbotText), nil)
+		return dispatcher.EndGroups
+	}
+	replied, err := ctx.Reply(update, ext.ReplyTextString("正在获取故事..."), nil)
+	if err != nil {
+		logger.Errorf("Failed to reply: %s", err)
+		return dispatcher.EndGroups
+	}
+	files, err := getStoryFiles(args[1], nil)
+	if err != nil {
+		logger.Errorf("Failed to get stories of %s: %s", args[1], err)
+		ctx.EditMessage(update.GetUserChat().GetID(), &tg.MessagesEditMessageRequest{
+			ID:      replied.ID,
+			Message: err.Error(),
+		})
+		return dispatcher.EndGroups
+	}
+	return handleStoryFiles(ctx, update, files, replied.ID)
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/gotd/td/tg"
 	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/msgelem"
 	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/shortcut"
+	"github.com/krau/SaveAny-Bot/config"
 	"github.com/krau/SaveAny-Bot/pkg/enums/tasktype"
 	"github.com/krau/SaveAny-Bot/pkg/tcbdata"
 	"github.com/krau/SaveAny-Bot/storage"
@@ -36,12 +37,18 @@ func handleTelegraphUrlMessage(ctx *ext.Context, update *ext.Update) error {
 		return dispatcher.EndGroups
 	}
 
+	mode := "仅图片"
+	if config.Cfg.Telegraph.Article {
+		mode = "完整文章"
+	}
 	eb := entity.Builder{}
 	if err := styling.Perform(&eb,
 		styling.Plain("标题: "),
 		styling.Code(result.Page.Title),
 		styling.Plain("\n图片数量: "),
 		styling.Code(fmt.Sprintf("%d", len(result.Pics))),
+		styling.Plain("\n保存方式: "),
+		styling.Code(mode),
 		styling.Plain("\n请选择存储位置"),
 	); err != nil {
 		log.FromContext(ctx).Errorf("Failed to build entity: %s", err)
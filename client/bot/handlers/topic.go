@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/celestix/gotgproto/dispatcher"
+	"github.com/celestix/gotgproto/ext"
+	"github.com/charmbracelet/log"
+	"github.com/gotd/td/tg"
+	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/msgelem"
+	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/shortcut"
+	userclient "github.com/krau/SaveAny-Bot/client/user"
+	"github.com/krau/SaveAny-Bot/common/cache"
+	"github.com/krau/SaveAny-Bot/common/utils/tgutil"
+	"github.com/krau/SaveAny-Bot/config"
+	"github.com/krau/SaveAny-Bot/pkg/tcbdata"
+	"github.com/krau/SaveAny-Bot/storage"
+	"github.com/rs/xid"
+)
+
+const topicArgPrefix = "topic:"
+
+func isTopicArg(arg string) bool {
+	return strings.HasPrefix(arg, topicArgPrefix)
+}
+
+// topic:<话题ID>
+func parseTopicArg(arg string) (int, error) {
+	id, err := strconv.Atoi(strings.TrimPrefix(arg, topicArgPrefix))
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("无效的话题ID: %s", arg)
+	}
+	return id, nil
+}
+
+// 在存储选择键盘下附加保存整个话题的按钮
+func appendTopicButton(markup *tg.ReplyInlineMarkup, t *tcbdata.Topic) error {
+	if t == nil || markup == nil {
+		return nil
+	}
+	dataid := xid.New().String()
+	if err := cache.Set(dataid, *t); err != nil {
+		return err
+	}
+	markup.Rows = append(markup.Rows, tg.KeyboardButtonRow{Buttons: []tg.KeyboardButtonClass{
+		&tg.KeyboardButtonCallback{
+			Text: "🗂 保存整个话题: " + t.Title,
+			Data: fmt.Appendf(nil, "%s %s", tcbdata.TypeTopic, dataid),
+		},
+	}})
+	return nil
+}
+
+func handleTopicCallback(ctx *ext.Context, update *ext.Update) error {
+	dataid := strings.Split(string(update.CallbackQuery.Data), " ")[1]
+	data, err := shortcut.GetCallbackDataWithAnswer[tcbdata.Topic](ctx, update, dataid)
+	if err != nil {
+		return err
+	}
+	userID := update.CallbackQuery.GetUserID()
+	msgID := update.CallbackQuery.GetMsgID()
+	markup, err := msgelem.BuildAddSelectStorageKeyboard(ctx, userID, tcbdata.Add{Topic: &data})
+	if err != nil {
+		log.FromContext(ctx).Errorf("构建存储选择键盘失败: %s", err)
+		ctx.AnswerCallback(msgelem.AlertCallbackAnswer(update.CallbackQuery.GetQueryID(), "构建存储选择键盘失败: "+err.Error()))
+		return dispatcher.EndGroups
+	}
+	ctx.EditMessage(userID, &tg.MessagesEditMessageRequest{
+		ID:          msgID,
+		Message:     fmt.Sprintf("将保存话题 %s 中的所有文件\n请选择存储位置", data.Title),
+		ReplyMarkup: markup,
+	})
+	return dispatcher.EndGroups
+}
+
+// /save <chat> topic:<id> [filter]
+func handleTopicSave(ctx *ext.Context, update *ext.Update, chatArg, topicArg, filter string) error {
+	logger := log.FromContext(ctx)
+	topicID, err := parseTopicArg(topicArg)
+	if err != nil {
+		ctx.Reply(update, ext.ReplyTextString(err.Error()), nil)
+		return dispatcher.EndGroups
+	}
+	if topicID == tgutil.GeneralTopicID {
+		ctx.Reply(update, ext.ReplyTextString("不支持保存 General 话题, 请使用消息ID范围保存"), nil)
+		return dispatcher.EndGroups
+	}
+	userbot := config.Cfg.Telegram.Userbot.Enable
+	tctx := ctx
+	if userbot {
+		uctx, err := userclient.ReadyCtx()
+		if err != nil {
+			ctx.Reply(update, ext.ReplyTextString("获取 userbot 失败: "+err.Error()), nil)
+			return dispatcher.EndGroups
+		}
+		tctx = uctx
+	}
+	chatID, err := tgutil.ParseChatID(tctx, chatArg)
+	if err != nil {
+		ctx.Reply(update, ext.ReplyTextString("无效的ID或用户名: "+err.Error()), nil)
+		return dispatcher.EndGroups
+	}
+	t, err := shortcut.GetTopic(tctx, chatID, topicID)
+	if err != nil {
+		logger.Errorf("Failed to get topic %d in %d: %s", topicID, chatID, err)
+		ctx.Reply(update, ext.ReplyTextString("获取话题失败: "+err.Error()), nil)
+		return dispatcher.EndGroups
+	}
+	t.Filter, t.Userbot = filter, userbot
+
+	replied, err := ctx.Reply(update, ext.ReplyTextString("正在准备保存话题..."), nil)
+	if err != nil {
+		logger.Errorf("回复失败: %s", err)
+		return dispatcher.EndGroups
+	}
+	userID := update.GetUserChat().GetID()
+	if stor := storage.FromContext(ctx); stor != nil {
+		return shortcut.SaveTopicWithEdit(ctx, userID, stor, "", *t, replied.ID)
+	}
+	markup, err := msgelem.BuildAddSelectStorageKeyboard(ctx, userID, tcbdata.Add{Topic: t})
+	if err != nil {
+		logger.Errorf("构建存储选择键盘失败: %s", err)
+		ctx.EditMessage(userID, &tg.MessagesEditMessageRequest{
+			ID:      replied.ID,
+			Message: "构建存储选择键盘失败: " + err.Error(),
+		})
+		return dispatcher.EndGroups
+	}
+	ctx.EditMessage(userID, &tg.MessagesEditMessageRequest{
+		ID:          replied.ID,
+		Message:     fmt.Sprintf("将保存话题 %s 中的所有文件\n请选择存储位置", t.Title),
+		ReplyMarkup: markup,
+	})
+	return dispatcher.EndGroups
+}
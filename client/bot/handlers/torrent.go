@@ -0,0 +1,299 @@
+package handlers
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/celestix/gotgproto/dispatcher"
+	"github.com/celestix/gotgproto/ext"
+	"github.com/charmbracelet/log"
+	"github.com/gotd/td/telegram/message/entity"
+	"github.com/gotd/td/telegram/message/styling"
+	"github.com/gotd/td/tg"
+	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/msgelem"
+	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/shortcut"
+	"github.com/krau/SaveAny-Bot/common/cache"
+	"github.com/krau/SaveAny-Bot/config"
+	"github.com/krau/SaveAny-Bot/pkg/enums/tasktype"
+	"github.com/krau/SaveAny-Bot/pkg/tcbdata"
+	"github.com/krau/SaveAny-Bot/storage"
+	"github.com/rs/xid"
+)
+
+// 文件选择键盘每页显示的文件数
+const torrentFilesPerPage = 8
+
+// 未启用 BitTorrent 下载时不处理, 交给之后的处理器
+func onTorrentEnabled(next func(*ext.Context, *ext.Update) error) func(*ext.Context, *ext.Update) error {
+	return func(ctx *ext.Context, update *ext.Update) error {
+		if !config.Cfg.Torrent.Enable {
+			return nil
+		}
+		return next(ctx, update)
+	}
+}
+
+// 只处理 .torrent 文件, 其他文件交给之后的媒体处理器
+func onTorrentDocument(next func(*ext.Context, *ext.Update) error) func(*ext.Context, *ext.Update) error {
+	return onTorrentEnabled(func(ctx *ext.Context, update *ext.Update) error {
+		if !shortcut.IsTorrentDocument(update.EffectiveMessage.Message) {
+			return nil
+		}
+		return next(ctx, update)
+	})
+}
+
+// 按钮上显示的文件名, 过长时保留末尾
+func torrentButtonName(p string) string {
+	name := []rune(path.Base(p))
+	if len(name) > 32 {
+		return "…" + string(name[len(name)-31:])
+	}
+	return string(name)
+}
+
+func buildTorrentSelectKeyboard(dataid string, data tcbdata.TorrentSelect) *tg.ReplyInlineMarkup {
+	callback := func(action string) []byte {
+		return fmt.Appendf(nil, "%s %s %s", tcbdata.TypeTorrentSelect, dataid, action)
+	}
+	markup := &tg.ReplyInlineMarkup{}
+	start := data.Page * torrentFilesPerPage
+	end := min(start+torrentFilesPerPage, len(data.Files))
+	for i := start; i < end; i++ {
+		f := data.Files[i]
+		mark := "⬜"
+		if data.Selected[i] {
+			mark = "✅"
+		}
+		markup.Rows = append(markup.Rows, tg.KeyboardButtonRow{Buttons: []tg.KeyboardButtonClass{
+			&tg.KeyboardButtonCallback{
+				Text: fmt.Sprintf("%s %s (%s)", mark, torrentButtonName(f.Path), msgelem.FormatSize(f.Size)),
+				Data: callback(fmt.Sprintf("t%d", i)),
+			},
+		}})
+	}
+	pages := (len(data.Files) + torrentFilesPerPage - 1) / torrentFilesPerPage
+	if pages > 1 {
+		nav := make([]tg.KeyboardButtonClass, 0, 3)
+		if data.Page > 0 {
+			nav = append(nav, &tg.KeyboardButtonCallback{Text: "⬅️ 上一页", Data: callback(fmt.Sprintf("p%d", data.Page-1))})
+		}
+		nav = append(nav, &tg.KeyboardButtonCallback{Text: fmt.Sprintf("%d/%d", data.Page+1, pages), Data: callback("noop")})
+		if data.Page < pages-1 {
+			nav = append(nav, &tg.KeyboardButtonCallback{Text: "下一页 ➡️", Data: callback(fmt.Sprintf("p%d", data.Page+1))})
+		}
+		markup.Rows = append(markup.Rows, tg.KeyboardButtonRow{Buttons: nav})
+	}
+	var count int
+	var size int64
+	for i, f := range data.Files {
+		if data.Selected[i] {
+			count++
+			size += f.Size
+		}
+	}
+	markup.Rows = append(markup.Rows,
+		tg.KeyboardButtonRow{Buttons: []tg.KeyboardButtonClass{
+			&tg.KeyboardButtonCallback{Text: "全选", Data: callback("all")},
+			&tg.KeyboardButtonCallback{Text: "全不选", Data: callback("none")},
+		}},
+		tg.KeyboardButtonRow{Buttons: []tg.KeyboardButtonClass{
+			&tg.KeyboardButtonCallback{
+				Text: fmt.Sprintf("确定 (%d 个文件, %s)", count, msgelem.FormatSize(size)),
+				Data: callback("ok"),
+			},
+		}},
+	)
+	return markup
+}
+
+func handleTorrentMessage(ctx *ext.Context, update *ext.Update) error {
+	logger := log.FromContext(ctx)
+	msg, metainfo, meta, err := shortcut.GetTorrentFromMessageWithReply(ctx, update)
+	if err != nil {
+		return err
+	}
+	userID := update.GetUserChat().GetID()
+	// 单文件种子无需选择文件
+	if len(meta.Files) == 1 {
+		return editTorrentStorageKeyboard(ctx, userID, msg.ID, tcbdata.Torrent{
+			Name:     meta.Name,
+			Metainfo: metainfo,
+			Indexes:  []int{0},
+		}, meta.Size)
+	}
+	data := tcbdata.TorrentSelect{
+		Name:     meta.Name,
+		Metainfo: metainfo,
+		Files:    meta.Files,
+		Selected: make([]bool, len(meta.Files)),
+	}
+	for i := range data.Selected {
+		data.Selected[i] = true
+	}
+	dataid := xid.New().String()
+	if err := cache.Set(dataid, data); err != nil {
+		logger.Errorf("Failed to set cache: %s", err)
+		ctx.EditMessage(userID, &tg.MessagesEditMessageRequest{
+			ID:      msg.ID,
+			Message: "构建文件选择键盘失败: " + err.Error(),
+		})
+		return dispatcher.EndGroups
+	}
+	eb := entity.Builder{}
+	if err := styling.Perform(&eb,
+		styling.Plain("种子: "),
+		styling.Code(meta.Name),
+		styling.Plain("\n文件数量: "),
+		styling.Code(strconv.Itoa(len(meta.Files))),
+		styling.Plain("\n总大小: "),
+		styling.Code(msgelem.FormatSize(meta.Size)),
+		styling.Plain("\n请选择要下载的文件"),
+	); err != nil {
+		logger.Errorf("Failed to build entity: %s", err)
+		return dispatcher.EndGroups
+	}
+	text, entities := eb.Complete()
+	ctx.EditMessage(userID, &tg.MessagesEditMessageRequest{
+		Message:     text,
+		ID:          msg.ID,
+		ReplyMarkup: buildTorrentSelectKeyboard(dataid, data),
+		Entities:    entities,
+	})
+	return dispatcher.EndGroups
+}
+
+func editTorrentStorageKeyboard(ctx *ext.Context, userID int64, msgID int, data tcbdata.Torrent, size int64) error {
+	logger := log.FromContext(ctx)
+	markup, err := msgelem.BuildAddSelectStorageKeyboard(ctx, userID, tcbdata.Add{
+		TaskType: tasktype.TaskTypeTorrent,
+		Torrent:  &data,
+	})
+	if err != nil {
+		logger.Errorf("构建存储选择键盘失败: %s", err)
+		ctx.EditMessage(userID, &tg.MessagesEditMessageRequest{
+			ID:      msgID,
+			Message: "构建存储选择键盘失败: " + err.Error(),
+		})
+		return dispatcher.EndGroups
+	}
+	eb := entity.Builder{}
+	if err := styling.Perform(&eb,
+		styling.Plain("种子: "),
+		styling.Code(data.Name),
+		styling.Plain("\n文件数量: "),
+		styling.Code(strconv.Itoa(len(data.Indexes))),
+		styling.Plain("\n大小: "),
+		styling.Code(msgelem.FormatSize(size)),
+		styling.Plain("\n请选择存储位置"),
+	); err != nil {
+		logger.Errorf("Failed to build entity: %s", err)
+		return dispatcher.EndGroups
+	}
+	text, entities := eb.Complete()
+	ctx.EditMessage(userID, &tg.MessagesEditMessageRequest{
+		Message:     text,
+		ID:          msgID,
+		ReplyMarkup: markup,
+		Entities:    entities,
+	})
+	return dispatcher.EndGroups
+}
+
+func handleTorrentSelectCallback(ctx *ext.Context, update *ext.Update) error {
+	args := strings.Split(string(update.CallbackQuery.Data), " ")
+	queryID := update.CallbackQuery.GetQueryID()
+	if len(args) != 3 {
+		ctx.AnswerCallback(msgelem.AlertCallbackAnswer(queryID, "无效的操作"))
+		return dispatcher.EndGroups
+	}
+	dataid, action := args[1], args[2]
+	data, err := shortcut.GetCallbackDataWithAnswer[tcbdata.TorrentSelect](ctx, update, dataid)
+	if err != nil {
+		return err
+	}
+	userID := update.CallbackQuery.GetUserID()
+	msgID := update.CallbackQuery.GetMsgID()
+	switch {
+	case action == "ok":
+		var indexes []int
+		var size int64
+		for i, f := range data.Files {
+			if data.Selected[i] {
+				indexes = append(indexes, i)
+				size += f.Size
+			}
+		}
+		if len(indexes) == 0 {
+			ctx.AnswerCallback(msgelem.AlertCallbackAnswer(queryID, "请至少选择一个文件"))
+			return dispatcher.EndGroups
+		}
+		cache.Del(dataid)
+		return editTorrentStorageKeyboard(ctx, userID, msgID, tcbdata.Torrent{
+			Name:     data.Name,
+			Metainfo: data.Metainfo,
+			Indexes:  indexes,
+		}, size)
+	case action == "noop":
+		ctx.AnswerCallback(&tg.MessagesSetBotCallbackAnswerRequest{QueryID: queryID})
+		return dispatcher.EndGroups
+	case action == "all" || action == "none":
+		for i := range data.Selected {
+			data.Selected[i] = action == "all"
+		}
+	case strings.HasPrefix(action, "t"):
+		i, err := strconv.Atoi(action[1:])
+		if err != nil || i < 0 || i >= len(data.Files) {
+			ctx.AnswerCallback(msgelem.AlertCallbackAnswer(queryID, "无效的文件"))
+			return dispatcher.EndGroups
+		}
+		data.Selected[i] = !data.Selected[i]
+	case strings.HasPrefix(action, "p"):
+		page, err := strconv.Atoi(action[1:])
+		if err != nil || page < 0 || page*torrentFilesPerPage >= len(data.Files) {
+			ctx.AnswerCallback(msgelem.AlertCallbackAnswer(queryID, "无效的页码"))
+			return dispatcher.EndGroups
+		}
+		data.Page = page
+	default:
+		ctx.AnswerCallback(msgelem.AlertCallbackAnswer(queryID, "无效的操作"))
+		return dispatcher.EndGroups
+	}
+	if err := cache.Set(dataid, data); err != nil {
+		log.FromContext(ctx).Errorf("Failed to set cache: %s", err)
+		ctx.AnswerCallback(msgelem.AlertCallbackAnswer(queryID, "保存选择失败: "+err.Error()))
+		return dispatcher.EndGroups
+	}
+	ctx.AnswerCallback(&tg.MessagesSetBotCallbackAnswerRequest{QueryID: queryID})
+	ctx.EditMessage(userID, &tg.MessagesEditMessageRequest{
+		ID:          msgID,
+		ReplyMarkup: buildTorrentSelectKeyboard(dataid, data),
+	})
+	return dispatcher.EndGroups
+}
+
+func handleSilentSaveTorrent(ctx *ext.Context, update *ext.Update) error {
+	logger := log.FromContext(ctx)
+	stor := storage.FromContext(ctx)
+	if stor == nil {
+		logger.Warn("Context storage is nil")
+		ctx.Reply(update, ext.ReplyTextString("未找到存储"), nil)
+		return dispatcher.EndGroups
+	}
+	msg, metainfo, meta, err := shortcut.GetTorrentFromMessageWithReply(ctx, update)
+	if err != nil {
+		return err
+	}
+	// 静默模式下载种子中的所有文件
+	indexes := make([]int, len(meta.Files))
+	for i := range indexes {
+		indexes[i] = i
+	}
+	return shortcut.CreateAndAddTorrentTaskWithEdit(ctx, update.GetUserChat().GetID(), stor, "", tcbdata.Torrent{
+		Name:     meta.Name,
+		Metainfo: metainfo,
+		Indexes:  indexes,
+	}, msg.ID)
+}
@@ -0,0 +1,446 @@
+// 任务面板: 每个用户一条置顶消息, 汇总显示排队中, 执行中和最近结束的任务.
+//
+// 任务的状态和进度来自 core 的任务事件, 只更新内存中的状态, 由每个面板的刷新协程按固定间隔合并后编辑消息,
+// 避免触发 Telegram 的编辑频率限制. 面板消息的 ID 保存在用户记录中, 重启后继续使用同一条消息
+package dashboard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/celestix/gotgproto/ext"
+	"github.com/charmbracelet/log"
+	"github.com/gotd/td/tg"
+	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/msgelem"
+	"github.com/krau/SaveAny-Bot/common/utils/dlutil"
+	"github.com/krau/SaveAny-Bot/config"
+	"github.com/krau/SaveAny-Bot/core"
+	"github.com/krau/SaveAny-Bot/database"
+)
+
+type state int
+
+const (
+	stateQueued state = iota
+	stateRunning
+	stateSucceeded
+	stateFailed
+	stateCanceled
+	stateInterrupted // 因 Bot 重启而中断, 重启后恢复
+)
+
+// 面板中的一个任务
+type entry struct {
+	id        string
+	name      string
+	state     state
+	countUnit bool // 进度以个数计 (如 Telegraph 图片), 否则以字节计
+	current   int64
+	total     int64
+	start     time.Time
+	finished  time.Time
+	err       error
+}
+
+func (e *entry) active() bool {
+	return e.state == stateQueued || e.state == stateRunning
+}
+
+type Board struct {
+	userID int64
+
+	mu      sync.Mutex
+	ext     *ext.Context
+	msgID   int
+	entries []*entry
+	dirty   bool
+	running bool // 刷新协程是否在运行
+}
+
+var (
+	boardsMu sync.Mutex
+	boards   = make(map[int64]*Board)
+)
+
+// 获取用户的任务面板
+func For(userID int64) *Board {
+	boardsMu.Lock()
+	defer boardsMu.Unlock()
+	b, ok := boards[userID]
+	if !ok {
+		b = &Board{userID: userID}
+		boards[userID] = b
+	}
+	return b
+}
+
+// 获取已创建的面板, 用户从未使用过面板时返回 nil
+func lookup(userID int64) *Board {
+	boardsMu.Lock()
+	defer boardsMu.Unlock()
+	return boards[userID]
+}
+
+func interval() time.Duration {
+	return time.Duration(max(config.Cfg.Dashboard.Interval, 2)) * time.Second
+}
+
+// 调用方需持有锁
+func (b *Board) find(id string) *entry {
+	for _, e := range b.entries {
+		if e.id == id {
+			return e
+		}
+	}
+	return nil
+}
+
+// 调用方需持有锁
+func (b *Board) markDirty() {
+	b.dirty = true
+	if b.running || b.ext == nil {
+		return
+	}
+	b.running = true
+	go b.loop()
+}
+
+// 在任务加入队列前登记任务. ctx 用于发送和编辑面板消息
+func (b *Board) Queue(ctx *ext.Context, taskID, name string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.ext = ctx
+	if b.find(taskID) == nil {
+		b.entries = append(b.entries, &entry{id: taskID, name: name})
+	}
+	b.markDirty()
+}
+
+// 任务未能加入队列时移除登记
+func (b *Board) Remove(taskID string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for i, e := range b.entries {
+		if e.id == taskID {
+			b.entries = append(b.entries[:i], b.entries[i+1:]...)
+			break
+		}
+	}
+	b.markDirty()
+}
+
+// 更新已登记的任务, 未通过 Queue 登记的任务 (用户未开启面板时添加的任务) 不显示在面板上
+func (b *Board) update(taskID string, fn func(e *entry)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	e := b.find(taskID)
+	if e == nil {
+		return
+	}
+	fn(e)
+	b.markDirty()
+}
+
+func (b *Board) started(taskID string, total int64, countUnit bool) {
+	b.update(taskID, func(e *entry) {
+		e.state = stateRunning
+		e.start = time.Now()
+		e.total = total
+		e.countUnit = countUnit
+	})
+}
+
+func (b *Board) progress(taskID string, current, total int64) {
+	b.update(taskID, func(e *entry) {
+		e.current = current
+		if total > 0 {
+			e.total = total
+		}
+	})
+}
+
+func (b *Board) done(taskID string, err error) {
+	b.update(taskID, func(e *entry) {
+		e.finished = time.Now()
+		e.err = err
+		switch {
+		case err == nil:
+			e.state = stateSucceeded
+		case errors.Is(err, core.ErrShutdown):
+			e.state = stateInterrupted
+		case errors.Is(err, context.Canceled):
+			e.state = stateCanceled
+		default:
+			e.state = stateFailed
+		}
+	})
+}
+
+var ErrNotOnBoard = errors.New("任务不存在或已结束")
+
+// 取消面板中的任务, 状态由任务的取消事件更新. 只能取消面板所属用户自己的未结束任务
+func (b *Board) Cancel(ctx context.Context, taskID string) error {
+	b.mu.Lock()
+	e := b.find(taskID)
+	active := e != nil && e.active()
+	b.mu.Unlock()
+	if !active {
+		return ErrNotOnBoard
+	}
+	if task, err := core.GetTask(ctx, taskID); err == nil {
+		if d, ok := task.(core.Describable); ok && d.Meta().UserID != b.userID {
+			return ErrNotOnBoard
+		}
+	}
+	return core.CancelTask(ctx, taskID)
+}
+
+// 调用方需持有锁. 只保留配置数量的已结束任务
+func (b *Board) prune() {
+	keep := max(config.Cfg.Dashboard.KeepFinished, 0)
+	finished := 0
+	for i := len(b.entries) - 1; i >= 0; i-- {
+		if b.entries[i].active() {
+			continue
+		}
+		finished++
+		if finished > keep {
+			b.entries = append(b.entries[:i], b.entries[i+1:]...)
+		}
+	}
+}
+
+func (b *Board) loop() {
+	ticker := time.NewTicker(interval())
+	defer ticker.Stop()
+	for range ticker.C {
+		b.mu.Lock()
+		if !b.dirty {
+			idle := true
+			for _, e := range b.entries {
+				if e.active() {
+					idle = false
+					break
+				}
+			}
+			if idle {
+				b.running = false
+				b.mu.Unlock()
+				return
+			}
+			b.mu.Unlock()
+			continue
+		}
+		b.dirty = false
+		b.prune()
+		extCtx := b.ext
+		text, entities, markup := b.render()
+		msgID := b.msgID
+		b.mu.Unlock()
+
+		newID := b.flush(extCtx, msgID, text, entities, markup)
+		b.mu.Lock()
+		b.msgID = newID
+		b.mu.Unlock()
+	}
+}
+
+// 立即刷新所有有未显示变更的面板, 在关闭前调用
+func FlushAll() {
+	boardsMu.Lock()
+	all := make([]*Board, 0, len(boards))
+	for _, b := range boards {
+		all = append(all, b)
+	}
+	boardsMu.Unlock()
+	for _, b := range all {
+		b.mu.Lock()
+		if !b.dirty || b.ext == nil {
+			b.mu.Unlock()
+			continue
+		}
+		b.dirty = false
+		b.prune()
+		extCtx := b.ext
+		text, entities, markup := b.render()
+		msgID := b.msgID
+		b.mu.Unlock()
+
+		newID := b.flush(extCtx, msgID, text, entities, markup)
+		b.mu.Lock()
+		b.msgID = newID
+		b.mu.Unlock()
+	}
+}
+
+// 编辑面板消息, 失败时 (消息被删除或过旧) 发送新消息并置顶, 同时取消置顶旧消息. 返回面板消息 ID.
+// msgID 为 0 时使用重启前保存的面板消息
+func (b *Board) flush(ctx *ext.Context, msgID int, text string, entities []tg.MessageEntityClass, markup tg.ReplyMarkupClass) int {
+	logger := log.FromContext(ctx)
+	if msgID == 0 {
+		if user, err := database.GetUserByChatID(ctx, b.userID); err == nil {
+			msgID = user.DashboardMsgID
+		}
+	}
+	if msgID != 0 {
+		err := msgelem.EditWithFormattedText(ctx, &tg.InputPeerUser{UserID: b.userID}, msgID, text, entities, markup)
+		if err == nil || strings.Contains(err.Error(), "MESSAGE_NOT_MODIFIED") {
+			return msgID
+		}
+		logger.Warn("Failed to edit dashboard message, sending a new one", "error", err, "user_id", b.userID)
+	}
+	req := &tg.MessagesSendMessageRequest{Message: text}
+	req.SetEntities(entities)
+	if markup != nil {
+		req.SetReplyMarkup(markup)
+	}
+	msg, err := ctx.SendMessage(b.userID, req)
+	if err != nil {
+		logger.Error("Failed to send dashboard message", "error", err, "user_id", b.userID)
+		return msgID
+	}
+	peer := ctx.PeerStorage.GetInputPeerById(b.userID)
+	if _, err := ctx.Raw.MessagesUpdatePinnedMessage(ctx, &tg.MessagesUpdatePinnedMessageRequest{
+		Silent: true,
+		Peer:   peer,
+		ID:     msg.ID,
+	}); err != nil {
+		logger.Warn("Failed to pin dashboard message", "error", err, "user_id", b.userID)
+	}
+	if msgID != 0 {
+		// 旧消息可能已被删除, 取消置顶失败无需处理
+		if _, err := ctx.Raw.MessagesUpdatePinnedMessage(ctx, &tg.MessagesUpdatePinnedMessageRequest{
+			Unpin: true,
+			Peer:  peer,
+			ID:    msgID,
+		}); err != nil {
+			logger.Debug("Failed to unpin old dashboard message", "error", err, "user_id", b.userID)
+		}
+	}
+	if err := database.UpdateUserDashboardMsgID(ctx, b.userID, msg.ID); err != nil {
+		logger.Warn("Failed to save dashboard message ID", "error", err, "user_id", b.userID)
+	}
+	return msg.ID
+}
+
+const (
+	// 面板最多显示的任务数, 使消息不超过 Telegram 的 4096 字符和按钮数量限制
+	maxShown    = 15
+	maxNameLen  = 48
+	maxErrorLen = 120
+)
+
+// 调用方需持有锁. 任务过多时优先显示未结束的任务, 其次是最近结束的任务, 返回要显示的任务和隐藏的数量
+func (b *Board) shown() ([]*entry, int) {
+	if len(b.entries) <= maxShown {
+		return b.entries, 0
+	}
+	show := make(map[*entry]bool, maxShown)
+	for _, e := range b.entries {
+		if len(show) < maxShown && e.active() {
+			show[e] = true
+		}
+	}
+	for i := len(b.entries) - 1; i >= 0 && len(show) < maxShown; i-- {
+		show[b.entries[i]] = true
+	}
+	entries := make([]*entry, 0, maxShown)
+	for _, e := range b.entries {
+		if show[e] {
+			entries = append(entries, e)
+		}
+	}
+	return entries, len(b.entries) - len(entries)
+}
+
+// 调用方需持有锁
+func (b *Board) render() (string, []tg.MessageEntityClass, tg.ReplyMarkupClass) {
+	var running, queued, finished int
+	for _, e := range b.entries {
+		switch e.state {
+		case stateRunning:
+			running++
+		case stateQueued:
+			queued++
+		default:
+			finished++
+		}
+	}
+	template := msgelem.NewInfoTemplate("📋 任务面板", fmt.Sprintf("执行中 %d · 排队中 %d · 已结束 %d", running, queued, finished))
+	rows := make([]tg.KeyboardButtonRow, 0)
+	entries, hidden := b.shown()
+	index := 0
+	for _, e := range entries {
+		index++
+		label := fmt.Sprintf("%d. %s", index, truncate(e.name, maxNameLen))
+		switch e.state {
+		case stateRunning:
+			template.AddItem("⬇️", label, e.progressText(), msgelem.ItemTypeText)
+		case stateQueued:
+			status := "排队中"
+			if pos := core.GetPosition(context.Background(), e.id); pos > 0 {
+				status = fmt.Sprintf("排队中, 第 %d 位", pos)
+			}
+			template.AddItem("⏳", label, status, msgelem.ItemTypeText)
+		case stateSucceeded:
+			template.AddItem("✅", label, "完成, 用时 "+msgelem.FormatDuration(e.finished.Sub(e.start)), msgelem.ItemTypeText)
+		case stateCanceled:
+			template.AddItem("🚫", label, "已取消", msgelem.ItemTypeText)
+		case stateInterrupted:
+			template.AddItem("⏸", label, "Bot 正在重启, 重启后自动恢复", msgelem.ItemTypeText)
+		case stateFailed:
+			template.AddItem("❌", label, "失败: "+truncate(e.err.Error(), maxErrorLen), msgelem.ItemTypeText)
+		}
+		if e.active() {
+			rows = append(rows, tg.KeyboardButtonRow{Buttons: []tg.KeyboardButtonClass{
+				&tg.KeyboardButtonCallback{
+					Text: fmt.Sprintf("取消 %d. %s", index, truncate(e.name, 24)),
+					Data: fmt.Appendf(nil, "%s%s", CancelCallbackPrefix, e.id),
+				},
+			}})
+		}
+	}
+	if hidden > 0 {
+		template.Footer = fmt.Sprintf("…还有 %d 个任务未显示", hidden)
+	}
+	text, entities := template.BuildFormattedMessage()
+	if len(rows) == 0 {
+		return text, entities, nil
+	}
+	return text, entities, &tg.ReplyInlineMarkup{Rows: rows}
+}
+
+// 面板取消按钮的回调前缀
+const CancelCallbackPrefix = "dash_cancel:"
+
+func (e *entry) progressText() string {
+	if e.total <= 0 {
+		return "开始执行"
+	}
+	percent := e.current * 100 / e.total
+	if e.countUnit {
+		return fmt.Sprintf("%d/%d (%d%%)", e.current, e.total, percent)
+	}
+	text := fmt.Sprintf("%d%% · %s/%s", percent, msgelem.FormatSize(e.current), msgelem.FormatSize(e.total))
+	speed := dlutil.GetSpeed(e.current, e.start)
+	if speed <= 0 {
+		return text
+	}
+	text += fmt.Sprintf(" · %s/s", msgelem.FormatSize(int64(speed)))
+	if remaining := int64(float64(e.total-e.current) / speed); remaining > 0 {
+		text += " · 剩余 " + msgelem.FormatDuration(time.Duration(remaining)*time.Second)
+	}
+	return text
+}
+
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n-1]) + "…"
+}
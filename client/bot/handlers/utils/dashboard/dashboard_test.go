@@ -0,0 +1,44 @@
+package dashboard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/gotd/td/tg"
+)
+
+func TestRenderLimitsEntries(t *testing.T) {
+	b := &Board{userID: 1}
+	for i := range 200 {
+		e := &entry{id: fmt.Sprint(i), name: strings.Repeat("长", 100), state: stateFailed, err: errors.New(strings.Repeat("x", 1000))}
+		if i >= 190 {
+			e.state = stateQueued
+		}
+		b.entries = append(b.entries, e)
+	}
+	text, _, markup := b.render()
+	if n := utf8.RuneCountInString(text); n > 4096 {
+		t.Fatalf("dashboard text has %d characters", n)
+	}
+	if !strings.Contains(text, "还有 185 个任务未显示") {
+		t.Errorf("missing hidden task count in %q", text)
+	}
+	if rows := markup.(*tg.ReplyInlineMarkup).Rows; len(rows) != 10 {
+		t.Errorf("got %d cancel buttons, want one for each of the 10 queued tasks", len(rows))
+	}
+}
+
+func TestCancelOnlyOwnTasks(t *testing.T) {
+	b := &Board{userID: 1, entries: []*entry{
+		{id: "done", state: stateSucceeded},
+	}}
+	for _, id := range []string{"other", "done"} {
+		if err := b.Cancel(context.Background(), id); !errors.Is(err, ErrNotOnBoard) {
+			t.Errorf("Cancel(%s) = %v, want ErrNotOnBoard", id, err)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package dashboard
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/krau/SaveAny-Bot/core"
+)
+
+// 订阅任务事件, 将已在面板登记的任务的状态和进度显示到所属用户的面板. 在处理任务前调用一次.
+//
+// 面板只更新内存中的状态, 以同步方式订阅, 关闭时 FlushAll 能看到所有任务的最终状态
+func Subscribe() func() {
+	return core.SubscribeSync(handleEvent, core.EventStarted, core.EventProgress, core.EventSucceeded, core.EventFailed, core.EventCanceled)
+}
+
+func handleEvent(ctx context.Context, ev core.Event) {
+	b := lookup(ev.Task.UserID)
+	if b == nil {
+		return
+	}
+	switch ev.Type {
+	case core.EventStarted:
+		total := ev.Task.TotalBytes
+		if ev.Task.CountProgress {
+			total = int64(ev.Task.Count)
+		}
+		b.started(ev.Task.TaskID, total, ev.Task.CountProgress)
+	case core.EventProgress:
+		b.progress(ev.Task.TaskID, ev.Done, ev.Total)
+	case core.EventSucceeded:
+		b.done(ev.Task.TaskID, nil)
+	case core.EventFailed, core.EventCanceled:
+		b.done(ev.Task.TaskID, ev.Err)
+	}
+}
+
+// 批量任务在面板上显示的名称
+func BatchName(count int) string {
+	return fmt.Sprintf("批量任务 (%d 个文件)", count)
+}
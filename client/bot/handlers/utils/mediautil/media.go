@@ -1,11 +1,19 @@
 package mediautil
 
-import "github.com/gotd/td/tg"
+import (
+	"github.com/gotd/td/tg"
+	"github.com/krau/SaveAny-Bot/pkg/tfile"
+)
 
+// 消息的媒体中是否有可保存的文件. 网页预览由链接处理器处理, 不作为消息的文件
 func IsSupported(media tg.MessageMediaClass) bool {
 	switch media.(type) {
 	case *tg.MessageMediaDocument, *tg.MessageMediaPhoto:
 		return true
+	case *tg.MessageMediaPaidMedia, *tg.MessageMediaInvoice, *tg.MessageMediaGame:
+		// 未购买的付费媒体只有预览
+		_, err := tfile.UnwrapMedia(media)
+		return err == nil
 	default:
 		return false
 	}
@@ -0,0 +1,20 @@
+package msgelem
+
+const (
+	ArchiveHelpText = `
+使用 /archive 命令归档一个聊天的消息, 生成 messages.jsonl 和可浏览的 index.html, 消息中的媒体按存储规则保存.
+
+命令语法:
+/archive <chat_id> [开始日期] [结束日期]
+
+参数:
+- <chat_id>: 聊天的 ID 或用户名
+- [开始日期] [结束日期]: 可选, 格式为 YYYY-MM-DD, 包含这两天
+
+命令示例:
+/archive @telegram 2024-01-01 2024-12-31
+
+再次归档同一聊天到相同位置时, 将从上次归档的最后一条消息继续.
+未启用 userbot 时只能归档 Bot 所在的频道和超级群组.
+	`
+)
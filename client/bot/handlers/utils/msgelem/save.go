@@ -11,5 +11,11 @@ const (
 	2. 设置默认存储后, 发送 /save <频道ID/用户名> <消息ID范围> 来批量保存文件. 遵从存储规则, 若未匹配到任何规则则使用默认存储.
 	示例:
 	/save @acherkrau 114-514
+	范围会被分页扫描, 每找到一批文件就创建一个批量任务, 扫描过程中可以随时停止.
+
+	3. 发送 /save <群组ID/用户名> topic:<话题ID> 来保存论坛话题中的所有文件, 可以在最后加上正则表达式过滤消息.
+	示例:
+	/save @acherkrau topic:11
+	存储规则和文件夹路径中可以使用 {{.TopicTitle}} 表示话题标题.
 	`
 )
@@ -26,10 +26,24 @@ func BuildAddSelectStorageKeyboard(ctx context.Context, chatID int64, adddata tc
 	}
 	taskType := adddata.TaskType
 	if taskType == "" {
-		if len(adddata.Files) > 0 {
+		if len(adddata.Files) > 0 || adddata.SaveRange != nil || adddata.Comments != nil || adddata.Topic != nil {
 			taskType = tasktype.TaskTypeTgfiles
 		} else if adddata.TphPageNode != nil {
 			taskType = tasktype.TaskTypeTphpics
+		} else if adddata.HTTPFile != nil {
+			taskType = tasktype.TaskTypeHttpfile
+		} else if adddata.Ytdlp != nil {
+			taskType = tasktype.TaskTypeYtdlp
+		} else if adddata.Torrent != nil {
+			taskType = tasktype.TaskTypeTorrent
+		} else if adddata.InstantView != nil {
+			taskType = tasktype.TaskTypeInstantview
+		} else if adddata.Note != nil {
+			taskType = tasktype.TaskTypeNote
+		} else if adddata.Archive != nil {
+			taskType = tasktype.TaskTypeArchive
+		} else if adddata.StickerSet != nil {
+			taskType = tasktype.TaskTypeSticker
 		} else {
 			return nil, fmt.Errorf("unknown task type: %s", taskType)
 		}
@@ -47,6 +61,19 @@ func BuildAddSelectStorageKeyboard(ctx context.Context, chatID int64, adddata tc
 			TphPageNode: adddata.TphPageNode,
 			TphPics:     adddata.TphPics,
 			TphDirPath:  adddata.TphDirPath,
+
+			SaveRange: adddata.SaveRange,
+			Comments:  adddata.Comments,
+			Topic:     adddata.Topic,
+
+			HTTPFile: adddata.HTTPFile,
+			Ytdlp:    adddata.Ytdlp,
+			Torrent:  adddata.Torrent,
+
+			InstantView: adddata.InstantView,
+			Note:        adddata.Note,
+			Archive:     adddata.Archive,
+			StickerSet:  adddata.StickerSet,
 		}
 		dataid := xid.New().String()
 		err := cache.Set(dataid, data)
@@ -96,6 +123,26 @@ func BuildAddOneSelectStorageMessage(ctx context.Context, chatID int64, file tfi
 	}, nil
 }
 
+// 只有一个文件时同 BuildAddOneSelectStorageMessage, 多个文件时作为批量任务选择存储
+func BuildAddSelectStorageMessage(ctx context.Context, chatID int64, files []tfile.TGFileMessage, msgId int) (*tg.MessagesEditMessageRequest, error) {
+	if len(files) == 1 {
+		return BuildAddOneSelectStorageMessage(ctx, chatID, files[0], msgId)
+	}
+	markup, err := BuildAddSelectStorageKeyboard(ctx, chatID, tcbdata.Add{
+		TaskType: tasktype.TaskTypeTgfiles,
+		Files:    files,
+		AsBatch:  true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to build storage keyboard: %w", err)
+	}
+	return &tg.MessagesEditMessageRequest{
+		Message:     fmt.Sprintf("共 %d 个文件, 请选择存储位置", len(files)),
+		ReplyMarkup: markup,
+		ID:          msgId,
+	}, nil
+}
+
 func BuildSetDefaultStorageMarkup(ctx context.Context, userID int64) (*tg.ReplyInlineMarkup, error) {
 	// 获取所有可用存储（系统配置 + 用户自定义）
 	stors, err := storage.Manager.GetAllUserStorages(ctx, userID)
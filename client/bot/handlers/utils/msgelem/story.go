@@ -0,0 +1,19 @@
+package msgelem
+
+const (
+	StoriesHelpText = `
+使用 /stories 命令保存一个用户或频道当前的故事和所有置顶的故事, 需要启用 userbot.
+
+命令语法:
+/stories <chat_id>
+
+参数:
+- <chat_id>: 用户或频道的 ID 或用户名
+
+命令示例:
+/stories @telegram
+
+也可以直接发送故事链接, 例如 https://t.me/telegram/s/1
+故事在发布 24 小时后过期, 可以使用 /watch <chat_id> stories 自动保存新发布的故事.
+	`
+)
@@ -5,15 +5,21 @@ const (
 使用 /watch 命令监听一个聊天的消息, 并自动保存到默认存储中, 遵从存储规则.
 
 命令语法:
-/watch <chat_id> [filter]
+/watch <chat_id> [topic:<topic_id>] [filter]
 
 参数:
 - <chat_id>: 聊天的 ID 或用户名
+- [topic:<topic_id>]: 可选, 只监听论坛中的此话题, 话题 ID 即话题链接中的数字
 - [filter]: 可选, 格式为 过滤器类型:表达式 , 所有支持类型的过滤器请查看文档
 
 命令示例:
 /watch 2229835658 msgre:.*plana.*
 
 这将监听 ID 为 2229835658 的聊天, 并转存所有包含 "plana" 的媒体消息
+
+/watch 2229835658 topic:11
+这将只监听该论坛中 ID 为 11 的话题, 使用 /unwatch 2229835658 topic:11 取消
+
+使用 /watch <chat_id> stories 监听用户或频道新发布的故事, 需要 userbot 账号关注该用户或频道.
 	`
 )
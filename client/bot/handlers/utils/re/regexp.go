@@ -3,8 +3,18 @@ package re
 import "regexp"
 
 var (
-	TgMessageLinkRegexString = `https?://t\.me/(?:c/\d+|[A-Za-z0-9_]+)/\d+(?:/\d+)?(?:\?[^\s#]*[A-Za-z0-9_])?\b`
-	TgMessageLinkRegexp      = regexp.MustCompile(TgMessageLinkRegexString)
-	TelegraphUrlRegexString  = `https://telegra.ph/.*`
-	TelegraphUrlRegexp       = regexp.MustCompile(TelegraphUrlRegexString)
+	TgMessageLinkRegexString  = `https?://t\.me/(?:c/\d+|[A-Za-z0-9_]+)/\d+(?:/\d+)?(?:\?[^\s#]*[A-Za-z0-9_])?\b`
+	TgMessageLinkRegexp       = regexp.MustCompile(TgMessageLinkRegexString)
+	TelegraphUrlRegexString   = `https://telegra.ph/.*`
+	TelegraphUrlRegexp        = regexp.MustCompile(TelegraphUrlRegexString)
+	HttpUrlRegexString        = `https?://[^\s/$.?#][^\s]*`
+	HttpUrlRegexp             = regexp.MustCompile(HttpUrlRegexString)
+	MagnetRegexString         = `magnet:\?[^\s]*xt=urn:bt[im]h:[^\s]+`
+	MagnetRegexp              = regexp.MustCompile(MagnetRegexString)
+	StickerSetLinkRegexString = `https?://t\.me/(addstickers|addemoji)/([A-Za-z0-9_]+)`
+	StickerSetLinkRegexp      = regexp.MustCompile(StickerSetLinkRegexString)
+	StoryLinkRegexString      = `https?://t\.me/([A-Za-z0-9_]+)/s/(\d+)`
+	StoryLinkRegexp           = regexp.MustCompile(StoryLinkRegexString)
+	TgInviteLinkRegexString   = `https?://t\.me/(?:\+|joinchat/)([A-Za-z0-9_-]+)`
+	TgInviteLinkRegexp        = regexp.MustCompile(TgInviteLinkRegexString)
 )
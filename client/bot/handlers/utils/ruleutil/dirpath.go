@@ -0,0 +1,89 @@
+package ruleutil
+
+import (
+	"bytes"
+	"context"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/celestix/gotgproto/ext"
+	"github.com/celestix/gotgproto/functions"
+	"github.com/charmbracelet/log"
+	uc "github.com/krau/SaveAny-Bot/client/user"
+	"github.com/krau/SaveAny-Bot/common/utils/tgutil"
+	"github.com/krau/SaveAny-Bot/pkg/ai"
+)
+
+// 目录路径中可以使用的模板值, 例如 {{.TopicTitle}}
+type DirPathValues struct {
+	// 文件所在的论坛话题, 不在话题中时为空
+	TopicID    int
+	TopicTitle string
+}
+
+// 已知文件所在的论坛话题时直接使用, 不再获取话题标题
+func WithTopic(id int, title string) ruleInputOption {
+	return func(i *ruleInput) {
+		i.topic = &DirPathValues{TopicID: id, TopicTitle: ai.SanitizeFilename(title)}
+	}
+}
+
+func (i *ruleInput) dirPathValues(ctx context.Context) DirPathValues {
+	if i.topic != nil {
+		return *i.topic
+	}
+	i.topic = &DirPathValues{}
+	if i.File == nil {
+		return *i.topic
+	}
+	msg := i.File.Message()
+	topicID, ok := tgutil.MessageTopicID(msg)
+	if !ok {
+		return *i.topic
+	}
+	i.topic.TopicID = topicID
+	i.topic.TopicTitle = topicTitle(ctx, functions.GetChatIdFromPeer(msg.PeerID), topicID)
+	return *i.topic
+}
+
+// 启用 userbot 时优先使用 userbot 获取话题标题, 失败时使用 Bot. 都获取不到时使用话题 ID
+func topicTitle(ctx context.Context, chatID int64, topicID int) string {
+	logger := log.FromContext(ctx)
+	ctxs := make([]*ext.Context, 0, 2)
+	if uctx, err := uc.ReadyCtx(); err == nil {
+		ctxs = append(ctxs, uctx)
+	}
+	if ectx := tgutil.ExtFromContext(ctx); ectx != nil {
+		ctxs = append(ctxs, ectx)
+	} else if ectx, ok := ctx.(*ext.Context); ok {
+		ctxs = append(ctxs, ectx)
+	}
+	for _, ectx := range ctxs {
+		title, err := tgutil.GetTopicTitle(ectx, chatID, topicID)
+		if err == nil {
+			return ai.SanitizeFilename(title)
+		}
+		logger.Debugf("Failed to get title of topic %d in %d: %s", topicID, chatID, err)
+	}
+	return strconv.Itoa(topicID)
+}
+
+// 渲染目录路径中的模板, 不含模板或渲染失败时原样返回
+func RenderDirPath(ctx context.Context, dirPath string, input *ruleInput) string {
+	if !strings.Contains(dirPath, "{{") || input == nil {
+		return dirPath
+	}
+	logger := log.FromContext(ctx)
+	tmpl, err := template.New("dir").Parse(dirPath)
+	if err != nil {
+		logger.Errorf("Invalid dir path template %q: %s", dirPath, err)
+		return dirPath
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, input.dirPathValues(ctx)); err != nil {
+		logger.Errorf("Failed to render dir path %q: %s", dirPath, err)
+		return dirPath
+	}
+	return buf.String()
+}
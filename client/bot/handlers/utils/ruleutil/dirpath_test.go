@@ -0,0 +1,22 @@
+package ruleutil
+
+import (
+	"context"
+	"testing"
+)
+
+func TestRenderDirPath(t *testing.T) {
+	ctx := context.Background()
+	input := NewInput(nil, WithTopic(11, "Photos"))
+	cases := map[string]string{
+		"forum/{{.TopicTitle}}": "forum/Photos",
+		"topic_{{.TopicID}}":    "topic_11",
+		"plain/dir":             "plain/dir",
+		"bad/{{.TopicTitle":     "bad/{{.TopicTitle",
+	}
+	for dirPath, want := range cases {
+		if got := RenderDirPath(ctx, dirPath, input); got != want {
+			t.Errorf("RenderDirPath(%q) = %q, want %q", dirPath, got, want)
+		}
+	}
+}
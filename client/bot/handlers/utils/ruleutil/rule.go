@@ -15,6 +15,11 @@ import (
 
 type ruleInput struct {
 	File tfile.TGFileMessage
+	// 没有 File 时使用的文件名和消息文本
+	name string
+	text string
+	// 渲染目录路径模板时的值, 需要时才获取
+	topic *DirPathValues
 }
 
 type ruleInputOption func(*ruleInput)
@@ -29,6 +34,33 @@ func NewInput(file tfile.TGFileMessage, opts ...ruleInputOption) *ruleInput {
 	return input
 }
 
+// 用于不来自 Telegram 的文件, 只有文件名和消息文本规则生效
+func NewNameInput(name, text string) *ruleInput {
+	return &ruleInput{name: name, text: text}
+}
+
+func (i *ruleInput) fileName() string {
+	if i.File != nil {
+		return i.File.Name()
+	}
+	return i.name
+}
+
+func (i *ruleInput) messageText() string {
+	if i.File != nil {
+		return i.File.Message().GetMessage()
+	}
+	return i.text
+}
+
+func (i *ruleInput) isAlbum() bool {
+	if i.File == nil {
+		return false
+	}
+	groupID, isGroup := i.File.Message().GetGroupedID()
+	return isGroup && groupID != 0
+}
+
 type matchedStorName string
 
 func (m matchedStorName) String() string {
@@ -63,7 +95,7 @@ func ApplyRule(ctx context.Context, rules []database.Rule, inputs *ruleInput) (m
 				logger.Errorf("Failed to create rule: %s", err)
 				continue
 			}
-			ok, err := ru.Match(inputs.File)
+			ok, err := ru.MatchName(inputs.fileName())
 			if err != nil {
 				logger.Errorf("Failed to match rule: %s", err)
 				continue
@@ -78,7 +110,7 @@ func ApplyRule(ctx context.Context, rules []database.Rule, inputs *ruleInput) (m
 				logger.Errorf("Failed to create rule: %s", err)
 				continue
 			}
-			ok, err := ru.Match(inputs.File.Message().GetMessage())
+			ok, err := ru.Match(inputs.messageText())
 			if err != nil {
 				logger.Errorf("Failed to match rule: %s", err)
 				continue
@@ -97,8 +129,7 @@ func ApplyRule(ctx context.Context, rules []database.Rule, inputs *ruleInput) (m
 				logger.Errorf("Failed to create rule: %s", err)
 				continue
 			}
-			groupID, isGroup := inputs.File.Message().GetGroupedID()
-			ok, err := ru.Match(isGroup && groupID != 0)
+			ok, err := ru.Match(inputs.isAlbum())
 			if err != nil {
 				logger.Errorf("Failed to match rule: %s", err)
 				continue
@@ -109,5 +140,8 @@ func ApplyRule(ctx context.Context, rules []database.Rule, inputs *ruleInput) (m
 			}
 		}
 	}
+	if dirPath != "" && !dirPath.NeedNewForAlbum() {
+		dirPath = MatchedDirPath(RenderDirPath(ctx, dirPath.String(), inputs))
+	}
 	return
 }
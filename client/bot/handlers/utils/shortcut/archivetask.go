@@ -0,0 +1,122 @@
+package shortcut
+
+import (
+	"context"
+	"path"
+
+	"github.com/celestix/gotgproto/dispatcher"
+	"github.com/celestix/gotgproto/ext"
+	"github.com/charmbracelet/log"
+	"github.com/gotd/td/tg"
+	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/dashboard"
+	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/msgelem"
+	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/ruleutil"
+	userclient "github.com/krau/SaveAny-Bot/client/user"
+	"github.com/krau/SaveAny-Bot/common/utils/tgutil"
+	"github.com/krau/SaveAny-Bot/core"
+	"github.com/krau/SaveAny-Bot/core/archivetask"
+	"github.com/krau/SaveAny-Bot/database"
+	"github.com/krau/SaveAny-Bot/pkg/tcbdata"
+	"github.com/krau/SaveAny-Bot/pkg/tfile"
+	"github.com/krau/SaveAny-Bot/storage"
+	"github.com/rs/xid"
+)
+
+// 获取历史消息和下载媒体使用的客户端
+func archiveClient(ctx *ext.Context, a tcbdata.Archive) (*ext.Context, error) {
+	if a.Userbot {
+		return userclient.ReadyCtx()
+	}
+	return ctx, nil
+}
+
+// 按用户的规则决定媒体的保存位置, 没有匹配的规则时保存到归档目录中的 media 目录
+func archiveRouter(user *database.User, stor storage.Storage, root string) archivetask.Router {
+	useRule := user.ApplyRule && user.Rules != nil
+	return func(ctx context.Context, file tfile.TGFileMessage) (storage.Storage, string, error) {
+		if useRule {
+			storName, dirPath := ruleutil.ApplyRule(ctx, user.Rules, ruleutil.NewInput(file))
+			if storName.IsUsable() || dirPath != "" && !dirPath.NeedNewForAlbum() {
+				target := stor
+				if storName.IsUsable() {
+					var err error
+					target, err = storage.Manager.GetUserStorageByName(ctx, user.ChatID, storName.String())
+					if err != nil {
+						return nil, "", err
+					}
+				}
+				return target, target.JoinStoragePath(path.Join(dirPath.String(), file.Name())), nil
+			}
+		}
+		return stor, path.Join(root, "media", file.Name()), nil
+	}
+}
+
+// 创建一个 archivetask.Task 并添加到任务队列中, 以编辑消息的方式反馈结果.
+// 同一聊天再次归档到相同位置时, 从上次归档的最后一条消息继续
+func CreateAndAddArchiveTaskWithEdit(ctx *ext.Context, userID int64, stor storage.Storage, dirPath string, a tcbdata.Archive, trackMsgID int) error {
+	logger := log.FromContext(ctx)
+	editError := func(text string, err error) error {
+		ctx.EditMessage(userID, &tg.MessagesEditMessageRequest{
+			ID:      trackMsgID,
+			Message: text + ": " + err.Error(),
+		})
+		return dispatcher.EndGroups
+	}
+	user, err := database.GetUserByChatID(ctx, userID)
+	if err != nil {
+		logger.Errorf("Failed to get user by chat ID: %s", err)
+		return editError("获取用户失败", err)
+	}
+	archive, err := database.GetOrCreateChatArchive(ctx, &database.ChatArchive{
+		UserID:      user.ID,
+		ChatID:      a.ChatID,
+		StorageName: stor.Name(),
+		DirPath:     dirPath,
+		Path:        stor.JoinStoragePath(path.Join(dirPath, archivetask.DirName(a.Title, a.ChatID))),
+	})
+	if err != nil {
+		logger.Errorf("Failed to get chat archive: %s", err)
+		return editError("获取归档记录失败", err)
+	}
+
+	injectCtx := tgutil.ExtWithContext(ctx.Context, ctx)
+	taskid := xid.New().String()
+	var board *dashboard.Board
+	progress := archivetask.NewProgressTrack(trackMsgID, userID)
+	if user.Dashboard {
+		board = dashboard.For(userID)
+		progress = nil
+	}
+	client, err := archiveClient(ctx, a)
+	if err != nil {
+		return editError("获取客户端失败", err)
+	}
+	task, err := archivetask.NewTask(taskid, injectCtx, userID, client, a.Userbot, a.Title, a.From, a.To,
+		archive, stor, archiveRouter(user, stor, archive.Path), progress)
+	if err != nil {
+		logger.Errorf("create task failed: %s", err)
+		return editError("创建任务失败", err)
+	}
+	if board != nil {
+		board.Queue(ctx, taskid, task.Title())
+	}
+	if err := core.AddTask(injectCtx, task); err != nil {
+		logger.Errorf("add task failed: %s", err)
+		if board != nil {
+			board.Remove(taskid)
+		}
+		return editError("添加任务失败", err)
+	}
+	if board != nil {
+		ctx.DeleteMessages(userID, []int{trackMsgID})
+		return dispatcher.EndGroups
+	}
+	text, entities := msgelem.BuildTaskAddedEntities(ctx, task.Title(), core.GetLength(injectCtx))
+	ctx.EditMessage(userID, &tg.MessagesEditMessageRequest{
+		ID:       trackMsgID,
+		Message:  text,
+		Entities: entities,
+	})
+	return dispatcher.EndGroups
+}
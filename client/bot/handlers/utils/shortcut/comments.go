@@ -0,0 +1,173 @@
+package shortcut
+
+import (
+	"fmt"
+	"path"
+
+	"github.com/celestix/gotgproto/dispatcher"
+	"github.com/celestix/gotgproto/ext"
+	"github.com/celestix/gotgproto/functions"
+	"github.com/charmbracelet/log"
+	"github.com/gotd/td/tg"
+	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/mediautil"
+	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/re"
+	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/ruleutil"
+	uc "github.com/krau/SaveAny-Bot/client/user"
+	"github.com/krau/SaveAny-Bot/common/utils/tgutil"
+	"github.com/krau/SaveAny-Bot/core/batchtftask"
+	"github.com/krau/SaveAny-Bot/database"
+	"github.com/krau/SaveAny-Bot/pkg/ai"
+	"github.com/krau/SaveAny-Bot/pkg/tcbdata"
+	"github.com/krau/SaveAny-Bot/pkg/tfile"
+	"github.com/krau/SaveAny-Bot/storage"
+)
+
+// 消息中第一个有评论的频道消息链接, 评论链接也指向其所在的频道消息. 没有时返回 nil
+func GetCommentsFromLinks(ctx *ext.Context, text string) *tcbdata.Comments {
+	tctx, err := uc.ReadyCtx()
+	userbot := err == nil
+	if !userbot {
+		tctx = ctx
+	}
+	for _, link := range re.TgMessageLinkRegexp.FindAllString(text, -1) {
+		channelID, postID, err := tgutil.ParsePostLink(tctx, link)
+		if err != nil {
+			continue
+		}
+		msg, err := tgutil.GetMessageByID(tctx, channelID, postID)
+		if err != nil {
+			log.FromContext(ctx).Debugf("Failed to get post %d in %d: %s", postID, channelID, err)
+			continue
+		}
+		replies, ok := msg.GetReplies()
+		if !ok || !replies.Comments || replies.Replies == 0 {
+			continue
+		}
+		return &tcbdata.Comments{
+			ChannelID: channelID,
+			PostID:    postID,
+			Count:     replies.Replies,
+			Userbot:   userbot,
+		}
+	}
+	return nil
+}
+
+// 评论者的目录名, 由名称和 ID 组成. 以群组或频道身份发送的评论使用其名称
+func commenterDir(names *tgutil.PeerNames, msg *tg.Message) string {
+	from, ok := msg.GetFromID()
+	if !ok {
+		from = msg.PeerID
+	}
+	return fmt.Sprintf("%s_%d", ai.SanitizeFilename(names.Name(from)), functions.GetChatIdFromPeer(from))
+}
+
+// 分页获取频道消息的所有评论, 将其中的文件按评论者分目录, 每 rangeSaveChunkSize 个左右添加为一个批量任务, 以编辑消息的方式反馈结果.
+// 匹配存储规则时使用规则指定的存储和目录, 评论者目录位于其中
+func SaveCommentsWithEdit(ctx *ext.Context, userID int64, stor storage.Storage, dirPath string, c tcbdata.Comments, trackMsgID int) error {
+	logger := log.FromContext(ctx)
+	editText := func(text string) {
+		ctx.EditMessage(userID, &tg.MessagesEditMessageRequest{
+			ID:      trackMsgID,
+			Message: text,
+		})
+	}
+	editText("正在获取评论...")
+	tctx := ctx
+	if c.Userbot {
+		uctx, err := uc.ReadyCtx()
+		if err != nil {
+			editText("获取 userbot 失败: " + err.Error())
+			return dispatcher.EndGroups
+		}
+		tctx = uctx
+	}
+	user, err := database.GetUserByChatID(ctx, userID)
+	if err != nil {
+		logger.Errorf("Failed to get user by chat ID: %s", err)
+		editText("获取用户失败: " + err.Error())
+		return dispatcher.EndGroups
+	}
+	useRule := user.ApplyRule && user.Rules != nil
+
+	tracker := &batchTracker{ctx: ctx, userID: userID, trackMsgID: trackMsgID}
+	var pending []batchtftask.TaskElement
+	flush := func() {
+		if len(pending) == 0 {
+			return
+		}
+		msgID, err := tracker.next()
+		if err != nil {
+			logger.Errorf("Failed to send message: %s", err)
+			return
+		}
+		AddBatchTGFileTaskWithEdit(ctx, userID, pending, msgID)
+		pending = nil
+	}
+	// 按评论时间逐页获取并按批添加任务
+	seen := make(map[string]bool)
+	for page, err := range tgutil.IterReplies(tctx, c.ChannelID, c.PostID) {
+		if err != nil {
+			logger.Errorf("Failed to get comments of post %d: %s", c.PostID, err)
+			flush()
+			tracker.notify("获取评论失败: " + err.Error())
+			return dispatcher.EndGroups
+		}
+		for _, msg := range page.Messages {
+			media, ok := msg.GetMedia()
+			if !ok || !mediautil.IsSupported(media) {
+				continue
+			}
+			files, err := tfile.FilesFromMediaMessage(media, tctx.Raw, msg,
+				tfile.WithNameIfEmpty(tgutil.GenFileNameFromMessage(*msg)),
+				tfile.WithMessageGetter(tctx),
+			)
+			if err != nil {
+				logger.Errorf("Failed to get file of comment %d: %s", msg.ID, err)
+				continue
+			}
+			for _, file := range files {
+				fileStor, fileDir := stor, dirPath
+				if useRule {
+					storName, dirP := ruleutil.ApplyRule(ctx, user.Rules, ruleutil.NewInput(file))
+					if storName.IsUsable() {
+						fileStor, err = storage.Manager.GetUserStorageByName(ctx, user.ChatID, storName.String())
+						if err != nil {
+							logger.Errorf("Failed to get storage by user ID and name: %s", err)
+							flush()
+							tracker.notify("获取存储失败: " + err.Error())
+							return dispatcher.EndGroups
+						}
+					}
+					if dirP != "" && !dirP.NeedNewForAlbum() {
+						fileDir = dirP.String()
+					}
+				}
+				dir := commenterDir(page.Names, msg)
+				storPath := fileStor.JoinStoragePath(path.Join(fileDir, dir, file.Name()))
+				// 同一评论者的文件重名时以评论 ID 区分
+				if key := fileStor.Name() + ":" + storPath; seen[key] {
+					storPath = fileStor.JoinStoragePath(path.Join(fileDir, dir, fmt.Sprintf("%d_%s", msg.ID, file.Name())))
+				} else {
+					seen[key] = true
+				}
+				elem, err := batchtftask.NewTaskElement(fileStor, storPath, file)
+				if err != nil {
+					logger.Errorf("Failed to create task element: %s", err)
+					flush()
+					tracker.notify("任务创建失败: " + err.Error())
+					return dispatcher.EndGroups
+				}
+				pending = append(pending, *elem)
+			}
+		}
+		if len(pending) >= rangeSaveChunkSize {
+			flush()
+		}
+	}
+	flush()
+	if tracker.batches == 0 {
+		editText("评论中没有可保存的文件")
+	}
+	return dispatcher.EndGroups
+}
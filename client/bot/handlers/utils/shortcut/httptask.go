@@ -0,0 +1,101 @@
+package shortcut
+
+import (
+	"path"
+
+	"github.com/celestix/gotgproto/dispatcher"
+	"github.com/celestix/gotgproto/ext"
+	"github.com/charmbracelet/log"
+	"github.com/gotd/td/tg"
+	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/dashboard"
+	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/msgelem"
+	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/ruleutil"
+	"github.com/krau/SaveAny-Bot/common/utils/tgutil"
+	"github.com/krau/SaveAny-Bot/core"
+	"github.com/krau/SaveAny-Bot/core/httptask"
+	"github.com/krau/SaveAny-Bot/database"
+	"github.com/krau/SaveAny-Bot/pkg/httpdl"
+	"github.com/krau/SaveAny-Bot/pkg/tcbdata"
+	"github.com/krau/SaveAny-Bot/storage"
+	"github.com/rs/xid"
+)
+
+// 创建一个 httptask.Task 并添加到任务队列中, 以编辑消息的方式反馈结果
+func CreateAndAddHTTPTaskWithEdit(ctx *ext.Context, userID int64, stor storage.Storage, dirPath string, file tcbdata.HTTPFile, trackMsgID int) error {
+	logger := log.FromContext(ctx)
+	user, err := database.GetUserByChatID(ctx, userID)
+	if err != nil {
+		logger.Errorf("Failed to get user by chat ID: %s", err)
+		ctx.EditMessage(userID, &tg.MessagesEditMessageRequest{
+			ID:      trackMsgID,
+			Message: "获取用户失败: " + err.Error(),
+		})
+		return dispatcher.EndGroups
+	}
+	if user.ApplyRule && user.Rules != nil {
+		matchedStorageName, matchedDirPath := ruleutil.ApplyRule(ctx, user.Rules, ruleutil.NewNameInput(file.FileName, file.URL))
+		dirPath = matchedDirPath.String()
+		if matchedStorageName.IsUsable() {
+			stor, err = storage.Manager.GetUserStorageByName(ctx, user.ChatID, matchedStorageName.String())
+			if err != nil {
+				logger.Errorf("Failed to get storage by user ID and name: %s", err)
+				ctx.EditMessage(userID, &tg.MessagesEditMessageRequest{
+					ID:      trackMsgID,
+					Message: "获取存储失败: " + err.Error(),
+				})
+				return dispatcher.EndGroups
+			}
+		}
+	}
+	storagePath := stor.JoinStoragePath(path.Join(dirPath, file.FileName))
+	req := httpdl.Request{URL: file.URL, Headers: file.Headers}
+	info := &httpdl.FileInfo{
+		URL:          file.URL,
+		Name:         file.FileName,
+		Size:         file.Size,
+		AcceptRanges: file.AcceptRanges,
+	}
+
+	injectCtx := tgutil.ExtWithContext(ctx.Context, ctx)
+	taskid := xid.New().String()
+	var board *dashboard.Board
+	progress := httptask.NewProgressTrack(trackMsgID, userID)
+	if user.Dashboard {
+		board = dashboard.For(userID)
+		progress = nil
+	}
+	task, err := httptask.NewTask(taskid, injectCtx, userID, req, info, stor, storagePath, progress)
+	if err != nil {
+		logger.Errorf("create task failed: %s", err)
+		ctx.EditMessage(userID, &tg.MessagesEditMessageRequest{
+			ID:      trackMsgID,
+			Message: "创建任务失败: " + err.Error(),
+		})
+		return dispatcher.EndGroups
+	}
+	if board != nil {
+		board.Queue(ctx, taskid, file.FileName)
+	}
+	if err := core.AddTask(injectCtx, task); err != nil {
+		logger.Errorf("add task failed: %s", err)
+		if board != nil {
+			board.Remove(taskid)
+		}
+		ctx.EditMessage(userID, &tg.MessagesEditMessageRequest{
+			ID:      trackMsgID,
+			Message: "添加任务失败: " + err.Error(),
+		})
+		return dispatcher.EndGroups
+	}
+	if board != nil {
+		ctx.DeleteMessages(userID, []int{trackMsgID})
+		return dispatcher.EndGroups
+	}
+	text, entities := msgelem.BuildTaskAddedEntities(ctx, file.FileName, core.GetLength(injectCtx))
+	ctx.EditMessage(userID, &tg.MessagesEditMessageRequest{
+		ID:       trackMsgID,
+		Message:  text,
+		Entities: entities,
+	})
+	return dispatcher.EndGroups
+}
@@ -0,0 +1,158 @@
+package shortcut
+
+import (
+	"errors"
+
+	"github.com/celestix/gotgproto/dispatcher"
+	"github.com/celestix/gotgproto/ext"
+	"github.com/charmbracelet/log"
+	"github.com/gotd/td/tg"
+	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/dashboard"
+	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/msgelem"
+	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/ruleutil"
+	userclient "github.com/krau/SaveAny-Bot/client/user"
+	"github.com/krau/SaveAny-Bot/common/utils/tgutil"
+	"github.com/krau/SaveAny-Bot/config"
+	"github.com/krau/SaveAny-Bot/core"
+	"github.com/krau/SaveAny-Bot/core/ivtask"
+	"github.com/krau/SaveAny-Bot/database"
+	"github.com/krau/SaveAny-Bot/pkg/instantview"
+	"github.com/krau/SaveAny-Bot/pkg/tcbdata"
+	"github.com/krau/SaveAny-Bot/storage"
+	"github.com/rs/xid"
+)
+
+// 获取消息中网页预览的 Instant View, 没有时返回 nil
+func GetInstantView(ctx *ext.Context, message *tg.Message) *tcbdata.InstantView {
+	if wp := instantview.FromMessage(message); wp != nil {
+		return &tcbdata.InstantView{WebPage: wp}
+	}
+	url := instantview.URLFromMessage(message)
+	if url == "" {
+		return nil
+	}
+	iv, err := FetchInstantView(ctx, url)
+	if err != nil {
+		if !errors.Is(err, instantview.ErrNoInstantView) {
+			log.FromContext(ctx).Debugf("Failed to get instant view of %s: %s", url, err)
+		}
+		return nil
+	}
+	return iv
+}
+
+// 获取网页的 Instant View. 先使用 Bot, 获取失败时使用 userbot
+func FetchInstantView(ctx *ext.Context, url string) (*tcbdata.InstantView, error) {
+	wp, err := instantview.Fetch(ctx, ctx.Raw, url)
+	if err == nil {
+		return &tcbdata.InstantView{WebPage: wp}, nil
+	}
+	if errors.Is(err, instantview.ErrNoInstantView) || !config.Cfg.Telegram.Userbot.Enable {
+		return nil, err
+	}
+	uctx, err := userclient.ReadyCtx()
+	if err != nil {
+		return nil, err
+	}
+	wp, err = instantview.Fetch(ctx, uctx.Raw, url)
+	if err != nil {
+		return nil, err
+	}
+	return &tcbdata.InstantView{WebPage: wp, Userbot: true}, nil
+}
+
+// 下载 Instant View 中文件使用的客户端
+func instantViewClient(ctx *ext.Context, iv tcbdata.InstantView) (*tg.Client, error) {
+	if iv.Userbot {
+		uctx, err := userclient.ReadyCtx()
+		if err != nil {
+			return nil, err
+		}
+		return uctx.Raw, nil
+	}
+	return ctx.Raw, nil
+}
+
+// 创建一个 ivtask.Task 并添加到任务队列中, 以编辑消息的方式反馈结果
+func CreateAndAddInstantViewTaskWithEdit(ctx *ext.Context, userID int64, stor storage.Storage, dirPath string, iv tcbdata.InstantView, trackMsgID int) error {
+	logger := log.FromContext(ctx)
+	user, err := database.GetUserByChatID(ctx, userID)
+	if err != nil {
+		logger.Errorf("Failed to get user by chat ID: %s", err)
+		ctx.EditMessage(userID, &tg.MessagesEditMessageRequest{
+			ID:      trackMsgID,
+			Message: "获取用户失败: " + err.Error(),
+		})
+		return dispatcher.EndGroups
+	}
+	title := instantview.Convert(iv.WebPage).Title
+	if user.ApplyRule && user.Rules != nil {
+		// 文章的所有文件保存在同一目录, 规则按标题和网页地址匹配
+		matchedStorageName, matchedDirPath := ruleutil.ApplyRule(ctx, user.Rules, ruleutil.NewNameInput(title, iv.WebPage.URL))
+		dirPath = matchedDirPath.String()
+		if matchedStorageName.IsUsable() {
+			stor, err = storage.Manager.GetUserStorageByName(ctx, user.ChatID, matchedStorageName.String())
+			if err != nil {
+				logger.Errorf("Failed to get storage by user ID and name: %s", err)
+				ctx.EditMessage(userID, &tg.MessagesEditMessageRequest{
+					ID:      trackMsgID,
+					Message: "获取存储失败: " + err.Error(),
+				})
+				return dispatcher.EndGroups
+			}
+		}
+	}
+
+	injectCtx := tgutil.ExtWithContext(ctx.Context, ctx)
+	taskid := xid.New().String()
+	var board *dashboard.Board
+	progress := ivtask.NewProgressTrack(trackMsgID, userID)
+	if user.Dashboard {
+		board = dashboard.For(userID)
+		progress = nil
+	}
+	client, err := instantViewClient(ctx, iv)
+	if err != nil {
+		logger.Errorf("Failed to get client: %s", err)
+		ctx.EditMessage(userID, &tg.MessagesEditMessageRequest{
+			ID:      trackMsgID,
+			Message: "获取客户端失败: " + err.Error(),
+		})
+		return dispatcher.EndGroups
+	}
+	task, err := ivtask.NewTask(taskid, injectCtx, userID, iv.WebPage, client,
+		stor, stor.JoinStoragePath(dirPath), config.Cfg.Telegraph.EPUB, progress)
+	if err != nil {
+		logger.Errorf("create task failed: %s", err)
+		ctx.EditMessage(userID, &tg.MessagesEditMessageRequest{
+			ID:      trackMsgID,
+			Message: "创建任务失败: " + err.Error(),
+		})
+		return dispatcher.EndGroups
+	}
+	if board != nil {
+		board.Queue(ctx, taskid, task.Title())
+	}
+	if err := core.AddTask(injectCtx, task); err != nil {
+		logger.Errorf("add task failed: %s", err)
+		if board != nil {
+			board.Remove(taskid)
+		}
+		ctx.EditMessage(userID, &tg.MessagesEditMessageRequest{
+			ID:      trackMsgID,
+			Message: "添加任务失败: " + err.Error(),
+		})
+		return dispatcher.EndGroups
+	}
+	if board != nil {
+		ctx.DeleteMessages(userID, []int{trackMsgID})
+		return dispatcher.EndGroups
+	}
+	text, entities := msgelem.BuildTaskAddedEntities(ctx, task.Title(), core.GetLength(injectCtx))
+	ctx.EditMessage(userID, &tg.MessagesEditMessageRequest{
+		ID:       trackMsgID,
+		Message:  text,
+		Entities: entities,
+	})
+	return dispatcher.EndGroups
+}
@@ -4,13 +4,13 @@ package shortcut
 import (
 	"encoding/json"
 	"net/url"
+	"regexp"
 	"strings"
 
 	"github.com/celestix/gotgproto/dispatcher"
 	"github.com/celestix/gotgproto/ext"
 	"github.com/celestix/gotgproto/types"
 	"github.com/charmbracelet/log"
-	"github.com/gotd/td/telegram/downloader"
 	"github.com/gotd/td/tg"
 	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/mediautil"
 	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/msgelem"
@@ -20,13 +20,17 @@ import (
 	"github.com/krau/SaveAny-Bot/common/utils/tgutil"
 	"github.com/krau/SaveAny-Bot/common/utils/tphutil"
 	"github.com/krau/SaveAny-Bot/config"
+	"github.com/krau/SaveAny-Bot/core/httptask"
+	"github.com/krau/SaveAny-Bot/pkg/httpdl"
+	"github.com/krau/SaveAny-Bot/pkg/tcbdata"
 	"github.com/krau/SaveAny-Bot/pkg/telegraph"
 	"github.com/krau/SaveAny-Bot/pkg/tfile"
 )
 
-// 获取消息中的文件并回复等待消息, 返回等待消息, 获取到的文件
-func GetFileFromMessageWithReply(ctx *ext.Context, update *ext.Update, message *tg.Message, tfileopts ...tfile.TGFileOptions) (replied *types.Message,
-	file tfile.TGFileMessage, err error,
+// 获取消息中的文件并回复等待消息, 返回等待消息, 获取到的文件.
+// 付费媒体可能有多个文件, 开启 telegram.save_alternatives 时还包括视频的其他清晰度和封面
+func GetFilesFromMessageWithReply(ctx *ext.Context, update *ext.Update, message *tg.Message, tfileopts ...tfile.TGFileOptions) (replied *types.Message,
+	files []tfile.TGFileMessage, err error,
 ) {
 	logger := log.FromContext(ctx)
 	media := message.Media
@@ -43,24 +47,25 @@ func GetFileFromMessageWithReply(ctx *ext.Context, update *ext.Update, message *
 	}
 	options := []tfile.TGFileOptions{
 		tfile.WithMessage(message),
+		tfile.WithMessageGetter(ctx),
 	}
 	if len(tfileopts) > 0 {
 		options = append(options, tfileopts...)
 	} else {
 		options = append(options, tfile.WithNameIfEmpty(tgutil.GenFileNameFromMessage(*message)))
 	}
-	file, err = tfile.FromMediaMessage(media, ctx.Raw, message, options...)
+	files, err = tfile.FilesFromMediaMessage(media, ctx.Raw, message, options...)
 	if err != nil {
 		logger.Errorf("Failed to get file from media: %s", err)
 		ctx.Reply(update, ext.ReplyTextString("获取文件失败: "+err.Error()), nil)
 		return nil, nil, dispatcher.EndGroups
 	}
-	return replied, file, nil
+	return replied, files, nil
 }
 
 type EditMessageFunc func(text string, markup tg.ReplyMarkupClass)
 
-// 获取链接中的文件并回复等待消息
+// 获取链接中的文件并回复等待消息, 链接指向的消息都没有文件时返回空的 files
 func GetFilesFromUpdateLinkMessageWithReplyEdit(ctx *ext.Context, update *ext.Update) (replied *types.Message, files []tfile.TGFileMessage, editReplied EditMessageFunc, err error) {
 	logger := log.FromContext(ctx)
 	msgLinks := re.TgMessageLinkRegexp.FindAllString(update.EffectiveMessage.GetMessage(), -1)
@@ -83,8 +88,25 @@ func GetFilesFromUpdateLinkMessageWithReplyEdit(ctx *ext.Context, update *ext.Up
 		}
 	}
 
-	files = make([]tfile.TGFileMessage, 0, len(msgLinks))
-	addFile := func(client downloader.Client, msg *tg.Message) {
+	tctx := ctx
+	if uctx, err := uc.ReadyCtx(); err == nil {
+		tctx = uctx
+	}
+	files, err = GetFilesFromLinks(tctx, msgLinks)
+	if err != nil {
+		editReplied(linkAccessErrorText(err), nil)
+		return nil, nil, nil, dispatcher.EndGroups
+	}
+	// 没有文件时由调用方决定是否保存消息文本
+	return replied, files, editReplied, nil
+}
+
+// 获取链接指向的消息中的文件, 相册消息获取整个相册, 链接带有 single 参数时除外.
+// 所有链接指向的消息都获取失败时返回最后一个错误
+func GetFilesFromLinks(tctx *ext.Context, links []string) ([]tfile.TGFileMessage, error) {
+	logger := log.FromContext(tctx)
+	files := make([]tfile.TGFileMessage, 0, len(links))
+	addFile := func(msg *tg.Message) {
 		if msg == nil || msg.Media == nil {
 			logger.Warn("message is nil, skipping")
 			return
@@ -94,54 +116,67 @@ func GetFilesFromUpdateLinkMessageWithReplyEdit(ctx *ext.Context, update *ext.Up
 			logger.Debugf("message %d has no media", msg.GetID())
 			return
 		}
-		file, err := tfile.FromMediaMessage(media, client, msg, tfile.WithNameIfEmpty(tgutil.GenFileNameFromMessage(*msg)))
+		msgFiles, err := tfile.FilesFromMediaMessage(media, tctx.Raw, msg,
+			tfile.WithNameIfEmpty(tgutil.GenFileNameFromMessage(*msg)),
+			tfile.WithMessageGetter(tctx),
+		)
 		if err != nil {
 			logger.Errorf("failed to create file from media: %s", err)
 			return
 		}
-		files = append(files, file)
+		files = append(files, msgFiles...)
 	}
 
-	tctx := ctx
-	if config.Cfg.Telegram.Userbot.Enable {
-		tctx = uc.GetCtx()
-	}
-
-	for _, link := range msgLinks {
+	var (
+		lastErr error
+		fetched int
+	)
+	for _, link := range links {
 		linkUrl, err := url.Parse(link)
 		if err != nil {
 			logger.Errorf("failed to parse message link %s: %s", link, err)
+			lastErr = err
 			continue
 		}
 		chatId, msgId, err := tgutil.ParseMessageLink(tctx, link)
 		if err != nil {
 			logger.Errorf("failed to parse message link %s: %s", link, err)
+			lastErr = err
 			continue
 		}
 		msg, err := tgutil.GetMessageByID(tctx, chatId, msgId)
 		if err != nil {
 			logger.Errorf("failed to get message by ID: %s", err)
+			lastErr = err
 			continue
 		}
+		fetched++
 		groupID, isGroup := msg.GetGroupedID()
 		if isGroup && groupID != 0 && !linkUrl.Query().Has("single") {
-			gmsgs, err := tgutil.GetGroupedMessages(ctx, chatId, msg)
+			gmsgs, err := tgutil.GetGroupedMessages(tctx, chatId, msg)
 			if err != nil {
 				logger.Errorf("failed to get grouped messages: %s", err)
 			} else {
 				for _, gmsg := range gmsgs {
-					addFile(tctx.Raw, gmsg)
+					addFile(gmsg)
 				}
 			}
 		} else {
-			addFile(tctx.Raw, msg)
+			addFile(msg)
 		}
 	}
-	if len(files) == 0 {
-		editReplied("没有找到可保存的文件", nil)
-		return nil, nil, nil, dispatcher.EndGroups
+	if fetched == 0 && lastErr != nil {
+		return nil, lastErr
 	}
-	return replied, files, editReplied, nil
+	return files, nil
+}
+
+// 无法获取链接指向的消息时的提示, 私有聊天需要先通过邀请链接让 userbot 加入
+func linkAccessErrorText(err error) string {
+	if !config.Cfg.Telegram.Userbot.Enable {
+		return "获取消息失败: " + err.Error() + "\n\nBot 可能不在该聊天中, 启用 userbot 后可以通过邀请链接访问私有聊天"
+	}
+	return "获取消息失败: " + err.Error() + "\n\n如果这是 userbot 未加入的私有聊天, 请将聊天的邀请链接和消息链接放在同一条消息中发送, 确认后 userbot 会加入聊天并获取消息"
 }
 
 func GetCallbackDataWithAnswer[DataType any](ctx *ext.Context, update *ext.Update, dataid string) (DataType, error) {
@@ -213,7 +248,8 @@ func GetTphPicsFromMessageWithReply(ctx *ext.Context, update *ext.Update) (*type
 			}
 		}
 	}
-	if len(imgs) == 0 {
+	// 保存完整文章时允许没有图片
+	if len(imgs) == 0 && !config.Cfg.Telegraph.Article {
 		logger.Warn("No images found in telegraph page")
 		ctx.Reply(update, ext.ReplyTextString("在 telegraph 页面中未找到图片"), nil)
 		return nil, nil, dispatcher.EndGroups
@@ -224,3 +260,54 @@ func GetTphPicsFromMessageWithReply(ctx *ext.Context, update *ext.Update) (*type
 		Page:   page,
 	}, nil
 }
+
+var httpHeaderKeyRegexp = regexp.MustCompile(`^[A-Za-z0-9-]+$`)
+
+// 解析消息中的第一个链接和之后形如 "Key: Value" 的请求头行, 消息中的请求头覆盖配置中的请求头
+func parseHTTPRequest(text string) (httpdl.Request, bool) {
+	rawURL := re.HttpUrlRegexp.FindString(text)
+	if rawURL == "" {
+		return httpdl.Request{}, false
+	}
+	headers := config.Cfg.HTTP.HeadersFor(rawURL)
+	for _, line := range strings.Split(text, "\n") {
+		key, value, ok := strings.Cut(line, ":")
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		// 跳过链接所在的行
+		if !ok || value == "" || strings.HasPrefix(value, "//") || !httpHeaderKeyRegexp.MatchString(key) {
+			continue
+		}
+		headers[key] = value
+	}
+	return httpdl.Request{URL: rawURL, Headers: headers}, true
+}
+
+// 获取消息中链接指向的文件信息并回复等待消息
+func GetHTTPFileFromMessageWithReply(ctx *ext.Context, update *ext.Update) (*types.Message, *tcbdata.HTTPFile, error) {
+	logger := log.FromContext(ctx)
+	req, ok := parseHTTPRequest(update.EffectiveMessage.GetMessage())
+	if !ok {
+		return nil, nil, dispatcher.ContinueGroups
+	}
+	msg, err := ctx.Reply(update, ext.ReplyTextString("正在获取文件信息..."), nil)
+	if err != nil {
+		logger.Errorf("Failed to reply to update: %s", err)
+		return nil, nil, dispatcher.EndGroups
+	}
+	info, err := httptask.NewClient().Probe(ctx, req)
+	if err != nil {
+		logger.Errorf("Failed to probe url %s: %s", req.URL, err)
+		ctx.EditMessage(update.GetUserChat().GetID(), &tg.MessagesEditMessageRequest{
+			ID:      msg.ID,
+			Message: "获取文件信息失败: " + err.Error(),
+		})
+		return nil, nil, dispatcher.EndGroups
+	}
+	return msg, &tcbdata.HTTPFile{
+		URL:          req.URL,
+		Headers:      req.Headers,
+		FileName:     info.Name,
+		Size:         info.Size,
+		AcceptRanges: info.AcceptRanges,
+	}, nil
+}
@@ -0,0 +1,341 @@
+package shortcut
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"time"
+
+	"github.com/celestix/gotgproto/dispatcher"
+	"github.com/celestix/gotgproto/ext"
+	"github.com/charmbracelet/log"
+	"github.com/gotd/td/tg"
+	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/dashboard"
+	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/msgelem"
+	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/re"
+	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/ruleutil"
+	userclient "github.com/krau/SaveAny-Bot/client/user"
+	"github.com/krau/SaveAny-Bot/common/utils/tgutil"
+	"github.com/krau/SaveAny-Bot/core"
+	"github.com/krau/SaveAny-Bot/core/notetask"
+	"github.com/krau/SaveAny-Bot/database"
+	"github.com/krau/SaveAny-Bot/pkg/mdnote"
+	"github.com/krau/SaveAny-Bot/pkg/tcbdata"
+	"github.com/krau/SaveAny-Bot/storage"
+	"github.com/rs/xid"
+)
+
+// 一个对话中最多保存的消息数
+const maxNoteThreadMessages = 1000
+
+// 笔记中媒体的说明
+func noteMediaLabel(media tg.MessageMediaClass) string {
+	switch m := media.(type) {
+	case nil, *tg.MessageMediaEmpty, *tg.MessageMediaWebPage:
+		return ""
+	case *tg.MessageMediaPhoto:
+		return "照片"
+	case *tg.MessageMediaDocument:
+		doc, ok := m.Document.AsNotEmpty()
+		if !ok {
+			return "文件"
+		}
+		for _, attr := range doc.Attributes {
+			switch a := attr.(type) {
+			case *tg.DocumentAttributeSticker:
+				return "贴纸"
+			case *tg.DocumentAttributeAnimated:
+				return "GIF"
+			case *tg.DocumentAttributeVideo:
+				return "视频"
+			case *tg.DocumentAttributeAudio:
+				if a.Voice {
+					return "语音"
+				}
+				return "音频"
+			}
+		}
+		for _, attr := range doc.Attributes {
+			if a, ok := attr.(*tg.DocumentAttributeFilename); ok {
+				return "文件: " + a.FileName
+			}
+		}
+		return "文件"
+	case *tg.MessageMediaGeo, *tg.MessageMediaGeoLive, *tg.MessageMediaVenue:
+		return "位置"
+	case *tg.MessageMediaContact:
+		return "联系人"
+	case *tg.MessageMediaPoll:
+		return "投票"
+	}
+	return "媒体"
+}
+
+func noteMessage(msg *tg.Message, names *tgutil.PeerNames, peer tg.PeerClass) mdnote.Message {
+	m := mdnote.Message{
+		ID:       msg.ID,
+		Date:     time.Unix(int64(msg.Date), 0),
+		Text:     msg.Message,
+		Entities: msg.Entities,
+		Media:    noteMediaLabel(msg.Media),
+	}
+	if peer != nil {
+		m.Link = names.Link(peer, msg.ID)
+	}
+	switch {
+	case msg.PostAuthor != "":
+		m.Author = msg.PostAuthor
+	case msg.FromID != nil:
+		m.Author = names.Name(msg.FromID)
+	case msg.Out:
+		m.Author = "Bot"
+	case msg.PeerID != nil:
+		m.Author = names.Name(msg.PeerID)
+	}
+	return m
+}
+
+// 消息有回复链或评论, 可以保存整个对话
+func hasNoteThread(msg *tg.Message) bool {
+	if header, ok := msg.ReplyTo.(*tg.MessageReplyHeader); ok && header.ReplyToMsgID != 0 && header.ReplyToPeerID == nil {
+		return true
+	}
+	replies, ok := msg.GetReplies()
+	return ok && replies.Replies > 0
+}
+
+// 由发送给 Bot 的消息生成笔记. 转发自频道的消息记录来源, 以便保存评论
+func NoteFromUpdate(ctx *ext.Context, update *ext.Update) *tcbdata.Note {
+	msg := update.EffectiveMessage.Message
+	names := tgutil.NewPeerNames()
+	names.AddEntities(update.Entities)
+	m := noteMessage(msg, names, nil)
+	userID := update.GetUserChat().GetID()
+	note := &tcbdata.Note{
+		Content:   &mdnote.Note{Messages: []mdnote.Message{m}},
+		ChatID:    userID,
+		MessageID: msg.ID,
+		HasThread: hasNoteThread(msg),
+	}
+	fwd, ok := msg.GetFwdFrom()
+	if !ok {
+		note.Content.Chat = names.Name(&tg.PeerUser{UserID: userID})
+		return note
+	}
+	// 转发的消息在原聊天中才有回复和评论
+	note.ChatID, note.MessageID, note.HasThread = 0, 0, false
+	m.Date = time.Unix(int64(fwd.Date), 0)
+	m.Author = fwd.PostAuthor
+	switch {
+	case fwd.FromID != nil:
+		note.Content.Chat = names.Name(fwd.FromID)
+		if m.Author == "" {
+			m.Author = note.Content.Chat
+		}
+		if ch, ok := fwd.FromID.(*tg.PeerChannel); ok && fwd.ChannelPost != 0 {
+			m.Link = names.Link(ch, fwd.ChannelPost)
+			note.ChatID, note.MessageID, note.HasThread = ch.ChannelID, fwd.ChannelPost, true
+			// Bot 无法获取评论, 使用 userbot 并提前解析频道
+			if uctx, err := userclient.ReadyCtx(); err == nil {
+				note.Userbot = true
+				if c, ok := names.Channels[ch.ChannelID]; ok && c.Username != "" {
+					if _, err := tgutil.ParseChatID(uctx, c.Username); err != nil {
+						log.FromContext(ctx).Debugf("Failed to resolve channel %s: %s", c.Username, err)
+					}
+				}
+			}
+		}
+	case fwd.FromName != "":
+		note.Content.Chat = fwd.FromName
+		m.Author = fwd.FromName
+	}
+	note.Content.Messages[0] = m
+	return note
+}
+
+// 获取消息链接指向的第一条文本消息并生成笔记
+func GetNoteFromLinks(ctx *ext.Context, text string) (*tcbdata.Note, error) {
+	tctx := ctx
+	if uctx, err := userclient.ReadyCtx(); err == nil {
+		tctx = uctx
+	}
+	var lastErr error
+	for _, link := range re.TgMessageLinkRegexp.FindAllString(text, -1) {
+		chatID, msgID, err := tgutil.ParseMessageLink(tctx, link)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		msgs, names, err := tgutil.GetMessagesWithPeers(tctx, chatID, []int{msgID})
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if len(msgs) == 0 || msgs[0].Message == "" {
+			continue
+		}
+		msg := msgs[0]
+		return &tcbdata.Note{
+			Content: &mdnote.Note{
+				Chat:     names.Name(msg.PeerID),
+				Messages: []mdnote.Message{noteMessage(msg, names, msg.PeerID)},
+			},
+			ChatID:    chatID,
+			MessageID: msgID,
+			HasThread: hasNoteThread(msg),
+			Userbot:   tctx != ctx,
+		}, nil
+	}
+	if lastErr != nil {
+		return nil, lastErr
+	}
+	return nil, errors.New("no text message found")
+}
+
+// 获取笔记所在的整个对话: 先沿回复链向上找到最早的消息, 再加上消息的评论或回复
+func GetNoteThread(ctx *ext.Context, note tcbdata.Note) (*mdnote.Note, error) {
+	if note.ChatID == 0 {
+		return nil, errors.New("source chat of the message is unknown")
+	}
+	tctx := ctx
+	if note.Userbot {
+		uctx, err := userclient.ReadyCtx()
+		if err != nil {
+			return nil, err
+		}
+		tctx = uctx
+	}
+	msgs, names, err := tgutil.GetMessagesWithPeers(tctx, note.ChatID, []int{note.MessageID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get message: %w", err)
+	}
+	if len(msgs) == 0 {
+		return nil, fmt.Errorf("message %d not found", note.MessageID)
+	}
+	root := msgs[0]
+	peer := root.PeerID
+	if _, ok := peer.(*tg.PeerUser); ok {
+		// 与 Bot 的私聊中的消息没有链接
+		peer = nil
+	}
+	thread := []mdnote.Message{noteMessage(root, names, peer)}
+	seen := map[int]bool{root.ID: true}
+	cur := root
+	for len(thread) < maxNoteThreadMessages {
+		header, ok := cur.ReplyTo.(*tg.MessageReplyHeader)
+		if !ok || header.ReplyToMsgID == 0 || header.ReplyToPeerID != nil || seen[header.ReplyToMsgID] {
+			break
+		}
+		parents, pnames, err := tgutil.GetMessagesWithPeers(tctx, note.ChatID, []int{header.ReplyToMsgID})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get replied message: %w", err)
+		}
+		if len(parents) == 0 {
+			break
+		}
+		cur = parents[0]
+		seen[cur.ID] = true
+		thread = append([]mdnote.Message{noteMessage(cur, pnames, peer)}, thread...)
+	}
+
+	if replies, ok := root.GetReplies(); ok && replies.Replies > 0 && len(thread) < maxNoteThreadMessages {
+		comments, err := getNoteReplies(tctx, note.ChatID, root.ID, maxNoteThreadMessages-len(thread))
+		if err != nil {
+			return nil, fmt.Errorf("failed to get replies: %w", err)
+		}
+		thread = append(thread, comments...)
+	}
+	return &mdnote.Note{
+		Chat:     note.Content.Chat,
+		Thread:   true,
+		Messages: thread,
+	}, nil
+}
+
+// 获取消息的评论或回复, 按时间排序
+func getNoteReplies(tctx *ext.Context, chatID int64, msgID int, limit int) ([]mdnote.Message, error) {
+	msgs, names, err := tgutil.GetReplies(tctx, chatID, msgID, limit)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]mdnote.Message, 0, len(msgs))
+	for _, msg := range msgs {
+		// 评论在频道的讨论组中, 链接指向讨论组中的消息
+		result = append(result, noteMessage(msg, names, msg.PeerID))
+	}
+	slices.SortStableFunc(result, func(a, b mdnote.Message) int {
+		return a.Date.Compare(b.Date)
+	})
+	return result, nil
+}
+
+// 创建一个 notetask.Task 并添加到任务队列中, 以编辑消息的方式反馈结果
+func CreateAndAddNoteTaskWithEdit(ctx *ext.Context, userID int64, stor storage.Storage, dirPath string, note tcbdata.Note, trackMsgID int) error {
+	logger := log.FromContext(ctx)
+	user, err := database.GetUserByChatID(ctx, userID)
+	if err != nil {
+		logger.Errorf("Failed to get user by chat ID: %s", err)
+		ctx.EditMessage(userID, &tg.MessagesEditMessageRequest{
+			ID:      trackMsgID,
+			Message: "获取用户失败: " + err.Error(),
+		})
+		return dispatcher.EndGroups
+	}
+	fileName := note.Content.FileName()
+	if user.ApplyRule && user.Rules != nil {
+		var text string
+		if len(note.Content.Messages) > 0 {
+			text = note.Content.Messages[0].Text
+		}
+		matchedStorageName, matchedDirPath := ruleutil.ApplyRule(ctx, user.Rules, ruleutil.NewNameInput(fileName, text))
+		dirPath = matchedDirPath.String()
+		if matchedStorageName.IsUsable() {
+			stor, err = storage.Manager.GetUserStorageByName(ctx, user.ChatID, matchedStorageName.String())
+			if err != nil {
+				logger.Errorf("Failed to get storage by user ID and name: %s", err)
+				ctx.EditMessage(userID, &tg.MessagesEditMessageRequest{
+					ID:      trackMsgID,
+					Message: "获取存储失败: " + err.Error(),
+				})
+				return dispatcher.EndGroups
+			}
+		}
+	}
+	storagePath := stor.JoinStoragePath(path.Join(dirPath, fileName))
+
+	injectCtx := tgutil.ExtWithContext(ctx.Context, ctx)
+	taskid := xid.New().String()
+	var board *dashboard.Board
+	progress := notetask.NewProgressTrack(trackMsgID, userID)
+	if user.Dashboard {
+		board = dashboard.For(userID)
+		progress = nil
+	}
+	task := notetask.NewTask(taskid, injectCtx, userID, note.Content.Title(), note.Content.Render(), stor, storagePath, progress)
+	if board != nil {
+		board.Queue(ctx, taskid, fileName)
+	}
+	if err := core.AddTask(injectCtx, task); err != nil {
+		logger.Errorf("add task failed: %s", err)
+		if board != nil {
+			board.Remove(taskid)
+		}
+		ctx.EditMessage(userID, &tg.MessagesEditMessageRequest{
+			ID:      trackMsgID,
+			Message: "添加任务失败: " + err.Error(),
+		})
+		return dispatcher.EndGroups
+	}
+	if board != nil {
+		ctx.DeleteMessages(userID, []int{trackMsgID})
+		return dispatcher.EndGroups
+	}
+	text, entities := msgelem.BuildTaskAddedEntities(ctx, fileName, core.GetLength(injectCtx))
+	ctx.EditMessage(userID, &tg.MessagesEditMessageRequest{
+		ID:       trackMsgID,
+		Message:  text,
+		Entities: entities,
+	})
+	return dispatcher.EndGroups
+}
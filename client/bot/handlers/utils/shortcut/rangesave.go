@@ -0,0 +1,291 @@
+package shortcut
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/celestix/gotgproto/dispatcher"
+	"github.com/celestix/gotgproto/ext"
+	"github.com/charmbracelet/log"
+	"github.com/gotd/td/tg"
+	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/mediautil"
+	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/msgelem"
+	"github.com/krau/SaveAny-Bot/common/utils/tgutil"
+	"github.com/krau/SaveAny-Bot/pkg/tcbdata"
+	"github.com/krau/SaveAny-Bot/pkg/tfile"
+	"github.com/krau/SaveAny-Bot/storage"
+	"github.com/rs/xid"
+)
+
+const (
+	// 扫描消息范围时, 每找到这么多文件就创建一个批量任务
+	rangeSaveChunkSize = 200
+	// 扫描进度消息的最小更新间隔
+	scanProgressInterval = 3 * time.Second
+)
+
+// 扫描进度消息上停止按钮的回调前缀, 后接扫描 ID
+const StopScanCallbackPrefix = "save_scan_stop:"
+
+type rangeScan struct {
+	userID int64
+	cancel context.CancelFunc
+}
+
+var scans sync.Map // scan id -> *rangeScan
+
+// 停止用户正在进行的消息范围扫描, 已创建的任务不受影响, 已找到但未创建任务的文件仍会添加. 扫描不存在时返回 false
+func StopRangeScan(userID int64, scanID string) bool {
+	v, ok := scans.Load(scanID)
+	if !ok || v.(*rangeScan).userID != userID {
+		return false
+	}
+	v.(*rangeScan).cancel()
+	return true
+}
+
+// 在后台分页扫描消息范围, 边扫描边按批创建任务, 以编辑 trackMsgID 的方式汇报扫描进度
+func SaveMessageRangeWithEdit(ctx *ext.Context, userID int64, stor storage.Storage, dirPath string, r tcbdata.SaveRange, trackMsgID int) error {
+	var filter *regexp.Regexp
+	if r.Filter != "" {
+		var err error
+		filter, err = regexp.Compile(r.Filter)
+		if err != nil {
+			ctx.EditMessage(userID, &tg.MessagesEditMessageRequest{
+				ID:      trackMsgID,
+				Message: "无效的正则表达式: " + err.Error(),
+			})
+			return dispatcher.EndGroups
+		}
+	}
+	scanID := xid.New().String()
+	sctx, cancel := context.WithCancel(ctx.Context)
+	scans.Store(scanID, &rangeScan{userID: userID, cancel: cancel})
+	go func() {
+		defer scans.Delete(scanID)
+		defer cancel()
+		s := &rangeScanner{
+			ctx:        ctx,
+			userID:     userID,
+			stor:       stor,
+			dirPath:    dirPath,
+			filter:     filter,
+			scanID:     scanID,
+			trackMsgID: trackMsgID,
+		}
+		s.run(sctx, r)
+	}()
+	return dispatcher.EndGroups
+}
+
+type rangeScanner struct {
+	ctx        *ext.Context
+	userID     int64
+	stor       storage.Storage
+	dirPath    string
+	filter     *regexp.Regexp
+	scanID     string
+	trackMsgID int
+
+	pending []tfile.TGFileMessage
+	found   int
+	batches int
+}
+
+func (s *rangeScanner) run(sctx context.Context, r tcbdata.SaveRange) {
+	logger := log.FromContext(s.ctx)
+	var (
+		scanErr  error
+		lastEdit time.Time
+		scanned  int
+		total    = r.EndID - r.StartID + 1
+	)
+	for page, err := range tgutil.IterMessagePages(sctx, s.ctx, r.ChatID, r.StartID, r.EndID) {
+		if err != nil {
+			scanErr = err
+			break
+		}
+		scanned = page.Scanned
+		for _, msg := range page.Messages {
+			if files := s.filesFromMessage(msg); len(files) > 0 {
+				s.pending = append(s.pending, files...)
+				s.found += len(files)
+			}
+		}
+		if len(s.pending) >= rangeSaveChunkSize {
+			s.flush(false)
+		}
+		if time.Since(lastEdit) >= scanProgressInterval {
+			lastEdit = time.Now()
+			s.editProgress(scanned, total)
+		}
+	}
+	// 扫描停止或出错时, 已找到的文件仍然添加
+	s.flush(true)
+	if errors.Is(scanErr, context.Canceled) {
+		logger.Infof("Message range scan %s stopped by user", s.scanID)
+		s.editResult("扫描已停止", scanned, total, nil)
+		return
+	}
+	if scanErr != nil {
+		logger.Errorf("Failed to scan message range: %s", scanErr)
+		s.editResult("扫描失败", scanned, total, scanErr)
+		return
+	}
+	if s.found == 0 {
+		s.ctx.EditMessage(s.userID, &tg.MessagesEditMessageRequest{
+			ID:      s.trackMsgID,
+			Message: "没有找到指定范围内的可保存消息",
+		})
+		return
+	}
+	s.editResult("扫描完成", scanned, total, nil)
+}
+
+func (s *rangeScanner) filesFromMessage(msg *tg.Message) []tfile.TGFileMessage {
+	media, ok := msg.GetMedia()
+	if !ok || !mediautil.IsSupported(media) {
+		return nil
+	}
+	if s.filter != nil {
+		fn, _ := tgutil.GetMediaFileName(media)
+		if !s.filter.MatchString(msg.GetMessage() + " " + fn) {
+			return nil
+		}
+	}
+	files, err := tfile.FilesFromMediaMessage(media, s.ctx.Raw, msg,
+		tfile.WithNameIfEmpty(tgutil.GenFileNameFromMessage(*msg)),
+		tfile.WithMessageGetter(s.ctx),
+	)
+	if err != nil {
+		log.FromContext(s.ctx).Errorf("获取文件失败: %s", err)
+		return nil
+	}
+	return files
+}
+
+// 将已找到的文件创建为批量任务. 非最后一批时, 末尾的相册留到下一批, 避免同一相册被拆分
+func (s *rangeScanner) flush(final bool) {
+	files := s.pending
+	if !final {
+		files, s.pending = splitTrailingAlbum(s.pending)
+	} else {
+		s.pending = nil
+	}
+	if len(files) == 0 {
+		return
+	}
+	msg, err := s.ctx.SendMessage(s.userID, &tg.MessagesSendMessageRequest{Message: "正在创建批量任务..."})
+	if err != nil {
+		log.FromContext(s.ctx).Errorf("Failed to send message: %s", err)
+		return
+	}
+	CreateAndAddBatchTGFileTaskWithEdit(s.ctx, s.userID, s.stor, s.dirPath, files, msg.ID)
+	s.batches++
+}
+
+// 返回可以立即添加的文件和属于末尾相册的文件. 整批都是同一个相册时全部立即添加
+func splitTrailingAlbum(files []tfile.TGFileMessage) ([]tfile.TGFileMessage, []tfile.TGFileMessage) {
+	if len(files) == 0 {
+		return nil, nil
+	}
+	groupOf := func(file tfile.TGFileMessage) int64 {
+		id, _ := file.Message().GetGroupedID()
+		return id
+	}
+	last := groupOf(files[len(files)-1])
+	if last == 0 {
+		return files, nil
+	}
+	i := len(files)
+	for i > 0 && groupOf(files[i-1]) == last {
+		i--
+	}
+	if i == 0 {
+		return files, nil
+	}
+	return files[:i], append([]tfile.TGFileMessage(nil), files[i:]...)
+}
+
+// 分批添加任务时的反馈消息: 第一批使用 trackMsgID, 之后每批发送新消息
+type batchTracker struct {
+	ctx        *ext.Context
+	userID     int64
+	trackMsgID int
+	batches    int
+}
+
+// 下一批任务使用的反馈消息 ID
+func (b *batchTracker) next() (int, error) {
+	b.batches++
+	if b.batches == 1 {
+		return b.trackMsgID, nil
+	}
+	msg, err := b.ctx.SendMessage(b.userID, &tg.MessagesSendMessageRequest{Message: "正在创建批量任务..."})
+	if err != nil {
+		return 0, err
+	}
+	return msg.ID, nil
+}
+
+// 还没有添加任务时编辑 trackMsgID, 否则发送新消息以免覆盖任务进度
+func (b *batchTracker) notify(text string) {
+	if b.batches == 0 {
+		b.ctx.EditMessage(b.userID, &tg.MessagesEditMessageRequest{
+			ID:      b.trackMsgID,
+			Message: text,
+		})
+		return
+	}
+	b.ctx.SendMessage(b.userID, &tg.MessagesSendMessageRequest{Message: text})
+}
+
+func (s *rangeScanner) editProgress(scanned, total int) {
+	template := msgelem.NewProcessingTemplate("正在扫描消息", "")
+	template.AddProgressBar("📊", "扫描进度", int64(scanned), int64(total), 12)
+	template.AddItem("📄", "找到文件", fmt.Sprintf("%d 个", s.found), msgelem.ItemTypeText)
+	template.AddItem("📦", "已添加任务", fmt.Sprintf("%d 个", s.batches), msgelem.ItemTypeText)
+	text, entities := template.BuildFormattedMessage()
+	markup := &tg.ReplyInlineMarkup{
+		Rows: []tg.KeyboardButtonRow{
+			{
+				Buttons: []tg.KeyboardButtonClass{
+					&tg.KeyboardButtonCallback{
+						Text: "停止扫描",
+						Data: fmt.Appendf(nil, "%s%s", StopScanCallbackPrefix, s.scanID),
+					},
+				},
+			},
+		},
+	}
+	peer := &tg.InputPeerUser{UserID: s.userID}
+	if err := msgelem.EditWithFormattedText(s.ctx, peer, s.trackMsgID, text, entities, markup); err != nil &&
+		!strings.Contains(err.Error(), "MESSAGE_NOT_MODIFIED") {
+		log.FromContext(s.ctx).Warn("Failed to edit scan progress message", "error", err)
+	}
+}
+
+func (s *rangeScanner) editResult(title string, scanned, total int, scanErr error) {
+	var template *msgelem.MessageTemplate
+	if scanErr != nil {
+		template = msgelem.NewErrorTemplate(title, "")
+	} else {
+		template = msgelem.NewSuccessTemplate(title, "")
+	}
+	template.AddItem("🔍", "已扫描", fmt.Sprintf("%d/%d 条消息", scanned, total), msgelem.ItemTypeText)
+	template.AddItem("📄", "找到文件", fmt.Sprintf("%d 个", s.found), msgelem.ItemTypeText)
+	template.AddItem("📦", "已添加任务", fmt.Sprintf("%d 个", s.batches), msgelem.ItemTypeText)
+	if scanErr != nil {
+		template.AddItem("❗", "错误信息", scanErr.Error(), msgelem.ItemTypeText)
+	}
+	text, entities := template.BuildFormattedMessage()
+	peer := &tg.InputPeerUser{UserID: s.userID}
+	if err := msgelem.EditWithFormattedText(s.ctx, peer, s.trackMsgID, text, entities, nil); err != nil {
+		log.FromContext(s.ctx).Warn("Failed to edit scan result message", "error", err)
+	}
+}
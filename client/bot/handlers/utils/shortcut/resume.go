@@ -0,0 +1,521 @@
+package shortcut
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/celestix/gotgproto/ext"
+	"github.com/charmbracelet/log"
+	"github.com/duke-git/lancet/v2/slice"
+	"github.com/gotd/td/telegram/downloader"
+	"github.com/gotd/td/tg"
+	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/dashboard"
+	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/msgelem"
+	userclient "github.com/krau/SaveAny-Bot/client/user"
+	"github.com/krau/SaveAny-Bot/common/utils/tgutil"
+	"github.com/krau/SaveAny-Bot/common/utils/tphutil"
+	"github.com/krau/SaveAny-Bot/core"
+	"github.com/krau/SaveAny-Bot/core/archivetask"
+	"github.com/krau/SaveAny-Bot/core/batchtftask"
+	"github.com/krau/SaveAny-Bot/core/httptask"
+	"github.com/krau/SaveAny-Bot/core/ivtask"
+	"github.com/krau/SaveAny-Bot/core/notetask"
+	"github.com/krau/SaveAny-Bot/core/stickertask"
+	"github.com/krau/SaveAny-Bot/core/tftask"
+	"github.com/krau/SaveAny-Bot/core/torrenttask"
+	"github.com/krau/SaveAny-Bot/core/tphtask"
+	"github.com/krau/SaveAny-Bot/core/ytdlptask"
+	"github.com/krau/SaveAny-Bot/database"
+	"github.com/krau/SaveAny-Bot/pkg/httpdl"
+	"github.com/krau/SaveAny-Bot/pkg/stickerset"
+	"github.com/krau/SaveAny-Bot/pkg/tcbdata"
+	"github.com/krau/SaveAny-Bot/pkg/tfile"
+	"github.com/krau/SaveAny-Bot/storage"
+)
+
+// 恢复上次关闭时保存的任务, 需要在 core.Run 之后调用
+func ResumeCheckpoints(ctx *ext.Context) {
+	logger := log.FromContext(ctx)
+	checkpoints, err := database.GetTaskCheckpoints(ctx)
+	if err != nil {
+		logger.Errorf("Failed to load task checkpoints: %s", err)
+		return
+	}
+	if len(checkpoints) == 0 {
+		return
+	}
+	logger.Infof("Resuming %d tasks from last shutdown", len(checkpoints))
+	for _, cp := range checkpoints {
+		if err := resumeCheckpoint(ctx, cp); err != nil {
+			logger.Errorf("Failed to resume task %s: %s", cp.TaskID, err)
+			ctx.SendMessage(cp.ChatID, &tg.MessagesSendMessageRequest{
+				Message: "Bot 重启后恢复任务失败: " + err.Error(),
+			})
+		}
+		if err := database.DeleteTaskCheckpoint(ctx, cp.ID); err != nil {
+			logger.Errorf("Failed to delete task checkpoint %d: %s", cp.ID, err)
+		}
+	}
+}
+
+func resumeCheckpoint(ctx *ext.Context, cp database.TaskCheckpoint) error {
+	userID := cp.ChatID
+	injectCtx := tgutil.ExtWithContext(ctx.Context, ctx)
+	switch cp.Kind {
+	case tftask.CheckpointKind:
+		var data tftask.CheckpointData
+		if err := json.Unmarshal([]byte(cp.Data), &data); err != nil {
+			return fmt.Errorf("invalid checkpoint data: %w", err)
+		}
+		stor, err := storage.Manager.GetUserStorageByName(ctx, userID, data.StorageName)
+		if err != nil {
+			return fmt.Errorf("failed to get storage %s: %w", data.StorageName, err)
+		}
+		getFiles := getResumeFiles
+		if data.Story {
+			getFiles = getResumeStoryFiles
+		}
+		ref := resumeRef{MessageID: data.MessageID, LocationID: data.LocationID, FileName: data.FileName}
+		files, err := getFiles(ctx, data.ChatID, []resumeRef{ref})
+		if err != nil {
+			return err
+		}
+		file, ok := files[ref]
+		if !ok {
+			return fmt.Errorf("message %d not found", data.MessageID)
+		}
+		display, err := newResumeDisplay(ctx, cp, data.ProgressMessageID)
+		if err != nil {
+			return err
+		}
+		progress := resumeProgress(display, func(msgID int, chatID int64) tftask.ProgressTracker {
+			return tftask.NewProgressTrack(msgID, chatID)
+		})
+		task, err := tftask.NewTGFileTask(cp.TaskID, injectCtx, userID, file, stor, data.Path, progress)
+		if err != nil {
+			return err
+		}
+		if data.CustomName != "" {
+			task.SetCustomName(data.CustomName)
+		}
+		return display.add(injectCtx, task, file.Name(), "")
+	case batchtftask.CheckpointKind:
+		var data batchtftask.CheckpointData
+		if err := json.Unmarshal([]byte(cp.Data), &data); err != nil {
+			return fmt.Errorf("invalid checkpoint data: %w", err)
+		}
+		// 故事和消息的 ID 不同, 需要分开获取
+		type source struct {
+			chatID int64
+			story  bool
+		}
+		byChat := make(map[source][]batchtftask.ElementCheckpoint)
+		for _, e := range data.Elements {
+			src := source{chatID: e.ChatID, story: e.Story}
+			byChat[src] = append(byChat[src], e)
+		}
+		elems := make([]batchtftask.TaskElement, 0, len(data.Elements))
+		for src, ecps := range byChat {
+			refs := make([]resumeRef, 0, len(ecps))
+			for _, e := range ecps {
+				refs = append(refs, resumeRef{MessageID: e.MessageID, LocationID: e.LocationID, FileName: e.FileName})
+			}
+			getFiles := getResumeFiles
+			if src.story {
+				getFiles = getResumeStoryFiles
+			}
+			files, err := getFiles(ctx, src.chatID, refs)
+			if err != nil {
+				return err
+			}
+			for i, e := range ecps {
+				file, ok := files[refs[i]]
+				if !ok {
+					log.FromContext(ctx).Warnf("Message %d in chat %d not found, skipping", e.MessageID, src.chatID)
+					continue
+				}
+				stor, err := storage.Manager.GetUserStorageByName(ctx, userID, e.StorageName)
+				if err != nil {
+					return fmt.Errorf("failed to get storage %s: %w", e.StorageName, err)
+				}
+				elem, err := batchtftask.NewTaskElement(stor, e.Path, file)
+				if err != nil {
+					return err
+				}
+				elems = append(elems, *elem)
+			}
+		}
+		if len(elems) == 0 {
+			return errors.New("no file left to resume")
+		}
+		display, err := newResumeDisplay(ctx, cp, data.ProgressMessageID)
+		if err != nil {
+			return err
+		}
+		task := batchtftask.NewBatchTGFileTask(cp.TaskID, injectCtx, userID, elems, resumeProgress(display, batchtftask.NewProgressTracker), true)
+		return display.add(injectCtx, task, dashboard.BatchName(len(elems)), fmt.Sprintf("🔄 Bot 已重启, 已恢复批量任务, 共 %d 个文件", len(elems)))
+	case tphtask.CheckpointKind:
+		var data tphtask.CheckpointData
+		if err := json.Unmarshal([]byte(cp.Data), &data); err != nil {
+			return fmt.Errorf("invalid checkpoint data: %w", err)
+		}
+		stor, err := storage.Manager.GetUserStorageByName(ctx, userID, data.StorageName)
+		if err != nil {
+			return fmt.Errorf("failed to get storage %s: %w", data.StorageName, err)
+		}
+		display, err := newResumeDisplay(ctx, cp, data.ProgressMessageID)
+		if err != nil {
+			return err
+		}
+		progress := resumeProgress(display, func(msgID int, chatID int64) tphtask.ProgressTracker {
+			return tphtask.NewProgress(msgID, chatID)
+		})
+		if data.Page != nil {
+			task, err := tphtask.NewArticleTask(cp.TaskID, injectCtx, userID, data.Page, stor, data.StorPath,
+				tphutil.DefaultClient(), progress, data.Article)
+			if err != nil {
+				return err
+			}
+			return display.add(injectCtx, task, data.Page.Title, fmt.Sprintf("🔄 Bot 已重启, 已恢复 Telegraph 文章任务: %s", data.Page.Title))
+		}
+		task := tphtask.NewTask(cp.TaskID, injectCtx, userID, data.PhPath, data.Pics, stor, data.StorPath,
+			tphutil.DefaultClient(), progress)
+		return display.add(injectCtx, task, data.PhPath, fmt.Sprintf("🔄 Bot 已重启, 已恢复 Telegraph 任务, 共 %d 张图片", len(data.Pics)))
+	case httptask.CheckpointKind:
+		var data httptask.CheckpointData
+		if err := json.Unmarshal([]byte(cp.Data), &data); err != nil {
+			return fmt.Errorf("invalid checkpoint data: %w", err)
+		}
+		stor, err := storage.Manager.GetUserStorageByName(ctx, userID, data.StorageName)
+		if err != nil {
+			return fmt.Errorf("failed to get storage %s: %w", data.StorageName, err)
+		}
+		// 缓存文件在关闭时已被清理, 重新获取文件信息后从头下载
+		req := httpdl.Request{URL: data.URL, Headers: data.Headers}
+		info, err := httptask.NewClient().Probe(ctx, req)
+		if err != nil {
+			return fmt.Errorf("failed to probe %s: %w", data.URL, err)
+		}
+		info.Name = data.FileName
+		display, err := newResumeDisplay(ctx, cp, data.ProgressMessageID)
+		if err != nil {
+			return err
+		}
+		task, err := httptask.NewTask(cp.TaskID, injectCtx, userID, req, info, stor, data.Path, resumeProgress(display, httptask.NewProgressTrack))
+		if err != nil {
+			return err
+		}
+		return display.add(injectCtx, task, data.FileName, "")
+	case ytdlptask.CheckpointKind:
+		var data ytdlptask.CheckpointData
+		if err := json.Unmarshal([]byte(cp.Data), &data); err != nil {
+			return fmt.Errorf("invalid checkpoint data: %w", err)
+		}
+		stor, err := storage.Manager.GetUserStorageByName(ctx, userID, data.StorageName)
+		if err != nil {
+			return fmt.Errorf("failed to get storage %s: %w", data.StorageName, err)
+		}
+		display, err := newResumeDisplay(ctx, cp, data.ProgressMessageID)
+		if err != nil {
+			return err
+		}
+		task, err := ytdlptask.NewTask(cp.TaskID, injectCtx, userID, data.URL, data.Format, data.Title, data.Size,
+			stor, data.DirPath, resumeProgress(display, ytdlptask.NewProgressTrack))
+		if err != nil {
+			return err
+		}
+		return display.add(injectCtx, task, data.Title, "")
+	case torrenttask.CheckpointKind:
+		var data torrenttask.CheckpointData
+		if err := json.Unmarshal([]byte(cp.Data), &data); err != nil {
+			return fmt.Errorf("invalid checkpoint data: %w", err)
+		}
+		stor, err := storage.Manager.GetUserStorageByName(ctx, userID, data.StorageName)
+		if err != nil {
+			return fmt.Errorf("failed to get storage %s: %w", data.StorageName, err)
+		}
+		display, err := newResumeDisplay(ctx, cp, data.ProgressMessageID)
+		if err != nil {
+			return err
+		}
+		task, err := torrenttask.NewTask(cp.TaskID, injectCtx, userID, data.Metainfo, data.Indexes,
+			stor, data.DirPath, resumeProgress(display, torrenttask.NewProgressTrack))
+		if err != nil {
+			return err
+		}
+		return display.add(injectCtx, task, task.TorrentName(), "")
+	case ivtask.CheckpointKind:
+		var data ivtask.CheckpointData
+		if err := json.Unmarshal([]byte(cp.Data), &data); err != nil {
+			return fmt.Errorf("invalid checkpoint data: %w", err)
+		}
+		stor, err := storage.Manager.GetUserStorageByName(ctx, userID, data.StorageName)
+		if err != nil {
+			return fmt.Errorf("failed to get storage %s: %w", data.StorageName, err)
+		}
+		iv, err := FetchInstantView(ctx, data.URL)
+		if err != nil {
+			return fmt.Errorf("failed to get instant view of %s: %w", data.URL, err)
+		}
+		display, err := newResumeDisplay(ctx, cp, data.ProgressMessageID)
+		if err != nil {
+			return err
+		}
+		client, err := instantViewClient(ctx, *iv)
+		if err != nil {
+			return err
+		}
+		task, err := ivtask.NewTask(cp.TaskID, injectCtx, userID, iv.WebPage, client,
+			stor, data.DirPath, data.EPUB, resumeProgress(display, ivtask.NewProgressTrack))
+		if err != nil {
+			return err
+		}
+		return display.add(injectCtx, task, task.Title(), "")
+	case notetask.CheckpointKind:
+		var data notetask.CheckpointData
+		if err := json.Unmarshal([]byte(cp.Data), &data); err != nil {
+			return fmt.Errorf("invalid checkpoint data: %w", err)
+		}
+		stor, err := storage.Manager.GetUserStorageByName(ctx, userID, data.StorageName)
+		if err != nil {
+			return fmt.Errorf("failed to get storage %s: %w", data.StorageName, err)
+		}
+		display, err := newResumeDisplay(ctx, cp, data.ProgressMessageID)
+		if err != nil {
+			return err
+		}
+		task := notetask.NewTask(cp.TaskID, injectCtx, userID, data.Title, data.Content,
+			stor, data.Path, resumeProgress(display, notetask.NewProgressTrack))
+		return display.add(injectCtx, task, task.FileName(), "")
+	case archivetask.CheckpointKind:
+		var data archivetask.CheckpointData
+		if err := json.Unmarshal([]byte(cp.Data), &data); err != nil {
+			return fmt.Errorf("invalid checkpoint data: %w", err)
+		}
+		user, err := database.GetUserByChatID(ctx, userID)
+		if err != nil {
+			return fmt.Errorf("failed to get user: %w", err)
+		}
+		// 归档进度保存在数据库中, 恢复后从最后一条已归档的消息继续
+		archive, err := database.GetChatArchiveByID(ctx, data.ArchiveID)
+		if err != nil {
+			return fmt.Errorf("failed to get chat archive %d: %w", data.ArchiveID, err)
+		}
+		stor, err := storage.Manager.GetUserStorageByName(ctx, userID, archive.StorageName)
+		if err != nil {
+			return fmt.Errorf("failed to get storage %s: %w", archive.StorageName, err)
+		}
+		display, err := newResumeDisplay(ctx, cp, data.ProgressMessageID)
+		if err != nil {
+			return err
+		}
+		a := tcbdata.Archive{ChatID: archive.ChatID, Title: data.Title, Userbot: data.Userbot, From: data.From, To: data.To}
+		client, err := archiveClient(ctx, a)
+		if err != nil {
+			return err
+		}
+		task, err := archivetask.NewTask(cp.TaskID, injectCtx, userID, client, a.Userbot, a.Title, a.From, a.To,
+			archive, stor, archiveRouter(user, stor, archive.Path), resumeProgress(display, archivetask.NewProgressTrack))
+		if err != nil {
+			return err
+		}
+		return display.add(injectCtx, task, task.Title(), "")
+	case stickertask.CheckpointKind:
+		var data stickertask.CheckpointData
+		if err := json.Unmarshal([]byte(cp.Data), &data); err != nil {
+			return fmt.Errorf("invalid checkpoint data: %w", err)
+		}
+		stor, err := storage.Manager.GetUserStorageByName(ctx, userID, data.StorageName)
+		if err != nil {
+			return fmt.Errorf("failed to get storage %s: %w", data.StorageName, err)
+		}
+		set, err := stickerset.Fetch(ctx, ctx.Raw, stickerset.ByShortName(data.ShortName))
+		if err != nil {
+			return fmt.Errorf("failed to get sticker set %s: %w", data.ShortName, err)
+		}
+		display, err := newResumeDisplay(ctx, cp, data.ProgressMessageID)
+		if err != nil {
+			return err
+		}
+		task, err := stickertask.NewTask(cp.TaskID, injectCtx, userID, set, ctx.Raw,
+			stor, data.DirPath, data.Zip, data.Preview, resumeProgress(display, stickertask.NewProgressTrack))
+		if err != nil {
+			return err
+		}
+		return display.add(injectCtx, task, task.Title(), "")
+	}
+	return fmt.Errorf("unknown checkpoint kind: %s", cp.Kind)
+}
+
+// 恢复任务的进度显示: 开启面板的用户在面板中显示, 否则继续使用原来的进度消息
+type resumeDisplay struct {
+	ctx    *ext.Context
+	userID int64
+	taskID string
+	board  *dashboard.Board
+	msgID  int
+}
+
+func newResumeDisplay(ctx *ext.Context, cp database.TaskCheckpoint, progressMsgID int) (*resumeDisplay, error) {
+	d := &resumeDisplay{ctx: ctx, userID: cp.ChatID, taskID: cp.TaskID}
+	if user, err := database.GetUserByChatID(ctx, cp.ChatID); err == nil && user.Dashboard {
+		d.board = dashboard.For(cp.ChatID)
+		return d, nil
+	}
+	msgID, err := resumeTrackMessage(ctx, cp.ChatID, progressMsgID)
+	if err != nil {
+		return nil, err
+	}
+	d.msgID = msgID
+	return d, nil
+}
+
+// 创建进度消息的 tracker, 在面板中显示时返回 nil
+func resumeProgress[T any](d *resumeDisplay, newTracker func(messageID int, chatID int64) T) T {
+	if d.board != nil {
+		var none T
+		return none
+	}
+	return newTracker(d.msgID, d.userID)
+}
+
+// 将恢复的任务加入队列, name 为面板中显示的名称. text 为空时进度消息显示任务已添加
+func (d *resumeDisplay) add(injectCtx context.Context, task core.Exectable, name, text string) error {
+	if d.board != nil {
+		d.board.Queue(d.ctx, d.taskID, name)
+		if err := core.AddTask(injectCtx, task); err != nil {
+			d.board.Remove(d.taskID)
+			return err
+		}
+		return nil
+	}
+	if err := core.AddTask(injectCtx, task); err != nil {
+		return err
+	}
+	req := &tg.MessagesEditMessageRequest{ID: d.msgID, Message: text}
+	if text == "" {
+		req.Message, req.Entities = msgelem.BuildTaskAddedEntities(d.ctx, name, core.GetLength(injectCtx))
+	}
+	d.ctx.EditMessage(d.userID, req)
+	return nil
+}
+
+// 继续使用原来的进度消息, 没有时发送新消息
+func resumeTrackMessage(ctx *ext.Context, userID int64, msgID int) (int, error) {
+	if msgID != 0 {
+		return msgID, nil
+	}
+	msg, err := ctx.SendMessage(userID, &tg.MessagesSendMessageRequest{Message: "🔄 Bot 已重启, 正在恢复任务..."})
+	if err != nil {
+		return 0, fmt.Errorf("failed to send message: %w", err)
+	}
+	return msg.ID, nil
+}
+
+// 恢复任务中的一个文件: 来源消息和消息中文件的位置 ID
+type resumeRef struct {
+	MessageID  int
+	LocationID int64
+	FileName   string
+}
+
+// 重新获取文件来源消息. 先使用 Bot, 获取不到时使用 userbot
+func getResumeFiles(ctx *ext.Context, chatID int64, refs []resumeRef) (map[resumeRef]tfile.TGFileMessage, error) {
+	getters := []*ext.Context{ctx}
+	if uctx, err := userclient.ReadyCtx(); err == nil {
+		getters = append(getters, uctx)
+	}
+	ids := make([]int, 0, len(refs))
+	for _, ref := range refs {
+		ids = append(ids, ref.MessageID)
+	}
+	ids = slice.Unique(ids)
+	files := make(map[resumeRef]tfile.TGFileMessage, len(refs))
+	var lastErr error
+	for _, getter := range getters {
+		msgs := make(map[int]*tg.Message, len(ids))
+		for _, chunk := range slice.Chunk(ids, tgutil.MessagePageSize) {
+			got, err := getter.GetMessages(chatID, tgutil.InputMessageClassSliceFromInt(chunk))
+			if err != nil {
+				lastErr = err
+				continue
+			}
+			for _, m := range got {
+				if msg, ok := m.(*tg.Message); ok {
+					msgs[msg.GetID()] = msg
+				}
+			}
+		}
+		for _, ref := range refs {
+			msg, ok := msgs[ref.MessageID]
+			if !ok {
+				continue
+			}
+			file, err := resumeFile(msg, getter.Raw, getter, ref)
+			if err != nil {
+				lastErr = err
+				continue
+			}
+			files[ref] = file
+		}
+		if len(files) > 0 {
+			return files, nil
+		}
+	}
+	if lastErr != nil {
+		return nil, fmt.Errorf("failed to get messages: %w", lastErr)
+	}
+	return nil, fmt.Errorf("messages not found in chat %d", chatID)
+}
+
+// 消息中与 ref 为同一文件的文件. 付费媒体等消息中有多个文件, 按位置 ID 区分
+func resumeFile(msg *tg.Message, client downloader.Client, getter tfile.MessageGetter, ref resumeRef) (tfile.TGFileMessage, error) {
+	media, ok := msg.GetMedia()
+	if !ok {
+		return nil, fmt.Errorf("message %d has no media", msg.GetID())
+	}
+	return tfile.FileFromMediaMessage(media, client, msg, ref.LocationID,
+		tfile.WithNameIfEmpty(ref.FileName),
+		tfile.WithMessageGetter(getter),
+	)
+}
+
+// 重新获取文件来源故事, 故事只能使用 userbot 获取
+func getResumeStoryFiles(ctx *ext.Context, chatID int64, refs []resumeRef) (map[resumeRef]tfile.TGFileMessage, error) {
+	uctx, err := userclient.ReadyCtx()
+	if err != nil {
+		return nil, fmt.Errorf("userbot is required to get stories: %w", err)
+	}
+	ids := make([]int, 0, len(refs))
+	for _, ref := range refs {
+		ids = append(ids, ref.MessageID)
+	}
+	getter := tgutil.StoryGetter{Ctx: uctx}
+	msgs, err := getter.GetMessages(chatID, tgutil.InputMessageClassSliceFromInt(slice.Unique(ids)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get stories: %w", err)
+	}
+	files := make(map[resumeRef]tfile.TGFileMessage, len(refs))
+	for _, m := range msgs {
+		msg := m.(*tg.Message)
+		for _, ref := range refs {
+			if ref.MessageID != msg.ID {
+				continue
+			}
+			file, err := tfile.FromStoryMessage(msg, uctx.Raw,
+				tfile.WithName(ref.FileName),
+				tfile.WithMessageGetter(getter),
+			)
+			if err != nil {
+				return nil, err
+			}
+			files[ref] = file
+		}
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("stories not found in chat %d", chatID)
+	}
+	return files, nil
+}
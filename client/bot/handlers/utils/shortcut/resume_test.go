@@ -0,0 +1,61 @@
+package shortcut
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/gotd/td/tg"
+	storcfg "github.com/krau/SaveAny-Bot/config/storage"
+	"github.com/krau/SaveAny-Bot/core/batchtftask"
+	"github.com/krau/SaveAny-Bot/pkg/tfile"
+	"github.com/krau/SaveAny-Bot/storage/local"
+)
+
+func TestResumePaidMediaCheckpoint(t *testing.T) {
+	msg := &tg.Message{ID: 5, PeerID: &tg.PeerChannel{ChannelID: 100}}
+	msg.SetMedia(&tg.MessageMediaPaidMedia{ExtendedMedia: []tg.MessageExtendedMediaClass{
+		&tg.MessageExtendedMedia{Media: &tg.MessageMediaDocument{Document: &tg.Document{ID: 1, Size: 100, Attributes: []tg.DocumentAttributeClass{
+			&tg.DocumentAttributeFilename{FileName: "first.mp4"},
+		}}}},
+		&tg.MessageExtendedMedia{Media: &tg.MessageMediaDocument{Document: &tg.Document{ID: 2, Size: 200, Attributes: []tg.DocumentAttributeClass{
+			&tg.DocumentAttributeFilename{FileName: "second.mp4"},
+		}}}},
+	}})
+	stor := new(local.Local)
+	if err := stor.Init(context.Background(), &storcfg.LocalStorageConfig{BaseConfig: storcfg.BaseConfig{Name: "local"}, BasePath: t.TempDir()}); err != nil {
+		t.Fatal(err)
+	}
+	files, err := tfile.FilesFromMediaMessage(msg.Media, nil, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	elems := make([]batchtftask.TaskElement, 0, len(files))
+	for _, file := range files {
+		elem, err := batchtftask.NewTaskElement(stor, "/", file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		elems = append(elems, *elem)
+	}
+	cp, err := batchtftask.NewBatchTGFileTask("t", context.Background(), 1, elems, nil, true).Checkpoint()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var data batchtftask.CheckpointData
+	if err := json.Unmarshal(cp.Data, &data); err != nil {
+		t.Fatal(err)
+	}
+	if len(data.Elements) != 2 {
+		t.Fatalf("checkpoint has %d elements, want 2", len(data.Elements))
+	}
+	for i, e := range data.Elements {
+		file, err := resumeFile(msg, nil, nil, resumeRef{MessageID: e.MessageID, LocationID: e.LocationID, FileName: e.FileName})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tfile.LocationID(file) != tfile.LocationID(files[i]) || file.Size() != files[i].Size() || file.Name() != files[i].Name() {
+			t.Errorf("element %d resumed as file %d %s, want %d %s", i, tfile.LocationID(file), file.Name(), tfile.LocationID(files[i]), files[i].Name())
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package shortcut
+
+import (
+	"github.com/celestix/gotgproto/dispatcher"
+	"github.com/celestix/gotgproto/ext"
+	"github.com/charmbracelet/log"
+	"github.com/gotd/td/tg"
+	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/dashboard"
+	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/msgelem"
+	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/ruleutil"
+	"github.com/krau/SaveAny-Bot/common/utils/tgutil"
+	"github.com/krau/SaveAny-Bot/config"
+	"github.com/krau/SaveAny-Bot/core"
+	"github.com/krau/SaveAny-Bot/core/stickertask"
+	"github.com/krau/SaveAny-Bot/database"
+	"github.com/krau/SaveAny-Bot/pkg/tcbdata"
+	"github.com/krau/SaveAny-Bot/storage"
+	"github.com/rs/xid"
+)
+
+// 创建一个 stickertask.Task 并添加到任务队列中, 以编辑消息的方式反馈结果
+func CreateAndAddStickerTaskWithEdit(ctx *ext.Context, userID int64, stor storage.Storage, dirPath string, s tcbdata.StickerSet, trackMsgID int) error {
+	logger := log.FromContext(ctx)
+	user, err := database.GetUserByChatID(ctx, userID)
+	if err != nil {
+		logger.Errorf("Failed to get user by chat ID: %s", err)
+		ctx.EditMessage(userID, &tg.MessagesEditMessageRequest{
+			ID:      trackMsgID,
+			Message: "获取用户失败: " + err.Error(),
+		})
+		return dispatcher.EndGroups
+	}
+	if user.ApplyRule && user.Rules != nil {
+		// 贴纸包的所有文件保存在同一目录, 规则按标题和链接匹配
+		matchedStorageName, matchedDirPath := ruleutil.ApplyRule(ctx, user.Rules, ruleutil.NewNameInput(s.Set.Title, s.Set.Link()))
+		dirPath = matchedDirPath.String()
+		if matchedStorageName.IsUsable() {
+			stor, err = storage.Manager.GetUserStorageByName(ctx, user.ChatID, matchedStorageName.String())
+			if err != nil {
+				logger.Errorf("Failed to get storage by user ID and name: %s", err)
+				ctx.EditMessage(userID, &tg.MessagesEditMessageRequest{
+					ID:      trackMsgID,
+					Message: "获取存储失败: " + err.Error(),
+				})
+				return dispatcher.EndGroups
+			}
+		}
+	}
+
+	injectCtx := tgutil.ExtWithContext(ctx.Context, ctx)
+	taskid := xid.New().String()
+	var board *dashboard.Board
+	progress := stickertask.NewProgressTrack(trackMsgID, userID)
+	if user.Dashboard {
+		board = dashboard.For(userID)
+		progress = nil
+	}
+	task, err := stickertask.NewTask(taskid, injectCtx, userID, s.Set, ctx.Raw, stor, stor.JoinStoragePath(dirPath),
+		config.Cfg.Sticker.Zip, config.Cfg.Sticker.Preview, progress)
+	if err != nil {
+		logger.Errorf("create task failed: %s", err)
+		ctx.EditMessage(userID, &tg.MessagesEditMessageRequest{
+			ID:      trackMsgID,
+			Message: "创建任务失败: " + err.Error(),
+		})
+		return dispatcher.EndGroups
+	}
+	if board != nil {
+		board.Queue(ctx, taskid, task.Title())
+	}
+	if err := core.AddTask(injectCtx, task); err != nil {
+		logger.Errorf("add task failed: %s", err)
+		if board != nil {
+			board.Remove(taskid)
+		}
+		ctx.EditMessage(userID, &tg.MessagesEditMessageRequest{
+			ID:      trackMsgID,
+			Message: "添加任务失败: " + err.Error(),
+		})
+		return dispatcher.EndGroups
+	}
+	if board != nil {
+		ctx.DeleteMessages(userID, []int{trackMsgID})
+		return dispatcher.EndGroups
+	}
+	text, entities := msgelem.BuildTaskAddedEntities(ctx, task.Title(), core.GetLength(injectCtx))
+	ctx.EditMessage(userID, &tg.MessagesEditMessageRequest{
+		ID:       trackMsgID,
+		Message:  text,
+		Entities: entities,
+	})
+	return dispatcher.EndGroups
+}
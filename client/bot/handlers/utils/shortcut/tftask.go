@@ -10,6 +10,7 @@ import (
 	"github.com/celestix/gotgproto/ext"
 	"github.com/charmbracelet/log"
 	"github.com/gotd/td/tg"
+	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/dashboard"
 	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/msgelem"
 	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/ruleutil"
 	"github.com/krau/SaveAny-Bot/common/utils/tgutil"
@@ -49,18 +50,27 @@ func CreateAndAddTGFileTaskWithEdit(ctx *ext.Context, userID int64, stor storage
 				return dispatcher.EndGroups
 			}
 		}
+	} else {
+		dirPath = ruleutil.RenderDirPath(ctx, dirPath, ruleutil.NewInput(file))
 	}
 
 	// Generate filename using AI if available, otherwise use original
 	fileName := tgutil.GenFileNameFromMessage(*file.Message())
+	if _, _, ok := tfile.StorySource(file); ok {
+		// 故事使用按所属用户和日期生成的文件名
+		fileName = file.Name()
+	}
 	storagePath := stor.JoinStoragePath(path.Join(dirPath, fileName))
 
 	injectCtx := tgutil.ExtWithContext(ctx.Context, ctx)
 	taskid := xid.New().String()
-	task, err := tftask.NewTGFileTask(taskid, injectCtx, file, stor, storagePath,
-		tftask.NewProgressTrack(
-			trackMsgID,
-			userID))
+	var board *dashboard.Board
+	progress := tftask.NewProgressTrack(trackMsgID, userID)
+	if user.Dashboard {
+		board = dashboard.For(userID)
+		progress = nil
+	}
+	task, err := tftask.NewTGFileTask(taskid, injectCtx, userID, file, stor, storagePath, progress)
 	if err == nil {
 		// Set the custom filename for display purposes
 		task.SetCustomName(fileName)
@@ -73,14 +83,25 @@ func CreateAndAddTGFileTaskWithEdit(ctx *ext.Context, userID int64, stor storage
 		})
 		return dispatcher.EndGroups
 	}
+	if board != nil {
+		board.Queue(ctx, taskid, fileName)
+	}
 	if err := core.AddTask(injectCtx, task); err != nil {
 		logger.Errorf("add task failed: %s", err)
+		if board != nil {
+			board.Remove(taskid)
+		}
 		ctx.EditMessage(userID, &tg.MessagesEditMessageRequest{
 			ID:      trackMsgID,
 			Message: "添加任务失败: " + err.Error(),
 		})
 		return dispatcher.EndGroups
 	}
+	if board != nil {
+		// 进度显示在任务面板中, 不再需要单独的消息
+		ctx.DeleteMessages(userID, []int{trackMsgID})
+		return dispatcher.EndGroups
+	}
 	text, entities := msgelem.BuildTaskAddedEntities(ctx, fileName, core.GetLength(injectCtx))
 	ctx.EditMessage(userID, &tg.MessagesEditMessageRequest{
 		ID:       trackMsgID,
@@ -91,6 +112,14 @@ func CreateAndAddTGFileTaskWithEdit(ctx *ext.Context, userID int64, stor storage
 	return dispatcher.EndGroups
 }
 
+// 只有一个文件时同 CreateAndAddTGFileTaskWithEdit, 多个文件时创建批量任务
+func CreateAndAddTGFilesTaskWithEdit(ctx *ext.Context, userID int64, stor storage.Storage, dirPath string, files []tfile.TGFileMessage, trackMsgID int) error {
+	if len(files) == 1 {
+		return CreateAndAddTGFileTaskWithEdit(ctx, userID, stor, dirPath, files[0], trackMsgID)
+	}
+	return CreateAndAddBatchTGFileTaskWithEdit(ctx, userID, stor, dirPath, files, trackMsgID)
+}
+
 // 创建一个 batchtftask.BatchTGFileTask 并添加到任务队列中, 以编辑消息的方式反馈结果
 func CreateAndAddBatchTGFileTaskWithEdit(ctx *ext.Context, userID int64, stor storage.Storage, dirPath string, files []tfile.TGFileMessage, trackMsgID int) error {
 	logger := log.FromContext(ctx)
@@ -108,7 +137,7 @@ func CreateAndAddBatchTGFileTaskWithEdit(ctx *ext.Context, userID int64, stor st
 
 	applyRule := func(file tfile.TGFileMessage) (string, ruleutil.MatchedDirPath) {
 		if !useRule {
-			return stor.Name(), ruleutil.MatchedDirPath(dirPath)
+			return stor.Name(), ruleutil.MatchedDirPath(ruleutil.RenderDirPath(ctx, dirPath, ruleutil.NewInput(file)))
 		}
 		storName, dirP := ruleutil.ApplyRule(ctx, user.Rules, ruleutil.NewInput(file))
 
@@ -248,20 +277,54 @@ func CreateAndAddBatchTGFileTaskWithEdit(ctx *ext.Context, userID int64, stor st
 		}
 	}
 
+	return addBatchTGFileTaskWithEdit(ctx, user, elems, trackMsgID)
+}
+
+// 将已创建的文件元素作为一个新的批量任务添加到任务队列中, 以编辑消息的方式反馈结果
+func AddBatchTGFileTaskWithEdit(ctx *ext.Context, userID int64, elems []batchtftask.TaskElement, trackMsgID int) error {
+	user, err := database.GetUserByChatID(ctx, userID)
+	if err != nil {
+		log.FromContext(ctx).Errorf("Failed to get user by chat ID: %s", err)
+		ctx.EditMessage(userID, &tg.MessagesEditMessageRequest{
+			ID:      trackMsgID,
+			Message: "获取用户失败: " + err.Error(),
+		})
+		return dispatcher.EndGroups
+	}
+	return addBatchTGFileTaskWithEdit(ctx, user, elems, trackMsgID)
+}
+
+func addBatchTGFileTaskWithEdit(ctx *ext.Context, user *database.User, elems []batchtftask.TaskElement, trackMsgID int) error {
+	logger := log.FromContext(ctx)
+	userID := user.ChatID
 	injectCtx := tgutil.ExtWithContext(ctx.Context, ctx)
 	taskid := xid.New().String()
-	task := batchtftask.NewBatchTGFileTask(taskid, injectCtx, elems, batchtftask.NewProgressTracker(trackMsgID, userID), true)
+	var board *dashboard.Board
+	progress := batchtftask.NewProgressTracker(trackMsgID, userID)
+	if user.Dashboard {
+		board = dashboard.For(userID)
+		progress = nil
+		board.Queue(ctx, taskid, dashboard.BatchName(len(elems)))
+	}
+	task := batchtftask.NewBatchTGFileTask(taskid, injectCtx, userID, elems, progress, true)
 	if err := core.AddTask(injectCtx, task); err != nil {
 		logger.Errorf("Failed to add batch task: %s", err)
+		if board != nil {
+			board.Remove(taskid)
+		}
 		ctx.EditMessage(userID, &tg.MessagesEditMessageRequest{
 			ID:      trackMsgID,
 			Message: "批量任务添加失败: " + err.Error(),
 		})
 		return dispatcher.EndGroups
 	}
+	if board != nil {
+		ctx.DeleteMessages(userID, []int{trackMsgID})
+		return dispatcher.EndGroups
+	}
 	ctx.EditMessage(userID, &tg.MessagesEditMessageRequest{
 		ID:          trackMsgID,
-		Message:     fmt.Sprintf("已添加批量任务, 共 %d 个文件", len(files)),
+		Message:     fmt.Sprintf("已添加批量任务, 共 %d 个文件", len(elems)),
 		ReplyMarkup: nil,
 	})
 	return dispatcher.EndGroups
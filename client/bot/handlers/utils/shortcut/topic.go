@@ -0,0 +1,159 @@
+package shortcut
+
+import (
+	"errors"
+	"regexp"
+
+	"github.com/celestix/gotgproto/dispatcher"
+	"github.com/celestix/gotgproto/ext"
+	"github.com/charmbracelet/log"
+	"github.com/gotd/td/tg"
+	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/mediautil"
+	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/re"
+	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/ruleutil"
+	uc "github.com/krau/SaveAny-Bot/client/user"
+	"github.com/krau/SaveAny-Bot/common/utils/tgutil"
+	"github.com/krau/SaveAny-Bot/pkg/tcbdata"
+	"github.com/krau/SaveAny-Bot/pkg/tfile"
+	"github.com/krau/SaveAny-Bot/storage"
+)
+
+// 消息中第一个指向论坛话题的消息链接所在的话题, 链接中没有话题时根据消息判断. 没有时返回 nil
+func GetTopicFromLinks(ctx *ext.Context, text string) *tcbdata.Topic {
+	tctx, err := uc.ReadyCtx()
+	userbot := err == nil
+	if !userbot {
+		tctx = ctx
+	}
+	for _, link := range re.TgMessageLinkRegexp.FindAllString(text, -1) {
+		chatID, topicID, msgID, err := tgutil.ParseTopicMessageLink(tctx, link)
+		if err != nil {
+			continue
+		}
+		if topicID == 0 {
+			msg, err := tgutil.GetMessageByID(tctx, chatID, msgID)
+			if err != nil {
+				continue
+			}
+			var ok bool
+			if topicID, ok = tgutil.MessageTopicID(msg); !ok {
+				continue
+			}
+		}
+		t, err := GetTopic(tctx, chatID, topicID)
+		if err != nil {
+			log.FromContext(ctx).Debugf("Failed to get topic %d in %d: %s", topicID, chatID, err)
+			continue
+		}
+		t.Userbot = userbot
+		return t
+	}
+	return nil
+}
+
+// 获取论坛话题, 同时确认聊天是论坛且话题存在. 不支持 General 话题
+func GetTopic(ctx *ext.Context, chatID int64, topicID int) (*tcbdata.Topic, error) {
+	// General 话题中的消息不是话题首条消息的回复, 无法按话题获取
+	if topicID == tgutil.GeneralTopicID {
+		return nil, errors.New("the General topic can only be saved by message ID range")
+	}
+	title, err := tgutil.GetTopicTitle(ctx, chatID, topicID)
+	if err != nil {
+		return nil, err
+	}
+	return &tcbdata.Topic{
+		ChatID:  chatID,
+		TopicID: topicID,
+		Title:   title,
+	}, nil
+}
+
+// 分页获取论坛话题中的所有消息, 其中的文件每 rangeSaveChunkSize 个左右添加为一个批量任务, 以编辑消息的方式反馈结果.
+// 目录路径中的 {{.TopicTitle}} 会被替换为话题标题
+func SaveTopicWithEdit(ctx *ext.Context, userID int64, stor storage.Storage, dirPath string, t tcbdata.Topic, trackMsgID int) error {
+	logger := log.FromContext(ctx)
+	editText := func(text string) {
+		ctx.EditMessage(userID, &tg.MessagesEditMessageRequest{
+			ID:      trackMsgID,
+			Message: text,
+		})
+	}
+	var filter *regexp.Regexp
+	if t.Filter != "" {
+		var err error
+		filter, err = regexp.Compile(t.Filter)
+		if err != nil {
+			editText("无效的正则表达式: " + err.Error())
+			return dispatcher.EndGroups
+		}
+	}
+	editText("正在获取话题消息...")
+	tctx := ctx
+	if t.Userbot {
+		uctx, err := uc.ReadyCtx()
+		if err != nil {
+			editText("获取 userbot 失败: " + err.Error())
+			return dispatcher.EndGroups
+		}
+		tctx = uctx
+	}
+	dirPath = ruleutil.RenderDirPath(ctx, dirPath, ruleutil.NewInput(nil, ruleutil.WithTopic(t.TopicID, t.Title)))
+	tracker := &batchTracker{ctx: ctx, userID: userID, trackMsgID: trackMsgID}
+	var pending []tfile.TGFileMessage
+	// 非最后一批时末尾的相册留到下一批, 避免同一相册被拆分
+	flush := func(final bool) {
+		files := pending
+		if final {
+			pending = nil
+		} else {
+			files, pending = splitTrailingAlbum(pending)
+		}
+		if len(files) == 0 {
+			return
+		}
+		msgID, err := tracker.next()
+		if err != nil {
+			logger.Errorf("Failed to send message: %s", err)
+			return
+		}
+		CreateAndAddBatchTGFileTaskWithEdit(ctx, userID, stor, dirPath, files, msgID)
+	}
+	// 话题中的消息都是话题首条消息的回复, 逐页获取并按批添加任务
+	for page, err := range tgutil.IterReplies(tctx, t.ChatID, t.TopicID) {
+		if err != nil {
+			logger.Errorf("Failed to get messages of topic %d: %s", t.TopicID, err)
+			flush(true)
+			tracker.notify("获取话题消息失败: " + err.Error())
+			return dispatcher.EndGroups
+		}
+		for _, msg := range page.Messages {
+			media, ok := msg.GetMedia()
+			if !ok || !mediautil.IsSupported(media) {
+				continue
+			}
+			if filter != nil {
+				fn, _ := tgutil.GetMediaFileName(media)
+				if !filter.MatchString(msg.GetMessage() + " " + fn) {
+					continue
+				}
+			}
+			msgFiles, err := tfile.FilesFromMediaMessage(media, tctx.Raw, msg,
+				tfile.WithNameIfEmpty(tgutil.GenFileNameFromMessage(*msg)),
+				tfile.WithMessageGetter(tctx),
+			)
+			if err != nil {
+				logger.Errorf("Failed to get file of message %d: %s", msg.ID, err)
+				continue
+			}
+			pending = append(pending, msgFiles...)
+		}
+		if len(pending) >= rangeSaveChunkSize {
+			flush(false)
+		}
+	}
+	flush(true)
+	if tracker.batches == 0 {
+		editText("话题中没有可保存的文件")
+	}
+	return dispatcher.EndGroups
+}
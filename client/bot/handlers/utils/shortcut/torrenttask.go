@@ -0,0 +1,178 @@
+package shortcut
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"time"
+
+	"github.com/celestix/gotgproto/dispatcher"
+	"github.com/celestix/gotgproto/ext"
+	"github.com/celestix/gotgproto/types"
+	"github.com/charmbracelet/log"
+	"github.com/gotd/td/tg"
+	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/dashboard"
+	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/msgelem"
+	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/re"
+	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/ruleutil"
+	"github.com/krau/SaveAny-Bot/common/utils/tgutil"
+	"github.com/krau/SaveAny-Bot/config"
+	"github.com/krau/SaveAny-Bot/core"
+	"github.com/krau/SaveAny-Bot/core/torrenttask"
+	"github.com/krau/SaveAny-Bot/database"
+	"github.com/krau/SaveAny-Bot/pkg/tcbdata"
+	"github.com/krau/SaveAny-Bot/pkg/tfile"
+	"github.com/krau/SaveAny-Bot/pkg/torrentdl"
+	"github.com/krau/SaveAny-Bot/storage"
+	"github.com/rs/xid"
+)
+
+// .torrent 文件的最大大小
+const maxTorrentFileSize = 10 << 20
+
+// 消息中的文件是否为 .torrent 文件
+func IsTorrentDocument(message *tg.Message) bool {
+	media, ok := message.Media.(*tg.MessageMediaDocument)
+	if !ok {
+		return false
+	}
+	doc, ok := media.Document.(*tg.Document)
+	if !ok || doc.Size > maxTorrentFileSize {
+		return false
+	}
+	if doc.MimeType == "application/x-bittorrent" {
+		return true
+	}
+	for _, attr := range doc.Attributes {
+		if name, ok := attr.(*tg.DocumentAttributeFilename); ok {
+			return strings.HasSuffix(strings.ToLower(name.FileName), ".torrent")
+		}
+	}
+	return false
+}
+
+// 获取消息中的磁力链接或 .torrent 文件的种子信息并回复等待消息
+func GetTorrentFromMessageWithReply(ctx *ext.Context, update *ext.Update) (*types.Message, []byte, *torrentdl.Meta, error) {
+	logger := log.FromContext(ctx)
+	userID := update.GetUserChat().GetID()
+	message := update.EffectiveMessage.Message
+	magnet := re.MagnetRegexp.FindString(message.GetMessage())
+	if magnet == "" && !IsTorrentDocument(message) {
+		return nil, nil, nil, dispatcher.ContinueGroups
+	}
+	msg, err := ctx.Reply(update, ext.ReplyTextString("正在获取种子信息..."), nil)
+	if err != nil {
+		logger.Errorf("Failed to reply to update: %s", err)
+		return nil, nil, nil, dispatcher.EndGroups
+	}
+	fail := func(text string, err error) (*types.Message, []byte, *torrentdl.Meta, error) {
+		logger.Errorf("%s: %s", text, err)
+		ctx.EditMessage(userID, &tg.MessagesEditMessageRequest{
+			ID:      msg.ID,
+			Message: text + ": " + err.Error(),
+		})
+		return nil, nil, nil, dispatcher.EndGroups
+	}
+	var metainfo []byte
+	if magnet != "" {
+		client, err := torrenttask.Default()
+		if err != nil {
+			return fail("启动 BitTorrent 客户端失败", err)
+		}
+		fctx, cancel := context.WithTimeout(ctx, time.Duration(config.Cfg.Torrent.MetadataTimeout)*time.Second)
+		defer cancel()
+		metainfo, err = client.FetchMetainfo(fctx, magnet)
+		if err != nil {
+			return fail("获取种子信息失败", err)
+		}
+	} else {
+		file, err := tfile.FromMediaMessage(message.Media, ctx.Raw, message, tfile.WithMessageGetter(ctx))
+		if err != nil {
+			return fail("获取文件失败", err)
+		}
+		var buf bytes.Buffer
+		if _, err := tfile.NewDownloader(file).Stream(ctx, &buf); err != nil {
+			return fail("下载种子文件失败", err)
+		}
+		metainfo = buf.Bytes()
+	}
+	meta, err := torrentdl.ParseMetainfo(metainfo)
+	if err != nil {
+		return fail("解析种子失败", err)
+	}
+	return msg, metainfo, meta, nil
+}
+
+// 创建一个 torrenttask.Task 并添加到任务队列中, 以编辑消息的方式反馈结果
+func CreateAndAddTorrentTaskWithEdit(ctx *ext.Context, userID int64, stor storage.Storage, dirPath string, data tcbdata.Torrent, trackMsgID int) error {
+	logger := log.FromContext(ctx)
+	user, err := database.GetUserByChatID(ctx, userID)
+	if err != nil {
+		logger.Errorf("Failed to get user by chat ID: %s", err)
+		ctx.EditMessage(userID, &tg.MessagesEditMessageRequest{
+			ID:      trackMsgID,
+			Message: "获取用户失败: " + err.Error(),
+		})
+		return dispatcher.EndGroups
+	}
+	if user.ApplyRule && user.Rules != nil {
+		// 所有文件保存在同一位置, 规则按种子名称匹配
+		matchedStorageName, matchedDirPath := ruleutil.ApplyRule(ctx, user.Rules, ruleutil.NewNameInput(data.Name, ""))
+		dirPath = matchedDirPath.String()
+		if matchedStorageName.IsUsable() {
+			stor, err = storage.Manager.GetUserStorageByName(ctx, user.ChatID, matchedStorageName.String())
+			if err != nil {
+				logger.Errorf("Failed to get storage by user ID and name: %s", err)
+				ctx.EditMessage(userID, &tg.MessagesEditMessageRequest{
+					ID:      trackMsgID,
+					Message: "获取存储失败: " + err.Error(),
+				})
+				return dispatcher.EndGroups
+			}
+		}
+	}
+
+	injectCtx := tgutil.ExtWithContext(ctx.Context, ctx)
+	taskid := xid.New().String()
+	var board *dashboard.Board
+	progress := torrenttask.NewProgressTrack(trackMsgID, userID)
+	if user.Dashboard {
+		board = dashboard.For(userID)
+		progress = nil
+	}
+	task, err := torrenttask.NewTask(taskid, injectCtx, userID, data.Metainfo, data.Indexes,
+		stor, stor.JoinStoragePath(dirPath), progress)
+	if err != nil {
+		logger.Errorf("create task failed: %s", err)
+		ctx.EditMessage(userID, &tg.MessagesEditMessageRequest{
+			ID:      trackMsgID,
+			Message: "创建任务失败: " + err.Error(),
+		})
+		return dispatcher.EndGroups
+	}
+	if board != nil {
+		board.Queue(ctx, taskid, data.Name)
+	}
+	if err := core.AddTask(injectCtx, task); err != nil {
+		logger.Errorf("add task failed: %s", err)
+		if board != nil {
+			board.Remove(taskid)
+		}
+		ctx.EditMessage(userID, &tg.MessagesEditMessageRequest{
+			ID:      trackMsgID,
+			Message: "添加任务失败: " + err.Error(),
+		})
+		return dispatcher.EndGroups
+	}
+	if board != nil {
+		ctx.DeleteMessages(userID, []int{trackMsgID})
+		return dispatcher.EndGroups
+	}
+	text, entities := msgelem.BuildTaskAddedEntities(ctx, data.Name, core.GetLength(injectCtx))
+	ctx.EditMessage(userID, &tg.MessagesEditMessageRequest{
+		ID:       trackMsgID,
+		Message:  text,
+		Entities: entities,
+	})
+	return dispatcher.EndGroups
+}
@@ -5,11 +5,14 @@ import (
 	"github.com/celestix/gotgproto/ext"
 	"github.com/charmbracelet/log"
 	"github.com/gotd/td/tg"
+	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/dashboard"
 	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/msgelem"
 	"github.com/krau/SaveAny-Bot/common/utils/tgutil"
 	"github.com/krau/SaveAny-Bot/common/utils/tphutil"
+	"github.com/krau/SaveAny-Bot/config"
 	"github.com/krau/SaveAny-Bot/core"
 	"github.com/krau/SaveAny-Bot/core/tphtask"
+	"github.com/krau/SaveAny-Bot/database"
 	"github.com/krau/SaveAny-Bot/pkg/telegraph"
 	"github.com/krau/SaveAny-Bot/storage"
 	"github.com/rs/xid"
@@ -23,23 +26,60 @@ func CreateAndAddTphTaskWithEdit(ctx *ext.Context,
 	stor storage.Storage,
 	trackMsgID int) error {
 	injectCtx := tgutil.ExtWithContext(ctx.Context, ctx)
-	task := tphtask.NewTask(xid.New().String(),
-		injectCtx,
-		tphpage.Path,
-		pics,
-		stor,
-		stor.JoinStoragePath(dirPath),
-		tphutil.DefaultClient(),
-		tphtask.NewProgress(trackMsgID, userID),
-	)
+	taskid := xid.New().String()
+	var board *dashboard.Board
+	var progress tphtask.ProgressTracker = tphtask.NewProgress(trackMsgID, userID)
+	if user, err := database.GetUserByChatID(ctx, userID); err == nil && user.Dashboard {
+		board = dashboard.For(userID)
+		progress = nil
+		board.Queue(ctx, taskid, tphpage.Title)
+	}
+	var task *tphtask.Task
+	if config.Cfg.Telegraph.Article {
+		var err error
+		task, err = tphtask.NewArticleTask(taskid, injectCtx, userID, tphpage, stor, stor.JoinStoragePath(dirPath),
+			tphutil.DefaultClient(), progress, tphtask.ArticleOptions{
+				EPUB:   config.Cfg.Telegraph.EPUB,
+				Embeds: config.Cfg.Telegraph.Embeds,
+			})
+		if err != nil {
+			log.FromContext(ctx).Errorf("Failed to create task: %s", err)
+			if board != nil {
+				board.Remove(taskid)
+			}
+			ctx.EditMessage(userID, &tg.MessagesEditMessageRequest{
+				ID:      trackMsgID,
+				Message: "任务创建失败: " + err.Error(),
+			})
+			return dispatcher.EndGroups
+		}
+	} else {
+		task = tphtask.NewTask(taskid,
+			injectCtx,
+			userID,
+			tphpage.Path,
+			pics,
+			stor,
+			stor.JoinStoragePath(dirPath),
+			tphutil.DefaultClient(),
+			progress,
+		)
+	}
 	if err := core.AddTask(injectCtx, task); err != nil {
 		log.FromContext(ctx).Errorf("Failed to add task: %s", err)
+		if board != nil {
+			board.Remove(taskid)
+		}
 		ctx.EditMessage(userID, &tg.MessagesEditMessageRequest{
 			ID:      trackMsgID,
 			Message: "任务添加失败: " + err.Error(),
 		})
 		return dispatcher.EndGroups
 	}
+	if board != nil {
+		ctx.DeleteMessages(userID, []int{trackMsgID})
+		return dispatcher.EndGroups
+	}
 	text, entities := msgelem.BuildTaskAddedEntities(ctx, tphpage.Title, core.GetLength(ctx))
 	ctx.EditMessage(userID, &tg.MessagesEditMessageRequest{
 		ID:       trackMsgID,
@@ -0,0 +1,119 @@
+package shortcut
+
+import (
+	"github.com/celestix/gotgproto/dispatcher"
+	"github.com/celestix/gotgproto/ext"
+	"github.com/celestix/gotgproto/types"
+	"github.com/charmbracelet/log"
+	"github.com/gotd/td/tg"
+	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/dashboard"
+	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/msgelem"
+	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/re"
+	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/ruleutil"
+	"github.com/krau/SaveAny-Bot/common/utils/tgutil"
+	"github.com/krau/SaveAny-Bot/core"
+	"github.com/krau/SaveAny-Bot/core/ytdlptask"
+	"github.com/krau/SaveAny-Bot/database"
+	"github.com/krau/SaveAny-Bot/pkg/tcbdata"
+	"github.com/krau/SaveAny-Bot/pkg/ytdlp"
+	"github.com/krau/SaveAny-Bot/storage"
+	"github.com/rs/xid"
+)
+
+// 获取消息中视频链接的信息并回复等待消息
+func GetYtdlpInfoFromMessageWithReply(ctx *ext.Context, update *ext.Update) (*types.Message, string, *ytdlp.Info, error) {
+	logger := log.FromContext(ctx)
+	url := re.HttpUrlRegexp.FindString(update.EffectiveMessage.GetMessage())
+	if url == "" {
+		return nil, "", nil, dispatcher.ContinueGroups
+	}
+	msg, err := ctx.Reply(update, ext.ReplyTextString("正在获取视频信息..."), nil)
+	if err != nil {
+		logger.Errorf("Failed to reply to update: %s", err)
+		return nil, "", nil, dispatcher.EndGroups
+	}
+	info, err := ytdlptask.NewClient().Info(ctx, url)
+	if err != nil {
+		logger.Errorf("Failed to get video info of %s: %s", url, err)
+		ctx.EditMessage(update.GetUserChat().GetID(), &tg.MessagesEditMessageRequest{
+			ID:      msg.ID,
+			Message: "获取视频信息失败: " + err.Error(),
+		})
+		return nil, "", nil, dispatcher.EndGroups
+	}
+	return msg, url, info, nil
+}
+
+// 创建一个 ytdlptask.Task 并添加到任务队列中, 以编辑消息的方式反馈结果
+func CreateAndAddYtdlpTaskWithEdit(ctx *ext.Context, userID int64, stor storage.Storage, dirPath string, video tcbdata.YtdlpVideo, trackMsgID int) error {
+	logger := log.FromContext(ctx)
+	user, err := database.GetUserByChatID(ctx, userID)
+	if err != nil {
+		logger.Errorf("Failed to get user by chat ID: %s", err)
+		ctx.EditMessage(userID, &tg.MessagesEditMessageRequest{
+			ID:      trackMsgID,
+			Message: "获取用户失败: " + err.Error(),
+		})
+		return dispatcher.EndGroups
+	}
+	if user.ApplyRule && user.Rules != nil {
+		// 最终文件名由 yt-dlp 决定, 规则按标题和预计的扩展名匹配
+		matchedStorageName, matchedDirPath := ruleutil.ApplyRule(ctx, user.Rules, ruleutil.NewNameInput(video.Title+"."+video.Ext, video.URL))
+		dirPath = matchedDirPath.String()
+		if matchedStorageName.IsUsable() {
+			stor, err = storage.Manager.GetUserStorageByName(ctx, user.ChatID, matchedStorageName.String())
+			if err != nil {
+				logger.Errorf("Failed to get storage by user ID and name: %s", err)
+				ctx.EditMessage(userID, &tg.MessagesEditMessageRequest{
+					ID:      trackMsgID,
+					Message: "获取存储失败: " + err.Error(),
+				})
+				return dispatcher.EndGroups
+			}
+		}
+	}
+
+	injectCtx := tgutil.ExtWithContext(ctx.Context, ctx)
+	taskid := xid.New().String()
+	var board *dashboard.Board
+	progress := ytdlptask.NewProgressTrack(trackMsgID, userID)
+	if user.Dashboard {
+		board = dashboard.For(userID)
+		progress = nil
+	}
+	task, err := ytdlptask.NewTask(taskid, injectCtx, userID, video.URL, video.Format, video.Title, video.Size,
+		stor, stor.JoinStoragePath(dirPath), progress)
+	if err != nil {
+		logger.Errorf("create task failed: %s", err)
+		ctx.EditMessage(userID, &tg.MessagesEditMessageRequest{
+			ID:      trackMsgID,
+			Message: "创建任务失败: " + err.Error(),
+		})
+		return dispatcher.EndGroups
+	}
+	if board != nil {
+		board.Queue(ctx, taskid, video.Title)
+	}
+	if err := core.AddTask(injectCtx, task); err != nil {
+		logger.Errorf("add task failed: %s", err)
+		if board != nil {
+			board.Remove(taskid)
+		}
+		ctx.EditMessage(userID, &tg.MessagesEditMessageRequest{
+			ID:      trackMsgID,
+			Message: "添加任务失败: " + err.Error(),
+		})
+		return dispatcher.EndGroups
+	}
+	if board != nil {
+		ctx.DeleteMessages(userID, []int{trackMsgID})
+		return dispatcher.EndGroups
+	}
+	text, entities := msgelem.BuildTaskAddedEntities(ctx, video.Title, core.GetLength(injectCtx))
+	ctx.EditMessage(userID, &tg.MessagesEditMessageRequest{
+		ID:       trackMsgID,
+		Message:  text,
+		Entities: entities,
+	})
+	return dispatcher.EndGroups
+}
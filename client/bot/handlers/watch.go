@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"fmt"
 	"regexp"
 	"strings"
 
@@ -36,7 +37,19 @@ func handleWatchCmd(ctx *ext.Context, update *ext.Update) error {
 		ctx.Reply(update, ext.ReplyTextString("无效的ID或用户名: "+err.Error()), nil)
 		return dispatcher.EndGroups
 	}
-	watching, err := user.WatchingChat(ctx, chatID)
+	// 监听故事时不使用过滤器
+	stories := len(args) == 3 && args[2] == "stories"
+	filterArgs := args[2:]
+	topicID := 0
+	if len(filterArgs) > 0 && isTopicArg(filterArgs[0]) {
+		topicID, err = parseTopicArg(filterArgs[0])
+		if err != nil {
+			ctx.Reply(update, ext.ReplyTextString(err.Error()), nil)
+			return dispatcher.EndGroups
+		}
+		filterArgs = filterArgs[1:]
+	}
+	watching, err := user.WatchingChat(ctx, chatID, stories, topicID)
 	if err != nil {
 		logger.Errorf("Failed to check if user is watching chat %d: %s", chatID, err)
 		return dispatcher.EndGroups
@@ -46,8 +59,8 @@ func handleWatchCmd(ctx *ext.Context, update *ext.Update) error {
 		return dispatcher.EndGroups
 	}
 	filter := ""
-	if len(args) > 2 {
-		filterArg := strings.Join(args[2:], " ")
+	if len(filterArgs) > 0 && !stories {
+		filterArg := strings.Join(filterArgs, " ")
 		filterType := strings.Split(filterArg, ":")[0]
 		filterData := strings.Split(filterArg, ":")[1]
 		if filterType == "" || filterData == "" {
@@ -68,14 +81,24 @@ func handleWatchCmd(ctx *ext.Context, update *ext.Update) error {
 		}
 	}
 	if err := user.WatchChat(ctx, database.WatchChat{
-		UserID: user.ID,
-		ChatID: chatID,
-		Filter: filter,
+		UserID:  user.ID,
+		ChatID:  chatID,
+		Filter:  filter,
+		Stories: stories,
+		TopicID: topicID,
 	}); err != nil {
 		logger.Errorf("Failed to watch chat %d: %s", chatID, err)
 		ctx.Reply(update, ext.ReplyTextString("监听聊天失败: "+err.Error()), nil)
 		return dispatcher.EndGroups
 	}
+	if stories {
+		ctx.Reply(update, ext.ReplyTextString("已开始监听故事: "+chatArg), nil)
+		return dispatcher.EndGroups
+	}
+	if topicID != 0 {
+		ctx.Reply(update, ext.ReplyTextString(fmt.Sprintf("已开始监听聊天 %s 中的话题 %d", chatArg, topicID)), nil)
+		return dispatcher.EndGroups
+	}
 	ctx.Reply(update, ext.ReplyTextString("已开始监听聊天: "+chatArg), nil)
 	return dispatcher.EndGroups
 }
@@ -100,7 +123,16 @@ func handleUnwatchCmd(ctx *ext.Context, update *ext.Update) error {
 		ctx.Reply(update, ext.ReplyTextString("无效的ID或用户名: "+err.Error()), nil)
 		return dispatcher.EndGroups
 	}
-	if err := user.UnwatchChat(ctx, chatID); err != nil {
+	stories := len(args) == 3 && args[2] == "stories"
+	topicID := 0
+	if len(args) == 3 && isTopicArg(args[2]) {
+		topicID, err = parseTopicArg(args[2])
+		if err != nil {
+			ctx.Reply(update, ext.ReplyTextString(err.Error()), nil)
+			return dispatcher.EndGroups
+		}
+	}
+	if err := user.UnwatchChat(ctx, chatID, stories, topicID); err != nil {
 		logger.Errorf("Failed to unwatch chat %d: %s", chatID, err)
 		ctx.Reply(update, ext.ReplyTextString("取消监听聊天失败: "+err.Error()), nil)
 		return dispatcher.EndGroups
@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/celestix/gotgproto/dispatcher"
+	"github.com/celestix/gotgproto/ext"
+	"github.com/charmbracelet/log"
+	"github.com/gotd/td/telegram/message/entity"
+	"github.com/gotd/td/telegram/message/styling"
+	"github.com/gotd/td/tg"
+	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/msgelem"
+	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/re"
+	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/shortcut"
+	"github.com/krau/SaveAny-Bot/common/cache"
+	"github.com/krau/SaveAny-Bot/config"
+	"github.com/krau/SaveAny-Bot/pkg/enums/tasktype"
+	"github.com/krau/SaveAny-Bot/pkg/tcbdata"
+	"github.com/krau/SaveAny-Bot/pkg/ytdlp"
+	"github.com/krau/SaveAny-Bot/storage"
+	"github.com/rs/xid"
+)
+
+// 只处理配置了使用 yt-dlp 的域名的链接, 其他链接交给之后的直链处理器
+func onYtdlpLink(next func(*ext.Context, *ext.Update) error) func(*ext.Context, *ext.Update) error {
+	return func(ctx *ext.Context, update *ext.Update) error {
+		url := re.HttpUrlRegexp.FindString(update.EffectiveMessage.GetMessage())
+		if !config.Cfg.Ytdlp.Match(url) {
+			return nil
+		}
+		return next(ctx, update)
+	}
+}
+
+func buildYtdlpFormatKeyboard(url string, info *ytdlp.Info) (*tg.ReplyInlineMarkup, error) {
+	buttons := make([]tg.KeyboardButtonClass, 0)
+	for _, opt := range ytdlp.FormatOptions(info) {
+		data := tcbdata.YtdlpVideo{
+			URL:    url,
+			Title:  info.Title,
+			Ext:    info.Ext,
+			Format: opt.Selector,
+			Size:   opt.Size,
+		}
+		dataid := xid.New().String()
+		if err := cache.Set(dataid, data); err != nil {
+			return nil, err
+		}
+		text := opt.Label
+		if opt.Size > 0 {
+			text = fmt.Sprintf("%s (~%s)", opt.Label, msgelem.FormatSize(opt.Size))
+		}
+		buttons = append(buttons, &tg.KeyboardButtonCallback{
+			Text: text,
+			Data: fmt.Appendf(nil, "%s %s", tcbdata.TypeYtdlpFormat, dataid),
+		})
+	}
+	markup := &tg.ReplyInlineMarkup{}
+	for i := 0; i < len(buttons); i += 2 {
+		markup.Rows = append(markup.Rows, tg.KeyboardButtonRow{Buttons: buttons[i:min(i+2, len(buttons))]})
+	}
+	return markup, nil
+}
+
+func handleYtdlpUrlMessage(ctx *ext.Context, update *ext.Update) error {
+	logger := log.FromContext(ctx)
+	msg, url, info, err := shortcut.GetYtdlpInfoFromMessageWithReply(ctx, update)
+	if err != nil {
+		return err
+	}
+	userID := update.GetUserChat().GetID()
+	markup, err := buildYtdlpFormatKeyboard(url, info)
+	if err != nil {
+		logger.Errorf("构建格式选择键盘失败: %s", err)
+		ctx.EditMessage(userID, &tg.MessagesEditMessageRequest{
+			ID:      msg.ID,
+			Message: "构建格式选择键盘失败: " + err.Error(),
+		})
+		return dispatcher.EndGroups
+	}
+	eb := entity.Builder{}
+	if err := styling.Perform(&eb,
+		styling.Plain("标题: "),
+		styling.Code(info.Title),
+		styling.Plain("\n请选择格式"),
+	); err != nil {
+		logger.Errorf("Failed to build entity: %s", err)
+		return dispatcher.EndGroups
+	}
+	text, entities := eb.Complete()
+	ctx.EditMessage(userID, &tg.MessagesEditMessageRequest{
+		Message:     text,
+		ID:          msg.ID,
+		ReplyMarkup: markup,
+		Entities:    entities,
+	})
+	return dispatcher.EndGroups
+}
+
+func handleYtdlpFormatCallback(ctx *ext.Context, update *ext.Update) error {
+	dataid := strings.Split(string(update.CallbackQuery.Data), " ")[1]
+	data, err := shortcut.GetCallbackDataWithAnswer[tcbdata.YtdlpVideo](ctx, update, dataid)
+	if err != nil {
+		return err
+	}
+	userID := update.CallbackQuery.GetUserID()
+	markup, err := msgelem.BuildAddSelectStorageKeyboard(ctx, userID, tcbdata.Add{
+		TaskType: tasktype.TaskTypeYtdlp,
+		Ytdlp:    &data,
+	})
+	if err != nil {
+		log.FromContext(ctx).Errorf("构建存储选择键盘失败: %s", err)
+		ctx.AnswerCallback(msgelem.AlertCallbackAnswer(update.CallbackQuery.GetQueryID(), "构建存储选择键盘失败: "+err.Error()))
+		return dispatcher.EndGroups
+	}
+	eb := entity.Builder{}
+	if err := styling.Perform(&eb,
+		styling.Plain("标题: "),
+		styling.Code(data.Title),
+		styling.Plain("\n请选择存储位置"),
+	); err != nil {
+		log.FromContext(ctx).Errorf("Failed to build entity: %s", err)
+		return dispatcher.EndGroups
+	}
+	text, entities := eb.Complete()
+	ctx.EditMessage(userID, &tg.MessagesEditMessageRequest{
+		Message:     text,
+		ID:          update.CallbackQuery.GetMsgID(),
+		ReplyMarkup: markup,
+		Entities:    entities,
+	})
+	return dispatcher.EndGroups
+}
+
+func handleSilentSaveYtdlpUrl(ctx *ext.Context, update *ext.Update) error {
+	logger := log.FromContext(ctx)
+	stor := storage.FromContext(ctx)
+	if stor == nil {
+		logger.Warn("Context storage is nil")
+		ctx.Reply(update, ext.ReplyTextString("未找到存储"), nil)
+		return dispatcher.EndGroups
+	}
+	msg, url, info, err := shortcut.GetYtdlpInfoFromMessageWithReply(ctx, update)
+	if err != nil {
+		return err
+	}
+	video := tcbdata.YtdlpVideo{
+		URL:    url,
+		Title:  info.Title,
+		Ext:    info.Ext,
+		Format: config.Cfg.Ytdlp.Format,
+	}
+	return shortcut.CreateAndAddYtdlpTaskWithEdit(ctx, update.GetUserChat().GetID(), stor, "", video, msg.ID)
+}
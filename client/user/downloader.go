@@ -0,0 +1,41 @@
+package user
+
+import (
+	"context"
+	"time"
+
+	"github.com/celestix/gotgproto"
+	"github.com/celestix/gotgproto/sessionMaker"
+	"github.com/gotd/td/telegram"
+	"github.com/krau/SaveAny-Bot/client/middleware"
+	"github.com/krau/SaveAny-Bot/common/utils/netutil"
+	"github.com/krau/SaveAny-Bot/config"
+	"github.com/ncruces/go-sqlite3/gormlite"
+)
+
+// 登录一个仅用于分担下载的额外用户会话, 不处理任何更新
+func LoginDownloader(ctx context.Context, session string, extra ...telegram.Middleware) (*gotgproto.Client, error) {
+	proxyUrl := ""
+	if config.Cfg.Telegram.Proxy.Enable {
+		proxyUrl = config.Cfg.Telegram.Proxy.URL
+	}
+	resolver, err := netutil.NewResolver(proxyUrl)
+	if err != nil {
+		return nil, err
+	}
+	return gotgproto.NewClient(
+		config.Cfg.Telegram.AppID,
+		config.Cfg.Telegram.AppHash,
+		gotgproto.ClientTypePhone(""),
+		&gotgproto.ClientOpts{
+			Session:          sessionMaker.SqlSession(gormlite.Open(session)),
+			AuthConversator:  &terminalAuthConversator{},
+			Context:          ctx,
+			DisableCopyright: true,
+			Resolver:         resolver,
+			MaxRetries:       config.Cfg.Telegram.RpcRetry,
+			NoUpdates:        true,
+			Middlewares:      append(middleware.NewDefaultMiddlewares(ctx, 5*time.Minute), extra...),
+		},
+	)
+}
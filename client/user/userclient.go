@@ -2,6 +2,7 @@ package user
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"github.com/celestix/gotgproto"
@@ -18,6 +19,7 @@ import (
 	"github.com/krau/SaveAny-Bot/common/utils/netutil"
 	"github.com/krau/SaveAny-Bot/config"
 	"github.com/krau/SaveAny-Bot/database"
+	"github.com/krau/SaveAny-Bot/pkg/dlpool"
 	"github.com/ncruces/go-sqlite3/gormlite"
 	"golang.org/x/net/proxy"
 )
@@ -36,6 +38,17 @@ func GetCtx() *ext.Context {
 	return ectx
 }
 
+// userbot 未启用或尚未登录
+var ErrNotReady = errors.New("userbot not ready")
+
+// 获取 userbot 的 context, 未启用或尚未登录时返回 ErrNotReady
+func ReadyCtx() (*ext.Context, error) {
+	if !config.Cfg.Telegram.Userbot.Enable || uc == nil {
+		return nil, ErrNotReady
+	}
+	return GetCtx(), nil
+}
+
 func GetClient() *gotgproto.Client {
 	if uc == nil {
 		panic("User client is not initialized, please call Login first")
@@ -69,6 +82,7 @@ func Login(ctx context.Context) (*gotgproto.Client, error) {
 		} else {
 			resolver = dcs.DefaultResolver()
 		}
+		account := dlpool.NewAccount("userbot")
 		tclient, err := gotgproto.NewClient(
 			config.Cfg.Telegram.AppID,
 			config.Cfg.Telegram.AppHash,
@@ -81,7 +95,7 @@ func Login(ctx context.Context) (*gotgproto.Client, error) {
 				Resolver:         resolver,
 				MaxRetries:       config.Cfg.Telegram.RpcRetry,
 				AutoFetchReply:   true,
-				Middlewares:      middleware.NewDefaultMiddlewares(ctx, 5*time.Minute),
+				Middlewares:      append(middleware.NewDefaultMiddlewares(ctx, 5*time.Minute), account.Middleware()),
 				ErrorHandler: func(ctx *ext.Context, u *ext.Update, s string) error {
 					log.FromContext(ctx).Errorf("Unhandled error: %s", s)
 					return dispatcher.EndGroups
@@ -93,7 +107,10 @@ func Login(ctx context.Context) (*gotgproto.Client, error) {
 				client *gotgproto.Client
 				err    error
 			}{nil, err}
+			return
 		}
+		account.Bind(tclient.API(), tclient.CreateContext())
+		dlpool.Default().Register(account)
 		res <- struct {
 			client *gotgproto.Client
 			err    error
@@ -111,13 +128,14 @@ func Login(ctx context.Context) (*gotgproto.Client, error) {
 			return nil, r.err
 		}
 		uc = r.client
+		uc.Dispatcher.AddHandler(handlers.NewAnyUpdate(handleStoryUpdate))
 		uc.Dispatcher.AddHandler(handlers.NewMessage(filters.Message.Media, func(ctx *ext.Context, u *ext.Update) error {
 			switch u.UpdateClass.(type) {
 			case *tg.UpdateEditChannelMessage, *tg.UpdateEditMessage, *tg.UpdateDeleteChannelMessages, *tg.UpdateDeleteMessages:
 				return dispatcher.EndGroups
 			}
 			chatId := u.EffectiveChat().GetID()
-			watchChats, err := database.GetWatchChatsByChatID(ctx, chatId)
+			watchChats, err := database.GetWatchChatsByChatID(ctx, chatId, false)
 			if err != nil || len(watchChats) == 0 {
 				return dispatcher.EndGroups
 			}
@@ -6,21 +6,25 @@ import (
 
 	"github.com/celestix/gotgproto/dispatcher"
 	"github.com/celestix/gotgproto/ext"
+	"github.com/celestix/gotgproto/functions"
 	"github.com/gotd/td/tg"
 	"github.com/krau/SaveAny-Bot/common/utils/tgutil"
+	"github.com/krau/SaveAny-Bot/database"
 	"github.com/krau/SaveAny-Bot/pkg/tfile"
 )
 
 type MediaMessageEvent struct {
 	Ctx       *ext.Context
 	ChatID    int64 // from witch the media message was sent
-	MessageID int
+	MessageID int   // 来自故事时为故事 ID
+	Story     bool
 	File      tfile.TGFileMessage
 }
 
 type messageKey struct {
 	ChatID    int64
 	MessageID int
+	Story     bool
 }
 
 type MediaMessageHandler struct {
@@ -44,7 +48,7 @@ func GetMediaMessageCh() chan MediaMessageEvent {
 }
 
 func sendMediaMessageEvent(event MediaMessageEvent) {
-	key := messageKey{ChatID: event.ChatID, MessageID: event.MessageID}
+	key := messageKey{ChatID: event.ChatID, MessageID: event.MessageID, Story: event.Story}
 
 	mediaMessageHandler.mu.Lock()
 	defer mediaMessageHandler.mu.Unlock()
@@ -76,6 +80,9 @@ func handleMediaMessage(ctx *ext.Context, update *ext.Update) error {
 		switch media.(type) {
 		case *tg.MessageMediaDocument, *tg.MessageMediaPhoto:
 			return true
+		case *tg.MessageMediaPaidMedia, *tg.MessageMediaInvoice, *tg.MessageMediaGame:
+			_, err := tfile.UnwrapMedia(media)
+			return err == nil
 		default:
 			return false
 		}
@@ -85,7 +92,7 @@ func handleMediaMessage(ctx *ext.Context, update *ext.Update) error {
 	}
 	file, err := tfile.FromMediaMessage(media, ctx.Raw, message.Message, tfile.WithNameIfEmpty(
 		tgutil.GenFileNameFromMessage(*message.Message),
-	))
+	), tfile.WithMessageGetter(ctx))
 	if err != nil {
 		return err
 	}
@@ -98,3 +105,37 @@ func handleMediaMessage(ctx *ext.Context, update *ext.Update) error {
 	})
 	return dispatcher.EndGroups
 }
+
+// 监听的用户或频道发布新故事, 需要 userbot 账号关注该用户或频道才能收到
+func handleStoryUpdate(ctx *ext.Context, update *ext.Update) error {
+	upd, ok := update.UpdateClass.(*tg.UpdateStory)
+	if !ok {
+		return nil
+	}
+	story, ok := upd.Story.(*tg.StoryItem)
+	if !ok || story.Media == nil {
+		return dispatcher.EndGroups
+	}
+	chatID := functions.GetChatIdFromPeer(upd.Peer)
+	watchChats, err := database.GetWatchChatsByChatID(ctx, chatID, true)
+	if err != nil || len(watchChats) == 0 {
+		return dispatcher.EndGroups
+	}
+	names := tgutil.NewPeerNames()
+	names.AddEntities(update.Entities)
+	file, err := tfile.FromStoryMessage(tgutil.StoryMessage(upd.Peer, story), ctx.Raw,
+		tfile.WithName(tgutil.GenStoryFileName(tgutil.StoryOwnerName(names, upd.Peer), story)),
+		tfile.WithMessageGetter(tgutil.StoryGetter{Ctx: ctx}),
+	)
+	if err != nil {
+		return err
+	}
+	sendMediaMessageEvent(MediaMessageEvent{
+		Ctx:       ctx,
+		ChatID:    chatID,
+		MessageID: story.ID,
+		Story:     true,
+		File:      file,
+	})
+	return dispatcher.EndGroups
+}
@@ -5,12 +5,14 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
 	"time"
 
 	"slices"
 
 	"github.com/charmbracelet/log"
 	"github.com/krau/SaveAny-Bot/client/bot"
+	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/dashboard"
 	userclient "github.com/krau/SaveAny-Bot/client/user"
 	"github.com/krau/SaveAny-Bot/common/cache"
 	"github.com/krau/SaveAny-Bot/common/i18n"
@@ -34,12 +36,20 @@ func Run(cmd *cobra.Command, _ []string) {
 	})
 	ctx = log.WithContext(ctx, logger)
 
-	initAll(ctx)
-	core.Run(ctx)
+	// 收到退出信号后仍需要客户端和任务继续运行, 直到优雅关闭完成
+	runCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancel()
+
+	initAll(runCtx)
+	dashboard.Subscribe()
+	core.Run(runCtx)
+	bot.ResumeTasks(runCtx)
 
 	<-ctx.Done()
 	logger.Info(i18n.T(i18nk.Exiting))
 	defer logger.Info(i18n.T(i18nk.Bye))
+	core.Shutdown(runCtx, time.Duration(config.Cfg.ShutdownTimeout)*time.Second)
+	dashboard.FlushAll()
 	cleanCache()
 }
 
@@ -52,6 +62,7 @@ func initAll(ctx context.Context) {
 	logger := log.FromContext(ctx)
 	i18n.Init(config.Cfg.Lang)
 	logger.Info(i18n.T(i18nk.Initing))
+	cleanOrphanedCache()
 
 	// Initialize AI rename service after config is loaded
 	if err := tgutil.InitAIRenameService(ctx, config.Cfg); err != nil {
@@ -67,41 +78,102 @@ func initAll(ctx context.Context) {
 		}
 	}
 	bot.Init(ctx)
+	bot.InitDownloadPool(ctx)
+}
+
+// 获取缓存文件夹的绝对路径, 路径无效时返回 false
+func cacheDirPath() (string, bool) {
+	if config.Cfg.Temp.BasePath == "" {
+		return "", false
+	}
+	if slices.Contains([]string{"/", ".", "\\", ".."}, filepath.Clean(config.Cfg.Temp.BasePath)) {
+		log.Error(i18n.T(i18nk.InvalidCacheDir, map[string]any{
+			"Path": config.Cfg.Temp.BasePath,
+		}))
+		return "", false
+	}
+	currentDir, err := os.Getwd()
+	if err != nil {
+		log.Error(i18n.T(i18nk.GetWorkdirFailed, map[string]any{
+			"Error": err,
+		}))
+		return "", false
+	}
+	cachePath := filepath.Join(currentDir, config.Cfg.Temp.BasePath)
+	cachePath, err = filepath.Abs(cachePath)
+	if err != nil {
+		log.Error(i18n.T(i18nk.GetCacheAbsPathFailed, map[string]any{
+			"Error": err,
+		}))
+		return "", false
+	}
+	return cachePath, true
 }
 
 func cleanCache() {
 	if config.Cfg.NoCleanCache {
 		return
 	}
-	if config.Cfg.Temp.BasePath != "" && !config.Cfg.Stream {
-		if slices.Contains([]string{"/", ".", "\\", ".."}, filepath.Clean(config.Cfg.Temp.BasePath)) {
-			log.Error(i18n.T(i18nk.InvalidCacheDir, map[string]any{
-				"Path": config.Cfg.Temp.BasePath,
-			}))
-			return
-		}
-		currentDir, err := os.Getwd()
-		if err != nil {
-			log.Error(i18n.T(i18nk.GetWorkdirFailed, map[string]any{
+	if config.Cfg.Stream {
+		return
+	}
+	cachePath, ok := cacheDirPath()
+	if !ok {
+		return
+	}
+	log.Info(i18n.T(i18nk.CleaningCache, map[string]any{
+		"Path": cachePath,
+	}))
+	if err := fsutil.RemoveAllInDir(cachePath); err != nil {
+		log.Error(i18n.T(i18nk.CleanCacheFailed, map[string]any{
+			"Error": err,
+		}))
+	}
+}
+
+// 任务缓存文件名: <xid>_<name> 或 tph_<xid>_<name>
+var orphanedCacheFileRegexp = regexp.MustCompile(`^(tph_)?[0-9a-v]{20}_`)
+
+// yt-dlp, BitTorrent, Telegraph, Instant View 文章, 聊天归档和贴纸包任务的缓存目录: ytdlp_<xid>, torrent_<xid>, tph_<xid>, iv_<xid>, archive_<xid>, sticker_<xid>
+var orphanedCacheDirRegexp = regexp.MustCompile(`^(ytdlp|torrent|tph|iv|archive|sticker)_[0-9a-v]{20}$`)
+
+// 启动时清理上次运行残留的任务缓存文件. 此时没有任何任务在运行, 所有匹配的文件都是孤立的
+func cleanOrphanedCache() {
+	if config.Cfg.NoCleanCache {
+		return
+	}
+	cachePath, ok := cacheDirPath()
+	if !ok {
+		return
+	}
+	entries, err := os.ReadDir(cachePath)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Error(i18n.T(i18nk.CleanCacheFailed, map[string]any{
 				"Error": err,
 			}))
-			return
 		}
-		cachePath := filepath.Join(currentDir, config.Cfg.Temp.BasePath)
-		cachePath, err = filepath.Abs(cachePath)
-		if err != nil {
-			log.Error(i18n.T(i18nk.GetCacheAbsPathFailed, map[string]any{
-				"Error": err,
-			}))
-			return
+		return
+	}
+	log.Info(i18n.T(i18nk.CleaningOrphanedCache, map[string]any{
+		"Path": cachePath,
+	}))
+	count := 0
+	for _, entry := range entries {
+		if entry.IsDir() && !orphanedCacheDirRegexp.MatchString(entry.Name()) ||
+			!entry.IsDir() && !orphanedCacheFileRegexp.MatchString(entry.Name()) {
+			continue
 		}
-		log.Info(i18n.T(i18nk.CleaningCache, map[string]any{
-			"Path": cachePath,
-		}))
-		if err := fsutil.RemoveAllInDir(cachePath); err != nil {
-			log.Error(i18n.T(i18nk.CleanCacheFailed, map[string]any{
+		if err := os.RemoveAll(filepath.Join(cachePath, entry.Name())); err != nil {
+			log.Error(i18n.T(i18nk.RemoveFileFailed, map[string]any{
+				"Path":  entry.Name(),
 				"Error": err,
 			}))
+			continue
 		}
+		count++
 	}
+	log.Info(i18n.T(i18nk.OrphanedCacheCleaned, map[string]any{
+		"Count": count,
+	}))
 }
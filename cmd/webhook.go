@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/charmbracelet/log"
+	"github.com/krau/SaveAny-Bot/config"
+	"github.com/krau/SaveAny-Bot/core"
+	"github.com/spf13/cobra"
+)
+
+var webhookCmd = &cobra.Command{
+	Use:   "webhook",
+	Short: "Manage outgoing webhooks",
+}
+
+var webhookTestCmd = &cobra.Command{
+	Use:   "test [name]",
+	Short: "Send a test event to the configured webhooks",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := log.WithContext(cmd.Context(), log.NewWithOptions(os.Stdout, log.Options{}))
+		if err := config.Init(ctx); err != nil {
+			fmt.Println("Failed to load config:", err)
+			os.Exit(1)
+		}
+		var name string
+		if len(args) > 0 {
+			name = args[0]
+		}
+		results, err := core.SendTestWebhook(ctx, name)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		failed := false
+		for wh, err := range results {
+			if err != nil {
+				failed = true
+				fmt.Printf("%s: %v\n", wh, err)
+				continue
+			}
+			fmt.Printf("%s: ok\n", wh)
+		}
+		if failed {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	webhookCmd.AddCommand(webhookTestCmd)
+	rootCmd.AddCommand(webhookCmd)
+}
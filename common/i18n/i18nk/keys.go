@@ -4,6 +4,7 @@ package i18nk
 const (
 	CleanCacheFailed                  = "CleanCacheFailed"
 	CleaningCache                     = "CleaningCache"
+	CleaningOrphanedCache             = "CleaningOrphanedCache"
 	ConfigInvalidDuplicateStorageName = "ConfigInvalid.DuplicateStorageName"
 	ConfigInvalidWorkersOrRetry       = "ConfigInvalid.WorkersOrRetry"
 	CreateRmTimerFailed               = "CreateRmTimerFailed"
@@ -11,6 +12,7 @@ const (
 	GetWorkdirFailed                  = "GetWorkdirFailed"
 	InvalidCacheDir                   = "InvalidCacheDir"
 	LoadedStorages                    = "LoadedStorages"
+	OrphanedCacheCleaned              = "OrphanedCacheCleaned"
 	RemoveFileAfter                   = "RemoveFileAfter"
 	RemoveFileFailed                  = "RemoveFileFailed"
 	Bye                               = "bye"
@@ -0,0 +1,196 @@
+package dlutil
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/gotd/td/tgerr"
+)
+
+// 自适应下载线程数.
+//
+// 下载开始时按 BestThreads 或该 DC 上测得平均吞吐量最高的线程数作为初始并发数, 之后每个采样窗口统计总吞吐量,
+// 以爬山法在 [min, max] 之间调整并发数: 吞吐提升则沿当前方向继续调整, 下降则反向, 变化不大则保持.
+// 遇到 FLOOD_WAIT 时并发数减半, 遇到 DC 内部错误时减一.
+type ThreadTuner struct {
+	dc       int
+	min, max int
+	now      func() time.Time
+
+	mu       sync.Mutex
+	limit    int
+	inflight int
+	notify   chan struct{}
+
+	windowStart time.Time
+	windowBytes int64
+	windowParts int
+	lastSpeed   float64 // 上一个窗口的总吞吐量 (bytes/s)
+	direction   int
+}
+
+var (
+	// 采样窗口的最短时长
+	tunerWindow = 2 * time.Second
+	// 吞吐量变化在该比例以内视为持平
+	tunerTolerance = 0.05
+)
+
+var (
+	dcSpeedMu sync.RWMutex
+	// 每个 DC 上各线程数测得的平均吞吐量 (bytes/s)
+	dcSpeed = make(map[int]map[int]float64)
+)
+
+// 新测得的吞吐量在平均值中所占的权重
+const dcSpeedWeight = 0.3
+
+// 返回该 DC 上测得平均吞吐量最高的线程数, 吞吐量相同时取较少的线程数, 没有记录时返回 0.
+// 平均值跨下载累计, 单次下载中偶然的快慢不会直接覆盖之前的测量结果
+func DCBestThreads(dc int) int {
+	dcSpeedMu.RLock()
+	defer dcSpeedMu.RUnlock()
+	best, bestSpeed := 0, 0.0
+	for threads, speed := range dcSpeed[dc] {
+		if speed > bestSpeed || (speed == bestSpeed && threads < best) {
+			best, bestSpeed = threads, speed
+		}
+	}
+	return best
+}
+
+func recordDCSpeed(dc, threads int, speed float64) {
+	dcSpeedMu.Lock()
+	defer dcSpeedMu.Unlock()
+	speeds, ok := dcSpeed[dc]
+	if !ok {
+		speeds = make(map[int]float64)
+		dcSpeed[dc] = speeds
+	}
+	if old, ok := speeds[threads]; ok {
+		speed = old*(1-dcSpeedWeight) + speed*dcSpeedWeight
+	}
+	speeds[threads] = speed
+}
+
+// 创建一个自适应线程控制器, initial 为没有该 DC 记录时使用的初始并发数
+func NewThreadTuner(dc, minThreads, maxThreads, initial int) *ThreadTuner {
+	if minThreads < 1 {
+		minThreads = 1
+	}
+	if maxThreads < minThreads {
+		maxThreads = minThreads
+	}
+	if best := DCBestThreads(dc); best > 0 {
+		initial = best
+	}
+	t := &ThreadTuner{
+		dc:        dc,
+		min:       minThreads,
+		max:       maxThreads,
+		now:       time.Now,
+		notify:    make(chan struct{}),
+		direction: 1,
+	}
+	t.limit = t.clamp(initial)
+	return t
+}
+
+func (t *ThreadTuner) clamp(n int) int {
+	return max(t.min, min(n, t.max))
+}
+
+// 当前允许的并发数
+func (t *ThreadTuner) Limit() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.limit
+}
+
+// 最大并发数, 下载器应以此值启动工作协程, 实际并发由 Acquire 控制
+func (t *ThreadTuner) Max() int {
+	return t.max
+}
+
+// 获取一个请求名额, 当前并发已达上限时等待
+func (t *ThreadTuner) Acquire(ctx context.Context) error {
+	for {
+		t.mu.Lock()
+		if t.inflight < t.limit {
+			t.inflight++
+			if t.windowStart.IsZero() {
+				t.windowStart = t.now()
+			}
+			t.mu.Unlock()
+			return nil
+		}
+		notify := t.notify
+		t.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-notify:
+		}
+	}
+}
+
+// 释放名额并记录本次请求下载的字节数和错误
+func (t *ThreadTuner) Release(n int, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.inflight--
+	switch {
+	case err == nil:
+		t.windowBytes += int64(n)
+		t.windowParts++
+		t.evaluate()
+	case tgerr.IsCode(err, 420):
+		t.backoff(t.limit / 2)
+	case tgerr.IsCode(err, 500) || tgerr.Is(err, "RPC_CALL_FAIL", "RPC_MCGET_FAIL", "TIMEOUT"):
+		t.backoff(t.limit - 1)
+	}
+	close(t.notify)
+	t.notify = make(chan struct{})
+}
+
+// 调用方需持有锁
+func (t *ThreadTuner) backoff(limit int) {
+	t.limit = t.clamp(limit)
+	t.direction = -1
+	t.lastSpeed = 0
+	t.resetWindow()
+}
+
+// 调用方需持有锁
+func (t *ThreadTuner) resetWindow() {
+	t.windowStart = time.Time{}
+	if t.inflight > 0 {
+		t.windowStart = t.now()
+	}
+	t.windowBytes = 0
+	t.windowParts = 0
+}
+
+// 调用方需持有锁
+func (t *ThreadTuner) evaluate() {
+	elapsed := t.now().Sub(t.windowStart)
+	// 至少每个连接完成一个分块才有参考意义
+	if elapsed < tunerWindow || t.windowParts < t.limit {
+		return
+	}
+	speed := float64(t.windowBytes) / elapsed.Seconds()
+	recordDCSpeed(t.dc, t.limit, speed)
+	switch {
+	case t.lastSpeed == 0 || speed > t.lastSpeed*(1+tunerTolerance):
+	case speed < t.lastSpeed*(1-tunerTolerance):
+		t.direction = -t.direction
+	default:
+		t.lastSpeed = speed
+		t.resetWindow()
+		return
+	}
+	t.lastSpeed = speed
+	t.limit = t.clamp(t.limit + t.direction)
+	t.resetWindow()
+}
@@ -0,0 +1,104 @@
+package dlutil
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gotd/td/tgerr"
+)
+
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) now() time.Time { return c.t }
+
+func newTestTuner(dc, minThreads, maxThreads, initial int) (*ThreadTuner, *fakeClock) {
+	clock := &fakeClock{t: time.Unix(0, 0)}
+	tuner := NewThreadTuner(dc, minThreads, maxThreads, initial)
+	tuner.now = clock.now
+	return tuner, clock
+}
+
+// 模拟一个采样窗口: 以当前并发数完成若干分块, 总共下载 total 字节
+func runWindow(t *testing.T, tuner *ThreadTuner, clock *fakeClock, total int) {
+	t.Helper()
+	limit := tuner.Limit()
+	for range limit {
+		if err := tuner.Acquire(context.Background()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	clock.t = clock.t.Add(tunerWindow)
+	for range limit {
+		tuner.Release(total/limit, nil)
+	}
+}
+
+func TestTunerScalesUpWhileFaster(t *testing.T) {
+	tuner, clock := newTestTuner(101, 1, 8, 2)
+	runWindow(t, tuner, clock, 2<<20)
+	if tuner.Limit() != 3 {
+		t.Fatalf("expected limit 3 after first window, got %d", tuner.Limit())
+	}
+	runWindow(t, tuner, clock, 3<<20)
+	if tuner.Limit() != 4 {
+		t.Fatalf("expected limit 4 while speed improves, got %d", tuner.Limit())
+	}
+	// 吞吐量下降, 应当反向减少并发
+	runWindow(t, tuner, clock, 2<<20)
+	if tuner.Limit() != 3 {
+		t.Fatalf("expected limit 3 after slowdown, got %d", tuner.Limit())
+	}
+	if best := DCBestThreads(101); best != 3 {
+		t.Fatalf("expected best threads 3 for dc, got %d", best)
+	}
+	next, _ := newTestTuner(101, 1, 8, 1)
+	if next.Limit() != 3 {
+		t.Fatalf("expected new tuner to start from remembered value 3, got %d", next.Limit())
+	}
+}
+
+func TestDCBestThreadsKeepsMeasuredAverage(t *testing.T) {
+	first, clock := newTestTuner(104, 4, 4, 4)
+	runWindow(t, first, clock, 8<<20)
+	// 之后的下载以较少的线程测得较慢的速度, 不应覆盖之前更快的测量结果
+	second, clock := newTestTuner(104, 2, 2, 2)
+	runWindow(t, second, clock, 2<<20)
+	if best := DCBestThreads(104); best != 4 {
+		t.Fatalf("expected best threads 4 for dc, got %d", best)
+	}
+}
+
+func TestTunerBacksOffOnFloodWait(t *testing.T) {
+	tuner, _ := newTestTuner(102, 1, 8, 8)
+	if err := tuner.Acquire(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tuner.Release(0, tgerr.New(420, "FLOOD_WAIT_3"))
+	if tuner.Limit() != 4 {
+		t.Fatalf("expected limit 4 after flood wait, got %d", tuner.Limit())
+	}
+	if err := tuner.Acquire(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tuner.Release(0, tgerr.New(500, "RPC_CALL_FAIL"))
+	if tuner.Limit() != 3 {
+		t.Fatalf("expected limit 3 after dc error, got %d", tuner.Limit())
+	}
+}
+
+func TestTunerLimitsConcurrency(t *testing.T) {
+	tuner, _ := newTestTuner(103, 1, 4, 1)
+	if err := tuner.Acquire(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := tuner.Acquire(ctx); err == nil {
+		t.Fatal("expected acquire to block while limit is reached")
+	}
+	tuner.Release(1, nil)
+	if err := tuner.Acquire(context.Background()); err != nil {
+		t.Fatalf("unexpected error after release: %v", err)
+	}
+}
@@ -0,0 +1,20 @@
+//go:build !windows
+
+package fsutil
+
+import (
+	"os"
+	"syscall"
+)
+
+// 获取路径所在磁盘的可用空间 (字节), 路径不存在时会先创建
+func FreeSpace(dirPath string) (uint64, error) {
+	if err := os.MkdirAll(dirPath, os.ModePerm); err != nil {
+		return 0, err
+	}
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(dirPath, &stat); err != nil {
+		return 0, err
+	}
+	return uint64(stat.Bavail) * uint64(stat.Bsize), nil
+}
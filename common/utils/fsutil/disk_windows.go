@@ -0,0 +1,25 @@
+//go:build windows
+
+package fsutil
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// 获取路径所在磁盘的可用空间 (字节), 路径不存在时会先创建
+func FreeSpace(dirPath string) (uint64, error) {
+	if err := os.MkdirAll(dirPath, os.ModePerm); err != nil {
+		return 0, err
+	}
+	ptr, err := windows.UTF16PtrFromString(dirPath)
+	if err != nil {
+		return 0, err
+	}
+	var freeBytesAvailable uint64
+	if err := windows.GetDiskFreeSpaceEx(ptr, &freeBytesAvailable, nil, nil); err != nil {
+		return 0, err
+	}
+	return freeBytesAvailable, nil
+}
@@ -22,6 +22,33 @@ func RemoveAllInDir(dirPath string) error {
 	return nil
 }
 
+// 文件夹内所有文件的总大小
+func DirSize(dirPath string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(dirPath, func(_ string, d os.DirEntry, err error) error {
+		if err != nil {
+			// 统计期间被删除的文件不计入
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		size += info.Size()
+		return nil
+	})
+	return size, err
+}
+
 func DetectFileExt(fp string) string {
 	mt, err := mimetype.DetectFile(fp)
 	if err != nil {
@@ -0,0 +1,20 @@
+package netutil
+
+import (
+	"github.com/gotd/td/telegram/dcs"
+	"golang.org/x/net/proxy"
+)
+
+// 创建 Telegram 客户端使用的 DC 解析器, proxyUrl 为空时直连
+func NewResolver(proxyUrl string) (dcs.Resolver, error) {
+	if proxyUrl == "" {
+		return dcs.DefaultResolver(), nil
+	}
+	dialer, err := NewProxyDialer(proxyUrl)
+	if err != nil {
+		return nil, err
+	}
+	return dcs.Plain(dcs.PlainOptions{
+		Dial: dialer.(proxy.ContextDialer).DialContext,
+	}), nil
+}
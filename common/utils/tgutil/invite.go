@@ -0,0 +1,114 @@
+package tgutil
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/celestix/gotgproto/ext"
+	"github.com/celestix/gotgproto/functions"
+	"github.com/gotd/td/tg"
+	"github.com/gotd/td/tgerr"
+)
+
+// 加入需要管理员批准的聊天时已发送加入请求
+var ErrInviteRequestSent = errors.New("invite request sent")
+
+// 邀请链接指向的聊天
+type ChatInvite struct {
+	// 已加入聊天时不为 0
+	ChatID        int64
+	Title         string
+	About         string
+	Members       int
+	Channel       bool // 频道或超级群组
+	Broadcast     bool // 频道
+	RequestNeeded bool // 加入需要管理员批准
+}
+
+// 检查邀请链接, 已加入的聊天会被保存到 PeerStorage 中
+func CheckChatInvite(ctx *ext.Context, hash string) (*ChatInvite, error) {
+	res, err := ctx.Raw.MessagesCheckChatInvite(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	switch invite := res.(type) {
+	case *tg.ChatInviteAlready:
+		return joinedChatInvite(ctx, invite.Chat)
+	case *tg.ChatInvitePeek:
+		// 可以预览但还未加入
+		ci, err := joinedChatInvite(ctx, invite.Chat)
+		if err != nil {
+			return nil, err
+		}
+		ci.ChatID = 0
+		return ci, nil
+	case *tg.ChatInvite:
+		about, _ := invite.GetAbout()
+		return &ChatInvite{
+			Title:         invite.Title,
+			About:         about,
+			Members:       invite.ParticipantsCount,
+			Channel:       invite.Channel,
+			Broadcast:     invite.Broadcast,
+			RequestNeeded: invite.RequestNeeded,
+		}, nil
+	}
+	return nil, fmt.Errorf("unexpected chat invite type: %T", res)
+}
+
+func joinedChatInvite(ctx *ext.Context, chat tg.ChatClass) (*ChatInvite, error) {
+	functions.SavePeersFromClassArray(ctx.PeerStorage, []tg.ChatClass{chat}, nil)
+	switch c := chat.(type) {
+	case *tg.Channel:
+		members, _ := c.GetParticipantsCount()
+		return &ChatInvite{
+			ChatID:    c.ID,
+			Title:     c.Title,
+			Members:   members,
+			Channel:   true,
+			Broadcast: c.Broadcast,
+		}, nil
+	case *tg.Chat:
+		return &ChatInvite{
+			ChatID:  c.ID,
+			Title:   c.Title,
+			Members: c.ParticipantsCount,
+		}, nil
+	}
+	return nil, fmt.Errorf("chat is not accessible: %T", chat)
+}
+
+// 通过邀请链接加入聊天, 返回聊天 ID. 需要管理员批准时返回 ErrInviteRequestSent
+func JoinChatInvite(ctx *ext.Context, hash string) (int64, error) {
+	res, err := ctx.Raw.MessagesImportChatInvite(ctx, hash)
+	if err != nil {
+		if tgerr.Is(err, "INVITE_REQUEST_SENT") {
+			return 0, ErrInviteRequestSent
+		}
+		if tgerr.Is(err, "USER_ALREADY_PARTICIPANT") {
+			invite, err := CheckChatInvite(ctx, hash)
+			if err != nil {
+				return 0, err
+			}
+			return invite.ChatID, nil
+		}
+		return 0, err
+	}
+	updates, ok := res.(interface {
+		GetChats() []tg.ChatClass
+		GetUsers() []tg.UserClass
+	})
+	if !ok {
+		return 0, fmt.Errorf("unexpected updates type: %T", res)
+	}
+	functions.SavePeersFromClassArray(ctx.PeerStorage, updates.GetChats(), updates.GetUsers())
+	for _, c := range updates.GetChats() {
+		switch chat := c.(type) {
+		case *tg.Channel:
+			return chat.ID, nil
+		case *tg.Chat:
+			return chat.ID, nil
+		}
+	}
+	return 0, fmt.Errorf("joined chat not found in updates")
+}
@@ -5,10 +5,16 @@ import (
 
 	"github.com/gabriel-vasile/mimetype"
 	"github.com/gotd/td/tg"
+	"github.com/krau/SaveAny-Bot/pkg/tfile"
 )
 
+// 媒体中第一个文件的文件名
 func GetMediaFileName(media tg.MessageMediaClass) (string, error) {
-	switch v := media.(type) {
+	medias, err := tfile.UnwrapMedia(media)
+	if err != nil {
+		return "", err
+	}
+	switch v := medias[0].(type) {
 	case *tg.MessageMediaPhoto:
 		f, ok := v.Photo.AsNotEmpty()
 		if !ok {
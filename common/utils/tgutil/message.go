@@ -20,6 +20,7 @@ import (
 	"github.com/krau/SaveAny-Bot/common/cache"
 	"github.com/krau/SaveAny-Bot/common/utils/strutil"
 	"github.com/krau/SaveAny-Bot/pkg/ai"
+	"github.com/krau/SaveAny-Bot/pkg/tfile"
 	"github.com/rs/xid"
 )
 
@@ -46,6 +47,9 @@ func GenFileNameFromMessageWithContext(ctx context.Context, message tg.Message)
 		}
 
 		// Fallback to media type based extension
+		if unwrapped, err := tfile.UnwrapMedia(media); err == nil {
+			media = unwrapped[0]
+		}
 		switch media := media.(type) {
 		case *tg.MessageMediaDocument:
 			doc, ok := media.Document.AsNotEmpty()
@@ -0,0 +1,125 @@
+package tgutil
+
+import (
+	"context"
+	"fmt"
+	"iter"
+
+	"github.com/gotd/td/tg"
+)
+
+// 每页获取的消息 ID 数量, 即 messages.getMessages 单次请求的上限
+const MessagePageSize = 100
+
+type MessagesGetter interface {
+	GetMessages(chatID int64, messageIDs []tg.InputMessageClass) ([]tg.MessageClass, error)
+}
+
+// 分页获取的一批消息
+type MessagePage struct {
+	Messages []*tg.Message
+	Scanned  int // 已扫描的消息 ID 数量, 包括本页
+	Total    int
+}
+
+// 按 ID 从小到大分页获取 [minId, maxId] 范围内的消息.
+//
+// 与 GetMessagesRange 不同, 不会一次性加载整个范围, 也不会把消息写入缓存, 适合很大的范围.
+// 获取失败时产生一个错误并结束迭代; ctx 取消时产生 ctx 的错误并结束迭代
+func IterMessagePages(ctx context.Context, getter MessagesGetter, chatID int64, minId, maxId int) iter.Seq2[MessagePage, error] {
+	return func(yield func(MessagePage, error) bool) {
+		if minId > maxId {
+			yield(MessagePage{}, fmt.Errorf("minId (%d) cannot be greater than maxId (%d)", minId, maxId))
+			return
+		}
+		total := maxId - minId + 1
+		for start := minId; start <= maxId; start += MessagePageSize {
+			if err := ctx.Err(); err != nil {
+				yield(MessagePage{}, err)
+				return
+			}
+			end := min(start+MessagePageSize-1, maxId)
+			ids := make([]tg.InputMessageClass, 0, end-start+1)
+			for id := start; id <= end; id++ {
+				ids = append(ids, &tg.InputMessageID{ID: id})
+			}
+			msgs, err := getter.GetMessages(chatID, ids)
+			if err != nil {
+				yield(MessagePage{}, fmt.Errorf("failed to get messages %d-%d: %w", start, end, err))
+				return
+			}
+			page := MessagePage{
+				Messages: make([]*tg.Message, 0, len(msgs)),
+				Scanned:  end - minId + 1,
+				Total:    total,
+			}
+			for _, msg := range msgs {
+				tgMessage, ok := msg.(*tg.Message)
+				if !ok || tgMessage.GetID() < start || tgMessage.GetID() > end {
+					continue
+				}
+				page.Messages = append(page.Messages, tgMessage)
+			}
+			if !yield(page, nil) {
+				return
+			}
+		}
+	}
+}
+
+type RepliesGetter interface {
+	MessagesGetReplies(ctx context.Context, request *tg.MessagesGetRepliesRequest) (tg.MessagesMessagesClass, error)
+}
+
+// 分页获取的一批回复以及其中用户和聊天的名称
+type ReplyPage struct {
+	Messages []*tg.Message // 从旧到新排列
+	Names    *PeerNames
+}
+
+// 按时间从旧到新分页获取消息的回复或频道消息的评论, 不会一次性加载整个讨论串.
+// 获取失败时产生一个错误并结束迭代; ctx 取消时产生 ctx 的错误并结束迭代
+func IterReplyPages(ctx context.Context, getter RepliesGetter, peer tg.InputPeerClass, msgID int) iter.Seq2[ReplyPage, error] {
+	return func(yield func(ReplyPage, error) bool) {
+		// 负的 AddOffset 使结果为 OffsetID 及之后的消息
+		offsetID := 1
+		for {
+			if err := ctx.Err(); err != nil {
+				yield(ReplyPage{}, err)
+				return
+			}
+			res, err := getter.MessagesGetReplies(ctx, &tg.MessagesGetRepliesRequest{
+				Peer:      peer,
+				MsgID:     msgID,
+				OffsetID:  offsetID,
+				AddOffset: -MessagePageSize,
+				Limit:     MessagePageSize,
+			})
+			if err != nil {
+				yield(ReplyPage{}, fmt.Errorf("failed to get replies from %d: %w", offsetID, err))
+				return
+			}
+			msgs, names, err := MessagesWithPeers(res)
+			if err != nil {
+				yield(ReplyPage{}, err)
+				return
+			}
+			page := ReplyPage{Messages: make([]*tg.Message, 0, len(msgs)), Names: names}
+			next := offsetID
+			for i := len(msgs) - 1; i >= 0; i-- {
+				if msgs[i].ID < offsetID {
+					continue
+				}
+				page.Messages = append(page.Messages, msgs[i])
+				next = max(next, msgs[i].ID+1)
+			}
+			if len(page.Messages) == 0 {
+				return
+			}
+			if !yield(page, nil) {
+				return
+			}
+			offsetID = next
+		}
+	}
+}
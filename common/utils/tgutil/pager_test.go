@@ -0,0 +1,101 @@
+package tgutil
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/gotd/td/tg"
+)
+
+// 只有偶数 ID 的消息存在
+type fakeMessagesGetter struct {
+	calls int
+}
+
+func (g *fakeMessagesGetter) GetMessages(chatID int64, messageIDs []tg.InputMessageClass) ([]tg.MessageClass, error) {
+	g.calls++
+	msgs := make([]tg.MessageClass, 0, len(messageIDs))
+	for _, id := range messageIDs {
+		id := id.(*tg.InputMessageID).ID
+		if id%2 == 0 {
+			msgs = append(msgs, &tg.Message{ID: id})
+		} else {
+			msgs = append(msgs, &tg.MessageEmpty{ID: id})
+		}
+	}
+	return msgs, nil
+}
+
+func TestIterMessagePages(t *testing.T) {
+	getter := &fakeMessagesGetter{}
+	var count, lastScanned int
+	for page, err := range IterMessagePages(context.Background(), getter, 1, 1, 250) {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		count += len(page.Messages)
+		lastScanned = page.Scanned
+		if page.Total != 250 {
+			t.Fatalf("expected total 250, got %d", page.Total)
+		}
+	}
+	if count != 125 || lastScanned != 250 || getter.calls != 3 {
+		t.Fatalf("expected 125 messages in 3 pages, got %d messages, scanned %d, %d calls", count, lastScanned, getter.calls)
+	}
+}
+
+func TestIterMessagePagesCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	getter := &fakeMessagesGetter{}
+	var lastErr error
+	for _, err := range IterMessagePages(ctx, getter, 1, 1, 1000) {
+		if err != nil {
+			lastErr = err
+			break
+		}
+		cancel()
+	}
+	if !errors.Is(lastErr, context.Canceled) || getter.calls != 1 {
+		t.Fatalf("expected iteration to stop after cancel, got %v after %d calls", lastErr, getter.calls)
+	}
+}
+
+// 讨论串中的回复 ID 为 2..250 中的偶数, 按 OffsetID 及之后取 Limit 条, 结果从新到旧排列
+type fakeRepliesGetter struct {
+	calls int
+}
+
+func (g *fakeRepliesGetter) MessagesGetReplies(ctx context.Context, req *tg.MessagesGetRepliesRequest) (tg.MessagesMessagesClass, error) {
+	g.calls++
+	var msgs []tg.MessageClass
+	for id := 2; id <= 250 && len(msgs) < req.Limit; id += 2 {
+		if id >= req.OffsetID {
+			msgs = append([]tg.MessageClass{&tg.Message{ID: id}}, msgs...)
+		}
+	}
+	return &tg.MessagesMessagesSlice{Messages: msgs}, nil
+}
+
+func TestIterReplyPages(t *testing.T) {
+	getter := &fakeRepliesGetter{}
+	last := 0
+	var count int
+	for page, err := range IterReplyPages(context.Background(), getter, &tg.InputPeerSelf{}, 1) {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		for _, msg := range page.Messages {
+			if msg.ID <= last {
+				t.Fatalf("expected replies from old to new, got %d after %d", msg.ID, last)
+			}
+			last = msg.ID
+		}
+		count += len(page.Messages)
+	}
+	// 125 条回复分 2 页, 第 3 次请求没有新消息
+	if count != 125 || getter.calls != 3 {
+		t.Fatalf("expected 125 replies in 3 calls, got %d replies in %d calls", count, getter.calls)
+	}
+}
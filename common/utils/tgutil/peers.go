@@ -0,0 +1,244 @@
+package tgutil
+
+import (
+	"fmt"
+	"iter"
+	"maps"
+	"strings"
+
+	"github.com/celestix/gotgproto/ext"
+	"github.com/gotd/td/tg"
+)
+
+// 消息结果中附带的用户和聊天, 用于显示发送者和来源聊天的名称
+type PeerNames struct {
+	Users    map[int64]*tg.User
+	Chats    map[int64]*tg.Chat
+	Channels map[int64]*tg.Channel
+}
+
+func NewPeerNames() *PeerNames {
+	return &PeerNames{
+		Users:    make(map[int64]*tg.User),
+		Chats:    make(map[int64]*tg.Chat),
+		Channels: make(map[int64]*tg.Channel),
+	}
+}
+
+func (n *PeerNames) Add(users []tg.UserClass, chats []tg.ChatClass) {
+	for _, u := range users {
+		if user, ok := u.(*tg.User); ok {
+			n.Users[user.ID] = user
+		}
+	}
+	for _, c := range chats {
+		switch chat := c.(type) {
+		case *tg.Chat:
+			n.Chats[chat.ID] = chat
+		case *tg.Channel:
+			n.Channels[chat.ID] = chat
+		}
+	}
+}
+
+func (n *PeerNames) AddEntities(e *tg.Entities) {
+	if e == nil {
+		return
+	}
+	for id, u := range e.Users {
+		n.Users[id] = u
+	}
+	for id, c := range e.Chats {
+		n.Chats[id] = c
+	}
+	for id, c := range e.Channels {
+		n.Channels[id] = c
+	}
+}
+
+func (n *PeerNames) Merge(o *PeerNames) {
+	maps.Copy(n.Users, o.Users)
+	maps.Copy(n.Chats, o.Chats)
+	maps.Copy(n.Channels, o.Channels)
+}
+
+func (n *PeerNames) Name(peer tg.PeerClass) string {
+	switch p := peer.(type) {
+	case *tg.PeerUser:
+		if u, ok := n.Users[p.UserID]; ok {
+			return UserName(u)
+		}
+		return fmt.Sprintf("用户 %d", p.UserID)
+	case *tg.PeerChat:
+		if c, ok := n.Chats[p.ChatID]; ok {
+			return c.Title
+		}
+		return fmt.Sprintf("群组 %d", p.ChatID)
+	case *tg.PeerChannel:
+		if c, ok := n.Channels[p.ChannelID]; ok {
+			return c.Title
+		}
+		return fmt.Sprintf("频道 %d", p.ChannelID)
+	}
+	return ""
+}
+
+// 用户或频道的用户名, 没有时为空
+func (n *PeerNames) Username(peer tg.PeerClass) string {
+	switch p := peer.(type) {
+	case *tg.PeerUser:
+		if u, ok := n.Users[p.UserID]; ok {
+			return u.Username
+		}
+	case *tg.PeerChannel:
+		if c, ok := n.Channels[p.ChannelID]; ok {
+			return c.Username
+		}
+	}
+	return ""
+}
+
+// 频道或超级群组中消息的链接, 其他聊天中的消息没有链接
+func (n *PeerNames) Link(peer tg.PeerClass, msgID int) string {
+	p, ok := peer.(*tg.PeerChannel)
+	if !ok {
+		return ""
+	}
+	if c, ok := n.Channels[p.ChannelID]; ok && c.Username != "" {
+		return fmt.Sprintf("https://t.me/%s/%d", c.Username, msgID)
+	}
+	return fmt.Sprintf("https://t.me/c/%d/%d", p.ChannelID, msgID)
+}
+
+func UserName(u *tg.User) string {
+	if name := strings.TrimSpace(u.FirstName + " " + u.LastName); name != "" {
+		return name
+	}
+	if u.Username != "" {
+		return "@" + u.Username
+	}
+	return fmt.Sprintf("用户 %d", u.ID)
+}
+
+// 按 ID 获取聊天中的消息以及其中用户和聊天的名称
+func GetMessagesWithPeers(ctx *ext.Context, chatID int64, ids []int) ([]*tg.Message, *PeerNames, error) {
+	peer := ctx.PeerStorage.GetInputPeerById(chatID)
+	var (
+		res tg.MessagesMessagesClass
+		err error
+	)
+	switch p := peer.(type) {
+	case *tg.InputPeerChannel:
+		res, err = ctx.Raw.ChannelsGetMessages(ctx, &tg.ChannelsGetMessagesRequest{
+			Channel: &tg.InputChannel{ChannelID: p.ChannelID, AccessHash: p.AccessHash},
+			ID:      InputMessageClassSliceFromInt(ids),
+		})
+	case *tg.InputPeerUser, *tg.InputPeerChat:
+		res, err = ctx.Raw.MessagesGetMessages(ctx, InputMessageClassSliceFromInt(ids))
+	default:
+		return nil, nil, fmt.Errorf("peer not found: %d", chatID)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return MessagesWithPeers(res)
+}
+
+// 取出结果中的消息以及其中用户和聊天的名称, 跳过空消息和服务消息
+func MessagesWithPeers(res tg.MessagesMessagesClass) ([]*tg.Message, *PeerNames, error) {
+	modified, ok := res.AsModified()
+	if !ok {
+		return nil, nil, fmt.Errorf("unexpected messages type: %T", res)
+	}
+	names := NewPeerNames()
+	names.Add(modified.GetUsers(), modified.GetChats())
+	msgs := make([]*tg.Message, 0, len(modified.GetMessages()))
+	for _, m := range modified.GetMessages() {
+		if msg, ok := m.(*tg.Message); ok {
+			msgs = append(msgs, msg)
+		}
+	}
+	return msgs, names, nil
+}
+
+// 按时间从旧到新分页获取消息的回复或频道消息的评论, 见 IterReplyPages
+func IterReplies(ctx *ext.Context, chatID int64, msgID int) iter.Seq2[ReplyPage, error] {
+	peer := ctx.PeerStorage.GetInputPeerById(chatID)
+	if _, ok := peer.(*tg.InputPeerEmpty); ok || peer == nil {
+		return func(yield func(ReplyPage, error) bool) {
+			yield(ReplyPage{}, fmt.Errorf("peer not found: %d", chatID))
+		}
+	}
+	return IterReplyPages(ctx, ctx.Raw, peer, msgID)
+}
+
+// 获取聊天的名称, 用户返回其姓名
+func GetChatTitle(ctx *ext.Context, chatID int64) (string, error) {
+	names := NewPeerNames()
+	switch p := ctx.PeerStorage.GetInputPeerById(chatID).(type) {
+	case *tg.InputPeerChannel:
+		res, err := ctx.Raw.ChannelsGetChannels(ctx, []tg.InputChannelClass{
+			&tg.InputChannel{ChannelID: p.ChannelID, AccessHash: p.AccessHash},
+		})
+		if err != nil {
+			return "", err
+		}
+		names.Add(nil, res.GetChats())
+		return names.Name(&tg.PeerChannel{ChannelID: p.ChannelID}), nil
+	case *tg.InputPeerChat:
+		res, err := ctx.Raw.MessagesGetChats(ctx, []int64{p.ChatID})
+		if err != nil {
+			return "", err
+		}
+		names.Add(nil, res.GetChats())
+		return names.Name(&tg.PeerChat{ChatID: p.ChatID}), nil
+	case *tg.InputPeerUser:
+		users, err := ctx.Raw.UsersGetUsers(ctx, []tg.InputUserClass{
+			&tg.InputUser{UserID: p.UserID, AccessHash: p.AccessHash},
+		})
+		if err != nil {
+			return "", err
+		}
+		names.Add(users, nil)
+		return names.Name(&tg.PeerUser{UserID: p.UserID}), nil
+	}
+	return "", fmt.Errorf("peer not found: %d", chatID)
+}
+
+// 分页获取消息的回复或频道消息的评论以及其中用户和聊天的名称, 从新到旧排列. limit 不大于 0 时获取全部,
+// 回复可能很多时应使用 IterReplies
+func GetReplies(ctx *ext.Context, chatID int64, msgID int, limit int) ([]*tg.Message, *PeerNames, error) {
+	peer := ctx.PeerStorage.GetInputPeerById(chatID)
+	if _, ok := peer.(*tg.InputPeerEmpty); ok || peer == nil {
+		return nil, nil, fmt.Errorf("peer not found: %d", chatID)
+	}
+	names := NewPeerNames()
+	var result []*tg.Message
+	offsetID := 0
+	for limit <= 0 || len(result) < limit {
+		pageSize := MessagePageSize
+		if limit > 0 {
+			pageSize = min(pageSize, limit-len(result))
+		}
+		res, err := ctx.Raw.MessagesGetReplies(ctx, &tg.MessagesGetRepliesRequest{
+			Peer:     peer,
+			MsgID:    msgID,
+			OffsetID: offsetID,
+			Limit:    pageSize,
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		msgs, pnames, err := MessagesWithPeers(res)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(msgs) == 0 {
+			break
+		}
+		names.Merge(pnames)
+		result = append(result, msgs...)
+		offsetID = msgs[len(msgs)-1].ID
+	}
+	return result, names, nil
+}
@@ -41,9 +41,17 @@ func ParseChatID(ctx *ext.Context, idOrUsername string) (int64, error) {
 
 // return: ChatID, MessageID, error
 func ParseMessageLink(ctx *ext.Context, link string) (int64, int, error) {
+	chatID, _, msgID, err := ParseTopicMessageLink(ctx, link)
+	return chatID, msgID, err
+}
+
+// 同 ParseMessageLink, 同时返回链接中的论坛话题 ID, 链接中没有话题时为 0
+//
+// return: ChatID, TopicID, MessageID, error
+func ParseTopicMessageLink(ctx *ext.Context, link string) (int64, int, int, error) {
 	u, err := url.Parse(link)
 	if err != nil {
-		return 0, 0, fmt.Errorf("invalid URL: %w", err)
+		return 0, 0, 0, fmt.Errorf("invalid URL: %w", err)
 	}
 	paths := strings.Split(strings.TrimPrefix(u.Path, "/"), "/")
 
@@ -52,68 +60,100 @@ func ParseMessageLink(ctx *ext.Context, link string) (int64, int, error) {
 		// https://t.me/acherkrau/123?comment=2
 		chid, err := ParseChatID(ctx, paths[0])
 		if err != nil {
-			return 0, 0, fmt.Errorf("failed to parse chat ID: %w", err)
+			return 0, 0, 0, fmt.Errorf("failed to parse chat ID: %w", err)
 		}
-		chatfull, err := ctx.GetChat(chid)
+		linkChatId, err := getLinkedChatID(ctx, chid)
 		if err != nil {
-			return 0, 0, fmt.Errorf("failed to get chat: %w", err)
-		}
-		chfull, ok := chatfull.(*tg.ChannelFull)
-		if !ok {
-			return 0, 0, fmt.Errorf("chat is not a channel: %s", chatfull.TypeName())
-		}
-		linkChatId, ok := chfull.GetLinkedChatID()
-		if !ok {
-			return 0, 0, fmt.Errorf("channel has no linked chat")
+			return 0, 0, 0, err
 		}
 		msgID, err := strconv.Atoi(cmt)
 		if err != nil {
-			return 0, 0, fmt.Errorf("failed to parse comment ID: %w", err)
+			return 0, 0, 0, fmt.Errorf("failed to parse comment ID: %w", err)
 		}
-		return linkChatId, msgID, nil
+		return linkChatId, 0, msgID, nil
 	}
 
+	var chatPart, topicPart, msgPart string
 	switch len(paths) {
 	case 2: // https://t.me/acherkrau/123
-		chatID, err := ParseChatID(ctx, paths[0])
-		if err != nil {
-			return 0, 0, fmt.Errorf("failed to parse chat ID: %w", err)
-		}
-		msgID, err := strconv.Atoi(paths[1])
-		if err != nil {
-			return 0, 0, fmt.Errorf("failed to parse message ID: %w", err)
-		}
-		return chatID, msgID, nil
+		chatPart, msgPart = paths[0], paths[1]
 	case 3:
 		// https://t.me/c/123456789/123
-		// https://t.me/acherkrau/123/456 , 456: message thread ID
-		chatPart, msgPart := paths[1], paths[2]
-		if paths[0] != "c" {
-			chatPart = paths[0]
+		// https://t.me/acherkrau/111/456 , 111: topic id
+		if paths[0] == "c" {
+			chatPart, msgPart = paths[1], paths[2]
+		} else {
+			chatPart, topicPart, msgPart = paths[0], paths[1], paths[2]
 		}
-		chatID, err := ParseChatID(ctx, chatPart)
-		if err != nil {
-			return 0, 0, fmt.Errorf("failed to parse chat ID: %w", err)
-		}
-		msgID, err := strconv.Atoi(msgPart)
-		if err != nil {
-			return 0, 0, fmt.Errorf("failed to parse message ID: %w", err)
-		}
-		return chatID, msgID, nil
 	case 4:
 		// https://t.me/c/123456789/111/456 111: topic id
 		if paths[0] != "c" {
-			return 0, 0, fmt.Errorf("invalid message link format: %s", link)
-		}
-		chatID, err := ParseChatID(ctx, paths[1])
-		if err != nil {
-			return 0, 0, fmt.Errorf("failed to parse chat ID: %w", err)
+			return 0, 0, 0, fmt.Errorf("invalid message link format: %s", link)
 		}
-		msgID, err := strconv.Atoi(paths[3])
+		chatPart, topicPart, msgPart = paths[1], paths[2], paths[3]
+	default:
+		return 0, 0, 0, fmt.Errorf("invalid message link format: %s", link)
+	}
+	chatID, err := ParseChatID(ctx, chatPart)
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("failed to parse chat ID: %w", err)
+	}
+	topicID := 0
+	if topicPart != "" {
+		topicID, err = strconv.Atoi(topicPart)
 		if err != nil {
-			return 0, 0, fmt.Errorf("failed to parse message ID: %w", err)
+			return 0, 0, 0, fmt.Errorf("failed to parse topic ID: %w", err)
 		}
-		return chatID, msgID, nil
 	}
-	return 0, 0, fmt.Errorf("invalid message link format: %s", link)
+	msgID, err := strconv.Atoi(msgPart)
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("failed to parse message ID: %w", err)
+	}
+	return chatID, topicID, msgID, nil
+}
+
+// 返回链接指向的频道消息, 用于获取消息的评论. 评论链接返回评论所在的频道消息
+//
+// https://t.me/acherkrau/123 , https://t.me/acherkrau/123?comment=2 , https://t.me/c/123456789/123
+func ParsePostLink(ctx *ext.Context, link string) (int64, int, error) {
+	u, err := url.Parse(link)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid URL: %w", err)
+	}
+	paths := strings.Split(strings.TrimPrefix(u.Path, "/"), "/")
+	var chatPart, postPart string
+	switch {
+	case len(paths) == 2 && paths[0] != "c":
+		chatPart, postPart = paths[0], paths[1]
+	case len(paths) == 3 && paths[0] == "c":
+		chatPart, postPart = paths[1], paths[2]
+	default:
+		return 0, 0, fmt.Errorf("not a channel post link: %s", link)
+	}
+	chatID, err := ParseChatID(ctx, chatPart)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to parse chat ID: %w", err)
+	}
+	postID, err := strconv.Atoi(postPart)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to parse message ID: %w", err)
+	}
+	return chatID, postID, nil
+}
+
+// 频道的讨论组
+func getLinkedChatID(ctx *ext.Context, channelID int64) (int64, error) {
+	chatfull, err := ctx.GetChat(channelID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get chat: %w", err)
+	}
+	chfull, ok := chatfull.(*tg.ChannelFull)
+	if !ok {
+		return 0, fmt.Errorf("chat is not a channel: %s", chatfull.TypeName())
+	}
+	linkChatId, ok := chfull.GetLinkedChatID()
+	if !ok {
+		return 0, fmt.Errorf("channel has no linked chat")
+	}
+	return linkChatId, nil
 }
@@ -0,0 +1,45 @@
+package tgutil
+
+import "testing"
+
+func TestParsePostLink(t *testing.T) {
+	// 数字 ID 不需要解析用户名
+	cases := []struct {
+		link   string
+		chatID int64
+		postID int
+		ok     bool
+	}{
+		{"https://t.me/c/123456/78", 123456, 78, true},
+		{"https://t.me/c/123456/78?comment=90", 123456, 78, true},
+		{"https://t.me/c/123456/11/78", 0, 0, false},
+		{"https://t.me/c/78", 0, 0, false},
+	}
+	for _, c := range cases {
+		chatID, postID, err := ParsePostLink(nil, c.link)
+		if (err == nil) != c.ok || chatID != c.chatID || postID != c.postID {
+			t.Errorf("ParsePostLink(%q) = %d, %d, %v", c.link, chatID, postID, err)
+		}
+	}
+}
+
+func TestParseTopicMessageLink(t *testing.T) {
+	cases := []struct {
+		link    string
+		chatID  int64
+		topicID int
+		msgID   int
+		ok      bool
+	}{
+		{"https://t.me/c/123456/78", 123456, 0, 78, true},
+		{"https://t.me/c/123456/11/78", 123456, 11, 78, true},
+		{"https://t.me/c/123456/x/78", 0, 0, 0, false},
+		{"https://t.me/c/123456/11/78/9", 0, 0, 0, false},
+	}
+	for _, c := range cases {
+		chatID, topicID, msgID, err := ParseTopicMessageLink(nil, c.link)
+		if (err == nil) != c.ok || chatID != c.chatID || topicID != c.topicID || msgID != c.msgID {
+			t.Errorf("ParseTopicMessageLink(%q) = %d, %d, %d, %v", c.link, chatID, topicID, msgID, err)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package tgutil
+
+import (
+	"fmt"
+	"path"
+	"slices"
+	"time"
+
+	"github.com/celestix/gotgproto/ext"
+	"github.com/celestix/gotgproto/functions"
+	"github.com/gotd/td/tg"
+	"github.com/krau/SaveAny-Bot/pkg/tfile"
+)
+
+// 每次获取置顶故事的数量
+const storyPageSize = 100
+
+// 以消息表示故事, 消息 ID 为故事 ID, 文本为故事的说明
+func StoryMessage(peer tg.PeerClass, story *tg.StoryItem) *tg.Message {
+	return &tg.Message{
+		ID:       story.ID,
+		PeerID:   peer,
+		Date:     story.Date,
+		Message:  story.Caption,
+		Entities: story.Entities,
+		Media:    story.Media,
+	}
+}
+
+// 故事的文件名, 由所属用户或频道, 故事 ID 和发布日期组成
+func GenStoryFileName(owner string, story *tg.StoryItem) string {
+	date := time.Unix(int64(story.Date), 0).Format("20060102")
+	return fmt.Sprintf("story_%s_%d_%s%s", owner, story.ID, date, storyExt(story.Media))
+}
+
+func storyExt(media tg.MessageMediaClass) string {
+	switch m := media.(type) {
+	case *tg.MessageMediaPhoto:
+		return ".jpg"
+	case *tg.MessageMediaDocument:
+		if name, err := GetMediaFileName(m); err == nil && path.Ext(name) != "" {
+			return path.Ext(name)
+		}
+	}
+	return ""
+}
+
+// 故事所属用户或频道的名称, 优先使用用户名
+func StoryOwnerName(names *PeerNames, peer tg.PeerClass) string {
+	if username := names.Username(peer); username != "" {
+		return username
+	}
+	return fmt.Sprint(functions.GetChatIdFromPeer(peer))
+}
+
+// 将故事转换为文件, 文件名为 GenStoryFileName 生成的名称
+func StoryFiles(ctx *ext.Context, chatID int64, stories []*tg.StoryItem, names *PeerNames) ([]tfile.TGFileMessage, error) {
+	peer := peerOfInput(ctx.PeerStorage.GetInputPeerById(chatID))
+	if peer == nil {
+		return nil, fmt.Errorf("peer not found: %d", chatID)
+	}
+	owner := StoryOwnerName(names, peer)
+	files := make([]tfile.TGFileMessage, 0, len(stories))
+	for _, story := range stories {
+		file, err := tfile.FromStoryMessage(StoryMessage(peer, story), ctx.Raw,
+			tfile.WithName(GenStoryFileName(owner, story)),
+			tfile.WithMessageGetter(StoryGetter{Ctx: ctx}),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get file of story %d: %w", story.ID, err)
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+func peerOfInput(input tg.InputPeerClass) tg.PeerClass {
+	switch p := input.(type) {
+	case *tg.InputPeerUser:
+		return &tg.PeerUser{UserID: p.UserID}
+	case *tg.InputPeerChat:
+		return &tg.PeerChat{ChatID: p.ChatID}
+	case *tg.InputPeerChannel:
+		return &tg.PeerChannel{ChannelID: p.ChannelID}
+	}
+	return nil
+}
+
+// 按 ID 获取用户或频道的故事, 已过期的故事只有置顶的才能获取到
+func GetStoriesByID(ctx *ext.Context, chatID int64, ids []int) ([]*tg.StoryItem, *PeerNames, error) {
+	peer := ctx.PeerStorage.GetInputPeerById(chatID)
+	if peerOfInput(peer) == nil {
+		return nil, nil, fmt.Errorf("peer not found: %d", chatID)
+	}
+	res, err := ctx.Raw.StoriesGetStoriesByID(ctx, &tg.StoriesGetStoriesByIDRequest{Peer: peer, ID: ids})
+	if err != nil {
+		return nil, nil, err
+	}
+	names := NewPeerNames()
+	names.Add(res.Users, res.Chats)
+	return storyItems(res.Stories), names, nil
+}
+
+// 获取用户或频道当前的故事和所有置顶的故事, 按 ID 排序
+func GetPeerStories(ctx *ext.Context, chatID int64) ([]*tg.StoryItem, *PeerNames, error) {
+	peer := ctx.PeerStorage.GetInputPeerById(chatID)
+	if peerOfInput(peer) == nil {
+		return nil, nil, fmt.Errorf("peer not found: %d", chatID)
+	}
+	names := NewPeerNames()
+	byID := make(map[int]*tg.StoryItem)
+	active, err := ctx.Raw.StoriesGetPeerStories(ctx, peer)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get active stories: %w", err)
+	}
+	names.Add(active.Users, active.Chats)
+	for _, s := range storyItems(active.Stories.Stories) {
+		byID[s.ID] = s
+	}
+	offsetID := 0
+	for {
+		res, err := ctx.Raw.StoriesGetPinnedStories(ctx, &tg.StoriesGetPinnedStoriesRequest{
+			Peer:     peer,
+			OffsetID: offsetID,
+			Limit:    storyPageSize,
+		})
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to get pinned stories: %w", err)
+		}
+		names.Add(res.Users, res.Chats)
+		for _, s := range res.Stories {
+			offsetID = s.GetID()
+		}
+		for _, s := range storyItems(res.Stories) {
+			byID[s.ID] = s
+		}
+		if len(res.Stories) < storyPageSize {
+			break
+		}
+	}
+	stories := make([]*tg.StoryItem, 0, len(byID))
+	for _, s := range byID {
+		stories = append(stories, s)
+	}
+	slices.SortFunc(stories, func(a, b *tg.StoryItem) int { return a.ID - b.ID })
+	return stories, names, nil
+}
+
+// 跳过已删除和未获取到内容的故事
+func storyItems(items []tg.StoryItemClass) []*tg.StoryItem {
+	stories := make([]*tg.StoryItem, 0, len(items))
+	for _, item := range items {
+		if s, ok := item.(*tg.StoryItem); ok && s.Media != nil {
+			stories = append(stories, s)
+		}
+	}
+	return stories
+}
+
+// 按故事 ID 重新获取故事的 tfile.MessageGetter
+type StoryGetter struct {
+	Ctx *ext.Context
+}
+
+func (g StoryGetter) GetMessages(chatID int64, ids []tg.InputMessageClass) ([]tg.MessageClass, error) {
+	storyIDs := make([]int, 0, len(ids))
+	for _, id := range ids {
+		if mid, ok := id.(*tg.InputMessageID); ok {
+			storyIDs = append(storyIDs, mid.ID)
+		}
+	}
+	stories, _, err := GetStoriesByID(g.Ctx, chatID, storyIDs)
+	if err != nil {
+		return nil, err
+	}
+	peer := peerOfInput(g.Ctx.PeerStorage.GetInputPeerById(chatID))
+	msgs := make([]tg.MessageClass, 0, len(stories))
+	for _, story := range stories {
+		msgs = append(msgs, StoryMessage(peer, story))
+	}
+	return msgs, nil
+}
@@ -0,0 +1,54 @@
+package tgutil
+
+import (
+	"fmt"
+
+	"github.com/celestix/gotgproto/ext"
+	"github.com/gotd/td/tg"
+	"github.com/krau/SaveAny-Bot/common/cache"
+)
+
+// 论坛的 General 话题, 其中的消息不带话题信息
+const GeneralTopicID = 1
+
+// 论坛中消息所在的话题 ID, 即话题首条消息的 ID. 不在话题中或在 General 话题中时 ok 为 false
+func MessageTopicID(msg *tg.Message) (int, bool) {
+	if msg == nil {
+		return 0, false
+	}
+	header, ok := msg.ReplyTo.(*tg.MessageReplyHeader)
+	if !ok || !header.ForumTopic {
+		return 0, false
+	}
+	// 回复话题中其他消息时 ReplyToTopID 为话题 ID, 否则回复的就是话题首条消息
+	if topID, ok := header.GetReplyToTopID(); ok {
+		return topID, true
+	}
+	return header.ReplyToMsgID, true
+}
+
+// 获取论坛话题的标题, 结果会被缓存
+func GetTopicTitle(ctx *ext.Context, chatID int64, topicID int) (string, error) {
+	key := fmt.Sprintf("tgtopic:%d:%d:%d", ctx.Self.ID, chatID, topicID)
+	if title, ok := cache.Get[string](key); ok {
+		return title, nil
+	}
+	peer, ok := ctx.PeerStorage.GetInputPeerById(chatID).(*tg.InputPeerChannel)
+	if !ok {
+		return "", fmt.Errorf("chat is not a forum: %d", chatID)
+	}
+	res, err := ctx.Raw.ChannelsGetForumTopicsByID(ctx, &tg.ChannelsGetForumTopicsByIDRequest{
+		Channel: &tg.InputChannel{ChannelID: peer.ChannelID, AccessHash: peer.AccessHash},
+		Topics:  []int{topicID},
+	})
+	if err != nil {
+		return "", err
+	}
+	for _, t := range res.Topics {
+		if topic, ok := t.(*tg.ForumTopic); ok && topic.ID == topicID {
+			cache.Set(key, topic.Title)
+			return topic.Title, nil
+		}
+	}
+	return "", fmt.Errorf("topic not found: %d", topicID)
+}
@@ -0,0 +1,29 @@
+package tgutil
+
+import (
+	"testing"
+
+	"github.com/gotd/td/tg"
+)
+
+func TestMessageTopicID(t *testing.T) {
+	inTopic := &tg.MessageReplyHeader{ForumTopic: true, ReplyToMsgID: 11}
+	replyInTopic := &tg.MessageReplyHeader{ForumTopic: true, ReplyToMsgID: 20}
+	replyInTopic.SetReplyToTopID(11)
+	cases := []struct {
+		msg *tg.Message
+		id  int
+		ok  bool
+	}{
+		{&tg.Message{ReplyTo: inTopic}, 11, true},
+		{&tg.Message{ReplyTo: replyInTopic}, 11, true},
+		{&tg.Message{ReplyTo: &tg.MessageReplyHeader{ReplyToMsgID: 20}}, 0, false},
+		{&tg.Message{}, 0, false},
+	}
+	for i, c := range cases {
+		id, ok := MessageTopicID(c.msg)
+		if id != c.id || ok != c.ok {
+			t.Errorf("case %d: MessageTopicID = %d, %v", i, id, ok)
+		}
+	}
+}
@@ -0,0 +1,6 @@
+package config
+
+type dashboardConfig struct {
+	Interval     int `toml:"interval" mapstructure:"interval" json:"interval"`                // 任务面板的刷新间隔 (秒), 最小为 2
+	KeepFinished int `toml:"keep_finished" mapstructure:"keep_finished" json:"keep_finished"` // 任务面板上保留的已结束任务数
+}
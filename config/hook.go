@@ -1,7 +1,19 @@
 package config
 
 type hookConfig struct {
-	Exec hookExecConfig `toml:"exec" mapstructure:"exec" json:"exec"`
+	Exec    hookExecConfig      `toml:"exec" mapstructure:"exec" json:"exec"`
+	Webhook []hookWebhookConfig `toml:"webhook" mapstructure:"webhook" json:"webhook"`
+}
+
+type hookWebhookConfig struct {
+	Name   string `toml:"name" mapstructure:"name" json:"name"`
+	URL    string `toml:"url" mapstructure:"url" json:"url"`
+	Secret string `toml:"secret" mapstructure:"secret" json:"secret"` // 用于 HMAC-SHA256 签名, 为空时不签名
+	// 要发送的事件类型, 为空时发送除 progress 以外的所有事件
+	Events  []string          `toml:"events" mapstructure:"events" json:"events"`
+	Headers map[string]string `toml:"headers" mapstructure:"headers" json:"headers"`
+	Timeout int               `toml:"timeout" mapstructure:"timeout" json:"timeout"` // 单次请求超时 (秒), 0 为 10 秒
+	Retry   int               `toml:"retry" mapstructure:"retry" json:"retry"`       // 失败后的重试次数
 }
 
 type hookExecConfig struct {
@@ -10,13 +22,43 @@ type hookExecConfig struct {
 	TaskSuccess     string `toml:"task_success" mapstructure:"task_success" json:"task_success"`
 	TaskFail        string `toml:"task_fail" mapstructure:"task_fail" json:"task_fail"`
 	TaskCancel      string `toml:"task_cancel" mapstructure:"task_cancel" json:"task_cancel"`
+	// 单个命令的超时时间 (秒), 0 为不限制
+	Timeout int `toml:"timeout" mapstructure:"timeout" json:"timeout"`
 
-	// TaskTypes map[string]hookExecOnTypeConfig `toml:"task_types" mapstructure:"task_types" json:"task_types"` // [TODO]
+	// 按任务类型覆盖上面的命令, 键为任务类型 (tgfiles, tphpics)
+	TaskTypes map[string]hookExecOnTypeConfig `toml:"task_types" mapstructure:"task_types" json:"task_types"`
 }
 
-// type hookExecOnTypeConfig struct {
-// 	TaskBeforeStart string `toml:"task_before_start" mapstructure:"task_before_start" json:"task_before_start"`
-// 	TaskSuccess     string `toml:"task_success" mapstructure:"task_success" json:"task_success"`
-// 	TaskFail        string `toml:"task_fail" mapstructure:"task_fail" json:"task_fail"`
-// 	TaskCancel      string `toml:"task_cancel" mapstructure:"task_cancel" json:"task_cancel"`
-// }
+type hookExecOnTypeConfig struct {
+	TaskBeforeStart string `toml:"task_before_start" mapstructure:"task_before_start" json:"task_before_start"`
+	TaskSuccess     string `toml:"task_success" mapstructure:"task_success" json:"task_success"`
+	TaskFail        string `toml:"task_fail" mapstructure:"task_fail" json:"task_fail"`
+	TaskCancel      string `toml:"task_cancel" mapstructure:"task_cancel" json:"task_cancel"`
+}
+
+// 获取任务类型对应的命令, 该类型未配置时使用全局命令
+func (c hookExecConfig) ForType(taskType string) hookExecOnTypeConfig {
+	hooks := hookExecOnTypeConfig{
+		TaskBeforeStart: c.TaskBeforeStart,
+		TaskSuccess:     c.TaskSuccess,
+		TaskFail:        c.TaskFail,
+		TaskCancel:      c.TaskCancel,
+	}
+	override, ok := c.TaskTypes[taskType]
+	if !ok {
+		return hooks
+	}
+	if override.TaskBeforeStart != "" {
+		hooks.TaskBeforeStart = override.TaskBeforeStart
+	}
+	if override.TaskSuccess != "" {
+		hooks.TaskSuccess = override.TaskSuccess
+	}
+	if override.TaskFail != "" {
+		hooks.TaskFail = override.TaskFail
+	}
+	if override.TaskCancel != "" {
+		hooks.TaskCancel = override.TaskCancel
+	}
+	return hooks
+}
@@ -0,0 +1,20 @@
+package config
+
+import "testing"
+
+func TestHookExecConfigForType(t *testing.T) {
+	cfg := hookExecConfig{
+		TaskBeforeStart: "global-start",
+		TaskSuccess:     "global-success",
+		TaskTypes: map[string]hookExecOnTypeConfig{
+			"tphpics": {TaskSuccess: "tph-success"},
+		},
+	}
+	tph := cfg.ForType("tphpics")
+	if tph.TaskBeforeStart != "global-start" || tph.TaskSuccess != "tph-success" {
+		t.Fatalf("unexpected hooks for tphpics: %+v", tph)
+	}
+	if tg := cfg.ForType("tgfiles"); tg.TaskSuccess != "global-success" {
+		t.Fatalf("unexpected hooks for tgfiles: %+v", tg)
+	}
+}
@@ -0,0 +1,42 @@
+package config
+
+import (
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// 直链下载配置
+type httpConfig struct {
+	Connections int    `toml:"connections" mapstructure:"connections" json:"connections"` // 每个文件的最大连接数
+	UserAgent   string `toml:"user_agent" mapstructure:"user_agent" json:"user_agent"`
+	// 所有请求附加的请求头
+	Headers map[string]string `toml:"headers" mapstructure:"headers" json:"headers"`
+	// 按域名附加的 Cookie, 键为域名, 同时匹配其子域名
+	Cookies map[string]string `toml:"cookies" mapstructure:"cookies" json:"cookies"`
+}
+
+// 获取请求 rawURL 时使用的请求头, 包括匹配域名的 Cookie
+func (c httpConfig) HeadersFor(rawURL string) map[string]string {
+	headers := make(map[string]string, len(c.Headers)+1)
+	for k, v := range c.Headers {
+		headers[k] = v
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return headers
+	}
+	host := strings.ToLower(u.Hostname())
+	var cookies []string
+	for domain, cookie := range c.Cookies {
+		domain = strings.ToLower(strings.TrimPrefix(domain, "."))
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			cookies = append(cookies, cookie)
+		}
+	}
+	if len(cookies) > 0 {
+		slices.Sort(cookies)
+		headers["Cookie"] = strings.Join(cookies, "; ")
+	}
+	return headers
+}
@@ -0,0 +1,11 @@
+package config
+
+// 贴纸包保存配置
+type stickerConfig struct {
+	// 打包为 zip 文件保存, 为 false 时保存为目录
+	Zip bool `toml:"zip" mapstructure:"zip" json:"zip"`
+	// 同时生成 PNG/GIF 预览, 需要 ffmpeg
+	Preview bool `toml:"preview" mapstructure:"preview" json:"preview"`
+	// ffmpeg 可执行文件路径
+	FFmpeg string `toml:"ffmpeg" mapstructure:"ffmpeg" json:"ffmpeg"`
+}
@@ -0,0 +1,11 @@
+package config
+
+// Telegraph 文章保存配置
+type telegraphConfig struct {
+	// 保存完整文章 (HTML 和 Markdown 以及其中的图片和视频), 为 false 时只保存图片
+	Article bool `toml:"article" mapstructure:"article" json:"article"`
+	// 同时生成 EPUB 电子书
+	EPUB bool `toml:"epub" mapstructure:"epub" json:"epub"`
+	// 使用 yt-dlp 下载文章中嵌入的外部视频, 需要启用 yt-dlp
+	Embeds bool `toml:"embeds" mapstructure:"embeds" json:"embeds"`
+}
@@ -1,5 +1,8 @@
 package config
 
 type tempConfig struct {
-	BasePath string `toml:"base_path" mapstructure:"base_path" json:"base_path"`
+	BasePath    string `toml:"base_path" mapstructure:"base_path" json:"base_path"`
+	Quota       int64  `toml:"quota" mapstructure:"quota" json:"quota"`                      // 缓存文件夹总大小上限 (MB), 0 为不限制
+	MinFree     int64  `toml:"min_free" mapstructure:"min_free" json:"min_free"`             // 磁盘需要保留的最小剩余空间 (MB)
+	WaitTimeout int    `toml:"wait_timeout" mapstructure:"wait_timeout" json:"wait_timeout"` // 空间不足时等待的最长时间 (秒), 0 为一直等待
 }
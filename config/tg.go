@@ -7,6 +7,10 @@ type telegramConfig struct {
 	Proxy    tgProxyConfig `toml:"proxy" mapstructure:"proxy"`
 	RpcRetry int           `toml:"rpc_retry" mapstructure:"rpc_retry" json:"rpc_retry"`
 	Userbot  userbotConfig `toml:"userbot" mapstructure:"userbot" json:"userbot"` // [TODO]
+
+	DownloadPool downloadPoolConfig `toml:"download_pool" mapstructure:"download_pool" json:"download_pool"`
+	// 保存视频时同时保存其他清晰度和封面
+	SaveAlternatives bool `toml:"save_alternatives" mapstructure:"save_alternatives" json:"save_alternatives"`
 }
 
 type userbotConfig struct {
@@ -14,6 +18,13 @@ type userbotConfig struct {
 	Session string `toml:"session" mapstructure:"session"`
 }
 
+// 多账号下载, bot 和 userbot 总是会加入账号池
+type downloadPoolConfig struct {
+	Enable   bool     `toml:"enable" mapstructure:"enable" json:"enable"`
+	Bots     []string `toml:"bots" mapstructure:"bots" json:"bots"`             // 额外的 bot token
+	Sessions []string `toml:"sessions" mapstructure:"sessions" json:"sessions"` // 额外的用户会话文件
+}
+
 type tgProxyConfig struct {
 	Enable bool   `toml:"enable" mapstructure:"enable"`
 	URL    string `toml:"url" mapstructure:"url"`
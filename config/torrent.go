@@ -0,0 +1,15 @@
+package config
+
+// BitTorrent / 磁力链接下载配置
+type torrentConfig struct {
+	Enable     bool `toml:"enable" mapstructure:"enable" json:"enable"`
+	ListenPort int  `toml:"listen_port" mapstructure:"listen_port" json:"listen_port"` // 0 为随机端口
+	NoDHT      bool `toml:"no_dht" mapstructure:"no_dht" json:"no_dht"`
+	// 附加到每个种子的 tracker
+	Trackers []string `toml:"trackers" mapstructure:"trackers" json:"trackers"`
+	// 获取磁力链接种子信息的超时时间, 单位秒
+	MetadataTimeout int `toml:"metadata_timeout" mapstructure:"metadata_timeout" json:"metadata_timeout"`
+	// 下载完成后继续做种, 直到分享率达到 SeedRatio 或做种时间达到 SeedTime 分钟. 都为 0 时不做种
+	SeedRatio float64 `toml:"seed_ratio" mapstructure:"seed_ratio" json:"seed_ratio"`
+	SeedTime  int     `toml:"seed_time" mapstructure:"seed_time" json:"seed_time"`
+}
@@ -0,0 +1,26 @@
+package config
+
+type transferConfig struct {
+	PerUser  int `toml:"per_user" mapstructure:"per_user" json:"per_user"`    // 单个用户同时传输的文件数上限, 0 为不限制
+	MaxTasks int `toml:"max_tasks" mapstructure:"max_tasks" json:"max_tasks"` // 同时执行的任务数, 0 为 workers 的两倍
+
+	AdaptiveThreads bool `toml:"adaptive_threads" mapstructure:"adaptive_threads" json:"adaptive_threads"` // 根据下载速度自动调整单个文件的下载线程数
+	MinThreads      int  `toml:"min_threads" mapstructure:"min_threads" json:"min_threads"`                // 自适应线程数下限
+	MaxThreads      int  `toml:"max_threads" mapstructure:"max_threads" json:"max_threads"`                // 自适应线程数上限, 0 为 threads 的两倍
+}
+
+// 同时执行的任务数, 任务内的每个文件仍需要获取全局传输槽位 (workers) 后才会开始传输
+func (c *Config) GetMaxTasks() int {
+	if c.Transfer.MaxTasks > 0 {
+		return c.Transfer.MaxTasks
+	}
+	return c.Workers * 2
+}
+
+// 自适应下载线程数的上限
+func (c *Config) GetMaxThreads() int {
+	if c.Transfer.MaxThreads > 0 {
+		return c.Transfer.MaxThreads
+	}
+	return c.Threads * 2
+}
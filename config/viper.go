@@ -21,15 +21,24 @@ type Config struct {
 	NoCleanCache bool   `toml:"no_clean_cache" mapstructure:"no_clean_cache" json:"no_clean_cache"`
 	Threads      int    `toml:"threads" mapstructure:"threads" json:"threads"`
 	Stream       bool   `toml:"stream" mapstructure:"stream" json:"stream"`
-
-	Cache    cacheConfig             `toml:"cache" mapstructure:"cache" json:"cache"`
-	Users    []userConfig            `toml:"users" mapstructure:"users" json:"users"`
-	Temp     tempConfig              `toml:"temp" mapstructure:"temp"`
-	DB       dbConfig                `toml:"db" mapstructure:"db"`
-	Telegram telegramConfig          `toml:"telegram" mapstructure:"telegram"`
-	Storages []storage.StorageConfig `toml:"-" mapstructure:"-" json:"storages"`
-	Hook     hookConfig              `toml:"hook" mapstructure:"hook" json:"hook"`
-	AI       AIConfig                `toml:"ai" mapstructure:"ai" json:"ai"`
+	// 关闭时等待正在执行的任务完成的最长时间 (秒), 超时后中断任务并保存, 下次启动时恢复
+	ShutdownTimeout int `toml:"shutdown_timeout" mapstructure:"shutdown_timeout" json:"shutdown_timeout"`
+
+	Cache     cacheConfig             `toml:"cache" mapstructure:"cache" json:"cache"`
+	Users     []userConfig            `toml:"users" mapstructure:"users" json:"users"`
+	Temp      tempConfig              `toml:"temp" mapstructure:"temp"`
+	DB        dbConfig                `toml:"db" mapstructure:"db"`
+	Telegram  telegramConfig          `toml:"telegram" mapstructure:"telegram"`
+	Storages  []storage.StorageConfig `toml:"-" mapstructure:"-" json:"storages"`
+	Hook      hookConfig              `toml:"hook" mapstructure:"hook" json:"hook"`
+	Transfer  transferConfig          `toml:"transfer" mapstructure:"transfer" json:"transfer"`
+	Dashboard dashboardConfig         `toml:"dashboard" mapstructure:"dashboard" json:"dashboard"`
+	HTTP      httpConfig              `toml:"http" mapstructure:"http" json:"http"`
+	Ytdlp     ytdlpConfig             `toml:"ytdlp" mapstructure:"ytdlp" json:"ytdlp"`
+	Torrent   torrentConfig           `toml:"torrent" mapstructure:"torrent" json:"torrent"`
+	Telegraph telegraphConfig         `toml:"telegraph" mapstructure:"telegraph" json:"telegraph"`
+	Sticker   stickerConfig           `toml:"sticker" mapstructure:"sticker" json:"sticker"`
+	AI        AIConfig                `toml:"ai" mapstructure:"ai" json:"ai"`
 }
 
 var Cfg *Config = &Config{}
@@ -59,6 +68,32 @@ func Init(ctx context.Context) error {
 		"workers": 3,
 		"retry":   3,
 		"threads": 4,
+		// 优雅关闭
+		"shutdown_timeout": 60,
+		// 传输并发
+		"transfer.per_user":  0,
+		"transfer.max_tasks": 0,
+		// 自适应下载线程
+		"transfer.adaptive_threads": true,
+		"transfer.min_threads":      1,
+		"transfer.max_threads":      0,
+
+		// 任务面板
+		"dashboard.interval":      3,
+		"dashboard.keep_finished": 5,
+
+		// 直链下载
+		"http.connections": 4,
+
+		// yt-dlp
+		"ytdlp.path":   "yt-dlp",
+		"ytdlp.format": "bv*+ba/b",
+
+		// BitTorrent
+		"torrent.metadata_timeout": 120,
+
+		// 贴纸包
+		"sticker.ffmpeg": "ffmpeg",
 
 		// 缓存配置
 		"cache.ttl":          86400,
@@ -66,14 +101,18 @@ func Init(ctx context.Context) error {
 		"cache.max_cost":     1e6,
 
 		// Telegram
-		"telegram.app_id":          1025907,
-		"telegram.app_hash":        "452b0359b988148995f22ff0f4229750",
-		"telegram.rpc_retry":       5,
-		"telegram.userbot.enable":  false,
-		"telegram.userbot.session": "data/usersession.db",
+		"telegram.app_id":               1025907,
+		"telegram.app_hash":             "452b0359b988148995f22ff0f4229750",
+		"telegram.rpc_retry":            5,
+		"telegram.userbot.enable":       false,
+		"telegram.userbot.session":      "data/usersession.db",
+		"telegram.download_pool.enable": false,
 
 		// 临时目录
-		"temp.base_path": "cache/",
+		"temp.base_path":    "cache/",
+		"temp.quota":        0,
+		"temp.min_free":     256,
+		"temp.wait_timeout": 1800,
 
 		// 数据库
 		"db.path":    "data/saveany.db",
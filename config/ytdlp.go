@@ -0,0 +1,37 @@
+package config
+
+import (
+	"net/url"
+	"strings"
+)
+
+// 使用本地安装的 yt-dlp 下载视频网站链接
+type ytdlpConfig struct {
+	Enable bool   `toml:"enable" mapstructure:"enable" json:"enable"`
+	Path   string `toml:"path" mapstructure:"path" json:"path"` // yt-dlp 可执行文件路径
+	// 交给 yt-dlp 处理的域名, 同时匹配其子域名
+	Domains []string `toml:"domains" mapstructure:"domains" json:"domains"`
+	// 静默模式下使用的格式
+	Format string `toml:"format" mapstructure:"format" json:"format"`
+	// 附加的命令行参数, 例如 --cookies
+	Args []string `toml:"args" mapstructure:"args" json:"args"`
+}
+
+// 链接是否应该交给 yt-dlp 处理
+func (c ytdlpConfig) Match(rawURL string) bool {
+	if !c.Enable {
+		return false
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, domain := range c.Domains {
+		domain = strings.ToLower(strings.TrimPrefix(domain, "."))
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
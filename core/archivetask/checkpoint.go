@@ -0,0 +1,43 @@
+package archivetask
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/krau/SaveAny-Bot/core"
+)
+
+// 归档任务保存的 core.Checkpoint.Kind
+const CheckpointKind = "archive"
+
+// 恢复归档任务所需的数据. 归档进度保存在数据库中, 恢复后从最后一条已归档的消息继续
+type CheckpointData struct {
+	ArchiveID         uint      `json:"archive_id"`
+	Title             string    `json:"title"`
+	Userbot           bool      `json:"userbot"`
+	From              time.Time `json:"from"`
+	To                time.Time `json:"to"`
+	ProgressMessageID int       `json:"progress_message_id,omitempty"` // 恢复后继续使用的进度消息
+}
+
+func (t *Task) Checkpoint() (*core.Checkpoint, error) {
+	data := CheckpointData{
+		ArchiveID: t.Archive.ID,
+		Title:     t.ChatTitle,
+		Userbot:   t.Userbot,
+		From:      t.From,
+		To:        t.To,
+	}
+	if p, ok := t.Progress.(*Progress); ok {
+		data.ProgressMessageID = p.MessageID
+	}
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	return &core.Checkpoint{TaskID: t.ID, UserID: t.UserID, Kind: CheckpointKind, Data: raw}, nil
+}
+
+func (t *Task) NotifyShutdown() {
+	t.Abort(core.ErrShutdown)
+}
@@ -0,0 +1,250 @@
+package archivetask
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/gabriel-vasile/mimetype"
+	"github.com/gotd/td/tg"
+	"github.com/krau/SaveAny-Bot/config"
+	"github.com/krau/SaveAny-Bot/core"
+	"github.com/krau/SaveAny-Bot/database"
+	"github.com/krau/SaveAny-Bot/pkg/archive"
+	"github.com/krau/SaveAny-Bot/pkg/slotpool"
+	"github.com/krau/SaveAny-Bot/pkg/tfile"
+	"github.com/krau/SaveAny-Bot/storage"
+	"golang.org/x/sync/errgroup"
+)
+
+const recordsFileName = "messages.jsonl"
+
+// 从上次归档的最后一条消息开始分页获取历史, 每页的媒体保存后将记录追加到本地并记录进度,
+// 全部完成后将所有记录生成 messages.jsonl 和静态页面保存到存储
+func (t *Task) Execute(ctx context.Context) (err error) {
+	logger := log.FromContext(ctx)
+	logger.Infof("Starting archive task for chat %d", t.Archive.ChatID)
+	if t.Progress != nil {
+		t.Progress.OnStart(ctx, t)
+	}
+	defer func() {
+		if err != nil {
+			logger.Errorf("Error during archive task execution: %v", err)
+		} else {
+			logger.Infof("Archive task for chat %d completed, %d messages archived", t.Archive.ChatID, t.archived.Load())
+		}
+		if t.Progress != nil {
+			t.Progress.OnDone(ctx, t, err)
+		}
+	}()
+	if err = os.MkdirAll(filepath.Dir(t.recordsPath), 0o755); err != nil {
+		return fmt.Errorf("failed to create records dir: %w", err)
+	}
+	if err = os.MkdirAll(t.cacheDir, 0o755); err != nil {
+		return fmt.Errorf("failed to create cache dir: %w", err)
+	}
+	defer func() {
+		if err := os.RemoveAll(t.cacheDir); err != nil {
+			logger.Errorf("Failed to remove cache dir: %v", err)
+		}
+	}()
+	if err = t.prepare(ctx); err != nil {
+		return err
+	}
+
+	for {
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+		afterID := int(t.lastID.Load())
+		var page *historyPage
+		page, err = t.nextPage(ctx, afterID)
+		if err != nil {
+			return err
+		}
+		if len(page.messages) == 0 {
+			break
+		}
+		records, lastID, end := t.collect(page)
+		if err = t.saveMedia(ctx, page, records); err != nil {
+			return err
+		}
+		if err = t.appendRecords(records); err != nil {
+			return fmt.Errorf("failed to write records: %w", err)
+		}
+		messages := t.Archive.Messages + int(t.archived.Add(int64(len(records))))
+		if err = database.UpdateChatArchiveProgress(ctx, t.Archive.ID, lastID, messages); err != nil {
+			return fmt.Errorf("failed to save archive progress: %w", err)
+		}
+		t.lastID.Store(int64(lastID))
+		core.PublishProgress(ctx, int64(lastID), int64(t.latestID))
+		if t.Progress != nil {
+			t.Progress.OnProgress(ctx, t)
+		}
+		if end {
+			break
+		}
+	}
+	// 上次中断时已归档但未保存的记录也在本地, 没有新消息时同样重新生成
+	if t.Archive.Messages+int(t.archived.Load()) == 0 {
+		return nil
+	}
+	return t.export(ctx)
+}
+
+// 将一页消息转换为记录, 跳过日期范围之外的消息. 返回处理到的最后一条消息的 ID, 以及是否已超出结束日期
+func (t *Task) collect(page *historyPage) ([]archive.Record, int, bool) {
+	records := make([]archive.Record, 0, len(page.messages))
+	lastID := int(t.lastID.Load())
+	for _, msg := range page.messages {
+		record, ok := archive.FromMessage(msg, page.names)
+		if !ok {
+			continue
+		}
+		if !t.To.IsZero() && !record.Date.Before(t.To) {
+			return records, lastID, true
+		}
+		lastID = msg.GetID()
+		if !t.From.IsZero() && record.Date.Before(t.From) {
+			continue
+		}
+		records = append(records, record)
+	}
+	return records, lastID, false
+}
+
+// 下载记录中可保存的媒体并按规则保存, 在记录中填写保存的位置
+func (t *Task) saveMedia(ctx context.Context, page *historyPage, records []archive.Record) error {
+	messages := make(map[int]*tg.Message, len(page.messages))
+	for _, msg := range page.messages {
+		if m, ok := msg.(*tg.Message); ok {
+			messages[m.ID] = m
+		}
+	}
+	eg, gctx := errgroup.WithContext(ctx)
+	eg.SetLimit(config.Cfg.Workers)
+	for i := range records {
+		msg := messages[records[i].ID]
+		if msg == nil || records[i].Media == nil {
+			continue
+		}
+		file, err := t.mediaFile(msg)
+		if err != nil {
+			// 位置, 投票等没有文件的媒体
+			continue
+		}
+		eg.Go(func() error {
+			release, err := slotpool.Default().Acquire(gctx, t.UserID)
+			if err != nil {
+				return err
+			}
+			defer release()
+			stor, storPath, err := t.Route(gctx, file)
+			if err != nil {
+				return fmt.Errorf("failed to route media of message %d: %w", msg.ID, err)
+			}
+			if err := core.DownloadTGFile(gctx, file, filepath.Join(t.cacheDir, file.Name())); err != nil {
+				return fmt.Errorf("failed to download media of message %d: %w", msg.ID, err)
+			}
+			if err := t.saveLocal(gctx, stor, storPath, file.Name()); err != nil {
+				return err
+			}
+			media := records[i].Media
+			media.Name = file.Name()
+			if root := t.Archive.Path + "/"; stor.Name() == t.Storage.Name() && strings.HasPrefix(storPath, root) {
+				media.File = strings.TrimPrefix(storPath, root)
+			} else {
+				media.Location = fmt.Sprintf("[%s]:%s", stor.Name(), storPath)
+			}
+			t.mediaSaved.Add(1)
+			return nil
+		})
+	}
+	return eg.Wait()
+}
+
+// 消息中可下载的文件, 文件名以消息 ID 开头以免重复
+func (t *Task) mediaFile(msg *tg.Message) (tfile.TGFileMessage, error) {
+	file, err := tfile.FromMediaMessage(msg.Media, t.Client.Raw, msg)
+	if err != nil {
+		return nil, err
+	}
+	name := sanitizeName(file.Name())
+	if name == "" {
+		name = "file"
+		if doc, ok := msg.Media.(*tg.MessageMediaDocument); ok {
+			if d, ok := doc.Document.AsNotEmpty(); ok {
+				if mt := mimetype.Lookup(d.MimeType); mt != nil {
+					name += mt.Extension()
+				}
+			}
+		}
+	}
+	return tfile.FromMediaMessage(msg.Media, t.Client.Raw, msg,
+		tfile.WithName(fmt.Sprintf("%d_%s", msg.ID, name)),
+		tfile.WithMessageGetter(t.Client),
+	)
+}
+
+// 将缓存目录中的文件保存到存储, 完成后删除
+func (t *Task) saveLocal(ctx context.Context, stor storage.Storage, storPath, name string) error {
+	localPath := filepath.Join(t.cacheDir, name)
+	defer os.Remove(localPath)
+	return core.SaveLocalFile(ctx, stor.Save, storPath, localPath)
+}
+
+func (t *Task) appendRecords(records []archive.Record) error {
+	f, err := os.OpenFile(t.recordsPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if err := archive.WriteRecords(f, records); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// 将本地保存的所有记录生成 messages.jsonl 和静态页面保存到归档目录
+func (t *Task) export(ctx context.Context) error {
+	data, err := os.ReadFile(t.recordsPath)
+	if err != nil {
+		return fmt.Errorf("failed to read records: %w", err)
+	}
+	records, err := archive.ReadRecords(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to parse records: %w", err)
+	}
+	if err := t.saveBytes(ctx, data, recordsFileName); err != nil {
+		return err
+	}
+	title := fmt.Sprintf("%s · 归档于 %s", t.ChatTitle, time.Now().Format("2006-01-02 15:04"))
+	for _, page := range archive.RenderHTML(title, records) {
+		if err := t.saveBytes(ctx, page.Content, page.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 覆盖保存归档目录中的文件, 重复导出时替换上次生成的 messages.jsonl 和页面
+func (t *Task) saveBytes(ctx context.Context, data []byte, name string) error {
+	overwrite := func(ctx context.Context, r io.Reader, storPath string) error {
+		return storage.Overwrite(ctx, t.Storage, r, storPath)
+	}
+	return core.SaveBytes(ctx, overwrite, path.Join(t.Archive.Path, name), data)
+}
+
+// 通知用户任务未执行就被中止
+func (t *Task) Abort(err error) {
+	if t.Progress != nil {
+		t.Progress.OnDone(t.Ctx, t, err)
+	}
+}
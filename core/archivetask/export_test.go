@@ -0,0 +1,74 @@
+package archivetask
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/krau/SaveAny-Bot/config"
+	storcfg "github.com/krau/SaveAny-Bot/config/storage"
+	"github.com/krau/SaveAny-Bot/database"
+	"github.com/krau/SaveAny-Bot/pkg/archive"
+	"github.com/krau/SaveAny-Bot/storage/local"
+)
+
+func TestExportTwiceOverwritesLocalFiles(t *testing.T) {
+	oldRetry := config.Cfg.Retry
+	config.Cfg.Retry = 1
+	defer func() { config.Cfg.Retry = oldRetry }()
+
+	ctx := context.Background()
+	dir := t.TempDir()
+	stor := new(local.Local)
+	if err := stor.Init(ctx, &storcfg.LocalStorageConfig{BaseConfig: storcfg.BaseConfig{Name: "local"}, BasePath: dir}); err != nil {
+		t.Fatal(err)
+	}
+	archiveDir := stor.JoinStoragePath("chat_1")
+	task := &Task{
+		ChatTitle:   "chat",
+		Archive:     &database.ChatArchive{Path: archiveDir},
+		Storage:     stor,
+		recordsPath: filepath.Join(t.TempDir(), "1.jsonl"),
+	}
+
+	date := time.Unix(1700000000, 0)
+	if err := task.appendRecords([]archive.Record{{ID: 1, Date: date, Text: "first"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := task.export(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := task.appendRecords([]archive.Record{{ID: 2, Date: date, Text: "second"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := task.export(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := os.ReadDir(archiveDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	if strings.Join(names, ",") != "index.html,messages.jsonl" {
+		t.Fatalf("archive dir contains %v, want only index.html and messages.jsonl", names)
+	}
+	data, err := os.ReadFile(filepath.Join(archiveDir, recordsFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, err := archive.ReadRecords(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("messages.jsonl has %d records, want 2", len(records))
+	}
+}
@@ -0,0 +1,143 @@
+package archivetask
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/gotd/td/tg"
+	"github.com/krau/SaveAny-Bot/common/utils/tgutil"
+)
+
+const (
+	// 每次获取的历史消息数
+	historyPageSize = tgutil.MessagePageSize
+	// Bot 按 ID 扫描时, 连续这么多页没有消息就认为已到达最新消息
+	botMaxEmptyPages = 10
+)
+
+// 一页历史消息, 按 ID 从小到大排列
+type historyPage struct {
+	messages []tg.MessageClass
+	names    *tgutil.PeerNames
+}
+
+func (t *Task) inputPeer() (tg.InputPeerClass, error) {
+	peer := t.Client.PeerStorage.GetInputPeerById(t.Archive.ChatID)
+	if peer == nil {
+		return nil, fmt.Errorf("peer not found: %d", t.Archive.ChatID)
+	}
+	if _, ok := peer.(*tg.InputPeerEmpty); ok {
+		return nil, fmt.Errorf("peer not found: %d", t.Archive.ChatID)
+	}
+	return peer, nil
+}
+
+// 首次归档且指定了开始日期时, 从该日期前的最后一条消息之后开始, 并获取最新消息的 ID.
+// Bot 无法获取历史, 从第一条消息开始按 ID 扫描
+func (t *Task) prepare(ctx context.Context) error {
+	if !t.Userbot {
+		return nil
+	}
+	peer, err := t.inputPeer()
+	if err != nil {
+		return err
+	}
+	latest, err := t.getHistory(ctx, &tg.MessagesGetHistoryRequest{Peer: peer, Limit: 1})
+	if err != nil {
+		return err
+	}
+	if len(latest.messages) > 0 {
+		t.latestID = latest.messages[0].GetID()
+	}
+	if t.lastID.Load() != 0 || t.From.IsZero() {
+		return nil
+	}
+	before, err := t.getHistory(ctx, &tg.MessagesGetHistoryRequest{
+		Peer:       peer,
+		OffsetDate: int(t.From.Unix()),
+		Limit:      1,
+	})
+	if err != nil {
+		return err
+	}
+	if len(before.messages) > 0 {
+		t.lastID.Store(int64(before.messages[0].GetID()))
+	}
+	return nil
+}
+
+// 获取 ID 大于 afterID 的一页消息, 没有更多消息时返回空页
+func (t *Task) nextPage(ctx context.Context, afterID int) (*historyPage, error) {
+	peer, err := t.inputPeer()
+	if err != nil {
+		return nil, err
+	}
+	if t.Userbot {
+		// 从 afterID 之后的消息开始向新消息方向获取
+		return t.getHistory(ctx, &tg.MessagesGetHistoryRequest{
+			Peer:      peer,
+			OffsetID:  afterID + 1,
+			AddOffset: -historyPageSize,
+			Limit:     historyPageSize,
+			MinID:     afterID,
+		})
+	}
+	channel, ok := peer.(*tg.InputPeerChannel)
+	if !ok {
+		return nil, fmt.Errorf("bot can only archive channels and supergroups")
+	}
+	for range botMaxEmptyPages {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		ids := make([]int, 0, historyPageSize)
+		for id := afterID + 1; id <= afterID+historyPageSize; id++ {
+			ids = append(ids, id)
+		}
+		res, err := t.Client.Raw.ChannelsGetMessages(ctx, &tg.ChannelsGetMessagesRequest{
+			Channel: &tg.InputChannel{ChannelID: channel.ChannelID, AccessHash: channel.AccessHash},
+			ID:      tgutil.InputMessageClassSliceFromInt(ids),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get messages %d-%d: %w", ids[0], ids[len(ids)-1], err)
+		}
+		page, err := parsePage(res, afterID)
+		if err != nil {
+			return nil, err
+		}
+		if len(page.messages) > 0 {
+			return page, nil
+		}
+		afterID += historyPageSize
+	}
+	return &historyPage{}, nil
+}
+
+func (t *Task) getHistory(ctx context.Context, req *tg.MessagesGetHistoryRequest) (*historyPage, error) {
+	res, err := t.Client.Raw.MessagesGetHistory(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get history: %w", err)
+	}
+	return parsePage(res, req.MinID)
+}
+
+// 取出 ID 大于 afterID 的消息, 包括服务消息, 按 ID 从小到大排列
+func parsePage(res tg.MessagesMessagesClass, afterID int) (*historyPage, error) {
+	modified, ok := res.AsModified()
+	if !ok {
+		return nil, fmt.Errorf("unexpected messages type: %T", res)
+	}
+	page := &historyPage{names: tgutil.NewPeerNames()}
+	page.names.Add(modified.GetUsers(), modified.GetChats())
+	for _, msg := range modified.GetMessages() {
+		if _, empty := msg.(*tg.MessageEmpty); empty || msg.GetID() <= afterID {
+			continue
+		}
+		page.messages = append(page.messages, msg)
+	}
+	slices.SortFunc(page.messages, func(a, b tg.MessageClass) int {
+		return a.GetID() - b.GetID()
+	})
+	return page, nil
+}
@@ -0,0 +1,120 @@
+package archivetask
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/gotd/td/tg"
+	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/msgelem"
+	"github.com/krau/SaveAny-Bot/common/utils/tgutil"
+	"github.com/krau/SaveAny-Bot/core"
+)
+
+type ProgressTracker interface {
+	OnStart(ctx context.Context, info TaskInfo)
+	OnProgress(ctx context.Context, info TaskInfo)
+	OnDone(ctx context.Context, info TaskInfo, err error)
+}
+
+// 进度消息的最小更新间隔
+const progressUpdateInterval = 3 * time.Second
+
+type Progress struct {
+	MessageID  int
+	ChatID     int64
+	start      time.Time
+	mu         sync.Mutex
+	lastUpdate time.Time
+}
+
+func (p *Progress) edit(ctx context.Context, info TaskInfo, template *msgelem.MessageTemplate, markup tg.ReplyMarkupClass) {
+	text, entities := template.BuildFormattedMessage()
+	ext := tgutil.ExtFromContext(ctx)
+	if ext == nil || p.MessageID == 0 {
+		return
+	}
+	peer := &tg.InputPeerUser{UserID: p.ChatID}
+	if err := msgelem.EditWithFormattedText(ext, peer, p.MessageID, text, entities, markup); err != nil {
+		log.Warn("Failed to edit message for archive task", "error", err, "task_id", info.TaskID())
+	}
+}
+
+func (p *Progress) OnStart(ctx context.Context, info TaskInfo) {
+	p.start = time.Now()
+	template := msgelem.NewInfoTemplate("🚀 开始归档聊天", "")
+	template.AddItem("💬", "聊天", info.Title(), msgelem.ItemTypeCode)
+	if id := info.LastMessageID(); id > 0 {
+		template.AddItem("⏩", "继续自消息", fmt.Sprintf("%d", id), msgelem.ItemTypeText)
+	}
+	template.AddItem("📂", "保存路径", fmt.Sprintf("[%s]:%s", info.StorageName(), info.StoragePath()), msgelem.ItemTypeCode)
+	p.edit(ctx, info, template, &tg.ReplyInlineMarkup{
+		Rows: []tg.KeyboardButtonRow{{Buttons: []tg.KeyboardButtonClass{tgutil.BuildCancelButton(info.TaskID())}}},
+	})
+}
+
+func (p *Progress) OnProgress(ctx context.Context, info TaskInfo) {
+	p.mu.Lock()
+	if time.Since(p.lastUpdate) < progressUpdateInterval {
+		p.mu.Unlock()
+		return
+	}
+	p.lastUpdate = time.Now()
+	p.mu.Unlock()
+
+	template := msgelem.NewProcessingTemplate("正在归档聊天", "")
+	template.AddItem("💬", "聊天", info.Title(), msgelem.ItemTypeCode)
+	if latest := info.LatestMessageID(); latest > 0 {
+		template.AddProgressBar("📊", "归档进度", int64(info.LastMessageID()), int64(latest), 12)
+	}
+	template.AddItem("📨", "已归档消息", fmt.Sprintf("%d", info.Archived()), msgelem.ItemTypeText)
+	template.AddItem("🖼", "已保存媒体", fmt.Sprintf("%d", info.MediaSaved()), msgelem.ItemTypeText)
+	p.edit(ctx, info, template, &tg.ReplyInlineMarkup{
+		Rows: []tg.KeyboardButtonRow{{Buttons: []tg.KeyboardButtonClass{
+			tgutil.BuildCancelButton(info.TaskID()),
+			tgutil.BuildDetailButton(info.TaskID()),
+		}}},
+	})
+}
+
+func (p *Progress) OnDone(ctx context.Context, info TaskInfo, err error) {
+	if err != nil {
+		log.FromContext(ctx).Errorf("Progress error for archive [%s]: %v", info.Title(), err)
+	}
+	var template *msgelem.MessageTemplate
+	switch {
+	case core.IsShutdown(ctx, err):
+		template = msgelem.NewInfoTemplate("⏸ Bot 正在重启", "任务将在重启后自动恢复")
+		template.AddItem("💬", "聊天", info.Title(), msgelem.ItemTypeCode)
+	case errors.Is(err, context.Canceled):
+		template = msgelem.NewErrorTemplate("任务已取消", "已归档的消息会在下次归档时一并保存")
+		template.AddItem("💬", "聊天", info.Title(), msgelem.ItemTypeCode)
+	case err != nil:
+		template = msgelem.NewErrorTemplate("归档失败", "再次归档时将从中断处继续")
+		template.AddItem("💬", "聊天", info.Title(), msgelem.ItemTypeCode)
+		template.AddItem("❗", "错误信息", err.Error(), msgelem.ItemTypeText)
+	case info.Archived() == 0:
+		template = msgelem.NewSuccessTemplate("没有新消息", "上次归档之后没有需要归档的消息")
+		template.AddItem("💬", "聊天", info.Title(), msgelem.ItemTypeCode)
+	default:
+		template = msgelem.NewSuccessTemplate("归档完成", "")
+		template.AddItem("💬", "聊天", info.Title(), msgelem.ItemTypeCode)
+		template.AddItem("📨", "归档消息", fmt.Sprintf("%d", info.Archived()), msgelem.ItemTypeText)
+		template.AddItem("🖼", "保存媒体", fmt.Sprintf("%d", info.MediaSaved()), msgelem.ItemTypeText)
+		template.AddItem("📂", "保存路径", fmt.Sprintf("[%s]:%s", info.StorageName(), info.StoragePath()), msgelem.ItemTypeCode)
+		if !p.start.IsZero() {
+			template.AddItem("⌚", "总用时", msgelem.FormatDuration(time.Since(p.start)), msgelem.ItemTypeText)
+		}
+	}
+	p.edit(ctx, info, template, nil)
+}
+
+func NewProgressTrack(messageID int, chatID int64) ProgressTracker {
+	return &Progress{
+		MessageID: messageID,
+		ChatID:    chatID,
+	}
+}
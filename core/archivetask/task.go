@@ -0,0 +1,110 @@
+package archivetask
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/celestix/gotgproto/ext"
+	"github.com/krau/SaveAny-Bot/config"
+	"github.com/krau/SaveAny-Bot/database"
+	"github.com/krau/SaveAny-Bot/pkg/ai"
+	"github.com/krau/SaveAny-Bot/pkg/enums/tasktype"
+	"github.com/krau/SaveAny-Bot/pkg/tfile"
+	"github.com/krau/SaveAny-Bot/storage"
+)
+
+// 决定媒体文件保存的存储和完整路径, 由调用方按用户的规则实现
+type Router func(ctx context.Context, file tfile.TGFileMessage) (storage.Storage, string, error)
+
+type Task struct {
+	ID        string
+	Ctx       context.Context
+	UserID    int64        // telegram user id of the task owner
+	Client    *ext.Context // 获取历史消息和下载媒体的客户端
+	Userbot   bool         // Client 是否为 userbot, Bot 只能按 ID 扫描频道和超级群组
+	ChatTitle string       // 聊天的名称
+	From      time.Time    // 只归档此时间之后的消息, 零值表示不限
+	To        time.Time    // 只归档此时间之前的消息, 零值表示不限
+	Archive   *database.ChatArchive
+	Storage   storage.Storage // 保存 messages.jsonl 和静态页面的存储
+	Route     Router
+	Progress  ProgressTracker
+
+	cacheDir    string
+	recordsPath string // 本地保存的所有已归档记录, 用于生成完整的 messages.jsonl 和页面
+	latestID    int    // 聊天中最新消息的 ID, 未知时为 0
+	lastID      atomic.Int64
+	archived    atomic.Int64
+	mediaSaved  atomic.Int64
+}
+
+func (t *Task) Type() tasktype.TaskType {
+	return tasktype.TaskTypeArchive
+}
+
+func NewTask(
+	id string,
+	ctx context.Context,
+	userID int64,
+	client *ext.Context,
+	userbot bool,
+	title string,
+	from, to time.Time,
+	archive *database.ChatArchive,
+	stor storage.Storage,
+	route Router,
+	progress ProgressTracker,
+) (*Task, error) {
+	cacheDir, err := filepath.Abs(filepath.Join(config.Cfg.Temp.BasePath, fmt.Sprintf("archive_%s", id)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get absolute path for cache: %w", err)
+	}
+	t := &Task{
+		ID:          id,
+		Ctx:         ctx,
+		UserID:      userID,
+		Client:      client,
+		Userbot:     userbot,
+		ChatTitle:   title,
+		From:        from,
+		To:          to,
+		Archive:     archive,
+		Storage:     stor,
+		Route:       route,
+		Progress:    progress,
+		cacheDir:    cacheDir,
+		recordsPath: RecordsPath(archive.ID),
+	}
+	t.lastID.Store(int64(archive.LastMessageID))
+	return t, nil
+}
+
+// 聊天归档在本地保存的记录文件, 位于数据库所在目录
+func RecordsPath(archiveID uint) string {
+	return filepath.Join(filepath.Dir(config.Cfg.DB.Path), "archives", fmt.Sprintf("%d.jsonl", archiveID))
+}
+
+// 清理后的文件名, 名称为空时返回空字符串以便调用方使用自己的默认名称
+func sanitizeName(name string) string {
+	name = strings.TrimSpace(strings.ReplaceAll(name, "\n", " "))
+	if name == "" {
+		return ""
+	}
+	return ai.SanitizeFilename(name)
+}
+
+// 归档目录的名称, 由聊天名称和 ID 组成
+func DirName(title string, chatID int64) string {
+	name := sanitizeName(title)
+	if runes := []rune(name); len(runes) > 48 {
+		name = strings.TrimSpace(string(runes[:48]))
+	}
+	if name == "" {
+		return fmt.Sprintf("archive_%d", chatID)
+	}
+	return fmt.Sprintf("%s_%d", name, chatID)
+}
@@ -0,0 +1,60 @@
+package archivetask
+
+import "github.com/krau/SaveAny-Bot/core"
+
+type TaskInfo interface {
+	TaskID() string
+	Title() string
+	Archived() int64   // 本次归档的消息数
+	MediaSaved() int64 // 本次保存的媒体数
+	LastMessageID() int
+	LatestMessageID() int // 聊天中最新消息的 ID, 未知时为 0
+	StorageName() string
+	StoragePath() string
+}
+
+func (t *Task) TaskID() string {
+	return t.ID
+}
+
+func (t *Task) Title() string {
+	return t.ChatTitle
+}
+
+func (t *Task) Archived() int64 {
+	return t.archived.Load()
+}
+
+func (t *Task) MediaSaved() int64 {
+	return t.mediaSaved.Load()
+}
+
+func (t *Task) LastMessageID() int {
+	return int(t.lastID.Load())
+}
+
+func (t *Task) LatestMessageID() int {
+	return t.latestID
+}
+
+func (t *Task) StorageName() string {
+	return t.Storage.Name()
+}
+
+// 归档目录
+func (t *Task) StoragePath() string {
+	return t.Archive.Path
+}
+
+func (t *Task) Meta() core.TaskMeta {
+	return core.TaskMeta{
+		UserID:        t.UserID,
+		Title:         t.ChatTitle,
+		StorageName:   t.StorageName(),
+		StorageType:   t.Storage.Type().String(),
+		StoragePath:   t.StoragePath(),
+		LocalPath:     t.cacheDir,
+		SourceChatID:  t.Archive.ChatID,
+		CountProgress: true,
+	}
+}
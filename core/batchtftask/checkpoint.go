@@ -0,0 +1,72 @@
+package batchtftask
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/krau/SaveAny-Bot/core"
+	"github.com/krau/SaveAny-Bot/pkg/tfile"
+)
+
+// 批量任务保存的 core.Checkpoint.Kind
+const CheckpointKind = "batch_tgfile"
+
+// 恢复批量任务中一个文件所需的数据
+type ElementCheckpoint struct {
+	ChatID      int64  `json:"chat_id"`               // 文件来源消息所在的 chat
+	MessageID   int    `json:"message_id"`            // 文件来源消息, 来自故事时为故事 ID
+	LocationID  int64  `json:"location_id,omitempty"` // 消息中有多个文件时用于找回同一个文件
+	Story       bool   `json:"story,omitempty"`
+	FileName    string `json:"file_name"`
+	StorageName string `json:"storage_name"`
+	Path        string `json:"path"`
+}
+
+// 恢复批量任务所需的数据, 只包含尚未成功保存的文件
+type CheckpointData struct {
+	Elements          []ElementCheckpoint `json:"elements"`
+	ProgressMessageID int                 `json:"progress_message_id,omitempty"` // 恢复后继续使用的进度消息
+}
+
+func (t *Task) Checkpoint() (*core.Checkpoint, error) {
+	t.mu.Lock()
+	data := CheckpointData{Elements: make([]ElementCheckpoint, 0, len(t.Elems))}
+	for _, elem := range t.Elems {
+		if err, done := t.finished[elem.ID]; done && (err == nil || errors.Is(err, ErrElementSkipped)) {
+			continue
+		}
+		chatID, msgID, ok := tfile.MessageSource(elem.File)
+		story := false
+		if !ok {
+			chatID, msgID, story = tfile.StorySource(elem.File)
+		}
+		if !ok && !story {
+			continue
+		}
+		data.Elements = append(data.Elements, ElementCheckpoint{
+			ChatID:      chatID,
+			MessageID:   msgID,
+			LocationID:  tfile.LocationID(elem.File),
+			Story:       story,
+			FileName:    elem.File.Name(),
+			StorageName: elem.Storage.Name(),
+			Path:        elem.Path,
+		})
+	}
+	t.mu.Unlock()
+	if len(data.Elements) == 0 {
+		return nil, errors.New("no element left to resume")
+	}
+	if p, ok := t.Progress.(*Progress); ok {
+		data.ProgressMessageID = p.MessageID
+	}
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	return &core.Checkpoint{TaskID: t.ID, UserID: t.UserID, Kind: CheckpointKind, Data: raw}, nil
+}
+
+func (t *Task) NotifyShutdown() {
+	t.Abort(core.ErrShutdown)
+}
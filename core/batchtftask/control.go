@@ -0,0 +1,109 @@
+package batchtftask
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrElementSkipped  = errors.New("element skipped by user")
+	ErrElementCanceled = errors.New("element canceled by user")
+	errElementRetry    = errors.New("element retry requested by user")
+	ErrElementNotFound = errors.New("element not found in task")
+)
+
+// 对批量任务中单个文件的操作
+type ElementAction string
+
+const (
+	ElementActionSkip   ElementAction = "skip"   // 跳过, 不计入失败
+	ElementActionCancel ElementAction = "cancel" // 取消, 计入失败, 可以稍后重试
+	ElementActionRetry  ElementAction = "retry"  // 中止当前传输并立即重新开始
+)
+
+// 调用方需持有锁
+func (t *Task) hasElement(elemID string) bool {
+	for _, elem := range t.Elems {
+		if elem.ID == elemID {
+			return true
+		}
+	}
+	return false
+}
+
+// 对单个文件执行操作. 正在处理的文件会被立即中止, 尚未开始的文件在开始前生效
+func (t *Task) ControlElement(elemID string, action ElementAction) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.hasElement(elemID) {
+		return ErrElementNotFound
+	}
+	var cause error
+	switch action {
+	case ElementActionSkip:
+		cause = ErrElementSkipped
+	case ElementActionCancel:
+		cause = ErrElementCanceled
+	case ElementActionRetry:
+		cause = errElementRetry
+	default:
+		return fmt.Errorf("unknown element action: %s", action)
+	}
+	if _, ok := t.finished[elemID]; ok {
+		return errors.New("element has already finished")
+	}
+	cancel, running := t.controls[elemID]
+	if !running {
+		if action == ElementActionRetry {
+			return errors.New("element is not being processed")
+		}
+		t.pending[elemID] = cause
+		return nil
+	}
+	cancel(cause)
+	return nil
+}
+
+func (t *Task) pendingCause(elemID string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.pending[elemID]
+}
+
+// 开始处理一个文件, 返回该文件的 context. 文件在开始前被跳过或取消时返回对应的错误
+func (t *Task) begin(ctx context.Context, elem *TaskElement) (context.Context, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if cause, ok := t.pending[elem.ID]; ok {
+		delete(t.pending, elem.ID)
+		return nil, cause
+	}
+	if t.processing[elem.ID] != nil {
+		return nil, fmt.Errorf("element with ID %s is already being processed", elem.ID)
+	}
+	ectx, cancel := context.WithCancelCause(ctx)
+	t.processing[elem.ID] = elem
+	t.controls[elem.ID] = cancel
+	return ectx, nil
+}
+
+func (t *Task) end(elemID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if cancel, ok := t.controls[elemID]; ok {
+		cancel(nil)
+	}
+	delete(t.processing, elemID)
+	delete(t.controls, elemID)
+}
+
+// 记录文件的最终结果, err 为 nil 或被跳过时不计入失败
+func (t *Task) finish(elemID string, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.finished[elemID] = err
+	if err != nil && !errors.Is(err, ErrElementSkipped) {
+		t.failed[elemID] = err
+	}
+}
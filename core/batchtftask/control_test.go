@@ -0,0 +1,91 @@
+package batchtftask
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/gotd/td/telegram/downloader"
+	"github.com/gotd/td/tg"
+)
+
+type fakeFile struct{ name string }
+
+func (f fakeFile) Location() tg.InputFileLocationClass { return nil }
+func (f fakeFile) Dler() downloader.Client             { return nil }
+func (f fakeFile) Size() int64                         { return 1 }
+func (f fakeFile) Name() string                        { return f.name }
+
+func newTestTask(n int) *Task {
+	elems := make([]TaskElement, 0, n)
+	for i := range n {
+		elems = append(elems, TaskElement{ID: fmt.Sprintf("e%d", i), File: fakeFile{name: fmt.Sprintf("f%d", i)}})
+	}
+	return NewBatchTGFileTask("t", context.Background(), 1, elems, nil, true)
+}
+
+func TestControlPendingElement(t *testing.T) {
+	task := newTestTask(2)
+	if err := task.ControlElement("e0", ElementActionRetry); err == nil {
+		t.Fatal("expected retry of a pending element to fail")
+	}
+	if err := task.ControlElement("e0", ElementActionSkip); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := task.begin(context.Background(), &task.Elems[0]); !errors.Is(err, ErrElementSkipped) {
+		t.Fatalf("expected skipped element not to start, got %v", err)
+	}
+	if err := task.ControlElement("missing", ElementActionSkip); !errors.Is(err, ErrElementNotFound) {
+		t.Fatalf("expected ErrElementNotFound, got %v", err)
+	}
+}
+
+func TestControlRunningElement(t *testing.T) {
+	task := newTestTask(2)
+	ctx, err := task.begin(context.Background(), &task.Elems[1])
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(task.Processing()) != 1 {
+		t.Fatal("expected element to be processing")
+	}
+	if err := task.ControlElement("e1", ElementActionCancel); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !errors.Is(context.Cause(ctx), ErrElementCanceled) {
+		t.Fatalf("expected element context to be canceled, got %v", context.Cause(ctx))
+	}
+	task.end("e1")
+	task.finish("e1", ErrElementCanceled)
+	task.finish("e0", ErrElementSkipped)
+	failed := task.Failed()
+	if len(failed) != 1 || failed[0].Element.ID != "e1" {
+		t.Fatalf("expected only the canceled element to be reported, got %v", failed)
+	}
+	if err := task.ControlElement("e1", ElementActionSkip); err == nil {
+		t.Fatal("expected control of a finished element to fail")
+	}
+}
+
+func TestProcessingConcurrentAccess(t *testing.T) {
+	task := newTestTask(16)
+	var wg sync.WaitGroup
+	for i := range task.Elems {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := task.begin(context.Background(), &task.Elems[i]); err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+			_ = task.Processing()
+			task.end(task.Elems[i].ID)
+		}()
+	}
+	wg.Wait()
+	if len(task.Processing()) != 0 {
+		t.Fatal("expected no element to be processing")
+	}
+}
@@ -2,63 +2,138 @@ package batchtftask
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path"
+	"sync/atomic"
 
 	"github.com/charmbracelet/log"
 	"github.com/duke-git/lancet/v2/retry"
 	"github.com/krau/SaveAny-Bot/common/utils/fsutil"
 	"github.com/krau/SaveAny-Bot/common/utils/ioutil"
 	"github.com/krau/SaveAny-Bot/config"
+	"github.com/krau/SaveAny-Bot/core"
+	"github.com/krau/SaveAny-Bot/pkg/diskquota"
 	"github.com/krau/SaveAny-Bot/pkg/enums/ctxkey"
+	"github.com/krau/SaveAny-Bot/pkg/slotpool"
 	"github.com/krau/SaveAny-Bot/pkg/tfile"
+	"github.com/krau/SaveAny-Bot/storage"
 	"golang.org/x/sync/errgroup"
 )
 
 func (t *Task) Execute(ctx context.Context) error {
 	logger := log.FromContext(ctx).WithPrefix(fmt.Sprintf("batch_file[%s]", t.ID))
 	logger.Info("Starting batch file task")
-	t.Progress.OnStart(ctx, t)
+	if t.Progress != nil {
+		t.Progress.OnStart(ctx, t)
+	}
+	// 限制同时等待槽位的协程数, 实际的传输并发由全局槽位池控制
 	workers := config.Cfg.Workers
 	eg, gctx := errgroup.WithContext(ctx)
 	eg.SetLimit(workers)
 	for _, elem := range t.Elems {
 		elem := elem
 		eg.Go(func() error {
-			if t.processing[elem.ID] != nil {
-				return fmt.Errorf("element with ID %s is already being processed", elem.ID)
+			// 尚未开始就被跳过或取消的文件不需要等待槽位
+			err := t.pendingCause(elem.ID)
+			if err == nil {
+				err = t.runElement(gctx, elem)
+			}
+			t.finish(elem.ID, err)
+			switch {
+			case err == nil, errors.Is(err, ErrElementSkipped):
+				return nil
+			case errors.Is(err, ErrElementCanceled):
+				logger.Infof("Element %s canceled by user", elem.FileName())
+				return nil
+			case t.IgnoreErrors && gctx.Err() == nil:
+				logger.Errorf("Failed to process element %s: %v", elem.FileName(), err)
+				return nil
 			}
-			t.processing[elem.ID] = &elem
-			defer func() {
-				delete(t.processing, elem.ID)
-			}()
-			return t.processElement(gctx, elem)
+			return err
 		})
 	}
 	err := eg.Wait()
+	if failed := len(t.Failed()); err == nil && failed > 0 {
+		err = fmt.Errorf("%d of %d files failed", failed, len(t.Elems))
+	}
 	if err != nil {
 		logger.Errorf("Error during batch file processing: %v", err)
 	} else {
 		logger.Info("Batch file task completed successfully")
 	}
-	t.Progress.OnDone(ctx, t, err)
+	if t.Progress != nil {
+		t.Progress.OnDone(ctx, t, err)
+	}
 	return err
 }
 
-func (t *Task) processElement(ctx context.Context, elem TaskElement) error {
+// 处理单个文件. 用户要求重试时中止当前传输并重新开始, 跳过或取消时返回对应的错误
+func (t *Task) runElement(ctx context.Context, elem TaskElement) error {
+	release, err := slotpool.Default().Acquire(ctx, t.UserID)
+	if err != nil {
+		return err
+	}
+	defer release()
+	for {
+		ectx, err := t.begin(ctx, &elem)
+		if err != nil {
+			return err
+		}
+		var written atomic.Int64
+		err = t.processElement(ectx, elem, &written)
+		t.end(elem.ID)
+		if err == nil || ctx.Err() != nil {
+			return err
+		}
+		cause := context.Cause(ectx)
+		if errors.Is(cause, errElementRetry) {
+			log.FromContext(ctx).Infof("Retrying element %s", elem.FileName())
+			t.downloaded.Add(-written.Load())
+			if t.Progress != nil {
+				t.Progress.OnProgress(ctx, t)
+			}
+			continue
+		}
+		if errors.Is(cause, ErrElementSkipped) || errors.Is(cause, ErrElementCanceled) {
+			return cause
+		}
+		return err
+	}
+}
+
+func (t *Task) processElement(ctx context.Context, elem TaskElement, written *atomic.Int64) error {
 	logger := log.FromContext(ctx).WithPrefix(fmt.Sprintf("file[%s]", elem.File.Name()))
+	if !elem.stream {
+		_, cannotStream := elem.Storage.(storage.StorageCannotStream)
+		reservation, stream, err := diskquota.Default().ReserveOrStream(ctx, elem.File.Size(), diskquota.WaitTimeout(), !cannotStream)
+		if err != nil {
+			return fmt.Errorf("failed to reserve cache space: %w", err)
+		}
+		if stream {
+			logger.Warn("Not enough cache space, falling back to stream mode")
+			elem.stream = true
+		}
+		defer reservation.Release()
+	}
 	if elem.stream {
 		pr, pw := io.Pipe()
 		defer pr.Close()
 		errg, uploadCtx := errgroup.WithContext(ctx)
+		var savedPath string
 		errg.Go(func() error {
-			return elem.Storage.Save(uploadCtx, pr, elem.Path)
+			var err error
+			savedPath, err = storage.Save(uploadCtx, elem.Storage, pr, elem.Path)
+			return err
 		})
 		wr := ioutil.NewProgressWriter(pw, func(n int) {
-			t.downloaded.Add(int64(n))
-			t.Progress.OnProgress(ctx, t)
+			written.Add(int64(n))
+			core.PublishProgress(ctx, t.downloaded.Add(int64(n)), t.totalSize)
+			if t.Progress != nil {
+				t.Progress.OnProgress(ctx, t)
+			}
 		})
 		errg.Go(func() error {
 			defer pw.Close()
@@ -73,6 +148,7 @@ func (t *Task) processElement(ctx context.Context, elem TaskElement) error {
 		if err := errg.Wait(); err != nil {
 			return fmt.Errorf("failed to download file in stream mode: %w", err)
 		}
+		elem.savedPath = savedPath
 		logger.Info("File downloaded successfully in stream mode")
 		return nil
 	}
@@ -87,8 +163,11 @@ func (t *Task) processElement(ctx context.Context, elem TaskElement) error {
 		}
 	}()
 	wrAt := ioutil.NewProgressWriterAt(localFile, func(n int) {
-		t.downloaded.Add(int64(n))
-		t.Progress.OnProgress(ctx, t)
+		written.Add(int64(n))
+		core.PublishProgress(ctx, t.downloaded.Add(int64(n)), t.totalSize)
+		if t.Progress != nil {
+			t.Progress.OnProgress(ctx, t)
+		}
 	})
 	_, err = tfile.NewDownloader(elem.File).Parallel(ctx, wrAt)
 	if err != nil {
@@ -107,14 +186,19 @@ func (t *Task) processElement(ctx context.Context, elem TaskElement) error {
 		return fmt.Errorf("failed to get file stat: %w", err)
 	}
 	vctx := context.WithValue(ctx, ctxkey.ContentLength, fileStat.Size())
+	attempt := 0
 	err = retry.Retry(func() error {
+		if attempt > 0 {
+			core.PublishRetrying(ctx, attempt, err)
+		}
+		attempt++
 		var file *os.File
 		file, err = os.Open(elem.localPath)
 		if err != nil {
 			return fmt.Errorf("failed to open cache file: %w", err)
 		}
 		defer file.Close()
-		if err = elem.Storage.Save(vctx, file, elem.Path); err != nil {
+		if elem.savedPath, err = storage.Save(vctx, elem.Storage, file, elem.Path); err != nil {
 			logger.Errorf("Failed to save file: %s, retrying...", err)
 			return err
 		}
@@ -122,3 +206,10 @@ func (t *Task) processElement(ctx context.Context, elem TaskElement) error {
 	}, retry.Context(vctx), retry.RetryTimes(uint(config.Cfg.Retry)))
 	return err
 }
+
+// 通知用户任务未执行就被中止
+func (t *Task) Abort(err error) {
+	if t.Progress != nil {
+		t.Progress.OnDone(t.Ctx, t, err)
+	}
+}
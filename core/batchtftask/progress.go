@@ -5,14 +5,19 @@ import (
 	"errors"
 	"fmt"
 	"strconv"
+	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/charmbracelet/log"
 	"github.com/gotd/td/tg"
 	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/msgelem"
+	"github.com/krau/SaveAny-Bot/common/cache"
 	"github.com/krau/SaveAny-Bot/common/utils/dlutil"
 	"github.com/krau/SaveAny-Bot/common/utils/tgutil"
+	"github.com/krau/SaveAny-Bot/core"
+	"github.com/rs/xid"
 )
 
 type ProgressTracker interface {
@@ -26,6 +31,10 @@ type Progress struct {
 	ChatID            int64
 	start             time.Time
 	lastUpdatePercent atomic.Int32
+
+	mu             sync.Mutex
+	lastEdit       time.Time
+	lastProcessing string // 上次显示的正在处理的文件
 }
 
 func (p *Progress) OnStart(ctx context.Context, info TaskInfo) {
@@ -62,14 +71,10 @@ func (p *Progress) OnStart(ctx context.Context, info TaskInfo) {
 }
 
 func (p *Progress) OnProgress(ctx context.Context, info TaskInfo) {
-	if !shouldUpdateProgress(info.TotalSize(), info.Downloaded(), int(p.lastUpdatePercent.Load())) {
-		return
-	}
-	percent := int((info.Downloaded() * 100) / info.TotalSize())
-	if p.lastUpdatePercent.Load() == int32(percent) {
+	processing := info.Processing()
+	if !p.shouldUpdate(info, processing) {
 		return
 	}
-	p.lastUpdatePercent.Store(int32(percent))
 	log.FromContext(ctx).Debugf("Progress update: %s, %d/%d", info.TaskID(), info.Downloaded(), info.TotalSize())
 	
 	// 使用新的模板系统，简化进度显示
@@ -81,11 +86,16 @@ func (p *Progress) OnProgress(ctx context.Context, info TaskInfo) {
 	// 进度信息
 	template.AddProgressBar("📊", "总体进度", info.Downloaded(), info.TotalSize(), 12)
 	
-	// 简化的当前状态信息
-	processingCount := len(info.Processing())
-	if processingCount > 0 {
-		statusText := fmt.Sprintf("%d 个文件", processingCount)
-		template.AddItem("🔄", "正在处理", statusText, msgelem.ItemTypeText)
+	// 正在处理的文件, 序号与下方的控制按钮对应
+	for i, elem := range processing {
+		if i >= maxElementControls {
+			template.AddItem("🔄", "其他", fmt.Sprintf("还有 %d 个文件", len(processing)-i), msgelem.ItemTypeText)
+			break
+		}
+		template.AddItem("🔄", strconv.Itoa(i+1), elem.FileName(), msgelem.ItemTypeText)
+	}
+	if failed := len(info.Failed()); failed > 0 {
+		template.AddItem("❌", "失败", fmt.Sprintf("%d 个文件", failed), msgelem.ItemTypeText)
 	}
 	
 	// 速度信息
@@ -105,6 +115,7 @@ func (p *Progress) OnProgress(ctx context.Context, info TaskInfo) {
 			},
 		},
 	}
+	markup.Rows = append(markup.Rows, buildElementControlRows(info.TaskID(), processing)...)
 	
 	ext := tgutil.ExtFromContext(ctx)
 	if ext != nil {
@@ -116,6 +127,61 @@ func (p *Progress) OnProgress(ctx context.Context, info TaskInfo) {
 	}
 }
 
+// 进度达到更新阈值, 或正在处理的文件发生变化且距上次更新超过一定时间时更新消息
+func (p *Progress) shouldUpdate(info TaskInfo, processing []TaskElementInfo) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	ids := make([]string, 0, len(processing))
+	for _, elem := range processing {
+		ids = append(ids, elem.ElementID())
+	}
+	key := strings.Join(ids, ",")
+	percent := 0
+	if info.TotalSize() > 0 {
+		percent = int((info.Downloaded() * 100) / info.TotalSize())
+	}
+	last := int(p.lastUpdatePercent.Load())
+	if shouldUpdateProgress(info.TotalSize(), info.Downloaded(), last) && percent != last {
+		p.lastUpdatePercent.Store(int32(percent))
+	} else if key == p.lastProcessing || time.Since(p.lastEdit) < controlsRefreshInterval {
+		return false
+	}
+	p.lastProcessing = key
+	p.lastEdit = time.Now()
+	return true
+}
+
+const (
+	// 进度消息中最多显示控制按钮的文件数
+	maxElementControls = 5
+	// 正在处理的文件变化时, 两次更新消息的最小间隔
+	controlsRefreshInterval = 3 * time.Second
+)
+
+// 批量任务中单个文件控制按钮的回调前缀, 格式为 batch_elem:<action>:<taskID>:<elemID>
+const ElementCallbackPrefix = "batch_elem:"
+
+// 仅重试失败文件按钮的回调前缀, 后接缓存中失败文件列表的 key
+const RetryCallbackPrefix = "batch_retry:"
+
+func buildElementControlRows(taskID string, processing []TaskElementInfo) []tg.KeyboardButtonRow {
+	rows := make([]tg.KeyboardButtonRow, 0, len(processing))
+	for i, elem := range processing {
+		if i >= maxElementControls {
+			break
+		}
+		data := func(action ElementAction) []byte {
+			return fmt.Appendf(nil, "%s%s:%s:%s", ElementCallbackPrefix, action, taskID, elem.ElementID())
+		}
+		rows = append(rows, tg.KeyboardButtonRow{Buttons: []tg.KeyboardButtonClass{
+			&tg.KeyboardButtonCallback{Text: fmt.Sprintf("%d. ⏭ 跳过", i+1), Data: data(ElementActionSkip)},
+			&tg.KeyboardButtonCallback{Text: fmt.Sprintf("%d. ✖ 取消", i+1), Data: data(ElementActionCancel)},
+			&tg.KeyboardButtonCallback{Text: fmt.Sprintf("%d. 🔁 重试", i+1), Data: data(ElementActionRetry)},
+		}})
+	}
+	return rows
+}
+
 func (p *Progress) OnDone(ctx context.Context, info TaskInfo, err error) {
 	if err != nil {
 		log.FromContext(ctx).Errorf("Batch task %s failed: %s", info.TaskID(), err)
@@ -124,36 +190,87 @@ func (p *Progress) OnDone(ctx context.Context, info TaskInfo, err error) {
 	}
 
 	var template *msgelem.MessageTemplate
+	var markup tg.ReplyMarkupClass
+	failed := info.Failed()
 	
-	if err != nil {
+	if core.IsShutdown(ctx, err) {
+		template = msgelem.NewInfoTemplate("⏸ Bot 正在重启", "任务将在重启后自动恢复")
+		template.AddItem("📦", "文件数量", strconv.Itoa(info.Count()), msgelem.ItemTypeText)
+	} else if err != nil {
 		if errors.Is(err, context.Canceled) {
 			template = msgelem.NewErrorTemplate("批量任务已取消", "")
 			template.AddItem("📦", "文件数量", strconv.Itoa(info.Count()), msgelem.ItemTypeText)
+		} else if len(failed) > 0 {
+			template = msgelem.NewErrorTemplate("批量下载部分失败", "")
+			template.AddItem("📦", "文件数量", strconv.Itoa(info.Count()), msgelem.ItemTypeText)
+			template.AddItem("❗", "失败数量", strconv.Itoa(len(failed)), msgelem.ItemTypeText)
 		} else {
 			template = msgelem.NewErrorTemplate("批量下载失败", "")
 			template.AddItem("📦", "文件数量", strconv.Itoa(info.Count()), msgelem.ItemTypeText)
 			template.AddItem("❗", "错误信息", err.Error(), msgelem.ItemTypeText)
 		}
+		for _, f := range failed {
+			template.AddItem("❌", f.Element.FileName(), failedReason(f.Err), msgelem.ItemTypeText)
+		}
+		if len(failed) > 0 {
+			markup = buildRetryMarkup(ctx, failed)
+		}
 	} else {
 		template = msgelem.NewSuccessTemplate("批量下载完成", "")
 		template.AddItem("📦", "文件数量", strconv.Itoa(info.Count()), msgelem.ItemTypeText)
 		template.AddItem("📏", "总大小", msgelem.FormatSize(info.TotalSize()), msgelem.ItemTypeText)
 		
-		elapsed := time.Since(p.start)
-		template.AddItem("⌚", "总用时", msgelem.FormatDuration(elapsed), msgelem.ItemTypeText)
+		if !p.start.IsZero() {
+			template.AddItem("⌚", "总用时", msgelem.FormatDuration(time.Since(p.start)), msgelem.ItemTypeText)
+		}
 	}
 
 	text, entities := template.BuildFormattedMessage()
 
 	ext := tgutil.ExtFromContext(ctx)
-	if ext != nil {
+	if ext != nil && p.MessageID != 0 {
 		peer := &tg.InputPeerUser{UserID: p.ChatID}
-		if err := msgelem.EditWithFormattedText(ext, peer, p.MessageID, text, entities, nil); err != nil {
+		if err := msgelem.EditWithFormattedText(ext, peer, p.MessageID, text, entities, markup); err != nil {
 			log.Warn("Failed to edit message for batch task completion", "error", err, "task_id", info.TaskID())
 		}
 	}
 }
 
+func failedReason(err error) string {
+	switch {
+	case errors.Is(err, ErrElementCanceled):
+		return "已取消"
+	case errors.Is(err, context.Canceled):
+		return "任务中止"
+	}
+	return err.Error()
+}
+
+// 将失败的文件放入缓存, 返回仅重试这些文件的按钮
+func buildRetryMarkup(ctx context.Context, failed []FailedElement) tg.ReplyMarkupClass {
+	elems := make([]TaskElement, 0, len(failed))
+	for _, f := range failed {
+		elems = append(elems, f.Element)
+	}
+	key := xid.New().String()
+	if err := cache.Set(key, elems); err != nil {
+		log.FromContext(ctx).Warn("Failed to cache failed elements for retry", "error", err)
+		return nil
+	}
+	return &tg.ReplyInlineMarkup{
+		Rows: []tg.KeyboardButtonRow{
+			{
+				Buttons: []tg.KeyboardButtonClass{
+					&tg.KeyboardButtonCallback{
+						Text: fmt.Sprintf("🔁 仅重试失败的 %d 个文件", len(elems)),
+						Data: fmt.Appendf(nil, "%s%s", RetryCallbackPrefix, key),
+					},
+				},
+			},
+		},
+	}
+}
+
 func NewProgressTracker(messageID int, chatID int64) ProgressTracker {
 	return &Progress{
 		MessageID: messageID,
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"path/filepath"
+	"sync"
 	"sync/atomic"
 
 	"github.com/krau/SaveAny-Bot/config"
@@ -19,19 +20,26 @@ type TaskElement struct {
 	Path      string
 	File      tfile.TGFile
 	localPath string
+	savedPath string // 实际保存的路径, 存储端另存为带后缀的文件时与 Path 不同
 	stream    bool
 }
 
 type Task struct {
 	ID           string
 	Ctx          context.Context
+	UserID       int64 // telegram user id of the task owner
 	Elems        []TaskElement
 	Progress     ProgressTracker
 	IgnoreErrors bool // if true, errors during processing will be ignored
 	downloaded   atomic.Int64
 	totalSize    int64
-	processing   map[string]TaskElementInfo
-	failed       map[string]error // errors for each element
+
+	mu         sync.Mutex // 保护以下字段, 各文件在不同的协程中处理
+	processing map[string]TaskElementInfo
+	controls   map[string]context.CancelCauseFunc // 正在处理的文件, 用于单独中止
+	pending    map[string]error                   // 尚未开始就被跳过或取消的文件
+	finished   map[string]error                   // 已结束的文件及其结果, 成功时为 nil
+	failed     map[string]error                   // errors for each element
 }
 
 func (t *Task) Type() tasktype.TaskType {
@@ -70,6 +78,7 @@ func NewTaskElement(
 func NewBatchTGFileTask(
 	id string,
 	ctx context.Context,
+	userID int64,
 	files []TaskElement,
 	progress ProgressTracker,
 	ignoreErrors bool,
@@ -77,6 +86,7 @@ func NewBatchTGFileTask(
 	task := &Task{
 		ID:         id,
 		Ctx:        ctx,
+		UserID:     userID,
 		Elems:      files,
 		Progress:   progress,
 		downloaded: atomic.Int64{},
@@ -89,6 +99,9 @@ func NewBatchTGFileTask(
 		}(),
 		processing:   make(map[string]TaskElementInfo),
 		IgnoreErrors: ignoreErrors,
+		controls:     make(map[string]context.CancelCauseFunc),
+		pending:      make(map[string]error),
+		finished:     make(map[string]error),
 		failed:       make(map[string]error),
 	}
 	return task
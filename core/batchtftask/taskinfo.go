@@ -1,12 +1,23 @@
 package batchtftask
 
+import (
+	"cmp"
+	"github.com/krau/SaveAny-Bot/core"
+	"github.com/krau/SaveAny-Bot/pkg/tfile"
+)
+
 type TaskElementInfo interface {
+	ElementID() string
 	FileName() string
 	FileSize() int64
 	StoragePath() string
 	StorageName() string
 }
 
+func (e *TaskElement) ElementID() string {
+	return e.ID
+}
+
 func (e *TaskElement) FileName() string {
 	return e.File.Name()
 }
@@ -16,7 +27,7 @@ func (e *TaskElement) FileSize() int64 {
 }
 
 func (e *TaskElement) StoragePath() string {
-	return e.Path
+	return cmp.Or(e.savedPath, e.Path)
 }
 
 func (e *TaskElement) StorageName() string {
@@ -29,6 +40,13 @@ type TaskInfo interface {
 	Downloaded() int64
 	Count() int
 	Processing() []TaskElementInfo
+	Failed() []FailedElement
+}
+
+// 处理失败的文件及其错误
+type FailedElement struct {
+	Element TaskElement
+	Err     error
 }
 
 func (t *Task) TaskID() string {
@@ -48,9 +66,56 @@ func (t *Task) Count() int {
 }
 
 func (t *Task) Processing() []TaskElementInfo {
-	processing := make([]TaskElementInfo, 0, len(t.Elems))
-	for _, elem := range t.processing {
-		processing = append(processing, elem)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	processing := make([]TaskElementInfo, 0, len(t.processing))
+	for _, elem := range t.Elems {
+		if info, ok := t.processing[elem.ID]; ok {
+			processing = append(processing, info)
+		}
 	}
 	return processing
 }
+
+// 按原始顺序返回处理失败的文件
+func (t *Task) Failed() []FailedElement {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	failed := make([]FailedElement, 0, len(t.failed))
+	for _, elem := range t.Elems {
+		if err, ok := t.failed[elem.ID]; ok {
+			failed = append(failed, FailedElement{Element: elem, Err: err})
+		}
+	}
+	return failed
+}
+
+// 各文件的存储端可能不同, 顶层的文件和存储信息取自第一个文件, 所有文件见 Files
+func (t *Task) Meta() core.TaskMeta {
+	meta := core.TaskMeta{
+		UserID:     t.UserID,
+		TotalBytes: t.totalSize,
+		Count:      len(t.Elems),
+		Files:      make([]core.FileMeta, 0, len(t.Elems)),
+	}
+	for i := range t.Elems {
+		elem := &t.Elems[i]
+		meta.Files = append(meta.Files, core.FileMeta{
+			Name:        elem.FileName(),
+			Size:        elem.FileSize(),
+			StorageName: elem.StorageName(),
+			StorageType: elem.Storage.Type().String(),
+			StoragePath: elem.StoragePath(),
+		})
+	}
+	if len(t.Elems) > 0 {
+		first := &t.Elems[0]
+		meta.Title = first.FileName()
+		meta.StorageName = first.StorageName()
+		meta.StorageType = first.Storage.Type().String()
+		meta.StoragePath = first.StoragePath()
+		meta.LocalPath = first.localPath
+		meta.SourceChatID, meta.SourceMessageID, _ = tfile.MessageSource(first.File)
+	}
+	return meta
+}
@@ -3,6 +3,7 @@ package core
 import (
 	"context"
 	"errors"
+	"fmt"
 
 	"github.com/charmbracelet/log"
 	"github.com/krau/SaveAny-Bot/config"
@@ -18,9 +19,13 @@ type Exectable interface {
 	Execute(ctx context.Context) error
 }
 
+// 可以在执行前被中止的任务, 中止时通知用户. 被中止或等待传输槽位失败的任务只调用进度跟踪器的 OnDone, 不会调用 OnStart
+type Abortable interface {
+	Abort(err error)
+}
+
 func worker(ctx context.Context, qe *queue.TaskQueue[Exectable], semaphore chan struct{}) {
 	logger := log.FromContext(ctx)
-	execHooks := config.Cfg.Hook.Exec
 	for {
 		semaphore <- struct{}{}
 		qtask, err := qe.Get()
@@ -28,52 +33,83 @@ func worker(ctx context.Context, qe *queue.TaskQueue[Exectable], semaphore chan
 			logger.Error("Failed to get task from queue:", err)
 			break // queue closed and empty
 		}
-		task := qtask.Data
-		logger.Infof("Processing task: %s", task.TaskID())
-		if err := ExecCommandString(qtask.Context(), execHooks.TaskBeforeStart); err != nil {
-			logger.Errorf("Failed to execute before start hook for task %s: %v", task.TaskID(), err)
-		}
-		if err := task.Execute(qtask.Context()); err != nil {
-			if errors.Is(err, context.Canceled) {
-				logger.Infof("Task %s was canceled", task.TaskID())
-				if err := ExecCommandString(ctx, execHooks.TaskCancel); err != nil {
-					logger.Errorf("Failed to execute cancel hook for task %s: %v", task.TaskID(), err)
-				}
-			} else {
-				logger.Errorf("Failed to execute task %s: %v", task.TaskID(), err)
-				if err := ExecCommandString(ctx, execHooks.TaskFail); err != nil {
-					logger.Errorf("Failed to execute fail hook for task %s: %v", task.TaskID(), err)
-				}
-			}
-		} else {
-			logger.Infof("Task %s completed successfully", task.TaskID())
-			if err := ExecCommandString(ctx, execHooks.TaskSuccess); err != nil {
-				logger.Errorf("Failed to execute success hook for task %s: %v", task.TaskID(), err)
-			}
-		}
+		runTask(ctx, qtask)
 		qe.Done(qtask.ID)
 		<-semaphore
 	}
 }
 
+func runTask(ctx context.Context, qtask *queue.Task[Exectable]) {
+	task := qtask.Data
+	tctx := withTaskEvents(qtask.Context(), task)
+	if err := runBeforeStartHook(tctx, metaOf(task)); err != nil {
+		err = fmt.Errorf("before start hook aborted the task: %w", err)
+		if a, ok := task.(Abortable); ok {
+			a.Abort(err)
+		}
+		publish(tctx, Event{Type: EventFailed, Task: metaOf(task), Err: err})
+		return
+	}
+	publish(tctx, Event{Type: EventStarted, Task: metaOf(task)})
+	err := task.Execute(tctx)
+	// 任务执行过程中路径等信息可能变化, 结束事件使用最新的信息
+	meta := metaOf(task)
+	switch {
+	case err == nil:
+		publish(ctx, Event{Type: EventSucceeded, Task: meta})
+	case IsShutdown(tctx, err):
+		interruptedMu.Lock()
+		interrupted = append(interrupted, task)
+		interruptedMu.Unlock()
+		publish(ctx, Event{Type: EventCanceled, Task: meta, Err: ErrShutdown})
+	case errors.Is(err, context.Canceled):
+		publish(ctx, Event{Type: EventCanceled, Task: meta, Err: err})
+	default:
+		publish(ctx, Event{Type: EventFailed, Task: meta, Err: err})
+	}
+}
+
 func Run(ctx context.Context) {
 	log.FromContext(ctx).Info("Start processing tasks...")
-	semaphore := make(chan struct{}, config.Cfg.Workers)
+	// 任务内的每个文件在传输前还需要从 slotpool 获取全局传输槽位, 这里只限制同时执行的任务数
+	maxTasks := config.Cfg.GetMaxTasks()
+	semaphore := make(chan struct{}, maxTasks)
 	if queueInstance == nil {
 		queueInstance = queue.NewTaskQueue[Exectable]()
 	}
-	for range config.Cfg.Workers {
+	subscribeDefaults(ctx)
+	for range maxTasks {
 		go worker(ctx, queueInstance, semaphore)
 	}
 
 }
 
 func AddTask(ctx context.Context, task Exectable) error {
-	return queueInstance.Add(queue.NewTask(ctx, task.TaskID(), task))
+	if shuttingDown.Load() {
+		return ErrShutdown
+	}
+	if err := queueInstance.Add(queue.NewTask(ctx, task.TaskID(), task)); err != nil {
+		return err
+	}
+	publish(ctx, Event{Type: EventQueued, Task: metaOf(task)})
+	return nil
 }
 
 func CancelTask(ctx context.Context, id string) error {
-	return queueInstance.CancelTask(id)
+	// 等待中的任务被取消后不会交给 worker, 在这里发布取消事件
+	var pending Exectable
+	if queueInstance.Position(id) > 0 {
+		if qtask, err := queueInstance.GetTask(id); err == nil {
+			pending = qtask.Data
+		}
+	}
+	if err := queueInstance.CancelTask(id); err != nil {
+		return err
+	}
+	if pending != nil {
+		publish(ctx, Event{Type: EventCanceled, Task: metaOf(pending), Err: context.Canceled})
+	}
+	return nil
 }
 
 func GetLength(ctx context.Context) int {
@@ -82,3 +118,23 @@ func GetLength(ctx context.Context) int {
 	}
 	return queueInstance.ActiveLength()
 }
+
+// 任务在等待队列中的位置, 已开始执行或不存在时返回 0
+func GetPosition(ctx context.Context, id string) int {
+	if queueInstance == nil {
+		return 0
+	}
+	return queueInstance.Position(id)
+}
+
+// 获取等待中或执行中的任务
+func GetTask(ctx context.Context, id string) (Exectable, error) {
+	if queueInstance == nil {
+		return nil, errors.New("task queue is not initialized")
+	}
+	task, err := queueInstance.GetTask(id)
+	if err != nil {
+		return nil, err
+	}
+	return task.Data, nil
+}
@@ -0,0 +1,338 @@
+package core
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/charmbracelet/log"
+	storenum "github.com/krau/SaveAny-Bot/pkg/enums/storage"
+	"github.com/krau/SaveAny-Bot/pkg/enums/tasktype"
+)
+
+type EventType string
+
+const (
+	EventQueued    EventType = "queued"
+	EventStarted   EventType = "started"
+	EventProgress  EventType = "progress"
+	EventRetrying  EventType = "retrying"
+	EventSucceeded EventType = "succeeded"
+	EventFailed    EventType = "failed"
+	EventCanceled  EventType = "canceled"
+)
+
+// 任务的基本信息, 随每个事件一起发布
+type TaskMeta struct {
+	TaskID      string
+	Type        tasktype.TaskType
+	UserID      int64
+	Title       string // 文件名或 Telegraph 路径
+	StorageName string
+	StorageType string
+	StoragePath string
+	FilePath    string     // 文件在本机上的绝对路径, 仅本地存储端有, 由 metaOf 填充
+	LocalPath   string     // 本地缓存路径, Stream 模式下为空
+	TotalBytes  int64      // 未知时为 0
+	Count       int        // 文件或图片数量
+	Files       []FileMeta // 批量和种子任务中的每个文件, 单文件任务为空
+	// 进度事件以个数计 (图片, 文件或消息), 否则以字节计
+	CountProgress bool
+	// 来源消息, 没有时为 0
+	SourceChatID    int64
+	SourceMessageID int
+}
+
+// 多文件任务中的单个文件
+type FileMeta struct {
+	Name        string `json:"name"`
+	Size        int64  `json:"size"`
+	StorageName string `json:"storage_name"`
+	StorageType string `json:"storage_type"`
+	StoragePath string `json:"storage_path"`
+	FilePath    string `json:"file_path,omitempty"`
+}
+
+// 可以提供 TaskMeta 的任务. 未实现时事件中只有 TaskID 和 Type
+type Describable interface {
+	Meta() TaskMeta
+}
+
+type Event struct {
+	Type EventType
+	Time time.Time
+	Task TaskMeta
+	// 失败, 取消和重试事件的错误. 因 Bot 关闭而中断的任务以 ErrShutdown 发布取消事件
+	Err error
+	// 重试事件的重试次数, 从 1 开始
+	Attempt int
+	// 进度事件的已完成量和总量, 单位为字节, Task.CountProgress 为 true 时为个数
+	Done  int64
+	Total int64
+}
+
+type EventHandler func(ctx context.Context, ev Event)
+
+type subscriber struct {
+	id      uint64
+	handler EventHandler
+	types   map[EventType]struct{}
+	sync    bool
+
+	// 异步订阅者的事件队列, 由订阅者自己的协程依次处理
+	mu      sync.Mutex
+	queue   []Event
+	pending int // 已入队但尚未处理完的事件数
+	closed  bool
+	notify  chan struct{}
+	dropped atomic.Int64 // 因订阅者处理不过来而丢弃的进度事件数
+}
+
+func (s *subscriber) wants(t EventType) bool {
+	if len(s.types) == 0 {
+		return true
+	}
+	_, ok := s.types[t]
+	return ok
+}
+
+// 放入异步订阅者的队列, 不会阻塞发布者. 积压的事件过多时丢弃进度事件, 其他事件总是保留
+func (s *subscriber) enqueue(ev Event) bool {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return true
+	}
+	if ev.Type == EventProgress && len(s.queue) >= subscriberBacklog {
+		s.mu.Unlock()
+		s.dropped.Add(1)
+		return false
+	}
+	s.queue = append(s.queue, ev)
+	s.pending++
+	s.mu.Unlock()
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+	return true
+}
+
+// 按发布顺序处理队列中的事件, 取消订阅后处理完剩余事件再退出
+func (s *subscriber) run(ctx context.Context) {
+	for {
+		s.mu.Lock()
+		batch, closed := s.queue, s.closed
+		s.queue = nil
+		s.mu.Unlock()
+		if len(batch) == 0 {
+			if closed {
+				return
+			}
+			<-s.notify
+			continue
+		}
+		for _, ev := range batch {
+			s.handler(ctx, ev)
+		}
+		s.mu.Lock()
+		s.pending -= len(batch)
+		s.mu.Unlock()
+	}
+}
+
+func (s *subscriber) idle() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.pending == 0
+}
+
+func (s *subscriber) close() {
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+}
+
+// 异步订阅者积压的事件达到该数量后丢弃新的进度事件
+const subscriberBacklog = 256
+
+// 同一任务的进度事件的最小发布间隔
+const progressEventInterval = time.Second
+
+var (
+	subscribersMu sync.RWMutex
+	subscribers   []*subscriber
+	subscriberSeq uint64
+)
+
+// 订阅任务事件, types 为空时订阅所有事件. 返回取消订阅的函数.
+//
+// handler 在独立的协程中按发布顺序调用, 不会阻塞任务执行. 处理过慢时进度事件可能被丢弃
+func Subscribe(ctx context.Context, handler EventHandler, types ...EventType) func() {
+	s := newSubscriber(handler, types, false)
+	s.notify = make(chan struct{}, 1)
+	go s.run(ctx)
+	return addSubscriber(s)
+}
+
+// 同步订阅任务事件, handler 在发布事件的协程中调用, ctx 为任务的 context.
+//
+// 用于需要在任务继续执行前完成的处理, handler 应尽快返回
+func SubscribeSync(handler EventHandler, types ...EventType) func() {
+	return addSubscriber(newSubscriber(handler, types, true))
+}
+
+func newSubscriber(handler EventHandler, types []EventType, sync bool) *subscriber {
+	s := &subscriber{handler: handler, sync: sync}
+	if len(types) > 0 {
+		s.types = make(map[EventType]struct{}, len(types))
+		for _, t := range types {
+			s.types[t] = struct{}{}
+		}
+	}
+	return s
+}
+
+func addSubscriber(s *subscriber) func() {
+	subscribersMu.Lock()
+	subscriberSeq++
+	s.id = subscriberSeq
+	subscribers = append(subscribers, s)
+	subscribersMu.Unlock()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			subscribersMu.Lock()
+			for i, sub := range subscribers {
+				if sub.id == s.id {
+					subscribers = append(subscribers[:i:i], subscribers[i+1:]...)
+					break
+				}
+			}
+			subscribersMu.Unlock()
+			if !s.sync {
+				s.close()
+			}
+		})
+	}
+}
+
+// 异步订阅者处理完积压事件的检查间隔
+var flushPollInterval = 20 * time.Millisecond
+
+// 等待所有异步订阅者处理完已发布的事件, 最多等待 timeout. 全部处理完时返回 true
+func FlushEvents(timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for {
+		subscribersMu.RLock()
+		subs := slices.Clone(subscribers)
+		subscribersMu.RUnlock()
+		idle := true
+		for _, s := range subs {
+			if !s.sync && !s.idle() {
+				idle = false
+				break
+			}
+		}
+		if idle {
+			return true
+		}
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(flushPollInterval)
+	}
+}
+
+func publish(ctx context.Context, ev Event) {
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+	subscribersMu.RLock()
+	subs := slices.Clone(subscribers)
+	subscribersMu.RUnlock()
+	for _, s := range subs {
+		if !s.wants(ev.Type) {
+			continue
+		}
+		if s.sync {
+			s.handler(ctx, ev)
+			continue
+		}
+		if !s.enqueue(ev) {
+			log.FromContext(ctx).Debug("Event subscriber is busy, dropping progress event", "task_id", ev.Task.TaskID, "dropped", s.dropped.Load())
+		}
+	}
+}
+
+func metaOf(task Exectable) TaskMeta {
+	if d, ok := task.(Describable); ok {
+		meta := d.Meta()
+		meta.TaskID = task.TaskID()
+		meta.Type = task.Type()
+		meta.FilePath = localFilePath(meta.StorageType, meta.StoragePath)
+		for i := range meta.Files {
+			meta.Files[i].FilePath = localFilePath(meta.Files[i].StorageType, meta.Files[i].StoragePath)
+		}
+		return meta
+	}
+	return TaskMeta{TaskID: task.TaskID(), Type: task.Type()}
+}
+
+type taskEventKey struct{}
+
+// 执行中任务的事件状态, 由 worker 注入任务的 context
+type taskEventState struct {
+	meta         TaskMeta
+	lastProgress atomic.Int64
+}
+
+func withTaskEvents(ctx context.Context, task Exectable) context.Context {
+	return context.WithValue(ctx, taskEventKey{}, &taskEventState{meta: metaOf(task)})
+}
+
+// 发布执行中任务的进度事件, 同一任务的进度事件会被节流.
+// ctx 需要是任务 Execute 收到的 context 或其派生, 否则不做任何事
+func PublishProgress(ctx context.Context, done, total int64) {
+	state, ok := ctx.Value(taskEventKey{}).(*taskEventState)
+	if !ok {
+		return
+	}
+	now := time.Now().UnixNano()
+	last := state.lastProgress.Load()
+	if (total <= 0 || done < total) && now-last < int64(progressEventInterval) {
+		return
+	}
+	if !state.lastProgress.CompareAndSwap(last, now) {
+		return
+	}
+	publish(ctx, Event{Type: EventProgress, Task: state.meta, Done: done, Total: total})
+}
+
+// 发布执行中任务的重试事件, attempt 从 1 开始
+func PublishRetrying(ctx context.Context, attempt int, err error) {
+	state, ok := ctx.Value(taskEventKey{}).(*taskEventState)
+	if !ok {
+		return
+	}
+	publish(ctx, Event{Type: EventRetrying, Task: state.meta, Attempt: attempt, Err: err})
+}
+
+// 本地存储端中文件的绝对路径, 供 hook 直接访问文件, 其他存储端为空
+func localFilePath(storageType, storagePath string) string {
+	if storageType != storenum.Local.String() || storagePath == "" {
+		return ""
+	}
+	abs, err := filepath.Abs(storagePath)
+	if err != nil {
+		return storagePath
+	}
+	return abs
+}
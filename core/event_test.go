@@ -0,0 +1,128 @@
+package core
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/krau/SaveAny-Bot/pkg/enums/tasktype"
+)
+
+type fakeTask struct{ id string }
+
+func (t *fakeTask) Type() tasktype.TaskType           { return tasktype.TaskTypeTgfiles }
+func (t *fakeTask) TaskID() string                    { return t.id }
+func (t *fakeTask) Execute(ctx context.Context) error { return nil }
+func (t *fakeTask) Meta() TaskMeta                    { return TaskMeta{UserID: 42, Title: "file"} }
+
+func TestEventSubscribe(t *testing.T) {
+	ctx := context.Background()
+	received := make(chan Event, 8)
+	unsubscribe := Subscribe(ctx, func(ctx context.Context, ev Event) {
+		received <- ev
+	}, EventSucceeded)
+	defer unsubscribe()
+	var syncEvents []EventType
+	unsubscribeSync := SubscribeSync(func(ctx context.Context, ev Event) {
+		syncEvents = append(syncEvents, ev.Type)
+	})
+	defer unsubscribeSync()
+
+	task := &fakeTask{id: "t1"}
+	tctx := withTaskEvents(ctx, task)
+	publish(tctx, Event{Type: EventStarted, Task: metaOf(task)})
+	PublishProgress(tctx, 1, 10)
+	PublishProgress(tctx, 2, 10) // 节流, 不会发布
+	PublishProgress(tctx, 10, 10)
+	PublishRetrying(tctx, 1, context.DeadlineExceeded)
+	PublishProgress(ctx, 1, 10) // 不是任务的 context, 不会发布
+	publish(ctx, Event{Type: EventSucceeded, Task: metaOf(task)})
+
+	want := []EventType{EventStarted, EventProgress, EventProgress, EventRetrying, EventSucceeded}
+	if len(syncEvents) != len(want) {
+		t.Fatalf("expected sync events %v, got %v", want, syncEvents)
+	}
+	for i := range want {
+		if syncEvents[i] != want[i] {
+			t.Fatalf("expected sync events %v, got %v", want, syncEvents)
+		}
+	}
+	select {
+	case ev := <-received:
+		if ev.Type != EventSucceeded || ev.Task.TaskID != "t1" || ev.Task.UserID != 42 {
+			t.Fatalf("unexpected event: %+v", ev)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("async subscriber did not receive the event")
+	}
+	select {
+	case ev := <-received:
+		t.Fatalf("unexpected extra event: %+v", ev)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestSlowSubscriberDoesNotBlockPublish(t *testing.T) {
+	ctx := context.Background()
+	release := make(chan struct{})
+	received := make(chan Event, 2*subscriberBacklog)
+	unsubscribe := Subscribe(ctx, func(ctx context.Context, ev Event) {
+		<-release
+		received <- ev
+	})
+	defer unsubscribe()
+
+	task := &fakeTask{id: "slow"}
+	published := make(chan struct{})
+	go func() {
+		for range subscriberBacklog + 10 {
+			publish(ctx, Event{Type: EventProgress, Task: metaOf(task)})
+		}
+		publish(ctx, Event{Type: EventSucceeded, Task: metaOf(task)})
+		close(published)
+	}()
+	select {
+	case <-published:
+	case <-time.After(time.Second):
+		t.Fatal("publish blocked on a slow subscriber")
+	}
+	close(release)
+	// 积压的进度事件被丢弃, 结束事件仍然送达
+	for {
+		select {
+		case ev := <-received:
+			if ev.Type == EventSucceeded {
+				return
+			}
+		case <-time.After(time.Second):
+			t.Fatal("succeeded event was not delivered")
+		}
+	}
+}
+
+func TestFlushEventsWaitsForAsyncSubscribers(t *testing.T) {
+	ctx := context.Background()
+	release := make(chan struct{})
+	var handled atomic.Int32
+	unsubscribe := Subscribe(ctx, func(ctx context.Context, ev Event) {
+		<-release
+		handled.Add(1)
+	}, EventCanceled)
+	defer unsubscribe()
+
+	task := &fakeTask{id: "flush"}
+	for range 3 {
+		publish(ctx, Event{Type: EventCanceled, Task: metaOf(task), Err: ErrShutdown})
+	}
+	if FlushEvents(50 * time.Millisecond) {
+		t.Fatal("expected flush to time out while the subscriber is blocked")
+	}
+	close(release)
+	if !FlushEvents(time.Second) {
+		t.Fatal("expected flush to finish after the subscriber is released")
+	}
+	if n := handled.Load(); n != 3 {
+		t.Fatalf("expected 3 handled events after flush, got %d", n)
+	}
+}
@@ -1,13 +1,29 @@
 package core
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"os"
 	"os/exec"
 	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/krau/SaveAny-Bot/config"
 )
 
 func ExecCommandString(ctx context.Context, cmd string) error {
+	return execCommand(ctx, cmd, nil)
+}
+
+func execCommand(ctx context.Context, cmd string, env []string) error {
 	if cmd == "" {
 		return nil
 	}
@@ -17,7 +33,175 @@ func ExecCommandString(ctx context.Context, cmd string) error {
 	} else {
 		execCmd = exec.CommandContext(ctx, "sh", "-c", cmd)
 	}
+	if len(env) > 0 {
+		execCmd.Env = append(os.Environ(), env...)
+	}
 	execCmd.Stdout = os.Stdout
 	execCmd.Stderr = os.Stderr
+	// 超时后不再等待命令的子进程关闭输出
+	execCmd.WaitDelay = time.Second
 	return execCmd.Run()
 }
+
+// 执行 hook 命令时可用的任务信息, 同时作为命令模板的字段和 SAVEANY_ 前缀的环境变量
+type HookData struct {
+	Event       string
+	TaskID      string
+	TaskType    string
+	FileName    string
+	FileSize    int64
+	FileCount   int
+	StorageName string
+	StorageType string
+	StoragePath string
+	FilePath    string
+	LocalPath   string
+	Files       []FileMeta // 批量和种子任务中的每个文件
+	UserID      int64
+	ChatID      int64
+	MessageID   int
+	Error       string
+}
+
+func newHookData(event EventType, meta TaskMeta, err error) HookData {
+	data := HookData{
+		Event:       string(event),
+		TaskID:      meta.TaskID,
+		TaskType:    meta.Type.String(),
+		FileName:    meta.Title,
+		FileSize:    meta.TotalBytes,
+		FileCount:   meta.Count,
+		StorageName: meta.StorageName,
+		StorageType: meta.StorageType,
+		StoragePath: meta.StoragePath,
+		FilePath:    meta.FilePath,
+		LocalPath:   meta.LocalPath,
+		Files:       meta.Files,
+		UserID:      meta.UserID,
+		ChatID:      meta.SourceChatID,
+		MessageID:   meta.SourceMessageID,
+	}
+	if err != nil {
+		data.Error = err.Error()
+	}
+	return data
+}
+
+func (d HookData) Env() []string {
+	files := "[]"
+	if len(d.Files) > 0 {
+		if data, err := json.Marshal(d.Files); err == nil {
+			files = string(data)
+		}
+	}
+	return []string{
+		"SAVEANY_EVENT=" + d.Event,
+		"SAVEANY_TASK_ID=" + d.TaskID,
+		"SAVEANY_TASK_TYPE=" + d.TaskType,
+		"SAVEANY_FILE_NAME=" + d.FileName,
+		"SAVEANY_FILE_SIZE=" + strconv.FormatInt(d.FileSize, 10),
+		"SAVEANY_FILE_COUNT=" + strconv.Itoa(d.FileCount),
+		"SAVEANY_STORAGE_NAME=" + d.StorageName,
+		"SAVEANY_STORAGE_TYPE=" + d.StorageType,
+		"SAVEANY_STORAGE_PATH=" + d.StoragePath,
+		"SAVEANY_FILE_PATH=" + d.FilePath,
+		"SAVEANY_LOCAL_PATH=" + d.LocalPath,
+		"SAVEANY_FILES=" + files,
+		"SAVEANY_USER_ID=" + strconv.FormatInt(d.UserID, 10),
+		"SAVEANY_CHAT_ID=" + strconv.FormatInt(d.ChatID, 10),
+		"SAVEANY_MESSAGE_ID=" + strconv.Itoa(d.MessageID),
+		"SAVEANY_ERROR=" + d.Error,
+	}
+}
+
+var hookTemplateFuncs = template.FuncMap{
+	// 将字符串转义为单个 shell 参数
+	"quote": func(s string) string {
+		if runtime.GOOS == "windows" {
+			return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+		}
+		return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+	},
+}
+
+// 渲染命令模板并执行, 任务信息同时通过环境变量传入
+func runHookCommand(ctx context.Context, cmd string, data HookData) error {
+	if cmd == "" {
+		return nil
+	}
+	if strings.Contains(cmd, "{{") {
+		tmpl, err := template.New("hook").Funcs(hookTemplateFuncs).Parse(cmd)
+		if err != nil {
+			return fmt.Errorf("invalid hook command template: %w", err)
+		}
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, data); err != nil {
+			return fmt.Errorf("failed to render hook command: %w", err)
+		}
+		cmd = buf.String()
+	}
+	if timeout := config.Cfg.Hook.Exec.Timeout; timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(timeout)*time.Second)
+		defer cancel()
+	}
+	return execCommand(ctx, cmd, data.Env())
+}
+
+// 执行任务开始前的 hook, 命令返回非零退出码时任务不会执行
+func runBeforeStartHook(ctx context.Context, meta TaskMeta) error {
+	cmd := config.Cfg.Hook.Exec.ForType(meta.Type.String()).TaskBeforeStart
+	return runHookCommand(ctx, cmd, newHookData(EventStarted, meta, nil))
+}
+
+var subscribeDefaultsOnce sync.Once
+
+// 注册内置的事件订阅者: 任务日志, config.Hook.Exec 中的命令和 webhook
+func subscribeDefaults(ctx context.Context) {
+	subscribeDefaultsOnce.Do(func() {
+		Subscribe(ctx, logEvent)
+		Subscribe(ctx, execDoneHook, EventSucceeded, EventFailed, EventCanceled)
+		subscribeWebhooks(ctx)
+	})
+}
+
+func logEvent(ctx context.Context, ev Event) {
+	logger := log.FromContext(ctx)
+	switch ev.Type {
+	case EventQueued:
+		logger.Debugf("Task %s queued", ev.Task.TaskID)
+	case EventStarted:
+		logger.Infof("Processing task: %s", ev.Task.TaskID)
+	case EventRetrying:
+		logger.Warnf("Task %s retrying (attempt %d): %v", ev.Task.TaskID, ev.Attempt, ev.Err)
+	case EventSucceeded:
+		logger.Infof("Task %s completed successfully", ev.Task.TaskID)
+	case EventFailed:
+		logger.Errorf("Failed to execute task %s: %v", ev.Task.TaskID, ev.Err)
+	case EventCanceled:
+		if errors.Is(ev.Err, ErrShutdown) {
+			logger.Infof("Task %s was interrupted by shutdown", ev.Task.TaskID)
+		} else {
+			logger.Infof("Task %s was canceled", ev.Task.TaskID)
+		}
+	}
+}
+
+func execDoneHook(ctx context.Context, ev Event) {
+	hooks := config.Cfg.Hook.Exec.ForType(ev.Task.Type.String())
+	var cmd, name string
+	switch ev.Type {
+	case EventSucceeded:
+		cmd, name = hooks.TaskSuccess, "success"
+	case EventFailed:
+		cmd, name = hooks.TaskFail, "fail"
+	case EventCanceled:
+		if errors.Is(ev.Err, ErrShutdown) {
+			return
+		}
+		cmd, name = hooks.TaskCancel, "cancel"
+	}
+	if err := runHookCommand(ctx, cmd, newHookData(ev.Type, ev.Task, ev.Err)); err != nil {
+		log.FromContext(ctx).Errorf("Failed to execute %s hook for task %s: %v", name, ev.Task.TaskID, err)
+	}
+}
@@ -0,0 +1,113 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/krau/SaveAny-Bot/config"
+	"github.com/krau/SaveAny-Bot/pkg/enums/tasktype"
+)
+
+func TestRunHookCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("hook test uses sh")
+	}
+	out := filepath.Join(t.TempDir(), "out")
+	data := newHookData(EventSucceeded, TaskMeta{
+		TaskID:   "t1",
+		Type:     tasktype.TaskTypeTgfiles,
+		Title:    "it's a file.mp4",
+		FilePath: "/data/it's a file.mp4",
+	}, nil)
+	cmd := `printf '%s|%s' {{quote .FilePath}} "$SAVEANY_TASK_ID" > ` + out
+	if err := runHookCommand(context.Background(), cmd, data); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "/data/it's a file.mp4|t1" {
+		t.Fatalf("unexpected hook output: %q", got)
+	}
+}
+
+func TestBeforeStartHook(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("hook test uses sh")
+	}
+	old := config.Cfg.Hook.Exec
+	defer func() { config.Cfg.Hook.Exec = old }()
+	meta := TaskMeta{TaskID: "t1", Type: tasktype.TaskTypeTgfiles}
+
+	config.Cfg.Hook.Exec.TaskBeforeStart = `test "$SAVEANY_TASK_TYPE" = tgfiles`
+	if err := runBeforeStartHook(context.Background(), meta); err != nil {
+		t.Fatalf("expected hook to pass, got %v", err)
+	}
+	config.Cfg.Hook.Exec.TaskBeforeStart = "exit 3"
+	var exitErr *exec.ExitError
+	if err := runBeforeStartHook(context.Background(), meta); !errors.As(err, &exitErr) || exitErr.ExitCode() != 3 {
+		t.Fatalf("expected exit code 3, got %v", err)
+	}
+	config.Cfg.Hook.Exec.TaskBeforeStart = "exec sleep 5"
+	config.Cfg.Hook.Exec.Timeout = 1
+	start := time.Now()
+	if err := runBeforeStartHook(context.Background(), meta); err == nil || time.Since(start) > 3*time.Second {
+		t.Fatalf("expected hook to time out, got %v after %s", err, time.Since(start))
+	}
+}
+
+func TestHookFiles(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("hook test uses sh")
+	}
+	out := filepath.Join(t.TempDir(), "out")
+	data := newHookData(EventSucceeded, TaskMeta{
+		TaskID: "t1",
+		Type:   tasktype.TaskTypeTgfiles,
+		Files: []FileMeta{
+			{Name: "a.jpg", Size: 1, StorageType: "local", StoragePath: "downloads/a.jpg", FilePath: "/data/downloads/a.jpg"},
+			{Name: "b.jpg", Size: 2, StorageType: "webdav", StoragePath: "/b.jpg"},
+		},
+	}, nil)
+	cmd := `printf '%s|%s' "$SAVEANY_FILES" '{{range .Files}}{{.Name}};{{end}}' > ` + out
+	if err := runHookCommand(context.Background(), cmd, data); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, names, _ := strings.Cut(string(got), "|")
+	var decoded []FileMeta
+	if err := json.Unmarshal([]byte(files), &decoded); err != nil {
+		t.Fatalf("SAVEANY_FILES is not a JSON list: %q", files)
+	}
+	if len(decoded) != 2 || decoded[0].FilePath != "/data/downloads/a.jpg" || decoded[1].StoragePath != "/b.jpg" {
+		t.Fatalf("unexpected files: %+v", decoded)
+	}
+	if names != "a.jpg;b.jpg;" {
+		t.Fatalf("unexpected template output: %q", names)
+	}
+}
+
+func TestLocalFilePath(t *testing.T) {
+	abs, err := filepath.Abs("downloads/a.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := localFilePath("local", "downloads/a.jpg"); got != abs {
+		t.Fatalf("expected %q, got %q", abs, got)
+	}
+	if got := localFilePath("webdav", "/a.jpg"); got != "" {
+		t.Fatalf("expected empty path for remote storage, got %q", got)
+	}
+}
@@ -0,0 +1,42 @@
+package httptask
+
+import (
+	"encoding/json"
+
+	"github.com/krau/SaveAny-Bot/core"
+)
+
+// HTTP 下载任务保存的 core.Checkpoint.Kind
+const CheckpointKind = "httpfile"
+
+// 恢复 HTTP 下载任务所需的数据. 恢复时重新获取文件信息并从头下载
+type CheckpointData struct {
+	URL               string            `json:"url"`
+	Headers           map[string]string `json:"headers,omitempty"`
+	FileName          string            `json:"file_name"`
+	StorageName       string            `json:"storage_name"`
+	Path              string            `json:"path"`
+	ProgressMessageID int               `json:"progress_message_id,omitempty"` // 恢复后继续使用的进度消息
+}
+
+func (t *Task) Checkpoint() (*core.Checkpoint, error) {
+	data := CheckpointData{
+		URL:         t.Request.URL,
+		Headers:     t.Request.Headers,
+		FileName:    t.Info.Name,
+		StorageName: t.Storage.Name(),
+		Path:        t.Path,
+	}
+	if p, ok := t.Progress.(*Progress); ok {
+		data.ProgressMessageID = p.MessageID
+	}
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	return &core.Checkpoint{TaskID: t.ID, UserID: t.UserID, Kind: CheckpointKind, Data: raw}, nil
+}
+
+func (t *Task) NotifyShutdown() {
+	t.Abort(core.ErrShutdown)
+}
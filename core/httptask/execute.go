@@ -0,0 +1,178 @@
+package httptask
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/krau/SaveAny-Bot/common/utils/fsutil"
+	"github.com/krau/SaveAny-Bot/config"
+	"github.com/krau/SaveAny-Bot/core"
+	"github.com/krau/SaveAny-Bot/pkg/diskquota"
+	"github.com/krau/SaveAny-Bot/pkg/enums/ctxkey"
+	"github.com/krau/SaveAny-Bot/pkg/slotpool"
+	"github.com/krau/SaveAny-Bot/storage"
+	"golang.org/x/sync/errgroup"
+)
+
+func (t *Task) Execute(ctx context.Context) error {
+	logger := log.FromContext(ctx).WithPrefix(fmt.Sprintf("http[%s]", t.Info.Name))
+	release, err := slotpool.Default().Acquire(ctx, t.UserID)
+	if err != nil {
+		logger.Debugf("Failed to acquire transfer slot: %v", err)
+		if t.Progress != nil {
+			t.Progress.OnDone(ctx, t, err)
+		}
+		return err
+	}
+	defer release()
+
+	if t.Progress != nil {
+		t.Progress.OnStart(ctx, t)
+	}
+	if t.stream {
+		return executeStream(ctx, t)
+	}
+
+	_, cannotStream := t.Storage.(storage.StorageCannotStream)
+	// 大小未知时只能按 0 预留, 由下载过程中的磁盘空间决定
+	reservation, stream, err := diskquota.Default().ReserveOrStream(ctx, max(t.Info.Size, 0), diskquota.WaitTimeout(), !cannotStream)
+	if err != nil {
+		err = fmt.Errorf("failed to reserve cache space: %w", err)
+		if t.Progress != nil {
+			t.Progress.OnDone(ctx, t, err)
+		}
+		return err
+	}
+	if stream {
+		logger.Warn("Not enough cache space, falling back to stream mode")
+		t.stream = true
+		return executeStream(ctx, t)
+	}
+	defer reservation.Release()
+
+	defer func() {
+		if t.Progress != nil {
+			t.Progress.OnDone(ctx, t, err)
+		}
+	}()
+
+	localFile, err := fsutil.CreateFile(t.localPath)
+	if err != nil {
+		err = fmt.Errorf("failed to create local file: %w", err)
+		return err
+	}
+	defer func() {
+		if err := localFile.CloseAndRemove(); err != nil {
+			logger.Errorf("Failed to close local file: %v", err)
+		}
+	}()
+
+	logger.Info("Starting http download")
+	if err = NewClient().Download(ctx, t.Request, t.Info, localFile, t.onProgress(ctx)); err != nil {
+		err = fmt.Errorf("failed to download file: %w", err)
+		return err
+	}
+	logger.Info("File downloaded successfully")
+	if path.Ext(t.Info.Name) == "" {
+		if ext := fsutil.DetectFileExt(t.localPath); ext != "" {
+			t.Path = t.Path + ext
+		}
+	}
+	var fileStat os.FileInfo
+	fileStat, err = os.Stat(t.localPath)
+	if err != nil {
+		err = fmt.Errorf("failed to get file stat: %w", err)
+		return err
+	}
+	vctx := context.WithValue(ctx, ctxkey.ContentLength, fileStat.Size())
+	for i := range config.Cfg.Retry + 1 {
+		if err = vctx.Err(); err != nil {
+			err = fmt.Errorf("context canceled while saving file: %w", err)
+			return err
+		}
+		var file *os.File
+		file, err = os.Open(t.localPath)
+		if err != nil {
+			err = fmt.Errorf("failed to open cache file: %w", err)
+			return err
+		}
+		t.savedPath, err = storage.Save(vctx, t.Storage, file, t.Path)
+		file.Close()
+		if err == nil {
+			return nil
+		}
+		if i == config.Cfg.Retry {
+			err = fmt.Errorf("failed to save file: %w", err)
+			return err
+		}
+		logger.Errorf("Failed to save file: %s, retrying...", err)
+		core.PublishRetrying(ctx, i+1, err)
+		select {
+		case <-vctx.Done():
+			err = fmt.Errorf("context canceled during retry delay: %w", vctx.Err())
+			return err
+		case <-time.After(time.Duration(i*500) * time.Millisecond):
+		}
+	}
+	return err
+}
+
+func executeStream(ctx context.Context, t *Task) error {
+	logger := log.FromContext(ctx).WithPrefix(fmt.Sprintf("http[%s]", t.Info.Name))
+
+	pr, pw := io.Pipe()
+	defer pr.Close()
+	errg, uploadCtx := errgroup.WithContext(ctx)
+	if t.Info.Size >= 0 {
+		uploadCtx = context.WithValue(uploadCtx, ctxkey.ContentLength, t.Info.Size)
+	}
+	var savedPath string
+	errg.Go(func() error {
+		var err error
+		savedPath, err = storage.Save(uploadCtx, t.Storage, pr, t.Path)
+		return err
+	})
+	errg.Go(func() error {
+		logger.Info("Starting http download in stream mode")
+		err := NewClient().Stream(uploadCtx, t.Request, t.Info, pw, t.onProgress(ctx))
+		if err != nil {
+			logger.Errorf("Failed to download file: %v", err)
+		}
+		pw.CloseWithError(err)
+		return err
+	})
+	var err error
+	defer func() {
+		if t.Progress != nil {
+			t.Progress.OnDone(ctx, t, err)
+		}
+	}()
+	if err = errg.Wait(); err != nil {
+		return err
+	}
+	t.savedPath = savedPath
+	logger.Info("File downloaded successfully in stream mode")
+	return nil
+}
+
+func (t *Task) onProgress(ctx context.Context) func(n int64) {
+	return func(n int64) {
+		downloaded := t.downloaded.Add(n)
+		if t.Progress != nil {
+			t.Progress.OnProgress(ctx, t, downloaded, t.Info.Size)
+		}
+		core.PublishProgress(ctx, downloaded, max(t.Info.Size, 0))
+	}
+}
+
+// 通知用户任务未执行就被中止
+func (t *Task) Abort(err error) {
+	if t.Progress != nil {
+		t.Progress.OnDone(t.Ctx, t, err)
+	}
+}
@@ -0,0 +1,166 @@
+package httptask
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/gotd/td/tg"
+	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/msgelem"
+	"github.com/krau/SaveAny-Bot/common/utils/dlutil"
+	"github.com/krau/SaveAny-Bot/common/utils/tgutil"
+	"github.com/krau/SaveAny-Bot/core"
+)
+
+type ProgressTracker interface {
+	OnStart(ctx context.Context, info TaskInfo)
+	OnProgress(ctx context.Context, info TaskInfo, downloaded, total int64)
+	OnDone(ctx context.Context, info TaskInfo, err error)
+}
+
+// 进度消息的最小更新间隔
+const progressUpdateInterval = 3 * time.Second
+
+type Progress struct {
+	MessageID  int
+	ChatID     int64
+	start      time.Time
+	mu         sync.Mutex
+	lastUpdate time.Time
+}
+
+func formatFileSize(size int64) string {
+	if size < 0 {
+		return "未知"
+	}
+	return msgelem.FormatSize(size)
+}
+
+func (p *Progress) OnStart(ctx context.Context, info TaskInfo) {
+	p.start = time.Now()
+	log.FromContext(ctx).Debugf("Progress tracking started for message %d in chat %d", p.MessageID, p.ChatID)
+
+	template := msgelem.NewInfoTemplate("🚀 开始下载", "")
+	template.AddItem("📄", "文件名", info.FileName(), msgelem.ItemTypeCode)
+	template.AddItem("🔗", "链接", info.URL(), msgelem.ItemTypeText)
+	template.AddItem("📂", "保存路径", fmt.Sprintf("[%s]:%s", info.StorageName(), path.Dir(info.StoragePath())), msgelem.ItemTypeCode)
+	template.AddItem("📦", "文件大小", formatFileSize(info.FileSize()), msgelem.ItemTypeText)
+	text, entities := template.BuildFormattedMessage()
+
+	markup := &tg.ReplyInlineMarkup{
+		Rows: []tg.KeyboardButtonRow{
+			{
+				Buttons: []tg.KeyboardButtonClass{
+					tgutil.BuildCancelButton(info.TaskID()),
+				},
+			},
+		},
+	}
+	ext := tgutil.ExtFromContext(ctx)
+	if ext != nil {
+		peer := &tg.InputPeerUser{UserID: p.ChatID}
+		if err := msgelem.EditWithFormattedText(ext, peer, p.MessageID, text, entities, markup); err != nil {
+			log.Warn("Failed to edit message for task start", "error", err, "task_id", info.TaskID())
+		}
+	}
+}
+
+func (p *Progress) OnProgress(ctx context.Context, info TaskInfo, downloaded, total int64) {
+	p.mu.Lock()
+	if time.Since(p.lastUpdate) < progressUpdateInterval {
+		p.mu.Unlock()
+		return
+	}
+	p.lastUpdate = time.Now()
+	p.mu.Unlock()
+	log.FromContext(ctx).Debugf("Progress update: %s, %d/%d", info.FileName(), downloaded, total)
+
+	template := msgelem.NewProcessingTemplate("正在下载", "")
+	template.AddItem("📄", "文件名", info.FileName(), msgelem.ItemTypeCode)
+	template.AddItem("📂", "保存路径", fmt.Sprintf("[%s]:%s", info.StorageName(), path.Dir(info.StoragePath())), msgelem.ItemTypeCode)
+	template.AddItem("📦", "文件大小", formatFileSize(total), msgelem.ItemTypeText)
+	if total > 0 {
+		template.AddProgressBar("📊", "传输进度", downloaded, total, 12)
+	} else {
+		template.AddItem("📥", "已下载", msgelem.FormatSize(downloaded), msgelem.ItemTypeText)
+	}
+	speed := dlutil.GetSpeed(downloaded, p.start)
+	template.AddItem("🚀", "平均速度", msgelem.FormatSize(int64(speed))+"/s", msgelem.ItemTypeText)
+	template.AddItem("⌚", "运行时间", msgelem.FormatDuration(time.Since(p.start)), msgelem.ItemTypeText)
+	if speed > 0 && total > 0 {
+		remaining := int64(float64(total-downloaded) / speed)
+		if remaining > 0 {
+			template.AddItem("⏱️", "预计剩余", msgelem.FormatDuration(time.Duration(remaining)*time.Second), msgelem.ItemTypeText)
+		}
+	}
+	text, entities := template.BuildFormattedMessage()
+
+	markup := &tg.ReplyInlineMarkup{
+		Rows: []tg.KeyboardButtonRow{
+			{
+				Buttons: []tg.KeyboardButtonClass{
+					tgutil.BuildCancelButton(info.TaskID()),
+					tgutil.BuildDetailButton(info.TaskID()),
+				},
+			},
+		},
+	}
+	ext := tgutil.ExtFromContext(ctx)
+	if ext != nil {
+		peer := &tg.InputPeerUser{UserID: p.ChatID}
+		if err := msgelem.EditWithFormattedText(ext, peer, p.MessageID, text, entities, markup); err != nil {
+			log.Warn("Failed to edit message for task progress", "error", err, "task_id", info.TaskID())
+		}
+	}
+}
+
+func (p *Progress) OnDone(ctx context.Context, info TaskInfo, err error) {
+	if err != nil {
+		log.FromContext(ctx).Errorf("Progress error for file [%s]: %v", info.FileName(), err)
+	} else {
+		log.FromContext(ctx).Debugf("Progress done for file [%s]", info.FileName())
+	}
+
+	var template *msgelem.MessageTemplate
+	if core.IsShutdown(ctx, err) {
+		template = msgelem.NewInfoTemplate("⏸ Bot 正在重启", "任务将在重启后自动恢复")
+		template.AddItem("📄", "文件名", info.FileName(), msgelem.ItemTypeCode)
+	} else if err != nil {
+		if errors.Is(err, context.Canceled) {
+			template = msgelem.NewErrorTemplate("任务已取消", "")
+			template.AddItem("📄", "文件名", info.FileName(), msgelem.ItemTypeCode)
+		} else {
+			template = msgelem.NewErrorTemplate("下载失败", "")
+			template.AddItem("📄", "文件名", info.FileName(), msgelem.ItemTypeCode)
+			template.AddItem("❗", "错误信息", err.Error(), msgelem.ItemTypeText)
+		}
+	} else {
+		template = msgelem.NewSuccessTemplate("下载完成", "")
+		template.AddItem("📄", "文件名", info.FileName(), msgelem.ItemTypeCode)
+		template.AddItem("📂", "保存路径", fmt.Sprintf("[%s]:%s", info.StorageName(), path.Dir(info.StoragePath())), msgelem.ItemTypeCode)
+		template.AddItem("📦", "文件大小", msgelem.FormatSize(info.Downloaded()), msgelem.ItemTypeText)
+		if !p.start.IsZero() {
+			template.AddItem("⌚", "总用时", msgelem.FormatDuration(time.Since(p.start)), msgelem.ItemTypeText)
+		}
+	}
+	text, entities := template.BuildFormattedMessage()
+
+	ext := tgutil.ExtFromContext(ctx)
+	if ext != nil && p.MessageID != 0 {
+		peer := &tg.InputPeerUser{UserID: p.ChatID}
+		if err := msgelem.EditWithFormattedText(ext, peer, p.MessageID, text, entities, nil); err != nil {
+			log.Warn("Failed to edit message for task completion", "error", err, "task_id", info.TaskID())
+		}
+	}
+}
+
+func NewProgressTrack(messageID int, chatID int64) ProgressTracker {
+	return &Progress{
+		MessageID: messageID,
+		ChatID:    chatID,
+	}
+}
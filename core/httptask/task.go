@@ -0,0 +1,71 @@
+package httptask
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sync/atomic"
+
+	"github.com/krau/SaveAny-Bot/config"
+	"github.com/krau/SaveAny-Bot/pkg/enums/tasktype"
+	"github.com/krau/SaveAny-Bot/pkg/httpdl"
+	"github.com/krau/SaveAny-Bot/storage"
+)
+
+type Task struct {
+	ID       string
+	Ctx      context.Context
+	UserID   int64 // telegram user id of the task owner
+	Request  httpdl.Request
+	Info     *httpdl.FileInfo
+	Storage  storage.Storage
+	Path     string
+	Progress ProgressTracker
+
+	stream     bool // true if the file should be downloaded in stream mode
+	localPath  string
+	savedPath  string // 实际保存的路径, 存储端另存为带后缀的文件时与 Path 不同
+	downloaded atomic.Int64
+}
+
+func (t *Task) Type() tasktype.TaskType {
+	return tasktype.TaskTypeHttpfile
+}
+
+// 使用配置创建下载客户端
+func NewClient() *httpdl.Client {
+	return httpdl.NewClient(config.Cfg.HTTP.Connections, config.Cfg.Retry, config.Cfg.HTTP.UserAgent)
+}
+
+func NewTask(
+	id string,
+	ctx context.Context,
+	userID int64,
+	req httpdl.Request,
+	info *httpdl.FileInfo,
+	stor storage.Storage,
+	path string,
+	progress ProgressTracker,
+) (*Task, error) {
+	task := &Task{
+		ID:       id,
+		Ctx:      ctx,
+		UserID:   userID,
+		Request:  req,
+		Info:     info,
+		Storage:  stor,
+		Path:     path,
+		Progress: progress,
+	}
+	_, ok := stor.(storage.StorageCannotStream)
+	if config.Cfg.Stream && !ok {
+		task.stream = true
+		return task, nil
+	}
+	cachePath, err := filepath.Abs(filepath.Join(config.Cfg.Temp.BasePath, fmt.Sprintf("%s_%s", id, info.Name)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get absolute path for cache: %w", err)
+	}
+	task.localPath = cachePath
+	return task, nil
+}
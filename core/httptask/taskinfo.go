@@ -0,0 +1,58 @@
+package httptask
+
+import (
+	"cmp"
+
+	"github.com/krau/SaveAny-Bot/core"
+)
+
+type TaskInfo interface {
+	TaskID() string
+	URL() string
+	FileName() string
+	FileSize() int64 // 未知时为 -1
+	Downloaded() int64
+	StoragePath() string
+	StorageName() string
+}
+
+func (t *Task) TaskID() string {
+	return t.ID
+}
+
+func (t *Task) URL() string {
+	return t.Request.URL
+}
+
+func (t *Task) FileName() string {
+	return t.Info.Name
+}
+
+func (t *Task) FileSize() int64 {
+	return t.Info.Size
+}
+
+func (t *Task) Downloaded() int64 {
+	return t.downloaded.Load()
+}
+
+func (t *Task) StoragePath() string {
+	return cmp.Or(t.savedPath, t.Path)
+}
+
+func (t *Task) StorageName() string {
+	return t.Storage.Name()
+}
+
+func (t *Task) Meta() core.TaskMeta {
+	return core.TaskMeta{
+		UserID:      t.UserID,
+		Title:       t.FileName(),
+		StorageName: t.StorageName(),
+		StorageType: t.Storage.Type().String(),
+		StoragePath: t.StoragePath(),
+		LocalPath:   t.localPath,
+		TotalBytes:  max(t.FileSize(), 0),
+		Count:       1,
+	}
+}
@@ -0,0 +1,40 @@
+package ivtask
+
+import (
+	"encoding/json"
+
+	"github.com/krau/SaveAny-Bot/core"
+)
+
+// Instant View 任务保存的 core.Checkpoint.Kind
+const CheckpointKind = "instantview"
+
+// 恢复 Instant View 任务所需的数据. 页面中的 file reference 会过期, 恢复时重新获取页面
+type CheckpointData struct {
+	URL               string `json:"url"`
+	StorageName       string `json:"storage_name"`
+	DirPath           string `json:"dir_path"`
+	EPUB              bool   `json:"epub"`
+	ProgressMessageID int    `json:"progress_message_id,omitempty"` // 恢复后继续使用的进度消息
+}
+
+func (t *Task) Checkpoint() (*core.Checkpoint, error) {
+	data := CheckpointData{
+		URL:         t.URL,
+		StorageName: t.Storage.Name(),
+		DirPath:     t.DirPath,
+		EPUB:        t.EPUB,
+	}
+	if p, ok := t.Progress.(*Progress); ok {
+		data.ProgressMessageID = p.MessageID
+	}
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	return &core.Checkpoint{TaskID: t.ID, UserID: t.UserID, Kind: CheckpointKind, Data: raw}, nil
+}
+
+func (t *Task) NotifyShutdown() {
+	t.Abort(core.ErrShutdown)
+}
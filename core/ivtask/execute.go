@@ -0,0 +1,132 @@
+package ivtask
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+
+	"github.com/charmbracelet/log"
+	"github.com/krau/SaveAny-Bot/config"
+	"github.com/krau/SaveAny-Bot/core"
+	"github.com/krau/SaveAny-Bot/pkg/slotpool"
+	"github.com/krau/SaveAny-Bot/pkg/telegraph"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	articleHTMLName     = "index.html"
+	articleMarkdownName = "index.md"
+	articleEPUBName     = "article.epub"
+)
+
+// 先下载页面引用的图片和文档到缓存目录并保存, 再生成引用本地媒体的 HTML, Markdown 和 EPUB
+func (t *Task) Execute(ctx context.Context) (err error) {
+	logger := log.FromContext(ctx)
+	logger.Infof("Starting Instant View task %s", t.URL)
+	if t.Progress != nil {
+		t.Progress.OnStart(ctx, t)
+	}
+	defer func() {
+		if err != nil {
+			logger.Errorf("Error during Instant View task execution: %v", err)
+		} else {
+			logger.Infof("Instant View task %s completed successfully", t.URL)
+		}
+		if t.Progress != nil {
+			t.Progress.OnDone(ctx, t, err)
+		}
+	}()
+	if err = os.MkdirAll(t.cacheDir, 0o755); err != nil {
+		return fmt.Errorf("failed to create cache dir: %w", err)
+	}
+	defer func() {
+		if err := os.RemoveAll(t.cacheDir); err != nil {
+			logger.Errorf("Failed to remove cache dir: %v", err)
+		}
+	}()
+
+	var mu sync.Mutex
+	local := make(map[string]string, len(t.medias))
+	images := make(map[string]string)
+	eg, gctx := errgroup.WithContext(ctx)
+	eg.SetLimit(config.Cfg.Workers)
+	for _, media := range t.medias {
+		eg.Go(func() error {
+			release, err := slotpool.Default().Acquire(gctx, t.UserID)
+			if err != nil {
+				return err
+			}
+			defer release()
+			file := t.files[media.Src]
+			if err := core.DownloadTGFile(gctx, file, filepath.Join(t.cacheDir, file.Name())); err != nil {
+				logger.Errorf("Error downloading media %s: %v", media.Src, err)
+				return fmt.Errorf("failed to download media %s: %w", file.Name(), err)
+			}
+			if err := t.saveLocal(gctx, file.Name()); err != nil {
+				return err
+			}
+			mu.Lock()
+			local[media.Src] = file.Name()
+			if media.Kind == telegraph.MediaImage {
+				images[media.Src] = file.Name()
+			}
+			mu.Unlock()
+			t.publishProgress(gctx)
+			return nil
+		})
+	}
+	if err = eg.Wait(); err != nil {
+		return err
+	}
+
+	if err = t.saveBytes(ctx, telegraph.RenderHTML(t.Page, local), articleHTMLName); err != nil {
+		return err
+	}
+	t.publishProgress(ctx)
+	if err = t.saveBytes(ctx, telegraph.RenderMarkdown(t.Page, local), articleMarkdownName); err != nil {
+		return err
+	}
+	t.publishProgress(ctx)
+	if t.EPUB {
+		var buf bytes.Buffer
+		err = telegraph.WriteEPUB(&buf, t.Page, images, func(name string) (io.ReadCloser, error) {
+			return os.Open(filepath.Join(t.cacheDir, name))
+		})
+		if err != nil {
+			return fmt.Errorf("failed to build epub: %w", err)
+		}
+		if err = t.saveBytes(ctx, buf.Bytes(), articleEPUBName); err != nil {
+			return err
+		}
+		t.publishProgress(ctx)
+	}
+	return nil
+}
+
+func (t *Task) publishProgress(ctx context.Context) {
+	core.PublishProgress(ctx, t.downloaded.Add(1), int64(t.total))
+	if t.Progress != nil {
+		t.Progress.OnProgress(ctx, t)
+	}
+}
+
+// 将缓存目录中的文件保存到存储
+func (t *Task) saveLocal(ctx context.Context, name string) error {
+	return core.SaveLocalFile(ctx, t.Storage.Save, path.Join(t.storDir(), name), filepath.Join(t.cacheDir, name))
+}
+
+func (t *Task) saveBytes(ctx context.Context, data []byte, name string) error {
+	return core.SaveBytes(ctx, t.Storage.Save, path.Join(t.storDir(), name), data)
+}
+
+// 通知用户任务未执行就被中止
+func (t *Task) Abort(err error) {
+	if t.Progress != nil {
+		t.Progress.OnDone(t.Ctx, t, err)
+	}
+}
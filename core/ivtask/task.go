@@ -0,0 +1,106 @@
+package ivtask
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"path/filepath"
+	"sync/atomic"
+
+	"github.com/gotd/td/telegram/downloader"
+	"github.com/gotd/td/tg"
+	"github.com/krau/SaveAny-Bot/config"
+	"github.com/krau/SaveAny-Bot/pkg/enums/tasktype"
+	"github.com/krau/SaveAny-Bot/pkg/instantview"
+	"github.com/krau/SaveAny-Bot/pkg/telegraph"
+	"github.com/krau/SaveAny-Bot/pkg/tfile"
+	"github.com/krau/SaveAny-Bot/storage"
+)
+
+type Task struct {
+	ID       string
+	Ctx      context.Context
+	UserID   int64 // telegram user id of the task owner
+	Page     *telegraph.Page
+	Storage  storage.Storage
+	DirPath  string // 存储中的目录, 文章保存在其中以标题命名的目录
+	EPUB     bool   // 同时生成 EPUB
+	Progress ProgressTracker
+	URL      string // 网页地址, 恢复任务时重新获取 Instant View 以得到新的 file reference
+
+	files      map[string]tfile.TGFile // 节点中的媒体地址到 Telegram 文件
+	medias     []telegraph.Media
+	cacheDir   string
+	total      int
+	downloaded atomic.Int64
+}
+
+func (t *Task) Type() tasktype.TaskType {
+	return tasktype.TaskTypeInstantview
+}
+
+func NewTask(
+	id string,
+	ctx context.Context,
+	userID int64,
+	wp *tg.WebPage,
+	client downloader.Client,
+	stor storage.Storage,
+	dirPath string,
+	epub bool,
+	progress ProgressTracker,
+) (*Task, error) {
+	page := instantview.Convert(wp)
+	if page == nil {
+		return nil, fmt.Errorf("web page %s has no instant view", wp.URL)
+	}
+	cacheDir, err := filepath.Abs(filepath.Join(config.Cfg.Temp.BasePath, fmt.Sprintf("iv_%s", id)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get absolute path for cache: %w", err)
+	}
+	refs := instantview.Medias(wp)
+	files := make(map[string]tfile.TGFile, len(refs))
+	var medias []telegraph.Media
+	for _, m := range telegraph.CollectMedia(page.Content) {
+		if m.Kind == telegraph.MediaEmbed {
+			// 外部嵌入内容只保留链接
+			continue
+		}
+		ref, ok := refs[m.Src]
+		if !ok {
+			continue
+		}
+		name := fmt.Sprintf("%d%s", len(medias)+1, instantview.Ext(ref))
+		file, err := tfile.FromMedia(ref, client, tfile.WithName(name))
+		if err != nil {
+			return nil, fmt.Errorf("failed to get file %s: %w", m.Src, err)
+		}
+		files[m.Src] = file
+		medias = append(medias, m)
+	}
+	// HTML 和 Markdown, 以及可选的 EPUB
+	total := len(medias) + 2
+	if epub {
+		total++
+	}
+	return &Task{
+		ID:       id,
+		Ctx:      ctx,
+		UserID:   userID,
+		Page:     page,
+		Storage:  stor,
+		DirPath:  dirPath,
+		EPUB:     epub,
+		Progress: progress,
+		URL:      wp.URL,
+		files:    files,
+		medias:   medias,
+		cacheDir: cacheDir,
+		total:    total,
+	}, nil
+}
+
+// 文章在存储中的目录
+func (t *Task) storDir() string {
+	return path.Join(t.DirPath, instantview.DirName(t.Page))
+}
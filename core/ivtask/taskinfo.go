@@ -0,0 +1,50 @@
+package ivtask
+
+import "github.com/krau/SaveAny-Bot/core"
+
+type TaskInfo interface {
+	TaskID() string
+	Title() string
+	TotalFiles() int // 媒体和文档的总数
+	Downloaded() int64
+	StorageName() string
+	StoragePath() string
+}
+
+func (t *Task) TaskID() string {
+	return t.ID
+}
+
+func (t *Task) Title() string {
+	return t.Page.Title
+}
+
+func (t *Task) TotalFiles() int {
+	return t.total
+}
+
+func (t *Task) Downloaded() int64 {
+	return t.downloaded.Load()
+}
+
+func (t *Task) StorageName() string {
+	return t.Storage.Name()
+}
+
+// 保存文章的目录
+func (t *Task) StoragePath() string {
+	return t.storDir()
+}
+
+func (t *Task) Meta() core.TaskMeta {
+	return core.TaskMeta{
+		UserID:        t.UserID,
+		Title:         t.Page.Title,
+		StorageName:   t.StorageName(),
+		StorageType:   t.Storage.Type().String(),
+		StoragePath:   t.StoragePath(),
+		LocalPath:     t.cacheDir,
+		Count:         t.total,
+		CountProgress: true,
+	}
+}
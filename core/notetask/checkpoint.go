@@ -0,0 +1,40 @@
+package notetask
+
+import (
+	"encoding/json"
+
+	"github.com/krau/SaveAny-Bot/core"
+)
+
+// 笔记任务保存的 core.Checkpoint.Kind
+const CheckpointKind = "note"
+
+// 恢复笔记任务所需的数据. 笔记内容较小, 直接保存生成好的内容
+type CheckpointData struct {
+	Title             string `json:"title"`
+	Content           []byte `json:"content"`
+	StorageName       string `json:"storage_name"`
+	Path              string `json:"path"`
+	ProgressMessageID int    `json:"progress_message_id,omitempty"` // 恢复后继续使用的进度消息
+}
+
+func (t *Task) Checkpoint() (*core.Checkpoint, error) {
+	data := CheckpointData{
+		Title:       t.title,
+		Content:     t.Content,
+		StorageName: t.Storage.Name(),
+		Path:        t.Path,
+	}
+	if p, ok := t.Progress.(*Progress); ok {
+		data.ProgressMessageID = p.MessageID
+	}
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	return &core.Checkpoint{TaskID: t.ID, UserID: t.UserID, Kind: CheckpointKind, Data: raw}, nil
+}
+
+func (t *Task) NotifyShutdown() {
+	t.Abort(core.ErrShutdown)
+}
@@ -0,0 +1,57 @@
+package notetask
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/charmbracelet/log"
+	"github.com/duke-git/lancet/v2/retry"
+	"github.com/krau/SaveAny-Bot/config"
+	"github.com/krau/SaveAny-Bot/core"
+	"github.com/krau/SaveAny-Bot/pkg/enums/ctxkey"
+	"github.com/krau/SaveAny-Bot/storage"
+	"go.uber.org/multierr"
+)
+
+func (t *Task) Execute(ctx context.Context) (err error) {
+	logger := log.FromContext(ctx)
+	logger.Infof("Saving note to %s", t.Path)
+	if t.Progress != nil {
+		t.Progress.OnStart(ctx, t)
+	}
+	defer func() {
+		if err != nil {
+			logger.Errorf("Failed to save note: %v", err)
+		}
+		if t.Progress != nil {
+			t.Progress.OnDone(ctx, t, err)
+		}
+	}()
+	vctx := context.WithValue(ctx, ctxkey.ContentLength, int64(len(t.Content)))
+	var lastErr error
+	attempt := 0
+	err = retry.Retry(func() error {
+		if attempt > 0 {
+			core.PublishRetrying(ctx, attempt, lastErr)
+		}
+		attempt++
+		t.savedPath, lastErr = storage.Save(vctx, t.Storage, bytes.NewReader(t.Content), t.Path)
+		if lastErr != nil {
+			lastErr = fmt.Errorf("failed to save note: %w", lastErr)
+		}
+		return lastErr
+	}, retry.Context(ctx), retry.RetryTimes(uint(config.Cfg.Retry)))
+	if err = multierr.Combine(err, lastErr); err != nil {
+		return err
+	}
+	core.PublishProgress(ctx, int64(len(t.Content)), int64(len(t.Content)))
+	return nil
+}
+
+// 通知用户任务未执行就被中止
+func (t *Task) Abort(err error) {
+	if t.Progress != nil {
+		t.Progress.OnDone(t.Ctx, t, err)
+	}
+}
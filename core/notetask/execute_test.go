@@ -0,0 +1,44 @@
+package notetask
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/krau/SaveAny-Bot/config"
+	storcfg "github.com/krau/SaveAny-Bot/config/storage"
+	"github.com/krau/SaveAny-Bot/storage/local"
+)
+
+func TestExecuteReportsRenamedPath(t *testing.T) {
+	old := config.Cfg.Retry
+	config.Cfg.Retry = 1
+	defer func() { config.Cfg.Retry = old }()
+
+	ctx := context.Background()
+	dir := t.TempDir()
+	stor := new(local.Local)
+	if err := stor.Init(ctx, &storcfg.LocalStorageConfig{BaseConfig: storcfg.BaseConfig{Name: "local"}, BasePath: dir}); err != nil {
+		t.Fatal(err)
+	}
+	storPath := stor.JoinStoragePath("note.md")
+	first := NewTask("a", ctx, 1, "note", []byte("first"), stor, storPath, nil)
+	second := NewTask("b", ctx, 1, "note", []byte("second"), stor, storPath, nil)
+	for _, task := range []*Task{first, second} {
+		if err := task.Execute(ctx); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if got := first.Meta().StoragePath; got != storPath {
+		t.Fatalf("expected first note at %q, got %q", storPath, got)
+	}
+	// 同名文件已存在, 第二个笔记另存为带后缀的文件
+	want := filepath.Join(dir, "note_1.md")
+	if got := second.Meta().StoragePath; got != want {
+		t.Fatalf("expected renamed path %q, got %q", want, got)
+	}
+	if data, err := os.ReadFile(want); err != nil || string(data) != "second" {
+		t.Fatalf("expected second note at the renamed path, got %q, %v", data, err)
+	}
+}
@@ -0,0 +1,77 @@
+package notetask
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+
+	"github.com/charmbracelet/log"
+	"github.com/gotd/td/tg"
+	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/msgelem"
+	"github.com/krau/SaveAny-Bot/common/utils/tgutil"
+	"github.com/krau/SaveAny-Bot/core"
+)
+
+type ProgressTracker interface {
+	OnStart(ctx context.Context, info TaskInfo)
+	OnDone(ctx context.Context, info TaskInfo, err error)
+}
+
+type Progress struct {
+	MessageID int
+	ChatID    int64
+}
+
+func (p *Progress) edit(ctx context.Context, info TaskInfo, template *msgelem.MessageTemplate, markup tg.ReplyMarkupClass) {
+	text, entities := template.BuildFormattedMessage()
+	ext := tgutil.ExtFromContext(ctx)
+	if ext == nil || p.MessageID == 0 {
+		return
+	}
+	peer := &tg.InputPeerUser{UserID: p.ChatID}
+	if err := msgelem.EditWithFormattedText(ext, peer, p.MessageID, text, entities, markup); err != nil {
+		log.Warn("Failed to edit message for note task", "error", err, "task_id", info.TaskID())
+	}
+}
+
+func (p *Progress) OnStart(ctx context.Context, info TaskInfo) {
+	template := msgelem.NewInfoTemplate("🚀 正在保存笔记", "")
+	template.AddItem("📝", "文件名", info.FileName(), msgelem.ItemTypeCode)
+	template.AddItem("📂", "保存路径", fmt.Sprintf("[%s]:%s", info.StorageName(), path.Dir(info.StoragePath())), msgelem.ItemTypeCode)
+	p.edit(ctx, info, template, &tg.ReplyInlineMarkup{
+		Rows: []tg.KeyboardButtonRow{{Buttons: []tg.KeyboardButtonClass{tgutil.BuildCancelButton(info.TaskID())}}},
+	})
+}
+
+func (p *Progress) OnDone(ctx context.Context, info TaskInfo, err error) {
+	if err != nil {
+		log.FromContext(ctx).Errorf("Progress error for note [%s]: %v", info.FileName(), err)
+	}
+	var template *msgelem.MessageTemplate
+	switch {
+	case core.IsShutdown(ctx, err):
+		template = msgelem.NewInfoTemplate("⏸ Bot 正在重启", "任务将在重启后自动恢复")
+		template.AddItem("📝", "文件名", info.FileName(), msgelem.ItemTypeCode)
+	case errors.Is(err, context.Canceled):
+		template = msgelem.NewErrorTemplate("任务已取消", "")
+		template.AddItem("📝", "文件名", info.FileName(), msgelem.ItemTypeCode)
+	case err != nil:
+		template = msgelem.NewErrorTemplate("保存失败", "")
+		template.AddItem("📝", "文件名", info.FileName(), msgelem.ItemTypeCode)
+		template.AddItem("❗", "错误信息", err.Error(), msgelem.ItemTypeText)
+	default:
+		template = msgelem.NewSuccessTemplate("保存完成", "")
+		template.AddItem("📝", "文件名", info.FileName(), msgelem.ItemTypeCode)
+		template.AddItem("📦", "文件大小", msgelem.FormatSize(info.FileSize()), msgelem.ItemTypeText)
+		template.AddItem("📂", "保存路径", fmt.Sprintf("[%s]:%s", info.StorageName(), info.StoragePath()), msgelem.ItemTypeCode)
+	}
+	p.edit(ctx, info, template, nil)
+}
+
+func NewProgressTrack(messageID int, chatID int64) ProgressTracker {
+	return &Progress{
+		MessageID: messageID,
+		ChatID:    chatID,
+	}
+}
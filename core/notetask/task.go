@@ -0,0 +1,47 @@
+package notetask
+
+import (
+	"context"
+
+	"github.com/krau/SaveAny-Bot/pkg/enums/tasktype"
+	"github.com/krau/SaveAny-Bot/storage"
+)
+
+type Task struct {
+	ID       string
+	Ctx      context.Context
+	UserID   int64 // telegram user id of the task owner
+	title    string
+	Content  []byte // 笔记的 Markdown 内容, 创建任务时生成
+	Storage  storage.Storage
+	Path     string
+	Progress ProgressTracker
+
+	savedPath string // 实际保存的路径, 存储端另存为带后缀的文件时与 Path 不同
+}
+
+func (t *Task) Type() tasktype.TaskType {
+	return tasktype.TaskTypeNote
+}
+
+func NewTask(
+	id string,
+	ctx context.Context,
+	userID int64,
+	title string,
+	content []byte,
+	stor storage.Storage,
+	path string,
+	progress ProgressTracker,
+) *Task {
+	return &Task{
+		ID:       id,
+		Ctx:      ctx,
+		UserID:   userID,
+		title:    title,
+		Content:  content,
+		Storage:  stor,
+		Path:     path,
+		Progress: progress,
+	}
+}
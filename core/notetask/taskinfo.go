@@ -0,0 +1,53 @@
+package notetask
+
+import (
+	"cmp"
+	"path"
+
+	"github.com/krau/SaveAny-Bot/core"
+)
+
+type TaskInfo interface {
+	TaskID() string
+	Title() string
+	FileName() string
+	FileSize() int64
+	StoragePath() string
+	StorageName() string
+}
+
+func (t *Task) TaskID() string {
+	return t.ID
+}
+
+func (t *Task) Title() string {
+	return t.title
+}
+
+func (t *Task) FileName() string {
+	return path.Base(t.Path)
+}
+
+func (t *Task) FileSize() int64 {
+	return int64(len(t.Content))
+}
+
+func (t *Task) StoragePath() string {
+	return cmp.Or(t.savedPath, t.Path)
+}
+
+func (t *Task) StorageName() string {
+	return t.Storage.Name()
+}
+
+func (t *Task) Meta() core.TaskMeta {
+	return core.TaskMeta{
+		UserID:      t.UserID,
+		Title:       t.FileName(),
+		StorageName: t.StorageName(),
+		StorageType: t.Storage.Type().String(),
+		StoragePath: t.StoragePath(),
+		TotalBytes:  t.FileSize(),
+		Count:       1,
+	}
+}
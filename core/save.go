@@ -0,0 +1,85 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+
+	"github.com/duke-git/lancet/v2/retry"
+	"github.com/krau/SaveAny-Bot/config"
+	"github.com/krau/SaveAny-Bot/pkg/enums/ctxkey"
+	"github.com/krau/SaveAny-Bot/pkg/tfile"
+	"go.uber.org/multierr"
+)
+
+// 保存到存储端的方法, 通常为 Storage.Save, 需要替换同名文件时使用 storage.Overwrite
+type SaveFunc func(ctx context.Context, r io.Reader, storagePath string) error
+
+// 按 config.Retry 重试保存, 每次尝试重新调用 open 获取内容, 重试时发布重试事件
+func SaveWithRetry(ctx context.Context, save SaveFunc, storPath string, size int64, open func() (io.ReadCloser, error)) error {
+	vctx := context.WithValue(ctx, ctxkey.ContentLength, size)
+	var lastErr error
+	attempt := 0
+	err := retry.Retry(func() error {
+		if attempt > 0 {
+			PublishRetrying(ctx, attempt, lastErr)
+		}
+		attempt++
+		var r io.ReadCloser
+		r, lastErr = open()
+		if lastErr != nil {
+			return lastErr
+		}
+		defer r.Close()
+		lastErr = save(vctx, r, storPath)
+		if lastErr != nil {
+			lastErr = fmt.Errorf("failed to save %s: %w", path.Base(storPath), lastErr)
+		}
+		return lastErr
+	}, retry.Context(ctx), retry.RetryTimes(uint(config.Cfg.Retry)))
+	return multierr.Combine(err, lastErr)
+}
+
+// 将本地文件保存到存储
+func SaveLocalFile(ctx context.Context, save SaveFunc, storPath, localPath string) error {
+	stat, err := os.Stat(localPath)
+	if err != nil {
+		return err
+	}
+	return SaveWithRetry(ctx, save, storPath, stat.Size(), func() (io.ReadCloser, error) {
+		return os.Open(localPath)
+	})
+}
+
+// 将内存中的数据保存到存储
+func SaveBytes(ctx context.Context, save SaveFunc, storPath string, data []byte) error {
+	return SaveWithRetry(ctx, save, storPath, int64(len(data)), func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(data)), nil
+	})
+}
+
+// 按 config.Retry 重试从 Telegram 下载文件到 localPath, 重试时发布重试事件
+func DownloadTGFile(ctx context.Context, file tfile.TGFile, localPath string) error {
+	var lastErr error
+	attempt := 0
+	err := retry.Retry(func() error {
+		if attempt > 0 {
+			PublishRetrying(ctx, attempt, lastErr)
+		}
+		attempt++
+		var f *os.File
+		f, lastErr = os.Create(localPath)
+		if lastErr != nil {
+			return lastErr
+		}
+		_, lastErr = tfile.NewDownloader(file).Stream(ctx, f)
+		if closeErr := f.Close(); lastErr == nil {
+			lastErr = closeErr
+		}
+		return lastErr
+	}, retry.Context(ctx), retry.RetryTimes(uint(config.Cfg.Retry)))
+	return multierr.Combine(err, lastErr)
+}
@@ -0,0 +1,35 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/krau/SaveAny-Bot/config"
+)
+
+func TestSaveBytesRetriesWithFreshReader(t *testing.T) {
+	old := config.Cfg.Retry
+	config.Cfg.Retry = 3
+	defer func() { config.Cfg.Retry = old }()
+
+	var got []string
+	save := func(ctx context.Context, r io.Reader, storagePath string) error {
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		got = append(got, storagePath+":"+string(data))
+		if len(got) == 1 {
+			return errors.New("temporary failure")
+		}
+		return nil
+	}
+	if err := SaveBytes(context.Background(), save, "dir/a.txt", []byte("hello")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got) != 2 || got[1] != "dir/a.txt:hello" {
+		t.Fatalf("unexpected attempts: %v", got)
+	}
+}
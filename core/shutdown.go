@@ -0,0 +1,125 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/krau/SaveAny-Bot/database"
+)
+
+// 任务因 Bot 关闭而中断. 作为任务 context 的取消原因, 也作为未开始任务的 OnDone 错误
+var ErrShutdown = errors.New("bot is shutting down")
+
+// 任务是否因 Bot 关闭而中断
+func IsShutdown(ctx context.Context, err error) bool {
+	return errors.Is(err, ErrShutdown) || errors.Is(context.Cause(ctx), ErrShutdown)
+}
+
+// 关闭时保存的任务数据, 下次启动时用于恢复任务
+type Checkpoint struct {
+	TaskID string
+	UserID int64
+	Kind   string // 恢复时用于选择解析方式, 由各任务包定义
+	Data   []byte // 由各任务类型定义的 JSON 数据
+}
+
+// 支持在关闭时保存并在下次启动时恢复的任务
+type Resumable interface {
+	Checkpoint() (*Checkpoint, error)
+	// 通知用户尚未开始的任务因关闭而中断. 执行中的任务通过 OnDone 通知
+	NotifyShutdown()
+}
+
+var (
+	shuttingDown atomic.Bool
+
+	interruptedMu sync.Mutex
+	interrupted   []Exectable // 因关闭而中断的执行中任务
+)
+
+// 执行中的任务被中断后多等待的时间, 让任务有机会更新进度消息
+var shutdownCancelWait = 10 * time.Second
+
+// 关闭时等待事件投递的最短时间
+var eventFlushMinWait = 5 * time.Second
+
+// 优雅关闭: 不再接受新任务, 等待执行中的任务最多 grace 时间, 超时后中断.
+// 未开始和被中断的任务保存到数据库, 下次启动时恢复
+func Shutdown(ctx context.Context, grace time.Duration) {
+	logger := log.FromContext(ctx)
+	shuttingDown.Store(true)
+	if queueInstance == nil {
+		return
+	}
+	deadline := time.Now().Add(grace)
+	pending := queueInstance.Drain()
+	logger.Infof("Waiting up to %s for running tasks to finish, %d queued tasks will be saved", grace, len(pending))
+
+	done := make(chan struct{})
+	go func() {
+		queueInstance.WaitRunning()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(grace):
+		running := queueInstance.Running()
+		logger.Warnf("Grace period exceeded, interrupting %d running tasks", len(running))
+		for _, task := range running {
+			task.CancelWithCause(ErrShutdown)
+		}
+		select {
+		case <-done:
+		case <-time.After(shutdownCancelWait):
+			logger.Warn("Some tasks did not stop in time")
+		}
+	}
+
+	for _, task := range pending {
+		if r, ok := task.Data.(Resumable); ok {
+			r.NotifyShutdown()
+		}
+		publish(ctx, Event{Type: EventCanceled, Task: metaOf(task.Data), Err: ErrShutdown})
+		saveCheckpoint(ctx, task.Data)
+	}
+	interruptedMu.Lock()
+	for _, task := range interrupted {
+		saveCheckpoint(ctx, task)
+	}
+	interrupted = nil
+	interruptedMu.Unlock()
+
+	// 关闭时发布的取消事件还在异步订阅者 (hook, webhook) 的队列中, 进程退出前等待它们处理完.
+	// 与等待任务共用 grace 的截止时间, 任务已用完时仍至少等待 eventFlushMinWait
+	if !FlushEvents(max(time.Until(deadline), eventFlushMinWait)) {
+		logger.Warn("Some task events were not delivered before shutdown")
+	}
+}
+
+func saveCheckpoint(ctx context.Context, task Exectable) {
+	logger := log.FromContext(ctx)
+	r, ok := task.(Resumable)
+	if !ok {
+		logger.Warnf("Task %s of type %s cannot be resumed, dropping", task.TaskID(), task.Type())
+		return
+	}
+	cp, err := r.Checkpoint()
+	if err != nil {
+		logger.Errorf("Failed to checkpoint task %s: %v", task.TaskID(), err)
+		return
+	}
+	if err := database.CreateTaskCheckpoint(ctx, &database.TaskCheckpoint{
+		TaskID: cp.TaskID,
+		ChatID: cp.UserID,
+		Kind:   cp.Kind,
+		Data:   string(cp.Data),
+	}); err != nil {
+		logger.Errorf("Failed to save checkpoint for task %s: %v", task.TaskID(), err)
+		return
+	}
+	logger.Infof("Saved checkpoint for task %s", task.TaskID())
+}
@@ -0,0 +1,42 @@
+package stickertask
+
+import (
+	"encoding/json"
+
+	"github.com/krau/SaveAny-Bot/core"
+)
+
+// 贴纸包任务保存的 core.Checkpoint.Kind
+const CheckpointKind = "sticker"
+
+// 恢复贴纸包任务所需的数据. 贴纸的 file reference 会过期, 恢复时按短名称重新获取贴纸包
+type CheckpointData struct {
+	ShortName         string `json:"short_name"`
+	StorageName       string `json:"storage_name"`
+	DirPath           string `json:"dir_path"`
+	Zip               bool   `json:"zip"`
+	Preview           bool   `json:"preview"`
+	ProgressMessageID int    `json:"progress_message_id,omitempty"` // 恢复后继续使用的进度消息
+}
+
+func (t *Task) Checkpoint() (*core.Checkpoint, error) {
+	data := CheckpointData{
+		ShortName:   t.Set.ShortName,
+		StorageName: t.Storage.Name(),
+		DirPath:     t.DirPath,
+		Zip:         t.Zip,
+		Preview:     t.Preview,
+	}
+	if p, ok := t.Progress.(*Progress); ok {
+		data.ProgressMessageID = p.MessageID
+	}
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	return &core.Checkpoint{TaskID: t.ID, UserID: t.UserID, Kind: CheckpointKind, Data: raw}, nil
+}
+
+func (t *Task) NotifyShutdown() {
+	t.Abort(core.ErrShutdown)
+}
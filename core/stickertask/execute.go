@@ -0,0 +1,286 @@
+package stickertask
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sync"
+
+	"github.com/charmbracelet/log"
+	"github.com/duke-git/lancet/v2/retry"
+	"github.com/gotd/td/tg"
+	"github.com/krau/SaveAny-Bot/config"
+	"github.com/krau/SaveAny-Bot/core"
+	"github.com/krau/SaveAny-Bot/pkg/enums/ctxkey"
+	"github.com/krau/SaveAny-Bot/pkg/slotpool"
+	"github.com/krau/SaveAny-Bot/pkg/stickerset"
+	"github.com/krau/SaveAny-Bot/pkg/tfile"
+	"go.uber.org/multierr"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	manifestName = "manifest.json"
+	previewDir   = "previews"
+)
+
+// 下载所有贴纸到缓存目录, 可选生成预览, 再保存为目录或打包为 zip 文件
+func (t *Task) Execute(ctx context.Context) (err error) {
+	logger := log.FromContext(ctx)
+	logger.Infof("Starting sticker set task %s", t.Set.ShortName)
+	if t.Progress != nil {
+		t.Progress.OnStart(ctx, t)
+	}
+	defer func() {
+		if err != nil {
+			logger.Errorf("Error during sticker set task execution: %v", err)
+		} else {
+			logger.Infof("Sticker set task %s completed successfully", t.Set.ShortName)
+		}
+		if t.Progress != nil {
+			t.Progress.OnDone(ctx, t, err)
+		}
+	}()
+	if err = os.MkdirAll(filepath.Join(t.cacheDir, previewDir), 0o755); err != nil {
+		return fmt.Errorf("failed to create cache dir: %w", err)
+	}
+	defer func() {
+		if err := os.RemoveAll(t.cacheDir); err != nil {
+			logger.Errorf("Failed to remove cache dir: %v", err)
+		}
+	}()
+	preview := t.Preview
+	if preview {
+		if _, err := exec.LookPath(config.Cfg.Sticker.FFmpeg); err != nil {
+			logger.Warnf("ffmpeg not found, skip sticker previews: %v", err)
+			preview = false
+		}
+	}
+
+	var mu sync.Mutex
+	previews := make(map[int]string)
+	eg, gctx := errgroup.WithContext(ctx)
+	eg.SetLimit(config.Cfg.Workers)
+	for _, sticker := range t.Set.Stickers {
+		eg.Go(func() error {
+			release, err := slotpool.Default().Acquire(gctx, t.UserID)
+			if err != nil {
+				return err
+			}
+			defer release()
+			doc := sticker.Document
+			file := tfile.NewTGFile(doc.AsInputDocumentFileLocation(), t.client, doc.Size, sticker.FileName(), tfile.WithDC(doc.DCID))
+			if err := t.download(gctx, file); err != nil {
+				return fmt.Errorf("failed to download sticker %s: %w", sticker.FileName(), err)
+			}
+			if !t.Zip {
+				if err := t.saveLocal(gctx, sticker.FileName()); err != nil {
+					return err
+				}
+			}
+			if preview {
+				name, err := t.makePreview(gctx, sticker)
+				if err != nil {
+					// 预览是可选的, 失败时只记录日志
+					logger.Warnf("Failed to make preview of sticker %s: %v", sticker.FileName(), err)
+				} else if name != "" {
+					if !t.Zip {
+						if err := t.saveLocal(gctx, name); err != nil {
+							return err
+						}
+					}
+					mu.Lock()
+					previews[sticker.Index] = name
+					mu.Unlock()
+				}
+			}
+			t.publishProgress(gctx)
+			return nil
+		})
+	}
+	if err = eg.Wait(); err != nil {
+		return err
+	}
+
+	manifest, err := t.Set.Manifest(previews)
+	if err != nil {
+		return fmt.Errorf("failed to build manifest: %w", err)
+	}
+	if !t.Zip {
+		if err = t.saveBytes(ctx, manifest, manifestName); err != nil {
+			return err
+		}
+		t.publishProgress(ctx)
+		return nil
+	}
+	zipName := t.Set.ShortName + ".zip"
+	if err = t.writeZip(zipName, manifest, previews); err != nil {
+		return fmt.Errorf("failed to build zip: %w", err)
+	}
+	if err = t.saveLocalAs(ctx, zipName, t.storPath()); err != nil {
+		return err
+	}
+	t.publishProgress(ctx)
+	return nil
+}
+
+func (t *Task) publishProgress(ctx context.Context) {
+	core.PublishProgress(ctx, t.downloaded.Add(1), int64(t.total))
+	if t.Progress != nil {
+		t.Progress.OnProgress(ctx, t)
+	}
+}
+
+// 在缓存目录中生成贴纸的预览, 返回相对于贴纸包目录的文件名. 没有可用的缩略图时返回空
+func (t *Task) makePreview(ctx context.Context, sticker stickerset.Sticker) (string, error) {
+	src := filepath.Join(t.cacheDir, sticker.FileName())
+	if sticker.Kind == stickerset.KindAnimated {
+		thumb := sticker.ThumbSize()
+		if thumb == "" {
+			return "", nil
+		}
+		doc := sticker.Document
+		name := fmt.Sprintf("%03d_thumb.webp", sticker.Index)
+		file := tfile.NewTGFile(&tg.InputDocumentFileLocation{
+			ID:            doc.ID,
+			AccessHash:    doc.AccessHash,
+			FileReference: doc.FileReference,
+			ThumbSize:     thumb,
+		}, t.client, 0, name, tfile.WithDC(doc.DCID))
+		if err := t.download(ctx, file); err != nil {
+			return "", fmt.Errorf("failed to download thumbnail: %w", err)
+		}
+		src = filepath.Join(t.cacheDir, name)
+	}
+	name := path.Join(previewDir, sticker.PreviewName())
+	if err := stickerset.Convert(ctx, config.Cfg.Sticker.FFmpeg, sticker.Kind, src, filepath.Join(t.cacheDir, name)); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// 将缓存目录中的贴纸, 预览和 manifest.json 打包到缓存目录中的 zip 文件
+func (t *Task) writeZip(zipName string, manifest []byte, previews map[int]string) error {
+	f, err := os.Create(filepath.Join(t.cacheDir, zipName))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	zw := zip.NewWriter(f)
+	addFile := func(name string) error {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Store})
+		if err != nil {
+			return err
+		}
+		src, err := os.Open(filepath.Join(t.cacheDir, name))
+		if err != nil {
+			return err
+		}
+		defer src.Close()
+		_, err = io.Copy(w, src)
+		return err
+	}
+	for _, sticker := range t.Set.Stickers {
+		if err := addFile(sticker.FileName()); err != nil {
+			return err
+		}
+		if name, ok := previews[sticker.Index]; ok {
+			if err := addFile(name); err != nil {
+				return err
+			}
+		}
+	}
+	w, err := zw.Create(manifestName)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(manifest); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// 从 Telegram 下载文件到缓存目录
+func (t *Task) download(ctx context.Context, file tfile.TGFile) error {
+	var lastErr error
+	attempt := 0
+	err := retry.Retry(func() error {
+		if attempt > 0 {
+			core.PublishRetrying(ctx, attempt, lastErr)
+		}
+		attempt++
+		var f *os.File
+		f, lastErr = os.Create(filepath.Join(t.cacheDir, file.Name()))
+		if lastErr != nil {
+			return lastErr
+		}
+		_, lastErr = tfile.NewDownloader(file).Stream(ctx, f)
+		if closeErr := f.Close(); lastErr == nil {
+			lastErr = closeErr
+		}
+		return lastErr
+	}, retry.Context(ctx), retry.RetryTimes(uint(config.Cfg.Retry)))
+	return multierr.Combine(err, lastErr)
+}
+
+// 将缓存目录中的文件保存到贴纸包目录
+func (t *Task) saveLocal(ctx context.Context, name string) error {
+	return t.saveLocalAs(ctx, name, path.Join(t.storPath(), name))
+}
+
+func (t *Task) saveLocalAs(ctx context.Context, name, storPath string) error {
+	localPath := filepath.Join(t.cacheDir, name)
+	stat, err := os.Stat(localPath)
+	if err != nil {
+		return err
+	}
+	return t.save(ctx, storPath, stat.Size(), func() (io.ReadCloser, error) {
+		return os.Open(localPath)
+	})
+}
+
+func (t *Task) saveBytes(ctx context.Context, data []byte, name string) error {
+	return t.save(ctx, path.Join(t.storPath(), name), int64(len(data)), func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(data)), nil
+	})
+}
+
+func (t *Task) save(ctx context.Context, storPath string, size int64, open func() (io.ReadCloser, error)) error {
+	vctx := context.WithValue(ctx, ctxkey.ContentLength, size)
+	var lastErr error
+	attempt := 0
+	err := retry.Retry(func() error {
+		if attempt > 0 {
+			core.PublishRetrying(ctx, attempt, lastErr)
+		}
+		attempt++
+		var r io.ReadCloser
+		r, lastErr = open()
+		if lastErr != nil {
+			return lastErr
+		}
+		defer r.Close()
+		lastErr = t.Storage.Save(vctx, r, storPath)
+		if lastErr != nil {
+			lastErr = fmt.Errorf("failed to save %s: %w", path.Base(storPath), lastErr)
+		}
+		return lastErr
+	}, retry.Context(ctx), retry.RetryTimes(uint(config.Cfg.Retry)))
+	return multierr.Combine(err, lastErr)
+}
+
+// 通知用户任务未执行就被中止
+func (t *Task) Abort(err error) {
+	if t.Progress != nil {
+		t.Progress.OnDone(t.Ctx, t, err)
+	}
+}
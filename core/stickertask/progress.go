@@ -0,0 +1,111 @@
+package stickertask
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/gotd/td/tg"
+	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/msgelem"
+	"github.com/krau/SaveAny-Bot/common/utils/tgutil"
+	"github.com/krau/SaveAny-Bot/core"
+)
+
+type ProgressTracker interface {
+	OnStart(ctx context.Context, info TaskInfo)
+	OnProgress(ctx context.Context, info TaskInfo)
+	OnDone(ctx context.Context, info TaskInfo, err error)
+}
+
+// 进度消息的最小更新间隔
+const progressUpdateInterval = 3 * time.Second
+
+type Progress struct {
+	MessageID  int
+	ChatID     int64
+	start      time.Time
+	mu         sync.Mutex
+	lastUpdate time.Time
+}
+
+func (p *Progress) edit(ctx context.Context, info TaskInfo, template *msgelem.MessageTemplate, markup tg.ReplyMarkupClass) {
+	text, entities := template.BuildFormattedMessage()
+	ext := tgutil.ExtFromContext(ctx)
+	if ext == nil || p.MessageID == 0 {
+		return
+	}
+	peer := &tg.InputPeerUser{UserID: p.ChatID}
+	if err := msgelem.EditWithFormattedText(ext, peer, p.MessageID, text, entities, markup); err != nil {
+		log.Warn("Failed to edit message for sticker set task", "error", err, "task_id", info.TaskID())
+	}
+}
+
+func (p *Progress) OnStart(ctx context.Context, info TaskInfo) {
+	p.start = time.Now()
+	template := msgelem.NewInfoTemplate("🚀 开始保存贴纸包", "")
+	template.AddItem("🏷", "贴纸包", info.Title(), msgelem.ItemTypeCode)
+	template.AddItem("📄", "贴纸数量", fmt.Sprintf("%d", info.Count()), msgelem.ItemTypeText)
+	template.AddItem("📂", "保存路径", fmt.Sprintf("[%s]:%s", info.StorageName(), info.StoragePath()), msgelem.ItemTypeCode)
+	p.edit(ctx, info, template, &tg.ReplyInlineMarkup{
+		Rows: []tg.KeyboardButtonRow{{Buttons: []tg.KeyboardButtonClass{tgutil.BuildCancelButton(info.TaskID())}}},
+	})
+}
+
+func (p *Progress) OnProgress(ctx context.Context, info TaskInfo) {
+	p.mu.Lock()
+	if time.Since(p.lastUpdate) < progressUpdateInterval {
+		p.mu.Unlock()
+		return
+	}
+	p.lastUpdate = time.Now()
+	p.mu.Unlock()
+
+	template := msgelem.NewProcessingTemplate("正在保存贴纸包", "")
+	template.AddItem("🏷", "贴纸包", info.Title(), msgelem.ItemTypeCode)
+	template.AddProgressBar("📊", "下载进度", info.Downloaded(), int64(info.TotalFiles()), 12)
+	template.AddItem("📏", "已下载", fmt.Sprintf("%d/%d", info.Downloaded(), info.TotalFiles()), msgelem.ItemTypeText)
+	p.edit(ctx, info, template, &tg.ReplyInlineMarkup{
+		Rows: []tg.KeyboardButtonRow{{Buttons: []tg.KeyboardButtonClass{
+			tgutil.BuildCancelButton(info.TaskID()),
+			tgutil.BuildDetailButton(info.TaskID()),
+		}}},
+	})
+}
+
+func (p *Progress) OnDone(ctx context.Context, info TaskInfo, err error) {
+	if err != nil {
+		log.FromContext(ctx).Errorf("Progress error for sticker set [%s]: %v", info.Title(), err)
+	}
+	var template *msgelem.MessageTemplate
+	switch {
+	case core.IsShutdown(ctx, err):
+		template = msgelem.NewInfoTemplate("⏸ Bot 正在重启", "任务将在重启后自动恢复")
+		template.AddItem("🏷", "贴纸包", info.Title(), msgelem.ItemTypeCode)
+	case errors.Is(err, context.Canceled):
+		template = msgelem.NewErrorTemplate("任务已取消", "")
+		template.AddItem("🏷", "贴纸包", info.Title(), msgelem.ItemTypeCode)
+	case err != nil:
+		template = msgelem.NewErrorTemplate("保存失败", "")
+		template.AddItem("🏷", "贴纸包", info.Title(), msgelem.ItemTypeCode)
+		template.AddItem("❗", "错误信息", err.Error(), msgelem.ItemTypeText)
+	default:
+		template = msgelem.NewSuccessTemplate("保存完成", "")
+		template.AddItem("🏷", "贴纸包", info.Title(), msgelem.ItemTypeCode)
+		template.AddItem("📄", "贴纸数量", fmt.Sprintf("%d", info.Count()), msgelem.ItemTypeText)
+		template.AddItem("📂", "保存路径", fmt.Sprintf("[%s]:%s", info.StorageName(), info.StoragePath()), msgelem.ItemTypeCode)
+		if !p.start.IsZero() {
+			template.AddItem("⌚", "总用时", msgelem.FormatDuration(time.Since(p.start)), msgelem.ItemTypeText)
+		}
+	}
+	p.edit(ctx, info, template, nil)
+}
+
+func NewProgressTrack(messageID int, chatID int64) ProgressTracker {
+	return &Progress{
+		MessageID: messageID,
+		ChatID:    chatID,
+	}
+}
@@ -0,0 +1,77 @@
+package stickertask
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"path/filepath"
+	"sync/atomic"
+
+	"github.com/gotd/td/telegram/downloader"
+	"github.com/krau/SaveAny-Bot/config"
+	"github.com/krau/SaveAny-Bot/pkg/enums/tasktype"
+	"github.com/krau/SaveAny-Bot/pkg/stickerset"
+	"github.com/krau/SaveAny-Bot/storage"
+)
+
+type Task struct {
+	ID       string
+	Ctx      context.Context
+	UserID   int64 // telegram user id of the task owner
+	Set      *stickerset.Set
+	Storage  storage.Storage
+	DirPath  string // 存储中的目录, 贴纸包保存在其中以短名称命名的目录或 zip 文件
+	Zip      bool   // 打包为 zip 文件
+	Preview  bool   // 生成 PNG/GIF 预览
+	Progress ProgressTracker
+
+	client     downloader.Client
+	cacheDir   string
+	total      int
+	downloaded atomic.Int64
+}
+
+func (t *Task) Type() tasktype.TaskType {
+	return tasktype.TaskTypeSticker
+}
+
+func NewTask(
+	id string,
+	ctx context.Context,
+	userID int64,
+	set *stickerset.Set,
+	client downloader.Client,
+	stor storage.Storage,
+	dirPath string,
+	zip bool,
+	preview bool,
+	progress ProgressTracker,
+) (*Task, error) {
+	cacheDir, err := filepath.Abs(filepath.Join(config.Cfg.Temp.BasePath, fmt.Sprintf("sticker_%s", id)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get absolute path for cache: %w", err)
+	}
+	return &Task{
+		ID:       id,
+		Ctx:      ctx,
+		UserID:   userID,
+		Set:      set,
+		Storage:  stor,
+		DirPath:  dirPath,
+		Zip:      zip,
+		Preview:  preview,
+		Progress: progress,
+		client:   client,
+		cacheDir: cacheDir,
+		// 所有贴纸和 manifest.json
+		total: len(set.Stickers) + 1,
+	}, nil
+}
+
+// 贴纸包在存储中的目录, 打包时为 zip 文件的路径
+func (t *Task) storPath() string {
+	if t.Zip {
+		return path.Join(t.DirPath, t.Set.ShortName+".zip")
+	}
+	return path.Join(t.DirPath, t.Set.ShortName)
+}
@@ -0,0 +1,55 @@
+package stickertask
+
+import "github.com/krau/SaveAny-Bot/core"
+
+type TaskInfo interface {
+	TaskID() string
+	Title() string
+	Count() int      // 贴纸数量
+	TotalFiles() int // 所有贴纸和 manifest.json
+	Downloaded() int64
+	StorageName() string
+	StoragePath() string
+}
+
+func (t *Task) TaskID() string {
+	return t.ID
+}
+
+func (t *Task) Title() string {
+	return t.Set.Title
+}
+
+func (t *Task) Count() int {
+	return len(t.Set.Stickers)
+}
+
+func (t *Task) TotalFiles() int {
+	return t.total
+}
+
+func (t *Task) Downloaded() int64 {
+	return t.downloaded.Load()
+}
+
+func (t *Task) StorageName() string {
+	return t.Storage.Name()
+}
+
+// 保存贴纸包的目录或 zip 文件
+func (t *Task) StoragePath() string {
+	return t.storPath()
+}
+
+func (t *Task) Meta() core.TaskMeta {
+	return core.TaskMeta{
+		UserID:        t.UserID,
+		Title:         t.Set.Title,
+		StorageName:   t.StorageName(),
+		StorageType:   t.Storage.Type().String(),
+		StoragePath:   t.StoragePath(),
+		LocalPath:     t.cacheDir,
+		Count:         t.Count(),
+		CountProgress: true,
+	}
+}
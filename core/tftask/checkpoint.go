@@ -0,0 +1,58 @@
+package tftask
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/krau/SaveAny-Bot/core"
+	"github.com/krau/SaveAny-Bot/pkg/tfile"
+)
+
+// 单文件任务保存的 core.Checkpoint.Kind
+const CheckpointKind = "tgfile"
+
+// 恢复单文件任务所需的数据
+type CheckpointData struct {
+	ChatID            int64  `json:"chat_id"`               // 文件来源消息所在的 chat
+	MessageID         int    `json:"message_id"`            // 文件来源消息, 来自故事时为故事 ID
+	LocationID        int64  `json:"location_id,omitempty"` // 消息中有多个文件时用于找回同一个文件
+	Story             bool   `json:"story,omitempty"`
+	FileName          string `json:"file_name"`
+	CustomName        string `json:"custom_name,omitempty"`
+	StorageName       string `json:"storage_name"`
+	Path              string `json:"path"`
+	ProgressMessageID int    `json:"progress_message_id,omitempty"` // 恢复后继续使用的进度消息
+}
+
+func (t *Task) Checkpoint() (*core.Checkpoint, error) {
+	chatID, msgID, ok := tfile.MessageSource(t.File)
+	story := false
+	if !ok {
+		chatID, msgID, story = tfile.StorySource(t.File)
+	}
+	if !ok && !story {
+		return nil, errors.New("file is not from a message")
+	}
+	data := CheckpointData{
+		ChatID:      chatID,
+		MessageID:   msgID,
+		LocationID:  tfile.LocationID(t.File),
+		Story:       story,
+		FileName:    t.File.Name(),
+		CustomName:  t.customName,
+		StorageName: t.Storage.Name(),
+		Path:        t.Path,
+	}
+	if p, ok := t.Progress.(*Progress); ok {
+		data.ProgressMessageID = p.MessageID
+	}
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	return &core.Checkpoint{TaskID: t.ID, UserID: t.UserID, Kind: CheckpointKind, Data: raw}, nil
+}
+
+func (t *Task) NotifyShutdown() {
+	t.Abort(core.ErrShutdown)
+}
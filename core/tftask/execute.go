@@ -10,8 +10,12 @@ import (
 	"github.com/charmbracelet/log"
 	"github.com/krau/SaveAny-Bot/common/utils/fsutil"
 	"github.com/krau/SaveAny-Bot/config"
+	"github.com/krau/SaveAny-Bot/core"
+	"github.com/krau/SaveAny-Bot/pkg/diskquota"
 	"github.com/krau/SaveAny-Bot/pkg/enums/ctxkey"
+	"github.com/krau/SaveAny-Bot/pkg/slotpool"
 	"github.com/krau/SaveAny-Bot/pkg/tfile"
+	"github.com/krau/SaveAny-Bot/storage"
 )
 
 func (t *Task) Execute(ctx context.Context) error {
@@ -23,6 +27,16 @@ func (t *Task) Execute(ctx context.Context) error {
 		return err
 	}
 	
+	release, err := slotpool.Default().Acquire(ctx, t.UserID)
+	if err != nil {
+		logger.Debugf("Failed to acquire transfer slot: %v", err)
+		if t.Progress != nil {
+			t.Progress.OnDone(ctx, t, err)
+		}
+		return err
+	}
+	defer release()
+
 	if t.Progress != nil {
 		t.Progress.OnStart(ctx, t)
 	}
@@ -30,6 +44,22 @@ func (t *Task) Execute(ctx context.Context) error {
 		return executeStream(ctx, t)
 	}
 
+	_, cannotStream := t.Storage.(storage.StorageCannotStream)
+	reservation, stream, err := diskquota.Default().ReserveOrStream(ctx, t.File.Size(), diskquota.WaitTimeout(), !cannotStream)
+	if err != nil {
+		err = fmt.Errorf("failed to reserve cache space: %w", err)
+		if t.Progress != nil {
+			t.Progress.OnDone(ctx, t, err)
+		}
+		return err
+	}
+	if stream {
+		logger.Warn("Not enough cache space, falling back to stream mode")
+		t.stream = true
+		return executeStream(ctx, t)
+	}
+	defer reservation.Release()
+
 	logger.Info("Starting file download")
 	
 	// Check context before creating file
@@ -90,11 +120,12 @@ func (t *Task) Execute(ctx context.Context) error {
 			return fmt.Errorf("failed to open cache file: %w", err)
 		}
 		defer file.Close()
-		if err = t.Storage.Save(vctx, file, t.Path); err != nil {
+		if t.savedPath, err = storage.Save(vctx, t.Storage, file, t.Path); err != nil {
 			if i == config.Cfg.Retry {
 				return fmt.Errorf("failed to save file: %w", err)
 			}
 			logger.Errorf("Failed to save file: %s, retrying...", err)
+			core.PublishRetrying(ctx, i+1, err)
 			select {
 			case <-vctx.Done():
 				return fmt.Errorf("context canceled during retry delay: %w", vctx.Err())
@@ -107,3 +138,10 @@ func (t *Task) Execute(ctx context.Context) error {
 	return fmt.Errorf("failed to save file after retries")
 
 }
+
+// 通知用户任务未执行就被中止
+func (t *Task) Abort(err error) {
+	if t.Progress != nil {
+		t.Progress.OnDone(t.Ctx, t, err)
+	}
+}
@@ -13,6 +13,7 @@ import (
 	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/msgelem"
 	"github.com/krau/SaveAny-Bot/common/utils/dlutil"
 	"github.com/krau/SaveAny-Bot/common/utils/tgutil"
+	"github.com/krau/SaveAny-Bot/core"
 )
 
 type ProgressTracker interface {
@@ -133,7 +134,10 @@ func (p *Progress) OnDone(ctx context.Context, info TaskInfo, err error) {
 
 	var template *msgelem.MessageTemplate
 	
-	if err != nil {
+	if core.IsShutdown(ctx, err) {
+		template = msgelem.NewInfoTemplate("⏸ Bot 正在重启", "任务将在重启后自动恢复")
+		template.AddItem("📄", "文件名", info.FileName(), msgelem.ItemTypeCode)
+	} else if err != nil {
 		if errors.Is(err, context.Canceled) {
 			template = msgelem.NewErrorTemplate("任务已取消", "")
 			template.AddItem("📄", "文件名", info.FileName(), msgelem.ItemTypeCode)
@@ -147,14 +151,15 @@ func (p *Progress) OnDone(ctx context.Context, info TaskInfo, err error) {
 		template.AddItem("📄", "文件名", info.FileName(), msgelem.ItemTypeCode)
 		template.AddItem("📂", "保存路径", fmt.Sprintf("[%s]:%s", info.StorageName(), path.Dir(info.StoragePath())), msgelem.ItemTypeCode)
 		
-		elapsed := time.Since(p.start)
-		template.AddItem("⌚", "总用时", msgelem.FormatDuration(elapsed), msgelem.ItemTypeText)
+		if !p.start.IsZero() {
+			template.AddItem("⌚", "总用时", msgelem.FormatDuration(time.Since(p.start)), msgelem.ItemTypeText)
+		}
 	}
 
 	text, entities := template.BuildFormattedMessage()
 
 	ext := tgutil.ExtFromContext(ctx)
-	if ext != nil {
+	if ext != nil && p.MessageID != 0 {
 		peer := &tg.InputPeerUser{UserID: p.ChatID}
 		if err := msgelem.EditWithFormattedText(ext, peer, p.MessageID, text, entities, nil); err != nil {
 			log.Warn("Failed to edit message for task completion", "error", err, "task_id", info.TaskID())
@@ -7,6 +7,7 @@ import (
 
 	"github.com/charmbracelet/log"
 	"github.com/krau/SaveAny-Bot/pkg/tfile"
+	"github.com/krau/SaveAny-Bot/storage"
 	"golang.org/x/sync/errgroup"
 )
 
@@ -16,8 +17,11 @@ func executeStream(ctx context.Context, task *Task) error {
 	pr, pw := io.Pipe()
 	defer pr.Close()
 	errg, uploadCtx := errgroup.WithContext(ctx)
+	var savedPath string
 	errg.Go(func() error {
-		return task.Storage.Save(uploadCtx, pr, task.Path)
+		var err error
+		savedPath, err = storage.Save(uploadCtx, task.Storage, pr, task.Path)
+		return err
 	})
 	wr := newWriter(ctx, pw, task.Progress, task)
 	errg.Go(func() error {
@@ -39,6 +43,7 @@ func executeStream(ctx context.Context, task *Task) error {
 	if err = errg.Wait(); err != nil {
 		return err
 	}
+	task.savedPath = savedPath
 	logger.Info("File downloaded successfully in stream mode")
 	return nil
 }
@@ -1,5 +1,11 @@
 package tftask
 
+import (
+	"cmp"
+	"github.com/krau/SaveAny-Bot/core"
+	"github.com/krau/SaveAny-Bot/pkg/tfile"
+)
+
 type TaskInfo interface {
 	TaskID() string
 	FileName() string
@@ -25,9 +31,24 @@ func (t *Task) FileSize() int64 {
 }
 
 func (t *Task) StoragePath() string {
-	return t.Path
+	return cmp.Or(t.savedPath, t.Path)
 }
 
 func (t *Task) StorageName() string {
 	return t.Storage.Name()
 }
+
+func (t *Task) Meta() core.TaskMeta {
+	meta := core.TaskMeta{
+		UserID:      t.UserID,
+		Title:       t.FileName(),
+		StorageName: t.StorageName(),
+		StorageType: t.Storage.Type().String(),
+		StoragePath: t.StoragePath(),
+		LocalPath:   t.localPath,
+		TotalBytes:  t.FileSize(),
+		Count:       1,
+	}
+	meta.SourceChatID, meta.SourceMessageID, _ = tfile.MessageSource(t.File)
+	return meta
+}
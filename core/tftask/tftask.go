@@ -14,12 +14,14 @@ import (
 type Task struct {
 	ID         string
 	Ctx        context.Context
+	UserID     int64 // telegram user id of the task owner
 	File       tfile.TGFile
 	Storage    storage.Storage
 	Path       string
 	Progress   ProgressTracker
 	stream     bool // true if the file should be downloaded in stream mode
 	localPath  string
+	savedPath  string // 实际保存的路径, 存储端另存为带后缀的文件时与 Path 不同
 	customName string // custom filename override (e.g., from AI rename)
 }
 
@@ -35,6 +37,7 @@ func (t *Task) SetCustomName(name string) {
 func NewTGFileTask(
 	id string,
 	ctx context.Context,
+	userID int64,
 	file tfile.TGFile,
 	stor storage.Storage,
 	path string,
@@ -49,6 +52,7 @@ func NewTGFileTask(
 		tftask := &Task{
 			ID:        id,
 			Ctx:       ctx,
+			UserID:    userID,
 			File:      file,
 			Storage:   stor,
 			Path:      path,
@@ -60,6 +64,7 @@ func NewTGFileTask(
 	tfileTask := &Task{
 		ID:       id,
 		Ctx:      ctx,
+		UserID:   userID,
 		File:     file,
 		Storage:  stor,
 		Path:     path,
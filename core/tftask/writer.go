@@ -6,6 +6,7 @@ import (
 	"sync/atomic"
 	
 	"github.com/charmbracelet/log"
+	"github.com/krau/SaveAny-Bot/core"
 )
 
 type ProgressWriterAt struct {
@@ -30,9 +31,11 @@ func (w *ProgressWriterAt) WriteAt(p []byte, off int64) (int, error) {
 	if err != nil {
 		return 0, err
 	}
+	downloaded := w.downloaded.Add(int64(at))
 	if w.progress != nil {
-		w.progress.OnProgress(w.ctx, w.info, w.downloaded.Add(int64(at)), w.total)
+		w.progress.OnProgress(w.ctx, w.info, downloaded, w.total)
 	}
+	core.PublishProgress(w.ctx, downloaded, w.total)
 	return at, nil
 }
 
@@ -73,9 +76,11 @@ func (w *ProgressWriter) Write(p []byte) (int, error) {
 	if err != nil {
 		return 0, err
 	}
+	downloaded := w.downloaded.Add(int64(at))
 	if w.progress != nil {
-		w.progress.OnProgress(w.ctx, w.info, w.downloaded.Add(int64(at)), w.total)
+		w.progress.OnProgress(w.ctx, w.info, downloaded, w.total)
 	}
+	core.PublishProgress(w.ctx, downloaded, w.total)
 	return at, nil
 }
 
@@ -0,0 +1,40 @@
+package torrenttask
+
+import (
+	"encoding/json"
+
+	"github.com/krau/SaveAny-Bot/core"
+)
+
+// BitTorrent 任务保存的 core.Checkpoint.Kind
+const CheckpointKind = "torrent"
+
+// 恢复 BitTorrent 任务所需的数据. 缓存在启动时被清理, 恢复时重新下载
+type CheckpointData struct {
+	Metainfo          []byte `json:"metainfo"`
+	Indexes           []int  `json:"indexes"`
+	StorageName       string `json:"storage_name"`
+	DirPath           string `json:"dir_path"`
+	ProgressMessageID int    `json:"progress_message_id,omitempty"` // 恢复后继续使用的进度消息
+}
+
+func (t *Task) Checkpoint() (*core.Checkpoint, error) {
+	data := CheckpointData{
+		Metainfo:    t.Metainfo,
+		Indexes:     t.Indexes,
+		StorageName: t.Storage.Name(),
+		DirPath:     t.DirPath,
+	}
+	if p, ok := t.Progress.(*Progress); ok {
+		data.ProgressMessageID = p.MessageID
+	}
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	return &core.Checkpoint{TaskID: t.ID, UserID: t.UserID, Kind: CheckpointKind, Data: raw}, nil
+}
+
+func (t *Task) NotifyShutdown() {
+	t.Abort(core.ErrShutdown)
+}
@@ -0,0 +1,148 @@
+package torrenttask
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/krau/SaveAny-Bot/config"
+	"github.com/krau/SaveAny-Bot/core"
+	"github.com/krau/SaveAny-Bot/pkg/diskquota"
+	"github.com/krau/SaveAny-Bot/pkg/enums/ctxkey"
+	"github.com/krau/SaveAny-Bot/pkg/slotpool"
+	"github.com/krau/SaveAny-Bot/pkg/torrentdl"
+	"github.com/krau/SaveAny-Bot/storage"
+)
+
+func (t *Task) Execute(ctx context.Context) error {
+	logger := log.FromContext(ctx).WithPrefix(fmt.Sprintf("torrent[%s]", t.meta.Name))
+	release, err := slotpool.Default().Acquire(ctx, t.UserID)
+	if err != nil {
+		logger.Debugf("Failed to acquire transfer slot: %v", err)
+		if t.Progress != nil {
+			t.Progress.OnDone(ctx, t, err)
+		}
+		return err
+	}
+	defer release()
+
+	if t.Progress != nil {
+		t.Progress.OnStart(ctx, t)
+	}
+	defer func() {
+		if t.Progress != nil {
+			t.Progress.OnDone(ctx, t, err)
+		}
+	}()
+
+	client, err := Default()
+	if err != nil {
+		err = fmt.Errorf("failed to start torrent client: %w", err)
+		return err
+	}
+	total := t.TotalSize()
+	reservation, _, err := diskquota.Default().ReserveOrStream(ctx, total, diskquota.WaitTimeout(), false)
+	if err != nil {
+		err = fmt.Errorf("failed to reserve cache space: %w", err)
+		return err
+	}
+	tor, err := client.Add(t.Metainfo, t.cacheDir)
+	if err != nil {
+		reservation.Release()
+		err = fmt.Errorf("failed to add torrent: %w", err)
+		return err
+	}
+	seeding := false
+	defer func() {
+		if seeding {
+			return
+		}
+		if err := tor.Close(); err != nil {
+			logger.Errorf("Failed to remove cache dir: %v", err)
+		}
+		reservation.Release()
+	}()
+
+	logger.Infof("Starting torrent download of %d files", len(t.files))
+	err = tor.Download(ctx, t.Indexes, func(done []int64) {
+		var sum int64
+		for i, n := range done {
+			t.files[i].downloaded.Store(n)
+			sum += n
+		}
+		if t.Progress != nil {
+			t.Progress.OnProgress(ctx, t)
+		}
+		core.PublishProgress(ctx, sum, total)
+	})
+	if err != nil {
+		err = fmt.Errorf("failed to download torrent: %w", err)
+		return err
+	}
+
+	for i, f := range t.files {
+		if err = t.saveFile(ctx, logger, tor, t.Indexes[i], f); err != nil {
+			return err
+		}
+		f.saved.Store(true)
+		if t.Progress != nil {
+			t.Progress.OnProgress(ctx, t)
+		}
+	}
+
+	cfg := config.Cfg.Torrent
+	if cfg.SeedRatio > 0 || cfg.SeedTime > 0 {
+		// 做种在后台进行, 结束后再删除缓存文件
+		seeding = true
+		go func() {
+			tor.Seed(context.Background(), total, cfg.SeedRatio, time.Duration(cfg.SeedTime)*time.Minute)
+			logger.Infof("Seeding finished, uploaded %d bytes", tor.Uploaded())
+			if err := tor.Close(); err != nil {
+				logger.Errorf("Failed to remove cache dir: %v", err)
+			}
+			reservation.Release()
+		}()
+	}
+	return nil
+}
+
+func (t *Task) saveFile(ctx context.Context, logger *log.Logger, tor *torrentdl.Torrent, index int, f *fileProgress) error {
+	storagePath := t.filePath(f.file)
+	vctx := context.WithValue(ctx, ctxkey.ContentLength, f.file.Size)
+	var err error
+	for i := range config.Cfg.Retry + 1 {
+		if err = vctx.Err(); err != nil {
+			return fmt.Errorf("context canceled while saving file: %w", err)
+		}
+		var file *os.File
+		file, err = os.Open(tor.FilePath(index))
+		if err != nil {
+			return fmt.Errorf("failed to open cache file: %w", err)
+		}
+		f.savedPath, err = storage.Save(vctx, t.Storage, file, storagePath)
+		file.Close()
+		if err == nil {
+			return nil
+		}
+		if i == config.Cfg.Retry {
+			return fmt.Errorf("failed to save file %s: %w", f.file.Path, err)
+		}
+		logger.Errorf("Failed to save file %s: %s, retrying...", f.file.Path, err)
+		core.PublishRetrying(ctx, i+1, err)
+		select {
+		case <-vctx.Done():
+			return fmt.Errorf("context canceled during retry delay: %w", vctx.Err())
+		case <-time.After(time.Duration(i*500) * time.Millisecond):
+		}
+	}
+	return err
+}
+
+// 通知用户任务未执行就被中止
+func (t *Task) Abort(err error) {
+	if t.Progress != nil {
+		t.Progress.OnDone(t.Ctx, t, err)
+	}
+}
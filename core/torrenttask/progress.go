@@ -0,0 +1,147 @@
+package torrenttask
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/gotd/td/tg"
+	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/msgelem"
+	"github.com/krau/SaveAny-Bot/common/utils/tgutil"
+	"github.com/krau/SaveAny-Bot/core"
+)
+
+type ProgressTracker interface {
+	OnStart(ctx context.Context, info TaskInfo)
+	OnProgress(ctx context.Context, info TaskInfo)
+	OnDone(ctx context.Context, info TaskInfo, err error)
+}
+
+const (
+	// 进度消息的最小更新间隔
+	progressUpdateInterval = 3 * time.Second
+	// 进度消息中最多列出的文件数
+	progressMaxFiles = 10
+)
+
+type Progress struct {
+	MessageID  int
+	ChatID     int64
+	start      time.Time
+	mu         sync.Mutex
+	lastUpdate time.Time
+}
+
+func (p *Progress) edit(ctx context.Context, info TaskInfo, template *msgelem.MessageTemplate, markup tg.ReplyMarkupClass) {
+	text, entities := template.BuildFormattedMessage()
+	ext := tgutil.ExtFromContext(ctx)
+	if ext == nil || p.MessageID == 0 {
+		return
+	}
+	peer := &tg.InputPeerUser{UserID: p.ChatID}
+	if err := msgelem.EditWithFormattedText(ext, peer, p.MessageID, text, entities, markup); err != nil {
+		log.Warn("Failed to edit message for torrent task", "error", err, "task_id", info.TaskID())
+	}
+}
+
+// 列出每个文件的进度
+func addFileItems(template *msgelem.MessageTemplate, info TaskInfo) {
+	files := info.Files()
+	for i, f := range files {
+		if i == progressMaxFiles {
+			template.AddItem("…", "其他", fmt.Sprintf("%d 个文件", len(files)-i), msgelem.ItemTypeText)
+			break
+		}
+		icon, status := "⏳", fmt.Sprintf("%.1f%%", percent(f.Downloaded, f.Size))
+		switch {
+		case f.Saved:
+			icon, status = "✅", "已保存"
+		case f.Size > 0 && f.Downloaded >= f.Size:
+			icon, status = "📤", "正在保存"
+		case f.Downloaded > 0:
+			icon = "📥"
+		}
+		template.AddItem(icon, f.Path, status, msgelem.ItemTypeText)
+	}
+}
+
+func percent(done, total int64) float64 {
+	if total <= 0 {
+		return 100
+	}
+	return float64(done) * 100 / float64(total)
+}
+
+func (p *Progress) OnStart(ctx context.Context, info TaskInfo) {
+	p.start = time.Now()
+	files := info.Files()
+	template := msgelem.NewInfoTemplate("🚀 开始下载", "")
+	template.AddItem("🧲", "种子", info.TorrentName(), msgelem.ItemTypeCode)
+	template.AddItem("📄", "文件数量", fmt.Sprintf("%d", len(files)), msgelem.ItemTypeText)
+	template.AddItem("📦", "总大小", msgelem.FormatSize(info.TotalSize()), msgelem.ItemTypeText)
+	template.AddItem("📂", "保存路径", fmt.Sprintf("[%s]:%s", info.StorageName(), info.StoragePath()), msgelem.ItemTypeCode)
+	p.edit(ctx, info, template, &tg.ReplyInlineMarkup{
+		Rows: []tg.KeyboardButtonRow{{Buttons: []tg.KeyboardButtonClass{tgutil.BuildCancelButton(info.TaskID())}}},
+	})
+}
+
+func (p *Progress) OnProgress(ctx context.Context, info TaskInfo) {
+	p.mu.Lock()
+	if time.Since(p.lastUpdate) < progressUpdateInterval {
+		p.mu.Unlock()
+		return
+	}
+	p.lastUpdate = time.Now()
+	p.mu.Unlock()
+
+	template := msgelem.NewProcessingTemplate("正在下载", "")
+	template.AddItem("🧲", "种子", info.TorrentName(), msgelem.ItemTypeCode)
+	template.AddProgressBar("📊", "总进度", info.Downloaded(), info.TotalSize(), 12)
+	addFileItems(template, info)
+	template.AddItem("⌚", "运行时间", msgelem.FormatDuration(time.Since(p.start)), msgelem.ItemTypeText)
+	p.edit(ctx, info, template, &tg.ReplyInlineMarkup{
+		Rows: []tg.KeyboardButtonRow{{Buttons: []tg.KeyboardButtonClass{
+			tgutil.BuildCancelButton(info.TaskID()),
+			tgutil.BuildDetailButton(info.TaskID()),
+		}}},
+	})
+}
+
+func (p *Progress) OnDone(ctx context.Context, info TaskInfo, err error) {
+	if err != nil {
+		log.FromContext(ctx).Errorf("Progress error for torrent [%s]: %v", info.TorrentName(), err)
+	}
+	var template *msgelem.MessageTemplate
+	switch {
+	case core.IsShutdown(ctx, err):
+		template = msgelem.NewInfoTemplate("⏸ Bot 正在重启", "任务将在重启后自动恢复")
+		template.AddItem("🧲", "种子", info.TorrentName(), msgelem.ItemTypeCode)
+	case errors.Is(err, context.Canceled):
+		template = msgelem.NewErrorTemplate("任务已取消", "")
+		template.AddItem("🧲", "种子", info.TorrentName(), msgelem.ItemTypeCode)
+	case err != nil:
+		template = msgelem.NewErrorTemplate("下载失败", "")
+		template.AddItem("🧲", "种子", info.TorrentName(), msgelem.ItemTypeCode)
+		template.AddItem("❗", "错误信息", err.Error(), msgelem.ItemTypeText)
+	default:
+		template = msgelem.NewSuccessTemplate("下载完成", "")
+		template.AddItem("🧲", "种子", info.TorrentName(), msgelem.ItemTypeCode)
+		template.AddItem("📄", "文件数量", fmt.Sprintf("%d", len(info.Files())), msgelem.ItemTypeText)
+		template.AddItem("📂", "保存路径", fmt.Sprintf("[%s]:%s", info.StorageName(), info.StoragePath()), msgelem.ItemTypeCode)
+		template.AddItem("📦", "总大小", msgelem.FormatSize(info.TotalSize()), msgelem.ItemTypeText)
+		if !p.start.IsZero() {
+			template.AddItem("⌚", "总用时", msgelem.FormatDuration(time.Since(p.start)), msgelem.ItemTypeText)
+		}
+	}
+	p.edit(ctx, info, template, nil)
+}
+
+func NewProgressTrack(messageID int, chatID int64) ProgressTracker {
+	return &Progress{
+		MessageID: messageID,
+		ChatID:    chatID,
+	}
+}
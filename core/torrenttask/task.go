@@ -0,0 +1,113 @@
+package torrenttask
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+
+	"github.com/krau/SaveAny-Bot/config"
+	"github.com/krau/SaveAny-Bot/pkg/ai"
+	"github.com/krau/SaveAny-Bot/pkg/enums/tasktype"
+	"github.com/krau/SaveAny-Bot/pkg/torrentdl"
+	"github.com/krau/SaveAny-Bot/storage"
+)
+
+type Task struct {
+	ID       string
+	Ctx      context.Context
+	UserID   int64  // telegram user id of the task owner
+	Metainfo []byte // .torrent 文件内容
+	Indexes  []int  // 选择下载的文件在种子中的序号
+	Storage  storage.Storage
+	DirPath  string // 存储中的目录, 多文件种子保存在其中以种子名称命名的目录
+	Progress ProgressTracker
+
+	meta     *torrentdl.Meta
+	files    []*fileProgress
+	cacheDir string
+}
+
+type fileProgress struct {
+	file       torrentdl.File
+	downloaded atomic.Int64
+	saved      atomic.Bool
+	savedPath  string // 实际保存的路径, 存储端另存为带后缀的文件时与 filePath 不同
+}
+
+func (t *Task) Type() tasktype.TaskType {
+	return tasktype.TaskTypeTorrent
+}
+
+var (
+	defaultClient    *torrentdl.Client
+	defaultClientErr error
+	defaultOnce      sync.Once
+)
+
+// 使用配置创建的 BitTorrent 客户端, 所有任务共用
+func Default() (*torrentdl.Client, error) {
+	defaultOnce.Do(func() {
+		cfg := config.Cfg.Torrent
+		defaultClient, defaultClientErr = torrentdl.NewClient(torrentdl.Config{
+			DataDir:    config.Cfg.Temp.BasePath,
+			ListenPort: cfg.ListenPort,
+			NoDHT:      cfg.NoDHT,
+			Trackers:   cfg.Trackers,
+		})
+	})
+	return defaultClient, defaultClientErr
+}
+
+func NewTask(
+	id string,
+	ctx context.Context,
+	userID int64,
+	metainfo []byte,
+	indexes []int,
+	stor storage.Storage,
+	dirPath string,
+	progress ProgressTracker,
+) (*Task, error) {
+	meta, err := torrentdl.ParseMetainfo(metainfo)
+	if err != nil {
+		return nil, err
+	}
+	if len(indexes) == 0 {
+		return nil, fmt.Errorf("no file selected")
+	}
+	files := make([]*fileProgress, 0, len(indexes))
+	for _, i := range indexes {
+		if i < 0 || i >= len(meta.Files) {
+			return nil, fmt.Errorf("file index %d out of range", i)
+		}
+		files = append(files, &fileProgress{file: meta.Files[i]})
+	}
+	cacheDir, err := filepath.Abs(filepath.Join(config.Cfg.Temp.BasePath, fmt.Sprintf("torrent_%s", id)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get absolute path for cache: %w", err)
+	}
+	return &Task{
+		ID:       id,
+		Ctx:      ctx,
+		UserID:   userID,
+		Metainfo: metainfo,
+		Indexes:  indexes,
+		Storage:  stor,
+		DirPath:  dirPath,
+		Progress: progress,
+		meta:     meta,
+		files:    files,
+		cacheDir: cacheDir,
+	}, nil
+}
+
+// 文件在存储中的路径, 种子中的名称经过清理
+func (t *Task) filePath(f torrentdl.File) string {
+	if t.meta.Dir {
+		return path.Join(t.StoragePath(), ai.SanitizeStoragePath(f.Path))
+	}
+	return path.Join(t.DirPath, ai.SanitizeFilename(f.Path))
+}
@@ -0,0 +1,98 @@
+package torrenttask
+
+import (
+	"cmp"
+	"path"
+
+	"github.com/krau/SaveAny-Bot/core"
+	"github.com/krau/SaveAny-Bot/pkg/ai"
+)
+
+type TaskInfo interface {
+	TaskID() string
+	TorrentName() string
+	Files() []FileInfo
+	Downloaded() int64
+	TotalSize() int64
+	StoragePath() string
+	StorageName() string
+}
+
+// 选择下载的文件及其进度
+type FileInfo struct {
+	Path       string // 种子中的相对路径
+	Size       int64
+	Downloaded int64
+	Saved      bool
+}
+
+func (t *Task) TaskID() string {
+	return t.ID
+}
+
+func (t *Task) TorrentName() string {
+	return t.meta.Name
+}
+
+func (t *Task) Files() []FileInfo {
+	files := make([]FileInfo, 0, len(t.files))
+	for _, f := range t.files {
+		files = append(files, FileInfo{
+			Path:       f.file.Path,
+			Size:       f.file.Size,
+			Downloaded: f.downloaded.Load(),
+			Saved:      f.saved.Load(),
+		})
+	}
+	return files
+}
+
+func (t *Task) Downloaded() int64 {
+	var n int64
+	for _, f := range t.files {
+		n += f.downloaded.Load()
+	}
+	return n
+}
+
+func (t *Task) TotalSize() int64 {
+	var n int64
+	for _, f := range t.files {
+		n += f.file.Size
+	}
+	return n
+}
+
+// 多文件种子为保存文件的目录, 单文件种子为文件路径
+func (t *Task) StoragePath() string {
+	return path.Join(t.DirPath, ai.SanitizeFilename(t.meta.Name))
+}
+
+func (t *Task) StorageName() string {
+	return t.Storage.Name()
+}
+
+func (t *Task) Meta() core.TaskMeta {
+	files := make([]core.FileMeta, 0, len(t.files))
+	for _, f := range t.files {
+		storagePath := cmp.Or(f.savedPath, t.filePath(f.file))
+		files = append(files, core.FileMeta{
+			Name:        path.Base(storagePath),
+			Size:        f.file.Size,
+			StorageName: t.StorageName(),
+			StorageType: t.Storage.Type().String(),
+			StoragePath: storagePath,
+		})
+	}
+	return core.TaskMeta{
+		UserID:      t.UserID,
+		Title:       t.meta.Name,
+		StorageName: t.StorageName(),
+		StorageType: t.Storage.Type().String(),
+		StoragePath: t.StoragePath(),
+		LocalPath:   t.cacheDir,
+		TotalBytes:  t.TotalSize(),
+		Count:       len(t.files),
+		Files:       files,
+	}
+}
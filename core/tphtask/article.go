@@ -0,0 +1,217 @@
+package tphtask
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+
+	"github.com/charmbracelet/log"
+	"github.com/duke-git/lancet/v2/retry"
+	"github.com/krau/SaveAny-Bot/config"
+	"github.com/krau/SaveAny-Bot/core"
+	"github.com/krau/SaveAny-Bot/pkg/slotpool"
+	"github.com/krau/SaveAny-Bot/pkg/telegraph"
+	"github.com/krau/SaveAny-Bot/pkg/ytdlp"
+	"go.uber.org/multierr"
+	"golang.org/x/sync/errgroup"
+)
+
+// 文章导出选项
+type ArticleOptions struct {
+	EPUB   bool // 同时生成 EPUB
+	Embeds bool // 使用 yt-dlp 下载 iframe 嵌入的视频
+}
+
+const (
+	articleHTMLName     = "index.html"
+	articleMarkdownName = "index.md"
+)
+
+// 媒体文件名, 与只保存图片时的命名相同
+func mediaFileName(index int, rawURL string) string {
+	ext := path.Ext(rawURL)
+	if u, err := url.Parse(rawURL); err == nil {
+		ext = path.Ext(u.Path)
+	}
+	return fmt.Sprintf("%d%s", index+1, ext)
+}
+
+func (t *Task) epubName() string {
+	return path.Base(t.PhPath) + ".epub"
+}
+
+// 保存完整文章: 先下载所有媒体到缓存目录并保存, 再生成引用本地媒体的 HTML, Markdown 和 EPUB
+func (t *Task) executeArticle(ctx context.Context) (err error) {
+	logger := log.FromContext(ctx)
+	logger.Infof("Starting Telegraph article task %s", t.PhPath)
+	if t.progress != nil {
+		t.progress.OnStart(ctx, t)
+	}
+	defer func() {
+		if err != nil {
+			logger.Errorf("Error during Telegraph article task execution: %v", err)
+		} else {
+			logger.Infof("Telegraph article task %s completed successfully", t.PhPath)
+		}
+		if t.progress != nil {
+			t.progress.OnDone(ctx, t, err)
+		}
+	}()
+	if err = os.MkdirAll(t.cacheDir, 0o755); err != nil {
+		return fmt.Errorf("failed to create cache dir: %w", err)
+	}
+	defer func() {
+		if err := os.RemoveAll(t.cacheDir); err != nil {
+			logger.Errorf("Failed to remove cache dir: %v", err)
+		}
+	}()
+
+	var mu sync.Mutex
+	local := make(map[string]string, len(t.medias))
+	images := make(map[string]string)
+	eg, gctx := errgroup.WithContext(ctx)
+	eg.SetLimit(config.Cfg.Workers)
+	for i, media := range t.medias {
+		eg.Go(func() error {
+			release, err := slotpool.Default().Acquire(gctx, t.UserID)
+			if err != nil {
+				return err
+			}
+			defer release()
+			name, err := t.processMedia(gctx, media, i)
+			if err != nil {
+				logger.Errorf("Error processing media %s: %v", media.URL, err)
+				return fmt.Errorf("failed to process media %s: %w", media.URL, err)
+			}
+			if name != "" {
+				mu.Lock()
+				local[media.Src] = name
+				if media.Kind == telegraph.MediaImage {
+					images[media.Src] = name
+				}
+				mu.Unlock()
+			}
+			t.publishProgress(gctx)
+			return nil
+		})
+	}
+	if err = eg.Wait(); err != nil {
+		return err
+	}
+
+	if err = t.saveBytes(ctx, telegraph.RenderHTML(t.Page, local), articleHTMLName); err != nil {
+		return err
+	}
+	t.publishProgress(ctx)
+	if err = t.saveBytes(ctx, telegraph.RenderMarkdown(t.Page, local), articleMarkdownName); err != nil {
+		return err
+	}
+	t.publishProgress(ctx)
+	if t.article.EPUB {
+		var buf bytes.Buffer
+		err = telegraph.WriteEPUB(&buf, t.Page, images, func(name string) (io.ReadCloser, error) {
+			return os.Open(filepath.Join(t.cacheDir, name))
+		})
+		if err != nil {
+			return fmt.Errorf("failed to build epub: %w", err)
+		}
+		if err = t.saveBytes(ctx, buf.Bytes(), t.epubName()); err != nil {
+			return err
+		}
+		t.publishProgress(ctx)
+	}
+	return nil
+}
+
+func (t *Task) publishProgress(ctx context.Context) {
+	core.PublishProgress(ctx, t.downloaded.Add(1), int64(t.totalpics))
+	if t.progress != nil {
+		t.progress.OnProgress(ctx, t)
+	}
+}
+
+// 下载媒体到缓存目录并保存到存储, 返回文件名. 无法下载的嵌入内容返回空文件名, 在文章中保留原始链接
+func (t *Task) processMedia(ctx context.Context, media telegraph.Media, index int) (string, error) {
+	if media.Kind == telegraph.MediaEmbed {
+		if !t.article.Embeds || !config.Cfg.Ytdlp.Enable {
+			return "", nil
+		}
+		name, err := t.downloadEmbed(ctx, media, index)
+		if err != nil {
+			if ctx.Err() != nil {
+				return "", ctx.Err()
+			}
+			log.FromContext(ctx).Warnf("Failed to download embedded media %s, keeping the link: %v", media.URL, err)
+			return "", nil
+		}
+		return name, t.saveLocal(ctx, name)
+	}
+	name := mediaFileName(index, media.URL)
+	retryOpts := []retry.Option{
+		retry.Context(ctx),
+		retry.RetryTimes(uint(config.Cfg.Retry)),
+	}
+	var lastErr error
+	attempt := 0
+	err := retry.Retry(func() error {
+		if attempt > 0 {
+			core.PublishRetrying(ctx, attempt, lastErr)
+		}
+		attempt++
+		var body io.ReadCloser
+		body, lastErr = t.client.Download(ctx, media.URL)
+		if lastErr != nil {
+			lastErr = fmt.Errorf("failed to download %s: %w", media.URL, lastErr)
+			return lastErr
+		}
+		defer body.Close()
+		var file *os.File
+		file, lastErr = os.Create(filepath.Join(t.cacheDir, name))
+		if lastErr != nil {
+			return lastErr
+		}
+		_, lastErr = io.Copy(file, body)
+		if closeErr := file.Close(); lastErr == nil {
+			lastErr = closeErr
+		}
+		if lastErr != nil {
+			lastErr = fmt.Errorf("failed to write cache file %s: %w", name, lastErr)
+		}
+		return lastErr
+	}, retryOpts...)
+	if err := multierr.Combine(err, lastErr); err != nil {
+		return "", err
+	}
+	return name, t.saveLocal(ctx, name)
+}
+
+// 使用 yt-dlp 下载嵌入的视频到缓存目录
+func (t *Task) downloadEmbed(ctx context.Context, media telegraph.Media, index int) (string, error) {
+	dir := filepath.Join(t.cacheDir, fmt.Sprintf("embed_%d", index+1))
+	defer os.RemoveAll(dir)
+	client := ytdlp.NewClient(config.Cfg.Ytdlp.Path, config.Cfg.Ytdlp.Args)
+	file, err := client.Download(ctx, media.URL, config.Cfg.Ytdlp.Format, dir, nil)
+	if err != nil {
+		return "", err
+	}
+	name := fmt.Sprintf("%d%s", index+1, filepath.Ext(file))
+	if err := os.Rename(file, filepath.Join(t.cacheDir, name)); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// 将缓存目录中的文件保存到存储
+func (t *Task) saveLocal(ctx context.Context, name string) error {
+	return core.SaveLocalFile(ctx, t.Stor.Save, path.Join(t.StorPath, name), filepath.Join(t.cacheDir, name))
+}
+
+func (t *Task) saveBytes(ctx context.Context, data []byte, name string) error {
+	return core.SaveBytes(ctx, t.Stor.Save, path.Join(t.StorPath, name), data)
+}
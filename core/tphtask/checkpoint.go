@@ -0,0 +1,46 @@
+package tphtask
+
+import (
+	"encoding/json"
+
+	"github.com/krau/SaveAny-Bot/core"
+	"github.com/krau/SaveAny-Bot/pkg/telegraph"
+)
+
+// Telegraph 任务保存的 core.Checkpoint.Kind
+const CheckpointKind = "tphpics"
+
+// 恢复 Telegraph 任务所需的数据
+type CheckpointData struct {
+	PhPath      string   `json:"ph_path"`
+	Pics        []string `json:"pics"`
+	StorageName string   `json:"storage_name"`
+	StorPath    string   `json:"stor_path"`
+	// 保存完整文章时的页面内容和选项
+	Page              *telegraph.Page `json:"page,omitempty"`
+	Article           ArticleOptions  `json:"article"`
+	ProgressMessageID int             `json:"progress_message_id,omitempty"` // 恢复后继续使用的进度消息
+}
+
+func (t *Task) Checkpoint() (*core.Checkpoint, error) {
+	data := CheckpointData{
+		PhPath:      t.PhPath,
+		Pics:        t.Pics,
+		StorageName: t.Stor.Name(),
+		StorPath:    t.StorPath,
+		Page:        t.Page,
+		Article:     t.article,
+	}
+	if p, ok := t.progress.(*Progress); ok {
+		data.ProgressMessageID = p.MessageID
+	}
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	return &core.Checkpoint{TaskID: t.ID, UserID: t.UserID, Kind: CheckpointKind, Data: raw}, nil
+}
+
+func (t *Task) NotifyShutdown() {
+	t.Abort(core.ErrShutdown)
+}
@@ -11,27 +11,42 @@ import (
 	"github.com/duke-git/lancet/v2/retry"
 	"github.com/krau/SaveAny-Bot/common/utils/fsutil"
 	"github.com/krau/SaveAny-Bot/config"
+	"github.com/krau/SaveAny-Bot/core"
+	"github.com/krau/SaveAny-Bot/pkg/slotpool"
 	"go.uber.org/multierr"
 	"golang.org/x/sync/errgroup"
 )
 
 func (t *Task) Execute(ctx context.Context) error {
+	if t.Page != nil {
+		return t.executeArticle(ctx)
+	}
 	logger := log.FromContext(ctx)
 	logger.Infof("Starting Telegraph task %s", t.PhPath)
-	t.progress.OnStart(ctx, t)
+	if t.progress != nil {
+		t.progress.OnStart(ctx, t)
+	}
+	// 限制同时等待槽位的协程数, 实际的传输并发由全局槽位池控制
 	eg, gctx := errgroup.WithContext(ctx)
 	eg.SetLimit(config.Cfg.Workers)
 	for i, pic := range t.Pics {
 		pic := pic
 		i := i
 		eg.Go(func() error {
-			err := t.processPic(gctx, pic, i)
+			release, err := slotpool.Default().Acquire(gctx, t.UserID)
+			if err != nil {
+				return err
+			}
+			defer release()
+			err = t.processPic(gctx, pic, i)
 			if err != nil {
 				logger.Errorf("Error processing picture %s: %v", pic, err)
 				return fmt.Errorf("failed to process picture %s: %w", pic, err)
 			}
-			t.downloaded.Add(1)
-			t.progress.OnProgress(gctx, t)
+			core.PublishProgress(gctx, t.downloaded.Add(1), int64(t.totalpics))
+			if t.progress != nil {
+				t.progress.OnProgress(gctx, t)
+			}
 			return nil
 		})
 	}
@@ -41,7 +56,9 @@ func (t *Task) Execute(ctx context.Context) error {
 	} else {
 		logger.Infof("Telegraph task %s completed successfully", t.PhPath)
 	}
-	t.progress.OnDone(ctx, t, err)
+	if t.progress != nil {
+		t.progress.OnDone(ctx, t, err)
+	}
 	return err
 }
 
@@ -51,7 +68,12 @@ func (t *Task) processPic(ctx context.Context, picUrl string, index int) error {
 		retry.RetryTimes(uint(config.Cfg.Retry)),
 	}
 	var lastErr error
+	attempt := 0
 	err := retry.Retry(func() error {
+		if attempt > 0 {
+			core.PublishRetrying(ctx, attempt, lastErr)
+		}
+		attempt++
 		var body io.ReadCloser
 		body, lastErr = t.client.Download(ctx, picUrl)
 		if lastErr != nil {
@@ -92,3 +114,10 @@ func (t *Task) processPic(ctx context.Context, picUrl string, index int) error {
 	}, retryOpts...)
 	return multierr.Combine(err, lastErr)
 }
+
+// 通知用户任务未执行就被中止
+func (t *Task) Abort(err error) {
+	if t.progress != nil {
+		t.progress.OnDone(t.Ctx, t, err)
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/gotd/td/tg"
 	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/msgelem"
 	"github.com/krau/SaveAny-Bot/common/utils/tgutil"
+	"github.com/krau/SaveAny-Bot/core"
 )
 
 type ProgressTracker interface {
@@ -29,7 +30,7 @@ func (p *Progress) OnStart(ctx context.Context, info TaskInfo) {
 	
 	// 使用新的模板系统
 	template := msgelem.NewInfoTemplate("🚀 开始Telegraph下载", "")
-	template.AddItem("🖼️", "图片数量", fmt.Sprintf("%d", info.TotalPics()), msgelem.ItemTypeText)
+	addCountItem(template, info)
 	
 	text, entities := template.BuildFormattedMessage()
 	
@@ -63,7 +64,7 @@ func (p *Progress) OnProgress(ctx context.Context, info TaskInfo) {
 	template := msgelem.NewProcessingTemplate("Telegraph下载中", "")
 	
 	// 基本信息
-	template.AddItem("🖼️", "图片数量", fmt.Sprintf("%d", info.TotalPics()), msgelem.ItemTypeText)
+	addCountItem(template, info)
 	
 	// 进度信息
 	template.AddProgressBar("📊", "下载进度", info.Downloaded(), int64(info.TotalPics()), 12)
@@ -94,16 +95,31 @@ func (p *Progress) OnProgress(ctx context.Context, info TaskInfo) {
 func (p *Progress) OnDone(ctx context.Context, info TaskInfo, err error) {
 	logger := log.FromContext(ctx)
 	if err != nil {
-		if errors.Is(err, context.Canceled) {
+		if core.IsShutdown(ctx, err) {
+			logger.Infof("Telegraph task %s was interrupted by shutdown", info.TaskID())
+			
+			template := msgelem.NewInfoTemplate("⏸ Bot 正在重启", "任务将在重启后自动恢复")
+			addCountItem(template, info)
+			
+			text, entities := template.BuildFormattedMessage()
+			
+			ext := tgutil.ExtFromContext(ctx)
+			if ext != nil && p.MessageID != 0 {
+				peer := &tg.InputPeerUser{UserID: p.ChatID}
+				if err := msgelem.EditWithFormattedText(ext, peer, p.MessageID, text, entities, nil); err != nil {
+					log.Warn("Failed to edit message for Telegraph task shutdown", "error", err, "task_id", info.TaskID())
+				}
+			}
+		} else if errors.Is(err, context.Canceled) {
 			logger.Infof("Telegraph task %s was canceled", info.TaskID())
 			
 			template := msgelem.NewErrorTemplate("Telegraph任务已取消", "")
-			template.AddItem("🖼️", "图片数量", fmt.Sprintf("%d", info.TotalPics()), msgelem.ItemTypeText)
+			addCountItem(template, info)
 			
 			text, entities := template.BuildFormattedMessage()
 			
 			ext := tgutil.ExtFromContext(ctx)
-			if ext != nil {
+			if ext != nil && p.MessageID != 0 {
 				peer := &tg.InputPeerUser{UserID: p.ChatID}
 				if err := msgelem.EditWithFormattedText(ext, peer, p.MessageID, text, entities, nil); err != nil {
 					log.Warn("Failed to edit message for Telegraph task cancellation", "error", err, "task_id", info.TaskID())
@@ -113,13 +129,13 @@ func (p *Progress) OnDone(ctx context.Context, info TaskInfo, err error) {
 			logger.Errorf("Telegraph task %s failed: %s", info.TaskID(), err)
 			
 			template := msgelem.NewErrorTemplate("Telegraph下载失败", "")
-			template.AddItem("🖼️", "图片数量", fmt.Sprintf("%d", info.TotalPics()), msgelem.ItemTypeText)
+			addCountItem(template, info)
 			template.AddItem("❗", "错误信息", err.Error(), msgelem.ItemTypeText)
 			
 			text, entities := template.BuildFormattedMessage()
 			
 			ext := tgutil.ExtFromContext(ctx)
-			if ext != nil {
+			if ext != nil && p.MessageID != 0 {
 				peer := &tg.InputPeerUser{UserID: p.ChatID}
 				if err := msgelem.EditWithFormattedText(ext, peer, p.MessageID, text, entities, nil); err != nil {
 					log.Warn("Failed to edit message for Telegraph task failure", "error", err, "task_id", info.TaskID())
@@ -131,13 +147,13 @@ func (p *Progress) OnDone(ctx context.Context, info TaskInfo, err error) {
 	logger.Infof("Telegraph task %s completed successfully", info.TaskID())
 
 	template := msgelem.NewSuccessTemplate("Telegraph下载完成", "")
-	template.AddItem("🖼️", "图片数量", fmt.Sprintf("%d", info.TotalPics()), msgelem.ItemTypeText)
+	addCountItem(template, info)
 	template.AddItem("📂", "保存路径", fmt.Sprintf("[%s]:%s", info.StorageName(), path.Dir(info.StoragePath())), msgelem.ItemTypeCode)
 	
 	text, entities := template.BuildFormattedMessage()
 	
 	ext := tgutil.ExtFromContext(ctx)
-	if ext != nil {
+	if ext != nil && p.MessageID != 0 {
 		peer := &tg.InputPeerUser{UserID: p.ChatID}
 		if err := msgelem.EditWithFormattedText(ext, peer, p.MessageID, text, entities, nil); err != nil {
 			log.Warn("Failed to edit message for Telegraph task completion", "error", err, "task_id", info.TaskID())
@@ -2,8 +2,11 @@ package tphtask
 
 import (
 	"context"
+	"fmt"
+	"path/filepath"
 	"sync/atomic"
 
+	"github.com/krau/SaveAny-Bot/config"
 	"github.com/krau/SaveAny-Bot/pkg/enums/tasktype"
 	"github.com/krau/SaveAny-Bot/pkg/telegraph"
 	"github.com/krau/SaveAny-Bot/storage"
@@ -12,15 +15,21 @@ import (
 type Task struct {
 	ID       string
 	Ctx      context.Context
+	UserID   int64 // telegram user id of the task owner
 	PhPath   string
 	Pics     []string
 	Stor     storage.Storage
 	StorPath string
 	client   *telegraph.Client
 	progress ProgressTracker
+	// 保存完整文章时的页面内容, 只保存图片时为 nil
+	Page *telegraph.Page
 
+	article      ArticleOptions
+	medias       []telegraph.Media
+	cacheDir     string
 	cannotStream bool
-	totalpics    int
+	totalpics    int // 只保存图片时为图片数量, 保存文章时为媒体和文档的总数
 	downloaded   atomic.Int64
 }
 
@@ -31,6 +40,7 @@ func (t *Task) Type() tasktype.TaskType {
 func NewTask(
 	id string,
 	ctx context.Context,
+	userID int64,
 	phPath string,
 	pics []string,
 	stor storage.Storage,
@@ -42,6 +52,7 @@ func NewTask(
 	tphtask := &Task{
 		ID:           id,
 		Ctx:          ctx,
+		UserID:       userID,
 		PhPath:       phPath,
 		Pics:         pics,
 		Stor:         stor,
@@ -54,3 +65,49 @@ func NewTask(
 	}
 	return tphtask
 }
+
+// 创建保存完整文章的任务
+func NewArticleTask(
+	id string,
+	ctx context.Context,
+	userID int64,
+	page *telegraph.Page,
+	stor storage.Storage,
+	storPath string,
+	client *telegraph.Client,
+	progress ProgressTracker,
+	opts ArticleOptions,
+) (*Task, error) {
+	cacheDir, err := filepath.Abs(filepath.Join(config.Cfg.Temp.BasePath, fmt.Sprintf("tph_%s", id)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get absolute path for cache: %w", err)
+	}
+	medias := telegraph.CollectMedia(page.Content)
+	var pics []string
+	for _, m := range medias {
+		if m.Kind == telegraph.MediaImage {
+			pics = append(pics, m.URL)
+		}
+	}
+	// HTML 和 Markdown, 以及可选的 EPUB
+	total := len(medias) + 2
+	if opts.EPUB {
+		total++
+	}
+	return &Task{
+		ID:        id,
+		Ctx:       ctx,
+		UserID:    userID,
+		PhPath:    page.Path,
+		Pics:      pics,
+		Stor:      stor,
+		StorPath:  storPath,
+		client:    client,
+		progress:  progress,
+		Page:      page,
+		article:   opts,
+		medias:    medias,
+		cacheDir:  cacheDir,
+		totalpics: total,
+	}, nil
+}
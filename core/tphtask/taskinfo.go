@@ -1,9 +1,12 @@
 package tphtask
 
+import "github.com/krau/SaveAny-Bot/core"
+
 type TaskInfo interface {
 	TaskID() string
 	Phpath() string
 	TotalPics() int
+	IsArticle() bool // 保存完整文章时 TotalPics 为媒体和文档的总数
 	Downloaded() int64
 	StorageName() string
 	StoragePath() string
@@ -21,6 +24,10 @@ func (t *Task) TotalPics() int {
 	return t.totalpics
 }
 
+func (t *Task) IsArticle() bool {
+	return t.Page != nil
+}
+
 func (t *Task) Downloaded() int64 {
 	return t.downloaded.Load()
 }
@@ -32,3 +39,16 @@ func (t *Task) StorageName() string {
 func (t *Task) StoragePath() string {
 	return t.StorPath
 }
+
+func (t *Task) Meta() core.TaskMeta {
+	return core.TaskMeta{
+		UserID:        t.UserID,
+		Title:         t.PhPath,
+		StorageName:   t.StorageName(),
+		StorageType:   t.Stor.Type().String(),
+		StoragePath:   t.StoragePath(),
+		LocalPath:     t.cacheDir,
+		Count:         t.totalpics,
+		CountProgress: true,
+	}
+}
@@ -1,5 +1,20 @@
 package tphtask
 
+import (
+	"fmt"
+
+	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/msgelem"
+)
+
+// 只保存图片时显示图片数量, 保存文章时显示文件数量
+func addCountItem(template *msgelem.MessageTemplate, info TaskInfo) {
+	if info.IsArticle() {
+		template.AddItem("📄", "文件数量", fmt.Sprintf("%d", info.TotalPics()), msgelem.ItemTypeText)
+		return
+	}
+	template.AddItem("🖼️", "图片数量", fmt.Sprintf("%d", info.TotalPics()), msgelem.ItemTypeText)
+}
+
 func shouldUpdateProgress(downloaded int64, total int64) bool {
 	if total <= 0 || downloaded <= 0 {
 		return false
@@ -0,0 +1,139 @@
+package core
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/krau/SaveAny-Bot/config"
+	"github.com/krau/SaveAny-Bot/pkg/enums/tasktype"
+	"github.com/krau/SaveAny-Bot/pkg/webhook"
+)
+
+// webhook 请求体
+type WebhookPayload struct {
+	Event       EventType  `json:"event"`
+	Time        time.Time  `json:"time"`
+	TaskID      string     `json:"task_id"`
+	TaskType    string     `json:"task_type"`
+	UserID      int64      `json:"user_id"`
+	Title       string     `json:"title"`
+	StorageName string     `json:"storage_name"`
+	StorageType string     `json:"storage_type"`
+	StoragePath string     `json:"storage_path"`
+	FilePath    string     `json:"file_path"`
+	TotalBytes  int64      `json:"total_bytes"`
+	Count       int        `json:"count"`
+	Files       []FileMeta `json:"files,omitempty"`
+	ChatID      int64      `json:"chat_id,omitempty"`
+	MessageID   int        `json:"message_id,omitempty"`
+	Error       string     `json:"error,omitempty"`
+	Attempt     int        `json:"attempt,omitempty"`
+	Done        int64      `json:"done,omitempty"`
+	Total       int64      `json:"total,omitempty"`
+}
+
+func NewWebhookPayload(ev Event) WebhookPayload {
+	payload := WebhookPayload{
+		Event:       ev.Type,
+		Time:        ev.Time,
+		TaskID:      ev.Task.TaskID,
+		TaskType:    ev.Task.Type.String(),
+		UserID:      ev.Task.UserID,
+		Title:       ev.Task.Title,
+		StorageName: ev.Task.StorageName,
+		StorageType: ev.Task.StorageType,
+		StoragePath: ev.Task.StoragePath,
+		FilePath:    ev.Task.FilePath,
+		TotalBytes:  ev.Task.TotalBytes,
+		Count:       ev.Task.Count,
+		Files:       ev.Task.Files,
+		ChatID:      ev.Task.SourceChatID,
+		MessageID:   ev.Task.SourceMessageID,
+		Attempt:     ev.Attempt,
+		Done:        ev.Done,
+		Total:       ev.Total,
+	}
+	if ev.Err != nil {
+		payload.Error = ev.Err.Error()
+	}
+	return payload
+}
+
+// 未配置 events 时发送的事件
+var defaultWebhookEvents = []EventType{EventQueued, EventStarted, EventRetrying, EventSucceeded, EventFailed, EventCanceled}
+
+func sendWebhook(ctx context.Context, client *webhook.Client, ev Event) error {
+	body, err := json.Marshal(NewWebhookPayload(ev))
+	if err != nil {
+		return err
+	}
+	return client.Send(ctx, string(ev.Type), body)
+}
+
+// 为每个配置的 webhook 注册一个订阅者
+func subscribeWebhooks(ctx context.Context) {
+	logger := log.FromContext(ctx)
+	for _, cfg := range config.Cfg.Hook.Webhook {
+		if cfg.URL == "" {
+			logger.Warnf("Webhook %s has no url, skipping", cfg.Name)
+			continue
+		}
+		events := defaultWebhookEvents
+		if len(cfg.Events) > 0 {
+			events = make([]EventType, 0, len(cfg.Events))
+			for _, e := range cfg.Events {
+				events = append(events, EventType(e))
+			}
+		}
+		client := webhook.NewClient(cfg.URL, cfg.Secret, cfg.Headers, time.Duration(cfg.Timeout)*time.Second, cfg.Retry)
+		name := cmp.Or(cfg.Name, cfg.URL)
+		Subscribe(ctx, func(ctx context.Context, ev Event) {
+			if err := sendWebhook(ctx, client, ev); err != nil {
+				log.FromContext(ctx).Errorf("Failed to send %s event of task %s to webhook %s: %v", ev.Type, ev.Task.TaskID, name, err)
+			}
+		}, events...)
+	}
+}
+
+// 向 webhook 发送一个测试事件, name 为空时发送到所有 webhook. 返回各 webhook 的结果
+func SendTestWebhook(ctx context.Context, name string) (map[string]error, error) {
+	ev := Event{
+		Type: EventSucceeded,
+		Time: time.Now(),
+		Task: TaskMeta{
+			TaskID:      "test",
+			Type:        tasktype.TaskTypeTgfiles,
+			Title:       "test.txt",
+			StorageName: "test",
+			StoragePath: "/test.txt",
+			FilePath:    "/test.txt",
+			TotalBytes:  1024,
+			Count:       1,
+		},
+	}
+	results := make(map[string]error)
+	for _, cfg := range config.Cfg.Hook.Webhook {
+		if name != "" && cfg.Name != name {
+			continue
+		}
+		whName := cmp.Or(cfg.Name, cfg.URL)
+		if len(cfg.Events) > 0 && !slices.Contains(cfg.Events, string(ev.Type)) {
+			log.FromContext(ctx).Warnf("Webhook %s does not subscribe to %s events, sending the test event anyway", whName, ev.Type)
+		}
+		client := webhook.NewClient(cfg.URL, cfg.Secret, cfg.Headers, time.Duration(cfg.Timeout)*time.Second, cfg.Retry)
+		results[whName] = sendWebhook(ctx, client, ev)
+	}
+	if len(results) == 0 {
+		if name == "" {
+			return nil, errors.New("no webhook configured")
+		}
+		return nil, fmt.Errorf("no webhook named %q", name)
+	}
+	return results, nil
+}
@@ -0,0 +1,44 @@
+package ytdlptask
+
+import (
+	"encoding/json"
+
+	"github.com/krau/SaveAny-Bot/core"
+)
+
+// yt-dlp 任务保存的 core.Checkpoint.Kind
+const CheckpointKind = "ytdlp"
+
+// 恢复 yt-dlp 任务所需的数据. 恢复时重新下载
+type CheckpointData struct {
+	URL               string `json:"url"`
+	Format            string `json:"format"`
+	Title             string `json:"title"`
+	Size              int64  `json:"size,omitempty"`
+	StorageName       string `json:"storage_name"`
+	DirPath           string `json:"dir_path"`
+	ProgressMessageID int    `json:"progress_message_id,omitempty"` // 恢复后继续使用的进度消息
+}
+
+func (t *Task) Checkpoint() (*core.Checkpoint, error) {
+	data := CheckpointData{
+		URL:         t.URL,
+		Format:      t.Format,
+		Title:       t.Title,
+		Size:        t.Size,
+		StorageName: t.Storage.Name(),
+		DirPath:     t.DirPath,
+	}
+	if p, ok := t.Progress.(*Progress); ok {
+		data.ProgressMessageID = p.MessageID
+	}
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	return &core.Checkpoint{TaskID: t.ID, UserID: t.UserID, Kind: CheckpointKind, Data: raw}, nil
+}
+
+func (t *Task) NotifyShutdown() {
+	t.Abort(core.ErrShutdown)
+}
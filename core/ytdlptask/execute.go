@@ -0,0 +1,123 @@
+package ytdlptask
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/krau/SaveAny-Bot/config"
+	"github.com/krau/SaveAny-Bot/core"
+	"github.com/krau/SaveAny-Bot/pkg/diskquota"
+	"github.com/krau/SaveAny-Bot/pkg/enums/ctxkey"
+	"github.com/krau/SaveAny-Bot/pkg/slotpool"
+	"github.com/krau/SaveAny-Bot/pkg/ytdlp"
+)
+
+func (t *Task) Execute(ctx context.Context) error {
+	logger := log.FromContext(ctx).WithPrefix(fmt.Sprintf("ytdlp[%s]", t.Title))
+	release, err := slotpool.Default().Acquire(ctx, t.UserID)
+	if err != nil {
+		logger.Debugf("Failed to acquire transfer slot: %v", err)
+		if t.Progress != nil {
+			t.Progress.OnDone(ctx, t, err)
+		}
+		return err
+	}
+	defer release()
+
+	if t.Progress != nil {
+		t.Progress.OnStart(ctx, t)
+	}
+	defer func() {
+		if t.Progress != nil {
+			t.Progress.OnDone(ctx, t, err)
+		}
+	}()
+
+	// yt-dlp 只能下载到本地, 无法使用流式传输
+	reservation, _, err := diskquota.Default().ReserveOrStream(ctx, t.Size, diskquota.WaitTimeout(), false)
+	if err != nil {
+		err = fmt.Errorf("failed to reserve cache space: %w", err)
+		return err
+	}
+	defer reservation.Release()
+
+	if err = os.MkdirAll(t.cacheDir, 0o755); err != nil {
+		err = fmt.Errorf("failed to create cache dir: %w", err)
+		return err
+	}
+	defer func() {
+		if err := os.RemoveAll(t.cacheDir); err != nil {
+			logger.Errorf("Failed to remove cache dir: %v", err)
+		}
+	}()
+
+	logger.Infof("Starting yt-dlp download with format %s", t.Format)
+	var localPath string
+	localPath, err = NewClient().Download(ctx, t.URL, t.Format, t.cacheDir, func(p ytdlp.Progress) {
+		// 视频和音频分开下载时, 每个文件都会从 0 开始汇报进度
+		t.downloaded.Store(p.Downloaded())
+		t.total.Store(p.Total())
+		if t.Progress != nil {
+			t.Progress.OnProgress(ctx, t, p.Downloaded(), p.Total())
+		}
+		core.PublishProgress(ctx, p.Downloaded(), p.Total())
+	})
+	if err != nil {
+		err = fmt.Errorf("failed to download video: %w", err)
+		return err
+	}
+	t.fileName.Store(filepath.Base(localPath))
+	logger.Infof("Video downloaded to %s", localPath)
+
+	var fileStat os.FileInfo
+	fileStat, err = os.Stat(localPath)
+	if err != nil {
+		err = fmt.Errorf("failed to get file stat: %w", err)
+		return err
+	}
+	t.downloaded.Store(fileStat.Size())
+	storagePath := path.Join(t.DirPath, t.FileName())
+	vctx := context.WithValue(ctx, ctxkey.ContentLength, fileStat.Size())
+	for i := range config.Cfg.Retry + 1 {
+		if err = vctx.Err(); err != nil {
+			err = fmt.Errorf("context canceled while saving file: %w", err)
+			return err
+		}
+		var file *os.File
+		file, err = os.Open(localPath)
+		if err != nil {
+			err = fmt.Errorf("failed to open cache file: %w", err)
+			return err
+		}
+		err = t.Storage.Save(vctx, file, storagePath)
+		file.Close()
+		if err == nil {
+			return nil
+		}
+		if i == config.Cfg.Retry {
+			err = fmt.Errorf("failed to save file: %w", err)
+			return err
+		}
+		logger.Errorf("Failed to save file: %s, retrying...", err)
+		core.PublishRetrying(ctx, i+1, err)
+		select {
+		case <-vctx.Done():
+			err = fmt.Errorf("context canceled during retry delay: %w", vctx.Err())
+			return err
+		case <-time.After(time.Duration(i*500) * time.Millisecond):
+		}
+	}
+	return err
+}
+
+// 通知用户任务未执行就被中止
+func (t *Task) Abort(err error) {
+	if t.Progress != nil {
+		t.Progress.OnDone(t.Ctx, t, err)
+	}
+}
@@ -0,0 +1,119 @@
+package ytdlptask
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/gotd/td/tg"
+	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/msgelem"
+	"github.com/krau/SaveAny-Bot/common/utils/tgutil"
+	"github.com/krau/SaveAny-Bot/core"
+)
+
+type ProgressTracker interface {
+	OnStart(ctx context.Context, info TaskInfo)
+	OnProgress(ctx context.Context, info TaskInfo, downloaded, total int64)
+	OnDone(ctx context.Context, info TaskInfo, err error)
+}
+
+// 进度消息的最小更新间隔
+const progressUpdateInterval = 3 * time.Second
+
+type Progress struct {
+	MessageID  int
+	ChatID     int64
+	start      time.Time
+	mu         sync.Mutex
+	lastUpdate time.Time
+}
+
+func (p *Progress) edit(ctx context.Context, info TaskInfo, template *msgelem.MessageTemplate, markup tg.ReplyMarkupClass) {
+	text, entities := template.BuildFormattedMessage()
+	ext := tgutil.ExtFromContext(ctx)
+	if ext == nil || p.MessageID == 0 {
+		return
+	}
+	peer := &tg.InputPeerUser{UserID: p.ChatID}
+	if err := msgelem.EditWithFormattedText(ext, peer, p.MessageID, text, entities, markup); err != nil {
+		log.Warn("Failed to edit message for yt-dlp task", "error", err, "task_id", info.TaskID())
+	}
+}
+
+func (p *Progress) OnStart(ctx context.Context, info TaskInfo) {
+	p.start = time.Now()
+	template := msgelem.NewInfoTemplate("🚀 开始下载", "")
+	template.AddItem("🎬", "标题", info.VideoTitle(), msgelem.ItemTypeCode)
+	template.AddItem("🔗", "链接", info.SourceURL(), msgelem.ItemTypeText)
+	template.AddItem("📂", "保存路径", fmt.Sprintf("[%s]:%s", info.StorageName(), info.StoragePath()), msgelem.ItemTypeCode)
+	if size := info.TotalSize(); size > 0 {
+		template.AddItem("📦", "预计大小", msgelem.FormatSize(size), msgelem.ItemTypeText)
+	}
+	p.edit(ctx, info, template, &tg.ReplyInlineMarkup{
+		Rows: []tg.KeyboardButtonRow{{Buttons: []tg.KeyboardButtonClass{tgutil.BuildCancelButton(info.TaskID())}}},
+	})
+}
+
+func (p *Progress) OnProgress(ctx context.Context, info TaskInfo, downloaded, total int64) {
+	p.mu.Lock()
+	if time.Since(p.lastUpdate) < progressUpdateInterval {
+		p.mu.Unlock()
+		return
+	}
+	p.lastUpdate = time.Now()
+	p.mu.Unlock()
+
+	template := msgelem.NewProcessingTemplate("正在下载", "")
+	template.AddItem("🎬", "标题", info.VideoTitle(), msgelem.ItemTypeCode)
+	template.AddItem("📂", "保存路径", fmt.Sprintf("[%s]:%s", info.StorageName(), info.StoragePath()), msgelem.ItemTypeCode)
+	if total > 0 {
+		template.AddProgressBar("📊", "传输进度", downloaded, total, 12)
+	} else {
+		template.AddItem("📥", "已下载", msgelem.FormatSize(downloaded), msgelem.ItemTypeText)
+	}
+	template.AddItem("⌚", "运行时间", msgelem.FormatDuration(time.Since(p.start)), msgelem.ItemTypeText)
+	p.edit(ctx, info, template, &tg.ReplyInlineMarkup{
+		Rows: []tg.KeyboardButtonRow{{Buttons: []tg.KeyboardButtonClass{
+			tgutil.BuildCancelButton(info.TaskID()),
+			tgutil.BuildDetailButton(info.TaskID()),
+		}}},
+	})
+}
+
+func (p *Progress) OnDone(ctx context.Context, info TaskInfo, err error) {
+	if err != nil {
+		log.FromContext(ctx).Errorf("Progress error for video [%s]: %v", info.VideoTitle(), err)
+	}
+	var template *msgelem.MessageTemplate
+	switch {
+	case core.IsShutdown(ctx, err):
+		template = msgelem.NewInfoTemplate("⏸ Bot 正在重启", "任务将在重启后自动恢复")
+		template.AddItem("🎬", "标题", info.VideoTitle(), msgelem.ItemTypeCode)
+	case errors.Is(err, context.Canceled):
+		template = msgelem.NewErrorTemplate("任务已取消", "")
+		template.AddItem("🎬", "标题", info.VideoTitle(), msgelem.ItemTypeCode)
+	case err != nil:
+		template = msgelem.NewErrorTemplate("下载失败", "")
+		template.AddItem("🎬", "标题", info.VideoTitle(), msgelem.ItemTypeCode)
+		template.AddItem("❗", "错误信息", err.Error(), msgelem.ItemTypeText)
+	default:
+		template = msgelem.NewSuccessTemplate("下载完成", "")
+		template.AddItem("📄", "文件名", info.FileName(), msgelem.ItemTypeCode)
+		template.AddItem("📂", "保存路径", fmt.Sprintf("[%s]:%s", info.StorageName(), info.StoragePath()), msgelem.ItemTypeCode)
+		template.AddItem("📦", "文件大小", msgelem.FormatSize(info.Downloaded()), msgelem.ItemTypeText)
+		if !p.start.IsZero() {
+			template.AddItem("⌚", "总用时", msgelem.FormatDuration(time.Since(p.start)), msgelem.ItemTypeText)
+		}
+	}
+	p.edit(ctx, info, template, nil)
+}
+
+func NewProgressTrack(messageID int, chatID int64) ProgressTracker {
+	return &Progress{
+		MessageID: messageID,
+		ChatID:    chatID,
+	}
+}
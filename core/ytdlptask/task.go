@@ -0,0 +1,69 @@
+package ytdlptask
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sync/atomic"
+
+	"github.com/krau/SaveAny-Bot/config"
+	"github.com/krau/SaveAny-Bot/pkg/enums/tasktype"
+	"github.com/krau/SaveAny-Bot/pkg/ytdlp"
+	"github.com/krau/SaveAny-Bot/storage"
+)
+
+type Task struct {
+	ID       string
+	Ctx      context.Context
+	UserID   int64 // telegram user id of the task owner
+	URL      string
+	Format   string // yt-dlp 格式选择器
+	Title    string
+	Size     int64 // 预计大小, 未知时为 0
+	Storage  storage.Storage
+	DirPath  string // 存储中的目录, 文件名在下载完成后确定
+	Progress ProgressTracker
+
+	cacheDir   string
+	fileName   atomic.Value // string, 下载完成后的文件名
+	downloaded atomic.Int64
+	total      atomic.Int64
+}
+
+func (t *Task) Type() tasktype.TaskType {
+	return tasktype.TaskTypeYtdlp
+}
+
+// 使用配置创建 yt-dlp 客户端
+func NewClient() *ytdlp.Client {
+	return ytdlp.NewClient(config.Cfg.Ytdlp.Path, config.Cfg.Ytdlp.Args)
+}
+
+func NewTask(
+	id string,
+	ctx context.Context,
+	userID int64,
+	url, format, title string,
+	size int64,
+	stor storage.Storage,
+	dirPath string,
+	progress ProgressTracker,
+) (*Task, error) {
+	cacheDir, err := filepath.Abs(filepath.Join(config.Cfg.Temp.BasePath, fmt.Sprintf("ytdlp_%s", id)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get absolute path for cache: %w", err)
+	}
+	return &Task{
+		ID:       id,
+		Ctx:      ctx,
+		UserID:   userID,
+		URL:      url,
+		Format:   format,
+		Title:    title,
+		Size:     size,
+		Storage:  stor,
+		DirPath:  dirPath,
+		Progress: progress,
+		cacheDir: cacheDir,
+	}, nil
+}
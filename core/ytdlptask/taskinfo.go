@@ -0,0 +1,71 @@
+package ytdlptask
+
+import (
+	"path"
+
+	"github.com/krau/SaveAny-Bot/core"
+)
+
+type TaskInfo interface {
+	TaskID() string
+	SourceURL() string
+	VideoTitle() string
+	FileName() string // 下载完成前为空
+	Downloaded() int64
+	TotalSize() int64 // 未知时为 0
+	StoragePath() string
+	StorageName() string
+}
+
+func (t *Task) TaskID() string {
+	return t.ID
+}
+
+func (t *Task) SourceURL() string {
+	return t.URL
+}
+
+func (t *Task) VideoTitle() string {
+	return t.Title
+}
+
+func (t *Task) FileName() string {
+	name, _ := t.fileName.Load().(string)
+	return name
+}
+
+func (t *Task) Downloaded() int64 {
+	return t.downloaded.Load()
+}
+
+func (t *Task) TotalSize() int64 {
+	if total := t.total.Load(); total > 0 {
+		return total
+	}
+	return t.Size
+}
+
+// 下载完成前为目录
+func (t *Task) StoragePath() string {
+	if name := t.FileName(); name != "" {
+		return path.Join(t.DirPath, name)
+	}
+	return t.DirPath
+}
+
+func (t *Task) StorageName() string {
+	return t.Storage.Name()
+}
+
+func (t *Task) Meta() core.TaskMeta {
+	return core.TaskMeta{
+		UserID:      t.UserID,
+		Title:       t.Title,
+		StorageName: t.StorageName(),
+		StorageType: t.Storage.Type().String(),
+		StoragePath: t.StoragePath(),
+		LocalPath:   t.cacheDir,
+		TotalBytes:  t.TotalSize(),
+		Count:       1,
+	}
+}
@@ -0,0 +1,37 @@
+package database
+
+import "context"
+
+// 获取用户在指定存储目录中的聊天归档, 不存在时以 archive 创建
+func GetOrCreateChatArchive(ctx context.Context, archive *ChatArchive) (*ChatArchive, error) {
+	var found ChatArchive
+	err := db.WithContext(ctx).
+		Where(ChatArchive{
+			UserID:      archive.UserID,
+			ChatID:      archive.ChatID,
+			StorageName: archive.StorageName,
+			DirPath:     archive.DirPath,
+		}).
+		Attrs(ChatArchive{Path: archive.Path}).
+		FirstOrCreate(&found).Error
+	if err != nil {
+		return nil, err
+	}
+	return &found, nil
+}
+
+func GetChatArchiveByID(ctx context.Context, id uint) (*ChatArchive, error) {
+	var archive ChatArchive
+	if err := db.WithContext(ctx).First(&archive, id).Error; err != nil {
+		return nil, err
+	}
+	return &archive, nil
+}
+
+// 记录已归档的最后一条消息和消息数
+func UpdateChatArchiveProgress(ctx context.Context, id uint, lastMessageID, messages int) error {
+	return db.WithContext(ctx).Model(&ChatArchive{}).Where("id = ?", id).Updates(map[string]any{
+		"last_message_id": lastMessageID,
+		"messages":        messages,
+	}).Error
+}
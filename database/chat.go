@@ -11,27 +11,28 @@ func (user *User) WatchChat(ctx context.Context, chat WatchChat) error {
 	return db.WithContext(ctx).Save(user.WatchChats).Error
 }
 
-func (user *User) UnwatchChat(ctx context.Context, chatID int64) error {
+func (user *User) UnwatchChat(ctx context.Context, chatID int64, stories bool, topicID int) error {
 	var watchChat WatchChat
-	err := db.WithContext(ctx).Where("chat_id = ? AND user_id = ?", chatID, user.ID).First(&watchChat).Error
+	err := db.WithContext(ctx).Where("chat_id = ? AND user_id = ? AND stories = ? AND topic_id = ?", chatID, user.ID, stories, topicID).First(&watchChat).Error
 	if err != nil {
 		return err
 	}
 	return db.WithContext(ctx).Unscoped().Delete(&watchChat).Error
 }
 
-func (user *User) WatchingChat(ctx context.Context, chatID int64) (bool, error) {
+func (user *User) WatchingChat(ctx context.Context, chatID int64, stories bool, topicID int) (bool, error) {
 	var count int64
-	err := db.WithContext(ctx).Model(&WatchChat{}).Where("chat_id = ? AND user_id = ?", chatID, user.ID).Count(&count).Error
+	err := db.WithContext(ctx).Model(&WatchChat{}).Where("chat_id = ? AND user_id = ? AND stories = ? AND topic_id = ?", chatID, user.ID, stories, topicID).Count(&count).Error
 	if err != nil {
 		return false, err
 	}
 	return count > 0, nil
 }
 
-func GetWatchChatsByChatID(ctx context.Context, chatID int64) ([]*WatchChat, error) {
+// 获取监听聊天消息的记录, stories 为 true 时获取监听故事的记录. 只监听话题的记录也会返回, 由调用方按话题过滤
+func GetWatchChatsByChatID(ctx context.Context, chatID int64, stories bool) ([]*WatchChat, error) {
 	var watchChats []*WatchChat
-	err := db.WithContext(ctx).Where("chat_id = ?", chatID).Find(&watchChats).Error
+	err := db.WithContext(ctx).Where("chat_id = ? AND stories = ?", chatID, stories).Find(&watchChats).Error
 	if err != nil {
 		return nil, err
 	}
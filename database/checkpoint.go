@@ -0,0 +1,17 @@
+package database
+
+import "context"
+
+func CreateTaskCheckpoint(ctx context.Context, checkpoint *TaskCheckpoint) error {
+	return db.WithContext(ctx).Create(checkpoint).Error
+}
+
+func GetTaskCheckpoints(ctx context.Context) ([]TaskCheckpoint, error) {
+	var checkpoints []TaskCheckpoint
+	err := db.WithContext(ctx).Order("id").Find(&checkpoints).Error
+	return checkpoints, err
+}
+
+func DeleteTaskCheckpoint(ctx context.Context, id uint) error {
+	return db.WithContext(ctx).Unscoped().Delete(&TaskCheckpoint{}, id).Error
+}
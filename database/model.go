@@ -8,6 +8,8 @@ type User struct {
 	gorm.Model
 	ChatID         int64 `gorm:"uniqueIndex;not null"`
 	Silent         bool
+	Dashboard      bool // 使用单条置顶消息汇总显示任务进度
+	DashboardMsgID int  // 面板置顶消息的 ID, 重启后继续编辑该消息
 	DefaultStorage string
 	Dirs           []Dir
 	ApplyRule      bool
@@ -18,9 +20,11 @@ type User struct {
 
 type WatchChat struct {
 	gorm.Model
-	UserID uint // User's database ID (not chat ID)
-	ChatID int64
-	Filter string
+	UserID  uint // User's database ID (not chat ID)
+	ChatID  int64
+	Filter  string
+	Stories bool // 监听聊天发布的故事而不是消息
+	TopicID int  // 只监听论坛中的此话题, 为 0 时监听整个聊天
 }
 
 type Dir struct {
@@ -55,3 +59,24 @@ type UserStorage struct {
 func (UserStorage) TableName() string {
 	return "user_storages"
 }
+
+// TaskCheckpoint 关闭时未完成的任务, 下次启动时恢复
+type TaskCheckpoint struct {
+	gorm.Model
+	TaskID string
+	ChatID int64  // 任务所属用户的 chat id
+	Kind   string // core.Checkpoint.Kind
+	Data   string `gorm:"type:text"` // JSON格式的任务数据, 由各任务类型定义
+}
+
+// ChatArchive 聊天归档的进度, 再次归档同一聊天时从上次的最后一条消息继续
+type ChatArchive struct {
+	gorm.Model
+	UserID        uint   `gorm:"not null;uniqueIndex:idx_chat_archive"` // User's database ID (not chat ID)
+	ChatID        int64  `gorm:"not null;uniqueIndex:idx_chat_archive"` // 被归档的聊天
+	StorageName   string `gorm:"not null;uniqueIndex:idx_chat_archive"`
+	DirPath       string `gorm:"not null;uniqueIndex:idx_chat_archive"` // 选择的存储目录
+	Path          string // 归档在存储中的目录, 首次归档时确定
+	LastMessageID int    // 已归档的最后一条消息
+	Messages      int    // 已归档的消息数
+}
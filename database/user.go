@@ -36,6 +36,10 @@ func UpdateUser(ctx context.Context, user *User) error {
 	return db.WithContext(ctx).Save(user).Error
 }
 
+func UpdateUserDashboardMsgID(ctx context.Context, chatID int64, msgID int) error {
+	return db.WithContext(ctx).Model(&User{}).Where("chat_id = ?", chatID).Update("dashboard_msg_id", msgID).Error
+}
+
 func DeleteUser(ctx context.Context, user *User) error {
 	return db.WithContext(ctx).
 		Unscoped().
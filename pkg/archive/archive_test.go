@@ -0,0 +1,157 @@
+package archive
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/gotd/td/tg"
+)
+
+type testNames struct{}
+
+func (testNames) Name(peer tg.PeerClass) string {
+	if p, ok := peer.(*tg.PeerUser); ok {
+		return fmt.Sprintf("User%d", p.UserID)
+	}
+	return "Channel"
+}
+
+func (testNames) Username(peer tg.PeerClass) string { return "" }
+
+func (testNames) Link(peer tg.PeerClass, msgID int) string {
+	if _, ok := peer.(*tg.PeerChannel); ok {
+		return fmt.Sprintf("https://t.me/c/1/%d", msgID)
+	}
+	return ""
+}
+
+func TestFromMessage(t *testing.T) {
+	msg := &tg.Message{
+		ID:       5,
+		Date:     1700000000,
+		PeerID:   &tg.PeerChannel{ChannelID: 1},
+		FromID:   &tg.PeerUser{UserID: 7},
+		Message:  "hello world",
+		Entities: []tg.MessageEntityClass{&tg.MessageEntityTextURL{Offset: 6, Length: 5, URL: "https://example.com"}},
+		ReplyTo:  &tg.MessageReplyHeader{ReplyToMsgID: 3},
+		Media: &tg.MessageMediaDocument{Document: &tg.Document{
+			Size:     2048,
+			MimeType: "audio/ogg",
+			Attributes: []tg.DocumentAttributeClass{
+				&tg.DocumentAttributeAudio{Voice: true, Duration: 3},
+			},
+		}},
+	}
+	r, ok := FromMessage(msg, testNames{})
+	if !ok {
+		t.Fatal("FromMessage() returned false")
+	}
+	if r.From == nil || r.From.Name != "User7" || r.From.Type != "user" {
+		t.Errorf("From = %+v", r.From)
+	}
+	if r.Link != "https://t.me/c/1/5" || r.ReplyTo != 3 {
+		t.Errorf("Link = %q, ReplyTo = %d", r.Link, r.ReplyTo)
+	}
+	if len(r.Entities) != 1 || r.Entities[0].Type != "text_url" || r.Entities[0].URL != "https://example.com" {
+		t.Errorf("Entities = %+v", r.Entities)
+	}
+	if r.Media == nil || r.Media.Type != "voice_message" || r.Media.Size != 2048 {
+		t.Errorf("Media = %+v", r.Media)
+	}
+
+	service, ok := FromMessage(&tg.MessageService{
+		ID:     6,
+		PeerID: &tg.PeerChannel{ChannelID: 1},
+		Action: &tg.MessageActionChatEditTitle{Title: "New"},
+	}, testNames{})
+	if !ok || service.Service != "chat_edit_title" || service.Text != "New" {
+		t.Errorf("service record = %+v", service)
+	}
+	if _, ok := FromMessage(&tg.MessageEmpty{ID: 7}, testNames{}); ok {
+		t.Error("FromMessage() accepted an empty message")
+	}
+}
+
+func TestTextHTML(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		entities []Entity
+		want     string
+	}{
+		{
+			name: "plain text is escaped",
+			text: "a < b & c",
+			want: "a &lt; b &amp; c",
+		},
+		{
+			name: "offsets are utf-16",
+			text: "😀 bold",
+			entities: []Entity{
+				{Type: "bold", Offset: 3, Length: 4},
+			},
+			want: "😀 <strong>bold</strong>",
+		},
+		{
+			name: "crossing entities are split",
+			text: "abcdef",
+			entities: []Entity{
+				{Type: "bold", Offset: 0, Length: 4},
+				{Type: "italic", Offset: 2, Length: 4},
+			},
+			want: "<strong>ab<em>cd</em></strong><em>ef</em>",
+		},
+		{
+			name: "links",
+			text: "see example.com and @user",
+			entities: []Entity{
+				{Type: "url", Offset: 4, Length: 11},
+				{Type: "mention", Offset: 20, Length: 5},
+			},
+			want: `see <a href="https://example.com">example.com</a> and <a href="https://t.me/user">@user</a>`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := TextHTML(tt.text, tt.entities); got != tt.want {
+				t.Errorf("TextHTML() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderHTML(t *testing.T) {
+	records := make([]Record, PageSize+1)
+	for i := range records {
+		records[i] = Record{ID: i + 1, Text: "msg"}
+	}
+	records[PageSize].ReplyTo = 1
+	records[0].Media = &Media{Type: "photo", File: "media/1 a.jpg"}
+
+	var buf bytes.Buffer
+	if err := WriteRecords(&buf, records); err != nil {
+		t.Fatal(err)
+	}
+	read, err := ReadRecords(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(read) != len(records) {
+		t.Fatalf("ReadRecords() returned %d records, want %d", len(read), len(records))
+	}
+
+	pages := RenderHTML("Chat", read)
+	if len(pages) != 2 || pages[0].Name != "index.html" || pages[1].Name != "messages2.html" {
+		t.Fatalf("RenderHTML() pages = %d", len(pages))
+	}
+	for _, want := range []string{`<img src="media/1%20a.jpg"`, `<a href="messages2.html">2</a>`} {
+		if !strings.Contains(string(pages[0].Content), want) {
+			t.Errorf("first page missing %q", want)
+		}
+	}
+	if !strings.Contains(string(pages[1].Content), `<a href="index.html#m1">回复 #1</a>`) {
+		t.Error("reply to a message on another page is not linked")
+	}
+}
@@ -0,0 +1,347 @@
+package archive
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"net/url"
+	"sort"
+	"strings"
+	"unicode/utf16"
+)
+
+// 每个静态页面中的消息数
+const PageSize = 1000
+
+const timeLayout = "2006-01-02 15:04:05"
+
+// 归档中的一个静态页面
+type Page struct {
+	Name    string
+	Content []byte
+}
+
+func pageName(i int) string {
+	if i == 0 {
+		return "index.html"
+	}
+	return fmt.Sprintf("messages%d.html", i+1)
+}
+
+// 生成归档的静态页面, 每页 PageSize 条消息, 第一页为 index.html
+func RenderHTML(title string, records []Record) []Page {
+	count := max((len(records)+PageSize-1)/PageSize, 1)
+	// 被回复的消息可能在其他页面
+	pageOf := make(map[int]string, len(records))
+	for i, r := range records {
+		pageOf[r.ID] = pageName(i / PageSize)
+	}
+	pages := make([]Page, 0, count)
+	for i := range count {
+		var buf bytes.Buffer
+		writePage(&buf, title, records[min(i*PageSize, len(records)):min((i+1)*PageSize, len(records))], len(records), i, count, pageOf)
+		pages = append(pages, Page{Name: pageName(i), Content: buf.Bytes()})
+	}
+	return pages
+}
+
+func writePage(b *bytes.Buffer, title string, records []Record, total, index, count int, pageOf map[int]string) {
+	esc := html.EscapeString
+	fmt.Fprintf(b, `<!DOCTYPE html>
+<html lang="zh">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>%s</title>
+<style>%s</style>
+</head>
+<body>
+<header>
+<h1>%s</h1>
+<p>共 %d 条消息</p>
+`, esc(title), pageStyle, esc(title), total)
+	nav := pageNav(index, count)
+	b.WriteString(nav)
+	b.WriteString("</header>\n<main>\n")
+	for _, r := range records {
+		writeRecord(b, r, pageOf)
+	}
+	b.WriteString("</main>\n<footer>\n")
+	b.WriteString(nav)
+	b.WriteString("</footer>\n</body>\n</html>\n")
+}
+
+func pageNav(index, count int) string {
+	if count <= 1 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("<nav>")
+	for i := range count {
+		if i == index {
+			fmt.Fprintf(&b, `<span class="current">%d</span>`, i+1)
+			continue
+		}
+		fmt.Fprintf(&b, `<a href="%s">%d</a>`, pageName(i), i+1)
+	}
+	b.WriteString("</nav>\n")
+	return b.String()
+}
+
+func writeRecord(b *bytes.Buffer, r Record, pageOf map[int]string) {
+	esc := html.EscapeString
+	if r.Service != "" {
+		fmt.Fprintf(b, `<div class="message service" id="m%d">`, r.ID)
+		if r.From != nil {
+			b.WriteString(esc(r.From.Name) + " · ")
+		}
+		b.WriteString(esc(r.Service))
+		if r.Text != "" {
+			b.WriteString(": " + esc(r.Text))
+		}
+		fmt.Fprintf(b, ` · %s</div>`+"\n", r.Date.Local().Format(timeLayout))
+		return
+	}
+	fmt.Fprintf(b, `<div class="message" id="m%d">`+"\n", r.ID)
+	b.WriteString(`<div class="meta">`)
+	switch {
+	case r.PostAuthor != "":
+		fmt.Fprintf(b, `<span class="from">%s</span> `, esc(r.PostAuthor))
+	case r.From != nil:
+		fmt.Fprintf(b, `<span class="from">%s</span> `, esc(r.From.Name))
+	}
+	fmt.Fprintf(b, `<a class="date" href="#m%d">%s</a>`, r.ID, r.Date.Local().Format(timeLayout))
+	if r.EditDate != nil {
+		fmt.Fprintf(b, ` <span class="edited" title="%s">已编辑</span>`, r.EditDate.Local().Format(timeLayout))
+	}
+	if r.Views > 0 {
+		fmt.Fprintf(b, ` <span class="views">👁 %d</span>`, r.Views)
+	}
+	if r.Link != "" {
+		fmt.Fprintf(b, ` <a class="link" href="%s">原消息</a>`, esc(r.Link))
+	}
+	b.WriteString("</div>\n")
+	if r.Forward != nil {
+		from := r.Forward.FromName
+		if r.Forward.From != nil {
+			from = r.Forward.From.Name
+		}
+		fmt.Fprintf(b, `<div class="forward">转发自 %s</div>`+"\n", esc(from))
+	}
+	if r.ReplyTo != 0 {
+		if page, ok := pageOf[r.ReplyTo]; ok {
+			fmt.Fprintf(b, `<div class="reply"><a href="%s#m%d">回复 #%d</a></div>`+"\n", page, r.ReplyTo, r.ReplyTo)
+		} else {
+			fmt.Fprintf(b, `<div class="reply">回复 #%d</div>`+"\n", r.ReplyTo)
+		}
+	}
+	if r.Media != nil {
+		writeMedia(b, r.Media)
+	}
+	if r.Text != "" {
+		fmt.Fprintf(b, `<div class="text">%s</div>`+"\n", TextHTML(r.Text, r.Entities))
+	}
+	b.WriteString("</div>\n")
+}
+
+func writeMedia(b *bytes.Buffer, m *Media) {
+	esc := html.EscapeString
+	b.WriteString(`<div class="media">`)
+	defer b.WriteString("</div>\n")
+	if m.File != "" {
+		src := esc((&url.URL{Path: m.File}).String())
+		switch {
+		case m.Type == "photo" || m.Type == "sticker" && strings.HasPrefix(m.MimeType, "image/"):
+			fmt.Fprintf(b, `<a href="%s"><img src="%s" loading="lazy" alt="%s"></a>`, src, src, esc(m.Type))
+			return
+		case m.Type == "video" || m.Type == "animation" || m.Type == "video_message":
+			fmt.Fprintf(b, `<video src="%s" controls preload="none"></video>`, src)
+			return
+		case m.Type == "audio" || m.Type == "voice_message":
+			fmt.Fprintf(b, `<audio src="%s" controls preload="none"></audio>`, src)
+			if m.Title != "" {
+				fmt.Fprintf(b, `<div>%s</div>`, esc(m.Title))
+			}
+			return
+		}
+		name := m.Name
+		if name == "" {
+			name = m.File
+		}
+		fmt.Fprintf(b, `📎 <a href="%s">%s</a>`, src, esc(name))
+		if m.Size > 0 {
+			fmt.Fprintf(b, ` (%s)`, formatSize(m.Size))
+		}
+		return
+	}
+	fmt.Fprintf(b, `<span class="type">[%s]</span>`, esc(m.Type))
+	switch {
+	case m.URL != "":
+		title := m.Title
+		if title == "" {
+			title = m.URL
+		}
+		fmt.Fprintf(b, ` <a href="%s">%s</a>`, esc(m.URL), esc(title))
+	case m.Title != "":
+		b.WriteString(" " + esc(m.Title))
+	case m.Name != "":
+		b.WriteString(" " + esc(m.Name))
+	}
+	if m.Location != "" {
+		fmt.Fprintf(b, ` <span class="location">已保存到 %s</span>`, esc(m.Location))
+	}
+}
+
+func formatSize(size int64) string {
+	units := []string{"B", "KB", "MB", "GB", "TB"}
+	value := float64(size)
+	i := 0
+	for value >= 1024 && i < len(units)-1 {
+		value /= 1024
+		i++
+	}
+	if i == 0 {
+		return fmt.Sprintf("%d B", size)
+	}
+	return fmt.Sprintf("%.2f %s", value, units[i])
+}
+
+// 实体对应的 HTML 标签, 偏移和长度以 UTF-16 编码单元计
+type tag struct {
+	start, end  int
+	open, close string
+}
+
+// 将消息文本和实体转换为 HTML
+func TextHTML(text string, entities []Entity) string {
+	units := utf16.Encode([]rune(text))
+	tags := make([]*tag, 0, len(entities))
+	for _, e := range entities {
+		if t := newTag(e, units); t != nil {
+			tags = append(tags, t)
+		}
+	}
+	sort.SliceStable(tags, func(i, j int) bool {
+		if tags[i].start != tags[j].start {
+			return tags[i].start < tags[j].start
+		}
+		return tags[i].end > tags[j].end
+	})
+	bounds := []int{0, len(units)}
+	for _, t := range tags {
+		bounds = append(bounds, t.start, t.end)
+	}
+	sort.Ints(bounds)
+
+	var b strings.Builder
+	var stack []*tag
+	for i := 0; i+1 < len(bounds); i++ {
+		start, end := bounds[i], bounds[i+1]
+		if start == end {
+			continue
+		}
+		var active []*tag
+		for _, t := range tags {
+			if t.start <= start && t.end >= end {
+				active = append(active, t)
+			}
+		}
+		// 只关闭和重新打开与上一段不同的标签, 交叉的实体会被拆分
+		same := 0
+		for same < len(stack) && same < len(active) && stack[same] == active[same] {
+			same++
+		}
+		for j := len(stack) - 1; j >= same; j-- {
+			b.WriteString(stack[j].close)
+		}
+		for _, t := range active[same:] {
+			b.WriteString(t.open)
+		}
+		stack = active
+		b.WriteString(html.EscapeString(string(utf16.Decode(units[start:end]))))
+	}
+	for j := len(stack) - 1; j >= 0; j-- {
+		b.WriteString(stack[j].close)
+	}
+	return b.String()
+}
+
+func newTag(e Entity, units []uint16) *tag {
+	start := max(e.Offset, 0)
+	end := min(start+e.Length, len(units))
+	if start >= end {
+		return nil
+	}
+	content := string(utf16.Decode(units[start:end]))
+	t := &tag{start: start, end: end}
+	link := func(href string) {
+		t.open, t.close = `<a href="`+html.EscapeString(href)+`">`, "</a>"
+	}
+	switch e.Type {
+	case "bold":
+		t.open, t.close = "<strong>", "</strong>"
+	case "italic":
+		t.open, t.close = "<em>", "</em>"
+	case "underline":
+		t.open, t.close = "<u>", "</u>"
+	case "strike":
+		t.open, t.close = "<s>", "</s>"
+	case "spoiler":
+		t.open, t.close = `<span class="spoiler">`, "</span>"
+	case "code":
+		t.open, t.close = "<code>", "</code>"
+	case "pre":
+		t.open, t.close = "<pre><code>", "</code></pre>"
+		if e.Language != "" {
+			t.open = `<pre><code class="language-` + html.EscapeString(e.Language) + `">`
+		}
+	case "blockquote":
+		t.open, t.close = "<blockquote>", "</blockquote>"
+	case "text_url":
+		link(e.URL)
+	case "url":
+		if !strings.Contains(content, "://") {
+			content = "https://" + content
+		}
+		link(content)
+	case "email":
+		link("mailto:" + content)
+	case "mention":
+		link("https://t.me/" + strings.TrimPrefix(content, "@"))
+	case "mention_name":
+		link(fmt.Sprintf("tg://user?id=%d", e.UserID))
+	default:
+		return nil
+	}
+	return t
+}
+
+const pageStyle = `
+body{margin:0;font-family:-apple-system,"Segoe UI",Roboto,"PingFang SC","Microsoft YaHei",sans-serif;background:#f4f4f5;color:#222}
+header,footer{max-width:760px;margin:0 auto;padding:16px}
+header h1{margin:0 0 4px;font-size:22px}
+header p{margin:0 0 8px;color:#777}
+nav a,nav span{display:inline-block;margin:2px;padding:2px 8px;border-radius:4px;background:#fff;text-decoration:none}
+nav .current{background:#3a76d1;color:#fff}
+main{max-width:760px;margin:0 auto;padding:0 16px}
+.message{background:#fff;border-radius:8px;padding:10px 14px;margin:8px 0}
+.message:target{outline:2px solid #3a76d1}
+.service{background:transparent;text-align:center;color:#777;font-size:13px}
+.meta{font-size:13px;color:#888;margin-bottom:4px}
+.meta a{color:#888}
+.from{font-weight:600;color:#3a76d1}
+.forward,.reply{font-size:13px;color:#3a76d1;border-left:3px solid #3a76d1;padding-left:8px;margin:4px 0}
+.text{white-space:pre-wrap;word-wrap:break-word;line-height:1.5}
+.media{margin:6px 0}
+.media img,.media video{max-width:100%;max-height:480px;border-radius:6px}
+.type{color:#777}
+.spoiler{background:#999;color:transparent}
+.spoiler:hover{background:transparent;color:inherit}
+pre{background:#f0f0f0;padding:8px;border-radius:4px;overflow-x:auto;white-space:pre}
+blockquote{margin:4px 0;padding-left:10px;border-left:3px solid #ccc}
+@media (prefers-color-scheme:dark){
+body{background:#17212b;color:#ddd}
+.message,nav a,nav span{background:#232e3c}
+pre{background:#1b2530}
+}
+`
@@ -0,0 +1,283 @@
+// Package archive 定义聊天归档中消息记录的格式, 并生成可浏览的静态页面
+package archive
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gotd/td/tg"
+)
+
+// 消息的发送者或转发来源
+type Peer struct {
+	ID       int64  `json:"id"`
+	Type     string `json:"type"` // user, chat 或 channel
+	Name     string `json:"name,omitempty"`
+	Username string `json:"username,omitempty"`
+}
+
+type Forward struct {
+	From       *Peer     `json:"from,omitempty"`
+	FromName   string    `json:"from_name,omitempty"` // 隐藏了账号的转发来源
+	PostAuthor string    `json:"post_author,omitempty"`
+	Date       time.Time `json:"date"`
+	MessageID  int       `json:"message_id,omitempty"` // 频道消息在原频道中的 ID
+}
+
+// 消息实体, 偏移和长度以 UTF-16 编码单元计
+type Entity struct {
+	Type     string `json:"type"` // 如 bold, text_url, pre, 与 Telegram API 中的类型名对应
+	Offset   int    `json:"offset"`
+	Length   int    `json:"length"`
+	URL      string `json:"url,omitempty"`
+	Language string `json:"language,omitempty"`
+	UserID   int64  `json:"user_id,omitempty"`
+}
+
+type Media struct {
+	Type     string  `json:"type"` // photo, video, file, sticker, location, poll 等
+	Name     string  `json:"name,omitempty"`
+	Size     int64   `json:"size,omitempty"`
+	MimeType string  `json:"mime_type,omitempty"`
+	Width    int     `json:"width,omitempty"`
+	Height   int     `json:"height,omitempty"`
+	Duration float64 `json:"duration,omitempty"`
+	Title    string  `json:"title,omitempty"` // 投票的问题, 位置的坐标, 网页的标题等
+	URL      string  `json:"url,omitempty"`
+	File     string  `json:"file,omitempty"`     // 相对归档目录的路径, 媒体保存在归档目录之外时为空
+	Location string  `json:"location,omitempty"` // 媒体保存的位置, 形如 [存储]:路径
+}
+
+// messages.jsonl 中的一行
+type Record struct {
+	ID         int        `json:"id"`
+	Date       time.Time  `json:"date"`
+	EditDate   *time.Time `json:"edit_date,omitempty"`
+	From       *Peer      `json:"from,omitempty"`
+	PostAuthor string     `json:"post_author,omitempty"`
+	Link       string     `json:"link,omitempty"`
+	ReplyTo    int        `json:"reply_to,omitempty"`
+	Forward    *Forward   `json:"forward,omitempty"`
+	GroupedID  int64      `json:"grouped_id,omitempty"`
+	Service    string     `json:"service,omitempty"` // 服务消息的类型, 如 chat_create, pin_message
+	Text       string     `json:"text,omitempty"`
+	Entities   []Entity   `json:"entities,omitempty"`
+	Media      *Media     `json:"media,omitempty"`
+	Views      int        `json:"views,omitempty"`
+	Forwards   int        `json:"forwards,omitempty"`
+}
+
+// 用于解析消息中用户和聊天的名称
+type Names interface {
+	Name(peer tg.PeerClass) string
+	Username(peer tg.PeerClass) string
+	Link(peer tg.PeerClass, msgID int) string
+}
+
+// 将消息转换为归档记录, 空消息返回 false
+func FromMessage(msg tg.MessageClass, names Names) (Record, bool) {
+	switch m := msg.(type) {
+	case *tg.Message:
+		r := Record{
+			ID:         m.ID,
+			Date:       time.Unix(int64(m.Date), 0),
+			From:       newPeer(m.FromID, names),
+			PostAuthor: m.PostAuthor,
+			Link:       names.Link(m.PeerID, m.ID),
+			ReplyTo:    replyTo(m.ReplyTo),
+			GroupedID:  m.GroupedID,
+			Text:       m.Message,
+			Entities:   Entities(m.Entities),
+			Media:      NewMedia(m.Media),
+			Views:      m.Views,
+			Forwards:   m.Forwards,
+		}
+		if m.EditDate != 0 && !m.EditHide {
+			edit := time.Unix(int64(m.EditDate), 0)
+			r.EditDate = &edit
+		}
+		if fwd, ok := m.GetFwdFrom(); ok {
+			r.Forward = &Forward{
+				From:       newPeer(fwd.FromID, names),
+				FromName:   fwd.FromName,
+				PostAuthor: fwd.PostAuthor,
+				Date:       time.Unix(int64(fwd.Date), 0),
+				MessageID:  fwd.ChannelPost,
+			}
+		}
+		return r, true
+	case *tg.MessageService:
+		r := Record{
+			ID:      m.ID,
+			Date:    time.Unix(int64(m.Date), 0),
+			From:    newPeer(m.FromID, names),
+			Link:    names.Link(m.PeerID, m.ID),
+			ReplyTo: replyTo(m.ReplyTo),
+			Service: snakeName(m.Action.TypeName(), "messageAction"),
+		}
+		switch a := m.Action.(type) {
+		case *tg.MessageActionChatCreate:
+			r.Text = a.Title
+		case *tg.MessageActionChannelCreate:
+			r.Text = a.Title
+		case *tg.MessageActionChatEditTitle:
+			r.Text = a.Title
+		case *tg.MessageActionTopicCreate:
+			r.Text = a.Title
+		}
+		return r, true
+	}
+	return Record{}, false
+}
+
+func newPeer(peer tg.PeerClass, names Names) *Peer {
+	var p Peer
+	switch v := peer.(type) {
+	case *tg.PeerUser:
+		p = Peer{ID: v.UserID, Type: "user"}
+	case *tg.PeerChat:
+		p = Peer{ID: v.ChatID, Type: "chat"}
+	case *tg.PeerChannel:
+		p = Peer{ID: v.ChannelID, Type: "channel"}
+	default:
+		return nil
+	}
+	p.Name = names.Name(peer)
+	p.Username = names.Username(peer)
+	return &p
+}
+
+// 同一聊天中被回复的消息, 回复其他聊天中的消息时为 0
+func replyTo(header tg.MessageReplyHeaderClass) int {
+	h, ok := header.(*tg.MessageReplyHeader)
+	if !ok || h.ReplyToPeerID != nil {
+		return 0
+	}
+	return h.ReplyToMsgID
+}
+
+func Entities(entities []tg.MessageEntityClass) []Entity {
+	if len(entities) == 0 {
+		return nil
+	}
+	result := make([]Entity, 0, len(entities))
+	for _, e := range entities {
+		entity := Entity{
+			Type:   snakeName(e.TypeName(), "messageEntity"),
+			Offset: e.GetOffset(),
+			Length: e.GetLength(),
+		}
+		switch v := e.(type) {
+		case *tg.MessageEntityTextURL:
+			entity.URL = v.URL
+		case *tg.MessageEntityPre:
+			entity.Language = v.Language
+		case *tg.MessageEntityMentionName:
+			entity.UserID = v.UserID
+		}
+		result = append(result, entity)
+	}
+	return result
+}
+
+// 媒体的说明, 文件路径由保存媒体后填写. 没有媒体时返回 nil
+func NewMedia(media tg.MessageMediaClass) *Media {
+	switch m := media.(type) {
+	case nil, *tg.MessageMediaEmpty:
+		return nil
+	case *tg.MessageMediaPhoto:
+		result := &Media{Type: "photo"}
+		if photo, ok := m.Photo.AsNotEmpty(); ok {
+			for _, size := range photo.Sizes {
+				if s, ok := size.(*tg.PhotoSize); ok && s.W*s.H >= result.Width*result.Height {
+					result.Width, result.Height, result.Size = s.W, s.H, int64(s.Size)
+				}
+			}
+		}
+		return result
+	case *tg.MessageMediaDocument:
+		result := &Media{Type: "file"}
+		doc, ok := m.Document.AsNotEmpty()
+		if !ok {
+			return result
+		}
+		result.Size = doc.Size
+		result.MimeType = doc.MimeType
+		for _, attr := range doc.Attributes {
+			switch a := attr.(type) {
+			case *tg.DocumentAttributeFilename:
+				result.Name = a.FileName
+			case *tg.DocumentAttributeSticker:
+				result.Type = "sticker"
+			case *tg.DocumentAttributeAnimated:
+				result.Type = "animation"
+			case *tg.DocumentAttributeImageSize:
+				result.Width, result.Height = a.W, a.H
+			case *tg.DocumentAttributeVideo:
+				if result.Type == "file" {
+					result.Type = "video"
+					if a.RoundMessage {
+						result.Type = "video_message"
+					}
+				}
+				result.Width, result.Height, result.Duration = a.W, a.H, a.Duration
+			case *tg.DocumentAttributeAudio:
+				result.Type = "audio"
+				if a.Voice {
+					result.Type = "voice_message"
+				}
+				result.Duration = float64(a.Duration)
+				if a.Title != "" {
+					result.Title = strings.TrimPrefix(a.Performer+" - "+a.Title, " - ")
+				}
+			}
+		}
+		return result
+	case *tg.MessageMediaGeo:
+		return &Media{Type: "location", Title: geoTitle(m.Geo)}
+	case *tg.MessageMediaGeoLive:
+		return &Media{Type: "location", Title: geoTitle(m.Geo)}
+	case *tg.MessageMediaVenue:
+		return &Media{Type: "venue", Title: strings.TrimSuffix(m.Title+", "+m.Address, ", ")}
+	case *tg.MessageMediaContact:
+		name := strings.TrimSpace(m.FirstName + " " + m.LastName)
+		return &Media{Type: "contact", Title: strings.TrimSpace(name + " " + m.PhoneNumber)}
+	case *tg.MessageMediaPoll:
+		return &Media{Type: "poll", Title: m.Poll.Question.Text}
+	case *tg.MessageMediaDice:
+		return &Media{Type: "dice", Title: fmt.Sprintf("%s %d", m.Emoticon, m.Value)}
+	case *tg.MessageMediaWebPage:
+		result := &Media{Type: "webpage"}
+		if wp, ok := m.Webpage.(*tg.WebPage); ok {
+			result.URL, result.Title = wp.URL, wp.Title
+		}
+		return result
+	}
+	return &Media{Type: snakeName(media.TypeName(), "messageMedia")}
+}
+
+func geoTitle(geo tg.GeoPointClass) string {
+	p, ok := geo.AsNotEmpty()
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf("%.6f, %.6f", p.Lat, p.Long)
+}
+
+// 将 TL 类型名转换为归档中使用的名称, 如 messageEntityTextUrl -> text_url
+func snakeName(typeName, prefix string) string {
+	name := strings.TrimPrefix(typeName, prefix)
+	var b strings.Builder
+	for i, r := range name {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,200 @@
+// 缓存目录的磁盘空间管理, 在缓存模式下载前为文件预留空间, 避免磁盘写满导致任务失败
+package diskquota
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/krau/SaveAny-Bot/common/utils/fsutil"
+	"github.com/krau/SaveAny-Bot/config"
+)
+
+var (
+	// 当前没有足够的空间, 等待其他任务释放后可能满足
+	ErrSpaceUnavailable = errors.New("not enough cache space available")
+	// 即使所有预留都被释放也无法满足
+	ErrSpaceExceeded = errors.New("file size exceeds cache space limit")
+)
+
+// 在等待空间期间, 定期重新检查磁盘剩余空间 (空间可能被外部释放)
+var pollInterval = 5 * time.Second
+
+type Manager struct {
+	dir       string
+	quota     int64 // 缓存总大小上限, 0 为不限制
+	minFree   int64 // 磁盘需要保留的最小剩余空间
+	freeSpace func(dir string) (uint64, error)
+	dirSize   func(dir string) (int64, error)
+
+	mu       sync.Mutex
+	reserved int64
+	notify   chan struct{}
+}
+
+func NewManager(dir string, quota, minFree int64) *Manager {
+	return &Manager{
+		dir:       dir,
+		quota:     quota,
+		minFree:   minFree,
+		freeSpace: fsutil.FreeSpace,
+		dirSize:   fsutil.DirSize,
+		notify:    make(chan struct{}),
+	}
+}
+
+type Reservation struct {
+	m    *Manager
+	size int64
+	once sync.Once
+}
+
+// 释放预留的空间, 可以安全地多次调用
+func (r *Reservation) Release() {
+	if r == nil {
+		return
+	}
+	r.once.Do(func() {
+		r.m.mu.Lock()
+		defer r.m.mu.Unlock()
+		r.m.reserved -= r.size
+		close(r.m.notify)
+		r.m.notify = make(chan struct{})
+	})
+}
+
+func (r *Reservation) Size() int64 {
+	if r == nil {
+		return 0
+	}
+	return r.size
+}
+
+// 调用方需持有锁
+func (m *Manager) check(size int64) error {
+	if m.quota > 0 {
+		if size > m.quota {
+			return ErrSpaceExceeded
+		}
+		// 缓存目录中实际的文件也计入配额, 包括中断任务残留的文件和不经过预留写入的文件.
+		// 已预留的空间中已写入的部分会被重复计算, 结果偏保守
+		used, err := m.dirSize(m.dir)
+		if err != nil {
+			used = 0
+		}
+		if used+m.reserved+size > m.quota {
+			return ErrSpaceUnavailable
+		}
+	}
+	free, err := m.freeSpace(m.dir)
+	if err != nil {
+		// 无法获取磁盘信息时不阻塞任务, 仅依赖配额限制
+		return nil
+	}
+	// 已预留的空间中有一部分已经写入磁盘, 这里按全部未写入计算, 结果偏保守
+	available := int64(free) - m.minFree - m.reserved
+	if available >= size {
+		return nil
+	}
+	if int64(free)+m.reserved-m.minFree < size {
+		return ErrSpaceExceeded
+	}
+	return ErrSpaceUnavailable
+}
+
+func (m *Manager) reserve(size int64) (*Reservation, <-chan struct{}, error) {
+	if size < 0 {
+		size = 0
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.check(size); err != nil {
+		return nil, m.notify, err
+	}
+	m.reserved += size
+	return &Reservation{m: m, size: size}, nil, nil
+}
+
+// 尝试立即预留空间, 不等待
+func (m *Manager) TryReserve(size int64) (*Reservation, error) {
+	res, _, err := m.reserve(size)
+	return res, err
+}
+
+// 预留空间, 空间不足时等待其他预留被释放, 直到 ctx 结束.
+//
+// 若所需空间永远无法满足则立即返回 ErrSpaceExceeded
+func (m *Manager) Reserve(ctx context.Context, size int64) (*Reservation, error) {
+	for {
+		res, notify, err := m.reserve(size)
+		if err == nil {
+			return res, nil
+		}
+		if !errors.Is(err, ErrSpaceUnavailable) {
+			return nil, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-notify:
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// 为缓存模式的下载预留空间.
+//
+// 空间不足时, 若 canStream 为 true 则返回 stream=true, 由调用方切换为流式传输;
+// 否则最多等待 timeout (0 为一直等待) 直到有足够空间
+func (m *Manager) ReserveOrStream(ctx context.Context, size int64, timeout time.Duration, canStream bool) (res *Reservation, stream bool, err error) {
+	res, err = m.TryReserve(size)
+	if err == nil {
+		return res, false, nil
+	}
+	if canStream {
+		return nil, true, nil
+	}
+	if errors.Is(err, ErrSpaceExceeded) {
+		return nil, false, err
+	}
+	wctx := ctx
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		wctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	res, err = m.Reserve(wctx, size)
+	if err != nil && ctx.Err() == nil && errors.Is(err, context.DeadlineExceeded) {
+		return nil, false, ErrSpaceUnavailable
+	}
+	return res, false, err
+}
+
+func (m *Manager) Reserved() int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.reserved
+}
+
+var (
+	defaultManager *Manager
+	defaultOnce    sync.Once
+)
+
+// 使用配置文件中的缓存目录和配额创建的全局 Manager
+func Default() *Manager {
+	defaultOnce.Do(func() {
+		defaultManager = NewManager(
+			config.Cfg.Temp.BasePath,
+			config.Cfg.Temp.Quota<<20,
+			config.Cfg.Temp.MinFree<<20,
+		)
+	})
+	return defaultManager
+}
+
+// 配置的等待空间超时时间
+func WaitTimeout() time.Duration {
+	return time.Duration(config.Cfg.Temp.WaitTimeout) * time.Second
+}
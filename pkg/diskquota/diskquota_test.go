@@ -0,0 +1,116 @@
+package diskquota
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestManager(quota, minFree int64, free uint64) *Manager {
+	m := NewManager("", quota, minFree)
+	m.freeSpace = func(string) (uint64, error) { return free, nil }
+	m.dirSize = func(string) (int64, error) { return 0, nil }
+	return m
+}
+
+func TestQuota(t *testing.T) {
+	m := newTestManager(100, 0, 1<<30)
+	r1, err := m.TryReserve(60)
+	if err != nil {
+		t.Fatalf("unexpected error on first reserve: %v", err)
+	}
+	if _, err := m.TryReserve(60); !errors.Is(err, ErrSpaceUnavailable) {
+		t.Fatalf("expected ErrSpaceUnavailable, got %v", err)
+	}
+	if _, err := m.TryReserve(101); !errors.Is(err, ErrSpaceExceeded) {
+		t.Fatalf("expected ErrSpaceExceeded, got %v", err)
+	}
+	r1.Release()
+	r1.Release() // should be idempotent
+	if m.Reserved() != 0 {
+		t.Fatalf("expected reserved 0 after release, got %d", m.Reserved())
+	}
+	if _, err := m.TryReserve(60); err != nil {
+		t.Fatalf("unexpected error after release: %v", err)
+	}
+}
+
+func TestQuotaCountsCacheDirectory(t *testing.T) {
+	dir := t.TempDir()
+	// 中断任务残留的文件, 不属于任何预留
+	if err := os.WriteFile(filepath.Join(dir, "leftover.part"), make([]byte, 50), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	m := NewManager(dir, 100, 0)
+	m.freeSpace = func(string) (uint64, error) { return 1 << 30, nil }
+	if _, err := m.TryReserve(60); !errors.Is(err, ErrSpaceUnavailable) {
+		t.Fatalf("expected ErrSpaceUnavailable with leftover files, got %v", err)
+	}
+	if _, err := m.TryReserve(50); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestFreeSpace(t *testing.T) {
+	m := newTestManager(0, 10, 100)
+	if _, err := m.TryReserve(91); !errors.Is(err, ErrSpaceExceeded) {
+		t.Fatalf("expected ErrSpaceExceeded, got %v", err)
+	}
+	if _, err := m.TryReserve(50); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := m.TryReserve(50); !errors.Is(err, ErrSpaceUnavailable) {
+		t.Fatalf("expected ErrSpaceUnavailable, got %v", err)
+	}
+}
+
+func TestReserveWaitsForRelease(t *testing.T) {
+	m := newTestManager(100, 0, 1<<30)
+	r1, err := m.TryReserve(80)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		_, err := m.Reserve(context.Background(), 50)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		t.Fatalf("reserve returned before release: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	r1.Release()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("unexpected error after release: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("reserve did not return after release")
+	}
+}
+
+func TestReserveOrStream(t *testing.T) {
+	m := newTestManager(100, 0, 1<<30)
+	if _, err := m.TryReserve(100); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_, stream, err := m.ReserveOrStream(context.Background(), 10, time.Second, true)
+	if err != nil || !stream {
+		t.Fatalf("expected stream fallback, got stream=%v err=%v", stream, err)
+	}
+	_, stream, err = m.ReserveOrStream(context.Background(), 10, 20*time.Millisecond, false)
+	if stream || !errors.Is(err, ErrSpaceUnavailable) {
+		t.Fatalf("expected ErrSpaceUnavailable after timeout, got stream=%v err=%v", stream, err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, _, err = m.ReserveOrStream(ctx, 10, 0, false)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
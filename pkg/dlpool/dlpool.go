@@ -0,0 +1,251 @@
+// 多账号下载负载均衡.
+//
+// Bot, userbot 以及配置中额外的 bot/用户会话都注册为 Account, 每个文件开始下载时根据各账号当前的负载和
+// FLOOD_WAIT 状态选择一个账号. 文件位置中的 file reference 和 access hash 只对获取消息的账号 (home) 有效,
+// 其他账号需要先用自己的 MessageGetter 重新获取来源消息, 获取失败的账号不会被选中.
+// 获取失败的结果按 (账号, chat) 缓存一段时间, 之后同一 chat 的文件不再尝试该账号.
+package dlpool
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/gotd/td/bin"
+	"github.com/gotd/td/telegram"
+	"github.com/gotd/td/telegram/downloader"
+	"github.com/gotd/td/tg"
+	"github.com/gotd/td/tgerr"
+	"github.com/krau/SaveAny-Bot/config"
+)
+
+// 可以按 ID 重新获取消息的客户端, 与 tfile.MessageGetter 相同
+type MessageGetter interface {
+	GetMessages(chatID int64, messageIDs []tg.InputMessageClass) ([]tg.MessageClass, error)
+}
+
+type Account struct {
+	name   string
+	client downloader.Client
+	getter MessageGetter
+
+	mu         sync.Mutex
+	pending    map[*pooledClient]time.Time // 已被选中但还未发出请求的下载, 超时后不再计入负载
+	active     int                         // 正在进行的 upload.getFile 请求
+	floodUntil time.Time
+	denied     map[int64]time.Time // 无法获取其中消息的 chat, 到期后重新尝试
+}
+
+var (
+	// 被选中后超过该时间仍未发出请求的下载不再计入负载
+	pendingTimeout = 30 * time.Second
+	// 账号无法获取某个 chat 的消息后, 在该时间内不再为该 chat 的文件尝试此账号
+	deniedTTL = 10 * time.Minute
+)
+
+func NewAccount(name string) *Account {
+	return &Account{name: name, pending: make(map[*pooledClient]time.Time), denied: make(map[int64]time.Time)}
+}
+
+func (a *Account) Name() string {
+	return a.name
+}
+
+// 绑定账号的下载客户端和获取消息的客户端, 在客户端创建完成后调用
+func (a *Account) Bind(client downloader.Client, getter MessageGetter) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.client = client
+	a.getter = getter
+}
+
+func (a *Account) Client() downloader.Client {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.client
+}
+
+// 该账号获取消息的客户端, 用于重新获取来源消息以取得该账号自己的文件位置
+func (a *Account) Getter() MessageGetter {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.getter
+}
+
+// 记录账号在 upload.getFile 上遇到的 FLOOD_WAIT, 需要放在 floodwait 中间件之后才能看到原始错误
+func (a *Account) Middleware() telegram.Middleware {
+	return telegram.MiddlewareFunc(func(next tg.Invoker) telegram.InvokeFunc {
+		return func(ctx context.Context, input bin.Encoder, output bin.Decoder) error {
+			err := next.Invoke(ctx, input, output)
+			if _, ok := input.(*tg.UploadGetFileRequest); ok {
+				if d, ok := tgerr.AsFloodWait(err); ok {
+					a.markFlood(time.Now().Add(d))
+				}
+			}
+			return err
+		}
+	})
+}
+
+func (a *Account) markFlood(until time.Time) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if until.After(a.floodUntil) {
+		a.floodUntil = until
+	}
+}
+
+// 调用方需持有锁
+func (a *Account) deniedChat(chatID int64, now time.Time) bool {
+	until, ok := a.denied[chatID]
+	if ok && !now.Before(until) {
+		delete(a.denied, chatID)
+		return false
+	}
+	return ok
+}
+
+func (a *Account) deny(chatID int64, now time.Time) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.denied[chatID] = now.Add(deniedTTL)
+}
+
+// 调用方需持有锁
+func (a *Account) load(now time.Time) int {
+	load := a.active
+	for c, at := range a.pending {
+		if now.Sub(at) > pendingTimeout {
+			delete(a.pending, c)
+			continue
+		}
+		load++
+	}
+	return load
+}
+
+type Pool struct {
+	mu       sync.RWMutex
+	accounts []*Account
+	now      func() time.Time
+}
+
+func NewPool() *Pool {
+	return &Pool{now: time.Now}
+}
+
+func (p *Pool) Register(a *Account) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.accounts = append(p.accounts, a)
+}
+
+func (p *Pool) Accounts() []*Account {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return append([]*Account(nil), p.accounts...)
+}
+
+// 为一个文件选择下载客户端. home 为获取该文件所在消息的客户端, 负载相同时优先使用, chatID 为消息所在的 chat.
+//
+// 其他账号只有在 accept 返回 true 时才会被使用, accept 应由该账号重新获取来源消息并改用其中的文件位置;
+// accept 为 nil 时只使用 home. accept 返回 false 的账号在 deniedTTL 内不再为同一 chat 尝试.
+// 按负载从低到高依次尝试, 处于 FLOOD_WAIT 中的账号排在最后, 所有账号都在等待时选择最早恢复的账号
+func (p *Pool) Pick(home downloader.Client, chatID int64, accept func(*Account) bool) downloader.Client {
+	accounts := p.Accounts()
+	if len(accounts) < 2 {
+		return home
+	}
+	now := p.now()
+	type candidate struct {
+		account *Account
+		client  downloader.Client
+		load    int
+		flood   time.Time
+	}
+	candidates := make([]candidate, 0, len(accounts))
+	for _, a := range accounts {
+		a.mu.Lock()
+		c := candidate{account: a, client: a.client, load: a.load(now), flood: a.floodUntil}
+		denied := a.deniedChat(chatID, now)
+		a.mu.Unlock()
+		if c.client == nil || (c.client != home && (accept == nil || denied)) {
+			continue
+		}
+		if !c.flood.After(now) {
+			c.flood = time.Time{}
+		}
+		candidates = append(candidates, c)
+	}
+	slices.SortStableFunc(candidates, func(x, y candidate) int {
+		switch {
+		case x.flood.IsZero() != y.flood.IsZero():
+			if x.flood.IsZero() {
+				return -1
+			}
+			return 1
+		case !x.flood.IsZero():
+			return x.flood.Compare(y.flood)
+		case x.load != y.load:
+			return x.load - y.load
+		case x.client == home:
+			return -1
+		case y.client == home:
+			return 1
+		}
+		return 0
+	})
+	for _, c := range candidates {
+		if c.client != home && !accept(c.account) {
+			c.account.deny(chatID, now)
+			continue
+		}
+		pc := &pooledClient{Client: c.client, account: c.account}
+		c.account.mu.Lock()
+		c.account.pending[pc] = p.now()
+		c.account.mu.Unlock()
+		return pc
+	}
+	return home
+}
+
+// 记录账号负载的下载客户端
+type pooledClient struct {
+	downloader.Client
+	account *Account
+}
+
+func (c *pooledClient) begin() {
+	c.account.mu.Lock()
+	defer c.account.mu.Unlock()
+	delete(c.account.pending, c)
+	c.account.active++
+}
+
+func (c *pooledClient) end() {
+	c.account.mu.Lock()
+	defer c.account.mu.Unlock()
+	c.account.active--
+}
+
+func (c *pooledClient) UploadGetFile(ctx context.Context, request *tg.UploadGetFileRequest) (tg.UploadFileClass, error) {
+	c.begin()
+	defer c.end()
+	return c.Client.UploadGetFile(ctx, request)
+}
+
+var defaultPool = NewPool()
+
+// 全局的下载账号池
+func Default() *Pool {
+	return defaultPool
+}
+
+// 启用下载账号池时从全局池中选择客户端, 否则直接返回 home
+func PickDefault(home downloader.Client, chatID int64, accept func(*Account) bool) downloader.Client {
+	if !config.Cfg.Telegram.DownloadPool.Enable {
+		return home
+	}
+	return defaultPool.Pick(home, chatID, accept)
+}
@@ -0,0 +1,147 @@
+package dlpool
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gotd/td/tg"
+)
+
+type fakeClient struct {
+	name  string
+	err   error
+	calls int
+}
+
+func (c *fakeClient) UploadGetFile(ctx context.Context, request *tg.UploadGetFileRequest) (tg.UploadFileClass, error) {
+	c.calls++
+	if c.err != nil {
+		return nil, c.err
+	}
+	return &tg.UploadFile{Bytes: []byte(c.name)}, nil
+}
+
+func (c *fakeClient) UploadGetFileHashes(ctx context.Context, request *tg.UploadGetFileHashesRequest) ([]tg.FileHash, error) {
+	return nil, c.err
+}
+
+func (c *fakeClient) UploadReuploadCDNFile(ctx context.Context, request *tg.UploadReuploadCDNFileRequest) ([]tg.FileHash, error) {
+	return nil, nil
+}
+
+func (c *fakeClient) UploadGetCDNFileHashes(ctx context.Context, request *tg.UploadGetCDNFileHashesRequest) ([]tg.FileHash, error) {
+	return nil, nil
+}
+
+func (c *fakeClient) UploadGetWebFile(ctx context.Context, request *tg.UploadGetWebFileRequest) (*tg.UploadWebFile, error) {
+	return nil, nil
+}
+
+func newTestPool(clients ...*fakeClient) (*Pool, []*Account) {
+	p := NewPool()
+	accounts := make([]*Account, 0, len(clients))
+	for _, c := range clients {
+		a := NewAccount(c.name)
+		a.Bind(c, nil)
+		p.Register(a)
+		accounts = append(accounts, a)
+	}
+	return p, accounts
+}
+
+func pickedName(t *testing.T, client any) string {
+	t.Helper()
+	res, err := client.(*pooledClient).UploadGetFile(context.Background(), &tg.UploadGetFileRequest{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return string(res.(*tg.UploadFile).Bytes)
+}
+
+func TestPickPrefersHomeThenSpreads(t *testing.T) {
+	home, other := &fakeClient{name: "home"}, &fakeClient{name: "other"}
+	p, _ := newTestPool(home, other)
+	first := p.Pick(home, 1, acceptAll)
+	second := p.Pick(home, 1, acceptAll)
+	if got := pickedName(t, first); got != "home" {
+		t.Fatalf("expected first pick to use home, got %s", got)
+	}
+	if got := pickedName(t, second); got != "other" {
+		t.Fatalf("expected second pick to use the idle account, got %s", got)
+	}
+}
+
+func TestPickSkipsFloodWait(t *testing.T) {
+	home, other := &fakeClient{name: "home"}, &fakeClient{name: "other"}
+	p, accounts := newTestPool(home, other)
+	accounts[0].markFlood(time.Now().Add(time.Minute))
+	if got := pickedName(t, p.Pick(home, 1, acceptAll)); got != "other" {
+		t.Fatalf("expected flooded home to be skipped, got %s", got)
+	}
+	accounts[1].markFlood(time.Now().Add(2 * time.Minute))
+	if got := pickedName(t, p.Pick(home, 1, acceptAll)); got != "home" {
+		t.Fatalf("expected the account recovering first, got %s", got)
+	}
+}
+
+func TestPickSkipsRejectedAccounts(t *testing.T) {
+	home, other, third := &fakeClient{name: "home"}, &fakeClient{name: "other"}, &fakeClient{name: "third"}
+	p, accounts := newTestPool(home, other, third)
+	accounts[0].mu.Lock()
+	accounts[0].active = 1
+	accounts[0].mu.Unlock()
+	var tried []string
+	client := p.Pick(home, 1, func(a *Account) bool {
+		tried = append(tried, a.Name())
+		return a.Name() == "third"
+	})
+	if got := pickedName(t, client); got != "third" {
+		t.Fatalf("expected the accepted account, got %s", got)
+	}
+	if len(tried) != 2 || tried[0] != "other" {
+		t.Fatalf("expected idle accounts to be tried in order, got %v", tried)
+	}
+	// 其他账号都无法获取消息时使用 home
+	if got := pickedName(t, p.Pick(home, 1, func(*Account) bool { return false })); got != "home" {
+		t.Fatalf("expected home when no other account accepts, got %s", got)
+	}
+	if got := pickedName(t, p.Pick(home, 1, nil)); got != "home" {
+		t.Fatalf("expected home without accept, got %s", got)
+	}
+}
+
+func TestPickRemembersInaccessibleChats(t *testing.T) {
+	home, other := &fakeClient{name: "home"}, &fakeClient{name: "other"}
+	p, accounts := newTestPool(home, other)
+	accounts[0].mu.Lock()
+	accounts[0].active = 1
+	accounts[0].mu.Unlock()
+	// 额外的账号没有该 chat 的 access hash, 无法重新获取消息
+	var tries int
+	cannotResolve := func(*Account) bool {
+		tries++
+		return false
+	}
+	for range 3 {
+		if got := pickedName(t, p.Pick(home, 100, cannotResolve)); got != "home" {
+			t.Fatalf("expected home when the other account cannot resolve the chat, got %s", got)
+		}
+	}
+	if tries != 1 {
+		t.Fatalf("expected the other account to be tried once for the chat, got %d", tries)
+	}
+	p.Pick(home, 200, cannotResolve)
+	if tries != 2 {
+		t.Fatalf("expected another chat to be tried again, got %d tries", tries)
+	}
+	p.now = func() time.Time { return time.Now().Add(deniedTTL) }
+	p.Pick(home, 100, cannotResolve)
+	if tries != 3 {
+		t.Fatalf("expected the chat to be tried again after deniedTTL, got %d tries", tries)
+	}
+}
+
+func acceptAll(*Account) bool {
+	return true
+}
@@ -1,6 +1,6 @@
 package tasktype
 
-// ENUM(tgfiles,tphpics)
+// ENUM(tgfiles,tphpics,httpfile,ytdlp,torrent,instantview,note,archive,sticker)
 //
 //go:generate go-enum --values --names --flag --nocase
 type TaskType string
@@ -16,6 +16,20 @@ const (
 	TaskTypeTgfiles TaskType = "tgfiles"
 	// TaskTypeTphpics is a TaskType of type tphpics.
 	TaskTypeTphpics TaskType = "tphpics"
+	// TaskTypeHttpfile is a TaskType of type httpfile.
+	TaskTypeHttpfile TaskType = "httpfile"
+	// TaskTypeYtdlp is a TaskType of type ytdlp.
+	TaskTypeYtdlp TaskType = "ytdlp"
+	// TaskTypeTorrent is a TaskType of type torrent.
+	TaskTypeTorrent TaskType = "torrent"
+	// TaskTypeInstantview is a TaskType of type instantview.
+	TaskTypeInstantview TaskType = "instantview"
+	// TaskTypeNote is a TaskType of type note.
+	TaskTypeNote TaskType = "note"
+	// TaskTypeArchive is a TaskType of type archive.
+	TaskTypeArchive TaskType = "archive"
+	// TaskTypeSticker is a TaskType of type sticker.
+	TaskTypeSticker TaskType = "sticker"
 )
 
 var ErrInvalidTaskType = fmt.Errorf("not a valid TaskType, try [%s]", strings.Join(_TaskTypeNames, ", "))
@@ -23,6 +37,13 @@ var ErrInvalidTaskType = fmt.Errorf("not a valid TaskType, try [%s]", strings.Jo
 var _TaskTypeNames = []string{
 	string(TaskTypeTgfiles),
 	string(TaskTypeTphpics),
+	string(TaskTypeHttpfile),
+	string(TaskTypeYtdlp),
+	string(TaskTypeTorrent),
+	string(TaskTypeInstantview),
+	string(TaskTypeNote),
+	string(TaskTypeArchive),
+	string(TaskTypeSticker),
 }
 
 // TaskTypeNames returns a list of possible string values of TaskType.
@@ -37,6 +58,13 @@ func TaskTypeValues() []TaskType {
 	return []TaskType{
 		TaskTypeTgfiles,
 		TaskTypeTphpics,
+		TaskTypeHttpfile,
+		TaskTypeYtdlp,
+		TaskTypeTorrent,
+		TaskTypeInstantview,
+		TaskTypeNote,
+		TaskTypeArchive,
+		TaskTypeSticker,
 	}
 }
 
@@ -53,8 +81,15 @@ func (x TaskType) IsValid() bool {
 }
 
 var _TaskTypeValue = map[string]TaskType{
-	"tgfiles": TaskTypeTgfiles,
-	"tphpics": TaskTypeTphpics,
+	"tgfiles":     TaskTypeTgfiles,
+	"tphpics":     TaskTypeTphpics,
+	"httpfile":    TaskTypeHttpfile,
+	"ytdlp":       TaskTypeYtdlp,
+	"torrent":     TaskTypeTorrent,
+	"instantview": TaskTypeInstantview,
+	"note":        TaskTypeNote,
+	"archive":     TaskTypeArchive,
+	"sticker":     TaskTypeSticker,
 }
 
 // ParseTaskType attempts to convert a string to a TaskType.
@@ -0,0 +1,319 @@
+// Package httpdl 下载任意 HTTP(S) 链接, 支持多连接分段下载和断点续传
+package httpdl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/krau/SaveAny-Bot/pkg/ai"
+)
+
+type Request struct {
+	URL     string
+	Headers map[string]string // 包括 Cookie
+}
+
+type FileInfo struct {
+	URL          string // 重定向后的最终地址
+	Name         string
+	Size         int64 // 未知时为 -1
+	AcceptRanges bool
+	ContentType  string
+}
+
+type Client struct {
+	HTTP        *http.Client
+	UserAgent   string
+	Connections int // 每个文件的最大连接数
+	Retry       int // 每个分段失败后的重试次数
+}
+
+const (
+	// 小于这个大小的文件只使用一个连接
+	minSegmentSize = 1 << 20
+	maxBackoff     = 10 * time.Second
+)
+
+// 分段下载失败后第一次重试前的等待时间, 之后每次翻倍
+var baseBackoff = 500 * time.Millisecond
+
+func NewClient(connections, retry int, userAgent string) *Client {
+	return &Client{
+		HTTP:        &http.Client{},
+		UserAgent:   userAgent,
+		Connections: max(connections, 1),
+		Retry:       max(retry, 0),
+	}
+}
+
+// 响应状态码不符合预期时返回的错误
+type StatusError struct {
+	StatusCode int
+	Status     string
+}
+
+func (e *StatusError) Error() string {
+	return "unexpected http status: " + e.Status
+}
+
+func (c *Client) newRequest(ctx context.Context, req Request, rawURL string) (*http.Request, error) {
+	hreq, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if c.UserAgent != "" {
+		hreq.Header.Set("User-Agent", c.UserAgent)
+	}
+	for k, v := range req.Headers {
+		hreq.Header.Set(k, v)
+	}
+	return hreq, nil
+}
+
+// 获取文件名, 大小和是否支持分段下载. 使用 Range: bytes=0-0 的 GET 请求, 兼容不支持 HEAD 的服务器
+func (c *Client) Probe(ctx context.Context, req Request) (*FileInfo, error) {
+	hreq, err := c.newRequest(ctx, req, req.URL)
+	if err != nil {
+		return nil, err
+	}
+	hreq.Header.Set("Range", "bytes=0-0")
+	resp, err := c.HTTP.Do(hreq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	info := &FileInfo{
+		URL:         resp.Request.URL.String(),
+		Size:        -1,
+		ContentType: resp.Header.Get("Content-Type"),
+	}
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		info.AcceptRanges = true
+		info.Size = parseContentRangeTotal(resp.Header.Get("Content-Range"))
+	case http.StatusOK:
+		info.AcceptRanges = resp.Header.Get("Accept-Ranges") == "bytes"
+		info.Size = resp.ContentLength
+	default:
+		return nil, &StatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+	if info.Size < 0 {
+		info.AcceptRanges = false
+	}
+	info.Name = FileNameFromResponse(resp)
+	return info, nil
+}
+
+// 解析 Content-Range: bytes 0-0/1234 中的总大小, 未知时返回 -1
+func parseContentRangeTotal(v string) int64 {
+	i := strings.LastIndex(v, "/")
+	if i < 0 {
+		return -1
+	}
+	size, err := strconv.ParseInt(strings.TrimSpace(v[i+1:]), 10, 64)
+	if err != nil {
+		return -1
+	}
+	return size
+}
+
+// 依次从 Content-Disposition, URL 路径推断文件名, 缺少扩展名时根据 Content-Type 补充
+func FileNameFromResponse(resp *http.Response) string {
+	var name string
+	if cd := resp.Header.Get("Content-Disposition"); cd != "" {
+		if _, params, err := mime.ParseMediaType(cd); err == nil {
+			name = params["filename"]
+		}
+	}
+	if name == "" && resp.Request != nil && resp.Request.URL != nil {
+		name = fileNameFromURL(resp.Request.URL)
+	}
+	name = sanitizeFileName(name)
+	if path.Ext(name) == "" {
+		if ct, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type")); err == nil {
+			if exts, _ := mime.ExtensionsByType(ct); len(exts) > 0 {
+				name += exts[0]
+			}
+		}
+	}
+	if name == "" || strings.HasPrefix(name, ".") {
+		name = "download" + name
+	}
+	return name
+}
+
+func fileNameFromURL(u *url.URL) string {
+	base := path.Base(u.Path)
+	if base == "/" || base == "." {
+		return ""
+	}
+	if unescaped, err := url.PathUnescape(base); err == nil {
+		return unescaped
+	}
+	return base
+}
+
+func sanitizeFileName(name string) string {
+	name = strings.ReplaceAll(name, "\\", "/")
+	name = path.Base(name)
+	if name == "/" || name == "." || name == ".." || strings.TrimSpace(name) == "" {
+		return ""
+	}
+	return ai.SanitizeFilename(name)
+}
+
+// 下载整个文件到 w. 服务器支持分段且文件足够大时使用多个连接, 每个分段失败后从已下载的位置继续.
+// onProgress 在每次写入后以写入的字节数调用, 重试不会重复计数
+func (c *Client) Download(ctx context.Context, req Request, info *FileInfo, w io.WriterAt, onProgress func(n int64)) error {
+	conns := c.Connections
+	if !info.AcceptRanges || info.Size < minSegmentSize*2 {
+		conns = 1
+	}
+	conns = int(min(int64(conns), max(info.Size/minSegmentSize, 1)))
+	if conns <= 1 {
+		end := int64(-1)
+		if info.Size > 0 {
+			end = info.Size - 1
+		}
+		return c.downloadSegment(ctx, req, info, 0, end, func(off int64) io.Writer {
+			return io.NewOffsetWriter(w, off)
+		}, onProgress)
+	}
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	segSize := info.Size / int64(conns)
+	errs := make(chan error, conns)
+	for i := range conns {
+		start := int64(i) * segSize
+		end := start + segSize - 1
+		if i == conns-1 {
+			end = info.Size - 1
+		}
+		go func() {
+			err := c.downloadSegment(ctx, req, info, start, end, func(off int64) io.Writer {
+				return io.NewOffsetWriter(w, off)
+			}, onProgress)
+			if err != nil {
+				cancel(err)
+			}
+			errs <- err
+		}()
+	}
+	var firstErr error
+	for range conns {
+		if err := <-errs; err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	if firstErr != nil {
+		if cause := context.Cause(ctx); cause != nil && !errors.Is(cause, context.Canceled) {
+			return cause
+		}
+		return firstErr
+	}
+	return nil
+}
+
+// 使用一个连接按顺序下载到 w. 服务器支持分段时, 失败后从已下载的位置继续
+func (c *Client) Stream(ctx context.Context, req Request, info *FileInfo, w io.Writer, onProgress func(n int64)) error {
+	end := int64(-1)
+	if info.Size > 0 {
+		end = info.Size - 1
+	}
+	return c.downloadSegment(ctx, req, info, 0, end, func(int64) io.Writer { return w }, onProgress)
+}
+
+// 下载 [start, end] 范围, end 为 -1 时下载到末尾. writerAt 返回写入指定偏移量的 writer
+func (c *Client) downloadSegment(ctx context.Context, req Request, info *FileInfo, start, end int64,
+	writerAt func(off int64) io.Writer, onProgress func(n int64)) error {
+	var (
+		done    int64
+		err     error
+		backoff = baseBackoff
+	)
+	for attempt := 0; attempt <= c.Retry; attempt++ {
+		if attempt > 0 {
+			// 不支持分段的服务器只能从头开始, 已写入的数据无法撤回
+			if done > 0 && !info.AcceptRanges {
+				return err
+			}
+			select {
+			case <-ctx.Done():
+				return context.Cause(ctx)
+			case <-time.After(backoff):
+			}
+			backoff = min(backoff*2, maxBackoff)
+		}
+		var n int64
+		n, err = c.fetchRange(ctx, req, info, start+done, end, writerAt(start+done), onProgress)
+		done += n
+		if err == nil {
+			if end >= 0 && start+done != end+1 {
+				err = io.ErrUnexpectedEOF
+			} else {
+				return nil
+			}
+		}
+		if ctx.Err() != nil {
+			return context.Cause(ctx)
+		}
+		var se *StatusError
+		if errors.As(err, &se) && se.StatusCode < 500 && se.StatusCode != http.StatusTooManyRequests {
+			return err
+		}
+	}
+	return fmt.Errorf("download failed after %d attempts: %w", c.Retry+1, err)
+}
+
+func (c *Client) fetchRange(ctx context.Context, req Request, info *FileInfo, start, end int64, w io.Writer, onProgress func(n int64)) (int64, error) {
+	hreq, err := c.newRequest(ctx, req, info.URL)
+	if err != nil {
+		return 0, err
+	}
+	ranged := start > 0 || (end >= 0 && info.AcceptRanges)
+	if ranged {
+		if end >= 0 {
+			hreq.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, end))
+		} else {
+			hreq.Header.Set("Range", fmt.Sprintf("bytes=%d-", start))
+		}
+	}
+	resp, err := c.HTTP.Do(hreq)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusPartialContent:
+	case resp.StatusCode == http.StatusOK && start == 0:
+	default:
+		return 0, &StatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+	var body io.Reader = resp.Body
+	if end >= 0 {
+		body = io.LimitReader(resp.Body, end-start+1)
+	}
+	return io.Copy(w, &progressReader{r: body, onProgress: onProgress})
+}
+
+type progressReader struct {
+	r          io.Reader
+	onProgress func(n int64)
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	if n > 0 && p.onProgress != nil {
+		p.onProgress(int64(n))
+	}
+	return n, err
+}
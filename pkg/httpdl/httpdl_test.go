@@ -0,0 +1,148 @@
+package httpdl
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func testData(size int) []byte {
+	data := make([]byte, size)
+	rand.New(rand.NewSource(1)).Read(data)
+	return data
+}
+
+// 支持 Range 的服务器. failFirst 为 true 时, 每个分段的第一次请求只返回一半数据后断开, 之后的请求正常返回
+func newRangeServer(t *testing.T, data []byte, failFirst bool) (*httptest.Server, *[]string) {
+	var (
+		mu     sync.Mutex
+		ranges []string
+		failed = make(map[string]bool)
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Token") != "secret" || !strings.Contains(r.Header.Get("Cookie"), "session=1") {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		rng := r.Header.Get("Range")
+		segEnd := rng[strings.Index(rng, "-")+1:]
+		mu.Lock()
+		ranges = append(ranges, rng)
+		fail := failFirst && rng != "bytes=0-0" && !failed[segEnd]
+		failed[segEnd] = true
+		mu.Unlock()
+		w.Header().Set("Content-Disposition", `attachment; filename*=UTF-8''%E6%B5%8B%E8%AF%95.bin`)
+		if fail {
+			var start, end int64
+			if _, err := parseRange(rng, &start, &end); err == nil {
+				half := (end - start + 1) / 2
+				w.Header().Set("Content-Range", rng[len("bytes="):])
+				w.Header().Set("Content-Length", "")
+				w.WriteHeader(http.StatusPartialContent)
+				w.Write(data[start : start+half])
+				panic(http.ErrAbortHandler)
+			}
+		}
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(data))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &ranges
+}
+
+func parseRange(rng string, start, end *int64) (int, error) {
+	return fmt.Sscanf(rng, "bytes=%d-%d", start, end)
+}
+
+var testReq = func(url string) Request {
+	return Request{URL: url, Headers: map[string]string{"X-Token": "secret", "Cookie": "session=1"}}
+}
+
+func TestProbe(t *testing.T) {
+	data := testData(3 << 20)
+	srv, _ := newRangeServer(t, data, false)
+	info, err := NewClient(4, 0, "").Probe(context.Background(), testReq(srv.URL+"/dl?id=1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Name != "测试.bin" || info.Size != int64(len(data)) || !info.AcceptRanges {
+		t.Fatalf("unexpected file info: %+v", info)
+	}
+}
+
+func TestDownloadMultiConnectionResume(t *testing.T) {
+	baseBackoff = time.Millisecond
+	data := testData(5<<20 + 123)
+	srv, ranges := newRangeServer(t, data, true)
+	c := NewClient(4, 2, "")
+	req := testReq(srv.URL)
+	info, err := c.Probe(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := os.Create(filepath.Join(t.TempDir(), "out"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+	var progress atomic.Int64
+	if err := c.Download(context.Background(), req, info, out, func(n int64) { progress.Add(n) }); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(out.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatal("downloaded data mismatch")
+	}
+	if progress.Load() != int64(len(data)) {
+		t.Fatalf("expected progress %d, got %d", len(data), progress.Load())
+	}
+	// probe + 4 个分段各请求两次, 第二次从中间继续
+	if len(*ranges) != 9 {
+		t.Fatalf("expected 9 requests, got %d: %v", len(*ranges), *ranges)
+	}
+}
+
+func TestDownloadWithoutRanges(t *testing.T) {
+	data := testData(3 << 20)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Write(data)
+	}))
+	defer srv.Close()
+	c := NewClient(4, 0, "")
+	req := Request{URL: srv.URL + "/files/report"}
+	info, err := c.Probe(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.AcceptRanges || info.Name != "report.pdf" {
+		t.Fatalf("unexpected file info: %+v", info)
+	}
+	var buf bytes.Buffer
+	if err := c.Stream(context.Background(), req, info, &buf, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), data) {
+		t.Fatal("downloaded data mismatch")
+	}
+}
+
+func TestFileNameFromResponseSanitizes(t *testing.T) {
+	resp := &http.Response{Header: http.Header{}}
+	resp.Header.Set("Content-Disposition", `attachment; filename="..\\a:b?.zip"`)
+	if name := FileNameFromResponse(resp); name != "a_b_.zip" {
+		t.Fatalf("FileNameFromResponse() = %q, want %q", name, "a_b_.zip")
+	}
+}
@@ -0,0 +1,469 @@
+// 将 Telegram 的 Instant View 页面转换为 Telegraph 节点, 以复用 Telegraph 文章的 HTML, Markdown 和 EPUB 导出
+package instantview
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gabriel-vasile/mimetype"
+	"github.com/gotd/td/tg"
+	"github.com/krau/SaveAny-Bot/pkg/ai"
+	"github.com/krau/SaveAny-Bot/pkg/telegraph"
+)
+
+var ErrNoInstantView = errors.New("web page has no instant view")
+
+// 页面中引用的图片和文档在节点中的地址, 下载后替换为本地文件
+const (
+	photoSrcPrefix    = "tg://photo/"
+	documentSrcPrefix = "tg://document/"
+)
+
+func photoSrc(id int64) string {
+	return photoSrcPrefix + strconv.FormatInt(id, 10)
+}
+
+func documentSrc(id int64) string {
+	return documentSrcPrefix + strconv.FormatInt(id, 10)
+}
+
+// 消息中带有 Instant View 的网页预览, 没有时返回 nil
+func FromMessage(msg *tg.Message) *tg.WebPage {
+	if msg == nil {
+		return nil
+	}
+	media, ok := msg.Media.(*tg.MessageMediaWebPage)
+	if !ok {
+		return nil
+	}
+	wp, ok := media.Webpage.(*tg.WebPage)
+	if !ok {
+		return nil
+	}
+	if _, ok := wp.GetCachedPage(); !ok {
+		return nil
+	}
+	return wp
+}
+
+// 消息中网页预览的地址, 没有网页预览时返回空字符串
+func URLFromMessage(msg *tg.Message) string {
+	if msg == nil {
+		return ""
+	}
+	media, ok := msg.Media.(*tg.MessageMediaWebPage)
+	if !ok {
+		return ""
+	}
+	switch wp := media.Webpage.(type) {
+	case *tg.WebPage:
+		return wp.URL
+	case *tg.WebPagePending:
+		return wp.URL
+	}
+	return ""
+}
+
+// 获取网页的 Instant View. 消息中的网页预览通常不带有页面内容, 需要单独获取.
+// 网页没有 Instant View 时返回 ErrNoInstantView
+func Fetch(ctx context.Context, client *tg.Client, url string) (*tg.WebPage, error) {
+	res, err := client.MessagesGetWebPage(ctx, &tg.MessagesGetWebPageRequest{URL: url})
+	if err != nil {
+		return nil, err
+	}
+	wp, ok := res.Webpage.(*tg.WebPage)
+	if !ok {
+		return nil, ErrNoInstantView
+	}
+	if _, ok := wp.GetCachedPage(); !ok {
+		return nil, ErrNoInstantView
+	}
+	return wp, nil
+}
+
+// 将网页的 Instant View 转换为 Telegraph 文章, 网页没有 Instant View 时返回 nil
+func Convert(wp *tg.WebPage) *telegraph.Page {
+	page, ok := wp.GetCachedPage()
+	if !ok {
+		return nil
+	}
+	c := &converter{}
+	var content []telegraph.Node
+	for _, block := range page.Blocks {
+		content = append(content, c.block(block)...)
+	}
+	title := wp.Title
+	if title == "" {
+		title = c.title
+	}
+	if title == "" {
+		title = wp.SiteName
+	}
+	if title == "" {
+		title = wp.DisplayURL
+	}
+	author := wp.Author
+	if author == "" {
+		author = c.author
+	}
+	return &telegraph.Page{
+		Path:        wp.URL,
+		Url:         wp.URL,
+		Title:       title,
+		Description: wp.Description,
+		AuthorName:  author,
+		Content:     content,
+	}
+}
+
+// 页面引用的图片和文档, 键为节点中的地址
+func Medias(wp *tg.WebPage) map[string]tg.MessageMediaClass {
+	page, ok := wp.GetCachedPage()
+	if !ok {
+		return nil
+	}
+	medias := make(map[string]tg.MessageMediaClass, len(page.Photos)+len(page.Documents))
+	for _, p := range page.Photos {
+		if photo, ok := p.AsNotEmpty(); ok {
+			medias[photoSrc(photo.ID)] = &tg.MessageMediaPhoto{Photo: photo}
+		}
+	}
+	for _, d := range page.Documents {
+		if doc, ok := d.AsNotEmpty(); ok {
+			medias[documentSrc(doc.ID)] = &tg.MessageMediaDocument{Document: doc}
+		}
+	}
+	return medias
+}
+
+// 保存文章的目录名, 取自标题, 过长时截断
+func DirName(page *telegraph.Page) string {
+	if strings.TrimSpace(page.Title) == "" {
+		return "instantview"
+	}
+	name := ai.SanitizeFilename(page.Title)
+	if runes := []rune(name); len(runes) > 64 {
+		name = strings.TrimSpace(string(runes[:64]))
+	}
+	if name == "" || strings.Trim(name, ".") == "" {
+		return "instantview"
+	}
+	return name
+}
+
+// 保存媒体时使用的扩展名
+func Ext(media tg.MessageMediaClass) string {
+	switch m := media.(type) {
+	case *tg.MessageMediaPhoto:
+		return ".jpg"
+	case *tg.MessageMediaDocument:
+		doc, ok := m.Document.AsNotEmpty()
+		if !ok {
+			return ""
+		}
+		for _, attr := range doc.Attributes {
+			if name, ok := attr.(*tg.DocumentAttributeFilename); ok && path.Ext(name.FileName) != "" {
+				return path.Ext(name.FileName)
+			}
+		}
+		if mt := mimetype.Lookup(doc.MimeType); mt != nil {
+			return mt.Extension()
+		}
+	}
+	return ""
+}
+
+type converter struct {
+	title  string // 页面中的标题块, 网页预览没有标题时使用
+	author string
+}
+
+func elem(tag string, children ...telegraph.Node) telegraph.NodeElement {
+	return telegraph.NodeElement{Tag: tag, Children: children}
+}
+
+func link(href string, children ...telegraph.Node) telegraph.NodeElement {
+	return telegraph.NodeElement{Tag: "a", Attrs: map[string]string{"href": href}, Children: children}
+}
+
+func mediaElem(tag, src string) telegraph.NodeElement {
+	return telegraph.NodeElement{Tag: tag, Attrs: map[string]string{"src": src}}
+}
+
+// 带说明的媒体
+func figure(caption tg.PageCaption, nodes ...telegraph.Node) []telegraph.Node {
+	children := nodes
+	text := richText(caption.Text)
+	if credit := richText(caption.Credit); len(credit) > 0 {
+		if len(text) > 0 {
+			text = append(text, " — ")
+		}
+		text = append(text, credit...)
+	}
+	if len(text) > 0 {
+		children = append(children, elem("figcaption", text...))
+	}
+	if len(children) == 0 {
+		return nil
+	}
+	return []telegraph.Node{elem("figure", children...)}
+}
+
+func (c *converter) blocks(blocks []tg.PageBlockClass) []telegraph.Node {
+	var nodes []telegraph.Node
+	for _, b := range blocks {
+		nodes = append(nodes, c.block(b)...)
+	}
+	return nodes
+}
+
+func (c *converter) block(block tg.PageBlockClass) []telegraph.Node {
+	switch b := block.(type) {
+	case *tg.PageBlockTitle:
+		if c.title == "" {
+			c.title = plainText(b.Text)
+		}
+		return nil
+	case *tg.PageBlockAuthorDate:
+		if c.author == "" {
+			c.author = plainText(b.Author)
+		}
+		if b.PublishedDate == 0 {
+			return nil
+		}
+		date := time.Unix(int64(b.PublishedDate), 0).Format(time.DateOnly)
+		return []telegraph.Node{elem("p", elem("em", date))}
+	case *tg.PageBlockSubtitle:
+		return []telegraph.Node{elem("h4", richText(b.Text)...)}
+	case *tg.PageBlockKicker:
+		return []telegraph.Node{elem("h4", richText(b.Text)...)}
+	case *tg.PageBlockHeader:
+		return []telegraph.Node{elem("h3", richText(b.Text)...)}
+	case *tg.PageBlockSubheader:
+		return []telegraph.Node{elem("h4", richText(b.Text)...)}
+	case *tg.PageBlockParagraph:
+		return []telegraph.Node{elem("p", richText(b.Text)...)}
+	case *tg.PageBlockFooter:
+		return []telegraph.Node{elem("p", elem("em", richText(b.Text)...))}
+	case *tg.PageBlockPreformatted:
+		return []telegraph.Node{elem("pre", plainText(b.Text))}
+	case *tg.PageBlockDivider:
+		return []telegraph.Node{elem("hr")}
+	case *tg.PageBlockList:
+		var items []telegraph.Node
+		for _, item := range b.Items {
+			switch it := item.(type) {
+			case *tg.PageListItemText:
+				items = append(items, elem("li", richText(it.Text)...))
+			case *tg.PageListItemBlocks:
+				items = append(items, elem("li", c.inlineBlocks(it.Blocks)...))
+			}
+		}
+		return []telegraph.Node{elem("ul", items...)}
+	case *tg.PageBlockOrderedList:
+		var items []telegraph.Node
+		for _, item := range b.Items {
+			switch it := item.(type) {
+			case *tg.PageListOrderedItemText:
+				items = append(items, elem("li", richText(it.Text)...))
+			case *tg.PageListOrderedItemBlocks:
+				items = append(items, elem("li", c.inlineBlocks(it.Blocks)...))
+			}
+		}
+		return []telegraph.Node{elem("ol", items...)}
+	case *tg.PageBlockBlockquote:
+		return []telegraph.Node{quote("blockquote", b.Text, b.Caption)}
+	case *tg.PageBlockPullquote:
+		return []telegraph.Node{quote("aside", b.Text, b.Caption)}
+	case *tg.PageBlockPhoto:
+		var img telegraph.Node = mediaElem("img", photoSrc(b.PhotoID))
+		if b.URL != "" {
+			img = link(b.URL, img)
+		}
+		return figure(b.Caption, img)
+	case *tg.PageBlockVideo:
+		return figure(b.Caption, mediaElem("video", documentSrc(b.VideoID)))
+	case *tg.PageBlockAudio:
+		// Telegraph 节点没有音频, 浏览器可以用 video 播放音频文件
+		return figure(b.Caption, mediaElem("video", documentSrc(b.AudioID)))
+	case *tg.PageBlockCover:
+		return c.block(b.Cover)
+	case *tg.PageBlockEmbed:
+		var nodes []telegraph.Node
+		if b.PosterPhotoID != 0 {
+			nodes = append(nodes, mediaElem("img", photoSrc(b.PosterPhotoID)))
+		}
+		if b.URL != "" {
+			nodes = append(nodes, mediaElem("iframe", b.URL))
+		}
+		return figure(b.Caption, nodes...)
+	case *tg.PageBlockEmbedPost:
+		children := []telegraph.Node{elem("p", elem("strong", b.Author))}
+		children = append(children, c.blocks(b.Blocks)...)
+		if b.URL != "" {
+			children = append(children, elem("p", link(b.URL, b.URL)))
+		}
+		return append([]telegraph.Node{elem("blockquote", children...)}, figure(b.Caption)...)
+	case *tg.PageBlockCollage:
+		return append(c.blocks(b.Items), figure(b.Caption)...)
+	case *tg.PageBlockSlideshow:
+		return append(c.blocks(b.Items), figure(b.Caption)...)
+	case *tg.PageBlockChannel:
+		channel, ok := b.Channel.(*tg.Channel)
+		if !ok {
+			return nil
+		}
+		if channel.Username == "" {
+			return []telegraph.Node{elem("p", elem("strong", channel.Title))}
+		}
+		return []telegraph.Node{elem("p", link("https://t.me/"+channel.Username, channel.Title))}
+	case *tg.PageBlockTable:
+		// Telegraph 节点没有表格, 每行转换为一个段落
+		var nodes []telegraph.Node
+		if title := richText(b.Title); len(title) > 0 {
+			nodes = append(nodes, elem("p", elem("strong", title...)))
+		}
+		for _, row := range b.Rows {
+			var cells []telegraph.Node
+			for i, cell := range row.Cells {
+				if i > 0 {
+					cells = append(cells, " | ")
+				}
+				text := richText(cell.Text)
+				if cell.Header {
+					cells = append(cells, elem("strong", text...))
+				} else {
+					cells = append(cells, text...)
+				}
+			}
+			nodes = append(nodes, elem("p", cells...))
+		}
+		return nodes
+	case *tg.PageBlockDetails:
+		return append([]telegraph.Node{elem("h4", richText(b.Title)...)}, c.blocks(b.Blocks)...)
+	case *tg.PageBlockRelatedArticles:
+		var items []telegraph.Node
+		for _, article := range b.Articles {
+			title := article.Title
+			if title == "" {
+				title = article.URL
+			}
+			items = append(items, elem("li", link(article.URL, title)))
+		}
+		if len(items) == 0 {
+			return nil
+		}
+		return []telegraph.Node{elem("h4", richText(b.Title)...), elem("ul", items...)}
+	case *tg.PageBlockMap:
+		geo, ok := b.Geo.(*tg.GeoPoint)
+		if !ok {
+			return nil
+		}
+		href := fmt.Sprintf("https://maps.google.com/?q=%f,%f", geo.Lat, geo.Long)
+		return figure(b.Caption, elem("p", link(href, href)))
+	}
+	return nil
+}
+
+// 列表项中的块, 段落直接展开为文本
+func (c *converter) inlineBlocks(blocks []tg.PageBlockClass) []telegraph.Node {
+	var nodes []telegraph.Node
+	for _, b := range blocks {
+		if p, ok := b.(*tg.PageBlockParagraph); ok {
+			nodes = append(nodes, richText(p.Text)...)
+			continue
+		}
+		nodes = append(nodes, c.block(b)...)
+	}
+	return nodes
+}
+
+func quote(tag string, text, caption tg.RichTextClass) telegraph.NodeElement {
+	children := richText(text)
+	if cite := richText(caption); len(cite) > 0 {
+		children = append(children, elem("br"), "— ")
+		children = append(children, cite...)
+	}
+	return elem(tag, children...)
+}
+
+func richText(text tg.RichTextClass) []telegraph.Node {
+	switch t := text.(type) {
+	case nil, *tg.TextEmpty:
+		return nil
+	case *tg.TextPlain:
+		return splitLines(t.Text)
+	case *tg.TextConcat:
+		var nodes []telegraph.Node
+		for _, part := range t.Texts {
+			nodes = append(nodes, richText(part)...)
+		}
+		return nodes
+	case *tg.TextBold:
+		return []telegraph.Node{elem("strong", richText(t.Text)...)}
+	case *tg.TextItalic:
+		return []telegraph.Node{elem("em", richText(t.Text)...)}
+	case *tg.TextUnderline:
+		return []telegraph.Node{elem("u", richText(t.Text)...)}
+	case *tg.TextStrike:
+		return []telegraph.Node{elem("s", richText(t.Text)...)}
+	case *tg.TextFixed:
+		return []telegraph.Node{elem("code", richText(t.Text)...)}
+	case *tg.TextURL:
+		return []telegraph.Node{link(t.URL, richText(t.Text)...)}
+	case *tg.TextEmail:
+		return []telegraph.Node{link("mailto:"+t.Email, richText(t.Text)...)}
+	case *tg.TextPhone:
+		return []telegraph.Node{link("tel:"+t.Phone, richText(t.Text)...)}
+	case *tg.TextImage:
+		return []telegraph.Node{mediaElem("img", documentSrc(t.DocumentID))}
+	case *tg.TextSubscript:
+		return richText(t.Text)
+	case *tg.TextSuperscript:
+		return richText(t.Text)
+	case *tg.TextMarked:
+		return richText(t.Text)
+	case *tg.TextAnchor:
+		return richText(t.Text)
+	}
+	return nil
+}
+
+// 文本中的换行转换为 br
+func splitLines(s string) []telegraph.Node {
+	lines := strings.Split(s, "\n")
+	nodes := make([]telegraph.Node, 0, len(lines)*2)
+	for i, line := range lines {
+		if i > 0 {
+			nodes = append(nodes, elem("br"))
+		}
+		if line != "" {
+			nodes = append(nodes, line)
+		}
+	}
+	return nodes
+}
+
+func plainText(text tg.RichTextClass) string {
+	var b strings.Builder
+	var walk func(t tg.RichTextClass)
+	walk = func(t tg.RichTextClass) {
+		switch v := t.(type) {
+		case *tg.TextPlain:
+			b.WriteString(v.Text)
+		case *tg.TextConcat:
+			for _, part := range v.Texts {
+				walk(part)
+			}
+		case interface{ GetText() tg.RichTextClass }:
+			walk(v.GetText())
+		}
+	}
+	walk(text)
+	return b.String()
+}
@@ -0,0 +1,98 @@
+package instantview
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gotd/td/tg"
+	"github.com/krau/SaveAny-Bot/pkg/telegraph"
+)
+
+func testWebPage() *tg.WebPage {
+	wp := &tg.WebPage{
+		URL:        "https://example.com/post/1",
+		DisplayURL: "example.com/post/1",
+		SiteName:   "Example",
+	}
+	wp.SetCachedPage(tg.Page{
+		URL: "https://example.com/post/1",
+		Blocks: []tg.PageBlockClass{
+			&tg.PageBlockTitle{Text: &tg.TextPlain{Text: "Post Title"}},
+			&tg.PageBlockAuthorDate{Author: &tg.TextPlain{Text: "Author"}},
+			&tg.PageBlockHeader{Text: &tg.TextPlain{Text: "Heading"}},
+			&tg.PageBlockParagraph{Text: &tg.TextConcat{Texts: []tg.RichTextClass{
+				&tg.TextPlain{Text: "Hello "},
+				&tg.TextBold{Text: &tg.TextPlain{Text: "bold"}},
+				&tg.TextPlain{Text: " "},
+				&tg.TextURL{Text: &tg.TextPlain{Text: "link"}, URL: "https://example.com/"},
+			}}},
+			&tg.PageBlockPhoto{PhotoID: 10, Caption: tg.PageCaption{
+				Text:   &tg.TextPlain{Text: "Caption"},
+				Credit: &tg.TextEmpty{},
+			}},
+			&tg.PageBlockCover{Cover: &tg.PageBlockVideo{VideoID: 20, Caption: tg.PageCaption{
+				Text:   &tg.TextEmpty{},
+				Credit: &tg.TextEmpty{},
+			}}},
+			&tg.PageBlockList{Items: []tg.PageListItemClass{
+				&tg.PageListItemText{Text: &tg.TextPlain{Text: "one"}},
+				&tg.PageListItemBlocks{Blocks: []tg.PageBlockClass{
+					&tg.PageBlockParagraph{Text: &tg.TextItalic{Text: &tg.TextPlain{Text: "two"}}},
+				}},
+			}},
+			&tg.PageBlockPreformatted{Text: &tg.TextPlain{Text: "code\nblock"}},
+		},
+		Photos: []tg.PhotoClass{&tg.Photo{ID: 10}},
+		Documents: []tg.DocumentClass{&tg.Document{ID: 20, MimeType: "video/mp4", Attributes: []tg.DocumentAttributeClass{
+			&tg.DocumentAttributeVideo{},
+		}}},
+	})
+	return wp
+}
+
+func TestFromMessage(t *testing.T) {
+	if FromMessage(&tg.Message{Media: &tg.MessageMediaWebPage{Webpage: &tg.WebPage{URL: "https://example.com/"}}}) != nil {
+		t.Error("web page without instant view should be ignored")
+	}
+	if FromMessage(&tg.Message{Media: &tg.MessageMediaWebPage{Webpage: testWebPage()}}) == nil {
+		t.Error("web page with instant view not detected")
+	}
+}
+
+func TestConvert(t *testing.T) {
+	page := Convert(testWebPage())
+	if page.Title != "Post Title" || page.AuthorName != "Author" || page.Url != "https://example.com/post/1" {
+		t.Fatalf("unexpected page header: %+v", page)
+	}
+	medias := telegraph.CollectMedia(page.Content)
+	if len(medias) != 2 || medias[0].Src != "tg://photo/10" || medias[1].Src != "tg://document/20" {
+		t.Fatalf("unexpected medias: %+v", medias)
+	}
+	md := string(telegraph.RenderMarkdown(page, map[string]string{"tg://photo/10": "1.jpg", "tg://document/20": "2.mp4"}))
+	for _, s := range []string{
+		"# Post Title",
+		"### Heading",
+		"Hello **bold** [link](https://example.com/)",
+		"![](1.jpg)\n\n*Caption*",
+		"[视频](2.mp4)",
+		"- one\n- *two*",
+		"```\ncode\nblock\n```",
+	} {
+		if !strings.Contains(md, s) {
+			t.Errorf("markdown missing %q:\n%s", s, md)
+		}
+	}
+}
+
+func TestMedias(t *testing.T) {
+	medias := Medias(testWebPage())
+	if len(medias) != 2 {
+		t.Fatalf("got %d medias, want 2", len(medias))
+	}
+	if ext := Ext(medias["tg://photo/10"]); ext != ".jpg" {
+		t.Errorf("photo ext = %q", ext)
+	}
+	if ext := Ext(medias["tg://document/20"]); ext != ".mp4" {
+		t.Errorf("document ext = %q", ext)
+	}
+}
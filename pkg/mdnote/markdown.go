@@ -0,0 +1,283 @@
+package mdnote
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+
+	"github.com/gotd/td/tg"
+)
+
+// 消息实体对应的 Markdown 标记, 偏移和长度以 UTF-16 编码单元计
+type span struct {
+	start, end  int
+	open, close string
+	raw         bool // 内容不转义, 用于代码和链接地址
+	pre         bool // 代码块, 需要单独成行
+	quote       bool
+}
+
+// 将消息文本和实体转换为 Markdown
+func Markdown(text string, entities []tg.MessageEntityClass) string {
+	units := utf16.Encode([]rune(text))
+	spans := make([]*span, 0, len(entities))
+	for _, e := range entities {
+		if s := newSpan(e, units); s != nil {
+			spans = append(spans, s)
+		}
+	}
+	sort.SliceStable(spans, func(i, j int) bool {
+		if spans[i].start != spans[j].start {
+			return spans[i].start < spans[j].start
+		}
+		return spans[i].end > spans[j].end
+	})
+
+	bounds := []int{0, len(units)}
+	for _, s := range spans {
+		bounds = append(bounds, s.start, s.end)
+	}
+	sort.Ints(bounds)
+
+	w := &writer{lineStart: true}
+	var stack []*span
+	next := 0
+	prev := -1
+	for _, pos := range bounds {
+		if pos == prev {
+			continue
+		}
+		prev = pos
+		// 关闭在此结束的实体, 与之交叉的实体关闭后重新打开
+		closing := -1
+		for i, s := range stack {
+			if s.end <= pos {
+				closing = i
+				break
+			}
+		}
+		if closing >= 0 {
+			var reopen []*span
+			for i := len(stack) - 1; i >= closing; i-- {
+				w.closeSpan(stack[i])
+				if stack[i].end > pos {
+					reopen = append(reopen, stack[i])
+				}
+			}
+			stack = stack[:closing]
+			for i := len(reopen) - 1; i >= 0; i-- {
+				w.openSpan(reopen[i])
+				stack = append(stack, reopen[i])
+			}
+		}
+		for next < len(spans) && spans[next].start == pos {
+			w.openSpan(spans[next])
+			stack = append(stack, spans[next])
+			next++
+		}
+		if pos >= len(units) {
+			break
+		}
+		end := len(units)
+		for _, b := range bounds {
+			if b > pos {
+				end = b
+				break
+			}
+		}
+		raw := false
+		for _, s := range stack {
+			raw = raw || s.raw
+		}
+		w.text(string(utf16.Decode(units[pos:end])), raw)
+	}
+	for i := len(stack) - 1; i >= 0; i-- {
+		w.closeSpan(stack[i])
+	}
+	return strings.TrimRight(w.b.String(), "\n")
+}
+
+func newSpan(e tg.MessageEntityClass, units []uint16) *span {
+	start := max(e.GetOffset(), 0)
+	end := min(start+e.GetLength(), len(units))
+	if start >= end {
+		return nil
+	}
+	content := string(utf16.Decode(units[start:end]))
+	s := &span{start: start, end: end}
+	switch e := e.(type) {
+	case *tg.MessageEntityBold:
+		s.open, s.close = "**", "**"
+	case *tg.MessageEntityItalic:
+		s.open, s.close = "*", "*"
+	case *tg.MessageEntityUnderline:
+		s.open, s.close = "<u>", "</u>"
+	case *tg.MessageEntityStrike:
+		s.open, s.close = "~~", "~~"
+	case *tg.MessageEntitySpoiler:
+		s.open, s.close = "||", "||"
+	case *tg.MessageEntityCode:
+		fence := "`"
+		if strings.Contains(content, "`") {
+			fence = "`` "
+		}
+		s.open, s.close, s.raw = fence, reverse(fence), true
+	case *tg.MessageEntityPre:
+		fence := "```"
+		for strings.Contains(content, fence) {
+			fence += "`"
+		}
+		s.open, s.close, s.raw, s.pre = fence+e.Language+"\n", fence, true, true
+		return s
+	case *tg.MessageEntityBlockquote:
+		s.quote = true
+		return s
+	case *tg.MessageEntityTextURL:
+		s.open, s.close = "[", "]("+linkURL(e.URL)+")"
+	case *tg.MessageEntityMentionName:
+		s.open, s.close = "[", fmt.Sprintf("](tg://user?id=%d)", e.UserID)
+	case *tg.MessageEntityMention:
+		s.open, s.close = "[", "](https://t.me/"+strings.TrimPrefix(content, "@")+")"
+	case *tg.MessageEntityURL:
+		s.open, s.close, s.raw = "<", ">", true
+		return s
+	case *tg.MessageEntityEmail:
+		s.open, s.close, s.raw = "<", ">", true
+		return s
+	default:
+		return nil
+	}
+	// 标记内侧不能是空白, 否则不会被识别为强调
+	for s.start < s.end && isSpace(units[s.start]) {
+		s.start++
+	}
+	for s.end > s.start && isSpace(units[s.end-1]) {
+		s.end--
+	}
+	if s.start >= s.end {
+		return nil
+	}
+	return s
+}
+
+func isSpace(u uint16) bool {
+	return unicode.IsSpace(rune(u))
+}
+
+func reverse(s string) string {
+	r := []rune(s)
+	for i, j := 0, len(r)-1; i < j; i, j = i+1, j-1 {
+		r[i], r[j] = r[j], r[i]
+	}
+	return string(r)
+}
+
+// 链接地址中的空格和括号会截断 Markdown 链接
+func linkURL(u string) string {
+	if parsed, err := url.Parse(u); err == nil {
+		u = parsed.String()
+	}
+	return strings.NewReplacer(" ", "%20", "(", "%28", ")", "%29").Replace(u)
+}
+
+type writer struct {
+	b         strings.Builder
+	lineStart bool
+	quote     bool
+	// 代码块和引用结束后需要空行, 否则之后的文本会接在其中
+	pendingBreak bool
+}
+
+func (w *writer) openSpan(s *span) {
+	switch {
+	case s.quote:
+		w.newLine()
+		w.quote = true
+		w.b.WriteString("> ")
+		w.lineStart = true
+	case s.pre:
+		w.newLine()
+		w.b.WriteString(s.open)
+		if w.quote {
+			w.b.WriteString("> ")
+		}
+		w.lineStart = true
+	default:
+		w.flushBreak()
+		w.b.WriteString(s.open)
+		w.lineStart = false
+	}
+}
+
+func (w *writer) closeSpan(s *span) {
+	switch {
+	case s.quote:
+		w.quote = false
+		w.pendingBreak = true
+	case s.pre:
+		w.newLine()
+		w.b.WriteString(s.close)
+		w.pendingBreak = true
+	default:
+		w.b.WriteString(s.close)
+		w.lineStart = false
+	}
+}
+
+// 确保之后的内容从新的一行开始
+func (w *writer) newLine() {
+	if w.flushBreak() || w.lineStart {
+		return
+	}
+	w.b.WriteByte('\n')
+	if w.quote {
+		w.b.WriteString("> ")
+	}
+	w.lineStart = true
+}
+
+func (w *writer) flushBreak() bool {
+	if !w.pendingBreak {
+		return false
+	}
+	w.pendingBreak = false
+	w.b.WriteString("\n\n")
+	if w.quote {
+		w.b.WriteString("> ")
+	}
+	w.lineStart = true
+	return true
+}
+
+func (w *writer) text(s string, raw bool) {
+	if w.pendingBreak {
+		s = strings.TrimLeft(s, "\n")
+		if s == "" {
+			return
+		}
+		w.flushBreak()
+	}
+	for _, r := range s {
+		if r == '\n' {
+			w.b.WriteByte('\n')
+			if w.quote {
+				w.b.WriteString("> ")
+			}
+			w.lineStart = true
+			continue
+		}
+		if !raw && (strings.ContainsRune("\\`*_[]~|<", r) || w.lineStart && (r == '#' || r == '>')) {
+			w.b.WriteByte('\\')
+		}
+		w.b.WriteRune(r)
+		w.lineStart = false
+	}
+}
+
+// 转义普通文本中的 Markdown 标记
+func Escape(text string) string {
+	return Markdown(text, nil)
+}
@@ -0,0 +1,110 @@
+package mdnote
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gotd/td/tg"
+)
+
+func TestMarkdown(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		entities []tg.MessageEntityClass
+		want     string
+	}{
+		{
+			name: "plain text is escaped",
+			text: "a_b *c*\n# not heading",
+			want: "a\\_b \\*c\\*\n\\# not heading",
+		},
+		{
+			name: "offsets are utf-16",
+			text: "😀 bold and link",
+			entities: []tg.MessageEntityClass{
+				&tg.MessageEntityBold{Offset: 3, Length: 4},
+				&tg.MessageEntityTextURL{Offset: 12, Length: 4, URL: "https://example.com/a b"},
+			},
+			want: "😀 **bold** and [link](https://example.com/a%20b)",
+		},
+		{
+			name: "nested and trailing space",
+			text: "bold italic rest",
+			entities: []tg.MessageEntityClass{
+				&tg.MessageEntityBold{Offset: 0, Length: 12},
+				&tg.MessageEntityItalic{Offset: 5, Length: 6},
+			},
+			want: "**bold *italic*** rest",
+		},
+		{
+			name: "code is not escaped",
+			text: "run a_b now",
+			entities: []tg.MessageEntityClass{
+				&tg.MessageEntityCode{Offset: 4, Length: 3},
+			},
+			want: "run `a_b` now",
+		},
+		{
+			name: "pre block",
+			text: "see:\nfmt.Println(1)\ndone",
+			entities: []tg.MessageEntityClass{
+				&tg.MessageEntityPre{Offset: 5, Length: 14, Language: "go"},
+			},
+			want: "see:\n```go\nfmt.Println(1)\n```\n\ndone",
+		},
+		{
+			name: "blockquote spoiler and mention",
+			text: "line1\nline2\nsecret @user",
+			entities: []tg.MessageEntityClass{
+				&tg.MessageEntityBlockquote{Offset: 0, Length: 11},
+				&tg.MessageEntitySpoiler{Offset: 12, Length: 6},
+				&tg.MessageEntityMention{Offset: 19, Length: 5},
+			},
+			want: "> line1\n> line2\n\n||secret|| [@user](https://t.me/user)",
+		},
+		{
+			name: "url is kept raw",
+			text: "https://example.com/a_b",
+			entities: []tg.MessageEntityClass{
+				&tg.MessageEntityURL{Offset: 0, Length: 23},
+			},
+			want: "<https://example.com/a_b>",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Markdown(tt.text, tt.entities); got != tt.want {
+				t.Errorf("Markdown() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNote(t *testing.T) {
+	date := time.Date(2024, 5, 6, 7, 8, 9, 0, time.Local)
+	note := &Note{
+		Chat:   "News/Channel",
+		Thread: true,
+		Messages: []Message{
+			{ID: 1, Date: date, Author: "Alice", Link: "https://t.me/news/1", Text: "hello"},
+			{ID: 2, Date: date.Add(time.Minute), Author: "Bob", Text: "caption", Media: "照片"},
+		},
+	}
+	if got, want := note.FileName(), "2024-05-06_070809_News_Channel_thread.md"; got != want {
+		t.Errorf("FileName() = %q, want %q", got, want)
+	}
+	md := string(note.Render())
+	for _, want := range []string{
+		"# News/Channel\n",
+		"共 2 条消息",
+		"### Alice · 2024-05-06 07:08:09",
+		"[原消息](https://t.me/news/1)",
+		"*\\[照片\\]*\n\ncaption",
+	} {
+		if !strings.Contains(md, want) {
+			t.Errorf("Render() missing %q in:\n%s", want, md)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+// Package mdnote 将 Telegram 文本消息保存为 Markdown 笔记
+package mdnote
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gotd/td/tg"
+)
+
+// 笔记中的一条消息
+type Message struct {
+	ID       int
+	Date     time.Time
+	Author   string // 发送者名称, 可以为空
+	Link     string // 原消息链接, 私聊中的消息没有链接
+	Text     string
+	Entities []tg.MessageEntityClass
+	Media    string // 消息中媒体的说明, 如 "照片", 没有媒体时为空
+}
+
+// 由一条消息或一个对话中的多条消息组成的笔记
+type Note struct {
+	Chat     string // 来源聊天的名称
+	Thread   bool
+	Messages []Message
+}
+
+const timeLayout = "2006-01-02 15:04:05"
+
+// 生成笔记的 Markdown 内容
+func (n *Note) Render() []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n", Escape(n.Title()))
+	if !n.Thread && len(n.Messages) == 1 {
+		m := n.Messages[0]
+		if m.Author != "" && m.Author != n.Chat {
+			fmt.Fprintf(&b, "- 作者: %s\n", Escape(m.Author))
+		}
+		fmt.Fprintf(&b, "- 时间: %s\n", m.Date.Local().Format(timeLayout))
+		if m.Link != "" {
+			fmt.Fprintf(&b, "- 链接: <%s>\n", m.Link)
+		}
+		b.WriteString("\n")
+		writeBody(&b, m)
+		return []byte(b.String())
+	}
+	fmt.Fprintf(&b, "共 %d 条消息\n", len(n.Messages))
+	for _, m := range n.Messages {
+		b.WriteString("\n---\n\n### ")
+		if m.Author != "" {
+			b.WriteString(Escape(m.Author) + " · ")
+		}
+		b.WriteString(m.Date.Local().Format(timeLayout) + "\n\n")
+		if m.Link != "" {
+			fmt.Fprintf(&b, "[原消息](%s)\n\n", m.Link)
+		}
+		writeBody(&b, m)
+	}
+	return []byte(b.String())
+}
+
+func writeBody(b *strings.Builder, m Message) {
+	if m.Media != "" {
+		fmt.Fprintf(b, "*\\[%s\\]*\n\n", m.Media)
+	}
+	if text := Markdown(m.Text, m.Entities); text != "" {
+		b.WriteString(text + "\n")
+	}
+}
+
+// 笔记标题, 即来源聊天的名称
+func (n *Note) Title() string {
+	if n.Chat != "" {
+		return n.Chat
+	}
+	return "笔记"
+}
+
+// 笔记的时间, 对话使用第一条消息的时间
+func (n *Note) Date() time.Time {
+	if len(n.Messages) == 0 {
+		return time.Time{}
+	}
+	return n.Messages[0].Date
+}
+
+var fileNameReplacer = strings.NewReplacer("/", "_", "\\", "_", ":", "_", "*", "_", "?", "_", "\"", "_", "<", "_", ">", "_", "|", "_", "\n", " ")
+
+// 保存笔记的文件名, 由时间和聊天名称组成
+func (n *Note) FileName() string {
+	chat := strings.TrimSpace(fileNameReplacer.Replace(n.Title()))
+	if runes := []rune(chat); len(runes) > 48 {
+		chat = strings.TrimSpace(string(runes[:48]))
+	}
+	name := n.Date().Local().Format("2006-01-02_150405") + "_" + chat
+	if n.Thread {
+		name += "_thread"
+	}
+	return name + ".md"
+}
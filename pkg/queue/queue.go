@@ -88,6 +88,7 @@ func (tq *TaskQueue[T]) Done(taskID string) {
 
 	delete(tq.taskMap, taskID)
 	delete(tq.runningTaskMap, taskID)
+	tq.cond.Broadcast()
 }
 
 func (tq *TaskQueue[T]) Peek() (*Task[T], error) {
@@ -128,6 +129,25 @@ func (tq *TaskQueue[T]) ActiveLength() int {
 	return count
 }
 
+// 返回任务在等待队列中的位置 (从 1 开始, 不计已取消的任务), 不在等待队列中时返回 0
+func (tq *TaskQueue[T]) Position(taskID string) int {
+	tq.mu.RLock()
+	defer tq.mu.RUnlock()
+
+	pos := 0
+	for element := tq.tasks.Front(); element != nil; element = element.Next() {
+		task := element.Value.(*Task[T])
+		if task.IsCancelled() {
+			continue
+		}
+		pos++
+		if task.ID == taskID {
+			return pos
+		}
+	}
+	return 0
+}
+
 func (tq *TaskQueue[T]) CancelTask(taskID string) error {
 	tq.mu.RLock()
 	task, exists := tq.taskMap[taskID]
@@ -198,6 +218,48 @@ func (tq *TaskQueue[T]) Close() {
 	tq.cond.Broadcast()
 }
 
+// 关闭队列并取出所有等待中的任务 (不含已取消的任务). 之后 Get 在没有任务时返回错误
+func (tq *TaskQueue[T]) Drain() []*Task[T] {
+	tq.mu.Lock()
+	defer tq.mu.Unlock()
+
+	drained := make([]*Task[T], 0, tq.tasks.Len())
+	for element := tq.tasks.Front(); element != nil; element = element.Next() {
+		task := element.Value.(*Task[T])
+		task.element = nil
+		delete(tq.taskMap, task.ID)
+		if !task.IsCancelled() {
+			drained = append(drained, task)
+		}
+	}
+	tq.tasks.Init()
+	tq.closed = true
+	tq.cond.Broadcast()
+	return drained
+}
+
+// 返回正在执行的任务
+func (tq *TaskQueue[T]) Running() []*Task[T] {
+	tq.mu.RLock()
+	defer tq.mu.RUnlock()
+
+	running := make([]*Task[T], 0, len(tq.runningTaskMap))
+	for _, task := range tq.runningTaskMap {
+		running = append(running, task)
+	}
+	return running
+}
+
+// 阻塞直到没有正在执行的任务. Get 交出的任务在 Done 之前都计为正在执行,
+// 因此 Drain 之后调用时, 不会漏掉已经交出但还未开始执行的任务
+func (tq *TaskQueue[T]) WaitRunning() {
+	tq.mu.Lock()
+	defer tq.mu.Unlock()
+	for len(tq.runningTaskMap) > 0 {
+		tq.cond.Wait()
+	}
+}
+
 func (tq *TaskQueue[T]) IsClosed() bool {
 	tq.mu.RLock()
 	defer tq.mu.RUnlock()
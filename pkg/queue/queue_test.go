@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/krau/SaveAny-Bot/pkg/queue"
 )
@@ -90,6 +91,26 @@ func TestCancelAndActiveLength(t *testing.T) {
 	}
 }
 
+func TestPosition(t *testing.T) {
+	q := queue.NewTaskQueue[int]()
+	for _, id := range []string{"p1", "p2", "p3"} {
+		q.Add(newTask(id))
+	}
+	if pos := q.Position("p3"); pos != 3 {
+		t.Fatalf("expected position 3, got %d", pos)
+	}
+	q.CancelTask("p1")
+	if pos := q.Position("p3"); pos != 2 {
+		t.Fatalf("expected position 2 after cancel, got %d", pos)
+	}
+	if pos := q.Position("p1"); pos != 0 {
+		t.Fatalf("expected cancelled task to have position 0, got %d", pos)
+	}
+	if pos := q.Position("missing"); pos != 0 {
+		t.Fatalf("expected missing task to have position 0, got %d", pos)
+	}
+}
+
 func TestRemoveTask(t *testing.T) {
 	q := queue.NewTaskQueue[int]()
 	t1 := newTask("r1")
@@ -143,6 +164,66 @@ func TestCloseBehavior(t *testing.T) {
 	<-done
 }
 
+func TestDrainAndRunning(t *testing.T) {
+	q := queue.NewTaskQueue[int]()
+	for _, id := range []string{"a", "b", "c"} {
+		if err := q.Add(newTask(id)); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	running, err := q.Get()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	q.CancelTask("c")
+	drained := q.Drain()
+	if len(drained) != 1 || drained[0].ID != "b" {
+		t.Fatalf("expected only b to be drained, got %v", drained)
+	}
+	if got := q.Running(); len(got) != 1 || got[0].ID != running.ID {
+		t.Fatalf("expected %s to be running, got %v", running.ID, got)
+	}
+	if err := q.Add(newTask("d")); err == nil {
+		t.Fatal("expected add to fail after drain")
+	}
+	if _, err := q.Get(); err == nil {
+		t.Fatal("expected get to fail after drain")
+	}
+	cause := fmt.Errorf("shutdown")
+	running.CancelWithCause(cause)
+	if context.Cause(running.Context()) != cause {
+		t.Fatalf("expected cancel cause to be kept, got %v", context.Cause(running.Context()))
+	}
+}
+
+func TestWaitRunning(t *testing.T) {
+	q := queue.NewTaskQueue[int]()
+	if err := q.Add(newTask("a")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	running, err := q.Get()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	q.Drain()
+	waited := make(chan struct{})
+	go func() {
+		q.WaitRunning()
+		close(waited)
+	}()
+	select {
+	case <-waited:
+		t.Fatal("expected WaitRunning to block while a task is running")
+	case <-time.After(50 * time.Millisecond):
+	}
+	q.Done(running.ID)
+	select {
+	case <-waited:
+	case <-time.After(time.Second):
+		t.Fatal("expected WaitRunning to return after Done")
+	}
+}
+
 func TestConcurrencySafety(t *testing.T) {
 	q := queue.NewTaskQueue[int]()
 	var wg sync.WaitGroup
@@ -10,13 +10,13 @@ type Task[T any] struct {
 	ID      string
 	Data    T
 	ctx     context.Context
-	cancel  context.CancelFunc
+	cancel  context.CancelCauseFunc
 	created time.Time
 	element *list.Element
 }
 
 func NewTask[T any](ctx context.Context, id string, data T) *Task[T] {
-	cancelCtx, cancel := context.WithCancel(ctx)
+	cancelCtx, cancel := context.WithCancelCause(ctx)
 	return &Task[T]{
 		ID:      id,
 		Data:    data,
@@ -36,7 +36,12 @@ func (t *Task[T]) IsCancelled() bool {
 }
 
 func (t *Task[T]) Cancel() {
-	t.cancel()
+	t.cancel(nil)
+}
+
+// 以指定原因取消任务, 可以通过 context.Cause(task.Context()) 获取
+func (t *Task[T]) CancelWithCause(cause error) {
+	t.cancel(cause)
 }
 
 func (t *Task[T]) Context() context.Context {
@@ -19,7 +19,12 @@ func (r RuleFileNameRegex) Type() ruleenum.RuleType {
 }
 
 func (r RuleFileNameRegex) Match(input tfile.TGFile) (bool, error) {
-	return r.regex.MatchString(input.Name()), nil
+	return r.MatchName(input.Name())
+}
+
+// 匹配不来自 Telegram 的文件名, 例如直链下载的文件
+func (r RuleFileNameRegex) MatchName(name string) (bool, error) {
+	return r.regex.MatchString(name), nil
 }
 
 func (r RuleFileNameRegex) StorageName() string {
@@ -0,0 +1,153 @@
+// 全局传输槽位池, 所有任务 (单文件, 批量任务的元素, telegraph 图片) 在开始传输前都需要获取一个槽位,
+// 以保证全局和单用户的并发限制真正生效
+package slotpool
+
+import (
+	"container/list"
+	"context"
+	"sync"
+
+	"github.com/krau/SaveAny-Bot/config"
+)
+
+type Pool struct {
+	mu      sync.Mutex
+	limit   int // 全局槽位数
+	perUser int // 单个用户可同时占用的槽位数, 0 为不限制
+	active  int
+	users   map[int64]int
+	waiters *list.List // *waiter, 按申请顺序排列
+}
+
+type waiter struct {
+	userID int64
+	ready  chan struct{}
+}
+
+func NewPool(limit, perUser int) *Pool {
+	if limit < 1 {
+		limit = 1
+	}
+	return &Pool{
+		limit:   limit,
+		perUser: perUser,
+		users:   make(map[int64]int),
+		waiters: list.New(),
+	}
+}
+
+// 调用方需持有锁
+func (p *Pool) canRun(userID int64) bool {
+	if p.active >= p.limit {
+		return false
+	}
+	return p.perUser <= 0 || p.users[userID] < p.perUser
+}
+
+// 调用方需持有锁
+func (p *Pool) take(userID int64) {
+	p.active++
+	p.users[userID]++
+}
+
+// 按申请顺序唤醒可以运行的等待者, 已达到单用户上限的等待者会被跳过. 调用方需持有锁
+func (p *Pool) grant() {
+	for e := p.waiters.Front(); e != nil && p.active < p.limit; {
+		next := e.Next()
+		w := e.Value.(*waiter)
+		if p.canRun(w.userID) {
+			p.take(w.userID)
+			p.waiters.Remove(e)
+			close(w.ready)
+		}
+		e = next
+	}
+}
+
+func (p *Pool) release(userID int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.active--
+	p.users[userID]--
+	if p.users[userID] <= 0 {
+		delete(p.users, userID)
+	}
+	p.grant()
+}
+
+func (p *Pool) releaseFunc(userID int64) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			p.release(userID)
+		})
+	}
+}
+
+// 为用户获取一个传输槽位, 阻塞直到获取成功或 ctx 结束. 返回的 release 函数可以安全地多次调用
+func (p *Pool) Acquire(ctx context.Context, userID int64) (release func(), err error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	// grant 保证了队列中不存在可以运行的等待者, 因此这里可以直接获取而不破坏顺序
+	if p.canRun(userID) {
+		p.take(userID)
+		p.mu.Unlock()
+		return p.releaseFunc(userID), nil
+	}
+	w := &waiter{userID: userID, ready: make(chan struct{})}
+	elem := p.waiters.PushBack(w)
+	p.mu.Unlock()
+
+	select {
+	case <-w.ready:
+		return p.releaseFunc(userID), nil
+	case <-ctx.Done():
+		p.mu.Lock()
+		select {
+		case <-w.ready:
+			// 在取消的同时已被分配槽位, 归还
+			p.mu.Unlock()
+			p.release(userID)
+		default:
+			p.waiters.Remove(elem)
+			p.mu.Unlock()
+		}
+		return nil, ctx.Err()
+	}
+}
+
+// 正在使用的槽位数
+func (p *Pool) Active() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.active
+}
+
+// 正在等待槽位的数量
+func (p *Pool) Waiting() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.waiters.Len()
+}
+
+// 用户正在使用的槽位数
+func (p *Pool) UserActive(userID int64) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.users[userID]
+}
+
+var (
+	defaultPool *Pool
+	defaultOnce sync.Once
+)
+
+// 使用配置文件中的 workers 和 transfer.per_user 创建的全局槽位池
+func Default() *Pool {
+	defaultOnce.Do(func() {
+		defaultPool = NewPool(config.Cfg.Workers, config.Cfg.Transfer.PerUser)
+	})
+	return defaultPool
+}
@@ -0,0 +1,111 @@
+package slotpool_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/krau/SaveAny-Bot/pkg/slotpool"
+)
+
+func acquireAsync(p *slotpool.Pool, ctx context.Context, userID int64) <-chan error {
+	ch := make(chan error, 1)
+	go func() {
+		_, err := p.Acquire(ctx, userID)
+		ch <- err
+	}()
+	return ch
+}
+
+func expectBlocked(t *testing.T, ch <-chan error) {
+	t.Helper()
+	select {
+	case err := <-ch:
+		t.Fatalf("expected acquire to block, got %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func expectAcquired(t *testing.T, ch <-chan error) {
+	t.Helper()
+	select {
+	case err := <-ch:
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("acquire did not return")
+	}
+}
+
+func TestGlobalLimit(t *testing.T) {
+	p := slotpool.NewPool(2, 0)
+	r1, _ := p.Acquire(context.Background(), 1)
+	if _, err := p.Acquire(context.Background(), 2); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ch := acquireAsync(p, context.Background(), 3)
+	expectBlocked(t, ch)
+	if p.Waiting() != 1 {
+		t.Fatalf("expected 1 waiter, got %d", p.Waiting())
+	}
+	r1()
+	r1() // should be idempotent
+	expectAcquired(t, ch)
+	if p.Active() != 2 {
+		t.Fatalf("expected 2 active slots, got %d", p.Active())
+	}
+}
+
+func TestPerUserLimit(t *testing.T) {
+	p := slotpool.NewPool(3, 1)
+	r1, _ := p.Acquire(context.Background(), 1)
+	blocked := acquireAsync(p, context.Background(), 1)
+	expectBlocked(t, blocked)
+	// another user should not be blocked by user 1's waiter
+	if _, err := p.Acquire(context.Background(), 2); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if p.UserActive(1) != 1 {
+		t.Fatalf("expected user 1 to hold 1 slot, got %d", p.UserActive(1))
+	}
+	r1()
+	expectAcquired(t, blocked)
+}
+
+func TestCancelWhileWaiting(t *testing.T) {
+	p := slotpool.NewPool(1, 0)
+	r1, _ := p.Acquire(context.Background(), 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := acquireAsync(p, ctx, 2)
+	expectBlocked(t, ch)
+	cancel()
+	select {
+	case err := <-ch:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected context.Canceled, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("acquire did not return after cancel")
+	}
+	if p.Waiting() != 0 {
+		t.Fatalf("expected no waiters after cancel, got %d", p.Waiting())
+	}
+	r1()
+	if p.Active() != 0 {
+		t.Fatalf("expected no active slots, got %d", p.Active())
+	}
+}
+
+func TestFIFOOrder(t *testing.T) {
+	p := slotpool.NewPool(1, 0)
+	r1, _ := p.Acquire(context.Background(), 1)
+	first := acquireAsync(p, context.Background(), 2)
+	expectBlocked(t, first)
+	second := acquireAsync(p, context.Background(), 3)
+	expectBlocked(t, second)
+	r1()
+	expectAcquired(t, first)
+	expectBlocked(t, second)
+}
@@ -0,0 +1,32 @@
+package stickerset
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// 使用 ffmpeg 将贴纸转换为预览. 视频贴纸转换为循环播放的 GIF, 其他图片转换为 PNG
+func Convert(ctx context.Context, ffmpeg string, kind Kind, src, dst string) error {
+	args := []string{"-v", "error", "-y"}
+	if kind == KindVideo {
+		// 使用 libvpx 解码以保留透明通道
+		args = append(args, "-c:v", "libvpx-vp9", "-i", src,
+			"-vf", "split[a][b];[a]palettegen=reserve_transparent=1[p];[b][p]paletteuse",
+			"-loop", "0", dst)
+	} else {
+		args = append(args, "-i", src, "-frames:v", "1", dst)
+	}
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, ffmpeg, args...)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("%w: %s", err, msg)
+		}
+		return err
+	}
+	return nil
+}
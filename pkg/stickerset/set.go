@@ -0,0 +1,239 @@
+package stickerset
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/gotd/td/tg"
+)
+
+// 贴纸文件的格式
+type Kind string
+
+const (
+	KindStatic   Kind = "static"   // webp 图片
+	KindAnimated Kind = "animated" // tgs (Lottie) 动画
+	KindVideo    Kind = "video"    // webm 视频
+)
+
+func kindOf(mimeType string) Kind {
+	switch mimeType {
+	case "application/x-tgsticker":
+		return KindAnimated
+	case "video/webm":
+		return KindVideo
+	}
+	return KindStatic
+}
+
+func (k Kind) Ext() string {
+	switch k {
+	case KindAnimated:
+		return ".tgs"
+	case KindVideo:
+		return ".webm"
+	}
+	return ".webp"
+}
+
+// 预览的扩展名, 视频贴纸转换为 GIF, 其他为 PNG
+func (k Kind) PreviewExt() string {
+	if k == KindVideo {
+		return ".gif"
+	}
+	return ".png"
+}
+
+type Sticker struct {
+	Index    int // 在贴纸包中的顺序, 从 1 开始
+	Emoji    string
+	Keywords []string
+	Kind     Kind
+	Width    int
+	Height   int
+	Document *tg.Document
+}
+
+// 贴纸文件名, 按顺序编号
+func (s Sticker) FileName() string {
+	return fmt.Sprintf("%03d%s", s.Index, s.Kind.Ext())
+}
+
+func (s Sticker) PreviewName() string {
+	return fmt.Sprintf("%03d%s", s.Index, s.Kind.PreviewExt())
+}
+
+// 生成预览使用的缩略图尺寸. 动画贴纸无法直接转换, 使用其中最大的缩略图, 没有时为空
+func (s Sticker) ThumbSize() string {
+	if s.Kind != KindAnimated {
+		return ""
+	}
+	thumb, area := "", 0
+	for _, t := range s.Document.Thumbs {
+		if size, ok := t.(*tg.PhotoSize); ok && size.W*size.H > area {
+			thumb, area = size.Type, size.W*size.H
+		}
+	}
+	return thumb
+}
+
+type Set struct {
+	ID        int64
+	ShortName string
+	Title     string
+	Emoji     bool // 自定义 emoji 包
+	Stickers  []Sticker
+}
+
+// 贴纸包的分享链接
+func (s *Set) Link() string {
+	if s.Emoji {
+		return "https://t.me/addemoji/" + s.ShortName
+	}
+	return "https://t.me/addstickers/" + s.ShortName
+}
+
+func FromResult(res tg.MessagesStickerSetClass) (*Set, error) {
+	full, ok := res.(*tg.MessagesStickerSet)
+	if !ok {
+		return nil, fmt.Errorf("unexpected sticker set type: %T", res)
+	}
+	emojis := make(map[int64]string)
+	for _, pack := range full.Packs {
+		for _, id := range pack.Documents {
+			// 一个贴纸可能对应多个 emoji, 以第一个为准
+			if _, ok := emojis[id]; !ok {
+				emojis[id] = pack.Emoticon
+			}
+		}
+	}
+	keywords := make(map[int64][]string, len(full.Keywords))
+	for _, k := range full.Keywords {
+		keywords[k.DocumentID] = k.Keyword
+	}
+	set := &Set{
+		ID:        full.Set.ID,
+		ShortName: full.Set.ShortName,
+		Title:     full.Set.Title,
+		Emoji:     full.Set.Emojis,
+		Stickers:  make([]Sticker, 0, len(full.Documents)),
+	}
+	for _, d := range full.Documents {
+		doc, ok := d.AsNotEmpty()
+		if !ok {
+			continue
+		}
+		sticker := Sticker{
+			Index:    len(set.Stickers) + 1,
+			Emoji:    emojis[doc.ID],
+			Keywords: keywords[doc.ID],
+			Kind:     kindOf(doc.MimeType),
+			Document: doc,
+		}
+		for _, attr := range doc.Attributes {
+			switch a := attr.(type) {
+			case *tg.DocumentAttributeImageSize:
+				sticker.Width, sticker.Height = a.W, a.H
+			case *tg.DocumentAttributeVideo:
+				sticker.Width, sticker.Height = a.W, a.H
+			case *tg.DocumentAttributeSticker:
+				if sticker.Emoji == "" {
+					sticker.Emoji = a.Alt
+				}
+			case *tg.DocumentAttributeCustomEmoji:
+				if sticker.Emoji == "" {
+					sticker.Emoji = a.Alt
+				}
+			}
+		}
+		set.Stickers = append(set.Stickers, sticker)
+	}
+	if len(set.Stickers) == 0 {
+		return nil, errors.New("sticker set is empty")
+	}
+	return set, nil
+}
+
+// 获取贴纸包的内容
+func Fetch(ctx context.Context, client *tg.Client, input tg.InputStickerSetClass) (*Set, error) {
+	res, err := client.MessagesGetStickerSet(ctx, &tg.MessagesGetStickerSetRequest{Stickerset: input})
+	if err != nil {
+		return nil, err
+	}
+	return FromResult(res)
+}
+
+// 按短名称指定贴纸包
+func ByShortName(name string) tg.InputStickerSetClass {
+	return &tg.InputStickerSetShortName{ShortName: name}
+}
+
+// 贴纸或自定义 emoji 所属的贴纸包, 不属于任何贴纸包时返回 false
+func FromDocument(doc *tg.Document) (tg.InputStickerSetClass, bool) {
+	for _, attr := range doc.Attributes {
+		var input tg.InputStickerSetClass
+		switch a := attr.(type) {
+		case *tg.DocumentAttributeSticker:
+			input = a.Stickerset
+		case *tg.DocumentAttributeCustomEmoji:
+			input = a.Stickerset
+		default:
+			continue
+		}
+		if _, empty := input.(*tg.InputStickerSetEmpty); input == nil || empty {
+			return nil, false
+		}
+		return input, true
+	}
+	return nil, false
+}
+
+type manifest struct {
+	ShortName string            `json:"short_name"`
+	Title     string            `json:"title"`
+	Type      string            `json:"type"` // stickers 或 emoji
+	Link      string            `json:"link"`
+	Count     int               `json:"count"`
+	Stickers  []manifestSticker `json:"stickers"`
+}
+
+type manifestSticker struct {
+	File       string   `json:"file"`
+	Preview    string   `json:"preview,omitempty"`
+	Emoji      string   `json:"emoji"`
+	Keywords   []string `json:"keywords,omitempty"`
+	Type       Kind     `json:"type"`
+	Width      int      `json:"width,omitempty"`
+	Height     int      `json:"height,omitempty"`
+	DocumentID int64    `json:"document_id"`
+}
+
+// 生成描述贴纸包和每个贴纸对应 emoji 的 JSON. previews 为已生成预览的贴纸序号到预览文件的路径
+func (s *Set) Manifest(previews map[int]string) ([]byte, error) {
+	m := manifest{
+		ShortName: s.ShortName,
+		Title:     s.Title,
+		Type:      "stickers",
+		Link:      s.Link(),
+		Count:     len(s.Stickers),
+		Stickers:  make([]manifestSticker, 0, len(s.Stickers)),
+	}
+	if s.Emoji {
+		m.Type = "emoji"
+	}
+	for _, sticker := range s.Stickers {
+		m.Stickers = append(m.Stickers, manifestSticker{
+			File:       sticker.FileName(),
+			Preview:    previews[sticker.Index],
+			Emoji:      sticker.Emoji,
+			Keywords:   sticker.Keywords,
+			Type:       sticker.Kind,
+			Width:      sticker.Width,
+			Height:     sticker.Height,
+			DocumentID: sticker.Document.ID,
+		})
+	}
+	return json.MarshalIndent(m, "", "  ")
+}
@@ -0,0 +1,85 @@
+package stickerset
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/gotd/td/tg"
+)
+
+func TestFromResult(t *testing.T) {
+	res := &tg.MessagesStickerSet{
+		Set: tg.StickerSet{ID: 1, ShortName: "cats", Title: "Cats"},
+		Packs: []tg.StickerPack{
+			{Emoticon: "😺", Documents: []int64{10, 30}},
+			{Emoticon: "😿", Documents: []int64{10}},
+		},
+		Keywords: []tg.StickerKeyword{{DocumentID: 20, Keyword: []string{"sleep"}}},
+		Documents: []tg.DocumentClass{
+			&tg.Document{ID: 10, MimeType: "image/webp", Attributes: []tg.DocumentAttributeClass{
+				&tg.DocumentAttributeImageSize{W: 512, H: 512},
+			}},
+			&tg.DocumentEmpty{ID: 99},
+			&tg.Document{ID: 20, MimeType: "application/x-tgsticker", Attributes: []tg.DocumentAttributeClass{
+				&tg.DocumentAttributeSticker{Alt: "😴"},
+			}, Thumbs: []tg.PhotoSizeClass{
+				&tg.PhotoSize{Type: "s", W: 100, H: 100},
+				&tg.PhotoSize{Type: "m", W: 320, H: 320},
+			}},
+			&tg.Document{ID: 30, MimeType: "video/webm", Attributes: []tg.DocumentAttributeClass{
+				&tg.DocumentAttributeVideo{W: 512, H: 384},
+			}},
+		},
+	}
+	set, err := FromResult(res)
+	if err != nil {
+		t.Fatalf("FromResult() error = %v", err)
+	}
+	if len(set.Stickers) != 3 {
+		t.Fatalf("got %d stickers, want 3", len(set.Stickers))
+	}
+	tests := []struct {
+		file, preview, emoji, thumb string
+	}{
+		{"001.webp", "001.png", "😺", ""},
+		{"002.tgs", "002.png", "😴", "m"},
+		{"003.webm", "003.gif", "😺", ""},
+	}
+	for i, tt := range tests {
+		s := set.Stickers[i]
+		if s.FileName() != tt.file || s.PreviewName() != tt.preview || s.Emoji != tt.emoji || s.ThumbSize() != tt.thumb {
+			t.Errorf("sticker %d = %s %s %s %q, want %s %s %s %q", i, s.FileName(), s.PreviewName(), s.Emoji, s.ThumbSize(),
+				tt.file, tt.preview, tt.emoji, tt.thumb)
+		}
+	}
+	if set.Link() != "https://t.me/addstickers/cats" {
+		t.Errorf("Link() = %s", set.Link())
+	}
+
+	data, err := set.Manifest(map[int]string{1: "previews/001.png"})
+	if err != nil {
+		t.Fatalf("Manifest() error = %v", err)
+	}
+	var m manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		t.Fatalf("invalid manifest: %v", err)
+	}
+	if m.Type != "stickers" || m.Count != 3 || m.Stickers[0].Preview != "previews/001.png" || m.Stickers[1].Preview != "" ||
+		m.Stickers[1].Keywords[0] != "sleep" || m.Stickers[2].Height != 384 {
+		t.Errorf("unexpected manifest: %s", data)
+	}
+}
+
+func TestFromDocument(t *testing.T) {
+	doc := &tg.Document{Attributes: []tg.DocumentAttributeClass{
+		&tg.DocumentAttributeFilename{FileName: "sticker.webp"},
+		&tg.DocumentAttributeSticker{Stickerset: &tg.InputStickerSetID{ID: 1, AccessHash: 2}},
+	}}
+	if input, ok := FromDocument(doc); !ok || input.(*tg.InputStickerSetID).ID != 1 {
+		t.Errorf("FromDocument() = %v, %v", input, ok)
+	}
+	doc.Attributes[1] = &tg.DocumentAttributeSticker{Stickerset: &tg.InputStickerSetEmpty{}}
+	if _, ok := FromDocument(doc); ok {
+		t.Error("sticker without set should not have a set")
+	}
+}
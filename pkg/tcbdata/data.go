@@ -1,9 +1,15 @@
 package tcbdata
 
 import (
+	"time"
+
+	"github.com/gotd/td/tg"
 	"github.com/krau/SaveAny-Bot/pkg/enums/tasktype"
+	"github.com/krau/SaveAny-Bot/pkg/mdnote"
+	"github.com/krau/SaveAny-Bot/pkg/stickerset"
 	"github.com/krau/SaveAny-Bot/pkg/telegraph"
 	"github.com/krau/SaveAny-Bot/pkg/tfile"
+	"github.com/krau/SaveAny-Bot/pkg/torrentdl"
 )
 
 const (
@@ -11,6 +17,12 @@ const (
 	TypeSetDefault           = "setdefault"
 	TypeDeleteStorageConfirm = "delete_storage_confirm"
 	TypeStorageToggle        = "storage_toggle"
+	TypeYtdlpFormat          = "ytdlp_format"
+	TypeTorrentSelect        = "torrent_select"
+	TypeNoteThread           = "note_thread"
+	TypeComments             = "comments"
+	TypeTopic                = "topic"
+	TypeJoinChat             = "join_chat"
 )
 
 // type TaskDataTGFiles struct {
@@ -39,6 +51,122 @@ type Add struct {
 	TphPageNode *telegraph.Page
 	TphPics     []string
 	TphDirPath  string // unescaped telegraph.Page.Path
+	// 消息范围, 选择存储后再分页扫描, 不在回调数据中保存所有文件
+	SaveRange *SaveRange
+	// 频道消息的评论, 选择存储后再获取
+	Comments *Comments
+	// 论坛话题中的所有消息, 选择存储后再获取
+	Topic *Topic
+	// httpfile
+	HTTPFile *HTTPFile
+	// ytdlp
+	Ytdlp *YtdlpVideo
+	// torrent
+	Torrent *Torrent
+	// instantview
+	InstantView *InstantView
+	// note
+	Note *Note
+	// archive
+	Archive *Archive
+	// sticker
+	StickerSet *StickerSet
+}
+
+// 直链下载的文件, 在发送链接时获取
+type HTTPFile struct {
+	URL          string
+	Headers      map[string]string
+	FileName     string
+	Size         int64 // 未知时为 -1
+	AcceptRanges bool
+}
+
+// /save 命令指定的消息范围
+type SaveRange struct {
+	ChatID  int64
+	StartID int
+	EndID   int
+	Filter  string // 匹配消息文本和文件名的正则表达式, 为空时不过滤
+}
+
+// 频道消息的评论, 评论中的文件按评论者分目录保存
+type Comments struct {
+	ChannelID int64
+	PostID    int
+	Count     int  // 评论数量, 仅用于显示
+	Userbot   bool // 需要使用 userbot 获取评论
+}
+
+// 论坛中的话题, 话题中的文件保存为一个批量任务
+type Topic struct {
+	ChatID  int64
+	TopicID int    // 话题首条消息的 ID
+	Title   string // 话题标题, 同时用于渲染目录模板
+	Filter  string // 匹配消息文本和文件名的正则表达式, 为空时不过滤
+	Userbot bool   // 需要使用 userbot 获取话题消息
+}
+
+// 交给 yt-dlp 下载的视频和选择的格式
+type YtdlpVideo struct {
+	URL    string
+	Title  string
+	Ext    string
+	Format string // yt-dlp 格式选择器
+	Size   int64  // 预计大小, 未知时为 0
+}
+
+// 种子和选择下载的文件
+type Torrent struct {
+	Name     string
+	Metainfo []byte // .torrent 文件内容
+	Indexes  []int
+}
+
+// 带有 Instant View 页面内容的网页
+type InstantView struct {
+	WebPage *tg.WebPage
+	Userbot bool // 页面由 userbot 获取, 其中的文件也需要使用 userbot 下载
+}
+
+// 保存为 Markdown 笔记的文本消息
+type Note struct {
+	Content *mdnote.Note
+	// 保存整个对话时从来源聊天重新获取消息, ChatID 为 0 时不能保存对话
+	ChatID    int64
+	MessageID int
+	HasThread bool // 消息有回复链或评论
+	Userbot   bool // 来源聊天需要使用 userbot 访问
+}
+
+// /archive 命令指定的聊天和日期范围
+type Archive struct {
+	ChatID  int64
+	Title   string
+	Userbot bool      // 使用 userbot 获取历史, 否则由 Bot 按 ID 扫描
+	From    time.Time // 零值表示不限
+	To      time.Time // 不包含, 零值表示不限
+}
+
+// 贴纸或链接所属的贴纸包
+type StickerSet struct {
+	Set *stickerset.Set
+}
+
+// 选择种子中要下载的文件时的状态
+type TorrentSelect struct {
+	Name     string
+	Metainfo []byte
+	Files    []torrentdl.File
+	Selected []bool
+	Page     int
+}
+
+// 确认后由 userbot 通过邀请链接加入的聊天
+type JoinChat struct {
+	Hash  string
+	Title string
+	Links []string // 与邀请链接一起发送的消息链接, 加入后获取其中的文件
 }
 
 type SetDefaultStorage struct {
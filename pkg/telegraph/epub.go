@@ -0,0 +1,127 @@
+package telegraph
+
+import (
+	"archive/zip"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+const epubContainer = `<?xml version="1.0" encoding="UTF-8"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+<rootfiles><rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/></rootfiles>
+</container>
+`
+
+// 将文章写入 EPUB 文件. images 为图片 src 到文件名的映射, open 用于读取这些文件的内容.
+// 未包含在 images 中的媒体使用原始地址
+func WriteEPUB(w io.Writer, page *Page, images map[string]string, open func(name string) (io.ReadCloser, error)) error {
+	zw := zip.NewWriter(w)
+	// mimetype 必须是第一个文件且不压缩
+	mw, err := zw.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store})
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(mw, "application/epub+zip"); err != nil {
+		return err
+	}
+	if err := writeZipFile(zw, "META-INF/container.xml", epubContainer); err != nil {
+		return err
+	}
+
+	local := make(map[string]string, len(images))
+	names := make([]string, 0, len(images))
+	for src, name := range images {
+		local[src] = "images/" + name
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var manifest strings.Builder
+	for i, name := range names {
+		mediaType := mime.TypeByExtension(path.Ext(name))
+		if mediaType == "" {
+			mediaType = "image/jpeg"
+		}
+		fmt.Fprintf(&manifest, `<item id="img%d" href="images/%s" media-type="%s"/>`+"\n", i, html.EscapeString(name), mediaType)
+		iw, err := zw.Create("OEBPS/images/" + name)
+		if err != nil {
+			return err
+		}
+		r, err := open(name)
+		if err != nil {
+			return fmt.Errorf("failed to open image %s: %w", name, err)
+		}
+		_, err = io.Copy(iw, r)
+		r.Close()
+		if err != nil {
+			return fmt.Errorf("failed to write image %s: %w", name, err)
+		}
+	}
+
+	title := html.EscapeString(page.Title)
+	var body strings.Builder
+	renderBody(&body, page.Content, local)
+	var byline strings.Builder
+	renderByline(&byline, page)
+	article := `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml">
+<head><meta charset="utf-8"/><title>` + title + `</title></head>
+<body>
+<h1>` + title + `</h1>
+` + byline.String() + `
+` + body.String() + `
+</body>
+</html>
+`
+	if err := writeZipFile(zw, "OEBPS/article.xhtml", article); err != nil {
+		return err
+	}
+	nav := `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops">
+<head><meta charset="utf-8"/><title>` + title + `</title></head>
+<body><nav epub:type="toc"><ol><li><a href="article.xhtml">` + title + `</a></li></ol></nav></body>
+</html>
+`
+	if err := writeZipFile(zw, "OEBPS/nav.xhtml", nav); err != nil {
+		return err
+	}
+	author := ""
+	if page.AuthorName != "" {
+		author = "<dc:creator>" + html.EscapeString(page.AuthorName) + "</dc:creator>\n"
+	}
+	opf := `<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="id">
+<metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+<dc:identifier id="id">` + html.EscapeString(page.Url) + `</dc:identifier>
+<dc:title>` + title + `</dc:title>
+<dc:language>und</dc:language>
+` + author + `<meta property="dcterms:modified">` + time.Now().UTC().Format("2006-01-02T15:04:05Z") + `</meta>
+</metadata>
+<manifest>
+<item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>
+<item id="article" href="article.xhtml" media-type="application/xhtml+xml"/>
+` + manifest.String() + `</manifest>
+<spine><itemref idref="article"/></spine>
+</package>
+`
+	if err := writeZipFile(zw, "OEBPS/content.opf", opf); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+func writeZipFile(zw *zip.Writer, name, content string) error {
+	fw, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(fw, content)
+	return err
+}
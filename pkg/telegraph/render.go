@@ -0,0 +1,354 @@
+package telegraph
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/url"
+	"strings"
+)
+
+const BaseUrl = "https://telegra.ph"
+
+// 文章中引用的媒体类型
+type MediaKind string
+
+const (
+	MediaImage MediaKind = "image"
+	MediaVideo MediaKind = "video"
+	MediaEmbed MediaKind = "embed" // iframe 嵌入的外部内容, 例如 YouTube 视频
+)
+
+// 文章中引用的媒体
+type Media struct {
+	Kind MediaKind
+	Src  string // 节点中的原始 src 属性
+	URL  string // 下载地址; 对于嵌入内容为原始链接
+}
+
+// 将 Telegraph 中的相对地址转换为完整地址
+func ResolveURL(src string) string {
+	switch {
+	case strings.HasPrefix(src, "//"):
+		return "https:" + src
+	case strings.HasPrefix(src, "/"):
+		return BaseUrl + src
+	}
+	return src
+}
+
+// iframe 嵌入内容的原始链接, 例如 /embed/youtube?url=... 中的 url 参数
+func EmbedURL(src string) string {
+	u, err := url.Parse(ResolveURL(src))
+	if err != nil {
+		return ResolveURL(src)
+	}
+	if strings.HasPrefix(u.Path, "/embed/") {
+		if raw := u.Query().Get("url"); raw != "" {
+			return raw
+		}
+	}
+	return u.String()
+}
+
+// 将节点转换为元素, 文本节点返回 nil 和文本内容
+func toElement(n Node) (*NodeElement, string) {
+	switch v := n.(type) {
+	case string:
+		return nil, v
+	case NodeElement:
+		return &v, ""
+	case *NodeElement:
+		return v, ""
+	}
+	data, err := json.Marshal(n)
+	if err != nil {
+		return nil, ""
+	}
+	var elem NodeElement
+	if err := json.Unmarshal(data, &elem); err != nil || elem.Tag == "" {
+		return nil, ""
+	}
+	return &elem, ""
+}
+
+// 按出现顺序收集文章中的图片, 视频和嵌入内容, 相同地址只保留一个
+func CollectMedia(content []Node) []Media {
+	var medias []Media
+	seen := make(map[string]bool)
+	var walk func(nodes []Node)
+	walk = func(nodes []Node) {
+		for _, n := range nodes {
+			elem, _ := toElement(n)
+			if elem == nil {
+				continue
+			}
+			src := elem.Attrs["src"]
+			if src != "" && !seen[src] {
+				switch elem.Tag {
+				case "img":
+					seen[src] = true
+					medias = append(medias, Media{Kind: MediaImage, Src: src, URL: ResolveURL(src)})
+				case "video":
+					seen[src] = true
+					medias = append(medias, Media{Kind: MediaVideo, Src: src, URL: ResolveURL(src)})
+				case "iframe":
+					seen[src] = true
+					medias = append(medias, Media{Kind: MediaEmbed, Src: src, URL: EmbedURL(src)})
+				}
+			}
+			walk(elem.Children)
+		}
+	}
+	walk(content)
+	return medias
+}
+
+// 不含子节点的元素
+var voidTags = map[string]bool{"br": true, "hr": true, "img": true}
+
+// 渲染正文. local 为媒体 src 到本地相对路径的映射, 未包含的媒体使用原始地址.
+// 输出同时是合法的 HTML 和 XHTML
+func renderBody(b *strings.Builder, nodes []Node, local map[string]string) {
+	for _, n := range nodes {
+		elem, text := toElement(n)
+		if elem == nil {
+			b.WriteString(html.EscapeString(text))
+			continue
+		}
+		src := elem.Attrs["src"]
+		localSrc, isLocal := local[src]
+		switch elem.Tag {
+		case "img":
+			if !isLocal {
+				localSrc = ResolveURL(src)
+			}
+			fmt.Fprintf(b, `<img src="%s" alt="" />`, html.EscapeString(localSrc))
+		case "video":
+			if !isLocal {
+				localSrc = ResolveURL(src)
+			}
+			fmt.Fprintf(b, `<video controls="controls" src="%s"></video>`, html.EscapeString(localSrc))
+		case "iframe":
+			link := EmbedURL(src)
+			if isLocal {
+				fmt.Fprintf(b, `<video controls="controls" src="%s"></video>`, html.EscapeString(localSrc))
+			}
+			fmt.Fprintf(b, `<p><a href="%s">%s</a></p>`, html.EscapeString(link), html.EscapeString(link))
+		case "a":
+			fmt.Fprintf(b, `<a href="%s">`, html.EscapeString(ResolveURL(elem.Attrs["href"])))
+			renderBody(b, elem.Children, local)
+			b.WriteString("</a>")
+		default:
+			tag := elem.Tag
+			if !isAllowedTag(tag) {
+				tag = "span"
+			}
+			if voidTags[tag] {
+				fmt.Fprintf(b, "<%s />", tag)
+				continue
+			}
+			fmt.Fprintf(b, "<%s>", tag)
+			renderBody(b, elem.Children, local)
+			fmt.Fprintf(b, "</%s>", tag)
+		}
+	}
+}
+
+func isAllowedTag(tag string) bool {
+	switch tag {
+	case "aside", "b", "blockquote", "br", "code", "em", "figcaption", "figure",
+		"h3", "h4", "hr", "i", "li", "ol", "p", "pre", "s", "strong", "u", "ul":
+		return true
+	}
+	return false
+}
+
+// 作者和原文链接
+func renderByline(b *strings.Builder, page *Page) {
+	b.WriteString(`<p class="byline">`)
+	if page.AuthorName != "" {
+		if page.AuthorUrl != "" {
+			fmt.Fprintf(b, `<a href="%s">%s</a> · `, html.EscapeString(page.AuthorUrl), html.EscapeString(page.AuthorName))
+		} else {
+			fmt.Fprintf(b, "%s · ", html.EscapeString(page.AuthorName))
+		}
+	}
+	fmt.Fprintf(b, `<a href="%s">%s</a></p>`, html.EscapeString(page.Url), html.EscapeString(page.Url))
+}
+
+const articleStyle = `body{max-width:732px;margin:0 auto;padding:21px;font-family:Georgia,serif;font-size:18px;line-height:1.6}` +
+	`img,video{max-width:100%;display:block;margin:1em auto}figcaption{text-align:center;color:#79828b;font-size:15px}` +
+	`blockquote,aside{border-left:3px solid #000;margin:0;padding-left:1em;font-style:italic}` +
+	`pre{white-space:pre-wrap;background:#f5f5f5;padding:1em}.byline{color:#79828b;font-size:15px}`
+
+// 将文章渲染为独立的 HTML 文件
+func RenderHTML(page *Page, local map[string]string) []byte {
+	var b strings.Builder
+	b.WriteString("<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\" />\n")
+	b.WriteString(`<meta name="viewport" content="width=device-width, initial-scale=1" />` + "\n")
+	fmt.Fprintf(&b, "<title>%s</title>\n<style>%s</style>\n</head>\n<body>\n", html.EscapeString(page.Title), articleStyle)
+	fmt.Fprintf(&b, "<h1>%s</h1>\n", html.EscapeString(page.Title))
+	renderByline(&b, page)
+	b.WriteString("\n<article>\n")
+	renderBody(&b, page.Content, local)
+	b.WriteString("\n</article>\n</body>\n</html>\n")
+	return []byte(b.String())
+}
+
+// 将文章渲染为 Markdown
+func RenderMarkdown(page *Page, local map[string]string) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n", page.Title)
+	if page.AuthorName != "" {
+		if page.AuthorUrl != "" {
+			fmt.Fprintf(&b, "[%s](%s) · ", escapeMarkdown(page.AuthorName), page.AuthorUrl)
+		} else {
+			fmt.Fprintf(&b, "%s · ", escapeMarkdown(page.AuthorName))
+		}
+	}
+	fmt.Fprintf(&b, "<%s>\n\n", page.Url)
+	md := &markdownRenderer{local: local}
+	for _, n := range page.Content {
+		md.block(&b, n, "")
+	}
+	return []byte(strings.TrimRight(b.String(), "\n") + "\n")
+}
+
+var markdownEscaper = strings.NewReplacer(`\`, `\\`, "*", `\*`, "_", `\_`, "`", "\\`", "[", `\[`, "]", `\]`)
+
+func escapeMarkdown(s string) string {
+	return markdownEscaper.Replace(s)
+}
+
+type markdownRenderer struct {
+	local map[string]string
+}
+
+func (m *markdownRenderer) mediaSrc(src string) string {
+	if p, ok := m.local[src]; ok {
+		return p
+	}
+	return ResolveURL(src)
+}
+
+// 渲染块级节点, prefix 为每行的前缀, 用于引用
+func (m *markdownRenderer) block(b *strings.Builder, n Node, prefix string) {
+	elem, text := toElement(n)
+	if elem == nil {
+		if text = strings.TrimSpace(text); text != "" {
+			writeLines(b, prefix, escapeMarkdown(text))
+		}
+		return
+	}
+	switch elem.Tag {
+	case "h3":
+		writeLines(b, prefix, "### "+m.inline(elem.Children))
+	case "h4":
+		writeLines(b, prefix, "#### "+m.inline(elem.Children))
+	case "hr":
+		writeLines(b, prefix, "---")
+	case "pre":
+		writeLines(b, prefix, "```\n"+strings.TrimRight(plainText(elem.Children), "\n")+"\n```")
+	case "blockquote", "aside":
+		for _, c := range elem.Children {
+			m.block(b, c, prefix+"> ")
+		}
+	case "ul", "ol":
+		var lines []string
+		i := 0
+		for _, c := range elem.Children {
+			li, _ := toElement(c)
+			if li == nil {
+				continue
+			}
+			i++
+			marker := "- "
+			if elem.Tag == "ol" {
+				marker = fmt.Sprintf("%d. ", i)
+			}
+			lines = append(lines, marker+m.inline(li.Children))
+		}
+		writeLines(b, prefix, strings.Join(lines, "\n"))
+	case "figure":
+		var parts []string
+		for _, c := range elem.Children {
+			child, _ := toElement(c)
+			if child != nil && child.Tag == "figcaption" {
+				if caption := m.inline(child.Children); caption != "" {
+					parts = append(parts, "*"+caption+"*")
+				}
+				continue
+			}
+			parts = append(parts, m.inline([]Node{c}))
+		}
+		writeLines(b, prefix, strings.Join(parts, "\n\n"))
+	default:
+		if s := m.inline([]Node{n}); strings.TrimSpace(s) != "" {
+			writeLines(b, prefix, s)
+		}
+	}
+}
+
+func (m *markdownRenderer) inline(nodes []Node) string {
+	var b strings.Builder
+	for _, n := range nodes {
+		elem, text := toElement(n)
+		if elem == nil {
+			b.WriteString(escapeMarkdown(text))
+			continue
+		}
+		inner := func() string { return m.inline(elem.Children) }
+		switch elem.Tag {
+		case "b", "strong":
+			fmt.Fprintf(&b, "**%s**", inner())
+		case "i", "em":
+			fmt.Fprintf(&b, "*%s*", inner())
+		case "s":
+			fmt.Fprintf(&b, "~~%s~~", inner())
+		case "code":
+			fmt.Fprintf(&b, "`%s`", plainText(elem.Children))
+		case "br":
+			b.WriteString("\\\n")
+		case "a":
+			fmt.Fprintf(&b, "[%s](%s)", inner(), ResolveURL(elem.Attrs["href"]))
+		case "img":
+			fmt.Fprintf(&b, "![](%s)", m.mediaSrc(elem.Attrs["src"]))
+		case "video":
+			fmt.Fprintf(&b, "[视频](%s)", m.mediaSrc(elem.Attrs["src"]))
+		case "iframe":
+			src := elem.Attrs["src"]
+			if p, ok := m.local[src]; ok {
+				fmt.Fprintf(&b, "[视频](%s) ", p)
+			}
+			fmt.Fprintf(&b, "<%s>", EmbedURL(src))
+		default:
+			b.WriteString(inner())
+		}
+	}
+	return b.String()
+}
+
+func plainText(nodes []Node) string {
+	var b strings.Builder
+	for _, n := range nodes {
+		elem, text := toElement(n)
+		if elem == nil {
+			b.WriteString(text)
+			continue
+		}
+		if elem.Tag == "br" {
+			b.WriteString("\n")
+			continue
+		}
+		b.WriteString(plainText(elem.Children))
+	}
+	return b.String()
+}
+
+func writeLines(b *strings.Builder, prefix, s string) {
+	for _, line := range strings.Split(s, "\n") {
+		b.WriteString(strings.TrimRight(prefix+line, " ") + "\n")
+	}
+	b.WriteString(strings.TrimRight(prefix, " ") + "\n")
+}
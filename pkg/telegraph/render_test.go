@@ -0,0 +1,144 @@
+package telegraph
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+)
+
+// 与 getPage 返回的 JSON 结构相同
+const testPageJSON = `{
+	"path": "Test-01-01",
+	"url": "https://telegra.ph/Test-01-01",
+	"title": "Test <Page>",
+	"author_name": "Author",
+	"content": [
+		{"tag": "h3", "children": ["Heading"]},
+		{"tag": "p", "children": ["Hello ", {"tag": "b", "children": ["bold"]}, " and ", {"tag": "a", "attrs": {"href": "/Other-01-01"}, "children": ["link"]}, {"tag": "br"}, "next*line"]},
+		{"tag": "figure", "children": [{"tag": "img", "attrs": {"src": "/file/a.jpg"}}, {"tag": "figcaption", "children": ["Caption"]}]},
+		{"tag": "figure", "children": [{"tag": "video", "attrs": {"src": "/file/b.mp4"}}]},
+		{"tag": "figure", "children": [{"tag": "iframe", "attrs": {"src": "/embed/youtube?url=https%3A%2F%2Fwww.youtube.com%2Fwatch%3Fv%3Dabc"}}]},
+		{"tag": "blockquote", "children": ["Quote"]},
+		{"tag": "ul", "children": [{"tag": "li", "children": ["one"]}, {"tag": "li", "children": ["two"]}]},
+		{"tag": "img", "attrs": {"src": "/file/a.jpg"}},
+		{"tag": "img", "attrs": {"src": "https://example.com/c.png"}}
+	]
+}`
+
+func testPage(t *testing.T) *Page {
+	var page Page
+	if err := json.Unmarshal([]byte(testPageJSON), &page); err != nil {
+		t.Fatal(err)
+	}
+	return &page
+}
+
+func TestCollectMedia(t *testing.T) {
+	medias := CollectMedia(testPage(t).Content)
+	want := []Media{
+		{Kind: MediaImage, Src: "/file/a.jpg", URL: "https://telegra.ph/file/a.jpg"},
+		{Kind: MediaVideo, Src: "/file/b.mp4", URL: "https://telegra.ph/file/b.mp4"},
+		{Kind: MediaEmbed, Src: "/embed/youtube?url=https%3A%2F%2Fwww.youtube.com%2Fwatch%3Fv%3Dabc", URL: "https://www.youtube.com/watch?v=abc"},
+		{Kind: MediaImage, Src: "https://example.com/c.png", URL: "https://example.com/c.png"},
+	}
+	if len(medias) != len(want) {
+		t.Fatalf("got %d medias, want %d: %+v", len(medias), len(want), medias)
+	}
+	for i := range want {
+		if medias[i] != want[i] {
+			t.Errorf("media %d = %+v, want %+v", i, medias[i], want[i])
+		}
+	}
+}
+
+func TestRenderHTML(t *testing.T) {
+	out := string(RenderHTML(testPage(t), map[string]string{"/file/a.jpg": "1.jpg", "/file/b.mp4": "2.mp4"}))
+	for _, s := range []string{
+		"<title>Test &lt;Page&gt;</title>",
+		"<h3>Heading</h3>",
+		`<b>bold</b>`,
+		`<a href="https://telegra.ph/Other-01-01">link</a><br />next*line`,
+		`<img src="1.jpg" alt="" /><figcaption>Caption</figcaption>`,
+		`<video controls="controls" src="2.mp4"></video>`,
+		`<a href="https://www.youtube.com/watch?v=abc">`,
+		`<img src="https://example.com/c.png" alt="" />`,
+	} {
+		if !strings.Contains(out, s) {
+			t.Errorf("html missing %q:\n%s", s, out)
+		}
+	}
+}
+
+func TestRenderMarkdown(t *testing.T) {
+	out := string(RenderMarkdown(testPage(t), map[string]string{"/file/a.jpg": "1.jpg"}))
+	for _, s := range []string{
+		"# Test <Page>\n\nAuthor · <https://telegra.ph/Test-01-01>\n",
+		"### Heading\n",
+		"Hello **bold** and [link](https://telegra.ph/Other-01-01)\\\nnext\\*line\n",
+		"![](1.jpg)\n\n*Caption*\n",
+		"[视频](https://telegra.ph/file/b.mp4)\n",
+		"<https://www.youtube.com/watch?v=abc>\n",
+		"> Quote\n",
+		"- one\n- two\n",
+	} {
+		if !strings.Contains(out, s) {
+			t.Errorf("markdown missing %q:\n%s", s, out)
+		}
+	}
+}
+
+func TestWriteEPUB(t *testing.T) {
+	var buf bytes.Buffer
+	err := WriteEPUB(&buf, testPage(t), map[string]string{"/file/a.jpg": "1.jpg"}, func(name string) (io.ReadCloser, error) {
+		return io.NopCloser(strings.NewReader("image " + name)), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if zr.File[0].Name != "mimetype" || zr.File[0].Method != zip.Store {
+		t.Fatalf("first entry should be stored mimetype, got %s", zr.File[0].Name)
+	}
+	files := make(map[string]string)
+	for _, f := range zr.File {
+		r, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := io.ReadAll(r)
+		r.Close()
+		files[f.Name] = string(data)
+	}
+	if files["OEBPS/images/1.jpg"] != "image 1.jpg" {
+		t.Errorf("unexpected image content: %q", files["OEBPS/images/1.jpg"])
+	}
+	for _, name := range []string{"OEBPS/article.xhtml", "OEBPS/nav.xhtml", "OEBPS/content.opf", "META-INF/container.xml"} {
+		content, ok := files[name]
+		if !ok {
+			t.Fatalf("missing %s", name)
+		}
+		// 所有文档都必须是合法的 XML
+		dec := xml.NewDecoder(strings.NewReader(content))
+		dec.Strict = true
+		for {
+			if _, err := dec.Token(); err == io.EOF {
+				break
+			} else if err != nil {
+				t.Fatalf("%s is not valid xml: %v\n%s", name, err, content)
+			}
+		}
+	}
+	if !strings.Contains(files["OEBPS/article.xhtml"], `<img src="images/1.jpg" alt="" />`) {
+		t.Errorf("article should reference local image:\n%s", files["OEBPS/article.xhtml"])
+	}
+	if !strings.Contains(files["OEBPS/content.opf"], `href="images/1.jpg" media-type="image/jpeg"`) {
+		t.Errorf("manifest should list image:\n%s", files["OEBPS/content.opf"])
+	}
+}
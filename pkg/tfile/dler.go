@@ -1,13 +1,80 @@
 package tfile
 
 import (
+	"context"
+
+	"github.com/charmbracelet/log"
 	"github.com/gotd/td/telegram/downloader"
+	"github.com/gotd/td/tg"
 	"github.com/krau/SaveAny-Bot/common/utils/dlutil"
 	"github.com/krau/SaveAny-Bot/config"
 	"github.com/krau/SaveAny-Bot/pkg/consts/tglimit"
+	"github.com/krau/SaveAny-Bot/pkg/dlpool"
 )
 
 func NewDownloader(file TGFile) *downloader.Builder {
+	client, file := pickClient(file)
+	threads := dlutil.BestThreads(file.Size(), config.Cfg.Threads)
+	if config.Cfg.Transfer.AdaptiveThreads {
+		dc := 0
+		if f, ok := file.(interface{ DC() int }); ok {
+			dc = f.DC()
+		}
+		tuner := dlutil.NewThreadTuner(dc, config.Cfg.Transfer.MinThreads, config.Cfg.GetMaxThreads(), threads)
+		client = &tunedClient{Client: client, tuner: tuner}
+		// 以最大线程数启动下载协程, 实际同时进行的请求数由 tuner 控制
+		threads = tuner.Max()
+	}
+	if f, ok := file.(TGFileMessage); ok {
+		client = &refreshClient{Client: client, file: f}
+	}
 	return downloader.NewDownloader().WithPartSize(tglimit.MaxPartSize).
-		Download(file.Dler(), file.Location()).WithThreads(dlutil.BestThreads(file.Size(), config.Cfg.Threads))
+		Download(client, file.Location()).WithThreads(threads)
+}
+
+// 从下载账号池中选择客户端. 选中其他账号时该账号已重新获取来源消息, 返回使用其自己文件位置的文件.
+// 故事和不是来自消息的文件只能由 home 下载
+func pickClient(file TGFile) (downloader.Client, TGFile) {
+	f, ok := file.(*tgFile)
+	if !ok || f.story || f.Message() == nil {
+		return dlpool.PickDefault(file.Dler(), 0, nil), file
+	}
+	chatID, _, _ := MessageSource(f)
+	var picked *tgFile
+	client := dlpool.PickDefault(f.dler, chatID, func(a *dlpool.Account) bool {
+		getter := a.Getter()
+		if getter == nil {
+			return false
+		}
+		location, msg, err := refetch(getter, f.Message(), f.Location())
+		if err != nil {
+			log.Debugf("Account %s cannot access file %s: %s", a.Name(), f.name, err)
+			return false
+		}
+		picked = f.withAccount(a.Client(), getter, location, msg)
+		return true
+	})
+	if picked == nil {
+		return client, file
+	}
+	return client, picked
+}
+
+// 限制并统计 upload.getFile 请求, 供 ThreadTuner 调整并发数
+type tunedClient struct {
+	downloader.Client
+	tuner *dlutil.ThreadTuner
+}
+
+func (c *tunedClient) UploadGetFile(ctx context.Context, request *tg.UploadGetFileRequest) (tg.UploadFileClass, error) {
+	if err := c.tuner.Acquire(ctx); err != nil {
+		return nil, err
+	}
+	res, err := c.Client.UploadGetFile(ctx, request)
+	n := 0
+	if file, ok := res.(*tg.UploadFile); ok {
+		n = len(file.Bytes)
+	}
+	c.tuner.Release(n, err)
+	return res, err
 }
@@ -0,0 +1,54 @@
+package tfile
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/gotd/td/telegram/downloader"
+	"github.com/gotd/td/tg"
+	"github.com/gotd/td/tgerr"
+	"github.com/krau/SaveAny-Bot/config"
+	"github.com/krau/SaveAny-Bot/pkg/dlpool"
+)
+
+// 只接受自己的 file reference 的账号, 模拟 file reference 只对获取消息的账号有效
+type accountClient struct {
+	downloader.Client
+	reference string
+	calls     int
+}
+
+func (c *accountClient) UploadGetFile(ctx context.Context, request *tg.UploadGetFileRequest) (tg.UploadFileClass, error) {
+	c.calls++
+	location := request.Location.(*tg.InputDocumentFileLocation)
+	if string(location.FileReference) != c.reference {
+		return nil, tgerr.New(400, "FILE_REFERENCE_INVALID")
+	}
+	return &tg.UploadFile{Bytes: []byte(c.reference)}, nil
+}
+
+func TestPooledDownloadUsesAccountLocation(t *testing.T) {
+	config.Cfg.Telegram.DownloadPool.Enable = true
+	defer func() { config.Cfg.Telegram.DownloadPool.Enable = false }()
+	home, other := &accountClient{reference: "ho"}, &accountClient{reference: "ot"}
+	for _, c := range []*accountClient{home, other} {
+		a := dlpool.NewAccount(c.reference)
+		a.Bind(c, &fakeGetter{reference: []byte(c.reference)})
+		dlpool.Default().Register(a)
+	}
+	msg := newDocumentMessage([]byte("ho"))
+	file, err := FromMediaMessage(msg.Media, home, msg, WithMessageGetter(&fakeGetter{reference: []byte("ho")}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// 第一个下载占用 home, 第二个由空闲的账号使用自己重新获取的文件位置下载
+	NewDownloader(file)
+	var buf bytes.Buffer
+	if _, err := NewDownloader(file).Stream(context.Background(), &buf); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if buf.String() != "ot" || other.calls == 0 || home.calls != 0 {
+		t.Fatalf("expected the other account to serve the download, got %q (home %d, other %d calls)", buf.String(), home.calls, other.calls)
+	}
+}
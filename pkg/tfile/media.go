@@ -0,0 +1,233 @@
+package tfile
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/gabriel-vasile/mimetype"
+	"github.com/gotd/td/telegram/downloader"
+	"github.com/gotd/td/tg"
+)
+
+var ErrNotPurchased = errors.New("paid media has not been purchased")
+
+// 取出付费媒体, 发票, 游戏和网页预览中的文档或图片, 转换为 MessageMediaDocument 或 MessageMediaPhoto.
+// 付费媒体返回所有已购买的项, 其他类型只有一项. 不需要转换的类型原样返回
+func UnwrapMedia(media tg.MessageMediaClass) ([]tg.MessageMediaClass, error) {
+	if paid, ok := media.(*tg.MessageMediaPaidMedia); ok {
+		var medias []tg.MessageMediaClass
+		for _, em := range paid.ExtendedMedia {
+			purchased, ok := em.(*tg.MessageExtendedMedia)
+			if !ok {
+				continue
+			}
+			m, err := unwrapMedia(purchased.Media)
+			if err != nil {
+				return nil, err
+			}
+			medias = append(medias, m)
+		}
+		if len(medias) == 0 {
+			return nil, ErrNotPurchased
+		}
+		return medias, nil
+	}
+	m, err := unwrapMedia(media)
+	if err != nil {
+		return nil, err
+	}
+	return []tg.MessageMediaClass{m}, nil
+}
+
+func unwrapMedia(media tg.MessageMediaClass) (tg.MessageMediaClass, error) {
+	switch m := media.(type) {
+	case *tg.MessageMediaInvoice:
+		em, ok := m.GetExtendedMedia()
+		if !ok {
+			return nil, errors.New("invoice has no media")
+		}
+		purchased, ok := em.(*tg.MessageExtendedMedia)
+		if !ok {
+			return nil, ErrNotPurchased
+		}
+		return unwrapMedia(purchased.Media)
+	case *tg.MessageMediaGame:
+		if doc, ok := m.Game.GetDocument(); ok && !isEmptyDocument(doc) {
+			return &tg.MessageMediaDocument{Document: doc}, nil
+		}
+		return &tg.MessageMediaPhoto{Photo: m.Game.Photo}, nil
+	case *tg.MessageMediaWebPage:
+		wp, ok := m.Webpage.(*tg.WebPage)
+		if !ok {
+			return nil, errors.New("web page is not loaded")
+		}
+		if doc, ok := wp.GetDocument(); ok && !isEmptyDocument(doc) {
+			return &tg.MessageMediaDocument{Document: doc}, nil
+		}
+		if photo, ok := wp.GetPhoto(); ok {
+			return &tg.MessageMediaPhoto{Photo: photo}, nil
+		}
+		return nil, errors.New("web page has no document or photo")
+	}
+	return media, nil
+}
+
+func isEmptyDocument(doc tg.DocumentClass) bool {
+	_, ok := doc.AsNotEmpty()
+	return !ok
+}
+
+// 主文件: 文档本身, 没有时为视频的其他清晰度中最大的一个, 再没有时为视频封面
+func mainDocument(m *tg.MessageMediaDocument) (*tg.Document, *tg.Photo, error) {
+	if doc, ok := m.Document.AsNotEmpty(); ok {
+		return doc, nil, nil
+	}
+	var best *tg.Document
+	for _, alt := range m.AltDocuments {
+		if doc, ok := alt.AsNotEmpty(); ok && (best == nil || doc.Size > best.Size) {
+			best = doc
+		}
+	}
+	if best != nil {
+		return best, nil, nil
+	}
+	if cover, ok := m.GetVideoCover(); ok {
+		if photo, ok := cover.AsNotEmpty(); ok {
+			return nil, photo, nil
+		}
+	}
+	return nil, nil, errors.New("document is empty")
+}
+
+func documentName(doc *tg.Document) string {
+	for _, attribute := range doc.Attributes {
+		if name, ok := attribute.(*tg.DocumentAttributeFilename); ok {
+			return name.GetFileName()
+		}
+	}
+	return ""
+}
+
+func documentFile(doc *tg.Document, client downloader.Client, name string, opts ...TGFileOptions) TGFile {
+	return NewTGFile(
+		doc.AsInputDocumentFileLocation(),
+		client,
+		doc.Size,
+		name,
+		append([]TGFileOptions{WithDC(doc.DCID)}, opts...)...,
+	)
+}
+
+// 图片中实际最大的尺寸及其字节数. 渐进式 JPEG 的最后一个分段大小即完整图片的大小
+func largestPhotoSize(photo *tg.Photo) (string, int64, error) {
+	thumb, area, size := "", -1, int64(0)
+	for _, s := range photo.Sizes {
+		var (
+			typ     string
+			w, h, n int
+		)
+		switch ps := s.(type) {
+		case *tg.PhotoSize:
+			typ, w, h, n = ps.Type, ps.W, ps.H, ps.Size
+		case *tg.PhotoSizeProgressive:
+			if len(ps.Sizes) == 0 {
+				continue
+			}
+			typ, w, h = ps.Type, ps.W, ps.H
+			for _, part := range ps.Sizes {
+				n = max(n, part)
+			}
+		default:
+			// 内嵌在消息中的缩略图和轮廓无法下载
+			continue
+		}
+		if w*h > area {
+			thumb, area, size = typ, w*h, int64(n)
+		}
+	}
+	if thumb == "" {
+		return "", 0, errors.New("photo sizes are empty")
+	}
+	return thumb, size, nil
+}
+
+func photoFile(photo *tg.Photo, client downloader.Client, name string, opts ...TGFileOptions) (TGFile, error) {
+	thumb, size, err := largestPhotoSize(photo)
+	if err != nil {
+		return nil, err
+	}
+	location := &tg.InputPhotoFileLocation{
+		ID:            photo.GetID(),
+		AccessHash:    photo.GetAccessHash(),
+		FileReference: photo.GetFileReference(),
+		ThumbSize:     thumb,
+	}
+	return NewTGFile(location, client, size, name, append([]TGFileOptions{WithDC(photo.DCID)}, opts...)...), nil
+}
+
+// 视频的其他清晰度和封面, 文件名由 ID 和清晰度组成, 不受 opts 中的文件名影响.
+// 付费媒体包括每个已购买的视频, 其他媒体返回空
+func Alternatives(media tg.MessageMediaClass, client downloader.Client, opts ...TGFileOptions) []TGFile {
+	medias, err := UnwrapMedia(media)
+	if err != nil {
+		return nil
+	}
+	var files []TGFile
+	for _, media := range medias {
+		if m, ok := media.(*tg.MessageMediaDocument); ok {
+			files = append(files, alternatives(m, client, opts...)...)
+		}
+	}
+	return files
+}
+
+func alternatives(m *tg.MessageMediaDocument, client downloader.Client, opts ...TGFileOptions) []TGFile {
+	main, _, _ := mainDocument(m)
+	var files []TGFile
+	for _, alt := range m.AltDocuments {
+		doc, ok := alt.AsNotEmpty()
+		if !ok || main != nil && doc.ID == main.ID {
+			continue
+		}
+		height := 0
+		for _, attr := range doc.Attributes {
+			if v, ok := attr.(*tg.DocumentAttributeVideo); ok {
+				height = v.H
+			}
+		}
+		ext := ".mp4"
+		if mt := mimetype.Lookup(doc.MimeType); mt != nil && mt.Extension() != "" {
+			ext = mt.Extension()
+		}
+		name := fmt.Sprintf("%d_%dp%s", doc.ID, height, ext)
+		files = append(files, documentFile(doc, client, name, append(slices.Clone(opts), WithName(name))...))
+	}
+	if cover, ok := m.GetVideoCover(); ok && main != nil {
+		if photo, ok := cover.AsNotEmpty(); ok {
+			name := fmt.Sprintf("%d_cover.jpg", photo.ID)
+			if file, err := photoFile(photo, client, name, append(slices.Clone(opts), WithName(name))...); err == nil {
+				files = append(files, file)
+			}
+		}
+	}
+	return files
+}
+
+// 媒体中所有可下载文件的位置, 用于在刷新时找到同一个文件
+func mediaLocations(media tg.MessageMediaClass) []tg.InputFileLocationClass {
+	medias, err := UnwrapMedia(media)
+	if err != nil {
+		return nil
+	}
+	var locations []tg.InputFileLocationClass
+	for _, media := range medias {
+		if file, err := FromMedia(media, nil); err == nil {
+			locations = append(locations, file.Location())
+		}
+		for _, file := range Alternatives(media, nil) {
+			locations = append(locations, file.Location())
+		}
+	}
+	return locations
+}
@@ -0,0 +1,194 @@
+package tfile
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/gotd/td/tg"
+	"github.com/krau/SaveAny-Bot/config"
+)
+
+func TestFromMediaPhotoLargestSize(t *testing.T) {
+	photo := &tg.Photo{ID: 1, Sizes: []tg.PhotoSizeClass{
+		&tg.PhotoStrippedSize{Type: "i", Bytes: []byte{1}},
+		&tg.PhotoSize{Type: "x", W: 800, H: 600, Size: 5000},
+		&tg.PhotoSizeProgressive{Type: "y", W: 1280, H: 960, Sizes: []int{100, 4000, 9000}},
+		// 最后一项不一定最大
+		&tg.PhotoSize{Type: "m", W: 320, H: 240, Size: 1000},
+	}}
+	file, err := FromMedia(&tg.MessageMediaPhoto{Photo: photo}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if thumb := file.Location().(*tg.InputPhotoFileLocation).ThumbSize; thumb != "y" {
+		t.Errorf("ThumbSize = %s, want y", thumb)
+	}
+	if file.Size() != 9000 {
+		t.Errorf("Size() = %d, want 9000", file.Size())
+	}
+}
+
+func TestFromMediaWrapped(t *testing.T) {
+	doc := &tg.Document{ID: 7, Size: 42, Attributes: []tg.DocumentAttributeClass{
+		&tg.DocumentAttributeFilename{FileName: "a.mp4"},
+	}}
+	photo := &tg.Photo{ID: 8, Sizes: []tg.PhotoSizeClass{&tg.PhotoSize{Type: "x", W: 1, H: 1, Size: 3}}}
+	tests := []struct {
+		name  string
+		media tg.MessageMediaClass
+		id    int64
+		size  int64
+		err   error
+	}{
+		{
+			name: "purchased paid media",
+			media: &tg.MessageMediaPaidMedia{ExtendedMedia: []tg.MessageExtendedMediaClass{
+				&tg.MessageExtendedMedia{Media: &tg.MessageMediaDocument{Document: doc}},
+			}},
+			id: 7, size: 42,
+		},
+		{
+			name: "paid media preview",
+			media: &tg.MessageMediaPaidMedia{ExtendedMedia: []tg.MessageExtendedMediaClass{
+				&tg.MessageExtendedMediaPreview{},
+			}},
+			err: ErrNotPurchased,
+		},
+		{
+			name: "invoice",
+			media: func() tg.MessageMediaClass {
+				m := &tg.MessageMediaInvoice{}
+				m.SetExtendedMedia(&tg.MessageExtendedMedia{Media: &tg.MessageMediaPhoto{Photo: photo}})
+				return m
+			}(),
+			id: 8, size: 3,
+		},
+		{
+			name:  "game photo",
+			media: &tg.MessageMediaGame{Game: tg.Game{Photo: photo}},
+			id:    8, size: 3,
+		},
+		{
+			name: "web page document",
+			media: func() tg.MessageMediaClass {
+				wp := &tg.WebPage{}
+				wp.SetDocument(doc)
+				wp.SetPhoto(photo)
+				return &tg.MessageMediaWebPage{Webpage: wp}
+			}(),
+			id: 7, size: 42,
+		},
+		{
+			name: "alternative quality when document is empty",
+			media: &tg.MessageMediaDocument{Document: &tg.DocumentEmpty{}, AltDocuments: []tg.DocumentClass{
+				&tg.Document{ID: 9, Size: 10},
+				&tg.Document{ID: 10, Size: 20},
+			}},
+			id: 10, size: 20,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file, err := FromMedia(tt.media, nil)
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("error = %v, want %v", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if id := locationID(file.Location()); id != tt.id || file.Size() != tt.size {
+				t.Errorf("got file %d with size %d, want %d with size %d", id, file.Size(), tt.id, tt.size)
+			}
+		})
+	}
+}
+
+func TestAlternatives(t *testing.T) {
+	media := &tg.MessageMediaDocument{
+		Document: &tg.Document{ID: 1, Size: 100},
+		AltDocuments: []tg.DocumentClass{
+			&tg.Document{ID: 2, Size: 50, MimeType: "video/mp4", Attributes: []tg.DocumentAttributeClass{
+				&tg.DocumentAttributeVideo{W: 1280, H: 720},
+			}},
+		},
+	}
+	media.SetVideoCover(&tg.Photo{ID: 3, Sizes: []tg.PhotoSizeClass{&tg.PhotoSize{Type: "x", W: 1, H: 1, Size: 1}}})
+	files := Alternatives(media, nil)
+	if len(files) != 2 || files[0].Name() != "2_720p.mp4" || files[1].Name() != "3_cover.jpg" {
+		t.Fatalf("unexpected alternatives: %v", files)
+	}
+	if locations := mediaLocations(media); len(locations) != 3 {
+		t.Errorf("got %d locations, want 3", len(locations))
+	}
+}
+
+func TestFilesFromMediaMessage(t *testing.T) {
+	video := &tg.MessageMediaDocument{
+		Document: &tg.Document{ID: 1, Size: 100},
+		AltDocuments: []tg.DocumentClass{
+			&tg.Document{ID: 2, Size: 50, MimeType: "video/mp4", Attributes: []tg.DocumentAttributeClass{
+				&tg.DocumentAttributeVideo{W: 1280, H: 720},
+			}},
+		},
+	}
+	photo := &tg.Photo{ID: 3, Sizes: []tg.PhotoSizeClass{&tg.PhotoSize{Type: "x", W: 1, H: 1, Size: 1}}}
+	media := &tg.MessageMediaPaidMedia{ExtendedMedia: []tg.MessageExtendedMediaClass{
+		&tg.MessageExtendedMedia{Media: video},
+		&tg.MessageExtendedMediaPreview{},
+		&tg.MessageExtendedMedia{Media: &tg.MessageMediaPhoto{Photo: photo}},
+	}}
+	msg := &tg.Message{ID: 5}
+
+	ids := func(files []TGFileMessage) []int64 {
+		var ids []int64
+		for _, f := range files {
+			if f.Message() != msg {
+				t.Errorf("file %s has no source message", f.Name())
+			}
+			ids = append(ids, locationID(f.Location()))
+		}
+		return ids
+	}
+	files, err := FilesFromMediaMessage(media, nil, msg, WithName("custom.mp4"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := ids(files); !slices.Equal(got, []int64{1, 3}) {
+		t.Errorf("got files %v, want every purchased item [1 3]", got)
+	}
+
+	config.Cfg.Telegram.SaveAlternatives = true
+	defer func() { config.Cfg.Telegram.SaveAlternatives = false }()
+	files, err = FilesFromMediaMessage(media, nil, msg, WithName("custom.mp4"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := ids(files); !slices.Equal(got, []int64{1, 2, 3}) {
+		t.Fatalf("got files %v, want [1 2 3] with the alternative quality", got)
+	}
+	if files[1].Name() != "2_720p.mp4" {
+		t.Errorf("alternative name = %s, want 2_720p.mp4", files[1].Name())
+	}
+}
+
+func TestFileFromMediaMessageByLocation(t *testing.T) {
+	media := &tg.MessageMediaPaidMedia{ExtendedMedia: []tg.MessageExtendedMediaClass{
+		&tg.MessageExtendedMedia{Media: &tg.MessageMediaDocument{Document: &tg.Document{ID: 1, Size: 100}}},
+		&tg.MessageExtendedMedia{Media: &tg.MessageMediaDocument{Document: &tg.Document{ID: 2, Size: 200}}},
+	}}
+	msg := &tg.Message{ID: 5}
+	file, err := FileFromMediaMessage(media, nil, msg, 2, WithNameIfEmpty("second.mp4"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if LocationID(file) != 2 || file.Size() != 200 || file.Name() != "second.mp4" || file.Message() != msg {
+		t.Fatalf("got file %d %s with size %d, want the second item", LocationID(file), file.Name(), file.Size())
+	}
+	if _, err := FileFromMediaMessage(media, nil, msg, 3); err == nil {
+		t.Fatal("expected error for a file that is not in the message")
+	}
+}
@@ -36,3 +36,16 @@ func WithSizeIfZero(size int64) TGFileOptions {
 		}
 	}
 }
+
+func WithDC(dc int) TGFileOptions {
+	return func(f *tgFile) {
+		f.dc = dc
+	}
+}
+
+// 用于在 file reference 过期时重新获取消息, 应为获取该消息的客户端
+func WithMessageGetter(getter MessageGetter) TGFileOptions {
+	return func(f *tgFile) {
+		f.getter = getter
+	}
+}
@@ -0,0 +1,147 @@
+package tfile
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/charmbracelet/log"
+	"github.com/gotd/td/telegram/downloader"
+
+	"github.com/celestix/gotgproto/functions"
+	"github.com/gotd/td/tg"
+	"github.com/gotd/td/tgerr"
+)
+
+var ErrCannotRefresh = errors.New("file cannot be refreshed")
+
+// 可以按 ID 重新获取消息的客户端, *ext.Context 满足该接口
+type MessageGetter interface {
+	GetMessages(chatID int64, messageIDs []tg.InputMessageClass) ([]tg.MessageClass, error)
+}
+
+func (f *tgFile) Refresh(ctx context.Context) error {
+	f.mu.RLock()
+	msg, getter, old := f.message, f.getter, f.location
+	f.mu.RUnlock()
+	if msg == nil || getter == nil {
+		return ErrCannotRefresh
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	location, newMsg, err := refetch(getter, msg, old)
+	if err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.location = location
+	f.message = newMsg
+	return nil
+}
+
+// 用 getter 重新获取来源消息, 返回其中与 old 为同一文件的位置
+func refetch(getter MessageGetter, msg *tg.Message, old tg.InputFileLocationClass) (tg.InputFileLocationClass, *tg.Message, error) {
+	chatID := functions.GetChatIdFromPeer(msg.GetPeerID())
+	msgs, err := getter.GetMessages(chatID, []tg.InputMessageClass{&tg.InputMessageID{ID: msg.GetID()}})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to refetch message %d: %w", msg.GetID(), err)
+	}
+	if len(msgs) == 0 {
+		return nil, nil, fmt.Errorf("message not found: chatID=%d, msgID=%d", chatID, msg.GetID())
+	}
+	newMsg, ok := msgs[0].(*tg.Message)
+	if !ok {
+		return nil, nil, fmt.Errorf("unexpected message type: %T", msgs[0])
+	}
+	media, ok := newMsg.GetMedia()
+	if !ok {
+		return nil, nil, fmt.Errorf("message %d has no media anymore", msg.GetID())
+	}
+	// 媒体中可能有多个文件, 例如付费媒体和视频的其他清晰度, 按 ID 找到原来的文件
+	var location tg.InputFileLocationClass
+	for _, l := range mediaLocations(media) {
+		if locationID(l) == locationID(old) {
+			location = l
+			break
+		}
+	}
+	if location == nil {
+		return nil, nil, fmt.Errorf("media of message %d has changed", msg.GetID())
+	}
+	return location, newMsg, nil
+}
+
+func locationID(location tg.InputFileLocationClass) int64 {
+	switch l := location.(type) {
+	case *tg.InputDocumentFileLocation:
+		return l.ID
+	case *tg.InputPhotoFileLocation:
+		return l.ID
+	}
+	return 0
+}
+
+// 判断是否为 file reference 过期的错误
+func IsFileReferenceExpired(err error) bool {
+	return tgerr.Is(err, "FILE_REFERENCE_EXPIRED")
+}
+
+// 每次请求都使用文件当前的 location, 遇到 FILE_REFERENCE_EXPIRED 时刷新文件后重试
+type refreshClient struct {
+	downloader.Client
+	file TGFileMessage
+
+	mu sync.Mutex
+}
+
+func (c *refreshClient) UploadGetFile(ctx context.Context, request *tg.UploadGetFileRequest) (tg.UploadFileClass, error) {
+	req := *request
+	req.Location = c.file.Location()
+	res, err := c.Client.UploadGetFile(ctx, &req)
+	if !IsFileReferenceExpired(err) {
+		return res, err
+	}
+	if rerr := c.refresh(ctx, req.Location); rerr != nil {
+		log.FromContext(ctx).Errorf("Failed to refresh file reference: %s", rerr)
+		return res, err
+	}
+	req.Location = c.file.Location()
+	return c.Client.UploadGetFile(ctx, &req)
+}
+
+// 多个分块可能同时遇到过期, 只有第一个需要刷新
+func (c *refreshClient) refresh(ctx context.Context, expired tg.InputFileLocationClass) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.file.Location() != expired {
+		return nil
+	}
+	return c.file.Refresh(ctx)
+}
+
+// 返回文件来源消息所在的 chat id 和消息 id, 文件不是来自消息时 ok 为 false
+func MessageSource(file TGFile) (chatID int64, msgID int, ok bool) {
+	fm, isMsg := file.(TGFileMessage)
+	if !isMsg || fm.Message() == nil || isStory(file) {
+		return 0, 0, false
+	}
+	msg := fm.Message()
+	return functions.GetChatIdFromPeer(msg.GetPeerID()), msg.GetID(), true
+}
+
+// 返回文件来源故事所属的 chat id 和故事 id, 文件不是来自故事时 ok 为 false
+func StorySource(file TGFile) (chatID int64, storyID int, ok bool) {
+	if !isStory(file) {
+		return 0, 0, false
+	}
+	msg := file.(TGFileMessage).Message()
+	return functions.GetChatIdFromPeer(msg.GetPeerID()), msg.GetID(), true
+}
+
+func isStory(file TGFile) bool {
+	f, ok := file.(*tgFile)
+	return ok && f.story && f.Message() != nil
+}
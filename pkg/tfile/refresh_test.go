@@ -0,0 +1,104 @@
+package tfile
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gotd/td/telegram/downloader"
+	"github.com/gotd/td/tg"
+	"github.com/gotd/td/tgerr"
+)
+
+type fakeGetter struct {
+	reference []byte
+	calls     int
+}
+
+func (g *fakeGetter) GetMessages(chatID int64, messageIDs []tg.InputMessageClass) ([]tg.MessageClass, error) {
+	g.calls++
+	return []tg.MessageClass{newDocumentMessage(g.reference)}, nil
+}
+
+// 只接受 file reference 为 valid 的请求
+type fakeDownloadClient struct {
+	downloader.Client
+}
+
+func (c *fakeDownloadClient) UploadGetFile(ctx context.Context, request *tg.UploadGetFileRequest) (tg.UploadFileClass, error) {
+	location := request.Location.(*tg.InputDocumentFileLocation)
+	if string(location.FileReference) != "valid" {
+		return nil, tgerr.New(400, "FILE_REFERENCE_EXPIRED")
+	}
+	return &tg.UploadFile{Bytes: []byte("ok")}, nil
+}
+
+func newDocumentMessage(reference []byte) *tg.Message {
+	msg := &tg.Message{
+		ID:     1,
+		PeerID: &tg.PeerChannel{ChannelID: 100},
+	}
+	msg.SetMedia(&tg.MessageMediaDocument{
+		Document: &tg.Document{ID: 42, FileReference: reference, Size: 2},
+	})
+	return msg
+}
+
+func TestRefreshOnExpiredReference(t *testing.T) {
+	msg := newDocumentMessage([]byte("expired"))
+	getter := &fakeGetter{reference: []byte("valid")}
+	file, err := FromMediaMessage(msg.Media, &fakeDownloadClient{}, msg, WithMessageGetter(getter))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	client := &refreshClient{Client: file.Dler(), file: file}
+	for range 2 {
+		res, err := client.UploadGetFile(context.Background(), &tg.UploadGetFileRequest{Location: msg.Media.(*tg.MessageMediaDocument).Document.(*tg.Document).AsInputDocumentFileLocation()})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if string(res.(*tg.UploadFile).Bytes) != "ok" {
+			t.Fatalf("unexpected response: %v", res)
+		}
+	}
+	if getter.calls != 1 {
+		t.Fatalf("expected message to be refetched once, got %d", getter.calls)
+	}
+}
+
+func TestRefreshRejectsChangedMedia(t *testing.T) {
+	msg := newDocumentMessage([]byte("expired"))
+	file, err := FromMediaMessage(msg.Media, &fakeDownloadClient{}, msg, WithMessageGetter(&fakeGetter{}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	file.(*tgFile).location.(*tg.InputDocumentFileLocation).ID = 7
+	if err := file.Refresh(context.Background()); err == nil {
+		t.Fatal("expected error when media has changed")
+	}
+	noGetter, _ := FromMediaMessage(msg.Media, &fakeDownloadClient{}, msg)
+	if err := noGetter.Refresh(context.Background()); err != ErrCannotRefresh {
+		t.Fatalf("expected ErrCannotRefresh, got %v", err)
+	}
+}
+
+func TestStorySource(t *testing.T) {
+	msg := newDocumentMessage([]byte("valid"))
+	file, err := FromMediaMessage(msg.Media, &fakeDownloadClient{}, msg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, _, ok := StorySource(file); ok {
+		t.Fatal("message file should not be a story")
+	}
+	story, err := FromStoryMessage(msg, &fakeDownloadClient{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, _, ok := MessageSource(story); ok {
+		t.Fatal("story file should not be a message")
+	}
+	chatID, storyID, ok := StorySource(story)
+	if !ok || chatID != 100 || storyID != 1 {
+		t.Fatalf("unexpected story source: %d %d %v", chatID, storyID, ok)
+	}
+}
@@ -1,12 +1,14 @@
 package tfile
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"sync"
 
-	"github.com/celestix/gotgproto/functions"
 	"github.com/gotd/td/telegram/downloader"
 	"github.com/gotd/td/tg"
+	"github.com/krau/SaveAny-Bot/config"
 )
 
 type TGFile interface {
@@ -19,17 +21,25 @@ type TGFile interface {
 type TGFileMessage interface {
 	TGFile
 	Message() *tg.Message
+	// 重新获取来源消息并更新文件位置, 用于处理过期的 file reference
+	Refresh(ctx context.Context) error
 }
 
 type tgFile struct {
+	mu       sync.RWMutex // 保护 location 和 message, 二者在 Refresh 时会被更新
 	location tg.InputFileLocationClass
 	size     int64
 	name     string
 	message  *tg.Message
 	dler     downloader.Client
+	dc       int
+	getter   MessageGetter
+	story    bool // 文件来自故事, message 为表示故事的消息
 }
 
 func (f *tgFile) Location() tg.InputFileLocationClass {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
 	return f.location
 }
 
@@ -42,6 +52,8 @@ func (f *tgFile) Name() string {
 }
 
 func (f *tgFile) Message() *tg.Message {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
 	return f.message
 }
 
@@ -49,6 +61,24 @@ func (f *tgFile) Dler() downloader.Client {
 	return f.dler
 }
 
+// 文件所在的 DC, 未知时为 0
+func (f *tgFile) DC() int {
+	return f.dc
+}
+
+// 由其他账号下载时使用的副本, location 和 message 为该账号重新获取的结果
+func (f *tgFile) withAccount(client downloader.Client, getter MessageGetter, location tg.InputFileLocationClass, msg *tg.Message) *tgFile {
+	return &tgFile{
+		location: location,
+		size:     f.size,
+		name:     f.name,
+		message:  msg,
+		dler:     client,
+		dc:       f.dc,
+		getter:   getter,
+	}
+}
+
 func NewTGFile(
 	location tg.InputFileLocationClass,
 	dler downloader.Client,
@@ -68,61 +98,41 @@ func NewTGFile(
 	return f
 }
 
+// 获取媒体中的文件. 支持文档, 图片, 以及已购买的付费媒体, 发票, 游戏和网页预览中的文档或图片.
+// 付费媒体有多个时取第一个, 需要所有文件时使用 FilesFromMediaMessage
 func FromMedia(media tg.MessageMediaClass, client downloader.Client, opts ...TGFileOptions) (TGFile, error) {
-	switch m := media.(type) {
+	medias, err := UnwrapMedia(media)
+	if err != nil {
+		return nil, err
+	}
+	switch m := medias[0].(type) {
 	case *tg.MessageMediaDocument:
-		document, ok := m.Document.AsNotEmpty()
-		if !ok {
-			return nil, errors.New("document is empty")
+		document, cover, err := mainDocument(m)
+		if err != nil {
+			return nil, err
 		}
-		fileName := ""
-		for _, attribute := range document.Attributes {
-			if name, ok := attribute.(*tg.DocumentAttributeFilename); ok {
-				fileName = name.GetFileName()
-				break
-			}
+		if cover != nil {
+			return photoFile(cover, client, fmt.Sprintf("%d_cover.jpg", cover.GetID()), opts...)
 		}
-		file := NewTGFile(
-			document.AsInputDocumentFileLocation(),
-			client,
-			document.Size,
-			fileName,
-			opts...,
-		)
-		return file, nil
+		return documentFile(document, client, documentName(document), opts...), nil
 	case *tg.MessageMediaPhoto:
 		photo, ok := m.Photo.AsNotEmpty()
 		if !ok {
 			return nil, errors.New("photo is empty")
 		}
-		sizes := photo.Sizes
-		if len(sizes) == 0 {
-			return nil, errors.New("photo sizes are empty")
-		}
-		photoSize := sizes[len(sizes)-1]
-		size, ok := photoSize.AsNotEmpty()
-		if !ok {
-			return nil, errors.New("photo size is empty")
-		}
-		location := new(tg.InputPhotoFileLocation)
-		location.ID = photo.GetID()
-		location.AccessHash = photo.GetAccessHash()
-		location.FileReference = photo.GetFileReference()
-		location.ThumbSize = size.GetType()
-		fileName, err := functions.GetMediaFileName(m)
-		if err != nil {
-			fileName = fmt.Sprintf("photo_%d.png", photo.GetID())
-		}
-		file := NewTGFile(
-			location,
-			client,
-			0, // Photo size is not available in InputPhotoFileLocation
-			fileName,
-			opts...,
-		)
-		return file, nil
+		return photoFile(photo, client, fmt.Sprintf("%d.png", photo.GetID()), opts...)
+	}
+	return nil, fmt.Errorf("unsupported media type: %T", medias[0])
+}
+
+// 故事中的文件. 故事不是消息, 以 msg 表示其所属的 chat, ID 和说明, 刷新时 getter 需按故事 ID 获取
+func FromStoryMessage(msg *tg.Message, client downloader.Client, opts ...TGFileOptions) (TGFileMessage, error) {
+	file, err := FromMediaMessage(msg.Media, client, msg, opts...)
+	if err != nil {
+		return nil, err
 	}
-	return nil, fmt.Errorf("unsupported media type: %T", media)
+	file.(*tgFile).story = true
+	return file, nil
 }
 
 func FromMediaMessage(media tg.MessageMediaClass, client downloader.Client, msg *tg.Message, opts ...TGFileOptions) (TGFileMessage, error) {
@@ -130,11 +140,62 @@ func FromMediaMessage(media tg.MessageMediaClass, client downloader.Client, msg
 	if err != nil {
 		return nil, err
 	}
-	return &tgFile{
-		location: file.Location(),
-		dler:     file.Dler(),
-		size:     file.Size(),
-		name:     file.Name(),
-		message:  msg,
-	}, nil
+	f := file.(*tgFile)
+	f.message = msg
+	return f, nil
+}
+
+// 消息媒体中位置 ID 为 id 的文件, 在付费媒体的每一项和视频的其他清晰度中查找, 用于恢复时找回同一个文件.
+// id 为 0 时与 FromMediaMessage 相同
+func FileFromMediaMessage(media tg.MessageMediaClass, client downloader.Client, msg *tg.Message, id int64, opts ...TGFileOptions) (TGFileMessage, error) {
+	if id == 0 {
+		return FromMediaMessage(media, client, msg, opts...)
+	}
+	medias, err := UnwrapMedia(media)
+	if err != nil {
+		return nil, err
+	}
+	for _, media := range medias {
+		if file, err := FromMediaMessage(media, client, msg, opts...); err == nil && LocationID(file) == id {
+			return file, nil
+		}
+		for _, alt := range Alternatives(media, client, opts...) {
+			if LocationID(alt) == id {
+				f := alt.(*tgFile)
+				f.message = msg
+				return f, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("file %d not found in message %d", id, msg.GetID())
+}
+
+// 文件位置的 ID, 同一消息中的不同文件各不相同
+func LocationID(file TGFile) int64 {
+	return locationID(file.Location())
+}
+
+// 消息媒体中的所有文件: 每个已购买的付费媒体, 开启 telegram.save_alternatives 时还包括视频的其他清晰度和封面
+func FilesFromMediaMessage(media tg.MessageMediaClass, client downloader.Client, msg *tg.Message, opts ...TGFileOptions) ([]TGFileMessage, error) {
+	medias, err := UnwrapMedia(media)
+	if err != nil {
+		return nil, err
+	}
+	files := make([]TGFileMessage, 0, len(medias))
+	for _, media := range medias {
+		file, err := FromMediaMessage(media, client, msg, opts...)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+		if !config.Cfg.Telegram.SaveAlternatives {
+			continue
+		}
+		for _, alt := range Alternatives(media, client, opts...) {
+			f := alt.(*tgFile)
+			f.message = msg
+			files = append(files, f)
+		}
+	}
+	return files, nil
 }
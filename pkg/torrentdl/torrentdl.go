@@ -0,0 +1,268 @@
+// Package torrentdl 使用内嵌的 BitTorrent 客户端下载种子中选择的文件
+package torrentdl
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/anacrolix/torrent"
+	"github.com/anacrolix/torrent/metainfo"
+	"github.com/anacrolix/torrent/storage"
+	"github.com/anacrolix/torrent/types"
+)
+
+type Config struct {
+	DataDir    string
+	ListenPort int // 0 为随机端口
+	NoDHT      bool
+	Trackers   []string // 附加到每个种子的 tracker
+}
+
+type Client struct {
+	cl       *torrent.Client
+	trackers []string
+}
+
+// 进度检查间隔
+var pollInterval = 500 * time.Millisecond
+
+func NewClient(cfg Config) (*Client, error) {
+	tcfg := torrent.NewDefaultClientConfig()
+	tcfg.DataDir = cfg.DataDir
+	tcfg.ListenPort = cfg.ListenPort
+	tcfg.NoDHT = cfg.NoDHT
+	tcfg.Seed = true
+	// 不在数据目录中创建分片完成状态数据库
+	tcfg.DefaultStorage = storage.NewFileOpts(storage.NewFileClientOpts{
+		ClientBaseDir:   cfg.DataDir,
+		PieceCompletion: storage.NewMapPieceCompletion(),
+	})
+	cl, err := torrent.NewClient(tcfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create torrent client: %w", err)
+	}
+	return &Client{cl: cl, trackers: cfg.Trackers}, nil
+}
+
+func (c *Client) Close() {
+	c.cl.Close()
+}
+
+// 种子中的一个文件
+type File struct {
+	Index int
+	Path  string // 多文件种子中的相对路径, 单文件种子为种子名称
+	Size  int64
+}
+
+// 种子信息
+type Meta struct {
+	Name     string
+	InfoHash string
+	Dir      bool // 多文件种子, 文件保存在以种子名称命名的目录中
+	Files    []File
+	Size     int64
+}
+
+// 解析 .torrent 文件内容
+func ParseMetainfo(data []byte) (*Meta, error) {
+	mi, err := metainfo.Load(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("invalid torrent: %w", err)
+	}
+	info, err := mi.UnmarshalInfo()
+	if err != nil {
+		return nil, fmt.Errorf("invalid torrent info: %w", err)
+	}
+	meta := &Meta{Name: info.BestName(), InfoHash: mi.HashInfoBytes().HexString(), Dir: info.IsDir()}
+	for i, fi := range info.UpvertedFiles() {
+		p := info.BestName()
+		if info.IsDir() {
+			p = filepath.ToSlash(filepath.Join(fi.BestPath()...))
+		}
+		meta.Files = append(meta.Files, File{Index: i, Path: p, Size: fi.Length})
+		meta.Size += fi.Length
+	}
+	return meta, nil
+}
+
+// 通过 DHT 和 tracker 获取磁力链接的种子信息, 返回 .torrent 文件内容
+func (c *Client) FetchMetainfo(ctx context.Context, magnet string) ([]byte, error) {
+	t, err := c.cl.AddMagnet(magnet)
+	if err != nil {
+		return nil, fmt.Errorf("invalid magnet: %w", err)
+	}
+	defer t.Drop()
+	if len(c.trackers) > 0 {
+		t.AddTrackers([][]string{c.trackers})
+	}
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-t.GotInfo():
+	}
+	var buf bytes.Buffer
+	mi := t.Metainfo()
+	if err := mi.Write(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// 添加中的种子
+type Torrent struct {
+	t       *torrent.Torrent
+	storage storage.ClientImplCloser
+	dir     string
+}
+
+// 从 .torrent 文件内容添加种子, 文件保存在 dir 中. 添加后不会下载任何文件
+func (c *Client) Add(data []byte, dir string) (*Torrent, error) {
+	mi, err := metainfo.Load(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("invalid torrent: %w", err)
+	}
+	spec, err := torrent.TorrentSpecFromMetaInfoErr(mi)
+	if err != nil {
+		return nil, err
+	}
+	if len(c.trackers) > 0 {
+		spec.Trackers = append(spec.Trackers, c.trackers)
+	}
+	stor := storage.NewFileOpts(storage.NewFileClientOpts{
+		ClientBaseDir:   dir,
+		FilePathMaker:   localFilePath,
+		PieceCompletion: storage.NewMapPieceCompletion(),
+	})
+	spec.Storage = stor
+	t, isNew, err := c.cl.AddTorrentSpec(spec)
+	if err != nil {
+		stor.Close()
+		return nil, err
+	}
+	if !isNew {
+		stor.Close()
+		return nil, errors.New("torrent is already being downloaded")
+	}
+	return &Torrent{t: t, storage: stor, dir: dir}, nil
+}
+
+// 下载指定的文件, 直到全部完成或 ctx 取消. onProgress 定期以各文件已完成的字节数调用, 顺序与 indexes 相同
+func (t *Torrent) Download(ctx context.Context, indexes []int, onProgress func(done []int64)) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.t.GotInfo():
+	}
+	files := t.t.Files()
+	selected := make([]*torrent.File, 0, len(indexes))
+	for _, f := range files {
+		f.SetPriority(types.PiecePriorityNone)
+	}
+	for _, i := range indexes {
+		if i < 0 || i >= len(files) {
+			return fmt.Errorf("file index %d out of range", i)
+		}
+		selected = append(selected, files[i])
+		files[i].Download()
+	}
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	done := make([]int64, len(selected))
+	for {
+		finished := true
+		for i, f := range selected {
+			done[i] = f.BytesCompleted()
+			if done[i] < f.Length() || !fileVerified(f) {
+				finished = false
+			}
+		}
+		if onProgress != nil {
+			onProgress(done)
+		}
+		if finished {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// BytesCompleted 包括未校验的数据, 完成前需要确认所有分片都已校验
+func fileVerified(f *torrent.File) bool {
+	for _, ps := range f.State() {
+		if !ps.Complete {
+			return false
+		}
+	}
+	return true
+}
+
+// 文件相对于下载目录的路径, 按 storage.ToSafeFilePath 的规则清理.
+// 路径会超出下载目录时原样返回, 由 storage 拒绝打开
+func localFilePath(opts storage.FilePathMakerOpts) string {
+	var parts []string
+	if opts.Info.BestName() != metainfo.NoName {
+		parts = append(parts, opts.Info.BestName())
+	}
+	parts = append(parts, opts.File.BestPath()...)
+	p, err := storage.ToSafeFilePath(parts...)
+	if err != nil {
+		return filepath.Join(parts...)
+	}
+	return p
+}
+
+// 文件在本地的路径, 与下载时 storage 使用的路径相同
+func (t *Torrent) FilePath(index int) string {
+	fi := t.t.Files()[index].FileInfo()
+	return filepath.Join(t.dir, localFilePath(storage.FilePathMakerOpts{Info: t.t.Info(), File: &fi}))
+}
+
+// 已上传的字节数
+func (t *Torrent) Uploaded() int64 {
+	stats := t.t.Stats()
+	return stats.BytesWrittenData.Int64()
+}
+
+// 继续做种直到上传量达到 size*ratio, 或者经过 duration, 或者 ctx 取消. ratio 和 duration 为 0 时不限制该条件, 都为 0 时直接返回
+func (t *Torrent) Seed(ctx context.Context, size int64, ratio float64, duration time.Duration) {
+	if ratio <= 0 && duration <= 0 {
+		return
+	}
+	var deadline <-chan time.Time
+	if duration > 0 {
+		timer := time.NewTimer(duration)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		if ratio > 0 && float64(t.Uploaded()) >= float64(size)*ratio {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-deadline:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// 停止下载和做种, 删除本地文件
+func (t *Torrent) Close() error {
+	t.t.Drop()
+	t.storage.Close()
+	return os.RemoveAll(t.dir)
+}
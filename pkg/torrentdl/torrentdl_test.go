@@ -0,0 +1,147 @@
+package torrentdl
+
+import (
+	"bytes"
+	"context"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/anacrolix/torrent/bencode"
+	"github.com/anacrolix/torrent/metainfo"
+	"github.com/anacrolix/torrent/storage"
+)
+
+func newTestClient(t *testing.T) *Client {
+	c, err := NewClient(Config{DataDir: t.TempDir(), NoDHT: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(c.Close)
+	return c
+}
+
+// 在 dir 中创建两个文件并生成种子
+func makeTorrent(t *testing.T, dir string) ([]byte, map[string][]byte) {
+	root := filepath.Join(dir, "pack")
+	files := map[string][]byte{
+		"a.bin":     make([]byte, 300<<10),
+		"sub/b.bin": make([]byte, 100<<10),
+	}
+	rnd := rand.New(rand.NewSource(1))
+	for name, data := range files {
+		rnd.Read(data)
+		p := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	info := metainfo.Info{PieceLength: 32 << 10}
+	if err := info.BuildFromFilePath(root); err != nil {
+		t.Fatal(err)
+	}
+	infoBytes, err := bencode.Marshal(info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := bencode.Marshal(metainfo.MetaInfo{InfoBytes: infoBytes})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data, files
+}
+
+func TestParseMetainfo(t *testing.T) {
+	data, _ := makeTorrent(t, t.TempDir())
+	meta, err := ParseMetainfo(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.Name != "pack" || !meta.Dir || len(meta.Files) != 2 || meta.Size != 400<<10 {
+		t.Fatalf("unexpected meta: %+v", meta)
+	}
+	if meta.Files[0].Path != "a.bin" || meta.Files[1].Path != "sub/b.bin" || meta.Files[1].Size != 100<<10 {
+		t.Fatalf("unexpected files: %+v", meta.Files)
+	}
+	if _, err := ParseMetainfo([]byte("not a torrent")); err == nil {
+		t.Fatal("expected error for invalid torrent")
+	}
+}
+
+func TestDownloadSelectedFile(t *testing.T) {
+	seedDir := t.TempDir()
+	data, files := makeTorrent(t, seedDir)
+
+	seeder := newTestClient(t)
+	st, err := seeder.Add(data, seedDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	st.t.VerifyData()
+	if !st.t.Complete().Bool() {
+		t.Fatal("seeder should have complete data")
+	}
+
+	leecher := newTestClient(t)
+	lt, err := leecher.Add(data, filepath.Join(t.TempDir(), "dl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lt.t.AddClientPeer(seeder.cl)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	var last []int64
+	if err := lt.Download(ctx, []int{1}, func(done []int64) {
+		last = append(last[:0], done...)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(last) != 1 || last[0] != 100<<10 {
+		t.Fatalf("unexpected progress: %v", last)
+	}
+	got, err := os.ReadFile(lt.FilePath(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, files["sub/b.bin"]) {
+		t.Fatal("downloaded data mismatch")
+	}
+
+	dir := lt.dir
+	if err := lt.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Fatalf("download dir should be removed, got %v", err)
+	}
+}
+
+func TestSeedLimits(t *testing.T) {
+	c := newTestClient(t)
+	data, _ := makeTorrent(t, t.TempDir())
+	tt, err := c.Add(data, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tt.Close()
+	start := time.Now()
+	tt.Seed(context.Background(), 1, 0, 0)
+	tt.Seed(context.Background(), 1, 0, 100*time.Millisecond)
+	if time.Since(start) > 5*time.Second {
+		t.Fatal("seed should stop after duration")
+	}
+}
+
+func TestLocalFilePathCleansComponents(t *testing.T) {
+	info := &metainfo.Info{Name: "pack"}
+	fi := &metainfo.FileInfo{Path: []string{"sub", "..", "b.bin"}}
+	if got, want := localFilePath(storage.FilePathMakerOpts{Info: info, File: fi}), filepath.Join("pack", "b.bin"); got != want {
+		t.Fatalf("localFilePath() = %q, want %q", got, want)
+	}
+}
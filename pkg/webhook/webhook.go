@@ -0,0 +1,149 @@
+// Package webhook 发送带签名的 JSON 请求, 失败时按指数退避重试
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/rs/xid"
+)
+
+const (
+	SignatureHeader = "X-SaveAny-Signature"
+	EventHeader     = "X-SaveAny-Event"
+	DeliveryHeader  = "X-SaveAny-Delivery"
+	TimestampHeader = "X-SaveAny-Timestamp"
+)
+
+const (
+	defaultTimeout = 10 * time.Second
+	maxBackoff     = 30 * time.Second
+)
+
+// 第一次重试前的等待时间, 之后每次翻倍
+var baseBackoff = time.Second
+
+type Client struct {
+	URL     string
+	Secret  string
+	Headers map[string]string
+	Retry   int
+
+	http *http.Client
+}
+
+func NewClient(url, secret string, headers map[string]string, timeout time.Duration, retry int) *Client {
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	return &Client{
+		URL:     url,
+		Secret:  secret,
+		Headers: headers,
+		Retry:   max(retry, 0),
+		http:    &http.Client{Timeout: timeout},
+	}
+}
+
+// 计算签名, 签名内容为 "<timestamp>.<body>", 格式为 sha256=<hex>.
+// timestamp 为 TimestampHeader 中的 Unix 秒数, 接收端据此拒绝重放的旧请求
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// 校验签名和时间戳, 时间戳与当前时间相差超过 tolerance 时视为无效, tolerance 为 0 时不检查时间.
+// 供接收端参考和测试使用
+func Verify(secret, timestamp string, body []byte, signature string, tolerance time.Duration) bool {
+	if tolerance > 0 {
+		ts, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil {
+			return false
+		}
+		if d := time.Since(time.Unix(ts, 0)); d > tolerance || d < -tolerance {
+			return false
+		}
+	}
+	return hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signature))
+}
+
+// 响应状态码不是 2xx 时返回的错误
+type StatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("webhook returned status %d: %s", e.StatusCode, e.Body)
+}
+
+func (e *StatusError) retryable() bool {
+	return e.StatusCode >= 500 || e.StatusCode == http.StatusTooManyRequests || e.StatusCode == http.StatusRequestTimeout
+}
+
+// 发送 body, 网络错误, 5xx 和 429 时重试. 所有重试使用同一个投递 ID
+func (c *Client) Send(ctx context.Context, event string, body []byte) error {
+	delivery := xid.New().String()
+	backoff := baseBackoff
+	var err error
+	for attempt := 0; attempt <= c.Retry; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return fmt.Errorf("webhook canceled after %d attempts: %w", attempt, err)
+			case <-time.After(backoff):
+			}
+			backoff = min(backoff*2, maxBackoff)
+		}
+		err = c.post(ctx, event, delivery, body)
+		if err == nil {
+			return nil
+		}
+		if se, ok := err.(*StatusError); ok && !se.retryable() {
+			return err
+		}
+	}
+	return fmt.Errorf("webhook failed after %d attempts: %w", c.Retry+1, err)
+}
+
+func (c *Client) post(ctx context.Context, event, delivery string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for k, v := range c.Headers {
+		req.Header.Set(k, v)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "SaveAny-Bot")
+	req.Header.Set(EventHeader, event)
+	req.Header.Set(DeliveryHeader, delivery)
+	// 每次尝试使用新的时间戳, 重试不会因为等待而过期
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set(TimestampHeader, timestamp)
+	if c.Secret != "" {
+		req.Header.Set(SignatureHeader, Sign(c.Secret, timestamp, body))
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return &StatusError{StatusCode: resp.StatusCode, Body: string(msg)}
+}
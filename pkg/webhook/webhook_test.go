@@ -0,0 +1,93 @@
+package webhook
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSendSignedWithRetry(t *testing.T) {
+	baseBackoff = time.Millisecond
+	body := []byte(`{"event":"succeeded","file_name":"it's \"quoted\".mp4"}`)
+	var calls atomic.Int32
+	var (
+		mu         sync.Mutex
+		deliveries []string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ := io.ReadAll(r.Body)
+		if string(got) != string(body) {
+			t.Errorf("unexpected body: %s", got)
+		}
+		if !Verify("secret", r.Header.Get(TimestampHeader), got, r.Header.Get(SignatureHeader), time.Minute) {
+			t.Errorf("invalid signature: %s", r.Header.Get(SignatureHeader))
+		}
+		if r.Header.Get(EventHeader) != "succeeded" || r.Header.Get("X-Custom") != "1" {
+			t.Errorf("unexpected headers: %v", r.Header)
+		}
+		mu.Lock()
+		deliveries = append(deliveries, r.Header.Get(DeliveryHeader))
+		mu.Unlock()
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL, "secret", map[string]string{"X-Custom": "1"}, time.Second, 3)
+	if err := c.Send(context.Background(), "succeeded", body); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if calls.Load() != 3 {
+		t.Fatalf("expected 3 attempts, got %d", calls.Load())
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if deliveries[0] == "" || deliveries[0] != deliveries[2] {
+		t.Fatalf("expected the same delivery id for retries, got %v", deliveries)
+	}
+}
+
+func TestVerifyRejectsReplayedRequest(t *testing.T) {
+	body := []byte(`{}`)
+	old := strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10)
+	sig := Sign("secret", old, body)
+	if Verify("secret", old, body, sig, 5*time.Minute) {
+		t.Fatal("expected an old timestamp to be rejected")
+	}
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	if Verify("secret", now, body, sig, 5*time.Minute) {
+		t.Fatal("expected a signature for another timestamp to be rejected")
+	}
+	if !Verify("secret", now, body, Sign("secret", now, body), 5*time.Minute) {
+		t.Fatal("expected a fresh signature to be accepted")
+	}
+}
+
+func TestSendNoRetryOnClientError(t *testing.T) {
+	baseBackoff = time.Millisecond
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		http.Error(w, "bad request", http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL, "", nil, time.Second, 3)
+	err := c.Send(context.Background(), "failed", []byte(`{}`))
+	se, ok := err.(*StatusError)
+	if !ok || se.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected status error 400, got %v", err)
+	}
+	if calls.Load() != 1 {
+		t.Fatalf("expected 1 attempt, got %d", calls.Load())
+	}
+}
@@ -0,0 +1,65 @@
+package ytdlp
+
+import (
+	"fmt"
+	"slices"
+)
+
+// 提供给用户选择的格式
+type FormatOption struct {
+	Label    string
+	Selector string // 传给 yt-dlp -f 的格式选择器
+	Size     int64  // 预计大小, 未知时为 0
+}
+
+const (
+	BestSelector  = "bv*+ba/b"
+	AudioSelector = "ba/b"
+)
+
+// 按分辨率列出可选格式, 依次为最佳画质, 各个分辨率 (从高到低) 和仅音频
+func FormatOptions(info *Info) []FormatOption {
+	var (
+		heights   []int
+		bestAudio int64
+		hasAudio  bool
+		bestSize  = make(map[int]int64) // 每个分辨率最大的视频大小
+	)
+	for _, f := range info.Formats {
+		if f.HasAudio() {
+			hasAudio = true
+			if !f.HasVideo() {
+				bestAudio = max(bestAudio, f.Size())
+			}
+		}
+		if !f.HasVideo() || f.Height <= 0 {
+			continue
+		}
+		if _, ok := bestSize[f.Height]; !ok {
+			heights = append(heights, f.Height)
+		}
+		bestSize[f.Height] = max(bestSize[f.Height], f.Size())
+	}
+	slices.Sort(heights)
+	slices.Reverse(heights)
+
+	options := []FormatOption{{Label: "最佳画质", Selector: BestSelector}}
+	if len(heights) > 0 && bestSize[heights[0]] > 0 {
+		options[0].Size = bestSize[heights[0]] + bestAudio
+	}
+	// 最高分辨率已包含在最佳画质中
+	for _, h := range heights[min(1, len(heights)):] {
+		opt := FormatOption{
+			Label:    fmt.Sprintf("%dp", h),
+			Selector: fmt.Sprintf("bv*[height<=%d]+ba/b[height<=%d]", h, h),
+		}
+		if bestSize[h] > 0 {
+			opt.Size = bestSize[h] + bestAudio
+		}
+		options = append(options, opt)
+	}
+	if hasAudio && len(heights) > 0 {
+		options = append(options, FormatOption{Label: "仅音频", Selector: AudioSelector, Size: bestAudio})
+	}
+	return options
+}
@@ -0,0 +1,208 @@
+// Package ytdlp 调用本地安装的 yt-dlp 获取视频信息和下载视频
+package ytdlp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 标记 yt-dlp 输出中的进度和文件路径行
+const (
+	progressPrefix = "[saveany-progress]"
+	filePrefix     = "[saveany-file]"
+)
+
+type Client struct {
+	Path string   // yt-dlp 可执行文件
+	Args []string // 附加在每次调用前的参数
+}
+
+func NewClient(path string, args []string) *Client {
+	if path == "" {
+		path = "yt-dlp"
+	}
+	return &Client{Path: path, Args: args}
+}
+
+type Format struct {
+	ID             string  `json:"format_id"`
+	Ext            string  `json:"ext"`
+	Height         int     `json:"height"`
+	VCodec         string  `json:"vcodec"`
+	ACodec         string  `json:"acodec"`
+	FileSize       int64   `json:"filesize"`
+	FileSizeApprox int64   `json:"filesize_approx"`
+	TBR            float64 `json:"tbr"`
+}
+
+func (f Format) HasVideo() bool {
+	return f.VCodec != "" && f.VCodec != "none"
+}
+
+func (f Format) HasAudio() bool {
+	return f.ACodec != "" && f.ACodec != "none"
+}
+
+// 文件大小, 未知时为 0
+func (f Format) Size() int64 {
+	if f.FileSize > 0 {
+		return f.FileSize
+	}
+	return f.FileSizeApprox
+}
+
+type Info struct {
+	ID       string   `json:"id"`
+	Type     string   `json:"_type"`
+	Title    string   `json:"title"`
+	Ext      string   `json:"ext"`
+	Uploader string   `json:"uploader"`
+	Duration float64  `json:"duration"`
+	URL      string   `json:"webpage_url"`
+	Formats  []Format `json:"formats"`
+}
+
+// yt-dlp --progress-template 中 %(progress)j 的内容
+type Progress struct {
+	Status             string  `json:"status"`
+	DownloadedBytes    float64 `json:"downloaded_bytes"`
+	TotalBytes         float64 `json:"total_bytes"`
+	TotalBytesEstimate float64 `json:"total_bytes_estimate"`
+	Speed              float64 `json:"speed"`
+	ETA                float64 `json:"eta"`
+	FileName           string  `json:"filename"`
+}
+
+func (p Progress) Downloaded() int64 {
+	return int64(p.DownloadedBytes)
+}
+
+// 总大小, 未知时为 0
+func (p Progress) Total() int64 {
+	if p.TotalBytes > 0 {
+		return int64(p.TotalBytes)
+	}
+	return int64(p.TotalBytesEstimate)
+}
+
+// yt-dlp 以非零状态退出时返回的错误, Output 为最后几行输出
+type ExitError struct {
+	Err    error
+	Output string
+}
+
+func (e *ExitError) Error() string {
+	if e.Output == "" {
+		return "yt-dlp failed: " + e.Err.Error()
+	}
+	return fmt.Sprintf("yt-dlp failed: %s: %s", e.Err, e.Output)
+}
+
+func (e *ExitError) Unwrap() error {
+	return e.Err
+}
+
+var ErrPlaylist = errors.New("playlists are not supported")
+
+func (c *Client) command(ctx context.Context, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, c.Path, append(append([]string{}, c.Args...), args...)...)
+	cmd.WaitDelay = time.Second
+	return cmd
+}
+
+// 获取视频信息, 不下载
+func (c *Client) Info(ctx context.Context, url string) (*Info, error) {
+	cmd := c.command(ctx, "--dump-single-json", "--no-playlist", "--no-warnings", "--", url)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, &ExitError{Err: err, Output: lastLines(stderr.String(), 3)}
+	}
+	var info Info
+	if err := json.Unmarshal(out, &info); err != nil {
+		return nil, fmt.Errorf("failed to parse yt-dlp output: %w", err)
+	}
+	if info.Type == "playlist" || info.Type == "multi_video" {
+		return nil, ErrPlaylist
+	}
+	return &info, nil
+}
+
+// 使用 format 下载视频到 outputDir, 返回下载的文件路径. onProgress 以 yt-dlp 汇报的进度调用
+func (c *Client) Download(ctx context.Context, url, format, outputDir string, onProgress func(Progress)) (string, error) {
+	cmd := c.command(ctx,
+		"--no-playlist", "--no-warnings", "--newline", "--progress",
+		"--progress-template", "download:"+progressPrefix+"%(progress)j",
+		"--print", "after_move:"+filePrefix+"%(filepath)s",
+		"-f", format,
+		"-P", outputDir,
+		"-o", "%(title).150B [%(id)s].%(ext)s",
+		"--", url,
+	)
+	pr, pw := io.Pipe()
+	cmd.Stdout = pw
+	cmd.Stderr = pw
+	if err := cmd.Start(); err != nil {
+		return "", fmt.Errorf("failed to start yt-dlp: %w", err)
+	}
+	var (
+		filePath string
+		output   []string
+		wg       sync.WaitGroup
+	)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		scanner := bufio.NewScanner(pr)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			line := scanner.Text()
+			switch {
+			case strings.HasPrefix(line, progressPrefix):
+				var p Progress
+				if err := json.Unmarshal([]byte(line[len(progressPrefix):]), &p); err == nil && onProgress != nil {
+					onProgress(p)
+				}
+			case strings.HasPrefix(line, filePrefix):
+				filePath = strings.TrimSpace(line[len(filePrefix):])
+			case strings.TrimSpace(line) != "":
+				output = append(output, line)
+				if len(output) > 3 {
+					output = output[1:]
+				}
+			}
+		}
+		io.Copy(io.Discard, pr)
+	}()
+	err := cmd.Wait()
+	pw.Close()
+	wg.Wait()
+	if err != nil {
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		return "", &ExitError{Err: err, Output: strings.Join(output, "\n")}
+	}
+	if filePath == "" {
+		return "", errors.New("yt-dlp did not report the downloaded file")
+	}
+	return filePath, nil
+}
+
+func lastLines(s string, n int) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	return strings.Join(lines[max(len(lines)-n, 0):], "\n")
+}
@@ -0,0 +1,104 @@
+package ytdlp
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// 模拟 yt-dlp: 输出视频信息, 或者输出进度并在 -P 指定的目录中创建文件
+const fakeScript = `#!/bin/sh
+dir=.
+format=
+while [ $# -gt 0 ]; do
+	case "$1" in
+	--dump-single-json)
+		cat <<'JSON'
+{"id":"abc","title":"Test Video","ext":"mp4","webpage_url":"https://video.example.com/watch?v=abc","formats":[
+{"format_id":"140","ext":"m4a","vcodec":"none","acodec":"mp4a","filesize":1000},
+{"format_id":"18","ext":"mp4","height":360,"vcodec":"avc1","acodec":"mp4a","filesize":5000},
+{"format_id":"137","ext":"mp4","height":1080,"vcodec":"avc1","acodec":"none","filesize_approx":90000},
+{"format_id":"136","ext":"mp4","height":720,"vcodec":"avc1","acodec":"none","filesize":40000}
+]}
+JSON
+		exit 0;;
+	-P) dir="$2"; shift;;
+	-f) format="$2"; shift;;
+	esac
+	shift
+done
+if [ "$format" = "fail" ]; then
+	echo "ERROR: Requested format is not available" >&2
+	exit 1
+fi
+echo "[youtube] abc: Downloading webpage"
+echo '[saveany-progress]{"status":"downloading","downloaded_bytes":512,"total_bytes":1024,"speed":100.5,"eta":null}'
+echo '[saveany-progress]{"status":"finished","downloaded_bytes":1024,"total_bytes":1024}'
+printf 'data' > "$dir/Test Video [abc].mp4"
+echo "[saveany-file]$dir/Test Video [abc].mp4"
+`
+
+func fakeClient(t *testing.T) *Client {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake yt-dlp script requires sh")
+	}
+	path := filepath.Join(t.TempDir(), "yt-dlp")
+	if err := os.WriteFile(path, []byte(fakeScript), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return NewClient(path, []string{"--cookies", "cookies.txt"})
+}
+
+func TestInfoAndFormatOptions(t *testing.T) {
+	info, err := fakeClient(t).Info(context.Background(), "https://video.example.com/watch?v=abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Title != "Test Video" || len(info.Formats) != 4 {
+		t.Fatalf("unexpected info: %+v", info)
+	}
+	options := FormatOptions(info)
+	labels := make([]string, 0, len(options))
+	for _, opt := range options {
+		labels = append(labels, opt.Label)
+	}
+	want := []string{"最佳画质", "720p", "360p", "仅音频"}
+	if len(labels) != len(want) {
+		t.Fatalf("expected %v, got %v", want, labels)
+	}
+	for i := range want {
+		if labels[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, labels)
+		}
+	}
+	if options[0].Size != 91000 || options[1].Selector != "bv*[height<=720]+ba/b[height<=720]" {
+		t.Fatalf("unexpected options: %+v", options)
+	}
+}
+
+func TestDownload(t *testing.T) {
+	c := fakeClient(t)
+	dir := t.TempDir()
+	var progress []Progress
+	file, err := c.Download(context.Background(), "https://video.example.com/watch?v=abc", BestSelector, dir, func(p Progress) {
+		progress = append(progress, p)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file != filepath.Join(dir, "Test Video [abc].mp4") {
+		t.Fatalf("unexpected file: %s", file)
+	}
+	if len(progress) != 2 || progress[0].Downloaded() != 512 || progress[1].Total() != 1024 {
+		t.Fatalf("unexpected progress: %+v", progress)
+	}
+
+	_, err = c.Download(context.Background(), "https://video.example.com/watch?v=abc", "fail", dir, nil)
+	var exitErr *ExitError
+	if !errors.As(err, &exitErr) || exitErr.Output != "ERROR: Requested format is not available" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
@@ -110,13 +110,22 @@ func (a *Alist) Save(ctx context.Context, reader io.Reader, storagePath string)
 	for i := 1; a.Exists(ctx, candidate); i++ {
 		candidate = fmt.Sprintf("%s_%d%s", base, i, ext)
 	}
+	return a.put(ctx, reader, candidate)
+}
+
+// 覆盖保存到 storagePath, Alist 上传同名文件时会替换已有的文件
+func (a *Alist) Overwrite(ctx context.Context, reader io.Reader, storagePath string) error {
+	a.logger.Infof("Overwriting file at %s", storagePath)
+	return a.put(ctx, reader, storagePath)
+}
 
+func (a *Alist) put(ctx context.Context, reader io.Reader, storagePath string) error {
 	req, err := http.NewRequestWithContext(ctx, http.MethodPut, a.baseURL+"/api/fs/put", reader)
 	if err != nil {
 		return fmt.Errorf("failed to create request: %w", err)
 	}
 	req.Header.Set("Authorization", a.token)
-	req.Header.Set("File-Path", url.PathEscape(candidate))
+	req.Header.Set("File-Path", url.PathEscape(storagePath))
 	req.Header.Set("Content-Type", "application/octet-stream")
 	if length := ctx.Value(ctxkey.ContentLength); length != nil {
 		length, ok := length.(int64)
@@ -49,6 +49,12 @@ func (l *Local) JoinStoragePath(path string) string {
 }
 
 func (l *Local) Save(ctx context.Context, r io.Reader, storagePath string) error {
+	_, err := l.SaveAs(ctx, r, storagePath)
+	return err
+}
+
+// 保存到 storagePath, 已存在同名文件时依次尝试 name_1.ext, name_2.ext..., 返回实际保存的路径
+func (l *Local) SaveAs(ctx context.Context, r io.Reader, storagePath string) (string, error) {
 	l.logger.Infof("Saving file to %s", storagePath)
 
 	ext := filepath.Ext(storagePath)
@@ -59,19 +65,53 @@ func (l *Local) Save(ctx context.Context, r io.Reader, storagePath string) error
 	}
 
 	absPath, err := filepath.Abs(candidate)
+	if err != nil {
+		return "", err
+	}
+	if err := fileutil.CreateDir(filepath.Dir(absPath)); err != nil {
+		return "", err
+	}
+	file, err := os.Create(absPath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	if _, err := io.Copy(file, r); err != nil {
+		return "", err
+	}
+	return candidate, nil
+}
+
+// 覆盖保存到 storagePath, 先写入同目录的临时文件再重命名, 写入失败时保留原有的文件
+func (l *Local) Overwrite(ctx context.Context, r io.Reader, storagePath string) error {
+	l.logger.Infof("Overwriting file at %s", storagePath)
+
+	absPath, err := filepath.Abs(storagePath)
 	if err != nil {
 		return err
 	}
 	if err := fileutil.CreateDir(filepath.Dir(absPath)); err != nil {
 		return err
 	}
-	file, err := os.Create(absPath)
+	file, err := os.CreateTemp(filepath.Dir(absPath), "."+filepath.Base(absPath)+".*.tmp")
 	if err != nil {
 		return err
 	}
-	defer file.Close()
-	_, err = io.Copy(file, r)
-	return err
+	tmpPath := file.Name()
+	if _, err := io.Copy(file, r); err != nil {
+		file.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Rename(tmpPath, absPath); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return nil
 }
 
 func (l *Local) Exists(ctx context.Context, storagePath string) bool {
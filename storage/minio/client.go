@@ -79,6 +79,16 @@ func (m *Minio) Save(ctx context.Context, r io.Reader, storagePath string) error
 			break
 		}
 	}
+	return m.put(ctx, r, candidate)
+}
+
+// 覆盖保存到 storagePath, 同名对象会被替换
+func (m *Minio) Overwrite(ctx context.Context, r io.Reader, storagePath string) error {
+	m.logger.Infof("Overwriting object at %s", storagePath)
+	return m.put(ctx, r, storagePath)
+}
+
+func (m *Minio) put(ctx context.Context, r io.Reader, storagePath string) error {
 	size := int64(-1)
 	if length := ctx.Value(ctxkey.ContentLength); length != nil {
 		length, ok := length.(int64)
@@ -86,7 +96,7 @@ func (m *Minio) Save(ctx context.Context, r io.Reader, storagePath string) error
 			size = length
 		}
 	}
-	_, err := m.client.PutObject(ctx, m.config.BucketName, candidate, r, size, minio.PutObjectOptions{})
+	_, err := m.client.PutObject(ctx, m.config.BucketName, storagePath, r, size, minio.PutObjectOptions{})
 	if err != nil {
 		return fmt.Errorf("failed to upload file to minio: %w", err)
 	}
@@ -28,6 +28,35 @@ type StorageCannotStream interface {
 	CannotStream() string
 }
 
+// 可以覆盖同名文件的存储端. Save 遇到同名文件时会另存为带后缀的新文件,
+// 需要固定路径的文件 (如重新生成的归档页面) 使用 Overwrite
+type StorageOverwriter interface {
+	Storage
+	Overwrite(ctx context.Context, reader io.Reader, storagePath string) error
+}
+
+// 保存到固定的 storagePath, 存储端不支持覆盖时退回 Save
+func Overwrite(ctx context.Context, stor Storage, reader io.Reader, storagePath string) error {
+	if o, ok := stor.(StorageOverwriter); ok {
+		return o.Overwrite(ctx, reader, storagePath)
+	}
+	return stor.Save(ctx, reader, storagePath)
+}
+
+// 遇到同名文件时另存为带后缀的新文件, 并能返回实际保存路径的存储端
+type StorageRenamer interface {
+	Storage
+	SaveAs(ctx context.Context, reader io.Reader, storagePath string) (string, error)
+}
+
+// 保存文件并返回实际的保存路径, 存储端不会另存时即为 storagePath
+func Save(ctx context.Context, stor Storage, reader io.Reader, storagePath string) (string, error) {
+	if r, ok := stor.(StorageRenamer); ok {
+		return r.SaveAs(ctx, reader, storagePath)
+	}
+	return storagePath, stor.Save(ctx, reader, storagePath)
+}
+
 var Storages = make(map[string]Storage)
 
 type StorageConstructor func() Storage
@@ -63,13 +63,22 @@ func (w *Webdav) Save(ctx context.Context, r io.Reader, storagePath string) erro
 			break
 		}
 	}
+	return w.put(ctx, r, candidate)
+}
+
+// 覆盖保存到 storagePath, WebDAV 的 PUT 会替换已有的文件
+func (w *Webdav) Overwrite(ctx context.Context, r io.Reader, storagePath string) error {
+	w.logger.Infof("Overwriting file at %s", storagePath)
+	return w.put(ctx, r, storagePath)
+}
 
-	if err := w.client.MkDir(ctx, path.Dir(candidate)); err != nil {
-		w.logger.Errorf("Failed to create directory %s: %v", path.Dir(candidate), err)
+func (w *Webdav) put(ctx context.Context, r io.Reader, storagePath string) error {
+	if err := w.client.MkDir(ctx, path.Dir(storagePath)); err != nil {
+		w.logger.Errorf("Failed to create directory %s: %v", path.Dir(storagePath), err)
 		return ErrFailedToCreateDirectory
 	}
-	if err := w.client.WriteFile(ctx, candidate, r); err != nil {
-		w.logger.Errorf("Failed to write file %s: %v", candidate, err)
+	if err := w.client.WriteFile(ctx, storagePath, r); err != nil {
+		w.logger.Errorf("Failed to write file %s: %v", storagePath, err)
 		return ErrFailedToWriteFile
 	}
 	return nil